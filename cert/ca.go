@@ -0,0 +1,382 @@
+package cert
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+const (
+	ecPrivateKeyConst        = "EC PRIVATE KEY"
+	certificateRequestConst  = "CERTIFICATE REQUEST"
+	defaultCAValidity        = time.Duration(87600) * time.Hour // 10 years
+	defaultLeafValidity      = time.Duration(8760) * time.Hour  // 1 year
+	defaultRotationOverlap   = time.Duration(720) * time.Hour   // 30 days
+	notBeforeClockSkewMargin = 5 * time.Minute
+)
+
+// Usage describes what an issued leaf certificate is used for
+type Usage int
+
+const (
+	// UsageServer marks a certificate presented by a TLS server
+	UsageServer Usage = iota
+	// UsageClient marks a certificate presented by a TLS client
+	UsageClient
+)
+
+// LeafOptions describes a leaf certificate to issue or request
+type LeafOptions struct {
+	CommonName  string
+	DNSNames    []string
+	IPAddresses []net.IP
+	Usage       Usage
+	Validity    time.Duration // defaults to one year when zero
+}
+
+// CA is a certificate authority with an ECDSA root that issues leaf server and client certificates
+type CA struct {
+	Cert *x509.Certificate
+	Key  *ecdsa.PrivateKey
+}
+
+// Leaf is a certificate issued by a CA together with its private key
+type Leaf struct {
+	Cert *x509.Certificate
+	Key  *ecdsa.PrivateKey
+}
+
+// NewCA creates a self-signed ECDSA P-256 root valid for the given duration (10 years when zero)
+func NewCA(cn string, validity time.Duration) (*CA, error) {
+	log.Tracef(">>>>> NewCA called with %s", cn)
+	defer log.Trace("<<<<< NewCA")
+
+	if cn == "" {
+		return nil, errors.New("common name cannot be empty")
+	}
+	if validity <= 0 {
+		validity = defaultCAValidity
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.New("generating random key: " + err.Error())
+	}
+	tmpl, err := ecdsaTemplate(cn, validity)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.IsCA = true
+	tmpl.MaxPathLenZero = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	tmpl.SubjectKeyId = subjectKeyID(&key.PublicKey)
+
+	rootCert, err := createCert(tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, errors.New("error creating cert " + err.Error())
+	}
+	return &CA{Cert: rootCert, Key: key}, nil
+}
+
+// LoadCA loads a CA from its PEM encoded certificate and EC private key
+func LoadCA(certPEM, keyPEM string) (*CA, error) {
+	rootCert, err := ParseCertPem(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	if !rootCert.IsCA {
+		return nil, fmt.Errorf("certificate %s is not a CA certificate", rootCert.Subject.CommonName)
+	}
+	key, err := ParseECKeyPem(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if !key.PublicKey.Equal(rootCert.PublicKey) {
+		return nil, errors.New("private key does not match the CA certificate")
+	}
+	return &CA{Cert: rootCert, Key: key}, nil
+}
+
+// CertPEM returns the PEM encoded CA certificate
+func (ca *CA) CertPEM() (string, error) {
+	return ConvertCertToPem(ca.Cert)
+}
+
+// KeyPEM returns the PEM encoded CA private key
+func (ca *CA) KeyPEM() (string, error) {
+	return ConvertECKeyToPem(ca.Key)
+}
+
+// CertPool returns a pool containing only the CA certificate
+func (ca *CA) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	return pool
+}
+
+// Issue generates a new ECDSA key and a leaf certificate for it signed by the CA
+func (ca *CA) Issue(opts *LeafOptions) (*Leaf, error) {
+	log.Tracef(">>>>> Issue called with %+v", opts)
+	defer log.Trace("<<<<< Issue")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.New("generating random key: " + err.Error())
+	}
+	leafCert, err := ca.sign(opts, &key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Leaf{Cert: leafCert, Key: key}, nil
+}
+
+// IssueServerCert issues a server certificate for the given names, names that parse as IP addresses are added as IP SANs
+func (ca *CA) IssueServerCert(cn string, names []string, validity time.Duration) (*Leaf, error) {
+	opts := &LeafOptions{CommonName: cn, Usage: UsageServer, Validity: validity}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			opts.IPAddresses = append(opts.IPAddresses, ip)
+		} else {
+			opts.DNSNames = append(opts.DNSNames, name)
+		}
+	}
+	return ca.Issue(opts)
+}
+
+// IssueClientCert issues a client certificate for the given common name
+func (ca *CA) IssueClientCert(cn string, validity time.Duration) (*Leaf, error) {
+	return ca.Issue(&LeafOptions{CommonName: cn, Usage: UsageClient, Validity: validity})
+}
+
+// SignCSR verifies the PEM encoded certificate request and issues a certificate for it.  Only the names of the
+// request are honored, usage and validity are decided by the CA.
+func (ca *CA) SignCSR(csrPEM string, usage Usage, validity time.Duration) (*x509.Certificate, error) {
+	log.Trace(">>>>> SignCSR")
+	defer log.Trace("<<<<< SignCSR")
+
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil || block.Type != certificateRequestConst {
+		return nil, errors.New("invalid certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err = csr.CheckSignature(); err != nil {
+		return nil, errors.New("certificate request signature is invalid: " + err.Error())
+	}
+	opts := &LeafOptions{
+		CommonName:  csr.Subject.CommonName,
+		DNSNames:    csr.DNSNames,
+		IPAddresses: csr.IPAddresses,
+		Usage:       usage,
+		Validity:    validity,
+	}
+	return ca.sign(opts, csr.PublicKey)
+}
+
+// Rotate issues a replacement for the leaf once it is within overlap of its expiry (30 days when zero).  The
+// replacement keeps the names and usage of the leaf and gets a new key, so both remain valid during the overlap.
+// The leaf is returned unchanged with false when no rotation is needed yet.
+func (ca *CA) Rotate(leaf *Leaf, validity, overlap time.Duration) (*Leaf, bool, error) {
+	if !NeedsRotation(leaf.Cert, overlap, time.Now()) {
+		return leaf, false, nil
+	}
+	log.Infof("rotating certificate %s expiring at %s", leaf.Cert.Subject.CommonName, leaf.Cert.NotAfter)
+	usage := UsageServer
+	for _, extUsage := range leaf.Cert.ExtKeyUsage {
+		if extUsage == x509.ExtKeyUsageClientAuth {
+			usage = UsageClient
+		}
+	}
+	rotated, err := ca.Issue(&LeafOptions{
+		CommonName:  leaf.Cert.Subject.CommonName,
+		DNSNames:    leaf.Cert.DNSNames,
+		IPAddresses: leaf.Cert.IPAddresses,
+		Usage:       usage,
+		Validity:    validity,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return rotated, true, nil
+}
+
+// RotateCA creates a new root with the same common name once the CA is within overlap of its expiry.  Peers should
+// trust both roots (see CertPoolOf) until the old root expires.
+func (ca *CA) RotateCA(validity, overlap time.Duration) (*CA, bool, error) {
+	if !NeedsRotation(ca.Cert, overlap, time.Now()) {
+		return ca, false, nil
+	}
+	log.Infof("rotating CA %s expiring at %s", ca.Cert.Subject.CommonName, ca.Cert.NotAfter)
+	rotated, err := NewCA(ca.Cert.Subject.CommonName, validity)
+	if err != nil {
+		return nil, false, err
+	}
+	return rotated, true, nil
+}
+
+// NeedsRotation returns true when the certificate expires within overlap (30 days when zero) of now
+func NeedsRotation(c *x509.Certificate, overlap time.Duration, now time.Time) bool {
+	if overlap <= 0 {
+		overlap = defaultRotationOverlap
+	}
+	return !now.Add(overlap).Before(c.NotAfter)
+}
+
+// CertPoolOf returns a pool trusting all of the given CAs, used to accept both roots during a CA rotation
+func CertPoolOf(cas ...*CA) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, ca := range cas {
+		if ca != nil {
+			pool.AddCert(ca.Cert)
+		}
+	}
+	return pool
+}
+
+// CertPEM returns the PEM encoded leaf certificate
+func (leaf *Leaf) CertPEM() (string, error) {
+	return ConvertCertToPem(leaf.Cert)
+}
+
+// KeyPEM returns the PEM encoded leaf private key
+func (leaf *Leaf) KeyPEM() (string, error) {
+	return ConvertECKeyToPem(leaf.Key)
+}
+
+// TLSCertificate returns the leaf as a tls.Certificate, chained with the issuing CA when given
+func (leaf *Leaf) TLSCertificate(ca *CA) tls.Certificate {
+	chain := [][]byte{leaf.Cert.Raw}
+	if ca != nil {
+		chain = append(chain, ca.Cert.Raw)
+	}
+	return tls.Certificate{Certificate: chain, PrivateKey: leaf.Key, Leaf: leaf.Cert}
+}
+
+// GenerateCSR generates a new ECDSA key and a PEM encoded certificate request for it
+func GenerateCSR(opts *LeafOptions) (string, *ecdsa.PrivateKey, error) {
+	log.Tracef(">>>>> GenerateCSR called with %+v", opts)
+	defer log.Trace("<<<<< GenerateCSR")
+
+	if opts == nil || opts.CommonName == "" {
+		return "", nil, errors.New("common name cannot be empty")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", nil, errors.New("generating random key: " + err.Error())
+	}
+	tmpl := &x509.CertificateRequest{
+		Subject:            pkix.Name{Organization: []string{organization}, CommonName: opts.CommonName},
+		DNSNames:           opts.DNSNames,
+		IPAddresses:        opts.IPAddresses,
+		SignatureAlgorithm: x509.ECDSAWithSHA256,
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: certificateRequestConst, Bytes: csrDER})), key, nil
+}
+
+// ParseCertPem parses the first certificate of a PEM block
+func ParseCertPem(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != certificateConst {
+		return nil, errors.New("invalid certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// ParseECKeyPem parses a PEM encoded EC private key
+func ParseECKeyPem(keyPEM string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil || block.Type != ecPrivateKeyConst {
+		return nil, errors.New("invalid EC private key")
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+// ConvertECKeyToPem returns the PEM encoding of an EC private key
+func ConvertECKeyToPem(key *ecdsa.PrivateKey) (string, error) {
+	if key == nil {
+		return "", errors.New("invalid private key")
+	}
+	b, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: ecPrivateKeyConst, Bytes: b})), nil
+}
+
+// sign issues a certificate for the public key described by opts
+func (ca *CA) sign(opts *LeafOptions, pub crypto.PublicKey) (*x509.Certificate, error) {
+	if opts == nil || opts.CommonName == "" {
+		return nil, errors.New("common name cannot be empty")
+	}
+	validity := opts.Validity
+	if validity <= 0 {
+		validity = defaultLeafValidity
+	}
+	tmpl, err := ecdsaTemplate(opts.CommonName, validity)
+	if err != nil {
+		return nil, err
+	}
+	// a leaf never outlives its issuer
+	if tmpl.NotAfter.After(ca.Cert.NotAfter) {
+		tmpl.NotAfter = ca.Cert.NotAfter
+	}
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	switch opts.Usage {
+	case UsageServer:
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	case UsageClient:
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	default:
+		return nil, fmt.Errorf("unknown certificate usage %d", opts.Usage)
+	}
+	tmpl.DNSNames = opts.DNSNames
+	tmpl.IPAddresses = opts.IPAddresses
+	tmpl.AuthorityKeyId = ca.Cert.SubjectKeyId
+
+	leafCert, err := createCert(tmpl, ca.Cert, pub, ca.Key)
+	if err != nil {
+		return nil, errors.New("error creating cert " + err.Error())
+	}
+	return leafCert, nil
+}
+
+// ecdsaTemplate returns a certificate template signed with ECDSA, backdated slightly to tolerate clock skew
+func ecdsaTemplate(cn string, validity time.Duration) (*x509.Certificate, error) {
+	tmpl, err := certTemplate()
+	if err != nil {
+		return nil, errors.New("error creating cert template: " + err.Error())
+	}
+	now := time.Now()
+	tmpl.SignatureAlgorithm = x509.ECDSAWithSHA256
+	tmpl.Subject.CommonName = cn
+	tmpl.NotBefore = now.Add(-notBeforeClockSkewMargin)
+	tmpl.NotAfter = now.Add(validity)
+	return tmpl, nil
+}
+
+// subjectKeyID derives the subject key identifier of an EC public key
+func subjectKeyID(pub *ecdsa.PublicKey) []byte {
+	b, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil
+	}
+	sum := sha1.Sum(b)
+	return sum[:]
+}
@@ -0,0 +1,134 @@
+package cert
+
+import (
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIssueServerAndClientCerts(t *testing.T) {
+	ca, err := NewCA("chapid-ca", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ca.Cert.IsCA || ca.Cert.SignatureAlgorithm != x509.ECDSAWithSHA256 {
+		t.Fatalf("unexpected CA certificate %+v", ca.Cert)
+	}
+
+	server, err := ca.IssueServerCert("chapid", []string{"localhost", "127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Cert.DNSNames) != 1 || len(server.Cert.IPAddresses) != 1 || !server.Cert.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("unexpected server names %v %v", server.Cert.DNSNames, server.Cert.IPAddresses)
+	}
+	if _, err = server.Cert.Verify(x509.VerifyOptions{Roots: ca.CertPool(), DNSName: "localhost", KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}); err != nil {
+		t.Errorf("server certificate does not verify, %s", err.Error())
+	}
+	if server.Cert.NotAfter.Sub(server.Cert.NotBefore) > time.Hour+notBeforeClockSkewMargin {
+		t.Errorf("unexpected server validity %s - %s", server.Cert.NotBefore, server.Cert.NotAfter)
+	}
+
+	client, err := ca.IssueClientCert("host1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Cert.Verify(x509.VerifyOptions{Roots: ca.CertPool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Errorf("client certificate does not verify, %s", err.Error())
+	}
+	if _, err = client.Cert.Verify(x509.VerifyOptions{Roots: ca.CertPool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}); err == nil {
+		t.Error("expected a client certificate to be rejected for server authentication")
+	}
+
+	// a leaf never outlives its CA
+	shortCA, _ := NewCA("short-ca", time.Hour)
+	leaf, err := shortCA.IssueClientCert("host1", 24*time.Hour)
+	if err != nil || leaf.Cert.NotAfter.After(shortCA.Cert.NotAfter) {
+		t.Errorf("expected the leaf to expire with the CA, got %v %v", leaf, err)
+	}
+
+	if _, err = ca.IssueClientCert("", 0); err == nil {
+		t.Error("expected an error for an empty common name")
+	}
+}
+
+func TestLoadCA(t *testing.T) {
+	ca, _ := NewCA("chapid-ca", 0)
+	certPEM, _ := ca.CertPEM()
+	keyPEM, _ := ca.KeyPEM()
+	loaded, err := LoadCA(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Cert.Equal(ca.Cert) {
+		t.Error("expected the loaded CA certificate to match")
+	}
+
+	other, _ := NewCA("other-ca", 0)
+	otherKeyPEM, _ := other.KeyPEM()
+	if _, err = LoadCA(certPEM, otherKeyPEM); err == nil {
+		t.Error("expected an error for a mismatched key")
+	}
+	leaf, _ := ca.IssueClientCert("host1", 0)
+	leafPEM, _ := leaf.CertPEM()
+	if _, err = LoadCA(leafPEM, keyPEM); err == nil {
+		t.Error("expected an error for a non CA certificate")
+	}
+}
+
+func TestSignCSR(t *testing.T) {
+	ca, _ := NewCA("chapid-ca", 0)
+	csrPEM, key, err := GenerateCSR(&LeafOptions{CommonName: "array1", DNSNames: []string{"array1.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := ca.SignCSR(csrPEM, UsageServer, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.PublicKey.Equal(signed.PublicKey) || signed.Subject.CommonName != "array1" || signed.DNSNames[0] != "array1.example.com" {
+		t.Errorf("unexpected signed certificate %+v", signed)
+	}
+	if _, err = ca.SignCSR("not a csr", UsageServer, 0); err == nil {
+		t.Error("expected an error for an invalid request")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	ca, _ := NewCA("chapid-ca", 0)
+	leaf, _ := ca.IssueClientCert("host1", 24*time.Hour)
+
+	same, rotated, err := ca.Rotate(leaf, 48*time.Hour, time.Hour)
+	if err != nil || rotated || same != leaf {
+		t.Errorf("expected no rotation outside the overlap, got %v %v", rotated, err)
+	}
+	next, rotated, err := ca.Rotate(leaf, 48*time.Hour, 48*time.Hour)
+	if err != nil || !rotated {
+		t.Fatalf("expected a rotation inside the overlap, got %v %v", rotated, err)
+	}
+	if next.Cert.Subject.CommonName != "host1" || next.Cert.ExtKeyUsage[0] != x509.ExtKeyUsageClientAuth || next.Key.Equal(leaf.Key) {
+		t.Errorf("unexpected rotated certificate %+v", next.Cert)
+	}
+	if !next.Cert.NotAfter.After(leaf.Cert.NotAfter) {
+		t.Error("expected the rotated certificate to outlive the original")
+	}
+
+	nextCA, rotated, err := ca.RotateCA(0, 20*365*24*time.Hour)
+	if err != nil || !rotated {
+		t.Fatalf("expected the CA to rotate, got %v %v", rotated, err)
+	}
+	// during the overlap, leaves of both roots verify against the combined pool
+	nextLeaf, _ := nextCA.IssueClientCert("host1", 0)
+	pool := CertPoolOf(ca, nextCA)
+	for _, c := range []*x509.Certificate{leaf.Cert, nextLeaf.Cert} {
+		if _, err = c.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+			t.Errorf("expected %s to verify during the overlap, %s", c.Issuer, err.Error())
+		}
+	}
+
+	now := time.Now()
+	if NeedsRotation(leaf.Cert, time.Hour, now) || !NeedsRotation(leaf.Cert, time.Hour, now.Add(24*time.Hour)) {
+		t.Error("unexpected rotation decision")
+	}
+}
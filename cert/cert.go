@@ -8,10 +8,10 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"errors"
-	"fmt"
 	log "github.com/hpe-storage/common-host-libs/logger"
 	"github.com/hpe-storage/common-host-libs/util"
 	"math/big"
+	"net"
 	"os"
 	"time"
 )
@@ -124,13 +124,35 @@ func ConvertCertToPem(cert *x509.Certificate) (string, error) {
 	return certPem, nil
 }
 
-//GetCertFromGroup :
+//GetCertFromGroup : returns the certificate of the group checked against the trust store at DefaultTrustStorePath.
+// The certificate is not pinned, see PinCertOfGroup.
 func GetCertFromGroup(ipAddress string, port string) (*x509.Certificate, error) {
 	log.Tracef("GetCertFromGroup called with %s:%s ", ipAddress, port)
-	addr := fmt.Sprintf("%s:%s", ipAddress, port)
-	config := &tls.Config{
-		InsecureSkipVerify: true,
+	store, err := NewTrustStore(DefaultTrustStorePath)
+	if err != nil {
+		return nil, err
+	}
+	return GetPinnedCertFromGroup(store, ipAddress, port)
+}
+
+//PinCertOfGroup : pins the certificate of the group in the trust store at DefaultTrustStorePath unless one is pinned
+// already
+func PinCertOfGroup(ipAddress string, port string, c *x509.Certificate) error {
+	log.Tracef("PinCertOfGroup called with %s:%s ", ipAddress, port)
+	store, err := NewTrustStore(DefaultTrustStorePath)
+	if err != nil {
+		return err
+	}
+	address := net.JoinHostPort(ipAddress, port)
+	if store.Get(address) != nil {
+		return store.Check(address, c)
 	}
+	log.Infof("pinning certificate %s with fingerprint %s for %s", c.Subject.CommonName, Fingerprint(c), address)
+	return store.Pin(address, c)
+}
+
+// getCertFromAddress returns the leaf certificate presented by addr during a handshake with the given config
+func getCertFromAddress(addr string, config *tls.Config) (*x509.Certificate, error) {
 	conn, err := tls.Dial("tcp", addr, config)
 	if err != nil {
 		return nil, err
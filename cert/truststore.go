@@ -0,0 +1,225 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package cert
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+var (
+	// DefaultTrustStorePath is where the certificates pinned for arrays are persisted
+	DefaultTrustStorePath = "/etc/hpe-storage/truststore.json"
+)
+
+// PinnedCert is a certificate pinned for an address
+type PinnedCert struct {
+	Fingerprint string    `json:"fingerprint"` // hex encoded SHA-256 of the DER certificate
+	Subject     string    `json:"subject,omitempty"`
+	NotAfter    time.Time `json:"notAfter"`
+	PinnedAt    time.Time `json:"pinnedAt"`
+}
+
+// TrustStore pins the certificate presented by an array on first use and rejects any other certificate for the same
+// address afterwards.  Arrays present self-signed certificates, so the pin takes the place of chain verification.
+type TrustStore struct {
+	lock sync.Mutex
+	path string // empty for a store that is only kept in memory
+	pins map[string]*PinnedCert
+}
+
+// NewTrustStore loads the trust store persisted at path, a missing file is an empty store and an empty path keeps
+// the store in memory only
+func NewTrustStore(path string) (*TrustStore, error) {
+	store := &TrustStore{path: path, pins: make(map[string]*PinnedCert)}
+	if path == "" {
+		return store, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, err
+	}
+	if len(data) != 0 {
+		if err = json.Unmarshal(data, &store.pins); err != nil {
+			return nil, fmt.Errorf("unable to parse trust store %s, %s", path, err.Error())
+		}
+	}
+	return store, nil
+}
+
+// Fingerprint returns the hex encoded SHA-256 fingerprint of a certificate
+func Fingerprint(c *x509.Certificate) string {
+	sum := sha256.Sum256(c.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the certificate pinned for address, or nil when none is
+func (store *TrustStore) Get(address string) *PinnedCert {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if pin, ok := store.pins[normalizeAddress(address)]; ok {
+		copy := *pin
+		return &copy
+	}
+	return nil
+}
+
+// Pin pins the certificate for address, replacing any existing pin.  It is used to accept a rotated array certificate.
+func (store *TrustStore) Pin(address string, c *x509.Certificate) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.pin(normalizeAddress(address), c)
+}
+
+// Remove forgets the certificate pinned for address
+func (store *TrustStore) Remove(address string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	address = normalizeAddress(address)
+	if _, ok := store.pins[address]; !ok {
+		return nil
+	}
+	delete(store.pins, address)
+	return store.save()
+}
+
+// Verify pins the certificate when nothing is pinned for address yet and otherwise checks it matches the pin.  A pin
+// that cannot be saved is logged and kept in memory rather than failing the verification.
+func (store *TrustStore) Verify(address string, c *x509.Certificate) error {
+	if c == nil {
+		return errors.New("no certificate presented by " + address)
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	address = normalizeAddress(address)
+	fingerprint := Fingerprint(c)
+	pin, ok := store.pins[address]
+	if !ok {
+		log.Infof("pinning certificate %s with fingerprint %s for %s on first use", c.Subject.CommonName, fingerprint, address)
+		if err := store.pin(address, c); err != nil {
+			// the handshake is trusted regardless, the pin is kept in memory and persisted with the next save
+			log.Errorf("unable to save the certificate pinned for %s to %s, %s", address, store.path, err.Error())
+		}
+		return nil
+	}
+	return matchPin(address, pin, fingerprint)
+}
+
+// Check checks the certificate matches the pin for address without pinning it when nothing is pinned yet
+func (store *TrustStore) Check(address string, c *x509.Certificate) error {
+	if c == nil {
+		return errors.New("no certificate presented by " + address)
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	address = normalizeAddress(address)
+	pin, ok := store.pins[address]
+	if !ok {
+		return nil
+	}
+	return matchPin(address, pin, Fingerprint(c))
+}
+
+// VerifyPeerCertificate returns a tls.Config callback that verifies the leaf certificate presented by address
+func (store *TrustStore) VerifyPeerCertificate(address string) func([][]byte, [][]*x509.Certificate) error {
+	return peerCertificateCallback(address, store.Verify)
+}
+
+// TLSConfig returns a client tls.Config that trusts address only through its pinned certificate.  Chain verification
+// is skipped as the pin check replaces it.
+func (store *TrustStore) TLSConfig(address string) *tls.Config {
+	return &tls.Config{
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: store.VerifyPeerCertificate(address),
+		MinVersion:            tls.VersionTLS12,
+	}
+}
+
+// GetPinnedCertFromGroup fetches the certificate of the group, the handshake fails when a different certificate is
+// pinned in the trust store.  A certificate that is not pinned yet is returned without pinning it, it is up to the
+// caller to Pin it once the group is trusted.
+func GetPinnedCertFromGroup(store *TrustStore, ipAddress string, port string) (*x509.Certificate, error) {
+	address := net.JoinHostPort(ipAddress, port)
+	config := &tls.Config{
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: peerCertificateCallback(address, store.Check),
+		MinVersion:            tls.VersionTLS12,
+	}
+	return getCertFromAddress(address, config)
+}
+
+// peerCertificateCallback returns a tls.Config callback that passes the leaf certificate presented by address to check
+func peerCertificateCallback(address string, check func(string, *x509.Certificate) error) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no certificate presented by " + address)
+		}
+		c, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return err
+		}
+		return check(address, c)
+	}
+}
+
+// matchPin fails unless fingerprint is the one pinned for address
+func matchPin(address string, pin *PinnedCert, fingerprint string) error {
+	if pin.Fingerprint != fingerprint {
+		return fmt.Errorf("certificate presented by %s with fingerprint %s does not match the pinned fingerprint %s",
+			address, fingerprint, pin.Fingerprint)
+	}
+	return nil
+}
+
+func (store *TrustStore) pin(address string, c *x509.Certificate) error {
+	store.pins[address] = &PinnedCert{
+		Fingerprint: Fingerprint(c),
+		Subject:     c.Subject.CommonName,
+		NotAfter:    c.NotAfter,
+		PinnedAt:    time.Now(),
+	}
+	return store.save()
+}
+
+// save writes the store through a temporary file so a crash never leaves a truncated store behind
+func (store *TrustStore) save() error {
+	if store.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(store.pins, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(store.path), 0700); err != nil {
+		return err
+	}
+	tmp := store.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, store.path)
+}
+
+// normalizeAddress lower cases the address so host names pin case-insensitively
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
@@ -0,0 +1,137 @@
+package cert
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrustStorePinning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "truststore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hpe", "truststore.json")
+
+	store, err := NewTrustStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := NewCA("array-ca", 0)
+	first, _ := ca.IssueServerCert("array1", nil, 0)
+	second, _ := ca.IssueServerCert("array1", nil, 0)
+
+	// first use pins the certificate, which is persisted
+	if err = store.Verify("10.0.0.1:443", first.Cert); err != nil {
+		t.Fatal(err)
+	}
+	if pin := store.Get("10.0.0.1:443"); pin == nil || pin.Fingerprint != Fingerprint(first.Cert) || pin.Subject != "array1" {
+		t.Errorf("unexpected pin %+v", pin)
+	}
+	reloaded, err := NewTrustStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = reloaded.Verify("10.0.0.1:443", first.Cert); err != nil {
+		t.Errorf("expected the persisted pin to match, %s", err.Error())
+	}
+	if err = reloaded.Verify("10.0.0.1:443", second.Cert); err == nil {
+		t.Error("expected a different certificate to be rejected")
+	}
+
+	// explicitly pinning a rotated certificate replaces the pin
+	if err = reloaded.Pin("10.0.0.1:443", second.Cert); err != nil {
+		t.Fatal(err)
+	}
+	if err = reloaded.Verify("10.0.0.1:443", first.Cert); err == nil {
+		t.Error("expected the previous certificate to be rejected after re-pinning")
+	}
+	if err = reloaded.Remove("10.0.0.1:443"); err != nil || reloaded.Get("10.0.0.1:443") != nil {
+		t.Errorf("expected the pin to be removed, %v", err)
+	}
+}
+
+func TestTrustStoreTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	store, _ := NewTrustStore("")
+	address := server.Listener.Addr().String()
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: store.TLSConfig(address)}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request %d failed, %s", i, err.Error())
+		}
+		resp.Body.Close()
+	}
+	if store.Get(address) == nil {
+		t.Fatal("expected the server certificate to be pinned")
+	}
+
+	// a server presenting another certificate under the pinned address is rejected
+	ca, _ := NewCA("array-ca", 0)
+	other, _ := ca.IssueServerCert("array1", nil, 0)
+	if err := store.Pin(address, other.Cert); err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: store.TLSConfig(address)}}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Error("expected a pin mismatch to fail the handshake")
+	}
+}
+
+func TestTrustStoreVerifyUnsavedPin(t *testing.T) {
+	file, err := ioutil.TempFile("", "truststore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// the store cannot be saved below a regular file
+	store, _ := NewTrustStore("")
+	store.path = filepath.Join(file.Name(), "truststore.json")
+	ca, _ := NewCA("array-ca", 0)
+	first, _ := ca.IssueServerCert("array1", nil, 0)
+	second, _ := ca.IssueServerCert("array1", nil, 0)
+	if err = store.Verify("10.0.0.1:443", first.Cert); err != nil {
+		t.Fatalf("expected an unsaved pin not to fail verification, %s", err.Error())
+	}
+	if err = store.Verify("10.0.0.1:443", second.Cert); err == nil {
+		t.Error("expected the in-memory pin to reject a different certificate")
+	}
+}
+
+func TestGetPinnedCertFromGroup(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	store, _ := NewTrustStore("")
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	groupCert, err := GetPinnedCertFromGroup(store, host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin := store.Get(server.Listener.Addr().String()); pin != nil {
+		t.Errorf("expected fetching the certificate not to pin it, got %+v", pin)
+	}
+	if err = store.Pin(server.Listener.Addr().String(), groupCert); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = GetPinnedCertFromGroup(store, host, port); err != nil {
+		t.Errorf("expected the pinned certificate to be accepted, %s", err.Error())
+	}
+
+	ca, _ := NewCA("array-ca", 0)
+	other, _ := ca.IssueServerCert("array1", nil, 0)
+	store.Pin(server.Listener.Addr().String(), other.Cert)
+	if _, err = GetPinnedCertFromGroup(store, host, port); err == nil {
+		t.Error("expected a pin mismatch to fail the handshake")
+	}
+}
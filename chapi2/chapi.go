@@ -10,6 +10,13 @@ import (
 
 // NewRouter creates a new mux.Router
 func NewRouter() *mux.Router {
+	router := mux.NewRouter().StrictSlash(true)
+	util.InitializeRouter(router, getRoutes())
+	return router
+}
+
+// getRoutes returns the CHAPI endpoints, including the platform specific endpoints
+func getRoutes() []util.Route {
 	routes := []util.Route{
 		///////////////////////////////////////////////////////////////////////////////////////////
 		// Endpoint:  		GET /hosts
@@ -236,6 +243,278 @@ func NewRouter() *mux.Router {
 			HandlerFunc: handler.OfflineDevice,
 		},
 
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		PUT /api/v1/devices/{serialNumber}/actions/expand
+		// Description: 	Picks up the new capacity of the device after the volume was grown on the
+		//					array.  Every path is rescanned, the multipath map and any LUKS mapping
+		//					are resized, and the mounted file systems (xfs, ext2/3/4, btrfs) are
+		//					grown to fill the device.  Partitioned devices are not supported.
+		// Input Object:	None
+		// Output Object:	chapi2.Device object with the new size
+		// Sample Output:
+		// {
+		//     "data": {
+		//         "serial_number": "28174883c7719ac236c9ce900...",
+		//         "path_name": "dm-3",
+		//         "alt_full_path_name": "/dev/mapper/mpathg",
+		//         "size": 21474836480
+		//     }
+		// }
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "ExpandDevice",
+			Method:      "PUT",
+			Pattern:     "/api/v1/devices/{serialNumber}/actions/expand",
+			HandlerFunc: handler.ExpandDevice,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		PUT /api/v1/devices/{serialNumber}/actions/fscheck
+		// Description: 	Runs a read-only check of the file system (ext2/3/4, xfs or btrfs) on the
+		//					device.  The file system must not be mounted nor published as a block device.
+		// Input Object:	None
+		// Output Object:	chapi2.FileSystemCheck object
+		// Sample Output:
+		// {
+		//     "data": {
+		//         "serial_number": "28174883c7719ac236c9ce900...",
+		//         "device_path": "/dev/mapper/mpathg",
+		//         "fs_type": "xfs",
+		//         "repair": false,
+		//         "errors_found": true,
+		//         "repaired": false,
+		//         "exit_code": 1,
+		//         "output": "Phase 1 - find and verify superblock...\n..."
+		//     }
+		// }
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "CheckFileSystem",
+			Method:      "PUT",
+			Pattern:     "/api/v1/devices/{serialNumber}/actions/fscheck",
+			HandlerFunc: handler.CheckFileSystem,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		PUT /api/v1/devices/{serialNumber}/actions/fsrepair
+		// Description: 	Repairs the file system on the device.  A read-only check is run first
+		//					and the repair is only attempted when errors are found.  The file system
+		//					must not be mounted nor published as a block device, and no other host
+		//					may have its reservation key registered with the device.  Hosts are only
+		//					detected through their persistent reservation keys, a device without
+		//					persistent reservation support is not repaired, and btrfs file systems
+		//					are only checked.
+		// Input Object:	None
+		// Output Object:	chapi2.FileSystemCheck object
+		// Sample Output:	See "PUT /api/v1/devices/{serialNumber}/actions/fscheck" endpoint
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "RepairFileSystem",
+			Method:      "PUT",
+			Pattern:     "/api/v1/devices/{serialNumber}/actions/fsrepair",
+			HandlerFunc: handler.RepairFileSystem,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		GET /api/v1/devices/{serialNumber}/encryption
+		// Description: 	Reports the LUKS header details of the device (version, cipher, volume key
+		//					size and key slots in use) and whether its LUKS mapping is opened.
+		// Input Object:	None
+		// Output Object:	chapi2.Encryption object
+		// Sample Output:
+		// {
+		//     "data": {
+		//         "serial_number": "28174883c7719ac236c9ce900...",
+		//         "encrypted": true,
+		//         "version": 1,
+		//         "uuid": "5a8c7e2c-8f1d-4e4b-9d1c-4f7b2e3a6d90",
+		//         "cipher": "aes-xts-plain64",
+		//         "key_size": 256,
+		//         "key_slots": [
+		//             0,
+		//             1
+		//         ],
+		//         "opened": true,
+		//         "mapped_path_name": "/dev/mapper/enc-mpathg"
+		//     }
+		// }
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "GetEncryption",
+			Method:      "GET",
+			Pattern:     "/api/v1/devices/{serialNumber}/encryption",
+			HandlerFunc: handler.GetEncryption,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		PUT /api/v1/devices/{serialNumber}/encryption
+		// Description: 	Applies an encryption action (format, open, close, add_key, remove_key
+		//					or rotate_key) to the device.  Passphrases are passed to cryptsetup on
+		//					its standard input, never logged nor written to disk.  The last key slot
+		//					cannot be removed.  Must be registered before the CreateFileSystem route
+		//					as the latter matches any second path segment.
+		// Input Object:	chapi2.EncryptionRequest object
+		// Output Object:	chapi2.Encryption object
+		// Sample Input:
+		// {
+		//     "action": "rotate_key",
+		//     "passphrase": "...",
+		//     "new_passphrase": "..."
+		// }
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "UpdateEncryption",
+			Method:      "PUT",
+			Pattern:     "/api/v1/devices/{serialNumber}/encryption",
+			HandlerFunc: handler.UpdateEncryption,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		GET /api/v1/devices/{serialNumber}/encryption/header
+		// Description: 	Returns a backup of the LUKS header of the device.  The backup holds the
+		//					volume key encrypted with the passphrases of the key slots in use.
+		// Input Object:	None
+		// Output Object:	chapi2.EncryptionHeader object
+		// Sample Output:
+		// {
+		//     "data": {
+		//         "serial_number": "28174883c7719ac236c9ce900...",
+		//         "header": "TFVLU7q+AAEAAAAAAAAAAAAAAAAAAAAAAAAAAAAA..."
+		//     }
+		// }
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "GetEncryptionHeader",
+			Method:      "GET",
+			Pattern:     "/api/v1/devices/{serialNumber}/encryption/header",
+			HandlerFunc: handler.GetEncryptionHeader,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		PUT /api/v1/devices/{serialNumber}/encryption/header
+		// Description: 	Restores the LUKS header of the device from a backup.  The LUKS mapping
+		//					of the device must be closed.
+		// Input Object:	chapi2.EncryptionHeader object
+		// Output Object:	chapi2.Encryption object
+		// Sample Output:	See "GET /api/v1/devices/{serialNumber}/encryption" endpoint
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "RestoreEncryptionHeader",
+			Method:      "PUT",
+			Pattern:     "/api/v1/devices/{serialNumber}/encryption/header",
+			HandlerFunc: handler.RestoreEncryptionHeader,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		GET /api/v1/devices/{serialNumber}/reservations
+		// Description: 	Reports the SCSI-3 persistent reservation state of the device along with
+		//					the reservation key of this host, derived from the host UUID.
+		// Input Object:	None
+		// Output Object:	chapi2.Reservation object
+		// Sample Output:
+		// {
+		//     "data": {
+		//         "serial_number": "28174883c7719ac236c9ce900...",
+		//         "host_key": "5b1c0e1d9a7f3e21",
+		//         "registered": true,
+		//         "keys": [
+		//             "5b1c0e1d9a7f3e21"
+		//         ],
+		//         "reserved": true,
+		//         "holder": "5b1c0e1d9a7f3e21",
+		//         "type": "write-exclusive-registrants-only",
+		//         "generation": 3
+		//     }
+		// }
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "GetReservation",
+			Method:      "GET",
+			Pattern:     "/api/v1/devices/{serialNumber}/reservations",
+			HandlerFunc: handler.GetReservation,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		PUT /api/v1/devices/{serialNumber}/reservations
+		// Description: 	Applies a persistent reservation action (register, unregister, reserve,
+		//					release, preempt or clear) with the key of this host on all paths of the
+		//					device.  Must be registered before the CreateFileSystem route as the
+		//					latter matches any second path segment.
+		// Input Object:	chapi2.ReservationRequest object
+		// Output Object:	chapi2.Reservation object
+		// Sample Input:
+		// {
+		//     "action": "reserve",
+		//     "type": "write-exclusive-registrants-only"
+		// }
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "UpdateReservation",
+			Method:      "PUT",
+			Pattern:     "/api/v1/devices/{serialNumber}/reservations",
+			HandlerFunc: handler.UpdateReservation,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		GET /api/v1/devices/{serialNumber}/publishes
+		// Description: 	Lists the files the device node of the volume is bind mounted onto (i.e.
+		//					the raw block volume publishes of the device).
+		// Input Object:	None
+		// Output Object:	Array of chapi2.BlockPublish objects
+		// Sample Output:
+		// {
+		//     "data": [
+		//         {
+		//             "serial_number": "28174883c7719ac236c9ce900...",
+		//             "target_path": "/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/publish/pvc-1/pod-1",
+		//             "device_path": "/dev/mapper/mpathg",
+		//             "major": 253,
+		//             "minor": 3
+		//         }
+		//     ]
+		// }
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "GetBlockPublishes",
+			Method:      "GET",
+			Pattern:     "/api/v1/devices/{serialNumber}/publishes",
+			HandlerFunc: handler.GetBlockPublishes,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		POST /api/v1/devices/{serialNumber}/publishes
+		// Description: 	Bind mounts the device node of the volume (or its opened LUKS mapping)
+		//					onto the target file, created if missing, and verifies the target then
+		//					has the major/minor number of the device node.
+		// Input Object:	chapi2.BlockPublishRequest object
+		// Output Object:	chapi2.BlockPublish object
+		// Sample Input:
+		// {
+		//     "target_path": "/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/publish/pvc-1/pod-1",
+		//     "read_only": false
+		// }
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "CreateBlockPublish",
+			Method:      "POST",
+			Pattern:     "/api/v1/devices/{serialNumber}/publishes",
+			HandlerFunc: handler.CreateBlockPublish,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		DELETE /api/v1/devices/{serialNumber}/publishes
+		// Description: 	Unmounts the device node of the volume from the target file and removes
+		//					the file.  Succeeds if the device node is not published at the target.
+		// Input Object:	chapi2.BlockPublishRequest object (only target_path is used)
+		// Output Object:	None (only Error details if request fails)
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "DeleteBlockPublish",
+			Method:      "DELETE",
+			Pattern:     "/api/v1/devices/{serialNumber}/publishes",
+			HandlerFunc: handler.DeleteBlockPublish,
+		},
+
 		///////////////////////////////////////////////////////////////////////////////////////////
 		// Endpoint:  		PUT /api/v1/devices/{serialNumber}/{fileSystem}
 		// Description: 	Formats the specified volume with the specified file system.
@@ -333,10 +612,107 @@ func NewRouter() *mux.Router {
 			Pattern:     "/api/v1/mounts/{mountId}",
 			HandlerFunc: handler.DeleteMount,
 		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		GET /api/v1/events
+		// Description: 	Stream device and mount changes as Server-Sent Events (text/event-stream).
+		//					Devices and mounts are reconciled periodically and on every udev block
+		//					device event; device_added, device_removed, device_resized,
+		//					path_state_changed, mount_added and mount_removed events are sent as
+		//					they are detected.  A client that reconnects with the Last-Event-ID
+		//					header receives the events it missed, or a resync event if they are no
+		//					longer retained.  A keepalive comment is sent when the stream is idle.
+		// Input Object:	Last-Event-ID header (optional)
+		// Output Object:	Stream of chapi2.Event objects
+		// Sample Output:
+		// id: 1591036200000000042
+		// event: path_state_changed
+		// data: {"id":1591036200000000042,"type":"path_state_changed","timestamp":"2020-06-01T18:30:00.5Z",
+		//        "serial_number":"5d8c1b9e2a4f7d316c9ce900d2a3c5e1","path":{"name":"sdc","state":"failed",
+		//        "previous_state":"active"}}
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "GetEvents",
+			Method:      "GET",
+			Pattern:     "/api/v1/events",
+			HandlerFunc: handler.GetEvents,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		GET /api/v1/openapi.json
+		// Description: 	This endpoint returns the OpenAPI 3 document of the CHAPI REST API.  It
+		//					is derived from this route table and the chapi2 model objects, see
+		//					openAPIEndpoints in chapi_openapi.go.
+		// Input Object:	None
+		// Output Object:	OpenAPI 3 document (not wrapped in a "data" property)
+		// Sample Output:
+		// {
+		//     "openapi": "3.0.3",
+		//     "info": {
+		//         "title": "CHAPI",
+		//         "version": "1.0.0"
+		//     },
+		//     "paths": {
+		//         "/api/v1/hosts": {
+		//             "get": {
+		//                 "operationId": "Hosts",
+		//                 "summary": "Get host information",
+		//                 ...
+		// }
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "OpenAPI",
+			Method:      "GET",
+			Pattern:     "/api/v1/openapi.json",
+			HandlerFunc: getOpenAPI,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		GET /api/v1/admin/loglevel
+		// Description: 	This endpoint returns the log levels of the CHAPI server.
+		// Input Object:	None
+		// Output Object:	chapi2.LogLevels object
+		// Sample Output:
+		// {
+		//     "data": {
+		//         "level": "info",
+		//         "packages": [
+		//             {
+		//                 "package": "iscsi",
+		//                 "level": "trace",
+		//                 "revert_at": "2020-06-01T18:30:00Z"
+		//             }
+		//         ]
+		//     }
+		// }
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "GetLogLevel",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/loglevel",
+			HandlerFunc: handler.GetLogLevel,
+		},
+
+		///////////////////////////////////////////////////////////////////////////////////////////
+		// Endpoint:  		PUT /api/v1/admin/loglevel
+		// Description: 	Change the log level of the CHAPI server, or of one of its packages
+		//					(e.g. linux, iscsi, multipath, tunelinux, connectivity), at runtime.
+		//					When revert_after is set the previous level is restored after that
+		//					duration so that trace logging is not left enabled.
+		// Input Object:	chapi2.LogLevelRequest object
+		//                          request.Package (optional)
+		//                          request.Level (required, except to remove a package level)
+		//                          request.RevertAfter (optional)
+		// Output Object:	chapi2.LogLevels object
+		// Sample Output:	See "GET /api/v1/admin/loglevel" endpoint
+		///////////////////////////////////////////////////////////////////////////////////////////
+		util.Route{
+			Name:        "UpdateLogLevel",
+			Method:      "PUT",
+			Pattern:     "/api/v1/admin/loglevel",
+			HandlerFunc: handler.UpdateLogLevel,
+		},
 	}
 
-	routes = append(routes, platformSpecificEndpoints...)
-	router := mux.NewRouter().StrictSlash(true)
-	util.InitializeRouter(router, routes)
-	return router
+	return append(routes, platformSpecificEndpoints...)
 }
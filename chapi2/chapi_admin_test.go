@@ -0,0 +1,74 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package chapi2_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hpe-storage/common-host-libs/chapi2"
+	"github.com/hpe-storage/common-host-libs/chapi2/chapiclient"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+// newTestClient serves the CHAPI router over TLS and returns a client connected to it
+func newTestClient(t *testing.T) *chapiclient.Client {
+	server := httptest.NewTLSServer(chapi2.NewRouter())
+	t.Cleanup(server.Close)
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	client, err := chapiclient.NewChapiTLSClientWithTimeout(server.URL, &tls.Config{RootCAs: roots}, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestLogLevelEndpoints(t *testing.T) {
+	level := log.GetLevel()
+	defer func() {
+		log.ResetLogLevels()
+		log.SetLogLevel(level.String(), 0)
+	}()
+	client := newTestClient(t)
+
+	levels, err := client.UpdateLogLevel(context.Background(), &model.LogLevelRequest{Level: "info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels.Level != "info" || len(levels.Packages) != 0 {
+		t.Errorf("unexpected log levels %+v", levels)
+	}
+
+	levels, err = client.UpdateLogLevel(context.Background(), &model.LogLevelRequest{Package: "iscsi", Level: "trace", RevertAfter: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels.Packages) != 1 || levels.Packages[0].Package != "iscsi" || levels.Packages[0].Level != "trace" || levels.Packages[0].RevertAt == "" {
+		t.Errorf("unexpected package log levels %+v", levels.Packages)
+	}
+
+	levels, err = client.GetLogLevel(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels.Level != "info" || len(levels.Packages) != 1 {
+		t.Errorf("unexpected log levels %+v", levels)
+	}
+
+	// Invalid requests are rejected
+	for _, request := range []*model.LogLevelRequest{
+		{Level: "loud"},
+		{Package: "linux", Level: "trace", RevertAfter: "soon"},
+		{Package: "bad package", Level: "trace"},
+	} {
+		if _, err = client.UpdateLogLevel(context.Background(), request); err == nil {
+			t.Errorf("expected an error for request %+v", request)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package chapi2_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+)
+
+func TestEventStream(t *testing.T) {
+	client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Events after an ID from another server instance are not retained, a resync is expected
+	lastEventID := uint64(1)
+	var received *model.Event
+	err := client.SubscribeEvents(ctx, &lastEventID, func(event *model.Event) {
+		received = event
+		cancel()
+	})
+	if err != context.Canceled {
+		t.Errorf("unexpected error %v", err)
+	}
+	if received == nil || received.Type != model.EventTypeResync || received.ID <= lastEventID {
+		t.Errorf("unexpected event %+v", received)
+	}
+}
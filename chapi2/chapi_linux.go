@@ -12,8 +12,12 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
 	"github.com/hpe-storage/common-host-libs/chapi2/handler"
+	"github.com/hpe-storage/common-host-libs/chapi2/openapi"
 	"github.com/hpe-storage/common-host-libs/connectivity"
+	"github.com/hpe-storage/common-host-libs/linux"
 	log "github.com/hpe-storage/common-host-libs/logger"
+	"github.com/hpe-storage/common-host-libs/model"
+	"github.com/hpe-storage/common-host-libs/tunelinux"
 	"github.com/hpe-storage/common-host-libs/util"
 )
 
@@ -51,7 +55,15 @@ var platformSpecificEndpoints = []util.Route{
 	},
 }
 
-// Run will invoke a new chapid listener with socket filename containing current process ID
+// OpenAPI description of the endpoints only supported by CHAPI for Linux
+var platformSpecificOpenAPIEndpoints = map[string]*openapi.Endpoint{
+	"Recommendations": {Summary: "Get the host setting recommendations", Response: []*tunelinux.Recommendation{}},
+	"DeletingDevices": {Summary: "Get the devices being deleted", Response: &linux.DeletingDevices{}},
+	"ChapInfo":        {Summary: "Get the iSCSI CHAP settings of the host", Response: &model.ChapInfo{}},
+}
+
+// Run will invoke a new chapid listener with socket filename containing current process ID, and a TLS listener on
+// ChapidTLSAddress when ChapidTLSOptions is set
 func Run() (err error) {
 	// check if chapid is already running listening on standard socket or per process socket
 	if IsChapidRunning(ChapidSocketPath+ChapidSocketName) ||
@@ -80,7 +92,16 @@ func Run() (err error) {
 	go startChapid(chapidResult)
 	// wait for the response on channel
 	err = <-chapidResult
-	return err
+	if err != nil {
+		return err
+	}
+	// serve over TLS as well when configured
+	if err = startChapidTLS(); err != nil {
+		// don't leave the local listener running on its own
+		stopChapid()
+		return err
+	}
+	return nil
 }
 
 // This function will invoke a new chapid listener with socket filename containing current process ID
@@ -114,6 +135,13 @@ func StopChapid() error {
 	chapidLock.Lock()
 	defer chapidLock.Unlock()
 
+	stopChapid()
+	return nil
+}
+
+// stopChapid stops the TLS and chapid listeners.  It is called with chapidLock held.
+func stopChapid() {
+	stopChapidTLS()
 	// stop the listener
 	if chapidListener != nil {
 		err := chapidListener.Close()
@@ -123,7 +151,6 @@ func StopChapid() error {
 		chapidListener = nil
 		os.RemoveAll(ChapidSocketPath + ChapidSocketName + strconv.Itoa(os.Getpid()))
 	}
-	return nil
 }
 
 // IsChapidRunning return true if chapid is running as part of service listening on given socket
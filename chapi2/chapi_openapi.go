@@ -0,0 +1,111 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package chapi2
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/handler"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/chapi2/openapi"
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+const (
+	openAPITitle   = "CHAPI"
+	openAPIVersion = "1.0.0"
+
+	errorMessageOpenAPIUnavailable = "OpenAPI document not available"
+)
+
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument []byte // OpenAPI document in JSON, built on first request
+)
+
+// openAPIEndpoints describes the input and output of the CHAPI routes, by route name, for the
+// OpenAPI document.  Every route returned by getRoutes must have an entry here or in
+// platformSpecificOpenAPIEndpoints.
+var openAPIEndpoints = map[string]*openapi.Endpoint{
+	// Host Endpoints
+	"Hosts":          {Summary: "Get host information", Response: &model.Host{}},
+	"HostNetworks":   {Summary: "Get the host network interfaces", Response: []*model.Network{}},
+	"HostInitiators": {Summary: "Get the host iSCSI and FC initiators", Response: []*model.Initiator{}},
+
+	// Device Endpoints
+	"Devices":                 {Summary: "Get the Nimble volumes", Response: []*model.Device{}, Query: []string{"serial"}},
+	"AllDeviceDetails":        {Summary: "Get the Nimble volumes with all their details", Response: []*model.Device{}, Query: []string{"serial"}},
+	"PartitionsForDevice":     {Summary: "Get the partitions of a device", Response: []*model.DevicePartition{}},
+	"CreateDevice":            {Summary: "Attach a Nimble volume", Request: &model.PublishInfo{}, Response: &model.Device{}},
+	"DeleteDevice":            {Summary: "Detach a Nimble volume", Response: &model.Device{}},
+	"OfflineDevice":           {Summary: "Offline a device", Response: &model.Device{}},
+	"ExpandDevice":            {Summary: "Expand a device to its volume size", Response: &model.Device{}},
+	"CheckFileSystem":         {Summary: "Check the file system of a device", Response: &model.FileSystemCheck{}},
+	"RepairFileSystem":        {Summary: "Repair the file system of a device", Response: &model.FileSystemCheck{}},
+	"GetEncryption":           {Summary: "Get the LUKS encryption state of a device", Response: &model.Encryption{}},
+	"UpdateEncryption":        {Summary: "Apply a LUKS encryption action to a device", Request: &model.EncryptionRequest{}, Response: &model.Encryption{}},
+	"GetEncryptionHeader":     {Summary: "Back up the LUKS header of a device", Response: &model.EncryptionHeader{}},
+	"RestoreEncryptionHeader": {Summary: "Restore the LUKS header of a device", Request: &model.EncryptionHeader{}, Response: &model.Encryption{}},
+	"GetReservation":          {Summary: "Get the persistent reservation state of a device", Response: &model.Reservation{}},
+	"UpdateReservation":       {Summary: "Apply a persistent reservation action to a device", Request: &model.ReservationRequest{}, Response: &model.Reservation{}},
+	"GetBlockPublishes":       {Summary: "Get the target files a device is published to", Response: []*model.BlockPublish{}},
+	"CreateBlockPublish":      {Summary: "Publish a device node to a target file", Request: &model.BlockPublishRequest{}, Response: &model.BlockPublish{}},
+	"DeleteBlockPublish":      {Summary: "Unpublish a device node from a target file", Request: &model.BlockPublishRequest{}, Response: &model.BlockPublish{}},
+	"CreateFileSystem":        {Summary: "Create a file system on a device"},
+
+	// Mount Endpoints
+	"GetMounts":          {Summary: "Get the mount points of the Nimble volumes", Response: []*model.Mount{}, Query: []string{"serial"}},
+	"GetAllMountDetails": {Summary: "Get the mount points of the Nimble volumes with all their details", Response: []*model.Mount{}, Query: []string{"serial", "mountId"}},
+	"CreateMount":        {Summary: "Mount a Nimble volume", Request: &model.Mount{}, Response: &model.Mount{}},
+	"DeleteMount":        {Summary: "Unmount a Nimble volume, the request body is the volume serial number", Request: "", Response: &model.Mount{}},
+
+	// Event Endpoints
+	"GetEvents": {Summary: "Stream device and mount change events", Response: &model.Event{}, Header: []string{"Last-Event-ID"}, ContentType: "text/event-stream"},
+
+	// Admin Endpoints
+	"OpenAPI":        {Summary: "Get the OpenAPI document of the CHAPI REST API", ContentType: openapi.ContentTypeJSON},
+	"GetLogLevel":    {Summary: "Get the log levels", Response: &model.LogLevels{}},
+	"UpdateLogLevel": {Summary: "Change a log level", Request: &model.LogLevelRequest{}, Response: &model.LogLevels{}},
+}
+
+// NewOpenAPIDocument returns the OpenAPI document of the CHAPI REST API
+func NewOpenAPIDocument() *openapi.Document {
+	document := openapi.NewDocument(openAPITitle, openAPIVersion, &cerrors.ChapiError{})
+	for _, route := range getRoutes() {
+		endpoint, ok := openAPIEndpoints[route.Name]
+		if !ok {
+			endpoint, ok = platformSpecificOpenAPIEndpoints[route.Name]
+		}
+		if !ok {
+			log.Warnf("route %v is not described in the OpenAPI document", route.Name)
+		}
+		document.AddOperation(route.Name, route.Method, route.Pattern, endpoint)
+	}
+	return document
+}
+
+//@APIVersion 1.0.0
+//@Title GetOpenAPI
+//@Description get the OpenAPI document of the CHAPI REST API
+//@Accept json
+//@Resource /api/v1/openapi.json
+//@Success 200 openapi.Document
+//@Router /api/v1/openapi.json [get]
+func getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		var err error
+		if openAPIDocument, err = NewOpenAPIDocument().JSON(); err != nil {
+			log.Errorf("unable to encode the OpenAPI document, err=%v", err)
+		}
+	})
+	if openAPIDocument == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(handler.Response{Err: cerrors.NewChapiError(cerrors.Internal, errorMessageOpenAPIUnavailable)})
+		return
+	}
+	w.Header().Set("Content-Type", openapi.ContentTypeJSON)
+	w.Write(openAPIDocument)
+}
@@ -0,0 +1,289 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package chapi2_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hpe-storage/common-host-libs/chapi2"
+	"github.com/hpe-storage/common-host-libs/chapi2/chapiclient"
+	"github.com/hpe-storage/common-host-libs/chapi2/openapi"
+)
+
+var (
+	// Client methods that don't submit a request (e.g. not yet implemented)
+	clientMethodsWithoutRequest = map[string]bool{"CreateBindMount": true}
+
+	// Client methods that aren't checked: Print calls itself, GetAccessKey returns the content of
+	// the key file rather than the endpoint data
+	clientMethodsNotChecked = map[string]bool{"Print": true, "GetAccessKey": true}
+)
+
+// getOpenAPIDocument returns the OpenAPI document served by the CHAPI router
+func getOpenAPIDocument(t *testing.T) *openapi.Document {
+	server := httptest.NewServer(chapi2.NewRouter())
+	defer server.Close()
+	response, err := http.Get(server.URL + "/api/v1/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != openapi.ContentTypeJSON {
+		t.Fatalf("unexpected response %v, content type %v", response.Status, response.Header.Get("Content-Type"))
+	}
+	document := &openapi.Document{}
+	if err = json.NewDecoder(response.Body).Decode(document); err != nil {
+		t.Fatal(err)
+	}
+	if document.OpenAPI != openapi.Version {
+		t.Errorf("unexpected OpenAPI version %v", document.OpenAPI)
+	}
+	return document
+}
+
+// TestOpenAPIRoutes checks that every route of the router is described by the OpenAPI document
+func TestOpenAPIRoutes(t *testing.T) {
+	document := getOpenAPIDocument(t)
+
+	routes := 0
+	err := chapi2.NewRouter().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			routes++
+			operation := document.Operation(method, path)
+			if operation == nil {
+				t.Errorf("route %v %v %v not in the OpenAPI document", route.GetName(), method, path)
+				continue
+			}
+			if operation.OperationID != route.GetName() || operation.Summary == "" {
+				t.Errorf("route %v %v %v not described, operation %+v", route.GetName(), method, path, operation)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operations := 0
+	for _, pathItem := range document.Paths {
+		operations += len(pathItem)
+	}
+	if operations != routes {
+		t.Errorf("%v operations in the OpenAPI document for %v routes", operations, routes)
+	}
+}
+
+// recordedRequest is a request received by the recording server
+type recordedRequest struct {
+	method string
+	path   string
+	query  url.Values
+	body   string
+}
+
+// TestOpenAPIClient checks that every request submitted by the CHAPI client, and the data it
+// expects in return, matches the OpenAPI document
+func TestOpenAPIClient(t *testing.T) {
+	document := getOpenAPIDocument(t)
+
+	// Record the client requests instead of serving them
+	var lock sync.Mutex
+	var requests []*recordedRequest
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		requests = append(requests, &recordedRequest{method: r.Method, path: r.URL.Path, query: r.URL.Query(), body: strings.TrimSpace(string(body))})
+		lock.Unlock()
+		if r.Header.Get("Accept") != "text/event-stream" {
+			fmt.Fprint(w, "{}")
+		}
+	}))
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	client, err := chapiclient.NewChapiTLSClientWithTimeout(server.URL, &tls.Config{RootCAs: roots}, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientValue := reflect.ValueOf(client)
+	for index := 0; index < clientValue.NumMethod(); index++ {
+		method := clientValue.Type().Method(index)
+		if clientMethodsNotChecked[method.Name] {
+			continue
+		}
+		lock.Lock()
+		requests = nil
+		lock.Unlock()
+
+		cancel := callClientMethod(clientValue.Method(index))
+		cancel()
+
+		lock.Lock()
+		received := requests
+		lock.Unlock()
+		if len(received) == 0 {
+			if !clientMethodsWithoutRequest[method.Name] {
+				t.Errorf("client method %v did not submit a request", method.Name)
+			}
+			continue
+		}
+		for _, request := range received {
+			checkClientRequest(t, document, method, request)
+		}
+	}
+}
+
+// callClientMethod calls the client method with placeholder arguments, and returns the cancel
+// function of the context passed to it (if any)
+func callClientMethod(method reflect.Value) context.CancelFunc {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	contextType := reflect.TypeOf((*context.Context)(nil)).Elem()
+	var args []reflect.Value
+	for index := 0; index < method.Type().NumIn(); index++ {
+		argType := method.Type().In(index)
+		switch {
+		case argType == contextType:
+			args = append(args, reflect.ValueOf(ctx))
+		case argType.Kind() == reflect.String:
+			args = append(args, reflect.ValueOf(fmt.Sprintf("arg%d", index)).Convert(argType))
+		case argType.Kind() == reflect.Ptr:
+			arg := reflect.New(argType.Elem())
+			fillStringFields(arg.Elem())
+			args = append(args, arg)
+		case argType.Kind() == reflect.Func:
+			args = append(args, reflect.MakeFunc(argType, func([]reflect.Value) []reflect.Value { return nil }))
+		default:
+			arg := reflect.New(argType).Elem()
+			fillStringFields(arg)
+			args = append(args, arg)
+		}
+	}
+	method.Call(args)
+	return cancel
+}
+
+// fillStringFields sets the string fields of a struct to placeholders so that they are submitted
+// in the request body
+func fillStringFields(value reflect.Value) {
+	if value.Kind() != reflect.Struct {
+		return
+	}
+	for index := 0; index < value.NumField(); index++ {
+		if field := value.Field(index); field.Kind() == reflect.String && field.CanSet() {
+			field.SetString("field")
+		}
+	}
+}
+
+// checkClientRequest checks a request submitted by a client method against the OpenAPI document
+func checkClientRequest(t *testing.T, document *openapi.Document, method reflect.Method, request *recordedRequest) {
+	operation := findOperation(document, request.method, request.path)
+	if operation == nil {
+		t.Errorf("client method %v request %v %v not in the OpenAPI document", method.Name, request.method, request.path)
+		return
+	}
+
+	// Query parameters
+	for name := range request.query {
+		if !hasParameter(operation, "query", name) {
+			t.Errorf("client method %v query parameter %v not in operation %v", method.Name, name, operation.OperationID)
+		}
+	}
+
+	// Request body
+	switch {
+	case request.body == "" || request.body == "null":
+		if operation.RequestBody != nil {
+			t.Errorf("client method %v submitted no request body to operation %v", method.Name, operation.OperationID)
+		}
+	case operation.RequestBody == nil:
+		t.Errorf("client method %v submitted a request body to operation %v", method.Name, operation.OperationID)
+	default:
+		schema := document.Resolve(operation.RequestBody.Content[openapi.ContentTypeJSON].Schema)
+		var body interface{}
+		if err := json.Unmarshal([]byte(request.body), &body); err != nil {
+			t.Errorf("client method %v submitted an invalid request body %v", method.Name, request.body)
+		}
+		switch body := body.(type) {
+		case map[string]interface{}:
+			for property := range body {
+				if schema.Properties[property] == nil {
+					t.Errorf("client method %v request property %v not in operation %v", method.Name, property, operation.OperationID)
+				}
+			}
+		case string:
+			if schema.Type != "string" {
+				t.Errorf("client method %v submitted a string to operation %v", method.Name, operation.OperationID)
+			}
+		}
+	}
+
+	// Response data, the first return value of the method if it returns more than an error
+	if method.Type.NumOut() == 2 {
+		expected := openapi.NewDocument("", "", nil).Schema(reflect.Zero(method.Type.Out(0)).Interface())
+		if schema := document.DataSchema(operation); !reflect.DeepEqual(schema, expected) {
+			t.Errorf("client method %v returns %v but operation %v returns %+v", method.Name, method.Type.Out(0), operation.OperationID, schema)
+		}
+	}
+}
+
+// findOperation returns the operation whose path template matches the request path, preferring
+// the template with the fewest path parameters (e.g. "/devices/details" over "/devices/{serialNumber}")
+func findOperation(document *openapi.Document, method string, path string) *openapi.Operation {
+	var found *openapi.Operation
+	fewest := -1
+	segments := strings.Split(path, "/")
+	for template := range document.Paths {
+		templateSegments := strings.Split(template, "/")
+		if len(templateSegments) != len(segments) {
+			continue
+		}
+		parameters := 0
+		for index, segment := range templateSegments {
+			if strings.HasPrefix(segment, "{") {
+				parameters++
+			} else if segment != segments[index] {
+				parameters = -1
+				break
+			}
+		}
+		operation := document.Operation(method, template)
+		if parameters >= 0 && operation != nil && (fewest < 0 || parameters < fewest) {
+			found, fewest = operation, parameters
+		}
+	}
+	return found
+}
+
+// hasParameter returns true if the operation has the parameter
+func hasParameter(operation *openapi.Operation, in string, name string) bool {
+	for _, parameter := range operation.Parameters {
+		if parameter.In == in && parameter.Name == name {
+			return true
+		}
+	}
+	return false
+}
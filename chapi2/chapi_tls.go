@@ -0,0 +1,158 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package chapi2
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+var (
+	// ChapidTLSAddress is the TCP address Run serves the CHAPI endpoints on over TLS when ChapidTLSOptions is set
+	ChapidTLSAddress = ":8443"
+	// ChapidTLSOptions enables serving over TLS from Run in addition to the local chapid listener, nil disables it
+	ChapidTLSOptions *TLSOptions
+	// chapidTLSListener is the TLS listener started by Run, guarded by chapidLock
+	chapidTLSListener net.Listener
+)
+
+// TLSOptions configures chapid to serve over TLS
+type TLSOptions struct {
+	CertFile     string // PEM encoded server certificate (optionally followed by its chain)
+	KeyFile      string // PEM encoded server private key
+	ClientCAFile string // PEM encoded CA bundle, clients must present a certificate issued by it
+}
+
+// keyPairReloader serves the key pair from disk, reloading it when the certificate file changes so a rotated
+// certificate is picked up without restarting chapid
+type keyPairReloader struct {
+	lock     sync.Mutex
+	certFile string
+	keyFile  string
+	modTime  time.Time
+	keyPair  *tls.Certificate
+}
+
+func (reloader *keyPairReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.lock.Lock()
+	defer reloader.lock.Unlock()
+
+	info, err := os.Stat(reloader.certFile)
+	if err != nil {
+		if reloader.keyPair != nil {
+			log.Errorf("unable to stat %s, serving the loaded certificate, %s", reloader.certFile, err.Error())
+			return reloader.keyPair, nil
+		}
+		return nil, err
+	}
+	if reloader.keyPair == nil || !info.ModTime().Equal(reloader.modTime) {
+		keyPair, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+		if err != nil {
+			if reloader.keyPair != nil {
+				// the certificate and key may be mid-rotation, keep serving the previous pair
+				log.Errorf("unable to reload %s, serving the loaded certificate, %s", reloader.certFile, err.Error())
+				return reloader.keyPair, nil
+			}
+			return nil, err
+		}
+		log.Infof("loaded chapid certificate %s", reloader.certFile)
+		reloader.keyPair = &keyPair
+		reloader.modTime = info.ModTime()
+	}
+	return reloader.keyPair, nil
+}
+
+// NewServerTLSConfig returns the tls.Config chapid serves with.  The TLS listener exposes the full CHAPI over TCP, so
+// client certificates issued by the client CA are always required and verified.
+func NewServerTLSConfig(opts *TLSOptions) (*tls.Config, error) {
+	if opts == nil || opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("a certificate and key file are required to serve over TLS")
+	}
+	if opts.ClientCAFile == "" {
+		return nil, errors.New("a client CA file is required to serve over TLS")
+	}
+	reloader := &keyPairReloader{certFile: opts.CertFile, keyFile: opts.KeyFile}
+	// load once up front so a bad configuration fails at startup rather than at the first handshake
+	if _, err := reloader.getCertificate(nil); err != nil {
+		return nil, err
+	}
+	pemData, err := ioutil.ReadFile(opts.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemData) {
+		return nil, fmt.Errorf("no certificates found in %s", opts.ClientCAFile)
+	}
+	return &tls.Config{
+		GetCertificate: reloader.getCertificate,
+		ClientCAs:      pool,
+		ClientAuth:     tls.RequireAndVerifyClientCert,
+		MinVersion:     tls.VersionTLS12,
+	}, nil
+}
+
+// RunTLS serves the CHAPI endpoints over TLS on the given TCP address.  The listener is returned so the caller can
+// stop the server, the result of serving is sent on c once the listener is closed.
+func RunTLS(address string, opts *TLSOptions, c chan error) (net.Listener, error) {
+	log.Tracef(">>>>> RunTLS called with %s", address)
+	defer log.Trace("<<<<< RunTLS")
+
+	config, err := NewServerTLSConfig(opts)
+	if err != nil {
+		log.Error("unable to setup TLS for chapid ", err.Error())
+		return nil, err
+	}
+	listener, err := tls.Listen("tcp", address, config)
+	if err != nil {
+		log.Error("listen error, Unable to create ChapidServer ", err.Error())
+		return nil, err
+	}
+	log.Infof("Serving TLS on %s", listener.Addr().String())
+	router := NewRouter()
+	go func() {
+		c <- http.Serve(listener, router)
+	}()
+	return listener, nil
+}
+
+// startChapidTLS serves over TLS on ChapidTLSAddress when ChapidTLSOptions is set.  It is called by Run with
+// chapidLock held.
+func startChapidTLS() error {
+	if ChapidTLSOptions == nil || chapidTLSListener != nil {
+		return nil
+	}
+	result := make(chan error, 1)
+	listener, err := RunTLS(ChapidTLSAddress, ChapidTLSOptions, result)
+	if err != nil {
+		return err
+	}
+	chapidTLSListener = listener
+	go func() {
+		if err := <-result; err != nil {
+			log.Info("exiting chapid TLS server ", err.Error())
+		}
+	}()
+	return nil
+}
+
+// stopChapidTLS closes the TLS listener started by Run.  It is called by StopChapid with chapidLock held.
+func stopChapidTLS() {
+	if chapidTLSListener == nil {
+		return
+	}
+	if err := chapidTLSListener.Close(); err != nil {
+		log.Error("Unable to close chapid TLS listener " + chapidTLSListener.Addr().String())
+	}
+	chapidTLSListener = nil
+}
@@ -0,0 +1,90 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package chapi2
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/cert"
+)
+
+func TestRunServesTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chapitls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, _ := cert.NewCA("chapid-ca", 0)
+	caPEM, _ := ca.CertPEM()
+	caFile := filepath.Join(dir, "ca.crt")
+	if err = ioutil.WriteFile(caFile, []byte(caPEM), 0600); err != nil {
+		t.Fatal(err)
+	}
+	server, _ := ca.IssueServerCert("chapid", []string{"127.0.0.1"}, 0)
+	certFile, keyFile := writeLeaf(t, dir, "server", server)
+
+	socketPath, address, opts := ChapidSocketPath, ChapidTLSAddress, ChapidTLSOptions
+	ChapidSocketPath = dir + "/"
+	ChapidTLSAddress = "127.0.0.1:0"
+	ChapidTLSOptions = &TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}
+	t.Cleanup(func() { ChapidSocketPath, ChapidTLSAddress, ChapidTLSOptions = socketPath, address, opts })
+
+	if err = Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer StopChapid()
+	if chapidTLSListener == nil {
+		t.Fatal("expected Run to serve over TLS")
+	}
+
+	leaf, _ := ca.IssueClientCert("host1", 0)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      ca.CertPool(),
+		Certificates: []tls.Certificate{leaf.TLSCertificate(ca)},
+	}}}
+	resp, err := client.Get("https://" + chapidTLSListener.Addr().String() + "/api/v1/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d from an unknown route, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestRunStopsChapidWhenTLSFails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chapitls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, _ := cert.NewCA("chapid-ca", 0)
+	server, _ := ca.IssueServerCert("chapid", []string{"127.0.0.1"}, 0)
+	certFile, keyFile := writeLeaf(t, dir, "server", server)
+
+	socketPath, address, opts := ChapidSocketPath, ChapidTLSAddress, ChapidTLSOptions
+	ChapidSocketPath = dir + "/"
+	ChapidTLSAddress = "127.0.0.1:0"
+	// no client CA, the TLS listener must not start
+	ChapidTLSOptions = &TLSOptions{CertFile: certFile, KeyFile: keyFile}
+	t.Cleanup(func() { ChapidSocketPath, ChapidTLSAddress, ChapidTLSOptions = socketPath, address, opts })
+
+	if err = Run(); err == nil {
+		StopChapid()
+		t.Fatal("expected Run to fail without a client CA")
+	}
+	if chapidListener != nil || chapidTLSListener != nil {
+		t.Error("expected Run to stop the chapid listener when the TLS listener fails")
+	}
+	if _, err = os.Stat(ChapidSocketPath + ChapidSocketName + strconv.Itoa(os.Getpid())); !os.IsNotExist(err) {
+		t.Errorf("expected the chapid socket to be removed, %v", err)
+	}
+}
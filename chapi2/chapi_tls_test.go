@@ -0,0 +1,94 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package chapi2
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/cert"
+)
+
+// writeLeaf writes the PEM certificate and key of a leaf into dir
+func writeLeaf(t *testing.T, dir, name string, leaf *cert.Leaf) (string, string) {
+	certPEM, _ := leaf.CertPEM()
+	keyPEM, _ := leaf.KeyPEM()
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certFile, []byte(certPEM), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, []byte(keyPEM), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestRunTLSWithClientCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chapitls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, _ := cert.NewCA("chapid-ca", 0)
+	caPEM, _ := ca.CertPEM()
+	caFile := filepath.Join(dir, "ca.crt")
+	if err = ioutil.WriteFile(caFile, []byte(caPEM), 0600); err != nil {
+		t.Fatal(err)
+	}
+	server, _ := ca.IssueServerCert("chapid", []string{"127.0.0.1"}, 0)
+	certFile, keyFile := writeLeaf(t, dir, "server", server)
+
+	result := make(chan error, 1)
+	listener, err := RunTLS("127.0.0.1:0", &TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	url := "https://" + listener.Addr().String() + "/api/v1/unknown"
+
+	// clients without a certificate from the CA are rejected during the handshake
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: ca.CertPool()}}}
+	if _, err = anonymous.Get(url); err == nil {
+		t.Error("expected a client without a certificate to be rejected")
+	}
+
+	client, _ := ca.IssueClientCert("host1", 0)
+	authenticated := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      ca.CertPool(),
+		Certificates: []tls.Certificate{client.TLSCertificate(ca)},
+	}}}
+	resp, err := authenticated.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d from an unknown route, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestNewServerTLSConfig(t *testing.T) {
+	if _, err := NewServerTLSConfig(&TLSOptions{}); err == nil {
+		t.Error("expected an error without a certificate")
+	}
+	if _, err := NewServerTLSConfig(&TLSOptions{CertFile: "/nonexistent.crt", KeyFile: "/nonexistent.key", ClientCAFile: "/nonexistent.crt"}); err == nil {
+		t.Error("expected an error for missing files")
+	}
+
+	dir, err := ioutil.TempDir("", "chapitls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca, _ := cert.NewCA("chapid-ca", 0)
+	server, _ := ca.IssueServerCert("chapid", []string{"127.0.0.1"}, 0)
+	certFile, keyFile := writeLeaf(t, dir, "server", server)
+	if _, err = NewServerTLSConfig(&TLSOptions{CertFile: certFile, KeyFile: keyFile}); err == nil {
+		t.Error("expected an error without a client CA")
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/hpe-storage/common-host-libs/chapi2/handler"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/chapi2/openapi"
 	log "github.com/hpe-storage/common-host-libs/logger"
 	"github.com/hpe-storage/common-host-libs/util"
 )
@@ -30,7 +32,12 @@ var platformSpecificEndpoints = []util.Route{
 	},
 }
 
-// Run will invoke a new chapid listener
+// OpenAPI description of the endpoints only supported by CHAPI for Windows
+var platformSpecificOpenAPIEndpoints = map[string]*openapi.Endpoint{
+	"Keyfile": {Summary: "Get the path of the local access key file", Response: &model.KeyFileInfo{}},
+}
+
+// Run will invoke a new chapid listener, and a TLS listener on ChapidTLSAddress when ChapidTLSOptions is set
 func Run() (err error) {
 	// acquire lock to avoid multiple chapid servers
 	chapidLock.Lock()
@@ -48,8 +55,17 @@ func Run() (err error) {
 	go startChapid(chapidResult)
 	// wait for the response on channel
 	err = <-chapidResult
+	if err != nil {
+		return err
+	}
 
-	return err
+	// serve over TLS as well when configured
+	if err = startChapidTLS(); err != nil {
+		// don't leave the local listener running on its own
+		stopChapid()
+		return err
+	}
+	return nil
 }
 
 // This function will invoke a new chapid listener with socket filename containing current process ID
@@ -101,6 +117,13 @@ func StopChapid() error {
 	chapidLock.Lock()
 	defer chapidLock.Unlock()
 
+	stopChapid()
+	return nil
+}
+
+// stopChapid stops the TLS and chapid listeners.  It is called with chapidLock held.
+func stopChapid() {
+	stopChapidTLS()
 	// stop the listener
 	if chapidListener != nil {
 		err := chapidListener.Close()
@@ -114,5 +137,4 @@ func StopChapid() error {
 		}
 		chapidListener = nil
 	}
-	return nil
 }
@@ -3,7 +3,10 @@
 package chapiclient
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -24,16 +27,29 @@ const (
 	networksURI   = apiVersion + "/networks"   // api/v1/networks
 
 	// Device Endpoints
-	devicesURI           = apiVersion + "/devices"            // api/v1/devices
-	devicesDetailURI     = devicesURI + "/details"            // api/v1/devices/details
-	devicesPartitionsURI = devicesURI + "/%v/partitions"      // api/v1/devices/{serialnumber}/partitions
-	devicesOfflineURI    = devicesURI + "/%v/actions/offline" // api/v1/devices/{serialnumber}/actions/offline
-	devicesFileSystemURI = devicesURI + "/%v/%v"              // api/v1/devices/{serialnumber}/filesystem/{filesystem}
+	devicesURI                 = apiVersion + "/devices"              // api/v1/devices
+	devicesDetailURI           = devicesURI + "/details"              // api/v1/devices/details
+	devicesPartitionsURI       = devicesURI + "/%v/partitions"        // api/v1/devices/{serialnumber}/partitions
+	devicesOfflineURI          = devicesURI + "/%v/actions/offline"   // api/v1/devices/{serialnumber}/actions/offline
+	devicesExpandURI           = devicesURI + "/%v/actions/expand"    // api/v1/devices/{serialnumber}/actions/expand
+	devicesFsCheckURI          = devicesURI + "/%v/actions/fscheck"   // api/v1/devices/{serialnumber}/actions/fscheck
+	devicesFsRepairURI         = devicesURI + "/%v/actions/fsrepair"  // api/v1/devices/{serialnumber}/actions/fsrepair
+	devicesFileSystemURI       = devicesURI + "/%v/%v"                // api/v1/devices/{serialnumber}/filesystem/{filesystem}
+	devicesReservationsURI     = devicesURI + "/%v/reservations"      // api/v1/devices/{serialnumber}/reservations
+	devicesEncryptionURI       = devicesURI + "/%v/encryption"        // api/v1/devices/{serialnumber}/encryption
+	devicesEncryptionHeaderURI = devicesURI + "/%v/encryption/header" // api/v1/devices/{serialnumber}/encryption/header
+	devicesPublishesURI        = devicesURI + "/%v/publishes"         // api/v1/devices/{serialnumber}/publishes
 
 	// Mount Endpoints
 	mountsURI       = apiVersion + "/mounts" // api/v1/mounts
 	mountsDetailURI = mountsURI + "/details" // api/v1/mounts/details
 	mountsDeleteURI = mountsURI + "/%v"      // api/v1/mounts/{mountId}
+
+	// Event Endpoints
+	eventsURI = apiVersion + "/events" // api/v1/events
+
+	// Admin Endpoints
+	adminLogLevelURI = apiVersion + "/admin/loglevel" // api/v1/admin/loglevel
 )
 
 const (
@@ -77,6 +93,18 @@ func NewChapiClientWithTimeout(timeout time.Duration) (*Client, error) {
 	return newChapiClientWithTimeout(timeout) // Reflect to platform specific handler
 }
 
+// NewChapiTLSClientWithTimeout returns the CHAPI client object that is used to communicate with a
+// CHAPI server serving over TLS at the given URL (e.g. https://host:port).  The tls.Config carries
+// the client certificate and the CAs used to verify the server.
+func NewChapiTLSClientWithTimeout(url string, tlsConfig *tls.Config, timeout time.Duration) (*Client, error) {
+	if tlsConfig == nil {
+		return nil, fmt.Errorf("tls configuration is required")
+	}
+	log.Traceln("Setting up CHAPI TLS client for ", url)
+	tlsClient := connectivity.NewHTTPSClientWithTimeout(url, &http.Transport{TLSClientConfig: tlsConfig}, timeout)
+	return &Client{ClientBase: ClientBase{client: tlsClient}}, nil
+}
+
 // Print to dump CHAPI client struct
 func (chapiClient *Client) Print() {
 	chapiClient.Print() // Reflect to platform specific handler
@@ -92,39 +120,39 @@ func (chapiClient *Client) addHeader(header map[string]string) {
 ///////////////////////////////////////////////////////////////////////////////////////////////////
 
 // GetHostInfo returns host name, domain, and network interfaces
-func (chapiClient *Client) GetHostInfo() (host *model.Host, err error) {
-	log.Trace(">>>>> GetHostInfo called")
-	defer log.Trace("<<<<< GetHostInfo")
+func (chapiClient *Client) GetHostInfo(ctx context.Context) (host *model.Host, err error) {
+	log.FromContext(ctx).Trace(">>>>> GetHostInfo called")
+	defer log.FromContext(ctx).Trace("<<<<< GetHostInfo")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: &host, Err: nil}
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "GET", Path: hostURI, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: hostURI, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return nil, err
 	}
 	return host, nil
 }
 
 // GetHostInitiators reports the initiators on this host
-func (chapiClient *Client) GetHostInitiators() (initiators []*model.Initiator, err error) {
-	log.Trace(">>>>> GetHostInitiators called")
-	defer log.Trace("<<<<< GetHostInitiators")
+func (chapiClient *Client) GetHostInitiators(ctx context.Context) (initiators []*model.Initiator, err error) {
+	log.FromContext(ctx).Trace(">>>>> GetHostInitiators called")
+	defer log.FromContext(ctx).Trace("<<<<< GetHostInitiators")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: &initiators, Err: nil}
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "GET", Path: initiatorsURI, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: initiatorsURI, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return nil, err
 	}
 	return initiators, nil
 }
 
 // GetHostNetworks reports the networks on this host
-func (chapiClient *Client) GetHostNetworks() (networks []*model.Network, err error) {
-	log.Trace(">>>>> GetHostNetworks called")
-	defer log.Trace("<<<<< GetHostNetworks")
+func (chapiClient *Client) GetHostNetworks(ctx context.Context) (networks []*model.Network, err error) {
+	log.FromContext(ctx).Trace(">>>>> GetHostNetworks called")
+	defer log.FromContext(ctx).Trace("<<<<< GetHostNetworks")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: &networks, Err: nil}
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "GET", Path: networksURI, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: networksURI, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return nil, err
 	}
 	return networks, nil
@@ -136,14 +164,14 @@ func (chapiClient *Client) GetHostNetworks() (networks []*model.Network, err err
 
 // GetDevices enumerates all the Nimble volumes with basic details.
 // If serialNumber is non-empty then only specified device is returned
-func (chapiClient *Client) GetDevices(serialNumber string) (devices []*model.Device, err error) {
-	log.Tracef(">>>>> GetDevices called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< GetDevices")
+func (chapiClient *Client) GetDevices(ctx context.Context, serialNumber string) (devices []*model.Device, err error) {
+	log.FromContext(ctx).Tracef(">>>>> GetDevices called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetDevices")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: &devices, Err: nil}
 	devicesURIOut := chapiClient.appendQuerySerialNumber(devicesURI, serialNumber)
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "GET", Path: devicesURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: devicesURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return nil, err
 	}
 	return devices, nil
@@ -151,125 +179,289 @@ func (chapiClient *Client) GetDevices(serialNumber string) (devices []*model.Dev
 
 // GetAllDeviceDetails enumerates all the Nimble volumes with detailed information.
 // If serialNumber is non-empty then only specified device is returned
-func (chapiClient *Client) GetAllDeviceDetails(serialNumber string) (devices []*model.Device, err error) {
-	log.Tracef(">>>>> GetAllDeviceDetails called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< GetAllDeviceDetails")
+func (chapiClient *Client) GetAllDeviceDetails(ctx context.Context, serialNumber string) (devices []*model.Device, err error) {
+	log.FromContext(ctx).Tracef(">>>>> GetAllDeviceDetails called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetAllDeviceDetails")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: &devices, Err: nil}
 	devicesURIOut := chapiClient.appendQuerySerialNumber(devicesDetailURI, serialNumber)
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "GET", Path: devicesURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: devicesURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return nil, err
 	}
 	return devices, nil
 }
 
 // GetPartitionInfo reports the partitions on the provided device
-func (chapiClient *Client) GetPartitionInfo(serialNumber string) (partitions []*model.DevicePartition, err error) {
-	log.Tracef(">>>>> GetPartitionInfo called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< GetPartitionInfo")
+func (chapiClient *Client) GetPartitionInfo(ctx context.Context, serialNumber string) (partitions []*model.DevicePartition, err error) {
+	log.FromContext(ctx).Tracef(">>>>> GetPartitionInfo called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetPartitionInfo")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: &partitions, Err: nil}
 	devicePartitionsURIOut := fmt.Sprintf(devicesPartitionsURI, serialNumber)
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "GET", Path: devicePartitionsURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: devicePartitionsURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return nil, err
 	}
 	return partitions, nil
 }
 
 // CreateDevice will attach device on this host based on the details provided
-func (chapiClient *Client) CreateDevice(publishInfo model.PublishInfo) (device *model.Device, err error) {
-	log.Tracef(">>>>> CreateDevice called, publishInfo=%v", publishInfo)
-	defer log.Trace("<<<<< CreateDevice")
+func (chapiClient *Client) CreateDevice(ctx context.Context, publishInfo model.PublishInfo) (device *model.Device, err error) {
+	log.FromContext(ctx).Tracef(">>>>> CreateDevice called, publishInfo=%v", log.Redacted(publishInfo))
+	defer log.FromContext(ctx).Trace("<<<<< CreateDevice")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: &device, Err: nil}
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "POST", Path: devicesURI, Header: chapiClient.header, Payload: &publishInfo, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "POST", Path: devicesURI, Header: chapiClient.header, Payload: &publishInfo, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return nil, err
 	}
 	return device, nil
 }
 
 // DeleteDevice will delete the given device from the host
-func (chapiClient *Client) DeleteDevice(serialNumber string) (err error) {
-	log.Tracef(">>>>> DeleteDevice called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< DeleteDevice")
+func (chapiClient *Client) DeleteDevice(ctx context.Context, serialNumber string) (err error) {
+	log.FromContext(ctx).Tracef(">>>>> DeleteDevice called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< DeleteDevice")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: nil, Err: nil}
 	devicesURIOut := devicesURI + "/" + serialNumber
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "DELETE", Path: devicesURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "DELETE", Path: devicesURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return err
 	}
 	return nil
 }
 
 // OfflineDevice will offline the given device from the host
-func (chapiClient *Client) OfflineDevice(serialNumber string) (err error) {
-	log.Tracef(">>>>> OfflineDevice called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< OfflineDevice")
+func (chapiClient *Client) OfflineDevice(ctx context.Context, serialNumber string) (err error) {
+	log.FromContext(ctx).Tracef(">>>>> OfflineDevice called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< OfflineDevice")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: nil, Err: nil}
 	deviceOfflineURIOut := fmt.Sprintf(devicesOfflineURI, serialNumber)
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "PUT", Path: deviceOfflineURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "PUT", Path: deviceOfflineURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return err
 	}
 	return nil
 }
 
+// ExpandDevice picks up the new capacity of the given device and grows its mounted file systems
+func (chapiClient *Client) ExpandDevice(ctx context.Context, serialNumber string) (device *model.Device, err error) {
+	log.FromContext(ctx).Tracef(">>>>> ExpandDevice called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< ExpandDevice")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &device, Err: nil}
+	deviceExpandURIOut := fmt.Sprintf(devicesExpandURI, serialNumber)
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "PUT", Path: deviceExpandURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// CheckFileSystem runs a read-only check of the unmounted file system on the given device
+func (chapiClient *Client) CheckFileSystem(ctx context.Context, serialNumber string) (check *model.FileSystemCheck, err error) {
+	log.FromContext(ctx).Tracef(">>>>> CheckFileSystem called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< CheckFileSystem")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &check, Err: nil}
+	deviceFsCheckURIOut := fmt.Sprintf(devicesFsCheckURI, serialNumber)
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "PUT", Path: deviceFsCheckURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// RepairFileSystem repairs the unmounted file system on the given device
+func (chapiClient *Client) RepairFileSystem(ctx context.Context, serialNumber string) (check *model.FileSystemCheck, err error) {
+	log.FromContext(ctx).Tracef(">>>>> RepairFileSystem called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< RepairFileSystem")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &check, Err: nil}
+	deviceFsRepairURIOut := fmt.Sprintf(devicesFsRepairURI, serialNumber)
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "PUT", Path: deviceFsRepairURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
 // CreateFileSystem writes the given file system to the device with the given serial number
-func (chapiClient *Client) CreateFileSystem(serialNumber string, filesystem string) (err error) {
-	log.Tracef(">>>>> CreateFileSystem called, serialNumber=%v, filesystem=%v", serialNumber, filesystem)
-	defer log.Trace("<<<<< CreateFileSystem")
+func (chapiClient *Client) CreateFileSystem(ctx context.Context, serialNumber string, filesystem string) (err error) {
+	log.FromContext(ctx).Tracef(">>>>> CreateFileSystem called, serialNumber=%v, filesystem=%v", serialNumber, filesystem)
+	defer log.FromContext(ctx).Trace("<<<<< CreateFileSystem")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: nil, Err: nil}
 	deviceFileSystemURIOut := fmt.Sprintf(devicesFileSystemURI, serialNumber, filesystem)
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "PUT", Path: deviceFileSystemURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "PUT", Path: deviceFileSystemURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return err
 	}
 	return nil
 }
 
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Encryption Methods
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// GetEncryption reports the LUKS encryption state of the device with the given serial number
+func (chapiClient *Client) GetEncryption(ctx context.Context, serialNumber string) (encryption *model.Encryption, err error) {
+	log.FromContext(ctx).Tracef(">>>>> GetEncryption called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetEncryption")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &encryption, Err: nil}
+	encryptionURIOut := fmt.Sprintf(devicesEncryptionURI, serialNumber)
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: encryptionURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return encryption, nil
+}
+
+// UpdateEncryption applies the encryption action to the device with the given serial number
+func (chapiClient *Client) UpdateEncryption(ctx context.Context, serialNumber string, request *model.EncryptionRequest) (encryption *model.Encryption, err error) {
+	log.FromContext(ctx).Tracef(">>>>> UpdateEncryption called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< UpdateEncryption")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &encryption, Err: nil}
+	encryptionURIOut := fmt.Sprintf(devicesEncryptionURI, serialNumber)
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "PUT", Path: encryptionURIOut, Header: chapiClient.header, Payload: request, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return encryption, nil
+}
+
+// GetEncryptionHeader returns a backup of the LUKS header of the device with the given serial number
+func (chapiClient *Client) GetEncryptionHeader(ctx context.Context, serialNumber string) (header *model.EncryptionHeader, err error) {
+	log.FromContext(ctx).Tracef(">>>>> GetEncryptionHeader called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetEncryptionHeader")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &header, Err: nil}
+	headerURIOut := fmt.Sprintf(devicesEncryptionHeaderURI, serialNumber)
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: headerURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// RestoreEncryptionHeader restores the LUKS header of the device with the given serial number from a backup
+func (chapiClient *Client) RestoreEncryptionHeader(ctx context.Context, serialNumber string, header *model.EncryptionHeader) (encryption *model.Encryption, err error) {
+	log.FromContext(ctx).Tracef(">>>>> RestoreEncryptionHeader called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< RestoreEncryptionHeader")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &encryption, Err: nil}
+	encryptionURIOut := fmt.Sprintf(devicesEncryptionHeaderURI, serialNumber)
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "PUT", Path: encryptionURIOut, Header: chapiClient.header, Payload: header, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return encryption, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Reservation Methods
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// GetReservation reports the persistent reservation state of the device with the given serial number
+func (chapiClient *Client) GetReservation(ctx context.Context, serialNumber string) (reservation *model.Reservation, err error) {
+	log.FromContext(ctx).Tracef(">>>>> GetReservation called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetReservation")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &reservation, Err: nil}
+	reservationsURIOut := fmt.Sprintf(devicesReservationsURI, serialNumber)
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: reservationsURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// UpdateReservation applies the persistent reservation action to the device with the given serial number
+func (chapiClient *Client) UpdateReservation(ctx context.Context, serialNumber string, request *model.ReservationRequest) (reservation *model.Reservation, err error) {
+	log.FromContext(ctx).Tracef(">>>>> UpdateReservation called, serialNumber=%v, request=%v", serialNumber, request)
+	defer log.FromContext(ctx).Trace("<<<<< UpdateReservation")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &reservation, Err: nil}
+	reservationsURIOut := fmt.Sprintf(devicesReservationsURI, serialNumber)
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "PUT", Path: reservationsURIOut, Header: chapiClient.header, Payload: request, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Admin Methods
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// GetLogLevel reports the log levels of the CHAPI server
+func (chapiClient *Client) GetLogLevel(ctx context.Context) (levels *model.LogLevels, err error) {
+	log.FromContext(ctx).Trace(">>>>> GetLogLevel called")
+	defer log.FromContext(ctx).Trace("<<<<< GetLogLevel")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &levels, Err: nil}
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: adminLogLevelURI, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+// UpdateLogLevel changes the log level of the CHAPI server or of one of its packages
+func (chapiClient *Client) UpdateLogLevel(ctx context.Context, request *model.LogLevelRequest) (levels *model.LogLevels, err error) {
+	log.FromContext(ctx).Tracef(">>>>> UpdateLogLevel called, request=%v", request)
+	defer log.FromContext(ctx).Trace("<<<<< UpdateLogLevel")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &levels, Err: nil}
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "PUT", Path: adminLogLevelURI, Header: chapiClient.header, Payload: request, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
 ///////////////////////////////////////////////////////////////////////////////////////////////////
 // Mount Methods
 ///////////////////////////////////////////////////////////////////////////////////////////////////
 
 // GetMounts reports all mounts on this host for the specified Nimble volume
-func (chapiClient *Client) GetMounts(serialNumber string) (mounts []*model.Mount, err error) {
-	log.Tracef(">>>>> GetMounts called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< GetMounts")
+func (chapiClient *Client) GetMounts(ctx context.Context, serialNumber string) (mounts []*model.Mount, err error) {
+	log.FromContext(ctx).Tracef(">>>>> GetMounts called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetMounts")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: &mounts, Err: nil}
 	mountsURIOut := chapiClient.appendQuerySerialNumber(mountsURI, serialNumber)
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "GET", Path: mountsURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: mountsURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return nil, err
 	}
 	return mounts, nil
 }
 
 // GetAllMountDetails enumerates the specified mount point ID
-func (chapiClient *Client) GetAllMountDetails(serialNumber, mountPointID string) (mounts []*model.Mount, err error) {
-	log.Tracef(">>>>> GetAllMountDetails called, serialNumber=%v, mountPointID=%v", serialNumber, mountPointID)
-	defer log.Trace("<<<<< GetAllMountDetails")
+func (chapiClient *Client) GetAllMountDetails(ctx context.Context, serialNumber, mountPointID string) (mounts []*model.Mount, err error) {
+	log.FromContext(ctx).Tracef(">>>>> GetAllMountDetails called, serialNumber=%v, mountPointID=%v", serialNumber, mountPointID)
+	defer log.FromContext(ctx).Trace("<<<<< GetAllMountDetails")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: &mounts, Err: nil}
 	mountsURIOut := chapiClient.appendQuerySerialNumber(mountsDetailURI, serialNumber)
 	mountsURIOut = chapiClient.appendQueryMountPointID(mountsURIOut, mountPointID)
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "GET", Path: mountsURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: mountsURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return nil, err
 	}
 	return mounts, nil
 }
 
 // CreateMount mounts the given device to the given mount point
-func (chapiClient *Client) CreateMount(serialNumber string, mountPoint string, fsOptions *model.FileSystemOptions) (mount *model.Mount, err error) {
-	log.Tracef(">>>>> CreateMount called, serialNumber=%v, mountPoint=%v, fsOptions=%v", serialNumber, mountPoint, fsOptions)
-	defer log.Trace("<<<<< CreateMount")
+func (chapiClient *Client) CreateMount(ctx context.Context, serialNumber string, mountPoint string, fsOptions *model.FileSystemOptions) (mount *model.Mount, err error) {
+	log.FromContext(ctx).Tracef(">>>>> CreateMount called, serialNumber=%v, mountPoint=%v, fsOptions=%v", serialNumber, mountPoint, fsOptions)
+	defer log.FromContext(ctx).Trace("<<<<< CreateMount")
 
 	// Initialize model.Mount submission object
 	mountSubmission := model.Mount{
@@ -280,30 +472,73 @@ func (chapiClient *Client) CreateMount(serialNumber string, mountPoint string, f
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: &mount, Err: nil}
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "POST", Path: mountsURI, Header: chapiClient.header, Payload: &mountSubmission, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "POST", Path: mountsURI, Header: chapiClient.header, Payload: &mountSubmission, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return nil, err
 	}
 	return mount, nil
 }
 
 // DeleteMount unmounts the given mount point, serialNumber can be optional in the body
-func (chapiClient *Client) DeleteMount(serialNumber, mountPointID string) (err error) {
-	log.Tracef(">>>>> DeleteMount called, serialNumber=%v, mountPointID=%v", serialNumber, mountPointID)
-	defer log.Trace("<<<<< DeleteMount")
+func (chapiClient *Client) DeleteMount(ctx context.Context, serialNumber, mountPointID string) (err error) {
+	log.FromContext(ctx).Tracef(">>>>> DeleteMount called, serialNumber=%v, mountPointID=%v", serialNumber, mountPointID)
+	defer log.FromContext(ctx).Trace("<<<<< DeleteMount")
 
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	chapiResp := Response{Data: nil, Err: nil}
 	mountsDeleteURIOut := fmt.Sprintf(mountsDeleteURI, mountPointID)
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "DELETE", Path: mountsDeleteURIOut, Header: chapiClient.header, Payload: serialNumber, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "DELETE", Path: mountsDeleteURIOut, Header: chapiClient.header, Payload: serialNumber, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetBlockPublishes reports the block publishes of the device with the given serial number
+func (chapiClient *Client) GetBlockPublishes(ctx context.Context, serialNumber string) (publishes []*model.BlockPublish, err error) {
+	log.FromContext(ctx).Tracef(">>>>> GetBlockPublishes called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetBlockPublishes")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &publishes, Err: nil}
+	publishesURIOut := fmt.Sprintf(devicesPublishesURI, serialNumber)
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "GET", Path: publishesURIOut, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return publishes, nil
+}
+
+// CreateBlockPublish bind mounts the device node of the device with the given serial number onto the target file
+func (chapiClient *Client) CreateBlockPublish(ctx context.Context, serialNumber string, request *model.BlockPublishRequest) (publish *model.BlockPublish, err error) {
+	log.FromContext(ctx).Tracef(">>>>> CreateBlockPublish called, serialNumber=%v, request=%+v", serialNumber, request)
+	defer log.FromContext(ctx).Trace("<<<<< CreateBlockPublish")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: &publish, Err: nil}
+	publishesURIOut := fmt.Sprintf(devicesPublishesURI, serialNumber)
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "POST", Path: publishesURIOut, Header: chapiClient.header, Payload: request, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+		return nil, err
+	}
+	return publish, nil
+}
+
+// DeleteBlockPublish unmounts the device node of the device with the given serial number from the target file
+func (chapiClient *Client) DeleteBlockPublish(ctx context.Context, serialNumber string, targetPath string) (err error) {
+	log.FromContext(ctx).Tracef(">>>>> DeleteBlockPublish called, serialNumber=%v, targetPath=%v", serialNumber, targetPath)
+	defer log.FromContext(ctx).Trace("<<<<< DeleteBlockPublish")
+
+	// Initialize CHAPI response object, submit request to specified endpoint, return status
+	chapiResp := Response{Data: nil, Err: nil}
+	publishesURIOut := fmt.Sprintf(devicesPublishesURI, serialNumber)
+	request := &model.BlockPublishRequest{TargetPath: targetPath}
+	if _, err = chapiClient.chapiClientDoJSON(ctx, &connectivity.Request{Action: "DELETE", Path: publishesURIOut, Header: chapiClient.header, Payload: request, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return err
 	}
 	return nil
 }
 
 // CreateBindMount creates the given bind mount
-func (chapiClient *Client) CreateBindMount(sourceMount string, targetMount string, bindType string) (mount *model.Mount, err error) {
-	log.Tracef(">>>>> CreateBindMount called, sourceMount=%s, targetMount=%s bindType=%s", sourceMount, targetMount, bindType)
-	defer log.Trace("<<<<< CreateBindMount")
+func (chapiClient *Client) CreateBindMount(ctx context.Context, sourceMount string, targetMount string, bindType string) (mount *model.Mount, err error) {
+	log.FromContext(ctx).Tracef(">>>>> CreateBindMount called, sourceMount=%s, targetMount=%s bindType=%s", sourceMount, targetMount, bindType)
+	defer log.FromContext(ctx).Trace("<<<<< CreateBindMount")
 
 	// TODO
 	return nil, cerrors.NewChapiError(cerrors.Unimplemented)
@@ -313,22 +548,33 @@ func (chapiClient *Client) CreateBindMount(sourceMount string, targetMount strin
 // Internal Support Methods
 ///////////////////////////////////////////////////////////////////////////////////////////////////
 
-// chapiClientDoJSON wraps the call to chapiClient.client.DoJSON().  If the request fails, and an
-// error was returned by the CHAPI server, that error is returned instead.
-func (chapiClient *Client) chapiClientDoJSON(r *connectivity.Request) (int, error) {
+// chapiClientDoJSON wraps the call to chapiClient.client.DoJSONWithContext().  If the request
+// fails, and an error was returned by the CHAPI server, that error is returned instead.  The
+// request is canceled along with ctx, and the request ID of ctx, if any, is sent along so the
+// CHAPI server logs the request with the same ID.
+func (chapiClient *Client) chapiClientDoJSON(ctx context.Context, r *connectivity.Request) (int, error) {
+
+	// Forward the request ID without changing the headers shared by all requests
+	if requestID, ok := log.ContextFields(ctx)[log.RequestIDKey]; ok {
+		header := map[string]string{log.RequestIDHeader: fmt.Sprint(requestID)}
+		for key, value := range r.Header {
+			header[key] = value
+		}
+		r.Header = header
+	}
 
 	// Start by calling submitting the request to the CHAPI endpoint
-	statusCode, err := chapiClient.client.DoJSON(r)
+	statusCode, err := chapiClient.client.DoJSONWithContext(ctx, r)
 
 	if err != nil {
 		//  If we received an error from the CHAPI server, use that error object
 		if cerror, ok := r.ResponseError.(*Response); ok && (cerror.Err != nil) {
-			log.Error("CHAPI Error : ", cerror.Err)
+			log.FromContext(ctx).Error("CHAPI Error : ", cerror.Err)
 			return 0, cerror.Err
 		}
 
 		// For all other errors, return the connectivity error
-		log.Error("Connectivity Error : ", err)
+		log.FromContext(ctx).Error("Connectivity Error : ", err)
 		return 0, err
 	}
 
@@ -6,3 +6,117 @@ package chapiclient
 // functionality, that extends the endpoint support, should be placed in this module.  For example,
 // CHAPI1 has an AttachAndMountDevice() Client method that makes use of multiple CHAPI endpoints.
 // This type of extended functionality, if needed, would be placed in this module.
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/connectivity"
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+
+	// Delay before reopening a failed event stream, doubled up to the maximum on each failure
+	eventRetryDelay    = time.Second
+	eventMaxRetryDelay = 30 * time.Second
+
+	// Largest event line accepted from the event stream
+	maxEventLineSize = 1024 * 1024
+)
+
+// SubscribeEvents calls handler with each device and mount event received from the CHAPI server
+// until ctx is canceled.  If lastEventID is nil, only events that occur after subscribing are
+// received, otherwise the events after lastEventID are received first.  The event stream is
+// reopened after a connection failure or a server error, resuming after the last event received.  A
+// resync event is received if events were missed, the devices and mounts should then be enumerated
+// again.  SubscribeEvents returns ctx.Err() when ctx is canceled, or the error of a subscription
+// the server refused (e.g. an invalid token).
+func (chapiClient *Client) SubscribeEvents(ctx context.Context, lastEventID *uint64, handler func(event *model.Event)) error {
+	log.FromContext(ctx).Trace(">>>>> SubscribeEvents called")
+	defer log.FromContext(ctx).Trace("<<<<< SubscribeEvents")
+
+	delay := eventRetryDelay
+	for {
+		header := make(map[string]string)
+		for key, value := range chapiClient.header {
+			header[key] = value
+		}
+		if lastEventID != nil {
+			header[lastEventIDHeader] = strconv.FormatUint(*lastEventID, 10)
+		}
+
+		chapiResp := Response{}
+		stream, err := chapiClient.client.DoStream(ctx, &connectivity.Request{Action: "GET", Path: eventsURI, Header: header, ResponseError: &chapiResp})
+		if err == nil {
+			delay = eventRetryDelay
+			lastEventID, err = readEventStream(stream.Body, lastEventID, handler)
+			stream.Body.Close()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if chapiResp.Err != nil {
+			if stream == nil || stream.StatusCode < http.StatusInternalServerError {
+				// The server refused the subscription (e.g. invalid token), retrying won't help
+				log.FromContext(ctx).Error("CHAPI Error : ", chapiResp.Err)
+				return chapiResp.Err
+			}
+			// The server failed to open the stream, it may recover
+			err = chapiResp.Err
+		}
+		log.FromContext(ctx).Infof("event stream closed, reopening in %v, err=%v", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > eventMaxRetryDelay {
+			delay = eventMaxRetryDelay
+		}
+	}
+}
+
+// readEventStream calls handler with each event read from a text/event-stream until the stream
+// ends, and returns the ID of the last event read
+func readEventStream(stream io.Reader, lastEventID *uint64, handler func(event *model.Event)) (*uint64, error) {
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 4096), maxEventLineSize)
+
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// A blank line dispatches the event
+			if len(data) == 0 {
+				continue
+			}
+			event := &model.Event{}
+			if err := json.Unmarshal([]byte(strings.Join(data, "\n")), event); err != nil {
+				log.Errorf("unable to decode event %v, err=%v", data, err)
+			} else {
+				id := event.ID
+				lastEventID = &id
+				handler(event)
+			}
+			data = nil
+		case strings.HasPrefix(line, ":"):
+			// Comment (e.g. keepalive)
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		default:
+			// The id and event fields are repeated in the event data
+		}
+	}
+	return lastEventID, scanner.Err()
+}
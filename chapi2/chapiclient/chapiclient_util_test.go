@@ -0,0 +1,88 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package chapiclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/connectivity"
+)
+
+func TestReadEventStream(t *testing.T) {
+	stream := ": keepalive\n\n" +
+		"id: 41\nevent: device_added\ndata: {\"id\":41,\"type\":\"device_added\",\"serial_number\":\"a\"}\n\n" +
+		"id: 42\nevent: mount_added\ndata: {\"id\":42,\n" +
+		"data: \"type\":\"mount_added\",\"mount\":{\"id\":\"1\"}}\n\n" +
+		"id: 43\nevent: bad\ndata: {\n\n" +
+		"id: 44\nevent: device_removed\ndata: {\"id\":44,\"type\":\"device_removed\""
+
+	var received []*model.Event
+	lastEventID, err := readEventStream(strings.NewReader(stream), nil, func(event *model.Event) {
+		received = append(received, event)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The malformed event is skipped and the incomplete event at the end of the stream isn't dispatched
+	if len(received) != 2 || received[0].SerialNumber != "a" || received[1].Mount == nil || received[1].Mount.ID != "1" {
+		t.Errorf("unexpected events %+v", received)
+	}
+	if lastEventID == nil || *lastEventID != 42 {
+		t.Errorf("unexpected last event ID %v", lastEventID)
+	}
+
+	// Nothing read keeps the previous last event ID
+	previous := uint64(7)
+	if lastEventID, _ = readEventStream(strings.NewReader(": keepalive\n\n"), &previous, nil); lastEventID != &previous {
+		t.Errorf("unexpected last event ID %v", lastEventID)
+	}
+}
+
+func TestSubscribeEventsRetriesServerErrors(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Authorization") == "bad":
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"errors":{"code":16,"text":"invalid token"}}`)
+		case atomic.AddInt32(&requests, 1) == 1:
+			// The first subscription fails while the server is busy
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"errors":{"code":13,"text":"internal error"}}`)
+		default:
+			fmt.Fprint(w, "id: 7\nevent: resync\ndata: {\"id\":7,\"type\":\"resync\"}\n\n")
+		}
+	}))
+	defer server.Close()
+	client := &Client{ClientBase: ClientBase{client: connectivity.NewHTTPClientWithTimeout(server.URL, time.Second)}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var received *model.Event
+	err := client.SubscribeEvents(ctx, nil, func(event *model.Event) {
+		received = event
+		cancel()
+	})
+	if err != context.Canceled {
+		t.Errorf("unexpected error %v", err)
+	}
+	if received == nil || received.ID != 7 || atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("expected the subscription to be retried, got %+v after %v requests", received, atomic.LoadInt32(&requests))
+	}
+
+	// A refused subscription is not retried
+	client.addHeader(map[string]string{"Authorization": "bad"})
+	err = client.SubscribeEvents(context.Background(), nil, func(event *model.Event) {})
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Text != "invalid token" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -3,6 +3,7 @@
 package chapiclient
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -156,7 +157,7 @@ func (chapiClient *Client) GetAccessKey() (accessKey string, err error) {
 	// Initialize CHAPI response object, submit request to specified endpoint, return status
 	var accessKeyPath *model.KeyFileInfo
 	chapiResp := Response{Data: &accessKeyPath, Err: nil}
-	if _, err = chapiClient.chapiClientDoJSON(&connectivity.Request{Action: "GET", Path: keyFileURI, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
+	if _, err = chapiClient.chapiClientDoJSON(context.Background(), &connectivity.Request{Action: "GET", Path: keyFileURI, Header: chapiClient.header, Payload: nil, Response: &chapiResp, ResponseError: &chapiResp}); err != nil {
 		return "", err
 	}
 
@@ -3,15 +3,18 @@
 package driver
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/encryption"
 	"github.com/hpe-storage/common-host-libs/chapi2/fc"
 	"github.com/hpe-storage/common-host-libs/chapi2/host"
 	"github.com/hpe-storage/common-host-libs/chapi2/iscsi"
 	"github.com/hpe-storage/common-host-libs/chapi2/model"
 	"github.com/hpe-storage/common-host-libs/chapi2/mount"
 	"github.com/hpe-storage/common-host-libs/chapi2/multipath"
+	"github.com/hpe-storage/common-host-libs/chapi2/reservation"
 	"github.com/hpe-storage/common-host-libs/chapi2/virtualdevice"
 	log "github.com/hpe-storage/common-host-libs/logger"
 )
@@ -19,6 +22,7 @@ import (
 const (
 	// Shared error messages
 	errorMessageEmptyIqnFound         = "empty iqn found"
+	errorMessageEncryptionBlockOnly   = "encryption only supported for block devices"
 	errorMessageMultipleDevices       = "multiple (%v) devices enumerated"
 	errorMessageMultipleDeviceObjects = "multiple device access objects provided"
 	errorMessageNoDeviceObject        = "device access object not provided"
@@ -28,18 +32,22 @@ const (
 	errorMessageNoNetworkInterfaces   = "no network interfaces found on host"
 	errorMessageNoPartitionsOnVolume  = "no partitions found on volume"
 	errorMessageNotYetImplemented     = "not yet implemented"
+	errorMessageVolumeInUseByHost     = "volume in use by the host with reservation key %v"
+	errorMessageVolumeInUseUnknown    = "unable to determine if the volume is in use by another host, %v"
 	errorMessageVolumeMounted         = "volume mounted"
+	errorMessageVolumePublished       = "volume published as a block device"
 )
 
-// Driver provides a common interface for host related operations
+// Driver provides a common interface for host related operations.  The context of each call
+// carries the log fields of the request (see log.FromContext).
 type Driver interface {
 	///////////////////////////////////////////////////////////////////////////////////////////
 	// Host Methods
 	///////////////////////////////////////////////////////////////////////////////////////////
 
-	GetHostInfo() (*model.Host, error)              // GET /api/v1/hosts
-	GetHostInitiators() ([]*model.Initiator, error) // GET /api/v1/initiators
-	GetHostNetworks() ([]*model.Network, error)     // GET /api/v1/networks
+	GetHostInfo(ctx context.Context) (*model.Host, error)              // GET /api/v1/hosts
+	GetHostInitiators(ctx context.Context) ([]*model.Initiator, error) // GET /api/v1/initiators
+	GetHostNetworks(ctx context.Context) ([]*model.Network, error)     // GET /api/v1/networks
 
 	///////////////////////////////////////////////////////////////////////////////////////////
 	// Device Methods
@@ -47,26 +55,61 @@ type Driver interface {
 
 	// GET /api/v1/devices or
 	// GET /api/v1/devices?serial=serial
-	GetDevices(serialNumber string) ([]*model.Device, error)
+	GetDevices(ctx context.Context, serialNumber string) ([]*model.Device, error)
 
 	// GET /api/v1/devices/details or
 	// GET /api/v1/devices/details?serial=serial
-	GetAllDeviceDetails(serialNumber string) ([]*model.Device, error)
+	GetAllDeviceDetails(ctx context.Context, serialNumber string) ([]*model.Device, error)
 
 	// GET /api/v1/devices/{serialnumber}/partitions
-	GetPartitionInfo(serialNumber string) ([]*model.DevicePartition, error)
+	GetPartitionInfo(ctx context.Context, serialNumber string) ([]*model.DevicePartition, error)
 
 	// POST /api/v1/devices
-	CreateDevice(publishInfo model.PublishInfo) (*model.Device, error)
+	CreateDevice(ctx context.Context, publishInfo model.PublishInfo) (*model.Device, error)
 
 	// DELETE /api/v1/devices/{serialnumber}
-	DeleteDevice(serialNumber string) error
+	DeleteDevice(ctx context.Context, serialNumber string) error
 
 	// PUT /api/v1/devices/{serialnumber}/actions/offline
-	OfflineDevice(serialNumber string) error
+	OfflineDevice(ctx context.Context, serialNumber string) error
+
+	// PUT /api/v1/devices/{serialnumber}/actions/expand
+	ExpandDevice(ctx context.Context, serialNumber string) (*model.Device, error)
+
+	// PUT /api/v1/devices/{serialnumber}/actions/fscheck
+	CheckFileSystem(ctx context.Context, serialNumber string) (*model.FileSystemCheck, error)
+
+	// PUT /api/v1/devices/{serialnumber}/actions/fsrepair
+	RepairFileSystem(ctx context.Context, serialNumber string) (*model.FileSystemCheck, error)
 
 	// PUT /api/v1/devices/{serialnumber}/filesystem/{filesystem}
-	CreateFileSystem(serialNumber string, filesystem string) error
+	CreateFileSystem(ctx context.Context, serialNumber string, filesystem string) error
+
+	///////////////////////////////////////////////////////////////////////////////////////////
+	// Encryption Methods
+	///////////////////////////////////////////////////////////////////////////////////////////
+
+	// GET /api/v1/devices/{serialnumber}/encryption
+	GetEncryption(ctx context.Context, serialNumber string) (*model.Encryption, error)
+
+	// PUT /api/v1/devices/{serialnumber}/encryption
+	UpdateEncryption(ctx context.Context, serialNumber string, request *model.EncryptionRequest) (*model.Encryption, error)
+
+	// GET /api/v1/devices/{serialnumber}/encryption/header
+	GetEncryptionHeader(ctx context.Context, serialNumber string) (*model.EncryptionHeader, error)
+
+	// PUT /api/v1/devices/{serialnumber}/encryption/header
+	RestoreEncryptionHeader(ctx context.Context, serialNumber string, header *model.EncryptionHeader) (*model.Encryption, error)
+
+	///////////////////////////////////////////////////////////////////////////////////////////
+	// Reservation Methods
+	///////////////////////////////////////////////////////////////////////////////////////////
+
+	// GET /api/v1/devices/{serialnumber}/reservations
+	GetReservation(ctx context.Context, serialNumber string) (*model.Reservation, error)
+
+	// PUT /api/v1/devices/{serialnumber}/reservations
+	UpdateReservation(ctx context.Context, serialNumber string, request *model.ReservationRequest) (*model.Reservation, error)
 
 	///////////////////////////////////////////////////////////////////////////////////////////
 	// Mount Methods
@@ -74,22 +117,31 @@ type Driver interface {
 
 	// GET /api/v1/mounts or
 	// GET /api/v1/mounts?serial=serial
-	GetMounts(serialNumber string) ([]*model.Mount, error)
+	GetMounts(ctx context.Context, serialNumber string) ([]*model.Mount, error)
 
 	// GET /api/v1/mounts/details  or filter by serial using
 	// GET /api/v1/mounts/details?serial=serial or filter by serial and specific mount using
 	// GET /api/v1/mounts/details?serial=serial,mountId=mount
-	GetAllMountDetails(serialNumber, mountPointID string) ([]*model.Mount, error)
+	GetAllMountDetails(ctx context.Context, serialNumber, mountPointID string) ([]*model.Mount, error)
 
 	// POST /api/v1/mounts
-	CreateMount(serialNumber string, mountPoint string, fsOptions *model.FileSystemOptions) (*model.Mount, error)
+	CreateMount(ctx context.Context, serialNumber string, mountPoint string, fsOptions *model.FileSystemOptions) (*model.Mount, error)
 
 	// DELETE /api/v1/mounts/{mountId}
-	DeleteMount(serialNumber, mountPointID string) error
+	DeleteMount(ctx context.Context, serialNumber, mountPointID string) error
+
+	// GET /api/v1/devices/{serialnumber}/publishes
+	GetBlockPublishes(ctx context.Context, serialNumber string) ([]*model.BlockPublish, error)
+
+	// POST /api/v1/devices/{serialnumber}/publishes
+	CreateBlockPublish(ctx context.Context, serialNumber string, request *model.BlockPublishRequest) (*model.BlockPublish, error)
+
+	// DELETE /api/v1/devices/{serialnumber}/publishes
+	DeleteBlockPublish(ctx context.Context, serialNumber string, targetPath string) error
 
 	// TODO: check with George/Suneeth on this
 	// POST /api/v1/mounts/bind
-	CreateBindMount(sourceMount string, targetMount string, bindType string) (*model.Mount, error)
+	CreateBindMount(ctx context.Context, sourceMount string, targetMount string, bindType string) (*model.Mount, error)
 }
 
 // ChapiServer ... Implements the "Driver" interfaces
@@ -101,41 +153,41 @@ type ChapiServer struct {
 ///////////////////////////////////////////////////////////////////////////////////////////////////
 
 // GetHostInfo returns host name, domain, and network interfaces
-func (driver *ChapiServer) GetHostInfo() (*model.Host, error) {
-	log.Trace(">>>>> GetHostInfo called")
-	defer log.Trace("<<<<< GetHostInfo")
+func (driver *ChapiServer) GetHostInfo(ctx context.Context) (*model.Host, error) {
+	log.FromContext(ctx).Trace(">>>>> GetHostInfo called")
+	defer log.FromContext(ctx).Trace("<<<<< GetHostInfo")
 	hostPlugin := host.NewHostPlugin()
 
-	log.Info("Get Host Information")
+	log.FromContext(ctx).Info("Get Host Information")
 
 	id, err := hostPlugin.GetUuid()
 	if err != nil {
 		return nil, cerrors.NewChapiError(err)
 	}
-	log.Infof("Host UUID - %v", id)
+	log.FromContext(ctx).Infof("Host UUID - %v", id)
 
 	hostName, err := hostPlugin.GetHostName()
 	if err != nil {
 		return nil, cerrors.NewChapiError(err)
 	}
-	log.Infof("Host Name - %v", hostName)
+	log.FromContext(ctx).Infof("Host Name - %v", hostName)
 
 	domainName, err := hostPlugin.GetDomainName()
 	if err != nil {
 		return nil, cerrors.NewChapiError(err)
 	}
-	log.Infof("Domain Name - %v", domainName)
+	log.FromContext(ctx).Infof("Domain Name - %v", domainName)
 
 	return &model.Host{UUID: id, Name: hostName, Domain: domainName}, nil
 }
 
 // GetHostNetworks reports the networks on this host
-func (driver *ChapiServer) GetHostNetworks() ([]*model.Network, error) {
-	log.Trace(">>>>> GetHostNetworks called")
-	defer log.Trace("<<<<< GetHostNetworks")
+func (driver *ChapiServer) GetHostNetworks(ctx context.Context) ([]*model.Network, error) {
+	log.FromContext(ctx).Trace(">>>>> GetHostNetworks called")
+	defer log.FromContext(ctx).Trace("<<<<< GetHostNetworks")
 	hostPlugin := host.NewHostPlugin()
 
-	log.Info("Get Host Networks")
+	log.FromContext(ctx).Info("Get Host Networks")
 
 	networks, err := hostPlugin.GetNetworks()
 	if err != nil {
@@ -144,25 +196,25 @@ func (driver *ChapiServer) GetHostNetworks() ([]*model.Network, error) {
 	if len(networks) == 0 {
 		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoNetworkInterfaces)
 	}
-	driver.logNetworks(networks)
+	driver.logNetworks(ctx, networks)
 	return networks, nil
 }
 
 // GetHostInitiators reports the initiators on this host
-func (driver *ChapiServer) GetHostInitiators() ([]*model.Initiator, error) {
-	log.Trace(">>>>> GetHostInitiators called")
-	defer log.Trace("<<<<< GetHostInitiators")
+func (driver *ChapiServer) GetHostInitiators(ctx context.Context) ([]*model.Initiator, error) {
+	log.FromContext(ctx).Trace(">>>>> GetHostInitiators called")
+	defer log.FromContext(ctx).Trace("<<<<< GetHostInitiators")
 	//var inits Initiators
 	var inits []*model.Initiator
 
-	log.Info("Get Host Initiators")
+	log.FromContext(ctx).Info("Get Host Initiators")
 
 	// fetch iscsi initiator details
 	iscsiPlugin := iscsi.NewIscsiPlugin()
 
 	iscsiInits, err := iscsiPlugin.GetIscsiInitiators()
 	if err != nil {
-		log.Trace("Error getting iscsiInitiator: ", err)
+		log.FromContext(ctx).Trace("Error getting iscsiInitiator: ", err)
 	}
 
 	// fetch fc initiator details
@@ -170,7 +222,7 @@ func (driver *ChapiServer) GetHostInitiators() ([]*model.Initiator, error) {
 
 	fcInits, err := fcPlugin.GetFcInitiators()
 	if err != nil {
-		log.Trace("Error getting FcInitiator: ", err)
+		log.FromContext(ctx).Trace("Error getting FcInitiator: ", err)
 	}
 	if fcInits != nil {
 		inits = append(inits, fcInits)
@@ -186,7 +238,7 @@ func (driver *ChapiServer) GetHostInitiators() ([]*model.Initiator, error) {
 	// Log enumerated iSCSI and FC initiators
 	for _, initiator := range inits {
 		for _, init := range initiator.Init {
-			log.Infof("AccessProtocol=%v, Initiator=%v", initiator.AccessProtocol, init)
+			log.FromContext(ctx).Infof("AccessProtocol=%v, Initiator=%v", initiator.AccessProtocol, init)
 		}
 	}
 
@@ -199,15 +251,15 @@ func (driver *ChapiServer) GetHostInitiators() ([]*model.Initiator, error) {
 
 // GetDevices enumerates all the Nimble volumes with basic details.
 // If serialNumber is non-empty then only specified device is returned
-func (driver *ChapiServer) GetDevices(serialNumber string) ([]*model.Device, error) {
-	log.Tracef(">>>>> GetDevices called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< GetDevices")
+func (driver *ChapiServer) GetDevices(ctx context.Context, serialNumber string) ([]*model.Device, error) {
+	log.FromContext(ctx).Tracef(">>>>> GetDevices called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetDevices")
 	multipathPlugin := multipath.NewMultipathPlugin()
 
-	log.Infof("Get Devices, serialNumber=%v", serialNumber)
+	log.FromContext(ctx).Infof("Get Devices, serialNumber=%v", serialNumber)
 
 	// Enumerate all the Nimble volumes on this host (basic details only)
-	devices, err := multipathPlugin.GetDevices(serialNumber)
+	devices, err := multipathPlugin.GetDevices(ctx, serialNumber)
 	if err != nil {
 		return nil, cerrors.NewChapiError(err)
 	}
@@ -219,7 +271,7 @@ func (driver *ChapiServer) GetDevices(serialNumber string) ([]*model.Device, err
 
 	// Log enumerated device serial numbers
 	for _, device := range devices {
-		log.Infof("Device SerialNumber=%v", device.SerialNumber)
+		log.FromContext(ctx).Infof("Device SerialNumber=%v", device.SerialNumber)
 	}
 
 	return devices, nil
@@ -227,15 +279,15 @@ func (driver *ChapiServer) GetDevices(serialNumber string) ([]*model.Device, err
 
 // GetAllDeviceDetails enumerates all the Nimble volumes with detailed information.
 // If serialNumber is non-empty then only specified device is returned
-func (driver *ChapiServer) GetAllDeviceDetails(serialNumber string) ([]*model.Device, error) {
-	log.Tracef(">>>>> GetAllDeviceDetails called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< GetAllDeviceDetails")
+func (driver *ChapiServer) GetAllDeviceDetails(ctx context.Context, serialNumber string) ([]*model.Device, error) {
+	log.FromContext(ctx).Tracef(">>>>> GetAllDeviceDetails called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetAllDeviceDetails")
 	multipathPlugin := multipath.NewMultipathPlugin()
 
-	log.Infof("Get All Device Details, serialNumber=%v", serialNumber)
+	log.FromContext(ctx).Infof("Get All Device Details, serialNumber=%v", serialNumber)
 
 	// Enumerate all the Nimble volumes on this host (full details)
-	devices, err := multipathPlugin.GetAllDeviceDetails(serialNumber)
+	devices, err := multipathPlugin.GetAllDeviceDetails(ctx, serialNumber)
 	if err != nil {
 		return nil, cerrors.NewChapiError(err)
 	}
@@ -246,21 +298,21 @@ func (driver *ChapiServer) GetAllDeviceDetails(serialNumber string) ([]*model.De
 	}
 
 	// Log enumerated device details
-	driver.logDeviceArrayDetails(devices)
+	driver.logDeviceArrayDetails(ctx, devices)
 
 	return devices, nil
 }
 
 // GetPartitionInfo reports the partitions on the provided device
-func (driver *ChapiServer) GetPartitionInfo(serialNumber string) ([]*model.DevicePartition, error) {
-	log.Tracef(">>>>> GetPartitionInfo called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< GetPartitionInfo")
+func (driver *ChapiServer) GetPartitionInfo(ctx context.Context, serialNumber string) ([]*model.DevicePartition, error) {
+	log.FromContext(ctx).Tracef(">>>>> GetPartitionInfo called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetPartitionInfo")
 	multipathPlugin := multipath.NewMultipathPlugin()
 
-	log.Infof("Get Partition Information, serialNumber=%v", serialNumber)
+	log.FromContext(ctx).Infof("Get Partition Information, serialNumber=%v", serialNumber)
 
 	// Enumerate all the Nimble volume's partition
-	partitions, err := multipathPlugin.GetPartitionInfo(serialNumber)
+	partitions, err := multipathPlugin.GetPartitionInfo(ctx, serialNumber)
 	if err != nil {
 		return nil, cerrors.NewChapiError(err)
 	}
@@ -272,131 +324,363 @@ func (driver *ChapiServer) GetPartitionInfo(serialNumber string) ([]*model.Devic
 
 	// Log enumerated partition details
 	for _, partition := range partitions {
-		log.Infof("Partition Name=%v, PartitionType=%v, Size=%v", partition.Name, partition.PartitionType, partition.Size)
+		log.FromContext(ctx).Infof("Partition Name=%v, PartitionType=%v, Size=%v", partition.Name, partition.PartitionType, partition.Size)
 	}
 
 	return partitions, nil
 }
 
 // CreateDevice will attach device on this host based on the details provided
-func (driver *ChapiServer) CreateDevice(publishInfo model.PublishInfo) (*model.Device, error) {
-	log.Tracef(">>>>> CreateDevice called, publishInfo=%v", publishInfo)
-	defer log.Trace("<<<<< CreateDevice")
+func (driver *ChapiServer) CreateDevice(ctx context.Context, publishInfo model.PublishInfo) (*model.Device, error) {
+	log.FromContext(ctx).Tracef(">>>>> CreateDevice called, publishInfo=%v", log.Redacted(publishInfo))
+	defer log.FromContext(ctx).Trace("<<<<< CreateDevice")
 
-	log.Info("Create Device")
+	log.FromContext(ctx).Info("Create Device")
 
 	// Invalid request if no device access object provided
 	if (publishInfo.BlockDev == nil) && (publishInfo.VirtualDev == nil) {
 		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageNoDeviceObject)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, err
 	}
 
 	// Invalid request if multiple device access objects provided
 	if (publishInfo.BlockDev != nil) && (publishInfo.VirtualDev != nil) {
 		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMultipleDeviceObjects)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	// LUKS mappings are only opened on top of multipath block devices
+	if (publishInfo.EncryptionKey != "") && (publishInfo.VirtualDev != nil) {
+		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageEncryptionBlockOnly)
+		log.FromContext(ctx).Error(err)
 		return nil, err
 	}
 
 	// Attach the virtual device
+	var device *model.Device
+	var err error
 	if publishInfo.VirtualDev != nil {
-		virtualDevPlugin := virtualdevice.NewVirtualDevPlugin()
-		_ = virtualDevPlugin // Avoid "declared and not used" errors until feature is implemented
-		return nil, cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+		device, err = virtualdevice.NewVirtualDevPlugin().AttachDevice(ctx, &publishInfo)
+	} else {
+		// Attach the block device
+		device, err = multipath.NewMultipathPlugin().AttachDevice(ctx, publishInfo.SerialNumber, *publishInfo.BlockDev)
 	}
-
-	// Attach the block device
-	multipathPlugin := multipath.NewMultipathPlugin()
-	device, err := multipathPlugin.AttachDevice(publishInfo.SerialNumber, *publishInfo.BlockDev)
 	if err != nil {
 		return nil, err
 	}
 
-	driver.logDeviceDetails(device)
+	// Format the device with the encryption key if it is new, and open its LUKS mapping
+	if publishInfo.EncryptionKey != "" {
+		if _, err = encryption.NewEncryptionPlugin().PrepareDevice(*device, publishInfo.EncryptionKey); err != nil {
+			return nil, err
+		}
+	}
+
+	driver.logDeviceDetails(ctx, device)
 	return device, nil
 }
 
 // DeleteDevice will delete the given device from the host
-func (driver *ChapiServer) DeleteDevice(serialNumber string) error {
-	log.Tracef(">>>>> DeleteDevice called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< DeleteDevice")
+func (driver *ChapiServer) DeleteDevice(ctx context.Context, serialNumber string) error {
+	log.FromContext(ctx).Tracef(">>>>> DeleteDevice called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< DeleteDevice")
 	multipathPlugin := multipath.NewMultipathPlugin()
 
-	log.Infof("Delete Device, serialNumber=%v", serialNumber)
-
-	// TODO - handle VirtualDev vs BlockDev
+	log.FromContext(ctx).Infof("Delete Device, serialNumber=%v", serialNumber)
 
-	// Find the device serial number details.  If the device is not present on this host (i.e.
-	// cerrors.NotFound), there is no device to detach so we return no error.
-	devices, err := multipathPlugin.GetAllDeviceDetails(serialNumber)
+	// Find the device serial number details.  If the block device is not present on this host, it
+	// might be a virtual device if the host has any.
+	devices, err := multipathPlugin.GetAllDeviceDetails(ctx, serialNumber)
+	if err != nil {
+		return err
+	}
 	if len(devices) == 0 {
-		log.Infof("Serial number %v not present, returning success", serialNumber)
+		if virtualdevice.NewVirtualDevPlugin().HasVirtualDevices() {
+			return driver.deleteVirtualDevice(ctx, serialNumber)
+		}
+		log.FromContext(ctx).Infof("Serial number %v not present, returning success", serialNumber)
 		return nil
-	} else if err != nil {
-		return err
 	}
 
 	// Fail request if device is mounted.  We only allow deleting the device if it isn't already
 	// mounted.  Caller should dismount the device before attempting to delete the device.
-	if mounts, _ := driver.GetMounts(serialNumber); len(mounts) > 0 {
+	if mounts, _ := driver.GetMounts(ctx, serialNumber); len(mounts) > 0 {
 		err = cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageVolumeMounted)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+
+	// Likewise, the device node must not be bind mounted onto a block publish target
+	if publishes, _ := driver.GetBlockPublishes(ctx, serialNumber); len(publishes) > 0 {
+		err = cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageVolumePublished)
+		log.FromContext(ctx).Error(err)
 		return err
 	}
 
 	// Detach the block device
-	driver.logDeviceDetails(devices[0])
-	if err := multipathPlugin.DetachDevice(*devices[0]); err != nil {
+	driver.logDeviceDetails(ctx, devices[0])
+	if err := multipathPlugin.DetachDevice(ctx, *devices[0]); err != nil {
 		return err
 	}
 
 	// Success!!!
-	log.Infof("Device Deleted, SerialNumber=%v", serialNumber)
+	log.FromContext(ctx).Infof("Device Deleted, SerialNumber=%v", serialNumber)
+	return nil
+}
+
+// deleteVirtualDevice will delete the given virtual device from the host.  If the device is not
+// present on this host, there is no device to detach so we return no error.
+func (driver *ChapiServer) deleteVirtualDevice(ctx context.Context, serialNumber string) error {
+	virtualDevPlugin := virtualdevice.NewVirtualDevPlugin()
+	device, err := virtualDevPlugin.GetDevice(serialNumber)
+	if (device == nil) || (err != nil) {
+		log.FromContext(ctx).Infof("Serial number %v not present, returning success", serialNumber)
+		return nil
+	}
+
+	// Fail request if device is mounted
+	if mounts, _ := driver.GetMounts(ctx, serialNumber); len(mounts) > 0 {
+		err = cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageVolumeMounted)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+
+	// Detach the virtual device
+	driver.logDeviceDetails(ctx, device)
+	if err = virtualDevPlugin.DetachDevice(ctx, *device); err != nil {
+		return err
+	}
+
+	log.FromContext(ctx).Infof("Virtual Device Deleted, SerialNumber=%v", serialNumber)
 	return nil
 }
 
 // OfflineDevice will offline the given device from the host
-func (driver *ChapiServer) OfflineDevice(serialNumber string) error {
-	log.Tracef(">>>>> OfflineDevice called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< OfflineDevice")
+func (driver *ChapiServer) OfflineDevice(ctx context.Context, serialNumber string) error {
+	log.FromContext(ctx).Tracef(">>>>> OfflineDevice called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< OfflineDevice")
 	multipathPlugin := multipath.NewMultipathPlugin()
 
-	log.Infof("Offline Device, serialNumber=%v", serialNumber)
+	log.FromContext(ctx).Infof("Offline Device, serialNumber=%v", serialNumber)
 
 	// Enumerate basic details for the serial number
-	device, err := driver.getSingleDeviceSummary(serialNumber)
+	device, err := driver.getSingleDeviceSummary(ctx, serialNumber)
 	if err != nil {
 		return err
 	}
 
 	// Offline the device
-	if err := multipathPlugin.OfflineDevice(*device); err != nil {
+	if err := multipathPlugin.OfflineDevice(ctx, *device); err != nil {
 		return err
 	}
 
 	// Success!!!
-	log.Infof("Device Offlined, SerialNumber=%v", serialNumber)
+	log.FromContext(ctx).Infof("Device Offlined, SerialNumber=%v", serialNumber)
 	return nil
 }
 
+// ExpandDevice picks up the new capacity of the device with the given serial number, after the
+// volume was grown on the array, and grows the file systems mounted from it.  The device is
+// returned with its new size.
+func (driver *ChapiServer) ExpandDevice(ctx context.Context, serialNumber string) (*model.Device, error) {
+	log.FromContext(ctx).Tracef(">>>>> ExpandDevice called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< ExpandDevice")
+	multipathPlugin := multipath.NewMultipathPlugin()
+
+	log.FromContext(ctx).Infof("Expand Device, serialNumber=%v", serialNumber)
+
+	// Enumerate basic details for the serial number
+	device, err := driver.getSingleDeviceSummary(ctx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	previousSize := device.Size
+
+	// Rescan the paths and resize the multipath map (and LUKS mapping if any)
+	if err = multipathPlugin.ExpandDevice(ctx, *device); err != nil {
+		return nil, err
+	}
+
+	// Grow the file systems of the mounted volume to fill the device
+	if _, err = mount.NewMounter().ExpandFileSystems(ctx, serialNumber); err != nil {
+		return nil, err
+	}
+
+	// Enumerate the device again to report its new size
+	if device, err = driver.getSingleDeviceSummary(ctx, serialNumber); err != nil {
+		return nil, err
+	}
+
+	// Success!!!
+	log.FromContext(ctx).Infof("Device Expanded, SerialNumber=%v, Size=%v, PreviousSize=%v", serialNumber, device.Size, previousSize)
+	return device, nil
+}
+
+// CheckFileSystem runs a read-only check of the file system on the device with the given serial
+// number.  The file system must not be mounted.
+func (driver *ChapiServer) CheckFileSystem(ctx context.Context, serialNumber string) (*model.FileSystemCheck, error) {
+	log.FromContext(ctx).Tracef(">>>>> CheckFileSystem called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< CheckFileSystem")
+
+	log.FromContext(ctx).Infof("Check File System, serialNumber=%v", serialNumber)
+
+	// Make sure the device is attached to this host
+	if _, err := driver.getSingleDeviceSummary(ctx, serialNumber); err != nil {
+		return nil, err
+	}
+
+	// Route request to the mount package, it knows which block device holds the file system
+	return mount.NewMounter().CheckFileSystem(ctx, serialNumber)
+}
+
+// RepairFileSystem repairs the file system on the device with the given serial number.  The file
+// system must not be mounted, and the volume must not be in use by other hosts.
+func (driver *ChapiServer) RepairFileSystem(ctx context.Context, serialNumber string) (*model.FileSystemCheck, error) {
+	log.FromContext(ctx).Tracef(">>>>> RepairFileSystem called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< RepairFileSystem")
+
+	log.FromContext(ctx).Infof("Repair File System, serialNumber=%v", serialNumber)
+
+	// Make sure the device is attached to this host
+	if _, err := driver.getSingleDeviceSummary(ctx, serialNumber); err != nil {
+		return nil, err
+	}
+
+	// A repair while another host has the volume attached would corrupt the file system further
+	if err := driver.checkDeviceNotShared(ctx, serialNumber); err != nil {
+		return nil, err
+	}
+
+	// Route request to the mount package, it knows which block device holds the file system
+	return mount.NewMounter().RepairFileSystem(ctx, serialNumber)
+}
+
 // CreateFileSystem writes the given file system to the device with the given serial number
-func (driver *ChapiServer) CreateFileSystem(serialNumber string, filesystem string) error {
-	log.Tracef(">>>>> CreateFileSystem called, serialNumber=%v, filesystem=%v", serialNumber, filesystem)
-	defer log.Trace("<<<<< CreateFileSystem")
+func (driver *ChapiServer) CreateFileSystem(ctx context.Context, serialNumber string, filesystem string) error {
+	log.FromContext(ctx).Tracef(">>>>> CreateFileSystem called, serialNumber=%v, filesystem=%v", serialNumber, filesystem)
+	defer log.FromContext(ctx).Trace("<<<<< CreateFileSystem")
 	multipathPlugin := multipath.NewMultipathPlugin()
 
-	log.Infof("Create File System, serialNumber=%v, filesystem=%v", serialNumber, filesystem)
+	log.FromContext(ctx).Infof("Create File System, serialNumber=%v, filesystem=%v", serialNumber, filesystem)
 
 	// Enumerate basic details for the serial number
-	device, err := driver.getSingleDeviceSummary(serialNumber)
+	device, err := driver.getSingleDeviceSummary(ctx, serialNumber)
 	if err != nil {
 		return err
 	}
 
 	// Format the device
-	driver.logDeviceDetails(device)
-	return multipathPlugin.CreateFileSystem(*device, filesystem)
+	driver.logDeviceDetails(ctx, device)
+	return multipathPlugin.CreateFileSystem(ctx, *device, filesystem)
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Encryption methods
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// GetEncryption reports the LUKS header details of the device with the given serial number and
+// whether its LUKS mapping is opened
+func (driver *ChapiServer) GetEncryption(ctx context.Context, serialNumber string) (*model.Encryption, error) {
+	log.FromContext(ctx).Tracef(">>>>> GetEncryption called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetEncryption")
+
+	// Enumerate basic details for the serial number
+	device, err := driver.getSingleDeviceSummary(ctx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.NewEncryptionPlugin().GetEncryption(*device)
+}
+
+// UpdateEncryption applies the encryption action (format, open, close, add_key, remove_key or
+// rotate_key) to the device with the given serial number.  The device must not be mounted to be
+// formatted or closed.
+func (driver *ChapiServer) UpdateEncryption(ctx context.Context, serialNumber string, request *model.EncryptionRequest) (*model.Encryption, error) {
+	log.FromContext(ctx).Tracef(">>>>> UpdateEncryption called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< UpdateEncryption")
+
+	log.FromContext(ctx).Infof("Update Encryption, serialNumber=%v, request=%v", serialNumber, log.Redacted(request))
+
+	// Enumerate basic details for the serial number
+	device, err := driver.getSingleDeviceSummary(ctx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail request if the file system on top of the LUKS mapping, or the device, is mounted
+	if request != nil && (request.Action == model.EncryptionActionFormat || request.Action == model.EncryptionActionClose) {
+		if mounts, _ := driver.GetMounts(ctx, serialNumber); len(mounts) > 0 {
+			err = cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageVolumeMounted)
+			log.FromContext(ctx).Error(err)
+			return nil, err
+		}
+	}
+	return encryption.NewEncryptionPlugin().UpdateEncryption(*device, request)
+}
+
+// GetEncryptionHeader returns a backup of the LUKS header of the device with the given serial number
+func (driver *ChapiServer) GetEncryptionHeader(ctx context.Context, serialNumber string) (*model.EncryptionHeader, error) {
+	log.FromContext(ctx).Tracef(">>>>> GetEncryptionHeader called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetEncryptionHeader")
+
+	log.FromContext(ctx).Infof("Backup Encryption Header, serialNumber=%v", serialNumber)
+
+	// Enumerate basic details for the serial number
+	device, err := driver.getSingleDeviceSummary(ctx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.NewEncryptionPlugin().BackupHeader(*device)
+}
+
+// RestoreEncryptionHeader writes the LUKS header backup to the device with the given serial number.
+// The LUKS mapping of the device must be closed.
+func (driver *ChapiServer) RestoreEncryptionHeader(ctx context.Context, serialNumber string, header *model.EncryptionHeader) (*model.Encryption, error) {
+	log.FromContext(ctx).Tracef(">>>>> RestoreEncryptionHeader called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< RestoreEncryptionHeader")
+
+	log.FromContext(ctx).Infof("Restore Encryption Header, serialNumber=%v", serialNumber)
+
+	// Enumerate basic details for the serial number
+	device, err := driver.getSingleDeviceSummary(ctx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.NewEncryptionPlugin().RestoreHeader(*device, header)
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Reservation methods
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// GetReservation reports the SCSI-3 persistent reservation state of the device with the given serial number
+func (driver *ChapiServer) GetReservation(ctx context.Context, serialNumber string) (*model.Reservation, error) {
+	log.FromContext(ctx).Tracef(">>>>> GetReservation called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetReservation")
+
+	// The reservation key of this host is derived from the host UUID
+	hostID, err := host.NewHostPlugin().GetUuid()
+	if err != nil {
+		return nil, cerrors.NewChapiError(err)
+	}
+	return reservation.NewReservationPlugin().GetReservation(serialNumber, hostID)
+}
+
+// UpdateReservation applies the persistent reservation action to all paths of the device with the given serial number
+func (driver *ChapiServer) UpdateReservation(ctx context.Context, serialNumber string, request *model.ReservationRequest) (*model.Reservation, error) {
+	log.FromContext(ctx).Tracef(">>>>> UpdateReservation called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< UpdateReservation")
+
+	log.FromContext(ctx).Infof("Update Reservation, serialNumber=%v, request=%+v", serialNumber, request)
+
+	// The reservation key of this host is derived from the host UUID
+	hostID, err := host.NewHostPlugin().GetUuid()
+	if err != nil {
+		return nil, cerrors.NewChapiError(err)
+	}
+	return reservation.NewReservationPlugin().UpdateReservation(serialNumber, hostID, request)
 }
 
 ///////////////////////////////////////////////////////////////////////////////////////////////////
@@ -404,15 +688,15 @@ func (driver *ChapiServer) CreateFileSystem(serialNumber string, filesystem stri
 ///////////////////////////////////////////////////////////////////////////////////////////////////
 
 // GetMounts reports all mounts on this host for the specified Nimble volume
-func (driver *ChapiServer) GetMounts(serialNumber string) ([]*model.Mount, error) {
-	log.Tracef(">>>>> GetMounts called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< GetMounts")
+func (driver *ChapiServer) GetMounts(ctx context.Context, serialNumber string) ([]*model.Mount, error) {
+	log.FromContext(ctx).Tracef(">>>>> GetMounts called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetMounts")
 
-	log.Infof("Get Mounts, serialNumber=%v", serialNumber)
+	log.FromContext(ctx).Infof("Get Mounts, serialNumber=%v", serialNumber)
 
 	// Route request to the mount package to get the mounts
 	mountPlugin := mount.NewMounter()
-	mounts, err := mountPlugin.GetMounts(serialNumber)
+	mounts, err := mountPlugin.GetMounts(ctx, serialNumber)
 	if err != nil {
 		return nil, err
 	}
@@ -422,20 +706,20 @@ func (driver *ChapiServer) GetMounts(serialNumber string) ([]*model.Mount, error
 		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoMountPointsFound)
 	}
 
-	driver.logMountArray(mounts)
+	driver.logMountArray(ctx, mounts)
 	return mounts, nil
 }
 
 // GetAllMountDetails enumerates the specified mount point ID
-func (driver *ChapiServer) GetAllMountDetails(serialNumber string, mountPointID string) ([]*model.Mount, error) {
-	log.Tracef(">>>>> GetAllMountDetails called, serialNumber=%v, mountPointID=%v", serialNumber, mountPointID)
-	defer log.Trace("<<<<< GetAllMountDetails")
+func (driver *ChapiServer) GetAllMountDetails(ctx context.Context, serialNumber string, mountPointID string) ([]*model.Mount, error) {
+	log.FromContext(ctx).Tracef(">>>>> GetAllMountDetails called, serialNumber=%v, mountPointID=%v", serialNumber, mountPointID)
+	defer log.FromContext(ctx).Trace("<<<<< GetAllMountDetails")
 
-	log.Infof("Get All Mount Details, serialNumber=%v, mountPointID=%v", serialNumber, mountPointID)
+	log.FromContext(ctx).Infof("Get All Mount Details, serialNumber=%v, mountPointID=%v", serialNumber, mountPointID)
 
 	// Route request to the mount package to get the mounts
 	mountPlugin := mount.NewMounter()
-	mounts, err := mountPlugin.GetAllMountDetails(serialNumber, mountPointID)
+	mounts, err := mountPlugin.GetAllMountDetails(ctx, serialNumber, mountPointID)
 	if err != nil {
 		return nil, err
 	}
@@ -445,56 +729,108 @@ func (driver *ChapiServer) GetAllMountDetails(serialNumber string, mountPointID
 		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoMountPointsFound)
 	}
 
-	driver.logMountArray(mounts)
+	driver.logMountArray(ctx, mounts)
 	return mounts, nil
 }
 
 // CreateMount mounts the given device to the given mount point
-func (driver *ChapiServer) CreateMount(serialNumber string, mountPoint string, fsOptions *model.FileSystemOptions) (*model.Mount, error) {
-	log.Tracef(">>>>> CreateMount called, serialNumber=%v, mountPoint=%v, fsOptions=%v", serialNumber, mountPoint, fsOptions)
-	defer log.Trace("<<<<< CreateMount")
+func (driver *ChapiServer) CreateMount(ctx context.Context, serialNumber string, mountPoint string, fsOptions *model.FileSystemOptions) (*model.Mount, error) {
+	log.FromContext(ctx).Tracef(">>>>> CreateMount called, serialNumber=%v, mountPoint=%v, fsOptions=%v", serialNumber, mountPoint, fsOptions)
+	defer log.FromContext(ctx).Trace("<<<<< CreateMount")
 
-	log.Infof("Create Mount, serialNumber=%v, mountPoint=%v", serialNumber, mountPoint)
+	log.FromContext(ctx).Infof("Create Mount, serialNumber=%v, mountPoint=%v", serialNumber, mountPoint)
 
 	// Route request to the mount package to create the mount point
 	mountPlugin := mount.NewMounter()
-	mount, err := mountPlugin.CreateMount(serialNumber, mountPoint, fsOptions)
+	mount, err := mountPlugin.CreateMount(ctx, serialNumber, mountPoint, fsOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	driver.logMount(mount)
+	driver.logMount(ctx, mount)
 	return mount, nil
 }
 
 // DeleteMount unmounts the given mount point, serialNumber can be optional in the body
-func (driver *ChapiServer) DeleteMount(serialNumber string, mountPointId string) error {
-	log.Tracef(">>>>> DeleteMount called, serialNumber=%v, mountPointID=%v", serialNumber, mountPointId)
-	defer log.Trace("<<<<< DeleteMount")
+func (driver *ChapiServer) DeleteMount(ctx context.Context, serialNumber string, mountPointId string) error {
+	log.FromContext(ctx).Tracef(">>>>> DeleteMount called, serialNumber=%v, mountPointID=%v", serialNumber, mountPointId)
+	defer log.FromContext(ctx).Trace("<<<<< DeleteMount")
 
-	log.Infof("Delete Mount, serialNumber=%v, mountPointId=%v", serialNumber, mountPointId)
+	log.FromContext(ctx).Infof("Delete Mount, serialNumber=%v, mountPointId=%v", serialNumber, mountPointId)
 
 	// Route request to the mount package to delete the mount point
 	mountPlugin := mount.NewMounter()
-	if err := mountPlugin.DeleteMount(serialNumber, mountPointId); err != nil {
+	if err := mountPlugin.DeleteMount(ctx, serialNumber, mountPointId); err != nil {
 		return err
 	}
 
 	// Success!!!
-	log.Infof("Mount Point ID %v successfully deleted", mountPointId)
+	log.FromContext(ctx).Infof("Mount Point ID %v successfully deleted", mountPointId)
 	return nil
 }
 
 // CreateBindMount creates the given bind mount
-func (driver *ChapiServer) CreateBindMount(sourceMount string, targetMount string, bindType string) (*model.Mount, error) {
-	log.Tracef(">>>>> CreateBindMount called, sourceMount=%s, targetMount=%s bindType=%s", sourceMount, targetMount, bindType)
-	defer log.Trace("<<<<< CreateBindMount")
+func (driver *ChapiServer) CreateBindMount(ctx context.Context, sourceMount string, targetMount string, bindType string) (*model.Mount, error) {
+	log.FromContext(ctx).Tracef(">>>>> CreateBindMount called, sourceMount=%s, targetMount=%s bindType=%s", sourceMount, targetMount, bindType)
+	defer log.FromContext(ctx).Trace("<<<<< CreateBindMount")
 
-	log.Infof("Create Bind Mount, sourceMount=%v, targetMount=%v, bindType=%v", sourceMount, targetMount, bindType)
+	log.FromContext(ctx).Infof("Create Bind Mount, sourceMount=%v, targetMount=%v, bindType=%v", sourceMount, targetMount, bindType)
 
 	return nil, cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
 }
 
+// GetBlockPublishes reports the device nodes of the given Nimble volume that are bind mounted onto
+// files (i.e. published as raw block volumes)
+func (driver *ChapiServer) GetBlockPublishes(ctx context.Context, serialNumber string) ([]*model.BlockPublish, error) {
+	log.FromContext(ctx).Tracef(">>>>> GetBlockPublishes called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetBlockPublishes")
+
+	// Route request to the mount package to enumerate the block publishes
+	publishes, err := mount.NewMounter().GetBlockPublishes(ctx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	for _, publish := range publishes {
+		log.FromContext(ctx).Infof("Block Publish, SerialNumber=%v, TargetPath=%v, DevicePath=%v", publish.SerialNumber, publish.TargetPath, publish.DevicePath)
+	}
+	return publishes, nil
+}
+
+// CreateBlockPublish bind mounts the device node of the given Nimble volume onto the target file
+func (driver *ChapiServer) CreateBlockPublish(ctx context.Context, serialNumber string, request *model.BlockPublishRequest) (*model.BlockPublish, error) {
+	log.FromContext(ctx).Tracef(">>>>> CreateBlockPublish called, serialNumber=%v, request=%+v", serialNumber, request)
+	defer log.FromContext(ctx).Trace("<<<<< CreateBlockPublish")
+
+	log.FromContext(ctx).Infof("Create Block Publish, serialNumber=%v, request=%+v", serialNumber, request)
+
+	// Route request to the mount package to publish the device node
+	publish, err := mount.NewMounter().CreateBlockPublish(ctx, serialNumber, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Success!!!
+	log.FromContext(ctx).Infof("Block Publish Created, SerialNumber=%v, TargetPath=%v, DevicePath=%v, Device=%v:%v", serialNumber, publish.TargetPath, publish.DevicePath, publish.Major, publish.Minor)
+	return publish, nil
+}
+
+// DeleteBlockPublish unmounts the device node of the given Nimble volume from the target file
+func (driver *ChapiServer) DeleteBlockPublish(ctx context.Context, serialNumber string, targetPath string) error {
+	log.FromContext(ctx).Tracef(">>>>> DeleteBlockPublish called, serialNumber=%v, targetPath=%v", serialNumber, targetPath)
+	defer log.FromContext(ctx).Trace("<<<<< DeleteBlockPublish")
+
+	log.FromContext(ctx).Infof("Delete Block Publish, serialNumber=%v, targetPath=%v", serialNumber, targetPath)
+
+	// Route request to the mount package to unpublish the device node
+	if err := mount.NewMounter().DeleteBlockPublish(ctx, serialNumber, targetPath); err != nil {
+		return err
+	}
+
+	// Success!!!
+	log.FromContext(ctx).Infof("Block Publish %v successfully deleted", targetPath)
+	return nil
+}
+
 ///////////////////////////////////////////////////////////////////////////////////////////////////
 // Internal helper methods
 ///////////////////////////////////////////////////////////////////////////////////////////////////
@@ -502,13 +838,13 @@ func (driver *ChapiServer) CreateBindMount(sourceMount string, targetMount strin
 // getSingleDeviceSummary uses the driver.GetDevices() endpoint to query basic summary details
 // about the given serial number.  If multiple volumes share that serial number (e.g. multipath
 // not configured properly), this routine will fail the request.
-func (driver *ChapiServer) getSingleDeviceSummary(serialNumber string) (*model.Device, error) {
-	log.Tracef(">>>>> getSingleDeviceSummary called, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< getSingleDeviceSummary")
+func (driver *ChapiServer) getSingleDeviceSummary(ctx context.Context, serialNumber string) (*model.Device, error) {
+	log.FromContext(ctx).Tracef(">>>>> getSingleDeviceSummary called, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< getSingleDeviceSummary")
 	multipathPlugin := multipath.NewMultipathPlugin()
 
 	// Enumerate the device details for the provided serial number
-	devices, err := multipathPlugin.GetDevices(serialNumber)
+	devices, err := multipathPlugin.GetDevices(ctx, serialNumber)
 	if err != nil {
 		return nil, cerrors.NewChapiError(err)
 	}
@@ -522,7 +858,7 @@ func (driver *ChapiServer) getSingleDeviceSummary(serialNumber string) (*model.D
 	// misconfigured (e.g. multipath misconfigured)
 	if len(devices) != 1 {
 		err = cerrors.NewChapiErrorf(cerrors.Internal, errorMessageMultipleDevices, len(devices))
-		log.Errorf(err.Error())
+		log.FromContext(ctx).Errorf(err.Error())
 		return nil, cerrors.NewChapiError(err)
 	}
 
@@ -530,49 +866,73 @@ func (driver *ChapiServer) getSingleDeviceSummary(serialNumber string) (*model.D
 	return devices[0], nil
 }
 
+// checkDeviceNotShared fails the request if the reservation key of another host is registered
+// with the device, which is the case when the volume is in use (or reserved) by that host.  Only
+// hosts that registered a persistent reservation key are detected, a host using the volume without
+// one is not.  A device without persistent reservation support cannot be shown not to be shared,
+// so the request fails as well.
+func (driver *ChapiServer) checkDeviceNotShared(ctx context.Context, serialNumber string) error {
+	reservation, err := driver.GetReservation(ctx, serialNumber)
+	if err != nil {
+		if chapiErr, ok := err.(*cerrors.ChapiError); ok && chapiErr.Code == cerrors.Unimplemented {
+			err = cerrors.NewChapiErrorf(cerrors.PermissionDenied, errorMessageVolumeInUseUnknown, chapiErr.Text)
+			log.FromContext(ctx).Error(err)
+		}
+		return err
+	}
+	for _, key := range reservation.Keys {
+		if key != reservation.HostKey {
+			err = cerrors.NewChapiErrorf(cerrors.PermissionDenied, errorMessageVolumeInUseByHost, key)
+			log.FromContext(ctx).Error(err)
+			return err
+		}
+	}
+	return nil
+}
+
 // logNetworks records the host NIC details, one line for NIC, to the information log
-func (driver *ChapiServer) logNetworks(networks []*model.Network) {
+func (driver *ChapiServer) logNetworks(ctx context.Context, networks []*model.Network) {
 	for _, network := range networks {
-		log.Infof("Network=%v, AddressV4=%v, MaskV4=%v, Up=%v", network.Name, network.AddressV4, network.MaskV4, network.Up)
+		log.FromContext(ctx).Infof("Network=%v, AddressV4=%v, MaskV4=%v, Up=%v", network.Name, network.AddressV4, network.MaskV4, network.Up)
 	}
 }
 
 // logDeviceArrayDetails records the device array details to the information log
-func (driver *ChapiServer) logDeviceArrayDetails(devices []*model.Device) {
+func (driver *ChapiServer) logDeviceArrayDetails(ctx context.Context, devices []*model.Device) {
 	for _, device := range devices {
-		driver.logDeviceDetails(device)
+		driver.logDeviceDetails(ctx, device)
 	}
 }
 
 // logDeviceDetails records the device details to the information log
-func (driver *ChapiServer) logDeviceDetails(device *model.Device) {
+func (driver *ChapiServer) logDeviceDetails(ctx context.Context, device *model.Device) {
 	if device == nil {
-		log.Error("logDeviceDetails called with nil device")
+		log.FromContext(ctx).Error("logDeviceDetails called with nil device")
 		return
 	}
 	msg := fmt.Sprintf("Device SerialNumber=%v, Pathname=%v, Size=%v, State=%v", device.SerialNumber, device.Pathname, device.Size, device.State)
 	if device.IscsiTarget != nil {
 		msg += fmt.Sprintf(", IscsiTargetName=%v, TargetScope=%v", device.IscsiTarget.Name, device.IscsiTarget.TargetScope)
 	}
-	log.Infoln(msg)
+	log.FromContext(ctx).Infoln(msg)
 }
 
 // logMountArray records the mount details to the information log
-func (driver *ChapiServer) logMountArray(mounts []*model.Mount) {
+func (driver *ChapiServer) logMountArray(ctx context.Context, mounts []*model.Mount) {
 	for _, mount := range mounts {
-		driver.logMount(mount)
+		driver.logMount(ctx, mount)
 	}
 }
 
 // logMount records the single mount details to the information log
-func (driver *ChapiServer) logMount(mount *model.Mount) {
+func (driver *ChapiServer) logMount(ctx context.Context, mount *model.Mount) {
 	if mount == nil {
-		log.Error("logMount called with nil mount")
+		log.FromContext(ctx).Error("logMount called with nil mount")
 		return
 	}
 	msg := fmt.Sprintf("Mount ID=%v", mount.ID)
 	if (mount.MountPoint != "") || (mount.SerialNumber != "") {
 		msg += fmt.Sprintf(", MountPoint=%v, SerialNumber=%v", mount.MountPoint, mount.SerialNumber)
 	}
-	log.Infoln(msg)
+	log.FromContext(ctx).Infoln(msg)
 }
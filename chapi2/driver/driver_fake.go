@@ -0,0 +1,669 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/encryption"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/chapi2/reservation"
+	"github.com/hpe-storage/common-host-libs/sgio"
+)
+
+const (
+	errorMessageDeviceNotFound    = "device not found"
+	errorMessageEncryptionFailed  = "no key slot matches the passphrase"
+	errorMessageEncryptionState   = "encryption action not allowed in the current state"
+	errorMessageInvalidTargetPath = "invalid target path"
+	errorMessageMountPointInUse   = "mount point in use"
+	errorMessageNoFileSystemFound = "no filesystem found on device"
+	errorMessageReservationFailed = "reservation conflict"
+)
+
+// FakeDriver implements the Driver interface with in-memory devices and mounts, it is intended for
+// testing consumers of CHAPI without a host to attach volumes to
+type FakeDriver struct {
+	lock         sync.Mutex
+	host         *model.Host
+	initiators   []*model.Initiator
+	networks     []*model.Network
+	devices      map[string]*model.Device // keyed by serial number
+	filesystems  map[string]string        // filesystem created on each device, keyed by serial number
+	mounts       map[string]*model.Mount  // keyed by mount ID
+	mountCount   int
+	reservations map[string]*fakeReservation    // keyed by serial number
+	encryption   map[string]*fakeEncryption     // keyed by serial number
+	publishes    map[string]*model.BlockPublish // keyed by target path
+}
+
+// fakeReservation is the persistent reservation state of a fake device
+type fakeReservation struct {
+	generation uint32
+	keys       []uint64
+	holder     uint64
+	prType     uint8
+}
+
+// fakeEncryption is the LUKS header state of a fake device
+type fakeEncryption struct {
+	keySlots map[int]string // passphrase of each key slot in use
+	opened   bool
+}
+
+// NewFakeDriver returns a FakeDriver for a single host with an iSCSI initiator and one network
+func NewFakeDriver() *FakeDriver {
+	return &FakeDriver{
+		host:         &model.Host{UUID: "fakehost1-uuid", Name: "host1", Domain: "host1.domain.com"},
+		initiators:   []*model.Initiator{{AccessProtocol: model.AccessProtocolIscsi, Init: []string{"iqn.1994-05.com.redhat:fakehost1"}}},
+		networks:     []*model.Network{{Name: "eth0", AddressV4: "10.0.0.1", MaskV4: "255.255.255.0", Up: true}},
+		devices:      make(map[string]*model.Device),
+		filesystems:  make(map[string]string),
+		mounts:       make(map[string]*model.Mount),
+		reservations: make(map[string]*fakeReservation),
+		encryption:   make(map[string]*fakeEncryption),
+		publishes:    make(map[string]*model.BlockPublish),
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Host methods
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// GetHostInfo returns host name, domain, and network interfaces
+func (driver *FakeDriver) GetHostInfo(ctx context.Context) (*model.Host, error) {
+	host := *driver.host
+	return &host, nil
+}
+
+// GetHostInitiators reports the initiators on this host
+func (driver *FakeDriver) GetHostInitiators(ctx context.Context) ([]*model.Initiator, error) {
+	return driver.initiators, nil
+}
+
+// GetHostNetworks reports the networks on this host
+func (driver *FakeDriver) GetHostNetworks(ctx context.Context) ([]*model.Network, error) {
+	return driver.networks, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Device methods
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// GetDevices enumerates the attached devices, or only the specified device if serialNumber is non-empty
+func (driver *FakeDriver) GetDevices(ctx context.Context, serialNumber string) ([]*model.Device, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	var devices []*model.Device
+	for serial, device := range driver.devices {
+		if serialNumber == "" || serialNumber == serial {
+			deviceCopy := *device
+			devices = append(devices, &deviceCopy)
+		}
+	}
+	if len(devices) == 0 {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesOnHost)
+	}
+	return devices, nil
+}
+
+// GetAllDeviceDetails enumerates the attached devices, or only the specified device if serialNumber is non-empty
+func (driver *FakeDriver) GetAllDeviceDetails(ctx context.Context, serialNumber string) ([]*model.Device, error) {
+	return driver.GetDevices(ctx, serialNumber)
+}
+
+// GetPartitionInfo reports the partitions on the provided device
+func (driver *FakeDriver) GetPartitionInfo(ctx context.Context, serialNumber string) ([]*model.DevicePartition, error) {
+	return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoPartitionsOnVolume)
+}
+
+// CreateDevice will attach device on this host based on the details provided
+func (driver *FakeDriver) CreateDevice(ctx context.Context, publishInfo model.PublishInfo) (*model.Device, error) {
+	if (publishInfo.BlockDev == nil) == (publishInfo.VirtualDev == nil) {
+		return nil, cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMultipleDeviceObjects)
+	}
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	device, ok := driver.devices[publishInfo.SerialNumber]
+	if !ok {
+		name := fmt.Sprintf("dm-%d", len(driver.devices))
+		device = &model.Device{
+			SerialNumber:    publishInfo.SerialNumber,
+			Pathname:        name,
+			AltFullPathName: "/dev/mapper/" + name,
+			State:           "active",
+		}
+		if publishInfo.BlockDev != nil && publishInfo.BlockDev.AccessProtocol == model.AccessProtocolIscsi {
+			device.IscsiTarget = &model.IscsiTarget{Name: publishInfo.BlockDev.TargetName, TargetScope: publishInfo.BlockDev.TargetScope}
+		}
+		driver.devices[publishInfo.SerialNumber] = device
+	}
+	if publishInfo.EncryptionKey != "" {
+		state, ok := driver.encryption[publishInfo.SerialNumber]
+		if !ok {
+			state = &fakeEncryption{keySlots: map[int]string{0: publishInfo.EncryptionKey}}
+			driver.encryption[publishInfo.SerialNumber] = state
+		}
+		if state.findKeySlot(publishInfo.EncryptionKey) < 0 {
+			return nil, cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageEncryptionFailed)
+		}
+		state.opened = true
+	}
+	deviceCopy := *device
+	return &deviceCopy, nil
+}
+
+// DeleteDevice will delete the given device from the host
+func (driver *FakeDriver) DeleteDevice(ctx context.Context, serialNumber string) error {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	if _, ok := driver.devices[serialNumber]; !ok {
+		return nil
+	}
+	if len(driver.getMounts(serialNumber)) != 0 {
+		return cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageVolumeMounted)
+	}
+	if len(driver.getBlockPublishes(serialNumber)) != 0 {
+		return cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageVolumePublished)
+	}
+	delete(driver.devices, serialNumber)
+	delete(driver.reservations, serialNumber)
+	if state, ok := driver.encryption[serialNumber]; ok {
+		state.opened = false
+	}
+	return nil
+}
+
+// OfflineDevice will offline the given device from the host
+func (driver *FakeDriver) OfflineDevice(ctx context.Context, serialNumber string) error {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	device, ok := driver.devices[serialNumber]
+	if !ok {
+		return cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesOnHost)
+	}
+	device.State = "offline"
+	return nil
+}
+
+// ExpandDevice picks up the new capacity of the given device, fake devices keep their size
+func (driver *FakeDriver) ExpandDevice(ctx context.Context, serialNumber string) (*model.Device, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	device, ok := driver.devices[serialNumber]
+	if !ok {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesOnHost)
+	}
+	deviceCopy := *device
+	return &deviceCopy, nil
+}
+
+// CheckFileSystem runs a read-only check of the file system on the given device, fake file systems are clean
+func (driver *FakeDriver) CheckFileSystem(ctx context.Context, serialNumber string) (*model.FileSystemCheck, error) {
+	return driver.checkFileSystem(serialNumber, false)
+}
+
+// RepairFileSystem repairs the file system on the given device, fake file systems are clean
+func (driver *FakeDriver) RepairFileSystem(ctx context.Context, serialNumber string) (*model.FileSystemCheck, error) {
+	return driver.checkFileSystem(serialNumber, true)
+}
+
+// checkFileSystem validates the file system check or repair request of the given device
+func (driver *FakeDriver) checkFileSystem(serialNumber string, repair bool) (*model.FileSystemCheck, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	device, ok := driver.devices[serialNumber]
+	if !ok {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesOnHost)
+	}
+	filesystem, ok := driver.filesystems[serialNumber]
+	if !ok {
+		return nil, cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageNoFileSystemFound)
+	}
+	if len(driver.getMounts(serialNumber)) != 0 {
+		return nil, cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageVolumeMounted)
+	}
+	if len(driver.getBlockPublishes(serialNumber)) != 0 {
+		return nil, cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageVolumePublished)
+	}
+	return &model.FileSystemCheck{SerialNumber: serialNumber, DevicePath: device.AltFullPathName, FsType: filesystem, Repair: repair}, nil
+}
+
+// CreateFileSystem writes the given file system to the device with the given serial number
+func (driver *FakeDriver) CreateFileSystem(ctx context.Context, serialNumber string, filesystem string) error {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	if _, ok := driver.devices[serialNumber]; !ok {
+		return cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesOnHost)
+	}
+	driver.filesystems[serialNumber] = filesystem
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Encryption methods
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// GetEncryption reports the LUKS header details of the device with the given serial number
+func (driver *FakeDriver) GetEncryption(ctx context.Context, serialNumber string) (*model.Encryption, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	if _, ok := driver.devices[serialNumber]; !ok {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesOnHost)
+	}
+	return driver.getEncryption(serialNumber), nil
+}
+
+// UpdateEncryption applies the encryption action to the device with the given serial number
+func (driver *FakeDriver) UpdateEncryption(ctx context.Context, serialNumber string, request *model.EncryptionRequest) (*model.Encryption, error) {
+	if err := encryption.ValidateRequest(request); err != nil {
+		return nil, err
+	}
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	if _, ok := driver.devices[serialNumber]; !ok {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesOnHost)
+	}
+	state, encrypted := driver.encryption[serialNumber]
+	if request.Action == model.EncryptionActionFormat {
+		if _, ok := driver.filesystems[serialNumber]; ok || encrypted {
+			return nil, cerrors.NewChapiError(cerrors.AlreadyExists, errorMessageEncryptionState)
+		}
+		driver.encryption[serialNumber] = &fakeEncryption{keySlots: map[int]string{0: request.Passphrase}}
+		return driver.getEncryption(serialNumber), nil
+	}
+	if !encrypted {
+		return nil, cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageEncryptionState)
+	}
+	if request.Action == model.EncryptionActionClose {
+		if len(driver.getMounts(serialNumber)) != 0 {
+			return nil, cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageVolumeMounted)
+		}
+		state.opened = false
+		return driver.getEncryption(serialNumber), nil
+	}
+	slot := state.findKeySlot(request.Passphrase)
+	if slot < 0 {
+		return nil, cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageEncryptionFailed)
+	}
+	switch request.Action {
+	case model.EncryptionActionOpen:
+		state.opened = true
+	case model.EncryptionActionAddKey:
+		newSlot := state.freeKeySlot()
+		if request.KeySlot != nil {
+			if _, ok := state.keySlots[*request.KeySlot]; ok {
+				return nil, cerrors.NewChapiError(cerrors.AlreadyExists, errorMessageEncryptionState)
+			}
+			newSlot = *request.KeySlot
+		}
+		state.keySlots[newSlot] = request.NewPassphrase
+	case model.EncryptionActionRemoveKey:
+		if request.KeySlot != nil {
+			slot = *request.KeySlot
+		}
+		if _, ok := state.keySlots[slot]; !ok || len(state.keySlots) <= 1 {
+			return nil, cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageEncryptionState)
+		}
+		delete(state.keySlots, slot)
+	case model.EncryptionActionRotateKey:
+		if request.KeySlot != nil && *request.KeySlot != slot {
+			return nil, cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageEncryptionFailed)
+		}
+		state.keySlots[slot] = request.NewPassphrase
+	}
+	return driver.getEncryption(serialNumber), nil
+}
+
+// GetEncryptionHeader returns a fake LUKS header backup of the device with the given serial number
+func (driver *FakeDriver) GetEncryptionHeader(ctx context.Context, serialNumber string) (*model.EncryptionHeader, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	if _, ok := driver.devices[serialNumber]; !ok {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesOnHost)
+	}
+	if _, ok := driver.encryption[serialNumber]; !ok {
+		return nil, cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageEncryptionState)
+	}
+	return &model.EncryptionHeader{SerialNumber: serialNumber, Header: []byte("LUKS\xba\xbe" + serialNumber)}, nil
+}
+
+// RestoreEncryptionHeader checks the device is encrypted and closed, fake headers are not restored
+func (driver *FakeDriver) RestoreEncryptionHeader(ctx context.Context, serialNumber string, header *model.EncryptionHeader) (*model.Encryption, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	if _, ok := driver.devices[serialNumber]; !ok {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesOnHost)
+	}
+	if header == nil || len(header.Header) == 0 {
+		return nil, cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageEncryptionState)
+	}
+	if state, ok := driver.encryption[serialNumber]; !ok || state.opened {
+		return nil, cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageEncryptionState)
+	}
+	return driver.getEncryption(serialNumber), nil
+}
+
+// getEncryption returns the encryption object of the serial number, lock must be held
+func (driver *FakeDriver) getEncryption(serialNumber string) *model.Encryption {
+	result := &model.Encryption{SerialNumber: serialNumber}
+	state, ok := driver.encryption[serialNumber]
+	if !ok {
+		return result
+	}
+	result.Encrypted, result.Version, result.Cipher, result.KeySize = true, 1, "aes-xts-plain64", 256
+	for slot := range state.keySlots {
+		result.KeySlots = append(result.KeySlots, slot)
+	}
+	sort.Ints(result.KeySlots)
+	if state.opened {
+		result.Opened = true
+		result.MappedPathName = "/dev/mapper/" + encryption.MappingPrefix + path.Base(driver.devices[serialNumber].AltFullPathName)
+	}
+	return result
+}
+
+// freeKeySlot returns the first key slot not in use
+func (state *fakeEncryption) freeKeySlot() int {
+	slot := 0
+	for state.keySlots[slot] != "" {
+		slot++
+	}
+	return slot
+}
+
+// findKeySlot returns the key slot of the passphrase, -1 if no key slot matches
+func (state *fakeEncryption) findKeySlot(passphrase string) int {
+	for slot, key := range state.keySlots {
+		if key == passphrase {
+			return slot
+		}
+	}
+	return -1
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Reservation methods
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// GetReservation reports the persistent reservation state of the device with the given serial number
+func (driver *FakeDriver) GetReservation(ctx context.Context, serialNumber string) (*model.Reservation, error) {
+	hostKey, err := reservation.KeyFromHostUUID(driver.host.UUID)
+	if err != nil {
+		return nil, err
+	}
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	if _, ok := driver.devices[serialNumber]; !ok {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageDeviceNotFound)
+	}
+	return driver.getReservation(serialNumber, hostKey), nil
+}
+
+// UpdateReservation applies the persistent reservation action with the key of the fake host
+func (driver *FakeDriver) UpdateReservation(ctx context.Context, serialNumber string, request *model.ReservationRequest) (*model.Reservation, error) {
+	prType, preemptKey, err := reservation.ValidateRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	hostKey, err := reservation.KeyFromHostUUID(driver.host.UUID)
+	if err != nil {
+		return nil, err
+	}
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	if _, ok := driver.devices[serialNumber]; !ok {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageDeviceNotFound)
+	}
+	state, ok := driver.reservations[serialNumber]
+	if !ok {
+		state = &fakeReservation{}
+		driver.reservations[serialNumber] = state
+	}
+	registered := state.hasKey(hostKey)
+	if !registered && request.Action != model.ReservationActionRegister && request.Action != model.ReservationActionUnregister {
+		return nil, cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageReservationFailed)
+	}
+	switch request.Action {
+	case model.ReservationActionRegister:
+		if !registered {
+			state.keys = append(state.keys, hostKey)
+		}
+		state.generation++
+	case model.ReservationActionUnregister:
+		if registered {
+			state.removeKey(hostKey)
+			state.generation++
+		}
+	case model.ReservationActionReserve:
+		if state.holder != 0 && (state.holder != hostKey || state.prType != prType) {
+			return nil, cerrors.NewChapiError(cerrors.PermissionDenied, errorMessageReservationFailed)
+		}
+		state.holder, state.prType = hostKey, prType
+	case model.ReservationActionRelease:
+		if state.holder == hostKey {
+			state.holder, state.prType = 0, 0
+		}
+	case model.ReservationActionPreempt:
+		state.removeKey(preemptKey)
+		if state.holder == preemptKey {
+			state.holder, state.prType = hostKey, prType
+		}
+		state.generation++
+	case model.ReservationActionClear:
+		state.keys, state.holder, state.prType = nil, 0, 0
+		state.generation++
+	}
+	return driver.getReservation(serialNumber, hostKey), nil
+}
+
+// getReservation returns the reservation object of the serial number, lock must be held
+func (driver *FakeDriver) getReservation(serialNumber string, hostKey uint64) *model.Reservation {
+	state, ok := driver.reservations[serialNumber]
+	if !ok {
+		state = &fakeReservation{}
+	}
+	keys := &sgio.PRKeys{Generation: state.generation, Keys: state.keys}
+	held := &sgio.PRReservation{Generation: state.generation, Reserved: state.holder != 0, Key: state.holder, Type: state.prType}
+	return reservation.NewReservation(serialNumber, hostKey, keys, held)
+}
+
+func (state *fakeReservation) hasKey(key uint64) bool {
+	for _, k := range state.keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// removeKey drops the registration of the key along with the reservation it holds
+func (state *fakeReservation) removeKey(key uint64) {
+	var keys []uint64
+	for _, k := range state.keys {
+		if k != key {
+			keys = append(keys, k)
+		}
+	}
+	state.keys = keys
+	if state.holder == key {
+		state.holder, state.prType = 0, 0
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Mount point methods
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// GetMounts reports all mounts on this host, or only the mounts of the specified serial number
+func (driver *FakeDriver) GetMounts(ctx context.Context, serialNumber string) ([]*model.Mount, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	mounts := driver.getMounts(serialNumber)
+	if len(mounts) == 0 {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoMountPointsFound)
+	}
+	return mounts, nil
+}
+
+// GetAllMountDetails enumerates the specified mount point ID
+func (driver *FakeDriver) GetAllMountDetails(ctx context.Context, serialNumber string, mountPointID string) ([]*model.Mount, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	var mounts []*model.Mount
+	for _, mount := range driver.getMounts(serialNumber) {
+		if mountPointID == "" || mount.ID == mountPointID {
+			mounts = append(mounts, mount)
+		}
+	}
+	if len(mounts) == 0 {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoMountPointsFound)
+	}
+	return mounts, nil
+}
+
+// CreateMount mounts the given device to the given mount point
+func (driver *FakeDriver) CreateMount(ctx context.Context, serialNumber string, mountPoint string, fsOptions *model.FileSystemOptions) (*model.Mount, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	if _, ok := driver.devices[serialNumber]; !ok {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageDeviceNotFound)
+	}
+	if _, ok := driver.filesystems[serialNumber]; !ok {
+		return nil, cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageNoFileSystemFound)
+	}
+	for _, mount := range driver.mounts {
+		if mount.MountPoint == mountPoint {
+			if mount.SerialNumber == serialNumber {
+				mountCopy := *mount
+				return &mountCopy, nil
+			}
+			return nil, cerrors.NewChapiError(cerrors.AlreadyExists, errorMessageMountPointInUse)
+		}
+	}
+	driver.mountCount++
+	mount := &model.Mount{
+		ID:           fmt.Sprintf("fakemount%d", driver.mountCount),
+		MountPoint:   mountPoint,
+		SerialNumber: serialNumber,
+	}
+	if fsOptions != nil {
+		fsOptionsCopy := *fsOptions
+		mount.FsOpts = &fsOptionsCopy
+	}
+	driver.mounts[mount.ID] = mount
+	mountCopy := *mount
+	return &mountCopy, nil
+}
+
+// DeleteMount unmounts the given mount point, serialNumber can be optional in the body
+func (driver *FakeDriver) DeleteMount(ctx context.Context, serialNumber string, mountPointID string) error {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	mount, ok := driver.mounts[mountPointID]
+	if !ok || (serialNumber != "" && mount.SerialNumber != serialNumber) {
+		return cerrors.NewChapiError(cerrors.NotFound, errorMessageNoMountPointsFound)
+	}
+	delete(driver.mounts, mountPointID)
+	return nil
+}
+
+// CreateBindMount creates the given bind mount
+func (driver *FakeDriver) CreateBindMount(ctx context.Context, sourceMount string, targetMount string, bindType string) (*model.Mount, error) {
+	return nil, cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// GetBlockPublishes reports the block publishes of the given device
+func (driver *FakeDriver) GetBlockPublishes(ctx context.Context, serialNumber string) ([]*model.BlockPublish, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	if _, ok := driver.devices[serialNumber]; !ok {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesOnHost)
+	}
+	return driver.getBlockPublishes(serialNumber), nil
+}
+
+// CreateBlockPublish publishes the device node of the given device at the target path
+func (driver *FakeDriver) CreateBlockPublish(ctx context.Context, serialNumber string, request *model.BlockPublishRequest) (*model.BlockPublish, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	device, ok := driver.devices[serialNumber]
+	if !ok {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesOnHost)
+	}
+	if request == nil || !path.IsAbs(request.TargetPath) {
+		return nil, cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageInvalidTargetPath)
+	}
+	targetPath := path.Clean(request.TargetPath)
+	if publish, ok := driver.publishes[targetPath]; ok {
+		if publish.SerialNumber != serialNumber {
+			return nil, cerrors.NewChapiError(cerrors.AlreadyExists, errorMessageMountPointInUse)
+		}
+		publishCopy := *publish
+		return &publishCopy, nil
+	}
+	for _, mount := range driver.mounts {
+		if mount.MountPoint == targetPath {
+			return nil, cerrors.NewChapiError(cerrors.AlreadyExists, errorMessageMountPointInUse)
+		}
+	}
+	var minor uint32
+	fmt.Sscanf(device.Pathname, "dm-%d", &minor)
+	publish := &model.BlockPublish{
+		SerialNumber: serialNumber,
+		TargetPath:   targetPath,
+		DevicePath:   device.AltFullPathName,
+		Major:        253,
+		Minor:        minor,
+		ReadOnly:     request.ReadOnly,
+	}
+	driver.publishes[targetPath] = publish
+	publishCopy := *publish
+	return &publishCopy, nil
+}
+
+// DeleteBlockPublish removes the block publish of the given device at the target path
+func (driver *FakeDriver) DeleteBlockPublish(ctx context.Context, serialNumber string, targetPath string) error {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	if _, ok := driver.devices[serialNumber]; !ok {
+		return cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesOnHost)
+	}
+	targetPath = path.Clean(targetPath)
+	if publish, ok := driver.publishes[targetPath]; ok {
+		if publish.SerialNumber != serialNumber {
+			return cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageInvalidTargetPath)
+		}
+		delete(driver.publishes, targetPath)
+	}
+	return nil
+}
+
+// getBlockPublishes returns copies of the block publishes of the serial number, lock must be held
+func (driver *FakeDriver) getBlockPublishes(serialNumber string) []*model.BlockPublish {
+	var publishes []*model.BlockPublish
+	for _, publish := range driver.publishes {
+		if publish.SerialNumber == serialNumber {
+			publishCopy := *publish
+			publishes = append(publishes, &publishCopy)
+		}
+	}
+	return publishes
+}
+
+// getMounts returns copies of the mounts of the serial number, or all mounts if it is empty, lock must be held
+func (driver *FakeDriver) getMounts(serialNumber string) []*model.Mount {
+	var mounts []*model.Mount
+	for _, mount := range driver.mounts {
+		if serialNumber == "" || mount.SerialNumber == serialNumber {
+			mountCopy := *mount
+			mounts = append(mounts, &mountCopy)
+		}
+	}
+	return mounts
+}
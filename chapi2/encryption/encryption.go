@@ -0,0 +1,203 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package encryption
+
+import (
+	"strings"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+const (
+	// Shared error messages
+	errorMessageDevicePathNotSet        = "device path not set for serial number %v"
+	errorMessageHeaderMissing           = "encryption header backup not provided"
+	errorMessageInvalidAction           = `invalid encryption action "%v"`
+	errorMessageInvalidKeySlot          = "invalid key slot %v"
+	errorMessageInvalidPassphrase       = "passphrase must not contain line breaks"
+	errorMessageNewPassphraseRequired   = "new passphrase required for the %v action"
+	errorMessageNotEncrypted            = "device %v is not encrypted"
+	errorMessagePassphraseRequired      = "passphrase required for the %v action"
+	errorMessageSerialNumberMissing     = "serial number not provided"
+	errorMessageUnexpectedNewPassphrase = "new passphrase not expected for the %v action"
+)
+
+// maxKeySlots is the number of key slots of a LUKS2 header, LUKS1 headers only have 8
+const maxKeySlots = 32
+
+// MappingPrefix is prepended to the multipath map name to name the LUKS mapping of a device
+// (e.g. "enc-mpathg"), it matches the mappings opened by the legacy linux package
+const MappingPrefix = "enc-"
+
+type EncryptionPlugin struct {
+}
+
+func NewEncryptionPlugin() *EncryptionPlugin {
+	return &EncryptionPlugin{}
+}
+
+// GetEncryption reports the LUKS header details of the device and whether its mapping is opened
+func (plugin *EncryptionPlugin) GetEncryption(device model.Device) (*model.Encryption, error) {
+	log.Tracef(">>>>> GetEncryption, serialNumber=%v", device.SerialNumber)
+	defer log.Trace("<<<<< GetEncryption")
+	if err := validateDevice(device); err != nil {
+		return nil, err
+	}
+	return plugin.getEncryption(device)
+}
+
+// UpdateEncryption applies the encryption action of the request to the device and reports the
+// resulting encryption state.  The passphrases of the request are only passed to cryptsetup through
+// its standard input.
+func (plugin *EncryptionPlugin) UpdateEncryption(device model.Device, request *model.EncryptionRequest) (*model.Encryption, error) {
+	log.Tracef(">>>>> UpdateEncryption, serialNumber=%v, request=%v", device.SerialNumber, log.Redacted(request))
+	defer log.Trace("<<<<< UpdateEncryption")
+	if err := validateDevice(device); err != nil {
+		return nil, err
+	}
+	if err := ValidateRequest(request); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Applying encryption action %v to %v", request.Action, device.SerialNumber)
+	var err error
+	switch request.Action {
+	case model.EncryptionActionFormat:
+		err = plugin.formatDevice(device, request.Passphrase)
+	case model.EncryptionActionOpen:
+		err = plugin.openDevice(device, request.Passphrase)
+	case model.EncryptionActionClose:
+		err = plugin.closeDevice(device)
+	case model.EncryptionActionAddKey:
+		err = plugin.addKey(device, request.Passphrase, request.NewPassphrase, request.KeySlot)
+	case model.EncryptionActionRemoveKey:
+		err = plugin.removeKey(device, request.Passphrase, request.KeySlot)
+	case model.EncryptionActionRotateKey:
+		err = plugin.rotateKey(device, request.Passphrase, request.NewPassphrase, request.KeySlot)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return plugin.getEncryption(device)
+}
+
+// PrepareDevice formats the device with the passphrase if it is not yet encrypted, and opens its
+// LUKS mapping.  It is used when a device is attached with an encryption key.
+func (plugin *EncryptionPlugin) PrepareDevice(device model.Device, passphrase string) (*model.Encryption, error) {
+	log.Tracef(">>>>> PrepareDevice, serialNumber=%v", device.SerialNumber)
+	defer log.Trace("<<<<< PrepareDevice")
+	if err := validateDevice(device); err != nil {
+		return nil, err
+	}
+	if err := validatePassphrase(passphrase, model.EncryptionActionOpen); err != nil {
+		return nil, err
+	}
+	encryption, err := plugin.getEncryption(device)
+	if err != nil {
+		return nil, err
+	}
+	if !encryption.Encrypted {
+		log.Infof("Device %v is not encrypted, formatting it", device.SerialNumber)
+		if err = plugin.formatDevice(device, passphrase); err != nil {
+			return nil, err
+		}
+	}
+	if err = plugin.openDevice(device, passphrase); err != nil {
+		return nil, err
+	}
+	return plugin.getEncryption(device)
+}
+
+// BackupHeader returns a backup of the LUKS header of the device
+func (plugin *EncryptionPlugin) BackupHeader(device model.Device) (*model.EncryptionHeader, error) {
+	log.Tracef(">>>>> BackupHeader, serialNumber=%v", device.SerialNumber)
+	defer log.Trace("<<<<< BackupHeader")
+	if err := validateDevice(device); err != nil {
+		return nil, err
+	}
+	header, err := plugin.backupHeader(device)
+	if err != nil {
+		return nil, err
+	}
+	return &model.EncryptionHeader{SerialNumber: device.SerialNumber, Header: header}, nil
+}
+
+// RestoreHeader replaces the LUKS header of the device with the given backup and reports the
+// resulting encryption state.  The LUKS mapping of the device must be closed.
+func (plugin *EncryptionPlugin) RestoreHeader(device model.Device, header *model.EncryptionHeader) (*model.Encryption, error) {
+	log.Tracef(">>>>> RestoreHeader, serialNumber=%v", device.SerialNumber)
+	defer log.Trace("<<<<< RestoreHeader")
+	if err := validateDevice(device); err != nil {
+		return nil, err
+	}
+	if header == nil || len(header.Header) == 0 {
+		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageHeaderMissing)
+		log.Error(err)
+		return nil, err
+	}
+	log.Infof("Restoring encryption header of %v", device.SerialNumber)
+	if err := plugin.restoreHeader(device, header.Header); err != nil {
+		return nil, err
+	}
+	return plugin.getEncryption(device)
+}
+
+// ValidateRequest checks that the request names a known action along with the passphrases it needs
+func ValidateRequest(request *model.EncryptionRequest) error {
+	if request == nil {
+		return cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageInvalidAction, "")
+	}
+	needsPassphrase, needsNewPassphrase := true, false
+	switch request.Action {
+	case model.EncryptionActionFormat, model.EncryptionActionOpen, model.EncryptionActionRemoveKey:
+	case model.EncryptionActionClose:
+		needsPassphrase = false
+	case model.EncryptionActionAddKey, model.EncryptionActionRotateKey:
+		needsNewPassphrase = true
+	default:
+		return cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageInvalidAction, request.Action)
+	}
+	if needsPassphrase {
+		if err := validatePassphrase(request.Passphrase, request.Action); err != nil {
+			return err
+		}
+	}
+	if needsNewPassphrase {
+		if request.NewPassphrase == "" {
+			return cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageNewPassphraseRequired, request.Action)
+		}
+		if strings.ContainsAny(request.NewPassphrase, "\r\n") {
+			return cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageInvalidPassphrase)
+		}
+	} else if request.NewPassphrase != "" {
+		return cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageUnexpectedNewPassphrase, request.Action)
+	}
+	if request.KeySlot != nil && (*request.KeySlot < 0 || *request.KeySlot >= maxKeySlots) {
+		return cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageInvalidKeySlot, *request.KeySlot)
+	}
+	return nil
+}
+
+// validatePassphrase checks the passphrase can be passed on a single line of the cryptsetup standard input
+func validatePassphrase(passphrase string, action string) error {
+	if passphrase == "" {
+		return cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessagePassphraseRequired, action)
+	}
+	if strings.ContainsAny(passphrase, "\r\n") {
+		return cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageInvalidPassphrase)
+	}
+	return nil
+}
+
+// validateDevice checks the device has the serial number and path name the plugin works with
+func validateDevice(device model.Device) error {
+	if device.SerialNumber == "" {
+		return cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageSerialNumberMissing)
+	}
+	if device.Pathname == "" || device.AltFullPathName == "" {
+		return cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageDevicePathNotSet, device.SerialNumber)
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package encryption
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/chapi2/sysfs"
+	"github.com/hpe-storage/common-host-libs/linux"
+	log "github.com/hpe-storage/common-host-libs/logger"
+	"github.com/hpe-storage/common-host-libs/util"
+)
+
+const (
+	cryptsetup     = "cryptsetup"
+	luksFormatType = "luks1" // LUKS1 headers can be opened by every host, as formatted by the linux package
+	headerFileName = "luks-header"
+
+	errorMessageCryptsetupFailed = "cryptsetup %v failed on %v, %v"
+	errorMessageDeviceEncrypted  = "device %v is already encrypted"
+	errorMessageDeviceOpened     = "encrypted device %v is opened as %v"
+	errorMessageFileSystemFound  = "device %v has a %v file system"
+	errorMessageLastKeySlot      = "cannot remove the last key slot of device %v"
+)
+
+var (
+	// LUKS1 "luksDump" fields
+	luks1CipherNameRegexp = regexp.MustCompile(`^Cipher name:\s*(\S+)`)
+	luks1CipherModeRegexp = regexp.MustCompile(`^Cipher mode:\s*(\S+)`)
+	luks1KeySizeRegexp    = regexp.MustCompile(`^MK bits:\s*(\d+)`)
+	luks1KeySlotRegexp    = regexp.MustCompile(`^Key Slot (\d+): ENABLED`)
+
+	// LUKS2 "luksDump" fields, the key slots and segments are listed below their section header
+	luks2SectionRegexp = regexp.MustCompile(`^(\S.*):\s*$`)
+	luks2EntryRegexp   = regexp.MustCompile(`^\s+(\d+): \S+`)
+	luks2CipherRegexp  = regexp.MustCompile(`^\s+cipher:\s*(\S+)`)
+	luks2KeySizeRegexp = regexp.MustCompile(`^\s+Key:\s*(\d+) bits`)
+
+	// Fields common to both versions
+	luksVersionRegexp = regexp.MustCompile(`^Version:\s*(\d+)`)
+	luksUUIDRegexp    = regexp.MustCompile(`^UUID:\s*(\S+)`)
+)
+
+// getEncryption reads the LUKS header of the device, if any, and looks for its opened mapping
+func (plugin *EncryptionPlugin) getEncryption(device model.Device) (*model.Encryption, error) {
+	encryption := &model.Encryption{SerialNumber: device.SerialNumber}
+	encrypted, err := isLuks(device.AltFullPathName)
+	if err != nil || !encrypted {
+		return encryption, err
+	}
+	out, _, err := util.ExecCommandOutput(cryptsetup, []string{"luksDump", device.AltFullPathName})
+	if err != nil {
+		err = cerrors.NewChapiErrorf(cerrors.Internal, errorMessageCryptsetupFailed, "luksDump", device.AltFullPathName, err)
+		log.Error(err)
+		return nil, err
+	}
+	encryption = parseLuksDump(out)
+	encryption.SerialNumber = device.SerialNumber
+	if mappingName := getCryptHolder(device.Pathname); mappingName != "" {
+		encryption.Opened = true
+		encryption.MappedPathName = sysfs.DevMapperPath + mappingName
+	}
+	return encryption, nil
+}
+
+// formatDevice writes a new LUKS header to a device without a file system or LUKS header
+func (plugin *EncryptionPlugin) formatDevice(device model.Device, passphrase string) error {
+	encrypted, err := isLuks(device.AltFullPathName)
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		err = cerrors.NewChapiErrorf(cerrors.AlreadyExists, errorMessageDeviceEncrypted, device.SerialNumber)
+		log.Error(err)
+		return err
+	}
+	fsType, err := linux.GetFilesystemType(device.AltFullPathName)
+	if err != nil {
+		return cerrors.NewChapiError(cerrors.Internal, err)
+	}
+	if fsType != "" {
+		err = cerrors.NewChapiErrorf(cerrors.AlreadyExists, errorMessageFileSystemFound, device.SerialNumber, fsType)
+		log.Error(err)
+		return err
+	}
+	return runCryptsetup("luksFormat", device.AltFullPathName, []string{"--type", luksFormatType, "--batch-mode", device.AltFullPathName}, passphrase)
+}
+
+// openDevice opens the LUKS mapping of the device, nothing is done if it is already opened
+func (plugin *EncryptionPlugin) openDevice(device model.Device, passphrase string) error {
+	if err := checkEncrypted(device); err != nil {
+		return err
+	}
+	if mappingName := getCryptHolder(device.Pathname); mappingName != "" {
+		log.Infof("Encrypted device %v already opened as %v", device.SerialNumber, mappingName)
+		return nil
+	}
+	return runCryptsetup("luksOpen", device.AltFullPathName, []string{device.AltFullPathName, getMappingName(device)}, passphrase)
+}
+
+// closeDevice closes the LUKS mapping of the device, nothing is done if it is not opened
+func (plugin *EncryptionPlugin) closeDevice(device model.Device) error {
+	mappingName := getCryptHolder(device.Pathname)
+	if mappingName == "" {
+		log.Infof("Encrypted device %v not opened", device.SerialNumber)
+		return nil
+	}
+	return runCryptsetup("luksClose", device.AltFullPathName, []string{mappingName})
+}
+
+// addKey adds a passphrase to a free key slot, or the requested one, unlocked with an existing passphrase
+func (plugin *EncryptionPlugin) addKey(device model.Device, passphrase string, newPassphrase string, keySlot *int) error {
+	if err := checkEncrypted(device); err != nil {
+		return err
+	}
+	return runCryptsetup("luksAddKey", device.AltFullPathName, appendKeySlot([]string{device.AltFullPathName}, keySlot), passphrase, newPassphrase)
+}
+
+// removeKey removes the key slot of the passphrase, or the requested key slot unlocked with the
+// passphrase of another slot.  The last key slot is never removed as the data would be lost.
+func (plugin *EncryptionPlugin) removeKey(device model.Device, passphrase string, keySlot *int) error {
+	encryption, err := plugin.getEncryption(device)
+	if err != nil {
+		return err
+	}
+	if !encryption.Encrypted {
+		return notEncryptedError(device)
+	}
+	if len(encryption.KeySlots) <= 1 {
+		err = cerrors.NewChapiErrorf(cerrors.PermissionDenied, errorMessageLastKeySlot, device.SerialNumber)
+		log.Error(err)
+		return err
+	}
+	if keySlot != nil {
+		return runCryptsetup("luksKillSlot", device.AltFullPathName, []string{device.AltFullPathName, strconv.Itoa(*keySlot)}, passphrase)
+	}
+	return runCryptsetup("luksRemoveKey", device.AltFullPathName, []string{device.AltFullPathName}, passphrase)
+}
+
+// rotateKey replaces an existing passphrase with a new one
+func (plugin *EncryptionPlugin) rotateKey(device model.Device, passphrase string, newPassphrase string, keySlot *int) error {
+	if err := checkEncrypted(device); err != nil {
+		return err
+	}
+	return runCryptsetup("luksChangeKey", device.AltFullPathName, appendKeySlot([]string{device.AltFullPathName}, keySlot), passphrase, newPassphrase)
+}
+
+// backupHeader reads a backup of the LUKS header, through a file in a private temporary directory
+// which is removed once read
+func (plugin *EncryptionPlugin) backupHeader(device model.Device) ([]byte, error) {
+	if err := checkEncrypted(device); err != nil {
+		return nil, err
+	}
+	directory, err := ioutil.TempDir("", "chapi-luks")
+	if err != nil {
+		return nil, cerrors.NewChapiError(cerrors.Internal, err)
+	}
+	defer os.RemoveAll(directory)
+
+	headerFile := filepath.Join(directory, headerFileName)
+	if err = runCryptsetup("luksHeaderBackup", device.AltFullPathName, []string{device.AltFullPathName, "--header-backup-file", headerFile}); err != nil {
+		return nil, err
+	}
+	header, err := ioutil.ReadFile(headerFile)
+	if err != nil {
+		return nil, cerrors.NewChapiError(cerrors.Internal, err)
+	}
+	return header, nil
+}
+
+// restoreHeader writes the LUKS header backup to the device, whose mapping must be closed
+func (plugin *EncryptionPlugin) restoreHeader(device model.Device, header []byte) error {
+	if mappingName := getCryptHolder(device.Pathname); mappingName != "" {
+		err := cerrors.NewChapiErrorf(cerrors.PermissionDenied, errorMessageDeviceOpened, device.SerialNumber, mappingName)
+		log.Error(err)
+		return err
+	}
+	directory, err := ioutil.TempDir("", "chapi-luks")
+	if err != nil {
+		return cerrors.NewChapiError(cerrors.Internal, err)
+	}
+	defer os.RemoveAll(directory)
+
+	headerFile := filepath.Join(directory, headerFileName)
+	if err = ioutil.WriteFile(headerFile, header, 0600); err != nil {
+		return cerrors.NewChapiError(cerrors.Internal, err)
+	}
+	return runCryptsetup("luksHeaderRestore", device.AltFullPathName, []string{"--batch-mode", device.AltFullPathName, "--header-backup-file", headerFile})
+}
+
+// runCryptsetup runs the cryptsetup action, the passphrases are passed one per line on its standard input
+func runCryptsetup(action string, devicePath string, args []string, passphrases ...string) error {
+	args = append([]string{action}, args...)
+	var err error
+	if len(passphrases) != 0 {
+		_, _, err = util.ExecCommandOutputWithStdinArgs(cryptsetup, args, passphrases)
+	} else {
+		_, _, err = util.ExecCommandOutput(cryptsetup, args)
+	}
+	if err != nil {
+		err = cerrors.NewChapiErrorf(cerrors.Internal, errorMessageCryptsetupFailed, action, devicePath, err)
+		log.Error(err)
+	}
+	return err
+}
+
+// isLuks returns true if the device has a LUKS header
+func isLuks(devicePath string) (bool, error) {
+	_, rc, err := util.ExecCommandOutput(cryptsetup, []string{"isLuks", devicePath})
+	switch rc {
+	case 0:
+		return true, nil
+	case 1:
+		return false, nil
+	}
+	err = cerrors.NewChapiErrorf(cerrors.Internal, errorMessageCryptsetupFailed, "isLuks", devicePath, err)
+	log.Error(err)
+	return false, err
+}
+
+// checkEncrypted fails the request if the device has no LUKS header
+func checkEncrypted(device model.Device) error {
+	encrypted, err := isLuks(device.AltFullPathName)
+	if err != nil {
+		return err
+	}
+	if !encrypted {
+		return notEncryptedError(device)
+	}
+	return nil
+}
+
+func notEncryptedError(device model.Device) error {
+	err := cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageNotEncrypted, device.SerialNumber)
+	log.Error(err)
+	return err
+}
+
+// appendKeySlot appends the key slot option when a key slot is requested
+func appendKeySlot(args []string, keySlot *int) []string {
+	if keySlot == nil {
+		return args
+	}
+	return append(args, "--key-slot", strconv.Itoa(*keySlot))
+}
+
+// getMappingName returns the LUKS mapping name of the device (e.g. "enc-mpathg")
+func getMappingName(device model.Device) string {
+	return MappingPrefix + filepath.Base(device.AltFullPathName)
+}
+
+// getCryptHolder returns the name of the dm-crypt mapping holding the dm device, if opened
+func getCryptHolder(dmName string) string {
+	if names := sysfs.GetHolderNames(dmName, sysfs.CryptUUIDPrefix); len(names) != 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// parseLuksDump extracts the header details from the "cryptsetup luksDump" output of a LUKS1 or
+// LUKS2 header
+func parseLuksDump(out string) *model.Encryption {
+	encryption := &model.Encryption{Encrypted: true}
+	var cipherName, cipherMode, section string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if match := luksVersionRegexp.FindStringSubmatch(line); match != nil {
+			encryption.Version, _ = strconv.Atoi(match[1])
+		} else if match = luksUUIDRegexp.FindStringSubmatch(line); match != nil {
+			encryption.UUID = match[1]
+		} else if match = luks1CipherNameRegexp.FindStringSubmatch(line); match != nil {
+			cipherName = match[1]
+		} else if match = luks1CipherModeRegexp.FindStringSubmatch(line); match != nil {
+			cipherMode = match[1]
+		} else if match = luks1KeySizeRegexp.FindStringSubmatch(line); match != nil {
+			encryption.KeySize, _ = strconv.Atoi(match[1])
+		} else if match = luks1KeySlotRegexp.FindStringSubmatch(line); match != nil {
+			slot, _ := strconv.Atoi(match[1])
+			encryption.KeySlots = append(encryption.KeySlots, slot)
+		} else if match = luks2SectionRegexp.FindStringSubmatch(line); match != nil {
+			section = match[1]
+		} else if match = luks2EntryRegexp.FindStringSubmatch(line); match != nil && section == "Keyslots" {
+			slot, _ := strconv.Atoi(match[1])
+			encryption.KeySlots = append(encryption.KeySlots, slot)
+		} else if match = luks2CipherRegexp.FindStringSubmatch(line); match != nil && section == "Data segments" && encryption.Cipher == "" {
+			encryption.Cipher = match[1]
+		} else if match = luks2KeySizeRegexp.FindStringSubmatch(line); match != nil && section == "Keyslots" && encryption.KeySize == 0 {
+			encryption.KeySize, _ = strconv.Atoi(match[1])
+		}
+	}
+	if cipherName != "" {
+		encryption.Cipher = cipherName
+		if cipherMode != "" {
+			encryption.Cipher += "-" + cipherMode
+		}
+	}
+	sort.Ints(encryption.KeySlots)
+	return encryption
+}
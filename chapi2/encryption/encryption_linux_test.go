@@ -0,0 +1,129 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package encryption
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/chapi2/sysfs/sysfstest"
+)
+
+const testLuks1Dump = `LUKS header information for /dev/mapper/mpathb
+
+Version:       	1
+Cipher name:   	aes
+Cipher mode:   	xts-plain64
+Hash spec:     	sha256
+Payload offset:	4096
+MK bits:       	256
+MK digest:     	1c 0a 5e 8f 2b 9d 77 31 46 0e 3a 5b 62 fd 0c 19 8a 24 4e 7b
+MK salt:       	43 11 f2 0b 9a 7c 6e 28 d5 5f 01 a3 c4 9b 8e 72
+               	5e 30 1d 46 aa 0f 93 e7 21 64 b8 5c 07 fd 3a 19
+MK iterations: 	71250
+UUID:          	5a8c7e2c-8f1d-4e4b-9d1c-4f7b2e3a6d90
+
+Key Slot 0: ENABLED
+	Iterations:         	1140000
+	Salt:               	9f 3e 21 a4 5c 0d 88 72 b1 4a 6e 93 0c d7 2f 58
+	Key material offset:	8
+	AF stripes:            	4000
+Key Slot 1: DISABLED
+Key Slot 2: ENABLED
+	Iterations:         	1152000
+	Key material offset:	520
+	AF stripes:            	4000
+Key Slot 3: DISABLED
+Key Slot 4: DISABLED
+Key Slot 5: DISABLED
+Key Slot 6: DISABLED
+Key Slot 7: DISABLED
+`
+
+const testLuks2Dump = `LUKS header information
+Version:       	2
+Epoch:         	5
+Metadata area: 	16384 [bytes]
+Keyslots area: 	16744448 [bytes]
+UUID:          	0b6f1e3a-2c4d-4f5e-8a9b-7c6d5e4f3a2b
+Label:         	(no label)
+Subsystem:     	(no subsystem)
+Flags:       	(no flags)
+
+Data segments:
+  0: crypt
+	offset: 16777216 [bytes]
+	length: (whole device)
+	cipher: aes-xts-plain64
+	sector: 512 [bytes]
+
+Keyslots:
+  1: luks2
+	Key:        512 bits
+	Priority:   normal
+	Cipher:     aes-xts-plain64
+	Cipher key: 512 bits
+	PBKDF:      argon2id
+	AF stripes: 4000
+	Area offset:290816 [bytes]
+  0: luks2
+	Key:        512 bits
+	Priority:   normal
+	Cipher:     aes-xts-plain64
+	Cipher key: 512 bits
+	PBKDF:      argon2id
+	AF stripes: 4000
+	Area offset:32768 [bytes]
+Tokens:
+Digests:
+  0: pbkdf2
+	Hash:       sha256
+	Iterations: 129774
+`
+
+func TestParseLuksDump(t *testing.T) {
+	expected := &model.Encryption{
+		Encrypted: true,
+		Version:   1,
+		UUID:      "5a8c7e2c-8f1d-4e4b-9d1c-4f7b2e3a6d90",
+		Cipher:    "aes-xts-plain64",
+		KeySize:   256,
+		KeySlots:  []int{0, 2},
+	}
+	if encryption := parseLuksDump(testLuks1Dump); !reflect.DeepEqual(encryption, expected) {
+		t.Errorf("unexpected LUKS1 header %+v", encryption)
+	}
+
+	expected = &model.Encryption{
+		Encrypted: true,
+		Version:   2,
+		UUID:      "0b6f1e3a-2c4d-4f5e-8a9b-7c6d5e4f3a2b",
+		Cipher:    "aes-xts-plain64",
+		KeySize:   512,
+		KeySlots:  []int{0, 1},
+	}
+	if encryption := parseLuksDump(testLuks2Dump); !reflect.DeepEqual(encryption, expected) {
+		t.Errorf("unexpected LUKS2 header %+v", encryption)
+	}
+}
+
+func TestGetCryptHolder(t *testing.T) {
+	root := t.TempDir()
+	sysfstest.AddDmDevice(t, root, "dm-3", "mpathb", "mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1", "dm-7")
+	sysfstest.AddDmDevice(t, root, "dm-4", "mpathc", "mpath-2aa3c1a4b2e8d9b06c9ce900d2a3c5e1", "dm-5")
+	sysfstest.AddDmDevice(t, root, "dm-5", "mpathc1", "part1-mpath-2aa3c1a4b2e8d9b06c9ce900d2a3c5e1")
+	sysfstest.AddDmDevice(t, root, "dm-7", "enc-mpathb", "CRYPT-LUKS1-5a8c7e2c8f1d4e4b9d1c4f7b2e3a6d90-enc-mpathb")
+	sysfstest.UseSysBlock(t, root)
+
+	if name := getCryptHolder("dm-3"); name != "enc-mpathb" {
+		t.Errorf("unexpected LUKS mapping %q", name)
+	}
+	// A partition is not a LUKS mapping
+	if name := getCryptHolder("dm-4"); name != "" {
+		t.Errorf("unexpected LUKS mapping %q", name)
+	}
+	if name := getMappingName(model.Device{AltFullPathName: "/dev/mapper/mpathb"}); name != "enc-mpathb" {
+		t.Errorf("unexpected mapping name %q", name)
+	}
+}
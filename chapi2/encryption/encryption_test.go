@@ -0,0 +1,59 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package encryption
+
+import (
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+)
+
+func TestValidateRequest(t *testing.T) {
+	slot, badSlot := 1, maxKeySlots
+	valid := []*model.EncryptionRequest{
+		{Action: model.EncryptionActionFormat, Passphrase: "secret"},
+		{Action: model.EncryptionActionOpen, Passphrase: "secret"},
+		{Action: model.EncryptionActionClose},
+		{Action: model.EncryptionActionAddKey, Passphrase: "secret", NewPassphrase: "other", KeySlot: &slot},
+		{Action: model.EncryptionActionRemoveKey, Passphrase: "secret", KeySlot: &slot},
+		{Action: model.EncryptionActionRotateKey, Passphrase: "secret", NewPassphrase: "other"},
+	}
+	for _, request := range valid {
+		if err := ValidateRequest(request); err != nil {
+			t.Errorf("unexpected error %v for %v", err, request.Action)
+		}
+	}
+
+	invalid := []*model.EncryptionRequest{
+		nil,
+		{Action: "wipe", Passphrase: "secret"},
+		{Action: model.EncryptionActionOpen},
+		{Action: model.EncryptionActionOpen, Passphrase: "secret\nother"},
+		{Action: model.EncryptionActionOpen, Passphrase: "secret", NewPassphrase: "other"},
+		{Action: model.EncryptionActionAddKey, Passphrase: "secret"},
+		{Action: model.EncryptionActionRotateKey, Passphrase: "secret", NewPassphrase: "other\r"},
+		{Action: model.EncryptionActionRemoveKey, Passphrase: "secret", KeySlot: &badSlot},
+	}
+	for _, request := range invalid {
+		err := ValidateRequest(request)
+		if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+			t.Errorf("unexpected error %v for %+v", err, request)
+		}
+	}
+}
+
+func TestValidateDevice(t *testing.T) {
+	plugin := NewEncryptionPlugin()
+	for _, device := range []model.Device{{}, {SerialNumber: "f6d3c1a4b2e8d9b06c9ce900d2a3c5e1"}} {
+		_, err := plugin.GetEncryption(device)
+		if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+			t.Errorf("unexpected error %v for %+v", err, device)
+		}
+	}
+	device := model.Device{SerialNumber: "f6d3c1a4b2e8d9b06c9ce900d2a3c5e1", Pathname: "dm-3", AltFullPathName: "/dev/mapper/mpathb"}
+	_, err := plugin.RestoreHeader(device, &model.EncryptionHeader{})
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+		t.Errorf("unexpected error %v", err)
+	}
+}
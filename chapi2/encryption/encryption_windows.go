@@ -0,0 +1,55 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package encryption
+
+import (
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+)
+
+const errorMessageNotYetImplemented = "device encryption not yet implemented on windows"
+
+// getEncryption is not yet implemented on Windows
+func (plugin *EncryptionPlugin) getEncryption(device model.Device) (*model.Encryption, error) {
+	return nil, cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// formatDevice is not yet implemented on Windows
+func (plugin *EncryptionPlugin) formatDevice(device model.Device, passphrase string) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// openDevice is not yet implemented on Windows
+func (plugin *EncryptionPlugin) openDevice(device model.Device, passphrase string) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// closeDevice is not yet implemented on Windows
+func (plugin *EncryptionPlugin) closeDevice(device model.Device) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// addKey is not yet implemented on Windows
+func (plugin *EncryptionPlugin) addKey(device model.Device, passphrase string, newPassphrase string, keySlot *int) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// removeKey is not yet implemented on Windows
+func (plugin *EncryptionPlugin) removeKey(device model.Device, passphrase string, keySlot *int) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// rotateKey is not yet implemented on Windows
+func (plugin *EncryptionPlugin) rotateKey(device model.Device, passphrase string, newPassphrase string, keySlot *int) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// backupHeader is not yet implemented on Windows
+func (plugin *EncryptionPlugin) backupHeader(device model.Device) ([]byte, error) {
+	return nil, cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// restoreHeader is not yet implemented on Windows
+func (plugin *EncryptionPlugin) restoreHeader(device model.Device, header []byte) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
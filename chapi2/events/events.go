@@ -0,0 +1,401 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package events
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+const (
+	// DefaultHistorySize is the number of events retained so that clients can resume a stream
+	DefaultHistorySize = 1024
+
+	// DefaultInterval is how often devices and mounts are reconciled without a udev trigger
+	DefaultInterval = 30 * time.Second
+
+	// settleDelay lets a burst of udev events (e.g. one per path of a new LUN) settle before the
+	// devices are enumerated again
+	settleDelay = 2 * time.Second
+
+	// subscriberBufferSize is the number of events queued for a subscriber before it is dropped
+	subscriberBufferSize = 64
+
+	// monitorOperation is the operation logged with the reconciliations of a Monitor
+	monitorOperation = "EventMonitor"
+)
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Broker
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Broker fans out events to subscribers and retains the most recent events so that a subscriber
+// can resume after the last event it received
+type Broker struct {
+	lock        sync.Mutex
+	lastID      uint64                 // ID of the most recently published event
+	history     []*model.Event         // Retained events, oldest first
+	historySize int                    // Maximum number of retained events
+	subscribers map[*Subscription]bool // Active subscribers
+}
+
+// Subscription receives the events published after it was created.  Events is closed if the
+// subscriber falls behind, the subscriber is then expected to resume from its last event ID.
+type Subscription struct {
+	Events <-chan *model.Event
+	events chan *model.Event
+	broker *Broker
+}
+
+// NewBroker returns a Broker retaining up to historySize events
+func NewBroker(historySize int) *Broker {
+	if historySize < 1 {
+		historySize = DefaultHistorySize
+	}
+	return &Broker{
+		// Event IDs start from the current time so that an ID handed out by a previous instance of
+		// the server is never mistaken for one of ours
+		lastID:      uint64(time.Now().UnixNano()),
+		historySize: historySize,
+		subscribers: make(map[*Subscription]bool),
+	}
+}
+
+// Publish assigns the next ID to the event and sends it to all subscribers
+func (broker *Broker) Publish(event *model.Event) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	broker.lastID++
+	event.ID = broker.lastID
+	if event.Timestamp == "" {
+		event.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	log.Debugf("publishing event %v, type=%v, serialNumber=%v", event.ID, event.Type, event.SerialNumber)
+
+	broker.history = append(broker.history, event)
+	if len(broker.history) > broker.historySize {
+		broker.history = broker.history[len(broker.history)-broker.historySize:]
+	}
+
+	for subscription := range broker.subscribers {
+		select {
+		case subscription.events <- event:
+		default:
+			// Don't let a slow subscriber hold up the others, it resumes from its last event
+			log.Warnf("event subscriber fell behind, dropping it at event %v", event.ID)
+			broker.unsubscribe(subscription)
+		}
+	}
+}
+
+// Subscribe returns a new Subscription along with the retained events to replay first.  If
+// lastEventID is nil only new events are received.  If events after lastEventID are no longer
+// retained, a single resync event is replayed instead.
+func (broker *Broker) Subscribe(lastEventID *uint64) (*Subscription, []*model.Event) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	events := make(chan *model.Event, subscriberBufferSize)
+	subscription := &Subscription{Events: events, events: events, broker: broker}
+	broker.subscribers[subscription] = true
+	if lastEventID == nil {
+		return subscription, nil
+	}
+	return subscription, broker.replay(*lastEventID)
+}
+
+// Close stops the subscription
+func (subscription *Subscription) Close() {
+	subscription.broker.lock.Lock()
+	defer subscription.broker.lock.Unlock()
+	subscription.broker.unsubscribe(subscription)
+}
+
+// LastEventID returns the ID of the most recently published event
+func (broker *Broker) LastEventID() uint64 {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+	return broker.lastID
+}
+
+// replay returns the retained events after lastEventID, broker lock must be held
+func (broker *Broker) replay(lastEventID uint64) []*model.Event {
+	if lastEventID == broker.lastID {
+		return nil
+	}
+	// The first retained event must directly follow lastEventID, otherwise events were missed
+	if lastEventID < broker.lastID && len(broker.history) > 0 && lastEventID+1 >= broker.history[0].ID {
+		index := sort.Search(len(broker.history), func(i int) bool { return broker.history[i].ID > lastEventID })
+		return append([]*model.Event(nil), broker.history[index:]...)
+	}
+	log.Infof("events after %v are no longer retained, requesting a resync", lastEventID)
+	return []*model.Event{{ID: broker.lastID, Type: model.EventTypeResync, Timestamp: time.Now().UTC().Format(time.RFC3339Nano)}}
+}
+
+// unsubscribe removes and closes the subscription, broker lock must be held
+func (broker *Broker) unsubscribe(subscription *Subscription) {
+	if broker.subscribers[subscription] {
+		delete(broker.subscribers, subscription)
+		close(subscription.events)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Monitor
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Source enumerates the devices and mounts that the Monitor reconciles (e.g. a chapi2 Driver)
+type Source interface {
+	GetAllDeviceDetails(ctx context.Context, serialNumber string) ([]*model.Device, error)
+	GetAllMountDetails(ctx context.Context, serialNumber, mountPointID string) ([]*model.Mount, error)
+}
+
+// Monitor periodically reconciles the devices and mounts of a Source, and whenever udev reports a
+// block device change, and publishes the differences to its Broker
+type Monitor struct {
+	lock     sync.Mutex
+	ctx      context.Context // Context of the Source calls, tagged with the monitor operation
+	broker   *Broker
+	source   Source
+	interval time.Duration
+	trigger  chan struct{}
+	stop     chan struct{}
+	running  bool
+
+	// Last reconciled state, nil until the first reconciliation
+	devices map[string]*deviceSnapshot
+	mounts  map[string]*model.Mount
+}
+
+// deviceSnapshot is the reconciled state of a single device
+type deviceSnapshot struct {
+	device *model.Device
+	paths  map[string]string // Path name to path state
+}
+
+// NewMonitor returns a Monitor of the given Source reconciling every interval
+func NewMonitor(source Source, interval time.Duration) *Monitor {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Monitor{
+		ctx:      log.NewContext(context.Background(), log.Fields{log.OperationKey: monitorOperation}),
+		broker:   NewBroker(DefaultHistorySize),
+		source:   source,
+		interval: interval,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Broker returns the Broker the Monitor publishes to
+func (monitor *Monitor) Broker() *Broker {
+	return monitor.broker
+}
+
+// Start takes the initial snapshot of devices and mounts and starts monitoring them
+func (monitor *Monitor) Start() {
+	log.Trace(">>>>> Start")
+	defer log.Trace("<<<<< Start")
+
+	monitor.lock.Lock()
+	defer monitor.lock.Unlock()
+	if monitor.running {
+		return
+	}
+	monitor.running = true
+	monitor.stop = make(chan struct{})
+
+	// Take the baseline before returning so that changes made right after Start are reported
+	monitor.reconcile()
+	go monitor.run(monitor.stop)
+	go watchDeviceChanges(monitor.Trigger, monitor.stop)
+}
+
+// Stop stops monitoring, subscribers remain open
+func (monitor *Monitor) Stop() {
+	log.Trace(">>>>> Stop")
+	defer log.Trace("<<<<< Stop")
+
+	monitor.lock.Lock()
+	defer monitor.lock.Unlock()
+	if monitor.running {
+		close(monitor.stop)
+		monitor.running = false
+	}
+}
+
+// Trigger requests a reconciliation ahead of the next interval
+func (monitor *Monitor) Trigger() {
+	select {
+	case monitor.trigger <- struct{}{}:
+	default:
+		// A reconciliation is already pending
+	}
+}
+
+// run reconciles on every interval and trigger until stop is closed
+func (monitor *Monitor) run(stop chan struct{}) {
+	ticker := time.NewTicker(monitor.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-monitor.trigger:
+			select {
+			case <-stop:
+				return
+			case <-time.After(settleDelay):
+			}
+			// Fold the triggers received while settling into this reconciliation
+			select {
+			case <-monitor.trigger:
+			default:
+			}
+		}
+		monitor.lock.Lock()
+		monitor.reconcile()
+		monitor.lock.Unlock()
+	}
+}
+
+// reconcile enumerates devices and mounts and publishes the changes since the last
+// reconciliation, monitor lock must be held
+func (monitor *Monitor) reconcile() {
+	log.FromContext(monitor.ctx).Trace(">>>>> reconcile")
+	defer log.FromContext(monitor.ctx).Trace("<<<<< reconcile")
+
+	// A failed enumeration keeps the previous state rather than reporting everything removed,
+	// while NotFound means there is nothing left to enumerate
+	if devices, err := monitor.source.GetAllDeviceDetails(monitor.ctx, ""); err != nil && !isNotFound(err) {
+		log.FromContext(monitor.ctx).Warnf("unable to enumerate devices, err=%v", err)
+	} else {
+		current := make(map[string]*deviceSnapshot)
+		for _, device := range devices {
+			current[device.SerialNumber] = &deviceSnapshot{device: device, paths: getPathStates(device)}
+		}
+		if monitor.devices != nil {
+			monitor.publish(diffDevices(monitor.devices, current))
+		}
+		monitor.devices = current
+	}
+
+	// Full details are needed for the serial number and mount point of the mount events
+	if mounts, err := monitor.source.GetAllMountDetails(monitor.ctx, "", ""); err != nil && !isNotFound(err) {
+		log.FromContext(monitor.ctx).Warnf("unable to enumerate mounts, err=%v", err)
+	} else {
+		current := make(map[string]*model.Mount)
+		for _, mount := range mounts {
+			current[mount.ID] = mount
+		}
+		if monitor.mounts != nil {
+			monitor.publish(diffMounts(monitor.mounts, current))
+		}
+		monitor.mounts = current
+	}
+}
+
+// isNotFound returns true if the Source reported that nothing was found
+func isNotFound(err error) bool {
+	chapiErr, ok := err.(*cerrors.ChapiError)
+	return ok && chapiErr.ErrorCode() == cerrors.NotFound
+}
+
+// publish sends the events to the Broker
+func (monitor *Monitor) publish(events []*model.Event) {
+	for _, event := range events {
+		monitor.broker.Publish(event)
+	}
+}
+
+// diffDevices returns the events that turn the previous devices into the current devices,
+// ordered by serial number
+func diffDevices(previous, current map[string]*deviceSnapshot) []*model.Event {
+	var events []*model.Event
+	for _, serialNumber := range sortedKeys(previous, current) {
+		before, after := previous[serialNumber], current[serialNumber]
+		switch {
+		case before == nil:
+			events = append(events, &model.Event{Type: model.EventTypeDeviceAdded, SerialNumber: serialNumber, Device: after.device})
+			continue
+		case after == nil:
+			events = append(events, &model.Event{Type: model.EventTypeDeviceRemoved, SerialNumber: serialNumber, Device: before.device})
+			continue
+		}
+
+		if before.device.Size != after.device.Size {
+			events = append(events, &model.Event{Type: model.EventTypeDeviceResized, SerialNumber: serialNumber, Device: after.device, PreviousSize: before.device.Size})
+		}
+
+		// Paths that were added or removed are reported with an empty previous or current state
+		var pathNames []string
+		for name := range before.paths {
+			pathNames = append(pathNames, name)
+		}
+		for name := range after.paths {
+			if _, ok := before.paths[name]; !ok {
+				pathNames = append(pathNames, name)
+			}
+		}
+		sort.Strings(pathNames)
+		for _, name := range pathNames {
+			if before.paths[name] != after.paths[name] {
+				path := &model.PathState{Name: name, State: after.paths[name], PreviousState: before.paths[name]}
+				events = append(events, &model.Event{Type: model.EventTypePathStateChanged, SerialNumber: serialNumber, Path: path})
+			}
+		}
+	}
+	return events
+}
+
+// diffMounts returns the events that turn the previous mounts into the current mounts, ordered by
+// mount ID
+func diffMounts(previous, current map[string]*model.Mount) []*model.Event {
+	ids := make(map[string]bool)
+	for id := range previous {
+		ids[id] = true
+	}
+	for id := range current {
+		ids[id] = true
+	}
+	var sorted []string
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+
+	var events []*model.Event
+	for _, id := range sorted {
+		before, after := previous[id], current[id]
+		switch {
+		case before == nil:
+			events = append(events, &model.Event{Type: model.EventTypeMountAdded, SerialNumber: after.SerialNumber, Mount: after})
+		case after == nil:
+			events = append(events, &model.Event{Type: model.EventTypeMountRemoved, SerialNumber: before.SerialNumber, Mount: before})
+		}
+	}
+	return events
+}
+
+// sortedKeys returns the sorted union of the serial numbers of both device maps
+func sortedKeys(previous, current map[string]*deviceSnapshot) []string {
+	var keys []string
+	for key := range previous {
+		keys = append(keys, key)
+	}
+	for key := range current {
+		if _, ok := previous[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
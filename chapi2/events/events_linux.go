@@ -0,0 +1,110 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package events
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	log "github.com/hpe-storage/common-host-libs/logger"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ueventBufferSize   = 64 * 1024 // Large enough for any kernel uevent
+	ueventKernelGroup  = 1         // Netlink multicast group of the kernel uevents
+	ueventRecvTimeout  = 1         // Seconds to wait for a uevent before checking for stop
+	ueventSubsystemKey = "SUBSYSTEM"
+	ueventActionKey    = "ACTION"
+)
+
+// watchDeviceChanges calls trigger for every block device uevent (e.g. a new SCSI disk, a
+// removed dm-multipath map, or a multipath path failure which udev reports as a change of the
+// map) until stop is closed.  If the netlink socket can't be opened, devices are only reconciled
+// periodically.
+func watchDeviceChanges(trigger func(), stop chan struct{}) {
+	log.Trace(">>>>> watchDeviceChanges")
+	defer log.Trace("<<<<< watchDeviceChanges")
+
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		log.Warnf("unable to open uevent socket, using periodic reconciliation, err=%v", err)
+		return
+	}
+	defer unix.Close(fd)
+	if err = unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: ueventKernelGroup}); err != nil {
+		log.Warnf("unable to bind uevent socket, using periodic reconciliation, err=%v", err)
+		return
+	}
+	// Wake up periodically to notice stop
+	if err = unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &unix.Timeval{Sec: ueventRecvTimeout}); err != nil {
+		log.Warnf("unable to set uevent socket timeout, using periodic reconciliation, err=%v", err)
+		return
+	}
+
+	buffer := make([]byte, ueventBufferSize)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		n, _, err := unix.Recvfrom(fd, buffer, 0)
+		if err != nil {
+			if err == unix.EAGAIN || err == unix.EINTR {
+				continue
+			}
+			// ENOBUFS means uevents were dropped, reconcile to catch up
+			if err == unix.ENOBUFS {
+				trigger()
+				continue
+			}
+			log.Warnf("unable to receive uevent, using periodic reconciliation, err=%v", err)
+			return
+		}
+		uevent := parseUevent(buffer[:n])
+		if isDeviceChange(uevent) {
+			log.Tracef("uevent %v %v", uevent[ueventActionKey], uevent["DEVPATH"])
+			trigger()
+		}
+	}
+}
+
+// parseUevent returns the properties of a kernel uevent (e.g. "add@/devices/...\0ACTION=add\0...")
+func parseUevent(message []byte) map[string]string {
+	uevent := make(map[string]string)
+	for index, field := range bytes.Split(message, []byte{0}) {
+		if index == 0 {
+			continue // "ACTION@DEVPATH" header
+		}
+		if keyValue := strings.SplitN(string(field), "=", 2); len(keyValue) == 2 {
+			uevent[keyValue[0]] = keyValue[1]
+		}
+	}
+	return uevent
+}
+
+// isDeviceChange returns true if the uevent adds, removes or changes a block device
+func isDeviceChange(uevent map[string]string) bool {
+	if uevent[ueventSubsystemKey] != "block" {
+		return false
+	}
+	switch uevent[ueventActionKey] {
+	case "add", "remove", "change":
+		return true
+	}
+	return false
+}
+
+// getPathStates returns the state of each path of a dm-multipath device
+func getPathStates(device *model.Device) map[string]string {
+	if device.Private == nil {
+		return nil
+	}
+	paths := make(map[string]string)
+	for _, path := range device.Private.Paths {
+		paths[path.Name] = path.State
+	}
+	return paths
+}
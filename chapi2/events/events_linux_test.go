@@ -0,0 +1,42 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package events
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+)
+
+func TestParseUevent(t *testing.T) {
+	message := "change@/devices/virtual/block/dm-3\x00ACTION=change\x00DEVPATH=/devices/virtual/block/dm-3\x00" +
+		"SUBSYSTEM=block\x00DM_ACTION=PATH_FAILED\x00DM_PATH=8:32\x00DEVNAME=dm-3\x00SEQNUM=4711\x00"
+	uevent := parseUevent([]byte(message))
+	if uevent["ACTION"] != "change" || uevent["DM_ACTION"] != "PATH_FAILED" || uevent["DEVNAME"] != "dm-3" || len(uevent) != 7 {
+		t.Errorf("unexpected uevent %v", uevent)
+	}
+	if !isDeviceChange(uevent) {
+		t.Errorf("path failure not detected as a device change")
+	}
+
+	for _, uevent := range []map[string]string{
+		{"ACTION": "add", "SUBSYSTEM": "net"},
+		{"ACTION": "bind", "SUBSYSTEM": "block"},
+		{},
+	} {
+		if isDeviceChange(uevent) {
+			t.Errorf("unexpected device change %v", uevent)
+		}
+	}
+}
+
+func TestGetPathStates(t *testing.T) {
+	device := &model.Device{Private: &model.DevicePrivate{Paths: []model.Path{{Name: "sdb", State: "active"}, {Name: "sdc", State: "failed"}}}}
+	if paths := getPathStates(device); !reflect.DeepEqual(paths, map[string]string{"sdb": "active", "sdc": "failed"}) {
+		t.Errorf("unexpected path states %v", paths)
+	}
+	if paths := getPathStates(&model.Device{}); paths != nil {
+		t.Errorf("unexpected path states %v", paths)
+	}
+}
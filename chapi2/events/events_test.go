@@ -0,0 +1,196 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package events
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+)
+
+func TestBrokerReplay(t *testing.T) {
+	broker := NewBroker(3)
+	first := broker.LastEventID()
+	subscription, replay := broker.Subscribe(nil)
+	defer subscription.Close()
+	if replay != nil {
+		t.Errorf("unexpected replay %v", replay)
+	}
+
+	for _, serialNumber := range []string{"a", "b", "c", "d"} {
+		broker.Publish(&model.Event{Type: model.EventTypeDeviceAdded, SerialNumber: serialNumber})
+	}
+	for _, serialNumber := range []string{"a", "b", "c", "d"} {
+		if event := <-subscription.Events; event.SerialNumber != serialNumber || event.Timestamp == "" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	}
+
+	// Resume within the retained events
+	lastEventID := first + 2
+	resumed, replay := broker.Subscribe(&lastEventID)
+	defer resumed.Close()
+	if len(replay) != 2 || replay[0].SerialNumber != "c" || replay[1].SerialNumber != "d" {
+		t.Errorf("unexpected replay %+v", replay)
+	}
+
+	// Resume with nothing missed
+	lastEventID = broker.LastEventID()
+	if _, replay = broker.Subscribe(&lastEventID); replay != nil {
+		t.Errorf("unexpected replay %+v", replay)
+	}
+
+	// Event "a" is no longer retained, and an unknown ID is from another server instance
+	for _, lastEventID := range []uint64{first, first + 100, 1} {
+		_, replay = broker.Subscribe(&lastEventID)
+		if len(replay) != 1 || replay[0].Type != model.EventTypeResync || replay[0].ID != broker.LastEventID() {
+			t.Errorf("unexpected replay %+v after %v", replay, lastEventID)
+		}
+	}
+}
+
+func TestBrokerSlowSubscriber(t *testing.T) {
+	broker := NewBroker(0)
+	subscription, _ := broker.Subscribe(nil)
+	for i := 0; i <= subscriberBufferSize; i++ {
+		broker.Publish(&model.Event{Type: model.EventTypeMountAdded})
+	}
+	count := 0
+	for range subscription.Events {
+		count++
+	}
+	if count != subscriberBufferSize {
+		t.Errorf("unexpected %v events before the subscriber was dropped", count)
+	}
+	// Closing a dropped subscription is harmless
+	subscription.Close()
+}
+
+func TestDiffDevices(t *testing.T) {
+	previous := map[string]*deviceSnapshot{
+		"a": {device: &model.Device{SerialNumber: "a", Size: 1024}, paths: map[string]string{"sdb": "active", "sdc": "active"}},
+		"b": {device: &model.Device{SerialNumber: "b", Size: 1024}},
+	}
+	current := map[string]*deviceSnapshot{
+		"a": {device: &model.Device{SerialNumber: "a", Size: 2048}, paths: map[string]string{"sdb": "failed", "sdd": "active"}},
+		"c": {device: &model.Device{SerialNumber: "c", Size: 1024}},
+	}
+	expected := []*model.Event{
+		{Type: model.EventTypeDeviceResized, SerialNumber: "a", Device: current["a"].device, PreviousSize: 1024},
+		{Type: model.EventTypePathStateChanged, SerialNumber: "a", Path: &model.PathState{Name: "sdb", State: "failed", PreviousState: "active"}},
+		{Type: model.EventTypePathStateChanged, SerialNumber: "a", Path: &model.PathState{Name: "sdc", PreviousState: "active"}},
+		{Type: model.EventTypePathStateChanged, SerialNumber: "a", Path: &model.PathState{Name: "sdd", State: "active"}},
+		{Type: model.EventTypeDeviceRemoved, SerialNumber: "b", Device: previous["b"].device},
+		{Type: model.EventTypeDeviceAdded, SerialNumber: "c", Device: current["c"].device},
+	}
+	if events := diffDevices(previous, current); !reflect.DeepEqual(events, expected) {
+		t.Errorf("unexpected events %+v", events)
+	}
+	if events := diffDevices(current, current); events != nil {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestDiffMounts(t *testing.T) {
+	mount1 := &model.Mount{ID: "1", SerialNumber: "a", MountPoint: "/mnt/a"}
+	mount2 := &model.Mount{ID: "2", SerialNumber: "b", MountPoint: "/mnt/b"}
+	expected := []*model.Event{
+		{Type: model.EventTypeMountRemoved, SerialNumber: "a", Mount: mount1},
+		{Type: model.EventTypeMountAdded, SerialNumber: "b", Mount: mount2},
+	}
+	if events := diffMounts(map[string]*model.Mount{"1": mount1}, map[string]*model.Mount{"2": mount2}); !reflect.DeepEqual(events, expected) {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+// testSource is a Source whose devices and mounts are set by the test.  Like the chapi2 driver, it
+// fails with NotFound when there are no devices or mounts.
+type testSource struct {
+	lock    sync.Mutex
+	devices []*model.Device
+	mounts  []*model.Mount
+}
+
+func (source *testSource) GetAllDeviceDetails(ctx context.Context, serialNumber string) ([]*model.Device, error) {
+	source.lock.Lock()
+	defer source.lock.Unlock()
+	if len(source.devices) == 0 {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, "no devices")
+	}
+	return source.devices, nil
+}
+
+func (source *testSource) GetAllMountDetails(ctx context.Context, serialNumber, mountPointID string) ([]*model.Mount, error) {
+	source.lock.Lock()
+	defer source.lock.Unlock()
+	if len(source.mounts) == 0 {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, "no mounts")
+	}
+	return source.mounts, nil
+}
+
+func TestMonitorReconcile(t *testing.T) {
+	source := &testSource{devices: []*model.Device{{SerialNumber: "a"}}}
+	monitor := NewMonitor(source, 0)
+	subscription, _ := monitor.Broker().Subscribe(nil)
+	defer subscription.Close()
+
+	// The initial state is the baseline and not reported
+	monitor.lock.Lock()
+	monitor.reconcile()
+	monitor.lock.Unlock()
+	select {
+	case event := <-subscription.Events:
+		t.Fatalf("unexpected event %+v", event)
+	default:
+	}
+
+	source.lock.Lock()
+	source.devices = append(source.devices, &model.Device{SerialNumber: "b"})
+	source.mounts = []*model.Mount{{ID: "1", SerialNumber: "b"}}
+	source.lock.Unlock()
+	monitor.lock.Lock()
+	monitor.reconcile()
+	monitor.lock.Unlock()
+	for _, eventType := range []string{model.EventTypeDeviceAdded, model.EventTypeMountAdded} {
+		if event := <-subscription.Events; event.Type != eventType || event.SerialNumber != "b" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	}
+}
+
+func TestMonitorReconcileLastRemoved(t *testing.T) {
+	mount := &model.Mount{ID: "1", SerialNumber: "a", MountPoint: "/mnt/a"}
+	source := &testSource{devices: []*model.Device{{SerialNumber: "a"}}, mounts: []*model.Mount{mount}}
+	monitor := NewMonitor(source, 0)
+	subscription, _ := monitor.Broker().Subscribe(nil)
+	defer subscription.Close()
+	monitor.lock.Lock()
+	monitor.reconcile()
+	monitor.lock.Unlock()
+
+	// Removing the last mount, and then the last device, must be reported
+	source.lock.Lock()
+	source.mounts = nil
+	source.lock.Unlock()
+	monitor.lock.Lock()
+	monitor.reconcile()
+	monitor.lock.Unlock()
+	if event := <-subscription.Events; event.Type != model.EventTypeMountRemoved || event.SerialNumber != "a" || event.Mount.MountPoint != "/mnt/a" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	source.lock.Lock()
+	source.devices = nil
+	source.lock.Unlock()
+	monitor.lock.Lock()
+	monitor.reconcile()
+	monitor.lock.Unlock()
+	if event := <-subscription.Events; event.Type != model.EventTypeDeviceRemoved || event.SerialNumber != "a" {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
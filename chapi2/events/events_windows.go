@@ -0,0 +1,18 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package events
+
+import (
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+// watchDeviceChanges is not yet implemented on Windows, devices are only reconciled periodically
+func watchDeviceChanges(trigger func(), stop chan struct{}) {
+	log.Info("device change notifications not yet implemented on windows, using periodic reconciliation")
+}
+
+// getPathStates is not yet implemented on Windows
+func getPathStates(device *model.Device) map[string]string {
+	return nil
+}
@@ -3,6 +3,7 @@
 package fc
 
 import (
+	"context"
 	"github.com/hpe-storage/common-host-libs/chapi2/model"
 	log "github.com/hpe-storage/common-host-libs/logger"
 )
@@ -68,8 +69,8 @@ func (plugin *FcPlugin) GetAllFcHostPortWwn() ([]string, error) {
 }
 
 // RescanFcTarget rescans host ports for new Fibre Channel devices
-func (plugin *FcPlugin) RescanFcTarget(lunID string) error {
-	log.Tracef(">>>>> RescanFcTarget called with lun id %s", lunID)
-	defer log.Trace("<<<<< RescanFcTarget")
-	return rescanFcTarget(lunID)
+func (plugin *FcPlugin) RescanFcTarget(ctx context.Context, lunID string) error {
+	log.FromContext(ctx).Tracef(">>>>> RescanFcTarget called with lun id %s", lunID)
+	defer log.FromContext(ctx).Trace("<<<<< RescanFcTarget")
+	return rescanFcTarget(ctx, lunID)
 }
@@ -3,6 +3,7 @@
 package fc
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -94,7 +95,7 @@ func getAllFcHostPortWWN() (portWWNs []string, err error) {
 }
 
 // fescanFcTarget rescans host ports for new Fibre Channel devices
-func rescanFcTarget(lunID string) (err error) {
+func rescanFcTarget(ctx context.Context, lunID string) (err error) {
 
 	// Get the list of FC hosts to rescan
 	fcHosts, err := getAllFcHostPorts()
@@ -112,7 +113,7 @@ func rescanFcTarget(lunID string) (err error) {
 			err = util.FileWriteString(fcHostScanPath, "- - "+lunID)
 		}
 		if err != nil {
-			log.Errorf("unable to rescan for fc devices on host port :%s lun: %s err %s", fcHost.HostNumber, lunID, err.Error())
+			log.FromContext(ctx).Errorf("unable to rescan for fc devices on host port :%s lun: %s err %s", fcHost.HostNumber, lunID, err.Error())
 			return err
 		}
 	}
@@ -3,6 +3,7 @@
 package fc
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/hpe-storage/common-host-libs/chapi2/model"
@@ -33,7 +34,7 @@ func getAllFcHostPorts() (hostPorts []*model.FcHostPort, err error) {
 }
 
 // rescanFcTarget rescans host ports for new Fibre Channel devices
-func rescanFcTarget(lunID string) (err error) {
+func rescanFcTarget(ctx context.Context, lunID string) (err error) {
 	// Unlike Linux, Windows does not have Target/LUN specific rescan capabilities so a synchronous
 	// disk rescan is initiated and the lunID is ignored.
 	return wmi.RescanDisks()
@@ -5,26 +5,45 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
 	chapiDriver "github.com/hpe-storage/common-host-libs/chapi2/driver"
+	"github.com/hpe-storage/common-host-libs/chapi2/events"
 	"github.com/hpe-storage/common-host-libs/chapi2/model"
 	log "github.com/hpe-storage/common-host-libs/logger"
 )
 
 var (
 	driver chapiDriver.Driver
+
+	// Device and mount event monitor, started by the first event stream
+	eventMonitor     *events.Monitor
+	eventMonitorLock sync.Mutex
+)
+
+const (
+	lastEventIDHeader      = "Last-Event-ID"
+	eventHeartbeatInterval = 15 * time.Second
 )
 
 const (
 	// Shared error messages
 	errorMessageEmptyFileSystem       = "empty filesystem type passed in the request"
+	errorMessageEmptyLogLevelRequest  = "empty log level request passed in the request"
 	errorMessageEmptyMountID          = "empty mount id passed in the request"
 	errorMessageEmptySerialNumber     = "empty serial number passed in the request"
 	errorMessageHTTPHeaderNotProvided = "http.Header not provided for authorization"
+	errorMessageInvalidLastEventID    = "invalid Last-Event-ID %v passed in the request"
+	errorMessageInvalidRevertAfter    = "invalid revert_after duration %v passed in the request"
 	errorMessageInvalidToken          = "invalid token: "
+	errorMessageStreamingNotSupported = "event streaming not supported by the connection"
 	errorMessageTokenNotSupplied      = "local access token not supplied"
 )
 
@@ -50,9 +69,10 @@ func GetHostInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var chapiResp Response
-	host, err := driver.GetHostInfo()
+	r = tagRequest(r, "GetHostInfo", nil)
+	host, err := driver.GetHostInfo(r.Context())
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 	chapiResp.Data = host
@@ -73,9 +93,10 @@ func GetHostNetworks(w http.ResponseWriter, r *http.Request) {
 	var chapiResp Response
 	var nics []*model.Network
 
-	nics, err := driver.GetHostNetworks()
+	r = tagRequest(r, "GetHostNetworks", nil)
+	nics, err := driver.GetHostNetworks(r.Context())
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 	chapiResp.Data = nics
@@ -96,9 +117,10 @@ func GetHostInitiators(w http.ResponseWriter, r *http.Request) {
 	var chapiResp Response
 	var inits []*model.Initiator
 
-	inits, err := driver.GetHostInitiators()
+	r = tagRequest(r, "GetHostInitiators", nil)
+	inits, err := driver.GetHostInitiators(r.Context())
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 	chapiResp.Data = inits
@@ -123,9 +145,10 @@ func GetDevices(w http.ResponseWriter, r *http.Request) {
 	if ok && len(keys[0]) > 0 {
 		serialNumber = keys[0]
 	}
-	devices, err := driver.GetDevices(serialNumber)
+	r = tagRequest(r, "GetDevices", log.Fields{log.SerialNumberKey: serialNumber})
+	devices, err := driver.GetDevices(r.Context(), serialNumber)
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 	chapiResp.Data = devices
@@ -150,9 +173,10 @@ func GetAllDeviceDetails(w http.ResponseWriter, r *http.Request) {
 	if ok && len(keys[0]) > 0 {
 		serialNumber = keys[0]
 	}
-	devices, err := driver.GetAllDeviceDetails(serialNumber)
+	r = tagRequest(r, "GetAllDeviceDetails", log.Fields{log.SerialNumberKey: serialNumber})
+	devices, err := driver.GetAllDeviceDetails(r.Context(), serialNumber)
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 	chapiResp.Data = devices
@@ -175,14 +199,16 @@ func GetPartitionsForDevice(w http.ResponseWriter, r *http.Request) {
 	serialNumber := vars["serialNumber"]
 
 	if serialNumber == "" {
-		handleError(w, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
 		return
 	}
 
+	r = tagRequest(r, "GetPartitionsForDevice", log.Fields{log.SerialNumberKey: serialNumber})
+
 	// Located the device. Now find all partitions
-	partitions, err := driver.GetPartitionInfo(serialNumber)
+	partitions, err := driver.GetPartitionInfo(r.Context(), serialNumber)
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 	chapiResp.Data = partitions
@@ -209,13 +235,14 @@ func CreateDevice(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusBadRequest)
+		handleError(w, r, chapiResp, err, http.StatusBadRequest)
 		return
 	}
 
-	devices, err := driver.CreateDevice(*publishInfo)
+	r = tagRequest(r, "CreateDevice", log.Fields{log.SerialNumberKey: publishInfo.SerialNumber})
+	devices, err := driver.CreateDevice(r.Context(), *publishInfo)
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 	chapiResp.Data = devices
@@ -239,13 +266,14 @@ func DeleteDevice(w http.ResponseWriter, r *http.Request) {
 	serialNumber := vars["serialNumber"]
 
 	if serialNumber == "" {
-		handleError(w, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
 		return
 	}
 
-	err := driver.DeleteDevice(serialNumber)
+	r = tagRequest(r, "DeleteDevice", log.Fields{log.SerialNumberKey: serialNumber})
+	err := driver.DeleteDevice(r.Context(), serialNumber)
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -269,13 +297,14 @@ func OfflineDevice(w http.ResponseWriter, r *http.Request) {
 	serialNumber := vars["serialNumber"]
 
 	if serialNumber == "" {
-		handleError(w, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
 		return
 	}
 
-	err := driver.OfflineDevice(serialNumber)
+	r = tagRequest(r, "OfflineDevice", log.Fields{log.SerialNumberKey: serialNumber})
+	err := driver.OfflineDevice(r.Context(), serialNumber)
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -284,6 +313,96 @@ func OfflineDevice(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+//@APIVersion 1.0.0
+//@Title ExpandDevice
+//@Description pick up the new capacity of the device serialnumber=serialnumber and grow its mounted file systems
+//@Accept json
+//@Resource /api/v1/devices/{serialNumber}
+//@Success 200 Device
+//@Router /api/v1/devices/{serialNumber}/actions/expand [put]
+func ExpandDevice(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	vars := mux.Vars(r)
+	serialNumber := vars["serialNumber"]
+
+	if serialNumber == "" {
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		return
+	}
+
+	r = tagRequest(r, "ExpandDevice", log.Fields{log.SerialNumberKey: serialNumber})
+	device, err := driver.ExpandDevice(r.Context(), serialNumber)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+	chapiResp.Data = device
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+//@APIVersion 1.0.0
+//@Title CheckFileSystem
+//@Description run a read-only check of the unmounted file system on the device serialnumber=serialnumber
+//@Accept json
+//@Resource /api/v1/devices/{serialNumber}
+//@Success 200 FileSystemCheck
+//@Router /api/v1/devices/{serialNumber}/actions/fscheck [put]
+func CheckFileSystem(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	vars := mux.Vars(r)
+	serialNumber := vars["serialNumber"]
+
+	if serialNumber == "" {
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		return
+	}
+
+	r = tagRequest(r, "CheckFileSystem", log.Fields{log.SerialNumberKey: serialNumber})
+	check, err := driver.CheckFileSystem(r.Context(), serialNumber)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+	chapiResp.Data = check
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+//@APIVersion 1.0.0
+//@Title RepairFileSystem
+//@Description repair the unmounted file system on the device serialnumber=serialnumber
+//@Accept json
+//@Resource /api/v1/devices/{serialNumber}
+//@Success 200 FileSystemCheck
+//@Router /api/v1/devices/{serialNumber}/actions/fsrepair [put]
+func RepairFileSystem(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	vars := mux.Vars(r)
+	serialNumber := vars["serialNumber"]
+
+	if serialNumber == "" {
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		return
+	}
+
+	r = tagRequest(r, "RepairFileSystem", log.Fields{log.SerialNumberKey: serialNumber})
+	check, err := driver.RepairFileSystem(r.Context(), serialNumber)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+	chapiResp.Data = check
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
 //@APIVersion 1.0.0
 //@Title CreateFileSystem on device
 //@Description create a filesysten on the device serialnumber=serialnumber
@@ -301,20 +420,228 @@ func CreateFileSystem(w http.ResponseWriter, r *http.Request) {
 	fileSystem := vars["fileSystem"]
 
 	if serialNumber == "" {
-		handleError(w, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
 		return
 	}
 
 	if fileSystem == "" {
-		handleError(w, chapiResp, errors.New(errorMessageEmptyFileSystem), http.StatusBadRequest)
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptyFileSystem), http.StatusBadRequest)
+		return
+	}
+
+	r = tagRequest(r, "CreateFileSystem", log.Fields{log.SerialNumberKey: serialNumber, "fileSystem": fileSystem})
+	err := driver.CreateFileSystem(r.Context(), serialNumber, fileSystem)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+//@APIVersion 1.0.0
+//@Title GetEncryption
+//@Description get the LUKS encryption state of the device serialnumber=serialnumber
+//@Accept json
+//@Resource /api/v1/devices/{serialNumber}/encryption
+//@Success 200 Encryption
+//@Router /api/v1/devices/{serialNumber}/encryption [get]
+func GetEncryption(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	vars := mux.Vars(r)
+	serialNumber := vars["serialNumber"]
+
+	if serialNumber == "" {
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		return
+	}
+
+	r = tagRequest(r, "GetEncryption", log.Fields{log.SerialNumberKey: serialNumber})
+	encryption, err := driver.GetEncryption(r.Context(), serialNumber)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+	chapiResp.Data = encryption
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+//@APIVersion 1.0.0
+//@Title UpdateEncryption
+//@Description apply an encryption action to the device serialnumber=serialnumber
+//@Accept json
+//@Resource /api/v1/devices/{serialNumber}/encryption
+//@Success 200 Encryption
+//@Router /api/v1/devices/{serialNumber}/encryption [put]
+func UpdateEncryption(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	vars := mux.Vars(r)
+	serialNumber := vars["serialNumber"]
+
+	if serialNumber == "" {
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		return
+	}
+
+	var request *model.EncryptionRequest
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+	defer r.Body.Close()
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusBadRequest)
+		return
+	}
+
+	r = tagRequest(r, "UpdateEncryption", log.Fields{log.SerialNumberKey: serialNumber})
+	encryption, err := driver.UpdateEncryption(r.Context(), serialNumber, request)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+	chapiResp.Data = encryption
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+//@APIVersion 1.0.0
+//@Title GetEncryptionHeader
+//@Description get a backup of the LUKS header of the device serialnumber=serialnumber
+//@Accept json
+//@Resource /api/v1/devices/{serialNumber}/encryption/header
+//@Success 200 EncryptionHeader
+//@Router /api/v1/devices/{serialNumber}/encryption/header [get]
+func GetEncryptionHeader(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	vars := mux.Vars(r)
+	serialNumber := vars["serialNumber"]
+
+	if serialNumber == "" {
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		return
+	}
+
+	r = tagRequest(r, "GetEncryptionHeader", log.Fields{log.SerialNumberKey: serialNumber})
+	header, err := driver.GetEncryptionHeader(r.Context(), serialNumber)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+	chapiResp.Data = header
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+//@APIVersion 1.0.0
+//@Title RestoreEncryptionHeader
+//@Description restore the LUKS header of the device serialnumber=serialnumber from a backup
+//@Accept json
+//@Resource /api/v1/devices/{serialNumber}/encryption/header
+//@Success 200 Encryption
+//@Router /api/v1/devices/{serialNumber}/encryption/header [put]
+func RestoreEncryptionHeader(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	vars := mux.Vars(r)
+	serialNumber := vars["serialNumber"]
+
+	if serialNumber == "" {
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		return
+	}
+
+	var request *model.EncryptionHeader
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+	defer r.Body.Close()
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusBadRequest)
+		return
+	}
+
+	r = tagRequest(r, "RestoreEncryptionHeader", log.Fields{log.SerialNumberKey: serialNumber})
+	encryption, err := driver.RestoreEncryptionHeader(r.Context(), serialNumber, request)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+	chapiResp.Data = encryption
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+//@APIVersion 1.0.0
+//@Title GetReservation
+//@Description get the persistent reservation state of the device serialnumber=serialnumber
+//@Accept json
+//@Resource /api/v1/devices/{serialNumber}/reservations
+//@Success 200 Reservation
+//@Router /api/v1/devices/{serialNumber}/reservations [get]
+func GetReservation(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	vars := mux.Vars(r)
+	serialNumber := vars["serialNumber"]
+
+	if serialNumber == "" {
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		return
+	}
+
+	r = tagRequest(r, "GetReservation", log.Fields{log.SerialNumberKey: serialNumber})
+	reservation, err := driver.GetReservation(r.Context(), serialNumber)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+	chapiResp.Data = reservation
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+//@APIVersion 1.0.0
+//@Title UpdateReservation
+//@Description apply a persistent reservation action to the device serialnumber=serialnumber
+//@Accept json
+//@Resource /api/v1/devices/{serialNumber}/reservations
+//@Success 200 Reservation
+//@Router /api/v1/devices/{serialNumber}/reservations [put]
+func UpdateReservation(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	vars := mux.Vars(r)
+	serialNumber := vars["serialNumber"]
+
+	if serialNumber == "" {
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
 		return
 	}
 
-	err := driver.CreateFileSystem(serialNumber, fileSystem)
+	var request *model.ReservationRequest
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+	defer r.Body.Close()
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusBadRequest)
 		return
 	}
+
+	r = tagRequest(r, "UpdateReservation", log.Fields{log.SerialNumberKey: serialNumber})
+	reservation, err := driver.UpdateReservation(r.Context(), serialNumber, request)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+	chapiResp.Data = reservation
 	json.NewEncoder(w).Encode(chapiResp)
 }
 
@@ -336,9 +663,10 @@ func GetMounts(w http.ResponseWriter, r *http.Request) {
 	if ok && len(keys[0]) > 0 {
 		serialNumber = keys[0]
 	}
-	mounts, err := driver.GetMounts(serialNumber)
+	r = tagRequest(r, "GetMounts", log.Fields{log.SerialNumberKey: serialNumber})
+	mounts, err := driver.GetMounts(r.Context(), serialNumber)
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 	chapiResp.Data = mounts
@@ -369,9 +697,10 @@ func GetAllMountDetails(w http.ResponseWriter, r *http.Request) {
 	if ok && len(keys[0]) > 0 {
 		mountId = keys[0]
 	}
-	mounts, err := driver.GetAllMountDetails(serialNumber, mountId)
+	r = tagRequest(r, "GetAllMountDetails", log.Fields{log.SerialNumberKey: serialNumber, log.MountIDKey: mountId})
+	mounts, err := driver.GetAllMountDetails(r.Context(), serialNumber, mountId)
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 	chapiResp.Data = mounts
@@ -397,18 +726,19 @@ func CreateMount(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusBadRequest)
+		handleError(w, r, chapiResp, err, http.StatusBadRequest)
 		return
 	}
 
 	if mount.SerialNumber == "" {
-		handleError(w, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
 		return
 	}
 
-	mnt, err := driver.CreateMount(mount.SerialNumber, mount.MountPoint, mount.FsOpts)
+	r = tagRequest(r, "CreateMount", log.Fields{log.SerialNumberKey: mount.SerialNumber, log.MountPointKey: mount.MountPoint})
+	mnt, err := driver.CreateMount(r.Context(), mount.SerialNumber, mount.MountPoint, mount.FsOpts)
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 	chapiResp.Data = mnt
@@ -431,7 +761,7 @@ func DeleteMount(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	mountId := vars["mountId"]
 	if mountId == "" {
-		handleError(w, chapiResp, errors.New(errorMessageEmptyMountID), http.StatusBadRequest)
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptyMountID), http.StatusBadRequest)
 		return
 	}
 
@@ -439,13 +769,14 @@ func DeleteMount(w http.ResponseWriter, r *http.Request) {
 	err := decoder.Decode(&serialNumber)
 	defer r.Body.Close()
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusBadRequest)
+		handleError(w, r, chapiResp, err, http.StatusBadRequest)
 		return
 	}
 
-	err = driver.DeleteMount(serialNumber, mountId)
+	r = tagRequest(r, "DeleteMount", log.Fields{log.SerialNumberKey: serialNumber, log.MountIDKey: mountId})
+	err = driver.DeleteMount(r.Context(), serialNumber, mountId)
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -453,13 +784,297 @@ func DeleteMount(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(chapiResp)
 }
 
+//@APIVersion 1.0.0
+//@Title GetBlockPublishes
+//@Description lists the block publishes of the device serialnumber=serialnumber
+//@Accept json
+//@Resource /api/v1/devices/{serialNumber}/publishes
+//@Success 200 {array} BlockPublish
+//@Router /api/v1/devices/{serialNumber}/publishes [get]
+func GetBlockPublishes(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	vars := mux.Vars(r)
+	serialNumber := vars["serialNumber"]
+
+	if serialNumber == "" {
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		return
+	}
+
+	r = tagRequest(r, "GetBlockPublishes", log.Fields{log.SerialNumberKey: serialNumber})
+	publishes, err := driver.GetBlockPublishes(r.Context(), serialNumber)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+	chapiResp.Data = publishes
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+//@APIVersion 1.0.0
+//@Title CreateBlockPublish
+//@Description bind mount the device node of the device serialnumber=serialnumber onto a target file
+//@Accept json
+//@Resource /api/v1/devices/{serialNumber}/publishes
+//@Success 200 BlockPublish
+//@Router /api/v1/devices/{serialNumber}/publishes [post]
+func CreateBlockPublish(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	vars := mux.Vars(r)
+	serialNumber := vars["serialNumber"]
+
+	if serialNumber == "" {
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		return
+	}
+
+	var request model.BlockPublishRequest
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+	defer r.Body.Close()
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusBadRequest)
+		return
+	}
+
+	r = tagRequest(r, "CreateBlockPublish", log.Fields{log.SerialNumberKey: serialNumber, log.MountPointKey: request.TargetPath})
+	publish, err := driver.CreateBlockPublish(r.Context(), serialNumber, &request)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+	chapiResp.Data = publish
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+//@APIVersion 1.0.0
+//@Title DeleteBlockPublish
+//@Description unmount the device node of the device serialnumber=serialnumber from a target file
+//@Accept json
+//@Resource /api/v1/devices/{serialNumber}/publishes
+//@Success 200 BlockPublish
+//@Router /api/v1/devices/{serialNumber}/publishes [delete]
+func DeleteBlockPublish(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	vars := mux.Vars(r)
+	serialNumber := vars["serialNumber"]
+
+	if serialNumber == "" {
+		handleError(w, r, chapiResp, errors.New(errorMessageEmptySerialNumber), http.StatusBadRequest)
+		return
+	}
+
+	var request model.BlockPublishRequest
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+	defer r.Body.Close()
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusBadRequest)
+		return
+	}
+
+	r = tagRequest(r, "DeleteBlockPublish", log.Fields{log.SerialNumberKey: serialNumber, log.MountPointKey: request.TargetPath})
+	err = driver.DeleteBlockPublish(r.Context(), serialNumber, request.TargetPath)
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
+		return
+	}
+
+	chapiResp.Data = &model.BlockPublish{}
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+//@APIVersion 1.0.0
+//@Title GetEvents
+//@Description stream device and mount change events as Server-Sent Events, resuming after the Last-Event-ID header
+//@Accept json
+//@Resource /api/v1/events
+//@Success 200 Event
+//@Router /api/v1/events [get]
+func GetEvents(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	r = tagRequest(r, "GetEvents", nil)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		handleError(w, r, chapiResp, errors.New(errorMessageStreamingNotSupported), http.StatusInternalServerError)
+		return
+	}
+
+	var lastEventID *uint64
+	if header := r.Header.Get(lastEventIDHeader); header != "" {
+		id, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			handleError(w, r, chapiResp, fmt.Errorf(errorMessageInvalidLastEventID, header), http.StatusBadRequest)
+			return
+		}
+		lastEventID = &id
+	}
+
+	subscription, replay := getEventMonitor().Broker().Subscribe(lastEventID)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range replay {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// Dropped for falling behind, the client resumes from the last event it received
+				log.FromContext(r.Context()).Warn("event subscriber dropped, closing the stream")
+				return
+			}
+			err = writeEvent(w, event)
+		case <-heartbeat.C:
+			// Comment line so that idle connections are kept open and broken ones detected
+			_, err = fmt.Fprint(w, ": keepalive\n\n")
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes the event in the text/event-stream format
+func writeEvent(w http.ResponseWriter, event *model.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// getEventMonitor returns the event monitor, started on first use
+func getEventMonitor() *events.Monitor {
+	eventMonitorLock.Lock()
+	defer eventMonitorLock.Unlock()
+	if eventMonitor == nil {
+		eventMonitor = events.NewMonitor(driver, events.DefaultInterval)
+		eventMonitor.Start()
+	}
+	return eventMonitor
+}
+
+//@APIVersion 1.0.0
+//@Title GetLogLevel
+//@Description retrieves the log levels of the CHAPI server
+//@Accept json
+//@Resource /api/v1/admin/loglevel
+//@Success 200 LogLevels
+//@Router /api/v1/admin/loglevel [get]
+func GetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+	chapiResp.Data = getLogLevels()
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+//@APIVersion 1.0.0
+//@Title UpdateLogLevel
+//@Description change the log level of the CHAPI server or of one of its packages, optionally reverting it after a while
+//@Accept json
+//@Resource /api/v1/admin/loglevel
+//@Success 200 LogLevels
+//@Router /api/v1/admin/loglevel [put]
+func UpdateLogLevel(w http.ResponseWriter, r *http.Request) {
+	if !validateRequestHeader(w, r) {
+		return
+	}
+	var chapiResp Response
+
+	var request *model.LogLevelRequest
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+	defer r.Body.Close()
+	if err == nil && request == nil {
+		err = errors.New(errorMessageEmptyLogLevelRequest)
+	}
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusBadRequest)
+		return
+	}
+	r = tagRequest(r, "UpdateLogLevel", nil)
+
+	var revertAfter time.Duration
+	if request.RevertAfter != "" {
+		if revertAfter, err = time.ParseDuration(request.RevertAfter); err != nil || revertAfter < 0 {
+			handleError(w, r, chapiResp, fmt.Errorf(errorMessageInvalidRevertAfter, request.RevertAfter), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if request.Package == "" {
+		err = log.SetLogLevel(request.Level, revertAfter)
+	} else {
+		err = log.SetPackageLogLevel(request.Package, request.Level, revertAfter)
+	}
+	if err != nil {
+		handleError(w, r, chapiResp, err, http.StatusBadRequest)
+		return
+	}
+	log.FromContext(r.Context()).Infof("log level changed, package=%v level=%v revertAfter=%v", request.Package, request.Level, request.RevertAfter)
+
+	chapiResp.Data = getLogLevels()
+	json.NewEncoder(w).Encode(chapiResp)
+}
+
+// getLogLevels returns the log levels in effect
+func getLogLevels() *model.LogLevels {
+	settings := log.GetLevelSettings()
+	revertAt := func(key string) string {
+		if at, ok := settings.Reverts[key]; ok {
+			return at.UTC().Format(time.RFC3339)
+		}
+		return ""
+	}
+
+	levels := &model.LogLevels{Level: settings.Level.String(), RevertAt: revertAt("")}
+	for pkg, level := range settings.Packages {
+		levels.Packages = append(levels.Packages, &model.LogLevel{Package: pkg, Level: level.String(), RevertAt: revertAt(pkg)})
+	}
+	sort.Slice(levels.Packages, func(i, j int) bool {
+		return levels.Packages[i].Package < levels.Packages[j].Package
+	})
+	return levels
+}
+
 // standard method for handling requests
 func handleRequest(function func() (interface{}, error), functionName string, w http.ResponseWriter, r *http.Request) {
 	var chapiResp Response
+	r = tagRequest(r, functionName, nil)
 
 	data, err := function()
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -467,8 +1082,25 @@ func handleRequest(function func() (interface{}, error), functionName string, w
 	json.NewEncoder(w).Encode(chapiResp)
 }
 
-func handleError(w http.ResponseWriter, chapiResp Response, err error, statusCode int) {
-	log.Error("Err :", err.Error())
+// tagRequest adds the operation and fields to the log context of the request, so every line logged
+// for the request with log.FromContext, including the HTTPLogger summary, can be filtered by them.
+// Empty values, such as an optional serial number query parameter that is absent, are left out.
+func tagRequest(r *http.Request, operation string, fields log.Fields) *http.Request {
+	tags := log.Fields{log.OperationKey: operation}
+	for key, value := range fields {
+		if value != "" {
+			tags[key] = value
+		}
+	}
+	if !log.AddContextFields(r.Context(), tags) {
+		// Not served through HTTPLogger, give the request a log context of its own
+		r = r.WithContext(log.NewContext(r.Context(), tags))
+	}
+	return r
+}
+
+func handleError(w http.ResponseWriter, r *http.Request, chapiResp Response, err error, statusCode int) {
+	log.FromContext(r.Context()).Error("Err :", err.Error())
 	w.WriteHeader(statusCode)
 	chapiResp.Err = cerrors.NewChapiError(err)
 	json.NewEncoder(w).Encode(chapiResp)
@@ -24,7 +24,7 @@ func GetHostRecommendations(w http.ResponseWriter, r *http.Request) {
 
 	settings, err := tunelinux.GetRecommendations()
 	if err != nil {
-		handleError(w, chapiResp, err, http.StatusInternalServerError)
+		handleError(w, r, chapiResp, err, http.StatusInternalServerError)
 		return
 	}
 	chapiResp.Data = settings
@@ -65,8 +65,9 @@ func GetChapInfo(w http.ResponseWriter, r *http.Request) {
 	handleRequest(function, "getChapInfo", w, r)
 }
 
-// CHAPI for Linux does not need to validate the request header.  See handler_windows.go for the
-// checks CHAPI for Windows needs to perform.
+// CHAPI for Linux does not need to validate the request header, the chapid socket is protected by its file
+// permissions and the TLS listener requires client certificates.  See handler_windows.go for the checks CHAPI for
+// Windows needs to perform.
 func validateRequestHeader(w http.ResponseWriter, r *http.Request) bool {
 	return true
 }
@@ -121,7 +121,7 @@ func validateRequestHeader(w http.ResponseWriter, r *http.Request) bool {
 	// If token authentication failed, return error to caller
 	if !status {
 		var chapiResp Response
-		handleError(w, chapiResp, err, http.StatusUnauthorized)
+		handleError(w, r, chapiResp, err, http.StatusUnauthorized)
 	}
 
 	// Return true if header is valid, else false
@@ -3,6 +3,9 @@
 package iscsi
 
 import (
+	"context"
+	"time"
+
 	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
 	"github.com/hpe-storage/common-host-libs/chapi2/model"
 	log "github.com/hpe-storage/common-host-libs/logger"
@@ -72,32 +75,32 @@ func (plugin *IscsiPlugin) DiscoverTargets(portal string) ([]*model.IscsiTarget,
 }
 
 // LoginTarget ensures that the provided iSCSI device is logged into this host
-func (plugin *IscsiPlugin) LoginTarget(blockDev model.BlockDeviceAccessInfo) (err error) {
-	log.Tracef(">>>>> LoginTarget, TargetName=%v", blockDev.TargetName)
-	defer log.Traceln("<<<<< LoginTarget")
+func (plugin *IscsiPlugin) LoginTarget(ctx context.Context, blockDev model.BlockDeviceAccessInfo) (err error) {
+	log.FromContext(ctx).Tracef(">>>>> LoginTarget, TargetName=%v", blockDev.TargetName)
+	defer log.FromContext(ctx).Traceln("<<<<< LoginTarget")
 
 	// If the iSCSI iqn is not provided, fail the request
 	if blockDev.TargetName == "" {
 		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMissingIscsiTargetName)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return err
 	}
 
 	// If the IscsiAccessInfo object is not provided, fail the request
 	if blockDev.IscsiAccessInfo == nil {
 		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMissingIscsiAccessInfo)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return err
 	}
 
 	// Use the platform specific routine to login to the iSCSI target
-	err = plugin.loginTarget(blockDev)
+	err = plugin.loginTarget(ctx, blockDev)
 
 	// If there was an error logging into the iSCSI target, but connections remain, clean up
 	// after ourselves by logging out the target.
 	if err != nil {
 		if loggedIn, _ := plugin.IsTargetLoggedIn(blockDev.TargetName); loggedIn == true {
-			plugin.LogoutTarget(blockDev.TargetName)
+			plugin.LogoutTarget(ctx, blockDev.TargetName)
 		}
 		return err
 	}
@@ -116,12 +119,12 @@ func (plugin *IscsiPlugin) IsTargetLoggedIn(targetName string) (bool, error) {
 }
 
 // LogoutTarget logs out the given iSCSI target
-func (plugin *IscsiPlugin) LogoutTarget(targetName string) error {
-	log.Tracef(">>>>> LogoutTarget, TargetName=%v", targetName)
-	defer log.Traceln("<<<<< LogoutTarget")
+func (plugin *IscsiPlugin) LogoutTarget(ctx context.Context, targetName string) error {
+	log.FromContext(ctx).Tracef(">>>>> LogoutTarget, TargetName=%v", targetName)
+	defer log.FromContext(ctx).Traceln("<<<<< LogoutTarget")
 
 	// Call platform specific module
-	return plugin.logoutTarget(targetName)
+	return plugin.logoutTarget(ctx, targetName)
 }
 
 // GetIscsiInitiators returns the host's iSCSI initiator object
@@ -140,3 +143,113 @@ func (plugin *IscsiPlugin) RescanIscsiTarget(lunID string) error {
 	defer log.Traceln("<<<<< RescanIscsiTarget")
 	return rescanIscsiTarget(lunID)
 }
+
+// connectTypeToArray takes the connectType string and returns an array of connection types that
+// reflect the input type.
+func (plugin *IscsiPlugin) connectTypeToArray(connectType string) (connectTypes []string, err error) {
+
+	// Determine how we should try to connect to the iSCSI target using the provided iSCSI
+	// ConnectType.  If property not provided, use the default value.
+	switch connectType {
+	case "", model.ConnectTypeDefault:
+		// If the default option is selected, we try multiple connection techniques to try and log
+		// into the iSCSI target.  We start with ConnectTypePing, then ConnectTypeSubnet and end
+		// with ConnectTypeAutoInitiator.
+		connectTypes = []string{model.ConnectTypePing, model.ConnectTypeSubnet, model.ConnectTypeAutoInitiator}
+	case model.ConnectTypePing, model.ConnectTypeSubnet, model.ConnectTypeAutoInitiator:
+		// Simple/singular connection type requested
+		connectTypes = []string{connectType}
+	default:
+		// Invalid / Unsupported connection type
+		err = cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageInvalidConnectionType, connectType)
+		log.Error(err)
+		return nil, err
+	}
+
+	return connectTypes, nil
+}
+
+// loginTargetPorts is called to connect an iSCSI target
+// Input Parameters
+//		ctx					Context of the request, used for logging
+//		blockDev			Login details for the iSCSI target
+//		initiatorPorts		Available initiator ports
+//		targetPorts			Available target ports
+//		connectType			Connection type
+//      loginExpiration		Login attempts need to complete by this time
+// Return Parameters
+//		connectionCount		Number of successful login attempts
+//		err					Error if unable to make any connection
+func (plugin *IscsiPlugin) loginTargetPorts(
+	ctx context.Context,
+	blockDev model.BlockDeviceAccessInfo,
+	initiatorPorts []*model.Network,
+	targetPorts []*model.TargetPortal,
+	connectType string,
+	loginExpiration time.Time,
+	maxConnectionCount uint32) (connections []ITNexus, err error) {
+
+	log.FromContext(ctx).Tracef(">>>>> loginTargetPorts, targetName=%v", blockDev.TargetName)
+	defer log.FromContext(ctx).Traceln("<<<<< loginTargetPorts")
+
+	// Enumerate the IT_nexuses we should attempt to make connections with using the
+	// specified connection type.
+	var itNexus map[*model.Network][]*model.TargetPortal
+	switch connectType {
+	case model.ConnectTypePing:
+		itNexus, _ = ITNexusPingCheck(initiatorPorts, targetPorts, 0, 0, 0)
+	case model.ConnectTypeSubnet:
+		itNexus, _ = ITNexusSubnetCheck(initiatorPorts, targetPorts)
+	case model.ConnectTypeAutoInitiator:
+		itNexus = make(map[*model.Network][]*model.TargetPortal)
+		emptyInitiatorPort := &model.Network{AddressV4: "0.0.0.0"}
+		for _, ipTarget := range targetPorts {
+			itNexus[emptyInitiatorPort] = append(itNexus[emptyInitiatorPort], ipTarget)
+		}
+	default:
+		err = cerrors.NewChapiErrorf(cerrors.Internal, errorMessageInvalidConnectionType, connectType)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	// Keep track of the last login error that occurs (if any)
+	var lastLoginError error
+
+	// Loop through each initiator and the array of target ports to connect
+	for initiatorPort, targetPorts := range itNexus {
+
+		// Loop through each target port and attempt to make a connection to it
+		for _, targetPort := range targetPorts {
+
+			// Break out of ITNexus loop if maximum connection count reached
+			if uint32(len(connections)) >= maxConnectionCount {
+				log.FromContext(ctx).Tracef("Maximum connection count reached, connections=%v, maxConnectionCount=%v", len(connections), maxConnectionCount)
+				break
+			}
+
+			// Log into the given target port from the given initiator port.  If an error occurred,
+			// move to the next IT nexus.
+			if loginError := plugin.loginTargetPort(ctx, blockDev, initiatorPort, targetPort, loginExpiration); loginError != nil {
+				lastLoginError = loginError
+				continue
+			}
+
+			// Connection successful; append connection to connections array
+			connections = append(connections, ITNexus{initiatorPort: initiatorPort, targetPort: targetPort})
+		}
+	}
+
+	// If no connections were made, fail the request
+	if len(connections) == 0 {
+		err = lastLoginError
+		if err == nil {
+			err = cerrors.NewChapiError(cerrors.Internal, errorMessageNoAvailableConnections)
+		}
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	// Success!  Return the connections established.
+	log.FromContext(ctx).Infof("%v connection(s) established", len(connections))
+	return connections, nil
+}
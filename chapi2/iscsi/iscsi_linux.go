@@ -3,9 +3,22 @@
 package iscsi
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/host"
 	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/linux"
 	log "github.com/hpe-storage/common-host-libs/logger"
+	"github.com/hpe-storage/common-host-libs/sgio"
 	"github.com/hpe-storage/common-host-libs/util"
 )
 
@@ -14,6 +27,48 @@ const (
 	initiatorNamePattern = "^InitiatorName=(?P<iscsiinit>.*)$"
 )
 
+const (
+	iscsiadmCmd = "iscsiadm"
+
+	// iscsiadm exit codes we need to handle
+	iscsiadmErrSessionExists = 15 // ISCSI_ERR_SESS_EXISTS - session is already logged in
+	iscsiadmErrNoObjsFound   = 21 // ISCSI_ERR_NO_OBJS_FOUND - no records/sessions matched the request
+
+	// iscsiadm iface used when the host initiator picks the initiator port (ConnectTypeAutoInitiator)
+	defaultIface = "default"
+
+	// Prefix of the iface created for an initiator port (e.g. "iface_eth1"), the same naming used
+	// by the linux package when binding ifaces.
+	ifacePrefix = "iface_"
+
+	// open-iscsi only establishes a single session per iface/portal record so, unlike Windows,
+	// there is no minimum connection padding.  We still cap the number of connections per target.
+	maxIscsiConnections = 32
+
+	// Size of the standard Inquiry buffer read from a target LUN
+	inquiryBufferSize = 96
+)
+
+var (
+	// sysfs locations used to enumerate iSCSI sessions (swapped by unit tests)
+	iscsiSessionPath = "/sys/class/iscsi_session"
+	devPath          = "/dev"
+
+	// iscsiadm node records (e.g. "10.0.0.1:3260,2460 iqn.2007-11.com.nimblestorage:vol1") and
+	// discovery records (e.g. "10.0.0.1:3260 via sendtargets")
+	nodeRecordRegexp      = regexp.MustCompile(`^(?P<address>\[[^\]]+\]|[^\s:]+):(?P<port>\d+),(?P<tag>-?\d+)\s+(?P<target>\S+)$`)
+	discoveryRecordRegexp = regexp.MustCompile(`^(?P<address>\[[^\]]+\]|[^\s:]+):(?P<port>\d+)\s+via\s+sendtargets$`)
+
+	// iscsiadm updates the iSCSI database files; serialize our updates
+	iscsiadmMutex sync.Mutex
+)
+
+// iscsiSession describes an iSCSI session enumerated from sysfs
+type iscsiSession struct {
+	targetName string   // Target iqn
+	devices    []string // SCSI block devices attached through the session (e.g. "sdb")
+}
+
 func getIscsiInitiators() (init *model.Initiator, err error) {
 	log.Trace(">>>>> getIscsiInitiators")
 	defer log.Trace("<<<<< getIscsiInitiators")
@@ -39,38 +94,564 @@ func getIscsiInitiators() (init *model.Initiator, err error) {
 
 // getTargetScope enumerates the target scope for the given iSCSI target.  An empty string is
 // returned if we were unable to determine the target scope.
-func getTargetScope(targetName string) (targetScope string, err error) {
-	// TODO
-	return "", nil
+func getTargetScope(targetName string) (string, error) {
+	log.Tracef(">>>>> getTargetScope, targetName=%v", targetName)
+	defer log.Trace("<<<<< getTargetScope")
+
+	// Enumerate all the iSCSI sessions
+	iscsiSessions, err := getIscsiSessions()
+	if err != nil {
+		log.Error(err.Error())
+		return "", err
+	}
+
+	// Keep track of the last enumeration error.  If all attempted queries fail, we'll return
+	// this error to the caller.
+	var lastErr error
+
+	// Loop through all the iSCSI sessions
+	for _, iscsiSession := range iscsiSessions {
+
+		// If the session isn't for our target, skip it
+		if !strings.EqualFold(targetName, iscsiSession.targetName) {
+			continue
+		}
+
+		// Unlike Windows, the Inquiry request is sent to a LUN attached through the session.  If
+		// the session has no LUN (e.g. GST without any volume), skip this session.
+		for _, device := range iscsiSession.devices {
+
+			// Issue an Inquiry request on the current session
+			inquiryBuffer := make([]byte, inquiryBufferSize)
+			if lastErr = sgio.ExecIoctl(sgio.StandardInquiry, inquiryBuffer, filepath.Join(devPath, device)); lastErr != nil {
+				lastErr = cerrors.NewChapiError(cerrors.NotFound, lastErr)
+				log.Tracef("Inquiry failed on %v, err=%v", device, lastErr)
+				continue
+			}
+
+			// Get the target scope value from the Inquiry data
+			targetScope, err := parseTargetScope(inquiryBuffer)
+			if err != nil {
+				log.Error(err.Error())
+				return "", err
+			}
+
+			// Successfully enumerated target scope on this session.  Log target scope and return to the caller
+			log.Tracef("targetName=%v, targetScope=%v", targetName, targetScope)
+			return targetScope, nil
+		}
+	}
+
+	// We were unable to enumerate the target scope from any target session; return last error detected
+	if lastErr == nil {
+		// If we couldn't find any session, or LUN, for our target, we could end up here.  In that
+		// case, we'll log a generic error.
+		lastErr = cerrors.NewChapiErrorf(cerrors.NotFound, errorMessageNoTargetScope)
+	}
+	log.Error(lastErr.Error())
+	return "", lastErr
+}
+
+// parseTargetScope returns the target scope reported by a Nimble target's standard Inquiry data
+func parseTargetScope(inquiryBuffer []byte) (string, error) {
+	if len(inquiryBuffer) <= nimbleTargetScopeOffset {
+		return "", cerrors.NewChapiErrorf(cerrors.NotFound, errorMessageFailedInquiry, 0, len(inquiryBuffer))
+	}
+
+	// If this isn't a Nimble target, fail request
+	vendorProduct := string(inquiryBuffer[8:32])
+	if vendorProduct != nimbleVendorProduct {
+		return "", cerrors.NewChapiErrorf(cerrors.Internal, errorMessageNonNimbleTarget, vendorProduct)
+	}
+
+	targetScopeBits := inquiryBuffer[nimbleTargetScopeOffset] & 0x03
+	switch targetScopeBits {
+	case 0:
+		return model.TargetScopeVolume, nil
+	case 1:
+		return model.TargetScopeGroup, nil
+	}
+	return "", cerrors.NewChapiErrorf(cerrors.Internal, errorMessageInvalidTargetScope, targetScopeBits)
 }
 
-// rescanIscsiTarget rescans host ports for iSCSI devices
+// rescanIscsiTarget rescans host ports for iSCSI devices.  If a lunID is provided, only that LUN
+// is scanned on each iSCSI host.
 func rescanIscsiTarget(lunID string) error {
-	// TODO
+	if err := linux.RescanIscsi(lunID); err != nil {
+		return cerrors.NewChapiError(cerrors.Internal, err)
+	}
 	return nil
 }
 
 // getTargetPortals enumerates the target portals for the given iSCSI target
 func (plugin *IscsiPlugin) getTargetPortals(targetName string, ipv4Only bool) ([]*model.TargetPortal, error) {
-	// TODO
-	return nil, nil
+
+	// Retrieve the node records from the iSCSI database
+	nodeRecords, err := getNodeRecords()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	// Node records are created per iface; only return each target portal once
+	var targetPortals []*model.TargetPortal
+	found := make(map[string]bool)
+	for _, nodeRecord := range nodeRecords {
+		if !strings.EqualFold(nodeRecord.targetName, targetName) {
+			continue
+		}
+		if ipv4Only && (net.ParseIP(nodeRecord.portal.Address).To4() == nil) {
+			continue
+		}
+		key := nodeRecord.portal.Address + ":" + nodeRecord.portal.Port
+		if found[key] {
+			continue
+		}
+		found[key] = true
+		targetPortals = append(targetPortals, nodeRecord.portal)
+	}
+	return targetPortals, nil
 }
 
 // loginTarget is called to connect to the given iSCSI target.  The parent LoginTarget() routine
 // has already validated that target iqn and blockDev.IscsiAccessInfo are provided.
-func (plugin *IscsiPlugin) loginTarget(blockDev model.BlockDeviceAccessInfo) (err error) {
-	// TODO
+func (plugin *IscsiPlugin) loginTarget(ctx context.Context, blockDev model.BlockDeviceAccessInfo) (err error) {
+	log.FromContext(ctx).Trace(">>>>> loginTarget")
+	defer log.FromContext(ctx).Trace("<<<<< loginTarget")
+
+	log.FromContext(ctx).Infof("Login iSCSI target %v", blockDev.TargetName)
+
+	// Determine how we should try to connect to the iSCSI target
+	var connectTypes []string
+	if connectTypes, err = plugin.connectTypeToArray(blockDev.IscsiAccessInfo.ConnectType); err != nil {
+		return err
+	}
+
+	// Add discovery IP to host if one was provided
+	if blockDev.IscsiAccessInfo.DiscoveryIP != "" {
+		if err = plugin.addDiscoveryPortal(ctx, blockDev.IscsiAccessInfo.DiscoveryIP); err != nil {
+			return err
+		}
+	}
+
+	// See if the requested iSCSI target is already connected on this host.  This mirrors the
+	// Windows behavior.
+	if loggedIn, err := plugin.IsTargetLoggedIn(blockDev.TargetName); (loggedIn == true) || (err != nil) {
+
+		// Failure querying logged in status
+		if err != nil {
+			return err
+		}
+
+		// iSCSI target is already connected!  If it is *not* a volume scoped target (e.g. it's
+		// a group scoped target), rescan for the LUN before returning.
+		if !strings.EqualFold(blockDev.TargetScope, model.TargetScopeVolume) {
+			rescanIscsiTarget(blockDev.LunID)
+		}
+
+		// Return no error.  Target is already connected.
+		log.FromContext(ctx).Infof("Target %v already connected", blockDev.TargetName)
+		return nil
+	}
+
+	// Make sure the target was found through the discovery IP.  If not found on the first query,
+	// perform a deep discovery and retry once more.
+	if err = plugin.isTargetPresent(ctx, blockDev.TargetName); err != nil {
+		return err
+	}
+
+	// Enumerate the host initiator ports
+	initiatorPorts, err := host.NewHostPlugin().GetNetworks()
+	if err != nil {
+		return err
+	}
+
+	// Enumerate the target's data ports
+	log.FromContext(ctx).Infof("Get iSCSI target portals for %v", blockDev.TargetName)
+	var targetPorts []*model.TargetPortal
+	if targetPorts, err = plugin.GetTargetPortals(blockDev.TargetName, true); err != nil {
+		return err
+	}
+	log.FromContext(ctx).Infof("Login connection type(s) = %v, maxConnectionCount=%v", connectTypes, maxIscsiConnections)
+
+	// If all connections are not established by this time, the login process will stop and a
+	// timeout error will be returned to the caller.
+	loginExpiration := time.Now().Add(time.Second * loginTimeout)
+
+	// Keep track of the ITNexus connections made
+	var connections []ITNexus
+
+	// Loop through each type of connection type until one successfully connects with the target
+	for _, connectType := range connectTypes {
+
+		// Attempt to connect to the iSCSI target using the specified initiator ports and target ports
+		log.FromContext(ctx).Infof("Attempting login using connection type = %v", connectType)
+		connections, err = plugin.loginTargetPorts(ctx, blockDev, initiatorPorts, targetPorts, connectType, loginExpiration, maxIscsiConnections)
+
+		// If no connections were established using the current connection type, move to next type
+		if len(connections) == 0 {
+			continue
+		}
+
+		// If we were only able to establish partial connections, we'll use those connections and
+		// log/ignore any failed connections.
+		if err != nil {
+			log.FromContext(ctx).Warnf("Partial connections established, ignoring error, connectType=%v, count=%v, err=%v", connectType, len(connections), err)
+			err = nil
+		}
+
+		// Break out of loop; one or more connections were established
+		log.FromContext(ctx).Tracef("%v connection(s) established using connectType=%v", len(connections), connectType)
+		break
+	}
+
+	// If no iSCSI connections could be established, we'll return the last error.  If
+	// no connection attempts were made, an internal error is returned
+	if len(connections) == 0 {
+		if err == nil {
+			err = cerrors.NewChapiError(cerrors.Internal, errorMessageNoAvailableConnections)
+			log.FromContext(ctx).Error(err)
+		}
+		return err
+	}
+
+	// If it is *not* a volume scoped target (e.g. it's a group scoped target), rescan for the LUN
+	// before returning.  It's possible a LUN has been added to a GST and we need a rescan to
+	// ensure that the OS has detected it.
+	if !strings.EqualFold(blockDev.TargetScope, model.TargetScopeVolume) {
+		rescanIscsiTarget(blockDev.LunID)
+	}
+
+	// Success!  iSCSI connections established!
 	return nil
 }
 
 // logoutTarget is called to disconnect the given iSCSI target from this host.
-func (plugin *IscsiPlugin) logoutTarget(targetName string) (err error) {
-	// TODO
+func (plugin *IscsiPlugin) logoutTarget(ctx context.Context, targetName string) (err error) {
+	log.FromContext(ctx).Trace(">>>>> logoutTarget")
+	defer log.FromContext(ctx).Trace("<<<<< logoutTarget")
+
+	log.FromContext(ctx).Infof("Logout iSCSI target %v", targetName)
+
+	// Logout all iSCSI target sessions
+	if _, rc, err := execIscsiadm("--mode", "node", "--targetname", targetName, "--logout"); err != nil && rc != iscsiadmErrNoObjsFound {
+		err = cerrors.NewChapiError(cerrors.Internal, err)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+
+	// Remove the persistent node records
+	if _, rc, err := execIscsiadm("--mode", "node", "--targetname", targetName, "--op", "delete"); err != nil && rc != iscsiadmErrNoObjsFound {
+		err = cerrors.NewChapiError(cerrors.Internal, err)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
 	return nil
 }
 
 // isTargetLoggedIn checks to see if the given iSCSI target is already logged in.
 func (plugin *IscsiPlugin) isTargetLoggedIn(targetName string) (bool, error) {
-	// TODO
+	log.Tracef(">>>>> isTargetLoggedIn, TargetName=%v", targetName)
+	defer log.Traceln("<<<<< isTargetLoggedIn")
+
+	// Get the current iSCSI session list
+	iscsiSessions, err := getIscsiSessions()
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+
+	// See if the requested iSCSI target is already connected on this host
+	for _, iscsiSession := range iscsiSessions {
+		if strings.EqualFold(iscsiSession.targetName, targetName) {
+			return true, nil
+		}
+	}
 	return false, nil
 }
+
+// addDiscoveryPortal adds the given discovery IP to the system's discovery portals.
+func (plugin *IscsiPlugin) addDiscoveryPortal(ctx context.Context, discoveryIP string) error {
+	log.FromContext(ctx).Tracef(">>>>> addDiscoveryPortal, discoveryIP=%v", discoveryIP)
+	defer log.FromContext(ctx).Traceln("<<<<< addDiscoveryPortal")
+
+	// Enumerate the send target portals (e.g. discovery IPs)
+	discoveryPortals, err := getDiscoveryPortals()
+	if err != nil {
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+
+	// Does this host already have an entry for the discovery IP?
+	for _, discoveryPortal := range discoveryPortals {
+		if discoveryPortal.Address == discoveryIP {
+			// If discovery IP is already registed on this host, return nil
+			log.FromContext(ctx).Infof("Use discovery IP %v", discoveryIP)
+			return nil
+		}
+	}
+
+	// Add discovery IP to host
+	log.FromContext(ctx).Infof("Add discovery IP %v", discoveryIP)
+	return discoverTargets(discoveryIP)
+}
+
+// isTargetPresent returns nil if the given iSCSI target can be detected by this host, else an
+// applicable error is returned.
+func (plugin *IscsiPlugin) isTargetPresent(ctx context.Context, targetName string) error {
+	log.FromContext(ctx).Tracef(">>>>> isTargetPresent, targetName=%v", targetName)
+	defer log.FromContext(ctx).Traceln("<<<<< isTargetPresent")
+
+	// Check to see if target is available in the node records.  If not found on the first
+	// query, perform a deep discovery and retry once more.
+	for loop := 0; loop < 2; loop++ {
+		if loop == 1 {
+			// Post an informational log entry that we're now performing a deep discovery
+			// since the node records did not contain the target.
+			log.FromContext(ctx).Infoln("Performing a deep discovery to discover iSCSI target")
+			discoveryPortals, _ := getDiscoveryPortals()
+			for _, discoveryPortal := range discoveryPortals {
+				discoverTargets(discoveryPortal.Address + ":" + discoveryPortal.Port)
+			}
+		}
+		nodeRecords, _ := getNodeRecords()
+		for _, nodeRecord := range nodeRecords {
+			if strings.EqualFold(nodeRecord.targetName, targetName) {
+				// Return nil as soon as target is found
+				return nil
+			}
+		}
+	}
+
+	// Fail query since target was not found
+	err := cerrors.NewChapiError(cerrors.NotFound, errorMessageTargetNotFound)
+	log.FromContext(ctx).Error(err)
+	return err
+}
+
+// loginTargetPort is called to log into a single target port from a single initiator port.  An
+// iscsiadm iface, bound to the initiator port, is used for the I_T nexus.
+func (plugin *IscsiPlugin) loginTargetPort(
+	ctx context.Context,
+	blockDev model.BlockDeviceAccessInfo,
+	initiatorPort *model.Network,
+	targetPort *model.TargetPortal,
+	loginExpiration time.Time) error {
+
+	log.FromContext(ctx).Tracef(">>>>> loginTargetPort, targetName=%v", blockDev.TargetName)
+	defer log.FromContext(ctx).Traceln("<<<<< loginTargetPort")
+
+	// If the amount of time given to login to an iSCSI target has expired, fail the
+	// request.
+	if time.Now().After(loginExpiration) {
+		err := cerrors.NewChapiError(cerrors.Timeout, errorMessageLoginTimeout)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+
+	// Create the iface bound to the initiator port, and a node record using that iface, if they
+	// do not exist yet.
+	iface, err := createIface(initiatorPort)
+	if err == nil {
+		err = createNodeRecord(blockDev.TargetName, targetPort, iface)
+	}
+
+	// Apply CHAP (or clear a previous CHAP configuration), mark the record persistent, and log in
+	if err == nil {
+		err = updateNodeRecord(blockDev.TargetName, targetPort, iface, getNodeSettings(blockDev.IscsiAccessInfo))
+	}
+	if err == nil {
+		var rc int
+		if _, rc, err = execIscsiadm("--mode", "node", "--targetname", blockDev.TargetName, "--portal", portalString(targetPort), "--interface", iface, "--login"); rc == iscsiadmErrSessionExists {
+			err = nil
+		}
+	}
+
+	// Log error if failure connection not successful
+	if err != nil {
+		err = cerrors.NewChapiError(cerrors.Internal, err)
+		log.FromContext(ctx).Errorf("Connection failure, err=%v, iqn=%v, initiatorPort=%v, targetPort=%v", err, blockDev.TargetName, initiatorPort.AddressV4, targetPort.Address)
+		return err
+	}
+
+	// Success!!!  Connection established.
+	log.FromContext(ctx).Infof("Connection established, iqn=%v, initiatorPort=%v, targetPort=%v", blockDev.TargetName, initiatorPort.AddressV4, targetPort.Address)
+	return nil
+}
+
+// getNodeSettings returns the node record settings applied before logging into a target port
+func getNodeSettings(iscsiAccessInfo *model.IscsiAccessInfo) [][2]string {
+	authMethod := "None"
+	if iscsiAccessInfo.ChapUser != "" {
+		authMethod = "CHAP"
+	}
+	return [][2]string{
+		{"node.session.auth.authmethod", authMethod},
+		{"node.session.auth.username", iscsiAccessInfo.ChapUser},
+		{"node.session.auth.password", iscsiAccessInfo.ChapPassword},
+		{"node.startup", "automatic"},
+	}
+}
+
+// createIface returns the iscsiadm iface bound to the given initiator port, creating it if needed.
+// The default iface is returned when no initiator port is specified (ConnectTypeAutoInitiator).
+func createIface(initiatorPort *model.Network) (string, error) {
+	if initiatorPort.Name == "" {
+		return defaultIface, nil
+	}
+
+	iface := ifacePrefix + initiatorPort.Name
+	if _, _, err := execIscsiadm("--mode", "iface", "--interface", iface); err == nil {
+		return iface, nil
+	}
+
+	log.Infof("Creating iface %v bound to %v", iface, initiatorPort.Name)
+	if _, _, err := execIscsiadm("--mode", "iface", "--interface", iface, "--op", "new"); err != nil {
+		return "", err
+	}
+	if _, _, err := execIscsiadm("--mode", "iface", "--interface", iface, "--op", "update", "--name", "iface.net_ifacename", "--value", initiatorPort.Name); err != nil {
+		return "", err
+	}
+	return iface, nil
+}
+
+// createNodeRecord adds the node record for the given target portal and iface if not present
+func createNodeRecord(targetName string, targetPort *model.TargetPortal, iface string) error {
+	portal := portalString(targetPort)
+	_, rc, err := execIscsiadm("--mode", "node", "--targetname", targetName, "--portal", portal, "--interface", iface)
+	if rc != iscsiadmErrNoObjsFound {
+		return err
+	}
+	_, _, err = execIscsiadm("--mode", "node", "--targetname", targetName, "--portal", portal, "--interface", iface, "--op", "new")
+	return err
+}
+
+// updateNodeRecord applies the given name/value settings to the node record
+func updateNodeRecord(targetName string, targetPort *model.TargetPortal, iface string, settings [][2]string) error {
+	for _, setting := range settings {
+		if _, _, err := execIscsiadm("--mode", "node", "--targetname", targetName, "--portal", portalString(targetPort), "--interface", iface, "--op", "update", "--name", setting[0], "--value", setting[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discoverTargets performs a SendTargets discovery on the given portal and adds the discovered
+// targets to the node records
+func discoverTargets(portal string) error {
+	if _, _, err := execIscsiadm("--mode", "discovery", "--type", "sendtargets", "--portal", portal, "--op", "new"); err != nil {
+		err = cerrors.NewChapiError(cerrors.Internal, err)
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// nodeRecord is an iscsiadm node record, a target portal of a target
+type nodeRecord struct {
+	targetName string
+	portal     *model.TargetPortal
+}
+
+// getNodeRecords returns the node records from the iSCSI database
+func getNodeRecords() ([]nodeRecord, error) {
+	out, rc, err := execIscsiadm("--mode", "node")
+	if err != nil && rc != iscsiadmErrNoObjsFound {
+		return nil, cerrors.NewChapiError(cerrors.Internal, err)
+	}
+	return parseNodeRecords(out), nil
+}
+
+// parseNodeRecords parses the output of "iscsiadm --mode node"
+func parseNodeRecords(out string) []nodeRecord {
+	var nodeRecords []nodeRecord
+	for _, line := range strings.Split(out, "\n") {
+		result := util.FindStringSubmatchMap(strings.TrimSpace(line), nodeRecordRegexp)
+		if len(result) == 0 {
+			continue
+		}
+		nodeRecords = append(nodeRecords, nodeRecord{
+			targetName: result["target"],
+			portal: &model.TargetPortal{
+				Address: strings.Trim(result["address"], "[]"),
+				Port:    result["port"],
+				Tag:     result["tag"],
+			},
+		})
+	}
+	return nodeRecords
+}
+
+// getDiscoveryPortals returns the SendTargets discovery portals recorded on this host
+func getDiscoveryPortals() ([]*model.TargetPortal, error) {
+	out, rc, err := execIscsiadm("--mode", "discoverydb", "--type", "sendtargets")
+	if err != nil && rc != iscsiadmErrNoObjsFound {
+		return nil, cerrors.NewChapiError(cerrors.Internal, err)
+	}
+	return parseDiscoveryPortals(out), nil
+}
+
+// parseDiscoveryPortals parses the output of "iscsiadm --mode discoverydb --type sendtargets"
+func parseDiscoveryPortals(out string) []*model.TargetPortal {
+	var portals []*model.TargetPortal
+	for _, line := range strings.Split(out, "\n") {
+		result := util.FindStringSubmatchMap(strings.TrimSpace(line), discoveryRecordRegexp)
+		if len(result) == 0 {
+			continue
+		}
+		portals = append(portals, &model.TargetPortal{Address: strings.Trim(result["address"], "[]"), Port: result["port"]})
+	}
+	return portals
+}
+
+// portalString returns the iscsiadm portal argument for the given target port
+func portalString(targetPort *model.TargetPortal) string {
+	address := targetPort.Address
+	if strings.Contains(address, ":") {
+		address = "[" + address + "]"
+	}
+	if targetPort.Port == "" {
+		return address
+	}
+	return address + ":" + targetPort.Port
+}
+
+// getIscsiSessions enumerates the iSCSI sessions, and the block devices attached through each
+// session, from sysfs
+func getIscsiSessions() ([]*iscsiSession, error) {
+	sessionPaths, err := filepath.Glob(filepath.Join(iscsiSessionPath, "session*"))
+	if err != nil {
+		return nil, cerrors.NewChapiError(cerrors.Internal, err)
+	}
+
+	var iscsiSessions []*iscsiSession
+	for _, sessionPath := range sessionPaths {
+		targetName, err := ioutil.ReadFile(filepath.Join(sessionPath, "targetname"))
+		if err != nil {
+			// Session is being torn down, skip it
+			log.Tracef("unable to read target name of %v, err=%v", sessionPath, err)
+			continue
+		}
+		session := &iscsiSession{targetName: strings.TrimSpace(string(targetName))}
+
+		// The session's SCSI devices are found below device/target<H:C:T>/<H:C:T:L>/block/<sdX>
+		devicePaths, _ := filepath.Glob(filepath.Join(sessionPath, "device", "target*", "*", "block", "*"))
+		for _, devicePath := range devicePaths {
+			session.devices = append(session.devices, filepath.Base(devicePath))
+		}
+		iscsiSessions = append(iscsiSessions, session)
+	}
+	return iscsiSessions, nil
+}
+
+// execIscsiadm runs iscsiadm with the given arguments.  Updates to the iSCSI database are
+// serialized.
+func execIscsiadm(args ...string) (string, int, error) {
+	iscsiadmMutex.Lock()
+	defer iscsiadmMutex.Unlock()
+	out, rc, err := util.ExecCommandOutput(iscsiadmCmd, args)
+	if err != nil {
+		err = fmt.Errorf("%s %s failed, rc=%d, %s", iscsiadmCmd, strings.Join(log.Scrubber(args), " "), rc, strings.TrimSpace(out))
+	}
+	return out, rc, err
+}
@@ -0,0 +1,177 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package iscsi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+)
+
+const (
+	testTargetVolume = "iqn.2007-11.com.nimblestorage:vol1-v1.0001.abcd"
+	testTargetGroup  = "iqn.2007-11.com.nimblestorage:group-g1.abcd"
+)
+
+func TestParseNodeRecords(t *testing.T) {
+	out := `10.0.0.1:3260,2460 ` + testTargetVolume + `
+10.0.0.2:3260,2460 ` + testTargetVolume + `
+10.0.0.1:3260,2460 ` + testTargetVolume + `
+[fe80::1]:3260,1 ` + testTargetGroup + `
+iscsiadm: some warning
+`
+	expected := []nodeRecord{
+		{targetName: testTargetVolume, portal: &model.TargetPortal{Address: "10.0.0.1", Port: "3260", Tag: "2460"}},
+		{targetName: testTargetVolume, portal: &model.TargetPortal{Address: "10.0.0.2", Port: "3260", Tag: "2460"}},
+		{targetName: testTargetVolume, portal: &model.TargetPortal{Address: "10.0.0.1", Port: "3260", Tag: "2460"}},
+		{targetName: testTargetGroup, portal: &model.TargetPortal{Address: "fe80::1", Port: "3260", Tag: "1"}},
+	}
+	if nodeRecords := parseNodeRecords(out); !reflect.DeepEqual(nodeRecords, expected) {
+		t.Errorf("unexpected node records %+v", nodeRecords)
+	}
+	if nodeRecords := parseNodeRecords("iscsiadm: No records found\n"); len(nodeRecords) != 0 {
+		t.Errorf("unexpected node records %+v", nodeRecords)
+	}
+}
+
+func TestParseDiscoveryPortals(t *testing.T) {
+	out := "10.0.0.10:3260 via sendtargets\n[fe80::10]:3260 via sendtargets\n10.0.0.11:3260 via isns\n"
+	expected := []*model.TargetPortal{
+		{Address: "10.0.0.10", Port: "3260"},
+		{Address: "fe80::10", Port: "3260"},
+	}
+	if portals := parseDiscoveryPortals(out); !reflect.DeepEqual(portals, expected) {
+		t.Errorf("unexpected discovery portals %+v", portals)
+	}
+}
+
+func TestPortalString(t *testing.T) {
+	tests := map[string]*model.TargetPortal{
+		"10.0.0.1:3260":    {Address: "10.0.0.1", Port: "3260"},
+		"10.0.0.1":         {Address: "10.0.0.1"},
+		"[fe80::1]:3260":   {Address: "fe80::1", Port: "3260"},
+		"[2001:db8::1]:80": {Address: "2001:db8::1", Port: "80"},
+	}
+	for expected, targetPort := range tests {
+		if portal := portalString(targetPort); portal != expected {
+			t.Errorf("portalString(%+v) = %v, expected %v", targetPort, portal, expected)
+		}
+	}
+}
+
+func TestGetNodeSettings(t *testing.T) {
+	settings := getNodeSettings(&model.IscsiAccessInfo{ChapUser: "chapuser", ChapPassword: "chappassword"})
+	if settings[0] != [2]string{"node.session.auth.authmethod", "CHAP"} || settings[1][1] != "chapuser" || settings[2][1] != "chappassword" {
+		t.Errorf("unexpected CHAP settings %v", settings)
+	}
+
+	// CHAP is cleared when not requested
+	settings = getNodeSettings(&model.IscsiAccessInfo{})
+	if settings[0] != [2]string{"node.session.auth.authmethod", "None"} || settings[1][1] != "" || settings[2][1] != "" {
+		t.Errorf("unexpected CHAP settings %v", settings)
+	}
+	if settings[3] != [2]string{"node.startup", "automatic"} {
+		t.Errorf("unexpected startup setting %v", settings[3])
+	}
+}
+
+func TestConnectTypeToArray(t *testing.T) {
+	plugin := NewIscsiPlugin()
+	tests := map[string][]string{
+		"":                             {model.ConnectTypePing, model.ConnectTypeSubnet, model.ConnectTypeAutoInitiator},
+		model.ConnectTypeDefault:       {model.ConnectTypePing, model.ConnectTypeSubnet, model.ConnectTypeAutoInitiator},
+		model.ConnectTypePing:          {model.ConnectTypePing},
+		model.ConnectTypeSubnet:        {model.ConnectTypeSubnet},
+		model.ConnectTypeAutoInitiator: {model.ConnectTypeAutoInitiator},
+	}
+	for connectType, expected := range tests {
+		connectTypes, err := plugin.connectTypeToArray(connectType)
+		if err != nil || !reflect.DeepEqual(connectTypes, expected) {
+			t.Errorf("connectTypeToArray(%q) = %v, %v", connectType, connectTypes, err)
+		}
+	}
+
+	_, err := plugin.connectTypeToArray("multicast")
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestParseTargetScope(t *testing.T) {
+	inquiryBuffer := make([]byte, inquiryBufferSize)
+	copy(inquiryBuffer[8:], nimbleVendorProduct)
+
+	if targetScope, err := parseTargetScope(inquiryBuffer); err != nil || targetScope != model.TargetScopeVolume {
+		t.Errorf("unexpected target scope %v, err=%v", targetScope, err)
+	}
+
+	inquiryBuffer[nimbleTargetScopeOffset] = 0x01
+	if targetScope, err := parseTargetScope(inquiryBuffer); err != nil || targetScope != model.TargetScopeGroup {
+		t.Errorf("unexpected target scope %v, err=%v", targetScope, err)
+	}
+
+	inquiryBuffer[nimbleTargetScopeOffset] = 0x02
+	if _, err := parseTargetScope(inquiryBuffer); err == nil {
+		t.Error("invalid target scope not detected")
+	}
+
+	copy(inquiryBuffer[8:], "3PARdataVV              ")
+	if _, err := parseTargetScope(inquiryBuffer); err == nil {
+		t.Error("non-Nimble target not detected")
+	}
+
+	if _, err := parseTargetScope(inquiryBuffer[:36]); err == nil {
+		t.Error("short Inquiry data not detected")
+	}
+}
+
+// writeSessionFile creates a file, and its parent directories, in the fake sysfs tree
+func writeSessionFile(t *testing.T, root string, name string, value string) {
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetIscsiSessions(t *testing.T) {
+	root := t.TempDir()
+	writeSessionFile(t, root, "session1/targetname", testTargetVolume)
+	writeSessionFile(t, root, "session1/device/target3:0:0/3:0:0:0/block/sdb/size", "2048")
+	writeSessionFile(t, root, "session2/targetname", testTargetGroup)
+	writeSessionFile(t, root, "session2/device/target4:0:0/4:0:0:1/block/sdc/size", "2048")
+	writeSessionFile(t, root, "session2/device/target4:0:0/4:0:0:2/block/sdd/size", "2048")
+	writeSessionFile(t, root, "session3/targetname", testTargetGroup)
+
+	previous := iscsiSessionPath
+	iscsiSessionPath = root
+	defer func() { iscsiSessionPath = previous }()
+
+	iscsiSessions, err := getIscsiSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*iscsiSession{
+		{targetName: testTargetVolume, devices: []string{"sdb"}},
+		{targetName: testTargetGroup, devices: []string{"sdc", "sdd"}},
+		{targetName: testTargetGroup},
+	}
+	if !reflect.DeepEqual(iscsiSessions, expected) {
+		t.Errorf("unexpected sessions %+v", iscsiSessions)
+	}
+
+	plugin := NewIscsiPlugin()
+	if loggedIn, err := plugin.isTargetLoggedIn("IQN.2007-11.COM.NIMBLESTORAGE:GROUP-G1.ABCD"); err != nil || !loggedIn {
+		t.Errorf("target not logged in, err=%v", err)
+	}
+	if loggedIn, err := plugin.isTargetLoggedIn("iqn.2007-11.com.nimblestorage:vol2"); err != nil || loggedIn {
+		t.Errorf("target logged in, err=%v", err)
+	}
+}
@@ -3,6 +3,7 @@
 package iscsi
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"strconv"
@@ -177,11 +178,11 @@ func (plugin *IscsiPlugin) getTargetPortals(targetName string, ipv4Only bool) ([
 
 // loginTarget is called to connect to the given iSCSI target.  The parent LoginTarget() routine
 // has already validated that the target iqn and blockDev.IscsiAccessInfo are provided.
-func (plugin *IscsiPlugin) loginTarget(blockDev model.BlockDeviceAccessInfo) (err error) {
-	log.Trace(">>>>> loginTarget")
-	defer log.Trace("<<<<< loginTarget")
+func (plugin *IscsiPlugin) loginTarget(ctx context.Context, blockDev model.BlockDeviceAccessInfo) (err error) {
+	log.FromContext(ctx).Trace(">>>>> loginTarget")
+	defer log.FromContext(ctx).Trace("<<<<< loginTarget")
 
-	log.Infof("Login iSCSI target %v", blockDev.TargetName)
+	log.FromContext(ctx).Infof("Login iSCSI target %v", blockDev.TargetName)
 
 	// Determine how we should try to connect to the iSCSI target
 	var connectTypes []string
@@ -191,7 +192,7 @@ func (plugin *IscsiPlugin) loginTarget(blockDev model.BlockDeviceAccessInfo) (er
 
 	// Add discovery IP to host if one was provided
 	if blockDev.IscsiAccessInfo.DiscoveryIP != "" {
-		if err = plugin.addDiscoveryPortal(blockDev.IscsiAccessInfo.DiscoveryIP); err != nil {
+		if err = plugin.addDiscoveryPortal(ctx, blockDev.IscsiAccessInfo.DiscoveryIP); err != nil {
 			return err
 		}
 	}
@@ -213,13 +214,13 @@ func (plugin *IscsiPlugin) loginTarget(blockDev model.BlockDeviceAccessInfo) (er
 		}
 
 		// Return no error.  Target is already connected.
-		log.Infof("Target %v already connected", blockDev.TargetName)
+		log.FromContext(ctx).Infof("Target %v already connected", blockDev.TargetName)
 		return nil
 	}
 
 	// Make sure the target was found through the discovery IP.  If not found on the first query,
 	// perform a deep discovery and retry once more.
-	if err = plugin.isTargetPresent(blockDev.TargetName); err != nil {
+	if err = plugin.isTargetPresent(ctx, blockDev.TargetName); err != nil {
 		return err
 	}
 
@@ -230,7 +231,7 @@ func (plugin *IscsiPlugin) loginTarget(blockDev model.BlockDeviceAccessInfo) (er
 	}
 
 	// Enumerate the target's data ports
-	log.Infof("Get iSCSI target portals for %v", blockDev.TargetName)
+	log.FromContext(ctx).Infof("Get iSCSI target portals for %v", blockDev.TargetName)
 	var targetPorts []*model.TargetPortal
 	if targetPorts, err = plugin.GetTargetPortals(blockDev.TargetName, true); err != nil {
 		return err
@@ -238,7 +239,7 @@ func (plugin *IscsiPlugin) loginTarget(blockDev model.BlockDeviceAccessInfo) (er
 
 	// Get the minimum and maximum connections allowed for the iSCSI target
 	minConnectionCount, maxConnectionCount := getMinMaxConnectionsPerTarget(blockDev.TargetScope)
-	log.Infof("Login connection type(s) = %v, minConnectionCount=%v, maxConnectionCount=%v", connectTypes, minConnectionCount, maxConnectionCount)
+	log.FromContext(ctx).Infof("Login connection type(s) = %v, minConnectionCount=%v, maxConnectionCount=%v", connectTypes, minConnectionCount, maxConnectionCount)
 
 	// If all optimal connections are not established by this time, the login process will stop and
 	// a timeout error will be returned to the caller.
@@ -251,8 +252,8 @@ func (plugin *IscsiPlugin) loginTarget(blockDev model.BlockDeviceAccessInfo) (er
 	for _, connectType := range connectTypes {
 
 		// Attempt to connect to the iSCSI target using the specified initiator ports and target ports
-		log.Infof("Attempting login using connection type = %v", connectType)
-		connections, err = plugin.loginTargetPorts(blockDev, initiatorPorts, targetPorts, connectType, loginExpiration, maxConnectionCount)
+		log.FromContext(ctx).Infof("Attempting login using connection type = %v", connectType)
+		connections, err = plugin.loginTargetPorts(ctx, blockDev, initiatorPorts, targetPorts, connectType, loginExpiration, maxConnectionCount)
 
 		// If no connections were established using the current connection type, move to next type
 		if len(connections) == 0 {
@@ -262,12 +263,12 @@ func (plugin *IscsiPlugin) loginTarget(blockDev model.BlockDeviceAccessInfo) (er
 		// If we were only able to establish partial connections, we'll use those connections and
 		// log/ignore any failed connections.
 		if err != nil {
-			log.Warnf("Partial connections established, ignoring error, connectType=%v, count=%v, err=%v", connectType, len(connections), err)
+			log.FromContext(ctx).Warnf("Partial connections established, ignoring error, connectType=%v, count=%v, err=%v", connectType, len(connections), err)
 			err = nil
 		}
 
 		// Break out of loop; one or more connections were established
-		log.Tracef("%v initial connection(s) established using connectType=%v", len(connections), connectType)
+		log.FromContext(ctx).Tracef("%v initial connection(s) established using connectType=%v", len(connections), connectType)
 		break
 	}
 
@@ -276,7 +277,7 @@ func (plugin *IscsiPlugin) loginTarget(blockDev model.BlockDeviceAccessInfo) (er
 	if len(connections) == 0 {
 		if err == nil {
 			err = cerrors.NewChapiError(cerrors.Internal, errorMessageNoAvailableConnections)
-			log.Error(err)
+			log.FromContext(ctx).Error(err)
 		}
 		return err
 	}
@@ -285,11 +286,11 @@ func (plugin *IscsiPlugin) loginTarget(blockDev model.BlockDeviceAccessInfo) (er
 	// count, try creating additional connections using the same ITNexus as the already established
 	// connections.
 	if uint32(len(connections)) < minConnectionCount {
-		log.Infof("Adding connections to reach minimum count, currentConnections=%v, minConnectionCount=%v", len(connections), minConnectionCount)
+		log.FromContext(ctx).Infof("Adding connections to reach minimum count, currentConnections=%v, minConnectionCount=%v", len(connections), minConnectionCount)
 		for uint32(len(connections)) < minConnectionCount {
 			var newConnections []ITNexus
 			for _, connection := range connections {
-				if err = plugin.loginTargetPort(blockDev, connection.initiatorPort, connection.targetPort, loginExpiration); err != nil {
+				if err = plugin.loginTargetPort(ctx, blockDev, connection.initiatorPort, connection.targetPort, loginExpiration); err != nil {
 					return err
 				}
 				newConnections = append(newConnections, connection)
@@ -310,51 +311,26 @@ func (plugin *IscsiPlugin) loginTarget(blockDev model.BlockDeviceAccessInfo) (er
 }
 
 // logoutTarget is called to disconnect the given iSCSI target from this host.
-func (plugin *IscsiPlugin) logoutTarget(targetName string) (err error) {
-	log.Trace(">>>>> loginTarget")
-	defer log.Trace("<<<<< loginTarget")
+func (plugin *IscsiPlugin) logoutTarget(ctx context.Context, targetName string) (err error) {
+	log.FromContext(ctx).Trace(">>>>> loginTarget")
+	defer log.FromContext(ctx).Trace("<<<<< loginTarget")
 
-	log.Infof("Logout iSCSI target %v", targetName)
+	log.FromContext(ctx).Infof("Logout iSCSI target %v", targetName)
 
 	// Logout all iSCSI target sessions and remove persistent settings
 	return iscsidsc.LogoutIScsiTargetAll(targetName, true)
 }
 
-// connectTypeToArray takes the connectType string and returns an array of connection types that
-// reflect the input type.
-func (plugin *IscsiPlugin) connectTypeToArray(connectType string) (connectTypes []string, err error) {
-
-	// Determine how we should try to connect to the iSCSI target using the provided iSCSI
-	// ConnectType.  If property not provided, use the default value.
-	switch connectType {
-	case "", model.ConnectTypeDefault:
-		// If the default option is selected, we try multiple connection techniques to try and log
-		// into the iSCSI target.  We start with ConnectTypePing, then ConnectTypeSubnet and end
-		// with ConnectTypeAutoInitiator.
-		connectTypes = []string{model.ConnectTypePing, model.ConnectTypeSubnet, model.ConnectTypeAutoInitiator}
-	case model.ConnectTypePing, model.ConnectTypeSubnet, model.ConnectTypeAutoInitiator:
-		// Simple/singular connection type requested
-		connectTypes = []string{connectType}
-	default:
-		// Invalid / Unsupported connection type
-		err = cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageInvalidConnectionType, connectType)
-		log.Error(err)
-		return nil, err
-	}
-
-	return connectTypes, nil
-}
-
 // addDiscoveryPortal adds the given discovery IP to the system's discovery portals.
-func (plugin *IscsiPlugin) addDiscoveryPortal(discoveryIP string) error {
-	log.Tracef(">>>>> addDiscoveryPortal, discoveryIP=%v", discoveryIP)
-	defer log.Traceln("<<<<< addDiscoveryPortal")
+func (plugin *IscsiPlugin) addDiscoveryPortal(ctx context.Context, discoveryIP string) error {
+	log.FromContext(ctx).Tracef(">>>>> addDiscoveryPortal, discoveryIP=%v", discoveryIP)
+	defer log.FromContext(ctx).Traceln("<<<<< addDiscoveryPortal")
 
 	// Enumerate the send target portals (e.g. discovery IPs)
 	sendTargetPortals, err := iscsidsc.ReportIScsiSendTargetPortalsEx()
 	if err != nil {
 		err = cerrors.IscsiErrToCerrors(err)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return err
 	}
 
@@ -362,16 +338,16 @@ func (plugin *IscsiPlugin) addDiscoveryPortal(discoveryIP string) error {
 	for _, sendTargetPortal := range sendTargetPortals {
 		if sendTargetPortal.Address == discoveryIP {
 			// If discovery IP is already registed on this host, return nil
-			log.Infof("Use discovery IP %v", discoveryIP)
+			log.FromContext(ctx).Infof("Use discovery IP %v", discoveryIP)
 			return nil
 		}
 	}
 
 	// Add discovery IP to host
-	log.Infof("Add discovery IP %v", discoveryIP)
+	log.FromContext(ctx).Infof("Add discovery IP %v", discoveryIP)
 	if err = iscsidsc.AddIScsiSendTargetPortal("", iscsidsc.ISCSI_ANY_INITIATOR_PORT, discoveryIP); err != nil {
 		err = cerrors.IscsiErrToCerrors(err)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return err
 	}
 
@@ -403,9 +379,9 @@ func (plugin *IscsiPlugin) isTargetLoggedIn(targetName string) (bool, error) {
 
 // isTargetPresent returns nil if the given iSCSI target can be detected by this host, else an
 // applicable error is returned.
-func (plugin *IscsiPlugin) isTargetPresent(targetName string) error {
-	log.Tracef(">>>>> isTargetPresent, targetName=%v", targetName)
-	defer log.Traceln("<<<<< isTargetPresent")
+func (plugin *IscsiPlugin) isTargetPresent(ctx context.Context, targetName string) error {
+	log.FromContext(ctx).Tracef(">>>>> isTargetPresent, targetName=%v", targetName)
+	defer log.FromContext(ctx).Traceln("<<<<< isTargetPresent")
 
 	// Check to see if target is available through a discovery query.  If not found on the first
 	// query, perform a deep discovery and retry once more.
@@ -413,7 +389,7 @@ func (plugin *IscsiPlugin) isTargetPresent(targetName string) error {
 		if loop == 1 {
 			// Post an informational log entry that we're now performing a deep discovery
 			// since the default discovery did not detect the target.
-			log.Infoln("Performing a deep discovery to discover iSCSI target")
+			log.FromContext(ctx).Infoln("Performing a deep discovery to discover iSCSI target")
 		}
 		targets, _ := iscsidsc.ReportIscsiTargets(loop == 1)
 		for _, target := range targets {
@@ -426,108 +402,26 @@ func (plugin *IscsiPlugin) isTargetPresent(targetName string) error {
 
 	// Fail query since target was not found
 	err := cerrors.NewChapiError(cerrors.NotFound, errorMessageTargetNotFound)
-	log.Error(err)
+	log.FromContext(ctx).Error(err)
 	return err
 }
 
-// loginTargetPorts is called to connect an iSCSI target
-// Input Parameters
-//		blockDev			Login details for the iSCSI target
-//		initiatorPorts		Available initiator ports
-//		targetPorts			Available target ports
-//		connectType			Connection type
-//      loginExpiration		Login attempts need to complete by this time
-// Return Parameters
-//		connectionCount		Number of successful login attempts
-//		err					Error if unable to make any connection
-func (plugin *IscsiPlugin) loginTargetPorts(
-	blockDev model.BlockDeviceAccessInfo,
-	initiatorPorts []*model.Network,
-	targetPorts []*model.TargetPortal,
-	connectType string,
-	loginExpiration time.Time,
-	maxConnectionCount uint32) (connections []ITNexus, err error) {
-
-	log.Tracef(">>>>> loginTargetPorts, targetName=%v", blockDev.TargetName)
-	defer log.Traceln("<<<<< loginTargetPorts")
-
-	// Enumerate the IT_nexuses we should attempt to make connections with using the
-	// specified connection type.
-	var itNexus map[*model.Network][]*model.TargetPortal
-	switch connectType {
-	case model.ConnectTypePing:
-		itNexus, _ = ITNexusPingCheck(initiatorPorts, targetPorts, 0, 0, 0)
-	case model.ConnectTypeSubnet:
-		itNexus, _ = ITNexusSubnetCheck(initiatorPorts, targetPorts)
-	case model.ConnectTypeAutoInitiator:
-		itNexus = make(map[*model.Network][]*model.TargetPortal)
-		emptyInitiatorPort := &model.Network{AddressV4: "0.0.0.0"}
-		for _, ipTarget := range targetPorts {
-			itNexus[emptyInitiatorPort] = append(itNexus[emptyInitiatorPort], ipTarget)
-		}
-	default:
-		err = cerrors.NewChapiErrorf(cerrors.Internal, errorMessageInvalidConnectionType, connectType)
-		log.Error(err)
-		return nil, err
-	}
-
-	// Keep track of the last login error that occurs (if any)
-	var lastLoginError error
-
-	// Loop through each initiator and the array of target ports to connect
-	for initiatorPort, targetPorts := range itNexus {
-
-		// Loop through each target port and attempt to make a connection to it
-		for _, targetPort := range targetPorts {
-
-			// Break out of ITNexus loop if maximum connection count reached
-			if uint32(len(connections)) >= maxConnectionCount {
-				log.Tracef("Maximum connection count reached, connections=%v, maxConnectionCount=%v", len(connections), maxConnectionCount)
-				break
-			}
-
-			// Log into the given target port from the given initiator port.  If an error occurred,
-			// move to the next IT nexus.
-			if loginError := plugin.loginTargetPort(blockDev, initiatorPort, targetPort, loginExpiration); loginError != nil {
-				lastLoginError = loginError
-				continue
-			}
-
-			// Connection successful; append connection to connections array
-			connections = append(connections, ITNexus{initiatorPort: initiatorPort, targetPort: targetPort})
-		}
-	}
-
-	// If no connections were made, fail the request
-	if len(connections) == 0 {
-		err = lastLoginError
-		if err == nil {
-			err = cerrors.NewChapiError(cerrors.Internal, errorMessageNoAvailableConnections)
-		}
-		log.Error(err)
-		return nil, err
-	}
-
-	// Success!  Return the connections established.
-	log.Infof("%v connection(s) established", len(connections))
-	return connections, nil
-}
-
 // loginTargetPort is called to log into a single target port from a single initiator port
 func (plugin *IscsiPlugin) loginTargetPort(
+	ctx context.Context,
 	blockDev model.BlockDeviceAccessInfo,
 	initiatorPort *model.Network,
 	targetPort *model.TargetPortal,
 	loginExpiration time.Time) error {
 
-	log.Tracef(">>>>> loginTargetPort, targetName=%v", blockDev.TargetName)
-	defer log.Traceln("<<<<< loginTargetPort")
+	log.FromContext(ctx).Tracef(">>>>> loginTargetPort, targetName=%v", blockDev.TargetName)
+	defer log.FromContext(ctx).Traceln("<<<<< loginTargetPort")
 
 	// If the amount of time given to login to an iSCSI target has expired, fail the
 	// request.
 	if time.Now().After(loginExpiration) {
 		err := cerrors.NewChapiError(cerrors.Timeout, errorMessageLoginTimeout)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return err
 	}
 
@@ -554,12 +448,12 @@ func (plugin *IscsiPlugin) loginTargetPort(
 	// Log error if failure connection not successful
 	if err != nil {
 		err = cerrors.IscsiErrToCerrors(err)
-		log.Errorf("Connection failure, err=%v, iqn=%v, initiatorPort=%v, targetPort=%v", err, blockDev.TargetName, initiatorPort.AddressV4, targetPort.Address)
+		log.FromContext(ctx).Errorf("Connection failure, err=%v, iqn=%v, initiatorPort=%v, targetPort=%v", err, blockDev.TargetName, initiatorPort.AddressV4, targetPort.Address)
 		return err
 	}
 
 	// Success!!!  Connection established.
-	log.Infof("Connection established, iqn=%v, initiatorPort=%v, targetPort=%v", blockDev.TargetName, initiatorPort.AddressV4, targetPort.Address)
+	log.FromContext(ctx).Infof("Connection established, iqn=%v, initiatorPort=%v, targetPort=%v", blockDev.TargetName, initiatorPort.AddressV4, targetPort.Address)
 	return nil
 }
 
@@ -144,9 +144,10 @@ type DevicePartition struct {
 
 // PublishInfo is the node side data required to access a volume
 type PublishInfo struct {
-	SerialNumber string                   `json:"serial_number,omitempty"`
-	BlockDev     *BlockDeviceAccessInfo   `json:"block_device,omitempty"`
-	VirtualDev   *VirtualDeviceAccessInfo `json:"virtual_device,omitempty"`
+	SerialNumber  string                   `json:"serial_number,omitempty"`
+	BlockDev      *BlockDeviceAccessInfo   `json:"block_device,omitempty"`
+	VirtualDev    *VirtualDeviceAccessInfo `json:"virtual_device,omitempty"`
+	EncryptionKey string                   `json:"encryption_key,omitempty" log:"redact"` // LUKS passphrase, the device is LUKS formatted (if new) and opened when set
 }
 
 // BlockDeviceAccessInfo contains the common fields for accessing a block device
@@ -160,10 +161,10 @@ type BlockDeviceAccessInfo struct {
 
 // IscsiAccessInfo contains the fields necessary for iSCSI access
 type IscsiAccessInfo struct {
-	ConnectType  string `json:"connect_type,omitempty"`  // How connections should be enumerated/established
-	DiscoveryIP  string `json:"discovery_ip,omitempty"`  // iSCSI Discovery IP (empty for FC volumes)
-	ChapUser     string `json:"chap_user,omitempty"`     // CHAP username (empty if CHAP not used)
-	ChapPassword string `json:"chap_password,omitempty"` // CHAP password (empty if CHAP not used)
+	ConnectType  string `json:"connect_type,omitempty"`               // How connections should be enumerated/established
+	DiscoveryIP  string `json:"discovery_ip,omitempty"`               // iSCSI Discovery IP (empty for FC volumes)
+	ChapUser     string `json:"chap_user,omitempty"`                  // CHAP username (empty if CHAP not used)
+	ChapPassword string `json:"chap_password,omitempty" log:"redact"` // CHAP password (empty if CHAP not used)
 }
 
 // VirtualDeviceAccessInfo contains the required data to access a virtual device
@@ -193,6 +194,169 @@ type FileSystemOptions struct {
 	MountOpts []string `json:"mount_options,omitempty"` // Mount options rw,ro nodiscard etc
 }
 
+// BlockPublish is the device node of a Nimble volume bind mounted onto a file, the way raw block
+// volumes are published to containers
+type BlockPublish struct {
+	SerialNumber string `json:"serial_number,omitempty"` // Nimble volume serial number
+	TargetPath   string `json:"target_path,omitempty"`   // File the device node is bind mounted onto
+	DevicePath   string `json:"device_path,omitempty"`   // Published device node (e.g. "/dev/mapper/mpathg", or "/dev/mapper/enc-mpathg" if encrypted)
+	Major        uint32 `json:"major"`                   // Major number of the published device node
+	Minor        uint32 `json:"minor"`                   // Minor number of the published device node
+	ReadOnly     bool   `json:"read_only,omitempty"`     // True if the bind mount is read-only
+}
+
+// BlockPublishRequest is the target of a block device publish, or unpublish
+type BlockPublishRequest struct {
+	TargetPath string `json:"target_path,omitempty"` // File to bind mount the device node onto, created if missing
+	ReadOnly   bool   `json:"read_only,omitempty"`   // Bind mount the device node read-only
+}
+
+// FileSystemCheck is the result of a file system check, or repair, of a Nimble volume
+type FileSystemCheck struct {
+	SerialNumber string `json:"serial_number,omitempty"` // Nimble volume serial number
+	DevicePath   string `json:"device_path,omitempty"`   // Checked block device (e.g. "/dev/mapper/mpathb1")
+	FsType       string `json:"fs_type,omitempty"`       // File system type (e.g. "xfs")
+	Repair       bool   `json:"repair"`                  // True if the file system was repaired, false for a read-only check
+	ErrorsFound  bool   `json:"errors_found"`            // True if file system errors were found
+	Repaired     bool   `json:"repaired"`                // True if the errors found were corrected
+	ExitCode     int    `json:"exit_code"`               // Exit code of the check or repair tool
+	Output       string `json:"output,omitempty"`        // Output of the check or repair tool
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// CHAPI Encryption Object
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Encryption actions accepted in an EncryptionRequest
+const (
+	EncryptionActionFormat    = "format"
+	EncryptionActionOpen      = "open"
+	EncryptionActionClose     = "close"
+	EncryptionActionAddKey    = "add_key"
+	EncryptionActionRemoveKey = "remove_key"
+	EncryptionActionRotateKey = "rotate_key"
+)
+
+// Encryption describes the LUKS header of a device and whether its mapping is opened
+type Encryption struct {
+	SerialNumber   string `json:"serial_number,omitempty"`    // Nimble volume serial number
+	Encrypted      bool   `json:"encrypted"`                  // True if the device has a LUKS header
+	Version        int    `json:"version,omitempty"`          // LUKS version (1 or 2)
+	UUID           string `json:"uuid,omitempty"`             // LUKS header UUID
+	Cipher         string `json:"cipher,omitempty"`           // Cipher and mode (e.g. "aes-xts-plain64")
+	KeySize        int    `json:"key_size,omitempty"`         // Volume key size in bits
+	KeySlots       []int  `json:"key_slots,omitempty"`        // Key slots in use
+	Opened         bool   `json:"opened"`                     // True if the LUKS mapping is opened
+	MappedPathName string `json:"mapped_path_name,omitempty"` // Opened LUKS mapping (e.g. "/dev/mapper/enc-mpathg")
+}
+
+// EncryptionRequest is an encryption action to apply to a device.  Passphrases are only passed
+// to cryptsetup through its standard input, they are never logged nor written to disk.
+type EncryptionRequest struct {
+	Action        string `json:"action,omitempty"`                      // One of the EncryptionAction values
+	Passphrase    string `json:"passphrase,omitempty" log:"redact"`     // Passphrase of an existing key slot (the new passphrase for format)
+	NewPassphrase string `json:"new_passphrase,omitempty" log:"redact"` // Passphrase to add (add_key) or to replace the existing one with (rotate_key)
+	KeySlot       *int   `json:"key_slot,omitempty"`                    // Key slot to add the passphrase to or to remove, optional
+}
+
+// EncryptionHeader is a backup of the LUKS header of a device
+type EncryptionHeader struct {
+	SerialNumber string `json:"serial_number,omitempty"`       // Nimble volume serial number
+	Header       []byte `json:"header,omitempty" log:"redact"` // LUKS header backup (base64 in JSON), holds the encrypted volume key
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// CHAPI Reservation Object
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Persistent reservation actions accepted in a ReservationRequest
+const (
+	ReservationActionRegister   = "register"
+	ReservationActionUnregister = "unregister"
+	ReservationActionReserve    = "reserve"
+	ReservationActionRelease    = "release"
+	ReservationActionPreempt    = "preempt"
+	ReservationActionClear      = "clear"
+)
+
+// Reservation describes the SCSI-3 persistent reservation state of a device
+type Reservation struct {
+	SerialNumber string   `json:"serial_number,omitempty"` // Nimble volume serial number
+	HostKey      string   `json:"host_key,omitempty"`      // Reservation key of this host (hex)
+	Registered   bool     `json:"registered"`              // True if the host key is registered with the device
+	Keys         []string `json:"keys,omitempty"`          // All registered reservation keys (hex)
+	Reserved     bool     `json:"reserved"`                // True if the device is reserved
+	Holder       string   `json:"holder,omitempty"`        // Reservation key of the holder (hex), empty if not reserved
+	Type         string   `json:"type,omitempty"`          // Reservation type (e.g. "write-exclusive-registrants-only")
+	Generation   uint32   `json:"generation"`              // Persistent reservation generation of the device
+}
+
+// ReservationRequest is a persistent reservation action to apply to a device with the host key
+type ReservationRequest struct {
+	Action     string `json:"action,omitempty"`      // One of the ReservationAction values
+	Type       string `json:"type,omitempty"`        // Reservation type, defaults to "write-exclusive-registrants-only"
+	PreemptKey string `json:"preempt_key,omitempty"` // Reservation key (hex) to preempt, only used with the preempt action
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// CHAPI Event Object
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Event types reported by the event stream
+const (
+	EventTypeDeviceAdded      = "device_added"
+	EventTypeDeviceRemoved    = "device_removed"
+	EventTypeDeviceResized    = "device_resized"
+	EventTypePathStateChanged = "path_state_changed"
+	EventTypeMountAdded       = "mount_added"
+	EventTypeMountRemoved     = "mount_removed"
+	EventTypeResync           = "resync" // Events since the requested ID are no longer retained, re-enumerate devices and mounts
+)
+
+// Event is a device or mount change reported by the event stream
+type Event struct {
+	ID           uint64     `json:"id"`                      // Event ID, resume the stream after it with the Last-Event-ID header
+	Type         string     `json:"type"`                    // One of the EventType values
+	Timestamp    string     `json:"timestamp,omitempty"`     // Time (RFC 3339) the change was detected
+	SerialNumber string     `json:"serial_number,omitempty"` // Nimble volume serial number
+	Device       *Device    `json:"device,omitempty"`        // Device added, removed or resized
+	PreviousSize uint64     `json:"previous_size,omitempty"` // Device size in bytes before it was resized
+	Path         *PathState `json:"path,omitempty"`          // Path whose state changed
+	Mount        *Mount     `json:"mount,omitempty"`         // Mount added or removed
+}
+
+// PathState is the state change of a single path of a device
+type PathState struct {
+	Name          string `json:"name,omitempty"`           // Path name (e.g. "sdc")
+	State         string `json:"state,omitempty"`          // Current path state, empty if the path was removed
+	PreviousState string `json:"previous_state,omitempty"` // Previous path state, empty if the path was added
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// CHAPI Log Level Objects
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// LogLevel is the log level of a package
+type LogLevel struct {
+	Package  string `json:"package"`             // Package name or import path suffix (e.g. "linux", "chapi2/iscsi")
+	Level    string `json:"level"`               // Log level (e.g. "trace")
+	RevertAt string `json:"revert_at,omitempty"` // Time (RFC 3339) the previous level is restored, if any
+}
+
+// LogLevels reports the log levels of the CHAPI server
+type LogLevels struct {
+	Level    string      `json:"level"`               // Log level of the packages without a level of their own
+	RevertAt string      `json:"revert_at,omitempty"` // Time (RFC 3339) the previous level is restored, if any
+	Packages []*LogLevel `json:"packages,omitempty"`  // Log levels of individual packages
+}
+
+// LogLevelRequest changes the log level of the CHAPI server or of one of its packages
+type LogLevelRequest struct {
+	Package     string `json:"package,omitempty"`      // Package to change, empty to change the level of every other package
+	Level       string `json:"level,omitempty"`        // New log level, empty to remove the level of a package
+	RevertAfter string `json:"revert_after,omitempty"` // Duration (e.g. "30m") after which the previous level is restored, empty to keep the change
+}
+
 // FcHostPort FC host port
 type FcHostPort struct {
 	HostNumber string `json:"-"`
@@ -35,4 +35,7 @@ type DevicePrivate struct {
 
 // MountPrivate provides model.Mount platform specific private data
 type MountPrivate struct {
+	DevicePath string `json:"-"` // Mountable block device (e.g. "/dev/mapper/mpathb", "/dev/mapper/mpathb1" for a partition)
+	DmName     string `json:"-"` // Device mapper name of the mountable block device (e.g. "dm-4")
+	Partition  int    `json:"-"` // Partition number of the mountable block device, 0 for the whole device
 }
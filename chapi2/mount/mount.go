@@ -3,6 +3,7 @@
 package mount
 
 import (
+	"context"
 	"path/filepath"
 
 	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
@@ -15,6 +16,8 @@ import (
 const (
 	// Shared error messages
 	errorMessageInvalidInputParameter       = "invalid input parameter"
+	errorMessageInvalidTargetPath           = `target path "%v" is not an absolute path`
+	errorMessageMissingTargetPath           = "missing target path"
 	errorMessageMissingMountPoint           = "missing mount point"
 	errorMessageMissingMountPointID         = "missing mount point ID"
 	errorMessageMissingSerialNumber         = "missing serial number"
@@ -22,9 +25,13 @@ const (
 	errorMessageMountPointNotEmpty          = `mount point "%v" is not empty`
 	errorMessageMountPointNotFound          = "mount point not found"
 	errorMessageMultipathPluginNotSet       = "multipathPlugin not set"
+	errorMessageMultipleDevicesDetected     = "multiple (%v) devices detected"
 	errorMessageMultipleMountPointsDetected = "multiple mount points detected"
+	errorMessageNoDevicesDetected           = "no devices detected"
+	errorMessageUnsupportedFileSystem       = `unsupported file system "%v" for online expansion`
 	errorMessageUnsupportedPartition        = "unsupported partition"
 	errorMessageVolumeAlreadyMounted        = `volume already mounted at "%v"`
+	errorMessageVolumeBlockPublished        = `volume published as a block device at "%v"`
 )
 
 type Mounter struct {
@@ -40,22 +47,22 @@ func NewMounter() *Mounter {
 }
 
 // GetMounts reports all mounts on this host for the specified Nimble volume
-func (mounter *Mounter) GetMounts(serialNumber string) ([]*model.Mount, error) {
-	return mounter.getMounts(serialNumber, "", false, true)
+func (mounter *Mounter) GetMounts(ctx context.Context, serialNumber string) ([]*model.Mount, error) {
+	return mounter.getMounts(ctx, serialNumber, "", false, true)
 }
 
 // GetAllMountDetails enumerates the specified mount point ID
-func (mounter *Mounter) GetAllMountDetails(serialNumber string, mountId string) ([]*model.Mount, error) {
-	return mounter.getMounts(serialNumber, mountId, true, true)
+func (mounter *Mounter) GetAllMountDetails(ctx context.Context, serialNumber string, mountId string) ([]*model.Mount, error) {
+	return mounter.getMounts(ctx, serialNumber, mountId, true, true)
 }
 
 // CreateMount is called to mount the given device to the given mount point
-func (mounter *Mounter) CreateMount(serialNumber string, mountPoint string, fsOptions *model.FileSystemOptions) (*model.Mount, error) {
-	log.Tracef(">>>>> CreateMount, serialNumber=%v, mountPoint=%v, fsOptions=%v", serialNumber, mountPoint, fsOptions)
-	defer log.Trace("<<<<< CreateMount")
+func (mounter *Mounter) CreateMount(ctx context.Context, serialNumber string, mountPoint string, fsOptions *model.FileSystemOptions) (*model.Mount, error) {
+	log.FromContext(ctx).Tracef(">>>>> CreateMount, serialNumber=%v, mountPoint=%v, fsOptions=%v", serialNumber, mountPoint, fsOptions)
+	defer log.FromContext(ctx).Trace("<<<<< CreateMount")
 
 	// Validate and enumerate the mount object for the given serial number and mount point
-	mount, alreadyMounted, err := mounter.getMountForCreate(serialNumber, mountPoint)
+	mount, alreadyMounted, err := mounter.getMountForCreate(ctx, serialNumber, mountPoint)
 
 	// Fail request if unable to validate and enumerate the mount object
 	if err != nil {
@@ -68,7 +75,7 @@ func (mounter *Mounter) CreateMount(serialNumber string, mountPoint string, fsOp
 	}
 
 	// Mount the volume at the specified mount point
-	err = mounter.createMount(mount, mountPoint, fsOptions)
+	err = mounter.createMount(ctx, mount, mountPoint, fsOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -79,12 +86,12 @@ func (mounter *Mounter) CreateMount(serialNumber string, mountPoint string, fsOp
 }
 
 // DeleteMount is called to unmount the given mount point ID
-func (mounter *Mounter) DeleteMount(serialNumber string, mountId string) error {
-	log.Tracef(">>>>> DeleteMount, serialNumber=%v, mountId=%v", serialNumber, mountId)
-	defer log.Trace("<<<<< DeleteMount")
+func (mounter *Mounter) DeleteMount(ctx context.Context, serialNumber string, mountId string) error {
+	log.FromContext(ctx).Tracef(">>>>> DeleteMount, serialNumber=%v, mountId=%v", serialNumber, mountId)
+	defer log.FromContext(ctx).Trace("<<<<< DeleteMount")
 
 	// Validate and enumerate the mount object for the given serial number and mount point ID
-	mount, err := mounter.getMountForDelete(serialNumber, mountId)
+	mount, err := mounter.getMountForDelete(ctx, serialNumber, mountId)
 
 	// Fail request if unable to validate and enumerate the mount object
 	if err != nil {
@@ -92,18 +99,145 @@ func (mounter *Mounter) DeleteMount(serialNumber string, mountId string) error {
 	}
 
 	// Call the platform specific deleteMount routine to dismount the volume
-	return mounter.deleteMount(mount)
+	return mounter.deleteMount(ctx, mount)
+}
+
+// ExpandFileSystems grows the file systems mounted from the given Nimble volume to fill the
+// device.  The expanded mount points are returned, file systems that are not mounted are left as is.
+func (mounter *Mounter) ExpandFileSystems(ctx context.Context, serialNumber string) ([]*model.Mount, error) {
+	log.FromContext(ctx).Tracef(">>>>> ExpandFileSystems, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< ExpandFileSystems")
+
+	// If the serialNumber is not provided, fail the request
+	if serialNumber == "" {
+		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMissingSerialNumber)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	// Enumerate the mounted file systems, with all details, for the given serial number
+	mounts, err := mounter.getMounts(ctx, serialNumber, "", true, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the platform specific expandFileSystem routine for each mount point
+	for _, mount := range mounts {
+		if err = mounter.expandFileSystem(ctx, mount); err != nil {
+			return nil, err
+		}
+	}
+	return mounts, nil
+}
+
+// CheckFileSystem runs a read-only check of the file system on the given Nimble volume.  The file
+// system must not be mounted.
+func (mounter *Mounter) CheckFileSystem(ctx context.Context, serialNumber string) (*model.FileSystemCheck, error) {
+	log.FromContext(ctx).Tracef(">>>>> CheckFileSystem, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< CheckFileSystem")
+
+	// Validate and enumerate the unmounted block device of the given serial number
+	mount, err := mounter.getMountForCheck(ctx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the platform specific checkFileSystem routine for a read-only check
+	return mounter.checkFileSystem(ctx, mount, false)
+}
+
+// RepairFileSystem repairs the file system on the given Nimble volume.  The file system must not
+// be mounted.  A read-only check is run first, the repair is only attempted if errors are found.
+func (mounter *Mounter) RepairFileSystem(ctx context.Context, serialNumber string) (*model.FileSystemCheck, error) {
+	log.FromContext(ctx).Tracef(">>>>> RepairFileSystem, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< RepairFileSystem")
+
+	// Validate and enumerate the unmounted block device of the given serial number
+	mount, err := mounter.getMountForCheck(ctx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing to repair if the read-only check comes back clean
+	check, err := mounter.checkFileSystem(ctx, mount, false)
+	if err != nil || !check.ErrorsFound {
+		return check, err
+	}
+
+	// Repair the file system
+	log.FromContext(ctx).Infof("File system errors found on %v, repairing %v file system", check.DevicePath, check.FsType)
+	return mounter.checkFileSystem(ctx, mount, true)
+}
+
+// GetBlockPublishes reports the device nodes of the given Nimble volume that are bind mounted
+// onto files (i.e. published as raw block volumes)
+func (mounter *Mounter) GetBlockPublishes(ctx context.Context, serialNumber string) ([]*model.BlockPublish, error) {
+	log.FromContext(ctx).Tracef(">>>>> GetBlockPublishes, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< GetBlockPublishes")
+
+	// Enumerate the single device of the given serial number
+	device, err := mounter.getDeviceForPublish(ctx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the platform specific getBlockPublishes routine
+	return mounter.getBlockPublishes(ctx, device)
+}
+
+// CreateBlockPublish bind mounts the device node of the given Nimble volume onto the target file,
+// which is created if missing.  If the device node is already published at the target, the
+// existing publish is returned.
+func (mounter *Mounter) CreateBlockPublish(ctx context.Context, serialNumber string, request *model.BlockPublishRequest) (*model.BlockPublish, error) {
+	log.FromContext(ctx).Tracef(">>>>> CreateBlockPublish, serialNumber=%v, request=%+v", serialNumber, request)
+	defer log.FromContext(ctx).Trace("<<<<< CreateBlockPublish")
+
+	// Validate the target path and enumerate the single device of the given serial number
+	if request == nil {
+		request = &model.BlockPublishRequest{}
+	}
+	targetPath, err := validateTargetPath(request.TargetPath)
+	if err != nil {
+		return nil, err
+	}
+	device, err := mounter.getDeviceForPublish(ctx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the platform specific createBlockPublish routine
+	return mounter.createBlockPublish(ctx, device, targetPath, request.ReadOnly)
+}
+
+// DeleteBlockPublish unmounts the device node of the given Nimble volume from the target file and
+// removes the file.  Nothing is done if the device node is not published at the target.
+func (mounter *Mounter) DeleteBlockPublish(ctx context.Context, serialNumber string, targetPath string) error {
+	log.FromContext(ctx).Tracef(">>>>> DeleteBlockPublish, serialNumber=%v, targetPath=%v", serialNumber, targetPath)
+	defer log.FromContext(ctx).Trace("<<<<< DeleteBlockPublish")
+
+	// Validate the target path and enumerate the single device of the given serial number
+	targetPath, err := validateTargetPath(targetPath)
+	if err != nil {
+		return err
+	}
+	device, err := mounter.getDeviceForPublish(ctx, serialNumber)
+	if err != nil {
+		return err
+	}
+
+	// Call the platform specific deleteBlockPublish routine
+	return mounter.deleteBlockPublish(ctx, device, targetPath)
 }
 
 // enumerateDevices enumerates the given serialNumber (or all devices if serialNumber is empty).
 // The allDetails boolean lets us know if we just need to enumerate basic details (false) or if
 // all details are required (true).  We can optimize our enumeration (e.g. reduce the amount of
 // enumeration required) if we only need basic details.
-func (mounter *Mounter) enumerateDevices(serialNumber string, allDetails bool) ([]*model.Device, error) {
+func (mounter *Mounter) enumerateDevices(ctx context.Context, serialNumber string, allDetails bool) ([]*model.Device, error) {
 	if !allDetails {
-		return mounter.multipathPlugin.GetDevices(serialNumber)
+		return mounter.multipathPlugin.GetDevices(ctx, serialNumber)
 	}
-	return mounter.multipathPlugin.GetAllDeviceDetails(serialNumber)
+	return mounter.multipathPlugin.GetAllDeviceDetails(ctx, serialNumber)
 }
 
 // getMountForCreate takes the Nimble serial number, and mount point path, validates the input
@@ -113,27 +247,27 @@ func (mounter *Mounter) enumerateDevices(serialNumber string, allDetails bool) (
 //                        returned else false ("mount" object returned if alreadyMounted==true)
 //      err             - If volume cannot be mounted, an error object is returned ("mount" and
 //                        "alreadyMounted" are invalid)
-func (mounter *Mounter) getMountForCreate(serialNumber string, mountPoint string) (mount *model.Mount, alreadyMounted bool, err error) {
-	log.Tracef(">>>>> getMountForCreate, serialNumber=%v, mountPoint=%v", serialNumber, mountPoint)
-	defer log.Trace("<<<<< getMountForCreate")
+func (mounter *Mounter) getMountForCreate(ctx context.Context, serialNumber string, mountPoint string) (mount *model.Mount, alreadyMounted bool, err error) {
+	log.FromContext(ctx).Tracef(">>>>> getMountForCreate, serialNumber=%v, mountPoint=%v", serialNumber, mountPoint)
+	defer log.FromContext(ctx).Trace("<<<<< getMountForCreate")
 
 	// If the serialNumber is not provided, fail the request
 	if serialNumber == "" {
 		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMissingSerialNumber)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, false, err
 	}
 
 	// If the mountPoint is not provided, fail the request
 	if mountPoint == "" {
 		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMissingMountPoint)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, false, err
 	}
 
 	// Enumerate all the mount points, with all details, for the given serial number
 	var mounts []*model.Mount
-	mounts, err = mounter.getMounts(serialNumber, "", true, false)
+	mounts, err = mounter.getMounts(ctx, serialNumber, "", true, false)
 	if err != nil {
 		return nil, false, err
 	}
@@ -141,7 +275,7 @@ func (mounter *Mounter) getMountForCreate(serialNumber string, mountPoint string
 	// Fail request if no mount points detected
 	if len(mounts) == 0 {
 		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMountPointNotFound)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, false, err
 	}
 
@@ -149,7 +283,7 @@ func (mounter *Mounter) getMountForCreate(serialNumber string, mountPoint string
 	// Fail request if multiple mount points detected.
 	if len(mounts) > 1 {
 		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMultipleMountPointsDetected)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, false, err
 	}
 
@@ -162,7 +296,7 @@ func (mounter *Mounter) getMountForCreate(serialNumber string, mountPoint string
 		var currentMountPoint string
 		currentMountPoint, err = filepath.Abs(mount.MountPoint)
 		if err != nil {
-			log.Errorf("Invalid current mount point, MountPoint=%v, err=%v", mount.MountPoint, err)
+			log.FromContext(ctx).Errorf("Invalid current mount point, MountPoint=%v, err=%v", mount.MountPoint, err)
 			return nil, false, err
 		}
 
@@ -170,21 +304,21 @@ func (mounter *Mounter) getMountForCreate(serialNumber string, mountPoint string
 		var requestedMountPoint string
 		requestedMountPoint, err = filepath.Abs(mountPoint)
 		if err != nil {
-			log.Errorf("Invalid requested mount point, MountPoint=%v, err=%v", mountPoint, err)
+			log.FromContext(ctx).Errorf("Invalid requested mount point, MountPoint=%v, err=%v", mountPoint, err)
 			return nil, false, err
 		}
 
 		// If the current mount point matches the target mount point, there is nothing to do as we
 		// are already mounted at the requested location.
 		if isSamePathName(currentMountPoint, requestedMountPoint) {
-			log.Tracef(`Mount point ID=%v, SerialNumber=%v, currentMountPoint=%v, already mounted`, mount.ID, mount.SerialNumber, currentMountPoint)
+			log.FromContext(ctx).Tracef(`Mount point ID=%v, SerialNumber=%v, currentMountPoint=%v, already mounted`, mount.ID, mount.SerialNumber, currentMountPoint)
 			return mount, true, nil
 		}
 
 		// If here, the device is already mounted but to a different location.  Log the error and
 		// fail the request.
 		err = cerrors.NewChapiErrorf(cerrors.AlreadyExists, errorMessageVolumeAlreadyMounted, currentMountPoint)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, false, err
 	}
 
@@ -192,31 +326,131 @@ func (mounter *Mounter) getMountForCreate(serialNumber string, mountPoint string
 	return mount, false, nil
 }
 
+// getMountForCheck takes the Nimble serial number, validates it, and enumerates the Mount object
+// of the block device holding its file system.  The volume must have a single block device
+// that is neither mounted nor published as a raw block device.
+func (mounter *Mounter) getMountForCheck(ctx context.Context, serialNumber string) (*model.Mount, error) {
+	log.FromContext(ctx).Tracef(">>>>> getMountForCheck, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< getMountForCheck")
+
+	// If the serialNumber is not provided, fail the request
+	if serialNumber == "" {
+		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMissingSerialNumber)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	// Enumerate all the mount points, with all details, for the given serial number
+	mounts, err := mounter.getMounts(ctx, serialNumber, "", true, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail request if no mount points, or multiple mount points, detected
+	if len(mounts) == 0 {
+		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMountPointNotFound)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+	if len(mounts) > 1 {
+		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMultipleMountPointsDetected)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	// A mounted file system cannot be checked reliably, nor repaired
+	if mounts[0].MountPoint != "" {
+		err = cerrors.NewChapiErrorf(cerrors.PermissionDenied, errorMessageVolumeAlreadyMounted, mounts[0].MountPoint)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	// Neither can a device node published as a raw block device, its consumer may write to it
+	device, err := mounter.getDeviceForPublish(ctx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	publishes, err := mounter.getBlockPublishes(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+	if len(publishes) != 0 {
+		err = cerrors.NewChapiErrorf(cerrors.PermissionDenied, errorMessageVolumeBlockPublished, publishes[0].TargetPath)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+	return mounts[0], nil
+}
+
+// getDeviceForPublish takes the Nimble serial number, validates it, and enumerates the single
+// device with that serial number
+func (mounter *Mounter) getDeviceForPublish(ctx context.Context, serialNumber string) (*model.Device, error) {
+	// If the serialNumber is not provided, fail the request
+	if serialNumber == "" {
+		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMissingSerialNumber)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	// Enumerate the device, basic details provide all we need (i.e. the dm device)
+	devices, err := mounter.enumerateDevices(ctx, serialNumber, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		err = cerrors.NewChapiError(cerrors.NotFound, errorMessageNoDevicesDetected)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+	if len(devices) > 1 {
+		err = cerrors.NewChapiErrorf(cerrors.Internal, errorMessageMultipleDevicesDetected, len(devices))
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+	return devices[0], nil
+}
+
+// validateTargetPath checks the target path of a block device publish is provided and absolute,
+// the cleaned path is returned
+func validateTargetPath(targetPath string) (string, error) {
+	if targetPath == "" {
+		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMissingTargetPath)
+		log.Error(err)
+		return "", err
+	}
+	if !filepath.IsAbs(targetPath) {
+		err := cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageInvalidTargetPath, targetPath)
+		log.Error(err)
+		return "", err
+	}
+	return filepath.Clean(targetPath), nil
+}
+
 // getMountForDelete takes the Nimble serial number, and mount point ID, validates the input
 // data, and enumerates the Mount object.  The following properties are returned:
 //      mount             - Enumerated model.Mount object for the provided serialNumber/mountPointId
 //      err               - If volume cannot be dismounted, an error object is returned
-func (mounter *Mounter) getMountForDelete(serialNumber string, mountId string) (mount *model.Mount, err error) {
-	log.Tracef(">>>>> getMountForDelete, serialNumber=%v, mountId=%v", serialNumber, mountId)
-	defer log.Trace("<<<<< getMountForDelete")
+func (mounter *Mounter) getMountForDelete(ctx context.Context, serialNumber string, mountId string) (mount *model.Mount, err error) {
+	log.FromContext(ctx).Tracef(">>>>> getMountForDelete, serialNumber=%v, mountId=%v", serialNumber, mountId)
+	defer log.FromContext(ctx).Trace("<<<<< getMountForDelete")
 
 	// If the serialNumber is not provided, fail the request
 	if serialNumber == "" {
 		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMissingSerialNumber)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, err
 	}
 
 	// If the mountId is not provided, fail the request
 	if mountId == "" {
 		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMissingMountPointID)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, err
 	}
 
 	// Find the specified mount point ID with all details
 	var mounts []*model.Mount
-	mounts, err = mounter.getMounts(serialNumber, mountId, true, true)
+	mounts, err = mounter.getMounts(ctx, serialNumber, mountId, true, true)
 	if err != nil {
 		return nil, err
 	}
@@ -224,7 +458,7 @@ func (mounter *Mounter) getMountForDelete(serialNumber string, mountId string) (
 	// There should only be a single mount point object
 	if len(mounts) != 1 {
 		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMountPointNotFound)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, err
 	}
 
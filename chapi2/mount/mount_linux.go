@@ -3,9 +3,97 @@
 package mount
 
 import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
 	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/chapi2/sysfs"
+	"github.com/hpe-storage/common-host-libs/linux"
+	log "github.com/hpe-storage/common-host-libs/logger"
+	linuxmodel "github.com/hpe-storage/common-host-libs/model"
+	"github.com/hpe-storage/common-host-libs/util"
+	"golang.org/x/sys/unix"
 )
 
+const (
+	wholeDeviceIndex = 0 // partition number used for a file system on the whole device
+
+	errorMessageExpandPartition     = "file system on partition %v of the device, expanding a partitioned device is not supported"
+	errorMessageFileSystemMismatch  = `device has a "%v" file system, "%v" requested`
+	errorMessageFileSystemNotFound  = "device has no file system"
+	errorMessageFileSystemToolError = "%v failed on %v with exit code %v: %v"
+	errorMessageMountPointBusy      = `mount point "%v" is busy`
+	errorMessageNoFileSystem        = "device has no file system and no file system type was requested"
+	errorMessagePublishMismatch     = `target path "%v" is device %v:%v, %v:%v expected`
+	errorMessageTargetIsDirectory   = `target path "%v" is a directory`
+	errorMessageTargetNotPublished  = `target path "%v" is not a publish of the device`
+	errorMessageUnsupportedCheck    = `unsupported file system "%v" for check and repair`
+	errorMessageUnsupportedRepair   = `unsupported file system "%v" for repair, run "%v" manually`
+
+	fileSystemCheckTimeout = 3600 // seconds, a check or repair of a large file system takes a while
+)
+
+// procMountsPath is the mount table, a variable so tests can use a fake mount table
+var procMountsPath = "/proc/self/mounts"
+
+// mountEntry is a single entry of the mount table
+type mountEntry struct {
+	device     string
+	mountPoint string
+	fsType     string
+	options    []string
+}
+
+// fileSystemTool describes how to check and repair a file system type.  The device path is
+// appended to the arguments.
+type fileSystemTool struct {
+	command          string   // e.g. "e2fsck"
+	checkArgs        []string // arguments of a read-only check
+	repairArgs       []string // arguments of a repair
+	checkErrorsCode  int      // exit code of a check that found errors (0 is clean)
+	repairedMaxCode  int      // highest exit code of a successful repair
+	repairFailedCode int      // exit code of a repair that left errors uncorrected, -1 if none
+	noRepair         bool     // true if the tool is only used to check, its repair is not safe to automate
+}
+
+// fileSystemTools are the check and repair tools of the supported file system types
+var fileSystemTools = map[string]*fileSystemTool{
+	// e2fsck: 1 errors corrected, 2 corrected and reboot advised, 3 both, 4 errors left uncorrected
+	"ext2": {command: "e2fsck", checkArgs: []string{"-f", "-n"}, repairArgs: []string{"-f", "-y"}, checkErrorsCode: 4, repairedMaxCode: 3, repairFailedCode: 4},
+	"ext3": {command: "e2fsck", checkArgs: []string{"-f", "-n"}, repairArgs: []string{"-f", "-y"}, checkErrorsCode: 4, repairedMaxCode: 3, repairFailedCode: 4},
+	"ext4": {command: "e2fsck", checkArgs: []string{"-f", "-n"}, repairArgs: []string{"-f", "-y"}, checkErrorsCode: 4, repairedMaxCode: 3, repairFailedCode: 4},
+	// xfs_repair: 1 corruption found by the check, 2 dirty log that must be replayed by a mount
+	"xfs": {command: "xfs_repair", checkArgs: []string{"-n"}, checkErrorsCode: 1, repairFailedCode: -1},
+	// btrfs check: 1 errors found.  "btrfs check --repair" is documented as able to make things worse,
+	// so btrfs is only checked and has to be repaired by hand.
+	"btrfs": {command: "btrfs", checkArgs: []string{"check", "--readonly"}, checkErrorsCode: 1, repairFailedCode: -1, noRepair: true},
+}
+
+// publishNode is a device node of a Nimble volume that can be published as a raw block volume
+type publishNode struct {
+	dmName string // e.g. "dm-4"
+	name   string // device mapper name, e.g. "mpathb" or "enc-mpathb"
+	major  uint32
+	minor  uint32
+}
+
+// blockDevice is a mountable block device of a Nimble volume, the multipath device itself or one
+// of its partition or LUKS mappings
+type blockDevice struct {
+	dmName    string // e.g. "dm-4"
+	name      string // device mapper name, e.g. "mpathb1"
+	uuid      string // device mapper uuid, stable across reboots
+	partition int    // partition number, wholeDeviceIndex for the whole device
+}
+
 // getMounts enumerates the mountpoints for the given device / mount point.  The following input
 // variables determine which mount points will get enumerated:
 //
@@ -25,24 +113,813 @@ import (
 // If onlyMounted is false, this routine *only* returns mounted objects, else both mounted and
 // dismounted objects are returned.  Being able to enumerate Mount objects that are not mounted is
 // important because it provides details about the potential mount point.  For example, under
-// Windows, this includes disk and partition details that are needed in order to mount a volume.
-func (mounter *Mounter) getMounts(serialNumber string, mountId string, allDetails bool, onlyMounted bool) ([]*model.Mount, error) {
-	// TODO
-	return nil, nil
+// Linux, this includes the partition or LUKS mapping that needs to be mounted.
+func (mounter *Mounter) getMounts(ctx context.Context, serialNumber string, mountId string, allDetails bool, onlyMounted bool) ([]*model.Mount, error) {
+	log.FromContext(ctx).Tracef(">>>>> getMounts, serialNumber=%v, mountId=%v, allDetails=%v, onlyMounted=%v", serialNumber, mountId, allDetails, onlyMounted)
+	defer log.FromContext(ctx).Trace("<<<<< getMounts")
+
+	// Fail request if our Mounter object was not initialized properly
+	if mounter.multipathPlugin == nil {
+		err := cerrors.NewChapiError(cerrors.Internal, errorMessageMultipathPluginNotSet)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	// If the caller passed in a mount point ID, with no serial number (Option #2), then log an error
+	// and recommend the caller use Option #4 instead.  This will reduce the amount of enumeration
+	// required by this routine.  The routine will, however, continue to function.
+	if serialNumber == "" && mountId != "" {
+		log.FromContext(ctx).Errorf("No serial number provided with mountId=%v.  A serial number is recommended to reduce the amount of enumeration this routine requires.", mountId)
+	}
+
+	// Enumerate the Nimble device(s) on this host for the given serial number (or all Nimble
+	// devices if serialNumber is empty).  Basic details provide all we need (i.e. the dm device).
+	devices, err := mounter.enumerateDevices(ctx, serialNumber, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the mount table once for all the devices
+	mountTable, err := getMountTable()
+	if err != nil {
+		return nil, err
+	}
+
+	// Allocate an initial empty array of Mount objects to return to the caller
+	var mountPoints []*model.Mount
+
+	// Loop through each enumerated Nimble device
+	for _, device := range devices {
+		log.FromContext(ctx).Tracef("Checking serial number %v, device %v, for mount points", device.SerialNumber, device.Pathname)
+
+		for _, mountPoint := range getDeviceMountPoints(device, mountTable, allDetails, onlyMounted) {
+
+			// If we were passed in a mount point ID as input, and the ID does not match, skip
+			// this mount point ID.
+			if (mountId != "") && (mountId != mountPoint.ID) {
+				log.FromContext(ctx).Tracef("Skipping mount point ID %v, does not match requested ID %v", mountPoint.ID, mountId)
+				continue
+			}
+
+			// Append the model.Mount to our mount point array
+			mountPoints = append(mountPoints, mountPoint)
+
+			// Return mountPoints array if we enumerated the one requested mount point
+			if mountId != "" {
+				logMountPoints(mountPoints, allDetails)
+				return mountPoints, nil
+			}
+		}
+	}
+
+	// Log the enumerated mount points before exiting
+	logMountPoints(mountPoints, allDetails)
+	return mountPoints, nil
+}
+
+// getDeviceMountPoints returns the model.Mount objects for the mountable block devices of the
+// given Nimble device
+func getDeviceMountPoints(device *model.Device, mountTable []*mountEntry, allDetails bool, onlyMounted bool) []*model.Mount {
+	blockDevices, err := getBlockDevices(device.Pathname, wholeDeviceIndex)
+	if err != nil {
+		log.Errorf("Skipping device %v, err=%v", device.Pathname, err)
+		return nil
+	}
+
+	var mountPoints []*model.Mount
+	for _, blockDev := range blockDevices {
+		devicePath := sysfs.DevMapperPath + blockDev.name
+
+		// CHAPI only supports a single mount point path for a block device.  If there happen to
+		// be multiple mount points (e.g. bind mounts), we'll use the first one enumerated.
+		entries := findMountEntries(mountTable, devicePath, "/dev/"+blockDev.dmName)
+		if onlyMounted && len(entries) == 0 {
+			continue
+		}
+		if len(entries) > 1 {
+			log.Tracef(`%v has multiple (%v) mount point paths, using first path "%v"`, devicePath, len(entries), entries[0].mountPoint)
+		}
+
+		// Create the mount point ID from the device/block device details
+		id := getMountPointID(device.SerialNumber, blockDev.partition, blockDev.uuid)
+		log.Tracef("Enumerated mount point ID %v for SerialNumber %v, DevicePath=%v", id, device.SerialNumber, devicePath)
+
+		// Create a model.Mount object with the mount point ID and Linux specific private data
+		mountPoint := &model.Mount{
+			ID: id,
+			Private: &model.MountPrivate{
+				DevicePath: devicePath,
+				DmName:     blockDev.dmName,
+				Partition:  blockDev.partition,
+			},
+		}
+
+		// If all details were requested, populate the rest of the Mount object
+		if allDetails {
+			mountPoint.SerialNumber = device.SerialNumber
+			if len(entries) != 0 {
+				mountPoint.MountPoint = entries[0].mountPoint
+				mountPoint.FsOpts = &model.FileSystemOptions{
+					FsType:    entries[0].fsType,
+					MountOpts: entries[0].options,
+				}
+			}
+		}
+		mountPoints = append(mountPoints, mountPoint)
+	}
+	return mountPoints
+}
+
+// getBlockDevices returns the mountable block devices for the given device mapper device.  The
+// holders of the device are followed through partition and LUKS mappings, the block devices
+// without holders are the ones that can be mounted.  Holders of other types (e.g. LVM) are
+// not supported and skipped.
+func getBlockDevices(dmName string, partition int) ([]*blockDevice, error) {
+	holders, err := sysfs.GetHolders(dmName)
+	if err != nil {
+		return nil, err
+	}
+
+	// A device without holders is mountable
+	if len(holders) == 0 {
+		blockDev := &blockDevice{dmName: dmName, partition: partition}
+		if blockDev.name, err = sysfs.ReadFile(dmName, "dm", "name"); err != nil {
+			return nil, err
+		}
+		if blockDev.uuid, err = sysfs.ReadFile(dmName, "dm", "uuid"); err != nil {
+			return nil, err
+		}
+		return []*blockDevice{blockDev}, nil
+	}
+
+	var blockDevices []*blockDevice
+	for _, holder := range holders {
+		uuid := holder.UUID
+		var holderDevices []*blockDevice
+		switch {
+		case strings.HasPrefix(uuid, sysfs.PartUUIDPrefix):
+			// e.g. "part1-mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1"
+			number, err := strconv.Atoi(strings.TrimPrefix(uuid[:strings.Index(uuid+"-", "-")], sysfs.PartUUIDPrefix))
+			if err != nil {
+				log.Tracef("Ignoring partition %v with uuid %v", holder.DmName, uuid)
+				continue
+			}
+			holderDevices, err = getBlockDevices(holder.DmName, number)
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(uuid, sysfs.CryptUUIDPrefix):
+			holderDevices, err = getBlockDevices(holder.DmName, partition)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			log.Tracef("Ignoring unsupported holder %v of %v, uuid=%v", holder.DmName, dmName, uuid)
+			continue
+		}
+		blockDevices = append(blockDevices, holderDevices...)
+	}
+	return blockDevices, nil
+}
+
+// getMountPointID takes the device serial number, partition number, and the device mapper uuid of
+// the mounted block device to create a unique mount ID that is stable across reboots.
+func getMountPointID(serialNumber string, partition int, uuid string) string {
+
+	// The serial number, partition number, and dm uuid are used to uniquely identify this mount
+	// point.  We're going to hash these three values into a 64-bit value.
+	id := fmt.Sprintf("%v.%v.%v", serialNumber, partition, uuid)
+	h := fnv.New64a()
+	h.Write([]byte(id))
+
+	// As on Windows, the partition number is appended to the 64-bit hash
+	return fmt.Sprintf("%x-%x", h.Sum64(), partition)
+}
+
+// getMountTable reads the mount table of this host
+func getMountTable() ([]*mountEntry, error) {
+	data, err := ioutil.ReadFile(procMountsPath)
+	if err != nil {
+		return nil, err
+	}
+	var mountTable []*mountEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		// e.g. "/dev/mapper/mpathb /mnt/vol1 xfs rw,relatime,attr2 0 0"
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		mountTable = append(mountTable, &mountEntry{
+			device:     unescapeMountField(fields[0]),
+			mountPoint: unescapeMountField(fields[1]),
+			fsType:     fields[2],
+			options:    strings.Split(fields[3], ","),
+		})
+	}
+	return mountTable, nil
+}
+
+// unescapeMountField replaces the octal escapes (e.g. "\040" for a space) of a mount table field
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if value, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+// findMountEntries returns the mount table entries for any of the given device paths
+func findMountEntries(mountTable []*mountEntry, devicePaths ...string) []*mountEntry {
+	var entries []*mountEntry
+	for _, entry := range mountTable {
+		for _, devicePath := range devicePaths {
+			if entry.device == devicePath {
+				entries = append(entries, entry)
+				break
+			}
+		}
+	}
+	return entries
 }
 
 // createMount is called to mount the given device to the given mount point
-func (mounter *Mounter) createMount(mount *model.Mount, mountPoint string, fsOptions *model.FileSystemOptions) error {
-	// TODO
+func (mounter *Mounter) createMount(ctx context.Context, mount *model.Mount, mountPoint string, fsOptions *model.FileSystemOptions) error {
+	log.FromContext(ctx).Tracef(`>>>>> createMount, mountPoint="%v", fsOptions=%v`, mountPoint, fsOptions)
+	defer log.FromContext(ctx).Trace("<<<<< createMount")
+
+	// Validate the Mount object
+	if err := validateMount(mount); err != nil {
+		return err
+	}
+	if fsOptions == nil {
+		fsOptions = &model.FileSystemOptions{}
+	}
+	devicePath := mount.Private.DevicePath
+	log.FromContext(ctx).Tracef("SerialNumber=%v, DevicePath=%v", mount.SerialNumber, devicePath)
+
+	// Adjust mount point path with absolute path if necessary
+	if absPath, err := filepath.Abs(mountPoint); (err == nil) && (absPath != mountPoint) {
+		log.FromContext(ctx).Tracef(`Adjusting requested mount point path "%v" with absolute path "%v"`, mountPoint, absPath)
+		mountPoint = absPath
+	}
+
+	// Fail the request if something is already mounted at the mount point
+	mountTable, err := getMountTable()
+	if err != nil {
+		return err
+	}
+	for _, entry := range mountTable {
+		if entry.mountPoint == mountPoint {
+			err = cerrors.NewChapiErrorf(cerrors.AlreadyExists, errorMessageMountPointInUse, mountPoint)
+			log.FromContext(ctx).Error(err)
+			return err
+		}
+	}
+
+	// If the directory exists, it must be empty.  You can only set a mountpoint to an empty
+	// directory.
+	isDirectoryExists := false
+	if _, err = os.Stat(mountPoint); !os.IsNotExist(err) {
+		isDirectoryExists = true
+		if isDirectoryEmpty, _ := isEmptyDirectory(mountPoint); !isDirectoryEmpty {
+			err = cerrors.NewChapiErrorf(cerrors.AlreadyExists, errorMessageMountPointNotEmpty, mountPoint)
+			log.FromContext(ctx).Error(err)
+			return err
+		}
+	}
+
+	// Create the file system if the device doesn't have one yet, else make sure it matches the
+	// requested file system type
+	fsType, err := linux.GetFilesystemType(devicePath)
+	if err != nil {
+		return cerrors.NewChapiError(err)
+	}
+	switch {
+	case fsType == "" && fsOptions.FsType == "":
+		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageNoFileSystem)
+		log.FromContext(ctx).Error(err)
+		return err
+	case fsType == "":
+		log.FromContext(ctx).Tracef("Creating %v file system on %v", fsOptions.FsType, devicePath)
+		if err = linux.RetryCreateFileSystem(devicePath, fsOptions.FsType); err != nil {
+			return cerrors.NewChapiError(err)
+		}
+	case fsOptions.FsType != "" && fsOptions.FsType != fsType:
+		err = cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageFileSystemMismatch, fsType, fsOptions.FsType)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+
+	// If the directory doesn't exist, create it
+	if !isDirectoryExists {
+		if err = os.MkdirAll(mountPoint, 0755); err != nil {
+			log.FromContext(ctx).Error(err)
+			return err
+		}
+		log.FromContext(ctx).Tracef(`Created mount point directory "%v"`, mountPoint)
+	}
+
+	// Mount the block device, with the requested mount options, and apply the requested mode and
+	// owner to the root of the file system
+	if _, err = linux.MountDeviceWithFileSystem(devicePath, mountPoint, fsOptions.MountOpts); err == nil {
+		err = linux.SetFilesystemOptions(mountPoint, &linuxmodel.FilesystemOpts{Mode: fsOptions.FsMode, Owner: fsOptions.FsOwner})
+		if err != nil {
+			// Don't leave a mount behind that doesn't have the requested options
+			if unmountErr := unix.Unmount(mountPoint, 0); unmountErr != nil {
+				log.FromContext(ctx).Errorf(`Unable to unmount "%v", err=%v`, mountPoint, unmountErr)
+			}
+		}
+	}
+	if err != nil {
+		if !isDirectoryExists {
+			// If we created an empty directory, to mount the Nimble volume, perform error cleanup
+			// by removing the folder before returning
+			if errRemove := os.Remove(mountPoint); errRemove != nil {
+				log.FromContext(ctx).Errorf(`Unable to remove created directory, directory="%v", err=%v`, mountPoint, errRemove)
+			}
+		}
+		return cerrors.NewChapiError(err)
+	}
+
+	// Success!
 	return nil
 }
 
 // deleteMount is called to unmount the given mount point ID
-func (mounter *Mounter) deleteMount(mount *model.Mount) error {
-	// TODO
+func (mounter *Mounter) deleteMount(ctx context.Context, mount *model.Mount) error {
+	log.FromContext(ctx).Trace(">>>>> deleteMount")
+	defer log.FromContext(ctx).Trace("<<<<< deleteMount")
+
+	// Validate the Mount object
+	if err := validateMount(mount); err != nil {
+		return err
+	}
+	log.FromContext(ctx).Tracef("SerialNumber=%v, DevicePath=%v, MountPoint=%v", mount.SerialNumber, mount.Private.DevicePath, mount.MountPoint)
+
+	// Unmount the file system, without forcing it, so a mount point in use is left intact
+	err := unix.Unmount(mount.MountPoint, 0)
+	if err == unix.EBUSY {
+		err = cerrors.NewChapiErrorf(cerrors.PermissionDenied, errorMessageMountPointBusy, mount.MountPoint)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+	if err != nil {
+		log.FromContext(ctx).Errorf(`Unable to unmount "%v", err=%v`, mount.MountPoint, err)
+		return cerrors.NewChapiError(err)
+	}
+
+	// The mount point was removed, we clean up after ourselves by removing the empty directory
+	log.FromContext(ctx).Tracef(`Removing "%v" directory`, mount.MountPoint)
+	if removeErr := os.Remove(mount.MountPoint); removeErr != nil {
+		// If we were able to remove the mount point, but unable to remove the empty directory,
+		// we'll simply log it as an error but not return the error to the caller.  From the
+		// caller's perspective, we were able to delete the mount point.
+		log.FromContext(ctx).Errorf("Failed to remove mount point directory, err=%v", removeErr)
+	}
+	return nil
+}
+
+// expandFileSystem grows the mounted file system to fill its block device.  A file system on a
+// partition is refused as the partition itself is not grown.
+func (mounter *Mounter) expandFileSystem(ctx context.Context, mount *model.Mount) error {
+	log.FromContext(ctx).Trace(">>>>> expandFileSystem")
+	defer log.FromContext(ctx).Trace("<<<<< expandFileSystem")
+
+	// Validate the Mount object
+	if err := validateMount(mount); err != nil {
+		return err
+	}
+	fsType := ""
+	if mount.FsOpts != nil {
+		fsType = mount.FsOpts.FsType
+	}
+	log.FromContext(ctx).Tracef("SerialNumber=%v, DevicePath=%v, MountPoint=%v, FsType=%v", mount.SerialNumber, mount.Private.DevicePath, mount.MountPoint, fsType)
+
+	// The partition would have to be grown before the file system on it
+	if mount.Private.Partition != wholeDeviceIndex {
+		err := cerrors.NewChapiErrorf(cerrors.Unimplemented, errorMessageExpandPartition, mount.Private.Partition)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+
+	// Only the file systems the linux package knows how to grow online are supported
+	switch fsType {
+	case linux.FsType.String(linux.Xfs), linux.FsType.String(linux.Ext2), linux.FsType.String(linux.Ext3),
+		linux.FsType.String(linux.Ext4), linux.FsType.String(linux.Btrfs):
+	default:
+		err := cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageUnsupportedFileSystem, fsType)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+
+	if err := linux.ExpandFilesystem(mount.Private.DevicePath, mount.MountPoint, fsType); err != nil {
+		log.FromContext(ctx).Errorf(`Unable to expand "%v", err=%v`, mount.MountPoint, err)
+		return cerrors.NewChapiError(err)
+	}
+	log.FromContext(ctx).Infof(`File system "%v" expanded on %v`, mount.MountPoint, mount.Private.DevicePath)
 	return nil
 }
 
+// checkFileSystem runs the read-only check, or the repair, of the file system on the unmounted
+// block device of the given mount
+func (mounter *Mounter) checkFileSystem(ctx context.Context, mount *model.Mount, repair bool) (*model.FileSystemCheck, error) {
+	log.FromContext(ctx).Tracef(">>>>> checkFileSystem, repair=%v", repair)
+	defer log.FromContext(ctx).Trace("<<<<< checkFileSystem")
+
+	// Validate the Mount object
+	if err := validateMount(mount); err != nil {
+		return nil, err
+	}
+	devicePath := mount.Private.DevicePath
+
+	// Determine the file system type on the block device
+	fsType, err := linux.GetFilesystemType(devicePath)
+	if err != nil {
+		return nil, cerrors.NewChapiError(err)
+	}
+	if fsType == "" {
+		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageFileSystemNotFound)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+	tool, ok := fileSystemTools[fsType]
+	if !ok {
+		err = cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageUnsupportedCheck, fsType)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	// Run the check or repair tool and interpret its exit code
+	args, err := tool.commandArgs(fsType, repair)
+	if err != nil {
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+	log.FromContext(ctx).Infof("Running %v %v on %v", tool.command, strings.Join(args, " "), devicePath)
+	output, exitCode, err := util.ExecCommandOutputWithTimeout(tool.command, append(append([]string{}, args...), devicePath), fileSystemCheckTimeout)
+	check := &model.FileSystemCheck{
+		SerialNumber: mount.SerialNumber,
+		DevicePath:   devicePath,
+		FsType:       fsType,
+		Repair:       repair,
+		ExitCode:     exitCode,
+		Output:       output,
+	}
+	if err = tool.interpretExitCode(check, err); err != nil {
+		return nil, err
+	}
+	log.FromContext(ctx).Infof("File system check of %v, repair=%v, errorsFound=%v, repaired=%v, exitCode=%v", devicePath, repair, check.ErrorsFound, check.Repaired, exitCode)
+	return check, nil
+}
+
+// commandArgs returns the arguments of the check or repair, a repair is refused if the tool is check only
+func (tool *fileSystemTool) commandArgs(fsType string, repair bool) ([]string, error) {
+	if !repair {
+		return tool.checkArgs, nil
+	}
+	if tool.noRepair {
+		return nil, cerrors.NewChapiErrorf(cerrors.Unimplemented, errorMessageUnsupportedRepair, fsType, tool.command)
+	}
+	return tool.repairArgs, nil
+}
+
+// interpretExitCode sets the outcome of the check from the exit code of the tool.  A repair is
+// only run once errors were found.  An error is returned if the tool failed to run to completion.
+func (tool *fileSystemTool) interpretExitCode(check *model.FileSystemCheck, err error) error {
+	succeeded := (err == nil) && (check.ExitCode == 0)
+	switch {
+	case !check.Repair && succeeded:
+		return nil
+	case !check.Repair && check.ExitCode == tool.checkErrorsCode:
+		check.ErrorsFound = true
+		return nil
+	case check.Repair && (succeeded || (check.ExitCode > 0 && check.ExitCode <= tool.repairedMaxCode)):
+		check.ErrorsFound, check.Repaired = true, true
+		return nil
+	case check.Repair && check.ExitCode == tool.repairFailedCode:
+		check.ErrorsFound = true
+		return nil
+	}
+	chapiErr := cerrors.NewChapiErrorf(cerrors.Internal, errorMessageFileSystemToolError, tool.command, check.DevicePath, check.ExitCode, strings.TrimSpace(check.Output))
+	log.Error(chapiErr)
+	return chapiErr
+}
+
+// getBlockPublishes returns the device nodes of the device bind mounted onto files
+func (mounter *Mounter) getBlockPublishes(ctx context.Context, device *model.Device) ([]*model.BlockPublish, error) {
+	nodes, err := getPublishNodes(device.Pathname)
+	if err != nil {
+		return nil, cerrors.NewChapiError(err)
+	}
+	mountTable, err := getMountTable()
+	if err != nil {
+		return nil, cerrors.NewChapiError(err)
+	}
+	return findBlockPublishes(device.SerialNumber, nodes, mountTable), nil
+}
+
+// createBlockPublish bind mounts the device node onto the target file and verifies the target
+// then is the device node.  The opened LUKS mapping of the device, if any, is published instead
+// of the multipath device.
+func (mounter *Mounter) createBlockPublish(ctx context.Context, device *model.Device, targetPath string, readOnly bool) (*model.BlockPublish, error) {
+	log.FromContext(ctx).Tracef(`>>>>> createBlockPublish, targetPath="%v", readOnly=%v`, targetPath, readOnly)
+	defer log.FromContext(ctx).Trace("<<<<< createBlockPublish")
+
+	nodes, err := getPublishNodes(device.Pathname)
+	if err != nil {
+		return nil, cerrors.NewChapiError(err)
+	}
+	node := nodes[0]
+	devicePath := sysfs.DevMapperPath + node.name
+	log.FromContext(ctx).Tracef("SerialNumber=%v, DevicePath=%v", device.SerialNumber, devicePath)
+
+	// If the device node is already published at the target there is nothing to do, fail the
+	// request if something else is mounted there
+	mountTable, err := getMountTable()
+	if err != nil {
+		return nil, cerrors.NewChapiError(err)
+	}
+	for _, publish := range findBlockPublishes(device.SerialNumber, nodes[:1], mountTable) {
+		if publish.TargetPath == targetPath {
+			log.FromContext(ctx).Tracef(`%v already published at "%v"`, devicePath, targetPath)
+			return publish, nil
+		}
+	}
+	for _, entry := range mountTable {
+		if entry.mountPoint == targetPath {
+			err = cerrors.NewChapiErrorf(cerrors.AlreadyExists, errorMessageMountPointInUse, targetPath)
+			log.FromContext(ctx).Error(err)
+			return nil, err
+		}
+	}
+
+	// The device node is bind mounted onto a file, create it if it doesn't exist yet
+	isTargetCreated := false
+	if info, statErr := os.Stat(targetPath); os.IsNotExist(statErr) {
+		if err = os.MkdirAll(filepath.Dir(targetPath), 0750); err == nil {
+			var file *os.File
+			if file, err = os.OpenFile(targetPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640); err == nil {
+				file.Close()
+				isTargetCreated = true
+				log.FromContext(ctx).Tracef(`Created target file "%v"`, targetPath)
+			}
+		}
+		if err != nil {
+			log.FromContext(ctx).Error(err)
+			return nil, cerrors.NewChapiError(err)
+		}
+	} else if statErr != nil {
+		log.FromContext(ctx).Error(statErr)
+		return nil, cerrors.NewChapiError(statErr)
+	} else if info.IsDir() {
+		err = cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageTargetIsDirectory, targetPath)
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	// Bind mount the device node, the read-only flag of a bind mount only applies when remounted
+	err = unix.Mount(devicePath, targetPath, "", unix.MS_BIND, "")
+	isMounted := err == nil
+	if err == nil && readOnly {
+		err = unix.Mount(devicePath, targetPath, "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY, "")
+	}
+	if err == nil {
+		err = verifyBlockPublish(targetPath, node)
+	}
+	if err != nil {
+		// Perform error cleanup, don't leave a partial publish behind
+		if isMounted {
+			if unmountErr := unix.Unmount(targetPath, 0); unmountErr != nil {
+				log.FromContext(ctx).Errorf(`Unable to unmount "%v", err=%v`, targetPath, unmountErr)
+			}
+		}
+		if isTargetCreated {
+			if removeErr := os.Remove(targetPath); removeErr != nil {
+				log.FromContext(ctx).Errorf(`Unable to remove created target file "%v", err=%v`, targetPath, removeErr)
+			}
+		}
+		log.FromContext(ctx).Error(err)
+		return nil, cerrors.NewChapiError(err)
+	}
+
+	// Success!
+	return &model.BlockPublish{
+		SerialNumber: device.SerialNumber,
+		TargetPath:   targetPath,
+		DevicePath:   devicePath,
+		Major:        node.major,
+		Minor:        node.minor,
+		ReadOnly:     readOnly,
+	}, nil
+}
+
+// deleteBlockPublish unmounts the device node from the target file and removes the file
+func (mounter *Mounter) deleteBlockPublish(ctx context.Context, device *model.Device, targetPath string) error {
+	log.FromContext(ctx).Tracef(`>>>>> deleteBlockPublish, targetPath="%v"`, targetPath)
+	defer log.FromContext(ctx).Trace("<<<<< deleteBlockPublish")
+
+	nodes, err := getPublishNodes(device.Pathname)
+	if err != nil {
+		return cerrors.NewChapiError(err)
+	}
+	mountTable, err := getMountTable()
+	if err != nil {
+		return cerrors.NewChapiError(err)
+	}
+
+	isPublished := false
+	for _, publish := range findBlockPublishes(device.SerialNumber, nodes, mountTable) {
+		if publish.TargetPath == targetPath {
+			isPublished = true
+			break
+		}
+	}
+	if !isPublished {
+		// Fail the request if something else is mounted at the target, else there is nothing to
+		// unmount and only a target file left behind by an interrupted publish is removed
+		for _, entry := range mountTable {
+			if entry.mountPoint == targetPath {
+				err = cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageTargetNotPublished, targetPath)
+				log.FromContext(ctx).Error(err)
+				return err
+			}
+		}
+		if info, statErr := os.Stat(targetPath); statErr == nil && info.Mode().IsRegular() {
+			if removeErr := os.Remove(targetPath); removeErr != nil {
+				log.FromContext(ctx).Errorf(`Failed to remove target file "%v", err=%v`, targetPath, removeErr)
+			}
+		}
+		log.FromContext(ctx).Tracef(`%v not published at "%v"`, device.SerialNumber, targetPath)
+		return nil
+	}
+
+	// Unmount the device node, without forcing it, so a target in use is left intact
+	err = unix.Unmount(targetPath, 0)
+	if err == unix.EBUSY {
+		err = cerrors.NewChapiErrorf(cerrors.PermissionDenied, errorMessageMountPointBusy, targetPath)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+	if err != nil {
+		log.FromContext(ctx).Errorf(`Unable to unmount "%v", err=%v`, targetPath, err)
+		return cerrors.NewChapiError(err)
+	}
+
+	// The publish was removed, we clean up after ourselves by removing the target file
+	if removeErr := os.Remove(targetPath); removeErr != nil {
+		log.FromContext(ctx).Errorf(`Failed to remove target file "%v", err=%v`, targetPath, removeErr)
+	}
+	return nil
+}
+
+// getPublishNodes returns the device nodes of the given device mapper device that can be
+// published, the opened LUKS mapping first if any, then the device itself
+func getPublishNodes(dmName string) ([]*publishNode, error) {
+	var dmNames []string
+	holders, err := sysfs.GetHolders(dmName)
+	if err != nil {
+		return nil, err
+	}
+	for _, holder := range holders {
+		if strings.HasPrefix(holder.UUID, sysfs.CryptUUIDPrefix) {
+			dmNames = append(dmNames, holder.DmName)
+		}
+	}
+	dmNames = append(dmNames, dmName)
+
+	var nodes []*publishNode
+	for _, name := range dmNames {
+		node := &publishNode{dmName: name}
+		if node.name, err = sysfs.ReadFile(name, "dm", "name"); err != nil {
+			return nil, err
+		}
+		// e.g. "253:3"
+		dev, err := sysfs.ReadFile(name, "dev")
+		if err != nil {
+			return nil, err
+		}
+		var major, minor uint64
+		if major, minor, err = parseDeviceNumber(dev); err != nil {
+			return nil, err
+		}
+		node.major, node.minor = uint32(major), uint32(minor)
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// parseDeviceNumber parses the "major:minor" device number of a sysfs dev file
+func parseDeviceNumber(dev string) (uint64, uint64, error) {
+	fields := strings.Split(dev, ":")
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("invalid device number %q", dev)
+	}
+	major, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	minor, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return major, minor, nil
+}
+
+// findBlockPublishes returns the mount table entries whose mount point is one of the given device
+// nodes.  A bind mounted device node keeps the file system of the /dev directory it comes from.
+func findBlockPublishes(serialNumber string, nodes []*publishNode, mountTable []*mountEntry) []*model.BlockPublish {
+	var publishes []*model.BlockPublish
+	for _, entry := range mountTable {
+		if entry.fsType != "devtmpfs" && entry.fsType != "tmpfs" {
+			continue
+		}
+		major, minor, isBlockDevice := statDeviceNode(entry.mountPoint)
+		if !isBlockDevice {
+			continue
+		}
+		for _, node := range nodes {
+			if node.major == major && node.minor == minor {
+				publishes = append(publishes, &model.BlockPublish{
+					SerialNumber: serialNumber,
+					TargetPath:   entry.mountPoint,
+					DevicePath:   sysfs.DevMapperPath + node.name,
+					Major:        major,
+					Minor:        minor,
+					ReadOnly:     hasMountOption(entry.options, "ro"),
+				})
+				break
+			}
+		}
+	}
+	return publishes
+}
+
+// verifyBlockPublish makes sure the target is the published device node
+func verifyBlockPublish(targetPath string, node *publishNode) error {
+	major, minor, isBlockDevice := statDeviceNode(targetPath)
+	if !isBlockDevice || major != node.major || minor != node.minor {
+		return cerrors.NewChapiErrorf(cerrors.Internal, errorMessagePublishMismatch, targetPath, major, minor, node.major, node.minor)
+	}
+	return nil
+}
+
+// statDeviceNode returns the device number of a block device node
+func statDeviceNode(path string) (uint32, uint32, bool) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil || stat.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return 0, 0, false
+	}
+	return unix.Major(uint64(stat.Rdev)), unix.Minor(uint64(stat.Rdev)), true
+}
+
+// hasMountOption returns true if the mount options include the given option
+func hasMountOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// validateMount validates that the Mount object was initialized properly.  The Mount object has
+// some private Linux properties that were populated during the getMounts() routine.
+func validateMount(mount *model.Mount) error {
+	if (mount == nil) || (mount.Private == nil) || (mount.Private.DevicePath == "") {
+		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageInvalidInputParameter)
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// isEmptyDirectory takes the given directory path and returns true if the directory is empty else
+// false is returned.  If the path is invalid / inaccessible, an error is returned.
+func isEmptyDirectory(accessPath string) (bool, error) {
+	f, err := os.Open(accessPath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// If Readdirnames(1) fails with io.EOF, we know that the directory is empty
+	_, err = f.Readdirnames(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
+
 // isSamePathName returns true if the two provided directory paths are equal else false.  Under
 // Linux we perform a case sensitive comparison.  Under Windows, it's case insensitive.  This
 // routine assumes that the caller (likely platform independent caller) has already retrieved the
@@ -0,0 +1,299 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package mount
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/chapi2/sysfs"
+	"github.com/hpe-storage/common-host-libs/chapi2/sysfs/sysfstest"
+)
+
+const testMountTable = `/dev/mapper/centos-root / xfs rw,relatime,attr2 0 0
+/dev/mapper/mpathb1 /mnt/vol\0401 xfs rw,relatime 0 0
+/dev/mapper/mpathb1 /var/lib/kubelet/pods/abc ext4 rw 0 0
+/dev/dm-7 /mnt/secure ext4 ro,noatime 0 0
+`
+
+// writeTestFile creates a file, and its parent directories, below root
+func writeTestFile(t *testing.T, root string, name string, value string) {
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(value), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useFakeHost points sysfs.SysBlockPath and procMountsPath to a fake sysfs tree and mount table.  The
+// dm-3 multipath device has two partitions, the second one is encrypted with LUKS.  The dm-4
+// multipath device is used by LVM and dm-9 has a file system on the whole device.
+func useFakeHost(t *testing.T) {
+	root := t.TempDir()
+	sysBlock := filepath.Join(root, "sys")
+	sysfstest.AddDmDevice(t, sysBlock, "dm-3", "mpathb", "mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1", "dm-5", "dm-6")
+	sysfstest.AddDmDevice(t, sysBlock, "dm-5", "mpathb1", "part1-mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1")
+	sysfstest.AddDmDevice(t, sysBlock, "dm-6", "mpathb2", "part2-mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1", "dm-7")
+	sysfstest.AddDmDevice(t, sysBlock, "dm-7", "luks-vol1", "CRYPT-LUKS2-5a8c7e2c8f1d4e4b9d1c4f7b2e3a6d90-luks-vol1")
+	sysfstest.AddDmDevice(t, sysBlock, "dm-4", "mpathc", "mpath-2aa3c1a4b2e8d9b06c9ce900d2a3c5e1", "dm-8")
+	sysfstest.AddDmDevice(t, sysBlock, "dm-8", "vg-lv", "LVM-x9Tz")
+	sysfstest.AddDmDevice(t, sysBlock, "dm-9", "mpathd", "mpath-2bb3c1a4b2e8d9b06c9ce900d2a3c5e1")
+	sysfstest.UseSysBlock(t, sysBlock)
+	writeTestFile(t, root, "mounts", testMountTable)
+
+	previousProcMountsPath := procMountsPath
+	procMountsPath = filepath.Join(root, "mounts")
+	t.Cleanup(func() { procMountsPath = previousProcMountsPath })
+}
+
+func TestGetDeviceMountPoints(t *testing.T) {
+	useFakeHost(t)
+	mountTable, err := getMountTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device := &model.Device{SerialNumber: "f6d3c1a4b2e8d9b06c9ce900d2a3c5e1", Pathname: "dm-3"}
+	mounts := getDeviceMountPoints(device, mountTable, true, false)
+	expected := []*model.Mount{
+		{
+			ID:           getMountPointID(device.SerialNumber, 1, "part1-mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1"),
+			MountPoint:   "/mnt/vol 1",
+			SerialNumber: device.SerialNumber,
+			FsOpts:       &model.FileSystemOptions{FsType: "xfs", MountOpts: []string{"rw", "relatime"}},
+			Private:      &model.MountPrivate{DevicePath: "/dev/mapper/mpathb1", DmName: "dm-5", Partition: 1},
+		},
+		{
+			ID:           getMountPointID(device.SerialNumber, 2, "CRYPT-LUKS2-5a8c7e2c8f1d4e4b9d1c4f7b2e3a6d90-luks-vol1"),
+			MountPoint:   "/mnt/secure",
+			SerialNumber: device.SerialNumber,
+			FsOpts:       &model.FileSystemOptions{FsType: "ext4", MountOpts: []string{"ro", "noatime"}},
+			Private:      &model.MountPrivate{DevicePath: "/dev/mapper/luks-vol1", DmName: "dm-7", Partition: 2},
+		},
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("unexpected mounts %+v", mounts)
+	}
+
+	// Only the ID and private data without all details
+	mounts = getDeviceMountPoints(device, mountTable, false, true)
+	if len(mounts) != 2 || mounts[0].MountPoint != "" || mounts[0].SerialNumber != "" || mounts[0].ID != expected[0].ID {
+		t.Errorf("unexpected mounts %+v", mounts)
+	}
+
+	// A device used by LVM has no mountable block device
+	if mounts = getDeviceMountPoints(&model.Device{SerialNumber: "aa3c1a4b2e8d9b06c9ce900d2a3c5e1", Pathname: "dm-4"}, mountTable, true, false); len(mounts) != 0 {
+		t.Errorf("unexpected mounts %+v", mounts)
+	}
+
+	// A file system on the whole device, reported when not mounted only if requested
+	device = &model.Device{SerialNumber: "bb3c1a4b2e8d9b06c9ce900d2a3c5e1", Pathname: "dm-9"}
+	if mounts = getDeviceMountPoints(device, mountTable, true, true); len(mounts) != 0 {
+		t.Errorf("unexpected mounts %+v", mounts)
+	}
+	mounts = getDeviceMountPoints(device, mountTable, true, false)
+	if len(mounts) != 1 || mounts[0].MountPoint != "" || mounts[0].Private.DevicePath != "/dev/mapper/mpathd" {
+		t.Errorf("unexpected mounts %+v", mounts)
+	}
+}
+
+func TestGetMountPointID(t *testing.T) {
+	uuid := "part1-mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1"
+	id := getMountPointID("f6d3c1a4b2e8d9b06c9ce900d2a3c5e1", 1, uuid)
+	if id != getMountPointID("f6d3c1a4b2e8d9b06c9ce900d2a3c5e1", 1, uuid) {
+		t.Error("mount point ID is not stable")
+	}
+	if id == getMountPointID("f6d3c1a4b2e8d9b06c9ce900d2a3c5e1", 2, uuid) {
+		t.Error("mount point ID does not depend on the partition")
+	}
+	if id[len(id)-2:] != "-1" {
+		t.Errorf("mount point ID %v does not end with the partition number", id)
+	}
+}
+
+func TestCreateMountValidation(t *testing.T) {
+	useFakeHost(t)
+	mounter := &Mounter{}
+	mount := &model.Mount{Private: &model.MountPrivate{DevicePath: "/dev/mapper/mpathd"}}
+
+	// Invalid mount object
+	err := mounter.createMount(context.Background(), &model.Mount{}, "/mnt/new", nil)
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// Mount point already in use
+	err = mounter.createMount(context.Background(), mount, "/mnt/secure", nil)
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.AlreadyExists {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// Mount point directory not empty
+	directory := t.TempDir()
+	writeTestFile(t, directory, "file", "data")
+	err = mounter.createMount(context.Background(), mount, directory, nil)
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.AlreadyExists {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestExpandFileSystemValidation(t *testing.T) {
+	mounter := &Mounter{}
+
+	// Invalid mount object
+	err := mounter.expandFileSystem(context.Background(), &model.Mount{})
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// File systems that cannot be grown online
+	for _, fsOptions := range []*model.FileSystemOptions{nil, {FsType: "vfat"}, {FsType: "nfs"}} {
+		mount := &model.Mount{MountPoint: "/mnt/vol1", FsOpts: fsOptions, Private: &model.MountPrivate{DevicePath: "/dev/mapper/mpathd"}}
+		err = mounter.expandFileSystem(context.Background(), mount)
+		if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+			t.Errorf("unexpected error %v for %+v", err, fsOptions)
+		}
+	}
+
+	// A file system on a partition, or on a LUKS mapping of one, is refused
+	mount := &model.Mount{MountPoint: "/mnt/vol1", FsOpts: &model.FileSystemOptions{FsType: "xfs"}, Private: &model.MountPrivate{DevicePath: "/dev/mapper/mpathb1", Partition: 1}}
+	err = mounter.expandFileSystem(context.Background(), mount)
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.Unimplemented {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// A serial number is required
+	_, err = mounter.ExpandFileSystems(context.Background(), "")
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestInterpretExitCode(t *testing.T) {
+	failed := errors.New("exit status")
+	tests := []struct {
+		fsType      string
+		repair      bool
+		exitCode    int
+		err         error
+		errorsFound bool
+		repaired    bool
+		fail        bool
+	}{
+		{"ext4", false, 0, nil, false, false, false},
+		{"ext4", false, 4, failed, true, false, false},
+		{"ext4", false, 8, failed, false, false, true},
+		{"ext4", true, 1, failed, true, true, false},
+		{"ext4", true, 4, failed, true, false, false},
+		{"ext4", true, 8, failed, false, false, true},
+		{"xfs", false, 1, failed, true, false, false},
+		{"xfs", true, 0, nil, true, true, false},
+		{"xfs", true, 2, failed, false, false, true},
+		{"btrfs", false, 1, failed, true, false, false},
+		{"btrfs", false, 888, failed, false, false, true},
+	}
+	for _, test := range tests {
+		check := &model.FileSystemCheck{FsType: test.fsType, Repair: test.repair, ExitCode: test.exitCode}
+		err := fileSystemTools[test.fsType].interpretExitCode(check, test.err)
+		if (err != nil) != test.fail || check.ErrorsFound != test.errorsFound || check.Repaired != test.repaired {
+			t.Errorf("unexpected result %+v, err=%v for %+v", check, err, test)
+		}
+	}
+
+	// btrfs is only checked, its repair is left to an administrator
+	if _, err := fileSystemTools["btrfs"].commandArgs("btrfs", true); err == nil || err.(*cerrors.ChapiError).Code != cerrors.Unimplemented {
+		t.Errorf("unexpected btrfs repair error %v", err)
+	}
+	if args, err := fileSystemTools["xfs"].commandArgs("xfs", true); err != nil || len(args) != 0 {
+		t.Errorf("unexpected xfs repair args %v, err=%v", args, err)
+	}
+
+	// A serial number is required
+	mounter := &Mounter{}
+	for _, check := range []func(context.Context, string) (*model.FileSystemCheck, error){mounter.CheckFileSystem, mounter.RepairFileSystem} {
+		_, err := check(context.Background(), "")
+		if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+}
+
+func TestUnescapeMountField(t *testing.T) {
+	tests := map[string]string{
+		`/mnt/vol1`:         "/mnt/vol1",
+		`/mnt/vol\0401`:     "/mnt/vol 1",
+		`/mnt/a\011b\134c`:  "/mnt/a\tb\\c",
+		`/mnt/trailing\04`:  `/mnt/trailing\04`,
+		`/mnt/not\999octal`: `/mnt/not\999octal`,
+	}
+	for field, expected := range tests {
+		if unescaped := unescapeMountField(field); unescaped != expected {
+			t.Errorf("unescapeMountField(%q) = %q, expected %q", field, unescaped, expected)
+		}
+	}
+}
+
+func TestBlockPublishHelpers(t *testing.T) {
+	useFakeHost(t)
+	sysfstest.WriteFile(t, sysfs.SysBlockPath, "dm-6/dev", "253:6")
+	sysfstest.WriteFile(t, sysfs.SysBlockPath, "dm-7/dev", "253:7")
+	sysfstest.WriteFile(t, sysfs.SysBlockPath, "dm-9/dev", "253:9")
+
+	// The opened LUKS mapping is published before the device itself
+	nodes, err := getPublishNodes("dm-6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*publishNode{
+		{dmName: "dm-7", name: "luks-vol1", major: 253, minor: 7},
+		{dmName: "dm-6", name: "mpathb2", major: 253, minor: 6},
+	}
+	if !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("unexpected publish nodes %+v", nodes)
+	}
+	if nodes, err = getPublishNodes("dm-9"); err != nil || len(nodes) != 1 || nodes[0].name != "mpathd" {
+		t.Errorf("unexpected publish nodes %+v, err=%v", nodes, err)
+	}
+
+	// A regular file bind mounted from /dev is not a block publish
+	target := filepath.Join(t.TempDir(), "target")
+	writeTestFile(t, filepath.Dir(target), "target", "")
+	mountTable := []*mountEntry{{device: "devtmpfs", mountPoint: target, fsType: "devtmpfs", options: []string{"rw"}}}
+	if publishes := findBlockPublishes("bb3c1a4b2e8d9b06c9ce900d2a3c5e1", nodes, mountTable); len(publishes) != 0 {
+		t.Errorf("unexpected publishes %+v", publishes)
+	}
+
+	for _, dev := range []string{"253", "253:x", "a:1", ""} {
+		if _, _, err = parseDeviceNumber(dev); err == nil {
+			t.Errorf("expected an error for device number %q", dev)
+		}
+	}
+}
+
+func TestValidateTargetPath(t *testing.T) {
+	if targetPath, err := validateTargetPath("/var/lib/publish/../pvc-1/pod-1"); err != nil || targetPath != "/var/lib/pvc-1/pod-1" {
+		t.Errorf("unexpected target path %v, err=%v", targetPath, err)
+	}
+	for _, targetPath := range []string{"", "pvc-1/pod-1"} {
+		_, err := validateTargetPath(targetPath)
+		if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+			t.Errorf("unexpected error %v for %q", err, targetPath)
+		}
+	}
+
+	// A serial number is required
+	mounter := &Mounter{}
+	_, err := mounter.CreateBlockPublish(context.Background(), "", &model.BlockPublishRequest{TargetPath: "/var/lib/pvc-1/pod-1"})
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -3,6 +3,7 @@
 package mount
 
 import (
+	"context"
 	"fmt"
 	"hash/fnv"
 	"io"
@@ -19,6 +20,10 @@ import (
 
 const (
 	PARTITION_BASIC_DATA_GUID = "{ebd0a0a2-b9e5-4433-87c0-68b6b72699c7}"
+
+	errorMessageCheckNotYetImplemented   = "file system check and repair not yet implemented on windows"
+	errorMessageExpandNotYetImplemented  = "file system expansion not yet implemented on windows"
+	errorMessagePublishNotYetImplemented = "block device publishing not yet implemented on windows"
 )
 
 // getMounts enumerates the mountpoints for the given device / mount point.  The following input
@@ -41,14 +46,14 @@ const (
 // dismounted objects are returned.  Being able to enumerate Mount objects that are not mounted is
 // important because it provides details about the potential mount point.  For example, under
 // Windows, this includes disk and partition details that are needed in order to mount a volume.
-func (mounter *Mounter) getMounts(serialNumber string, mountId string, allDetails bool, onlyMounted bool) ([]*model.Mount, error) {
-	log.Tracef(">>>>> getMounts, serialNumber=%v, mountId=%v, allDetails=%v, onlyMounted=%v", serialNumber, mountId, allDetails, onlyMounted)
-	defer log.Trace("<<<<< getMounts")
+func (mounter *Mounter) getMounts(ctx context.Context, serialNumber string, mountId string, allDetails bool, onlyMounted bool) ([]*model.Mount, error) {
+	log.FromContext(ctx).Tracef(">>>>> getMounts, serialNumber=%v, mountId=%v, allDetails=%v, onlyMounted=%v", serialNumber, mountId, allDetails, onlyMounted)
+	defer log.FromContext(ctx).Trace("<<<<< getMounts")
 
 	// Fail request if our Mounter object was not initialized properly
 	if mounter.multipathPlugin == nil {
 		err := cerrors.NewChapiError(cerrors.Internal, errorMessageMultipathPluginNotSet)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, err
 	}
 
@@ -56,12 +61,12 @@ func (mounter *Mounter) getMounts(serialNumber string, mountId string, allDetail
 	// and recommend the caller use Option #4 instead.  This will reduce the amount of enumeration
 	// required by this routine.  The routine will, however, continue to function.
 	if serialNumber == "" && mountId != "" {
-		log.Errorf("No serial number provided with mountId=%v.  A serial number is recommended to reduce the amount of enumeration this routine requires.", mountId)
+		log.FromContext(ctx).Errorf("No serial number provided with mountId=%v.  A serial number is recommended to reduce the amount of enumeration this routine requires.", mountId)
 	}
 
 	// Enumerate the Nimble device(s) on this host for the given serial number (or all Nimble
 	// devices if serialNumber is empty)
-	devices, err := mounter.enumerateDevices(serialNumber, allDetails)
+	devices, err := mounter.enumerateDevices(ctx, serialNumber, allDetails)
 	if err != nil {
 		return nil, err
 	}
@@ -71,12 +76,12 @@ func (mounter *Mounter) getMounts(serialNumber string, mountId string, allDetail
 
 	// Loop through each enumerated Nimble device
 	for _, device := range devices {
-		log.Tracef("Checking serial number %v, disk number %v, for mount points", device.SerialNumber, device.Private.WindowsDisk.Number)
+		log.FromContext(ctx).Tracef("Checking serial number %v, disk number %v, for mount points", device.SerialNumber, device.Private.WindowsDisk.Number)
 
 		// Enumerate all the partitions on this Nimble device
 		partitions, err := wmi.GetMSFTPartitionForDiskNumber(device.Private.WindowsDisk.Number)
 		if err != nil {
-			log.Errorf("Skipping device's partitions, err=%v", err)
+			log.FromContext(ctx).Errorf("Skipping device's partitions, err=%v", err)
 			continue
 		}
 
@@ -92,7 +97,7 @@ func (mounter *Mounter) getMounts(serialNumber string, mountId string, allDetail
 			// If we were passed in a mount point ID as input, and the ID does not match, skip
 			// this mount point ID.
 			if (mountId != "") && (mountId != mountPoint.ID) {
-				log.Tracef("Skipping mount point ID %v, does not match requested ID %v", mountPoint.ID, mountId)
+				log.FromContext(ctx).Tracef("Skipping mount point ID %v, does not match requested ID %v", mountPoint.ID, mountId)
 				continue
 			}
 
@@ -229,9 +234,9 @@ func logPartitionDetails(partition *wmi.MSFT_Partition, indent int) {
 }
 
 // createMount is called to mount the given device to the given mount point
-func (mounter *Mounter) createMount(mount *model.Mount, mountPoint string, fsOptions *model.FileSystemOptions) error {
-	log.Tracef(`>>>>> createMount, mountPoint="%v", fsOptions=%v`, mountPoint, fsOptions)
-	defer log.Trace("<<<<< createMount")
+func (mounter *Mounter) createMount(ctx context.Context, mount *model.Mount, mountPoint string, fsOptions *model.FileSystemOptions) error {
+	log.FromContext(ctx).Tracef(`>>>>> createMount, mountPoint="%v", fsOptions=%v`, mountPoint, fsOptions)
+	defer log.FromContext(ctx).Trace("<<<<< createMount")
 
 	// TODO - How is fsOptions going to be used under Windows?
 
@@ -241,7 +246,7 @@ func (mounter *Mounter) createMount(mount *model.Mount, mountPoint string, fsOpt
 	}
 
 	// Now that we validated the mount object, log details about the create mount request
-	log.Tracef("SerialNumber=%v, PathName=%v, IsOffline=%v, IsReadOnly=%v",
+	log.FromContext(ctx).Tracef("SerialNumber=%v, PathName=%v, IsOffline=%v, IsReadOnly=%v",
 		mount.SerialNumber, mount.Private.WindowsDisk.Path, mount.Private.WindowsDisk.IsOffline, mount.Private.WindowsDisk.IsReadOnly)
 
 	// If the disk is offline, or read only, we first need to online the disk and/or make it writable
@@ -252,7 +257,7 @@ func (mounter *Mounter) createMount(mount *model.Mount, mountPoint string, fsOpt
 
 		// Now that the disk is online and writable, re-enumerate the device's mount point.  We need
 		// to do this because the mount point data wasn't enumerable if the disk was offline.
-		newMount, alreadyMounted, err := mounter.getMountForCreate(mount.SerialNumber, mountPoint)
+		newMount, alreadyMounted, err := mounter.getMountForCreate(ctx, mount.SerialNumber, mountPoint)
 		if err != nil {
 			return err
 		}
@@ -274,12 +279,12 @@ func (mounter *Mounter) createMount(mount *model.Mount, mountPoint string, fsOpt
 		isDirectoryExists = true
 		isDirectoryEmpty, _ = isEmptyDirectory(mountPoint)
 	}
-	log.Tracef("Mount point details, isDriveLetterMount=%v, isDirectoryExists=%v, isDirectoryEmpty=%v", isDriveLetterMount, isDirectoryExists, isDirectoryEmpty)
+	log.FromContext(ctx).Tracef("Mount point details, isDriveLetterMount=%v, isDirectoryExists=%v, isDirectoryEmpty=%v", isDriveLetterMount, isDirectoryExists, isDirectoryEmpty)
 
 	// If it's a drive letter mount, and the drive letter already exists, fail the request
 	if isDriveLetterMount && isDirectoryExists {
 		err := cerrors.NewChapiErrorf(cerrors.AlreadyExists, errorMessageMountPointInUse, mountPoint)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return err
 	}
 
@@ -287,14 +292,14 @@ func (mounter *Mounter) createMount(mount *model.Mount, mountPoint string, fsOpt
 	// the request.  You can only set a mountpoint to an empty directory.
 	if !isDriveLetterMount && isDirectoryExists && !isDirectoryEmpty {
 		err := cerrors.NewChapiErrorf(cerrors.AlreadyExists, errorMessageMountPointNotEmpty, mountPoint)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return err
 	}
 
 	// If we're mounting to a directory, adjust mount point path with absolute path if necessary
 	if !isDriveLetterMount && (mountPoint != "") {
 		if absPath, err := filepath.Abs(mountPoint); (err == nil) && (absPath != mountPoint) {
-			log.Tracef(`Adjusting requested mount point path "%v" with absolute path "%v"`, mountPoint, absPath)
+			log.FromContext(ctx).Tracef(`Adjusting requested mount point path "%v" with absolute path "%v"`, mountPoint, absPath)
 			mountPoint = absPath
 		}
 	}
@@ -304,11 +309,11 @@ func (mounter *Mounter) createMount(mount *model.Mount, mountPoint string, fsOpt
 	createdMountDirectory := false
 	if !isDriveLetterMount && !isDirectoryExists {
 		if err := os.MkdirAll(mountPoint, os.ModePerm); err != nil {
-			log.Error(err)
+			log.FromContext(ctx).Error(err)
 			return err
 		}
 		createdMountDirectory = true
-		log.Tracef(`Created mount point directory "%v"`, mountPoint)
+		log.FromContext(ctx).Tracef(`Created mount point directory "%v"`, mountPoint)
 	}
 
 	// Mount the device/partition to the specified mount point
@@ -317,7 +322,7 @@ func (mounter *Mounter) createMount(mount *model.Mount, mountPoint string, fsOpt
 			// If we created an empty directory, to mount the Nimble volume, perform error cleanup
 			// by removing the folder before returning
 			if errRemove := os.Remove(mountPoint); errRemove != nil {
-				log.Errorf(`Unable to remove created directory, directory="%v", err=%v`, mountPoint, errRemove)
+				log.FromContext(ctx).Errorf(`Unable to remove created directory, directory="%v", err=%v`, mountPoint, errRemove)
 			}
 		}
 		return err
@@ -328,9 +333,9 @@ func (mounter *Mounter) createMount(mount *model.Mount, mountPoint string, fsOpt
 }
 
 // deleteMount is called to unmount the given mount point ID
-func (mounter *Mounter) deleteMount(mount *model.Mount) error {
-	log.Trace(">>>>> deleteMount")
-	defer log.Trace("<<<<< deleteMount")
+func (mounter *Mounter) deleteMount(ctx context.Context, mount *model.Mount) error {
+	log.FromContext(ctx).Trace(">>>>> deleteMount")
+	defer log.FromContext(ctx).Trace("<<<<< deleteMount")
 
 	// Validate the Mount object
 	if err := validateMount(mount); err != nil {
@@ -345,12 +350,12 @@ func (mounter *Mounter) deleteMount(mount *model.Mount) error {
 	mountPointPaths := getMountPointPaths(mount.Private.WindowsPartition.AccessPaths)
 	if len(mountPointPaths) > 1 {
 		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMultipleMountPointsDetected)
-		log.Errorf("Multiple paths detected, paths=%v, err=%v", strings.Join(mountPointPaths, ","), err)
+		log.FromContext(ctx).Errorf("Multiple paths detected, paths=%v, err=%v", strings.Join(mountPointPaths, ","), err)
 		return err
 	}
 
 	// Now that we validated the mount object, log details about the delete mount request
-	log.Tracef("SerialNumber=%v, PathName=%v, IsOffline=%v, IsReadOnly=%v",
+	log.FromContext(ctx).Tracef("SerialNumber=%v, PathName=%v, IsOffline=%v, IsReadOnly=%v",
 		mount.SerialNumber, mount.Private.WindowsDisk.Path, mount.Private.WindowsDisk.IsOffline, mount.Private.WindowsDisk.IsReadOnly)
 
 	// Unmount the device/partition from the specified mount point
@@ -359,12 +364,12 @@ func (mounter *Mounter) deleteMount(mount *model.Mount) error {
 	// If the mount point was removed, and we were mounted to an empty directory, we clean up after
 	// ourselves by removing the empty directory.
 	if (err == nil) && !isWindowsDriveLetterPath(mount.MountPoint) {
-		log.Tracef(`Removing "%v" directory`, mount.MountPoint)
+		log.FromContext(ctx).Tracef(`Removing "%v" directory`, mount.MountPoint)
 		if removeErr := os.Remove(mount.MountPoint); removeErr != nil {
 			// If we were able to remove the mount point, but unable to remove the empty directory,
 			// we'll simply log it as an error but not return the error to the caller.  From the
 			// caller's perspective, we were able to delete the mount point.
-			log.Errorf("Failed to remove mount point directory, err=%v", err)
+			log.FromContext(ctx).Errorf("Failed to remove mount point directory, err=%v", err)
 		}
 	}
 
@@ -372,6 +377,31 @@ func (mounter *Mounter) deleteMount(mount *model.Mount) error {
 	return err
 }
 
+// expandFileSystem is not yet implemented on Windows
+func (mounter *Mounter) expandFileSystem(ctx context.Context, mount *model.Mount) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageExpandNotYetImplemented)
+}
+
+// checkFileSystem is not yet implemented on Windows
+func (mounter *Mounter) checkFileSystem(ctx context.Context, mount *model.Mount, repair bool) (*model.FileSystemCheck, error) {
+	return nil, cerrors.NewChapiError(cerrors.Unimplemented, errorMessageCheckNotYetImplemented)
+}
+
+// getBlockPublishes is not yet implemented on Windows
+func (mounter *Mounter) getBlockPublishes(ctx context.Context, device *model.Device) ([]*model.BlockPublish, error) {
+	return nil, cerrors.NewChapiError(cerrors.Unimplemented, errorMessagePublishNotYetImplemented)
+}
+
+// createBlockPublish is not yet implemented on Windows
+func (mounter *Mounter) createBlockPublish(ctx context.Context, device *model.Device, targetPath string, readOnly bool) (*model.BlockPublish, error) {
+	return nil, cerrors.NewChapiError(cerrors.Unimplemented, errorMessagePublishNotYetImplemented)
+}
+
+// deleteBlockPublish is not yet implemented on Windows
+func (mounter *Mounter) deleteBlockPublish(ctx context.Context, device *model.Device, targetPath string) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessagePublishNotYetImplemented)
+}
+
 // validateMount validates that the Mount object was initialized properly.  The Mount object has
 // some private Windows properties that were populated during the getMounts() routine.  The Windows
 // properties should *always* be available.  Adding a routine to validate that the properties were
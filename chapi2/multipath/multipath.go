@@ -3,6 +3,7 @@
 package multipath
 
 import (
+	"context"
 	"strings"
 	"sync"
 
@@ -41,8 +42,8 @@ func NewMultipathPlugin() *MultipathPlugin {
 
 // GetDevices enumerates all the Nimble volumes while only providing basic details (e.g. serial number).
 // If a "serialNumber" is passed in, only that specific serial number is enumerated.
-func (plugin *MultipathPlugin) GetDevices(serialNumber string) ([]*model.Device, error) {
-	devices, err := plugin.getDevices(serialNumber)
+func (plugin *MultipathPlugin) GetDevices(ctx context.Context, serialNumber string) ([]*model.Device, error) {
+	devices, err := plugin.getDevices(ctx, serialNumber)
 	if err != nil {
 		return nil, err
 	}
@@ -51,8 +52,8 @@ func (plugin *MultipathPlugin) GetDevices(serialNumber string) ([]*model.Device,
 
 // GetAllDeviceDetails enumerates all the Nimble volumes while providing full details about the
 // device.  If a "serialNumber" is passed in, only that specific serial number is enumerated.
-func (plugin *MultipathPlugin) GetAllDeviceDetails(serialNumber string) ([]*model.Device, error) {
-	devices, err := plugin.getAllDeviceDetails(serialNumber)
+func (plugin *MultipathPlugin) GetAllDeviceDetails(ctx context.Context, serialNumber string) ([]*model.Device, error) {
+	devices, err := plugin.getAllDeviceDetails(ctx, serialNumber)
 	if err != nil {
 		return nil, err
 	}
@@ -60,8 +61,8 @@ func (plugin *MultipathPlugin) GetAllDeviceDetails(serialNumber string) ([]*mode
 }
 
 // GetPartitionInfo enumerates the partitions on the given volume
-func (plugin *MultipathPlugin) GetPartitionInfo(serialNumber string) ([]*model.DevicePartition, error) {
-	partitions, err := plugin.getPartitionInfo(serialNumber)
+func (plugin *MultipathPlugin) GetPartitionInfo(ctx context.Context, serialNumber string) ([]*model.DevicePartition, error) {
+	partitions, err := plugin.getPartitionInfo(ctx, serialNumber)
 	if err != nil {
 		return nil, err
 	}
@@ -69,27 +70,33 @@ func (plugin *MultipathPlugin) GetPartitionInfo(serialNumber string) ([]*model.D
 }
 
 // OfflineDevice is called to offline the given device
-func (plugin *MultipathPlugin) OfflineDevice(device model.Device) error {
-	return plugin.offlineDevice(device)
+func (plugin *MultipathPlugin) OfflineDevice(ctx context.Context, device model.Device) error {
+	return plugin.offlineDevice(ctx, device)
 }
 
 // CreateFileSystem is called to create a file system on the given device
-func (plugin *MultipathPlugin) CreateFileSystem(device model.Device, filesystem string) error {
-	return plugin.createFileSystem(device, filesystem)
+func (plugin *MultipathPlugin) CreateFileSystem(ctx context.Context, device model.Device, filesystem string) error {
+	return plugin.createFileSystem(ctx, device, filesystem)
+}
+
+// ExpandDevice is called to pick up the new capacity of the given device after the volume was
+// grown on the array.  Mounted file systems are not expanded by this routine.
+func (plugin *MultipathPlugin) ExpandDevice(ctx context.Context, device model.Device) error {
+	return plugin.expandDevice(ctx, device)
 }
 
 // AttachDevice attaches the given block device to this host.  If the device is successfully
 // attached, a model.Device object is returned for the attached device.
-func (plugin *MultipathPlugin) AttachDevice(serialNumber string, blockDev model.BlockDeviceAccessInfo) (device *model.Device, err error) {
-	log.Trace(">>>>> AttachDevice called")
-	defer log.Trace("<<<<< AttachDevice")
+func (plugin *MultipathPlugin) AttachDevice(ctx context.Context, serialNumber string, blockDev model.BlockDeviceAccessInfo) (device *model.Device, err error) {
+	log.FromContext(ctx).Trace(">>>>> AttachDevice called")
+	defer log.FromContext(ctx).Trace("<<<<< AttachDevice")
 
-	log.Infof("Attach device, serialNumber=%v, protocol=%v", serialNumber, blockDev.AccessProtocol)
+	log.FromContext(ctx).Infof("Attach device, serialNumber=%v, protocol=%v", serialNumber, blockDev.AccessProtocol)
 
 	// Fail request if no serial number provided
 	if serialNumber == "" {
 		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageSerialNumberNotProvided)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, err
 	}
 
@@ -97,12 +104,12 @@ func (plugin *MultipathPlugin) AttachDevice(serialNumber string, blockDev model.
 	// ensure the target is logged in.  Any other AccessProtocol is invalid and unsupported.
 	switch blockDev.AccessProtocol {
 	case model.AccessProtocolFC:
-		err = fc.NewFcPlugin().RescanFcTarget(blockDev.LunID)
+		err = fc.NewFcPlugin().RescanFcTarget(ctx, blockDev.LunID)
 	case model.AccessProtocolIscsi:
-		err = iscsi.NewIscsiPlugin().LoginTarget(blockDev)
+		err = iscsi.NewIscsiPlugin().LoginTarget(ctx, blockDev)
 	default:
 		err = cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageInvalidAccessProtocol, blockDev.AccessProtocol)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 	}
 
 	// Exit if FC rescan or iSCSI login failure
@@ -112,7 +119,7 @@ func (plugin *MultipathPlugin) AttachDevice(serialNumber string, blockDev model.
 
 	// Enumerate the device with the provided serial number
 	var devices []*model.Device
-	devices, err = plugin.GetAllDeviceDetails(serialNumber)
+	devices, err = plugin.GetAllDeviceDetails(ctx, serialNumber)
 	if err != nil {
 		return nil, err
 	}
@@ -120,7 +127,7 @@ func (plugin *MultipathPlugin) AttachDevice(serialNumber string, blockDev model.
 	// If device was not found, fail the request
 	if len(devices) == 0 {
 		err = cerrors.NewChapiError(cerrors.NotFound, errorMessageDeviceNotFound)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, err
 	}
 
@@ -130,21 +137,21 @@ func (plugin *MultipathPlugin) AttachDevice(serialNumber string, blockDev model.
 }
 
 // DetachDevice detaches the given block device from this host.
-func (plugin *MultipathPlugin) DetachDevice(device model.Device) error {
-	log.Trace(">>>>> DetachDevice called")
-	defer log.Trace("<<<<< DetachDevice")
+func (plugin *MultipathPlugin) DetachDevice(ctx context.Context, device model.Device) error {
+	log.FromContext(ctx).Trace(">>>>> DetachDevice called")
+	defer log.FromContext(ctx).Trace("<<<<< DetachDevice")
 
-	log.Infof("Detach device, serialNumber=%v", device.SerialNumber)
+	log.FromContext(ctx).Infof("Detach device, serialNumber=%v", device.SerialNumber)
 
 	// Start by offlining the device on the host
-	if err := plugin.OfflineDevice(device); err != nil {
+	if err := plugin.OfflineDevice(ctx, device); err != nil {
 		return err
 	}
 
 	// If this is an iSCSI Volume Scoped Target (VST), logout iSCSI connections.  For all other
 	// target types (e.g. GST, FC), leave connections intact.
 	if (device.IscsiTarget != nil) && strings.EqualFold(device.IscsiTarget.TargetScope, model.TargetScopeVolume) {
-		if err := iscsi.NewIscsiPlugin().LogoutTarget(device.IscsiTarget.Name); err != nil {
+		if err := iscsi.NewIscsiPlugin().LogoutTarget(ctx, device.IscsiTarget.Name); err != nil {
 			return err
 		}
 	}
@@ -153,6 +160,21 @@ func (plugin *MultipathPlugin) DetachDevice(device model.Device) error {
 	return nil
 }
 
+// checkDuplicateSerialNumbers scans the array of CHAPI devices for any duplicate serial numbers.
+// If any are found, an error object is returned (e.g. misconfigured MPIO) else nil is returned.
+func (plugin *MultipathPlugin) checkDuplicateSerialNumbers(ctx context.Context, devices []*model.Device) error {
+	m := make(map[string]bool)
+	for _, device := range devices {
+		if m[device.SerialNumber] == true {
+			err := cerrors.NewChapiErrorf(cerrors.Internal, errorMessageMisconfiguredMultipathIO, device.SerialNumber)
+			log.FromContext(ctx).Error(err)
+			return err
+		}
+		m[device.SerialNumber] = true
+	}
+	return nil
+}
+
 // getTargetTypeCache returns the global TargetTypeCache object
 func getTargetTypeCache() *TargetTypeCache {
 	lock.Lock()
@@ -3,52 +3,412 @@
 package multipath
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
 	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/chapi2/sysfs"
+	"github.com/hpe-storage/common-host-libs/linux"
 	log "github.com/hpe-storage/common-host-libs/logger"
+	linuxmodel "github.com/hpe-storage/common-host-libs/model"
+	"github.com/hpe-storage/common-host-libs/util"
+)
+
+const (
+	dmPrefix        = "dm-"
+	mpathUUIDPrefix = "mpath-"
+	sectorSize      = 512
+
+	errorMessageDevicePathNotSet = "device path not set for serial number %v"
+	errorMessageMultipathResize  = "failed to resize multipath map %v, %v"
+	errorMessageNoPathsToRescan  = "no paths found for device %v"
+	errorMessagePartitioned      = "device %v has partitions %v, expanding a partitioned device is not supported"
 )
 
 // getDevices enumerates all the Nimble volumes while only providing basic details (e.g. serial number).
 // If a "serialNumber" is passed in, only that specific serial number is enumerated.
-// getDevices enumerates all the Nimble volumes while only providing basic details (e.g. serial number).
-// If a "serialNumber" is passed in, only that specific serial number is enumerated.
-func (plugin *MultipathPlugin) getDevices(serialNumber string) ([]*model.Device, error) {
-	log.Tracef(">>>>> getDevices, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< getDevices")
-	// TODO
-	return nil, nil
+func (plugin *MultipathPlugin) getDevices(ctx context.Context, serialNumber string) ([]*model.Device, error) {
+	log.FromContext(ctx).Tracef(">>>>> getDevices, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< getDevices")
+
+	// Enumerate the dm-multipath maps from sysfs (e.g. /sys/block/dm-3/dm/uuid)
+	dmPaths, err := filepath.Glob(filepath.Join(sysfs.SysBlockPath, dmPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []*model.Device
+	for _, dmPath := range dmPaths {
+		device, err := getDmDevice(dmPath)
+		if err != nil {
+			// Don't fail the enumeration because of a single device, the device could be in the
+			// process of being torn down
+			log.FromContext(ctx).Warnf("unable to enumerate %v, continue with other devices, err=%v", dmPath, err)
+			continue
+		}
+		if device == nil {
+			continue // not a multipath device (e.g. LVM or LUKS)
+		}
+		if serialNumber != "" && !strings.EqualFold(device.SerialNumber, serialNumber) {
+			continue
+		}
+		log.FromContext(ctx).Tracef("SerialNumber=%v, Pathname=%v, AltFullPathName=%v, Size=%v", device.SerialNumber, device.Pathname, device.AltFullPathName, device.Size)
+		devices = append(devices, device)
+	}
+
+	// Make sure duplicate serial numbers are not detected (e.g. misconfigured multipath)
+	if err = plugin.checkDuplicateSerialNumbers(ctx, devices); err != nil {
+		return nil, err
+	}
+
+	return devices, nil
 }
 
-// getDevices enumerates all the Nimble volumes while providing full details about the device.
-// If a "serialNumber" is passed in, only that specific serial number is enumerated.
-func (plugin *MultipathPlugin) getAllDeviceDetails(serialNumber string) ([]*model.Device, error) {
-	log.Trace(">>>>> getAllDeviceDetails")
-	defer log.Trace("<<<<< getAllDeviceDetails")
-	// TODO
-	return nil, nil
+// getAllDeviceDetails enumerates all the Nimble volumes while providing full details about the
+// device.  If a "serialNumber" is passed in, only that specific serial number is enumerated.
+func (plugin *MultipathPlugin) getAllDeviceDetails(ctx context.Context, serialNumber string) ([]*model.Device, error) {
+	log.FromContext(ctx).Trace(">>>>> getAllDeviceDetails")
+	defer log.FromContext(ctx).Trace("<<<<< getAllDeviceDetails")
+
+	// Enumerate the multipath devices along with all their paths (active or not)
+	linuxDevices, err := linux.GetLinuxDmDevices(false, &linuxmodel.Volume{SerialNumber: serialNumber})
+	if err != nil {
+		return nil, cerrors.NewChapiError(err)
+	}
+
+	// Loop through and create a fully populated array of model.Device objects
+	var devices []*model.Device
+	for deviceIndex, linuxDevice := range linuxDevices {
+		device := newDevice(linuxDevice)
+
+		// Is this an iSCSI volume?  If so, we want to populate the device iSCSI details.
+		if len(linuxDevice.IscsiTargets) != 0 {
+			device.IscsiTarget = plugin.getIscsiTarget(ctx, linuxDevice)
+		}
+
+		// Log the device details
+		log.FromContext(ctx).Tracef("Device %v, SerialNumber=%v, Pathname=%v, AltFullPathName=%v, Size=%v, State=%v",
+			deviceIndex, device.SerialNumber, device.Pathname, device.AltFullPathName, device.Size, device.State)
+		for _, path := range device.Private.Paths {
+			log.FromContext(ctx).Tracef("    Path  - %v (%v:%v), hcil=%v, state=%v", path.Name, path.Major, path.Minor, path.Hcils, path.State)
+		}
+
+		// If it's an iSCSI target, log the iSCSI details
+		if device.IscsiTarget != nil {
+			log.FromContext(ctx).Tracef("    IQN   - %v", device.IscsiTarget.Name)
+			log.FromContext(ctx).Tracef("    Scope - %v", device.IscsiTarget.TargetScope)
+			for _, targetPortal := range device.IscsiTarget.TargetPortals {
+				log.FromContext(ctx).Tracef("    Port  - %v:%v", targetPortal.Address, targetPortal.Port)
+			}
+		}
+
+		// Append the enumerated device to our return device array
+		devices = append(devices, device)
+	}
+
+	// Make sure duplicate serial numbers are not detected (e.g. misconfigured multipath)
+	if err = plugin.checkDuplicateSerialNumbers(ctx, devices); err != nil {
+		return nil, err
+	}
+
+	return devices, nil
 }
 
 // getPartitionInfo enumerates the partitions on the given volume
-func (plugin *MultipathPlugin) getPartitionInfo(serialNumber string) ([]*model.DevicePartition, error) {
-	log.Tracef(">>>>> getPartitionInfo, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< getPartitionInfo")
-	// TODO
-	return nil, nil
+func (plugin *MultipathPlugin) getPartitionInfo(ctx context.Context, serialNumber string) ([]*model.DevicePartition, error) {
+	log.FromContext(ctx).Tracef(">>>>> getPartitionInfo, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< getPartitionInfo")
+
+	// Enumerate the one serial number
+	device, err := plugin.getDevices(ctx, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail request if volume not found
+	if len(device) != 1 {
+		return nil, cerrors.NewChapiError(cerrors.NotFound, errorMessageDeviceNotFound)
+	}
+
+	// Enumerate the volume's partitions
+	linuxPartitions, err := linux.GetPartitionInfo(&linuxmodel.Device{
+		SerialNumber:    device[0].SerialNumber,
+		AltFullPathName: device[0].AltFullPathName,
+	})
+	if err != nil {
+		return nil, cerrors.NewChapiError(err)
+	}
+
+	// Convert []linuxmodel.DevicePartition into []*model.DevicePartition
+	var partitions []*model.DevicePartition
+	for _, linuxPartition := range linuxPartitions {
+		partition := &model.DevicePartition{
+			Name:          linuxPartition.Name,
+			PartitionType: linuxPartition.Partitiontype,
+			Size:          uint64(linuxPartition.Size),
+		}
+		log.FromContext(ctx).Tracef("Name=%v, PartitionType=%v, Size=%v", partition.Name, partition.PartitionType, partition.Size)
+		partitions = append(partitions, partition)
+	}
+
+	// Return the enumerated partitions (or empty list if no partitions present)
+	return partitions, nil
 }
 
 // offlineDevice is called to offline the given device
-func (plugin *MultipathPlugin) offlineDevice(device model.Device) error {
-	log.Tracef(">>>>> offlineDevice")
-	defer log.Trace("<<<<< offlineDevice")
+func (plugin *MultipathPlugin) offlineDevice(ctx context.Context, device model.Device) error {
+	log.FromContext(ctx).Tracef(">>>>> offlineDevice, SerialNumber=%v, AltFullPathName=%v", device.SerialNumber, device.AltFullPathName)
+	defer log.FromContext(ctx).Trace("<<<<< offlineDevice")
 
-	// TODO
+	// Offline all the SCSI paths of the multipath device
+	if err := linux.OfflineDevice(newLinuxDevice(device)); err != nil {
+		return cerrors.NewChapiError(err)
+	}
 	return nil
 }
 
 // createFileSystem is called to create a file system on the given device
-func (plugin *MultipathPlugin) createFileSystem(device model.Device, filesystem string) error {
-	log.Tracef(">>>>> createFileSystem")
-	defer log.Trace("<<<<< createFileSystem")
+func (plugin *MultipathPlugin) createFileSystem(ctx context.Context, device model.Device, filesystem string) error {
+	log.FromContext(ctx).Tracef(">>>>> createFileSystem, AltFullPathName=%v, filesystem=%v", device.AltFullPathName, filesystem)
+	defer log.FromContext(ctx).Trace("<<<<< createFileSystem")
 
-	// TODO
+	if device.AltFullPathName == "" {
+		err := cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageDevicePathNotSet, device.SerialNumber)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+
+	// Run mkfs on the multipath device, retrying while the device is still being set up
+	if err := linux.RetryCreateFileSystem(device.AltFullPathName, filesystem); err != nil {
+		return cerrors.NewChapiError(err)
+	}
 	return nil
 }
+
+// expandDevice rescans every SCSI path of the given multipath device so the new capacity is
+// detected, resizes the multipath map, and then resizes any LUKS mapping opened on top of it.
+// Partitioned devices are refused as their partitions would not grow along with the device.
+func (plugin *MultipathPlugin) expandDevice(ctx context.Context, device model.Device) error {
+	log.FromContext(ctx).Tracef(">>>>> expandDevice, Pathname=%v, AltFullPathName=%v", device.Pathname, device.AltFullPathName)
+	defer log.FromContext(ctx).Trace("<<<<< expandDevice")
+
+	if device.Pathname == "" {
+		err := cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageDevicePathNotSet, device.SerialNumber)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+
+	// Growing the device leaves its partitions, and the file systems on them, at their old size
+	if partitions := getPartitionHolders(device.Pathname); len(partitions) != 0 {
+		err := cerrors.NewChapiErrorf(cerrors.Unimplemented, errorMessagePartitioned, device.SerialNumber, strings.Join(partitions, ", "))
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+
+	// Rescan each SCSI path so the SCSI layer picks up the new capacity
+	if err := rescanPaths(ctx, device.Pathname); err != nil {
+		return err
+	}
+
+	// Reload the multipath map to apply the new size; multipathd takes the map name (e.g. "mpathb")
+	mapName := strings.TrimPrefix(device.AltFullPathName, sysfs.DevMapperPath)
+	out, _, err := util.ExecCommandOutput("multipathd", []string{"resize", "map", mapName})
+	if err != nil || !strings.Contains(out, "ok") {
+		if err == nil {
+			err = fmt.Errorf("%v", strings.TrimSpace(out))
+		}
+		err = cerrors.NewChapiErrorf(cerrors.Internal, errorMessageMultipathResize, mapName, err)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+
+	// A LUKS mapping keeps its size until it is told to grow along with the multipath device
+	for _, cryptName := range getCryptHolders(device.Pathname) {
+		log.FromContext(ctx).Infof("Resizing LUKS mapping %v of %v", cryptName, mapName)
+		if _, _, err = util.ExecCommandOutput("cryptsetup", []string{"resize", cryptName}); err != nil {
+			err = cerrors.NewChapiError(cerrors.Internal, err)
+			log.FromContext(ctx).Error(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// rescanPaths triggers a capacity rescan on every SCSI path (slave) of the given dm device
+func rescanPaths(ctx context.Context, dmName string) error {
+	slaves, err := ioutil.ReadDir(filepath.Join(sysfs.SysBlockPath, dmName, "slaves"))
+	if err != nil && !os.IsNotExist(err) {
+		return cerrors.NewChapiError(cerrors.Internal, err)
+	}
+	if len(slaves) == 0 {
+		err = cerrors.NewChapiErrorf(cerrors.NotFound, errorMessageNoPathsToRescan, dmName)
+		log.FromContext(ctx).Error(err)
+		return err
+	}
+	for _, slave := range slaves {
+		rescanPath := filepath.Join(sysfs.SysBlockPath, slave.Name(), "device", "rescan")
+		log.FromContext(ctx).Tracef("Rescan %v", rescanPath)
+		if err = ioutil.WriteFile(rescanPath, []byte("1"), 0200); err != nil {
+			err = cerrors.NewChapiError(cerrors.Internal, err)
+			log.FromContext(ctx).Error(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// getCryptHolders returns the device mapper names of the LUKS mappings opened directly on top of
+// the given dm device
+func getCryptHolders(dmName string) []string {
+	return sysfs.GetHolderNames(dmName, sysfs.CryptUUIDPrefix)
+}
+
+// getPartitionHolders returns the device mapper names of the partition mappings of the given dm device
+func getPartitionHolders(dmName string) []string {
+	return sysfs.GetHolderNames(dmName, sysfs.PartUUIDPrefix)
+}
+
+// getDmDevice returns the model.Device, with basic details, for the device mapper device at the
+// given sysfs path.  A nil device is returned if the device is not a multipath device.
+func getDmDevice(dmPath string) (*model.Device, error) {
+	// e.g. "mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1", where the first character of the WWID
+	// is the SCSI ID type (2 for EUI, 3 for NAA)
+	uuid, err := util.FileReadFirstLine(filepath.Join(dmPath, "dm", "uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(uuid, mpathUUIDPrefix) || len(uuid) <= len(mpathUUIDPrefix)+1 {
+		return nil, nil
+	}
+	name, err := util.FileReadFirstLine(filepath.Join(dmPath, "dm", "name"))
+	if err != nil {
+		return nil, err
+	}
+	size, err := getSizeInBytes(filepath.Base(dmPath))
+	if err != nil {
+		return nil, err
+	}
+	return &model.Device{
+		SerialNumber:    uuid[len(mpathUUIDPrefix)+1:],
+		Pathname:        filepath.Base(dmPath),
+		AltFullPathName: sysfs.DevMapperPath + name,
+		Size:            size,
+		Private:         &model.DevicePrivate{},
+	}, nil
+}
+
+// getSizeInBytes returns the size of the given block device (e.g. "dm-3") in bytes
+func getSizeInBytes(blockDevice string) (uint64, error) {
+	sectors, err := util.FileReadFirstLine(filepath.Join(sysfs.SysBlockPath, blockDevice, "size"))
+	if err != nil {
+		return 0, err
+	}
+	size, err := strconv.ParseUint(sectors, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse size of %v, err=%v", blockDevice, err)
+	}
+	return size * sectorSize, nil
+}
+
+// newDevice converts the given linux package device into a model.Device
+func newDevice(linuxDevice *linuxmodel.Device) *model.Device {
+	device := &model.Device{
+		SerialNumber:    linuxDevice.SerialNumber,
+		Pathname:        linuxDevice.Pathname,
+		AltFullPathName: linuxDevice.AltFullPathName,
+		State:           linuxDevice.State,
+		Private:         &model.DevicePrivate{},
+	}
+
+	// The linux package reports the size in MiB, read the exact byte count when possible
+	if size, err := getSizeInBytes(dmPrefix + linuxDevice.Minor); err == nil {
+		device.Size = size
+	} else {
+		device.Size = uint64(linuxDevice.Size) * 1024 * 1024
+	}
+
+	// Populate the SCSI paths of the device
+	for index, slave := range linuxDevice.Slaves {
+		path := model.Path{Name: slave}
+		if index < len(linuxDevice.Hcils) {
+			path.Hcils = linuxDevice.Hcils[index]
+		}
+		if majorMinor, err := util.FileReadFirstLine(filepath.Join(sysfs.SysBlockPath, slave, "dev")); err == nil {
+			if fields := strings.SplitN(majorMinor, ":", 2); len(fields) == 2 {
+				path.Major, path.Minor = fields[0], fields[1]
+			}
+		}
+		if state, err := util.FileReadFirstLine(filepath.Join(sysfs.SysBlockPath, slave, "device", "state")); err == nil {
+			path.State = state
+		}
+		device.Private.Paths = append(device.Private.Paths, path)
+	}
+	return device
+}
+
+// newLinuxDevice converts the given model.Device into a linux package device
+func newLinuxDevice(device model.Device) *linuxmodel.Device {
+	linuxDevice := &linuxmodel.Device{
+		SerialNumber:    device.SerialNumber,
+		Pathname:        device.Pathname,
+		AltFullPathName: device.AltFullPathName,
+		MpathName:       strings.TrimPrefix(device.AltFullPathName, sysfs.DevMapperPath),
+		Minor:           strings.TrimPrefix(device.Pathname, dmPrefix),
+		State:           device.State,
+	}
+	if device.Private != nil {
+		for _, path := range device.Private.Paths {
+			linuxDevice.Slaves = append(linuxDevice.Slaves, path.Name)
+			linuxDevice.Hcils = append(linuxDevice.Hcils, path.Hcils)
+		}
+	}
+	return linuxDevice
+}
+
+// getIscsiTarget returns the IscsiTarget object for the given linux package device.  The linux
+// package reports one IscsiTarget per target portal, these are combined into a single target.
+func (plugin *MultipathPlugin) getIscsiTarget(ctx context.Context, linuxDevice *linuxmodel.Device) *model.IscsiTarget {
+	iscsiTarget := &model.IscsiTarget{Name: linuxDevice.IscsiTargets[0].Name}
+	portals := make(map[string]bool)
+	for _, target := range linuxDevice.IscsiTargets {
+		if target.Name != iscsiTarget.Name {
+			log.FromContext(ctx).Warnf("device %v connected to multiple targets, ignoring %v", linuxDevice.SerialNumber, target.Name)
+			continue
+		}
+		if iscsiTarget.TargetScope == "" {
+			iscsiTarget.TargetScope = target.Scope
+		}
+		portal := target.Address + ":" + target.Port
+		if target.Address == "" || portals[portal] {
+			continue
+		}
+		portals[portal] = true
+		iscsiTarget.TargetPortals = append(iscsiTarget.TargetPortals, &model.TargetPortal{
+			Address: target.Address,
+			Port:    target.Port,
+			Tag:     target.Tag,
+		})
+	}
+	if iscsiTarget.TargetScope == "" {
+		iscsiTarget.TargetScope = linuxDevice.TargetScope
+	}
+
+	// See if we have a cached target scope for the iqn.  If we do not, enumerate the scope from
+	// the device.
+	if iscsiTarget.TargetScope == "" {
+		iscsiTarget.TargetScope = getTargetTypeCache().GetTargetType(iscsiTarget.Name)
+	}
+	if iscsiTarget.TargetScope == "" && plugin.iscsiPlugin != nil {
+		iscsiTarget.TargetScope, _ = plugin.iscsiPlugin.GetTargetScope(iscsiTarget.Name)
+	}
+	if iscsiTarget.TargetScope != "" {
+		getTargetTypeCache().SetTargetType(iscsiTarget.Name, iscsiTarget.TargetScope)
+	}
+	return iscsiTarget
+}
@@ -0,0 +1,198 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package multipath
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/chapi2/sysfs"
+	"github.com/hpe-storage/common-host-libs/chapi2/sysfs/sysfstest"
+	linuxmodel "github.com/hpe-storage/common-host-libs/model"
+)
+
+// useFakeSysfs points sysfs.SysBlockPath to a fake sysfs tree with two multipath devices, an LVM
+// device and two SCSI paths
+func useFakeSysfs(t *testing.T) {
+	root := t.TempDir()
+	sysfstest.WriteFile(t, root, "dm-0/dm/uuid", "LVM-x9Tz")
+	sysfstest.WriteFile(t, root, "dm-0/dm/name", "centos-root")
+	sysfstest.WriteFile(t, root, "dm-0/size", "2048")
+	sysfstest.WriteFile(t, root, "dm-3/dm/uuid", "mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1")
+	sysfstest.WriteFile(t, root, "dm-3/dm/name", "mpathb")
+	sysfstest.WriteFile(t, root, "dm-3/size", "20971520")
+	sysfstest.WriteFile(t, root, "dm-4/dm/uuid", "mpath-36002ac000000000000000e4f00019b13")
+	sysfstest.WriteFile(t, root, "dm-4/dm/name", "mpathc")
+	sysfstest.WriteFile(t, root, "dm-4/size", "2097152")
+	sysfstest.WriteFile(t, root, "sdb/dev", "8:16")
+	sysfstest.WriteFile(t, root, "sdb/device/state", "running")
+	sysfstest.WriteFile(t, root, "sdc/dev", "8:32")
+	sysfstest.WriteFile(t, root, "sdc/device/state", "offline")
+
+	sysfstest.UseSysBlock(t, root)
+}
+
+func TestGetDevices(t *testing.T) {
+	useFakeSysfs(t)
+	plugin := &MultipathPlugin{}
+
+	devices, err := plugin.getDevices(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*model.Device{
+		{
+			SerialNumber:    "f6d3c1a4b2e8d9b06c9ce900d2a3c5e1",
+			Pathname:        "dm-3",
+			AltFullPathName: "/dev/mapper/mpathb",
+			Size:            10 * 1024 * 1024 * 1024,
+			Private:         &model.DevicePrivate{},
+		},
+		{
+			SerialNumber:    "6002ac000000000000000e4f00019b13",
+			Pathname:        "dm-4",
+			AltFullPathName: "/dev/mapper/mpathc",
+			Size:            1024 * 1024 * 1024,
+			Private:         &model.DevicePrivate{},
+		},
+	}
+	if !reflect.DeepEqual(devices, expected) {
+		t.Errorf("unexpected devices %+v", devices)
+	}
+
+	// A single serial number, matched case insensitively
+	devices, err = plugin.getDevices(context.Background(), "F6D3C1A4B2E8D9B06C9CE900D2A3C5E1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 || devices[0].Pathname != "dm-3" {
+		t.Errorf("unexpected devices %+v", devices)
+	}
+
+	// Unknown serial number
+	devices, err = plugin.getDevices(context.Background(), "00000000000000000000000000000000")
+	if err != nil || len(devices) != 0 {
+		t.Errorf("unexpected devices %+v, err=%v", devices, err)
+	}
+}
+
+func TestDeviceConversion(t *testing.T) {
+	useFakeSysfs(t)
+	plugin := &MultipathPlugin{}
+
+	linuxDevice := &linuxmodel.Device{
+		SerialNumber:    "f6d3c1a4b2e8d9b06c9ce900d2a3c5e1",
+		Pathname:        "dm-3",
+		AltFullPathName: "/dev/mapper/mpathb",
+		MpathName:       "mpathb",
+		Minor:           "3",
+		Size:            10240,
+		Slaves:          []string{"sdb", "sdc"},
+		Hcils:           []string{"3:0:0:1", "4:0:0:1"},
+		State:           linuxmodel.ActiveState.String(),
+		IscsiTargets: []*linuxmodel.IscsiTarget{
+			{Name: "iqn.2007-11.com.nimblestorage:vol1", Address: "10.0.0.1", Port: "3260", Tag: "2460"},
+			{Name: "iqn.2007-11.com.nimblestorage:vol1", Address: "10.0.0.2", Port: "3260", Tag: "2460", Scope: model.TargetScopeVolume},
+			{Name: "iqn.2007-11.com.nimblestorage:vol1", Address: "10.0.0.1", Port: "3260", Tag: "2460"},
+		},
+	}
+
+	device := newDevice(linuxDevice)
+	device.IscsiTarget = plugin.getIscsiTarget(context.Background(), linuxDevice)
+	expected := &model.Device{
+		SerialNumber:    "f6d3c1a4b2e8d9b06c9ce900d2a3c5e1",
+		Pathname:        "dm-3",
+		AltFullPathName: "/dev/mapper/mpathb",
+		Size:            10 * 1024 * 1024 * 1024,
+		State:           "active",
+		IscsiTarget: &model.IscsiTarget{
+			Name: "iqn.2007-11.com.nimblestorage:vol1",
+			TargetPortals: []*model.TargetPortal{
+				{Address: "10.0.0.1", Port: "3260", Tag: "2460"},
+				{Address: "10.0.0.2", Port: "3260", Tag: "2460"},
+			},
+			TargetScope: model.TargetScopeVolume,
+		},
+		Private: &model.DevicePrivate{
+			Paths: []model.Path{
+				{Name: "sdb", Major: "8", Minor: "16", Hcils: "3:0:0:1", State: "running"},
+				{Name: "sdc", Major: "8", Minor: "32", Hcils: "4:0:0:1", State: "offline"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(device, expected) {
+		t.Errorf("unexpected device %+v", device)
+	}
+
+	// Converting back provides what the linux package needs to offline the device
+	back := newLinuxDevice(*device)
+	if back.SerialNumber != linuxDevice.SerialNumber || back.MpathName != "mpathb" || back.Minor != "3" ||
+		!reflect.DeepEqual(back.Slaves, linuxDevice.Slaves) || !reflect.DeepEqual(back.Hcils, linuxDevice.Hcils) {
+		t.Errorf("unexpected linux device %+v", back)
+	}
+}
+
+func TestExpandDeviceHelpers(t *testing.T) {
+	useFakeSysfs(t)
+	root := sysfs.SysBlockPath
+
+	// dm-3 has two SCSI paths and a LUKS mapping, dm-4 is only used by LVM
+	sysfstest.WriteFile(t, root, "sdb/device/rescan", "")
+	sysfstest.WriteFile(t, root, "sdc/device/rescan", "")
+	sysfstest.WriteFile(t, root, "dm-5/dm/uuid", "CRYPT-LUKS2-5a8c7e2c8f1d4e4b9d1c4f7b2e3a6d90-enc-mpathb")
+	sysfstest.WriteFile(t, root, "dm-5/dm/name", "enc-mpathb")
+	for _, link := range []struct{ dir, name string }{
+		{"dm-3/slaves", "sdb"}, {"dm-3/slaves", "sdc"}, {"dm-3/holders", "dm-5"}, {"dm-4/holders", "dm-0"},
+	} {
+		if err := os.MkdirAll(filepath.Join(root, link.dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join("..", "..", link.name), filepath.Join(root, link.dir, link.name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := rescanPaths(context.Background(), "dm-3"); err != nil {
+		t.Fatal(err)
+	}
+	for _, slave := range []string{"sdb", "sdc"} {
+		if rescan, _ := ioutil.ReadFile(filepath.Join(root, slave, "device", "rescan")); string(rescan) != "1" {
+			t.Errorf("%v not rescanned, rescan=%q", slave, rescan)
+		}
+	}
+
+	// A device without paths cannot be rescanned
+	if err := rescanPaths(context.Background(), "dm-4"); err == nil {
+		t.Error("rescan of dm-4 succeeded without paths")
+	}
+
+	if names := getCryptHolders("dm-3"); !reflect.DeepEqual(names, []string{"enc-mpathb"}) {
+		t.Errorf("unexpected LUKS mappings %v", names)
+	}
+	if names := getCryptHolders("dm-4"); len(names) != 0 {
+		t.Errorf("unexpected LUKS mappings %v", names)
+	}
+
+	// A partitioned device is refused before any path is rescanned
+	plugin := &MultipathPlugin{}
+	device := model.Device{SerialNumber: "2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1", Pathname: "dm-3", AltFullPathName: "/dev/mapper/mpathb"}
+	sysfstest.WriteFile(t, root, "sdb/device/rescan", "")
+	sysfstest.WriteFile(t, root, "dm-6/dm/uuid", "part1-mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1")
+	sysfstest.WriteFile(t, root, "dm-6/dm/name", "mpathb1")
+	if err := os.Symlink(filepath.Join("..", "..", "dm-6"), filepath.Join(root, "dm-3", "holders", "dm-6")); err != nil {
+		t.Fatal(err)
+	}
+	err := plugin.expandDevice(context.Background(), device)
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.Unimplemented {
+		t.Errorf("unexpected error %v", err)
+	}
+	if rescan, _ := ioutil.ReadFile(filepath.Join(root, "sdb", "device", "rescan")); string(rescan) != "\n" {
+		t.Errorf("partitioned device rescanned, rescan=%q", rescan)
+	}
+}
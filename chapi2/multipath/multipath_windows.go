@@ -3,6 +3,7 @@
 package multipath
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
@@ -15,11 +16,13 @@ import (
 	"github.com/hpe-storage/common-host-libs/windows/wmi"
 )
 
+const errorMessageExpandNotYetImplemented = "device expansion not yet implemented on windows"
+
 // getDevices enumerates all the Nimble volumes while only providing basic details (e.g. serial number).
 // If a "serialNumber" is passed in, only that specific serial number is enumerated.
-func (plugin *MultipathPlugin) getDevices(serialNumber string) ([]*model.Device, error) {
-	log.Tracef(">>>>> getDevices, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< getDevices")
+func (plugin *MultipathPlugin) getDevices(ctx context.Context, serialNumber string) ([]*model.Device, error) {
+	log.FromContext(ctx).Tracef(">>>>> getDevices, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< getDevices")
 
 	// Enumerate all Nimble volumes
 	nimbleDisks, err := wmi.GetNimbleMSFTDisk(serialNumber)
@@ -34,12 +37,12 @@ func (plugin *MultipathPlugin) getDevices(serialNumber string) ([]*model.Device,
 			SerialNumber: nimbleDisk.SerialNumber,
 			Private:      &model.DevicePrivate{WindowsDisk: nimbleDisk},
 		}
-		log.Tracef("SerialNumber=%v, Number=%v, IsOffline=%v, IsReadOnly=%v", nimbleDisk.SerialNumber, nimbleDisk.Number, nimbleDisk.IsOffline, nimbleDisk.IsReadOnly)
+		log.FromContext(ctx).Tracef("SerialNumber=%v, Number=%v, IsOffline=%v, IsReadOnly=%v", nimbleDisk.SerialNumber, nimbleDisk.Number, nimbleDisk.IsOffline, nimbleDisk.IsReadOnly)
 		devices = append(devices, device)
 	}
 
 	// Make sure duplicate serial numbers are not detected (e.g. misconfigured MPIO)
-	if err = plugin.checkDuplicateSerialNumbers(devices); err != nil {
+	if err = plugin.checkDuplicateSerialNumbers(ctx, devices); err != nil {
 		return nil, err
 	}
 
@@ -48,9 +51,9 @@ func (plugin *MultipathPlugin) getDevices(serialNumber string) ([]*model.Device,
 
 // getAllDeviceDetails enumerates all the Nimble volumes while providing full details about the
 // device.  If a "serialNumber" is passed in, only that specific serial number is enumerated.
-func (plugin *MultipathPlugin) getAllDeviceDetails(serialNumber string) ([]*model.Device, error) {
-	log.Trace(">>>>> getAllDeviceDetails")
-	defer log.Trace("<<<<< getAllDeviceDetails")
+func (plugin *MultipathPlugin) getAllDeviceDetails(ctx context.Context, serialNumber string) ([]*model.Device, error) {
+	log.FromContext(ctx).Trace(">>>>> getAllDeviceDetails")
+	defer log.FromContext(ctx).Trace("<<<<< getAllDeviceDetails")
 
 	// Enumerate all Nimble volumes
 	nimbleDisks, err := wmi.GetNimbleMSFTDisk(serialNumber)
@@ -90,24 +93,24 @@ func (plugin *MultipathPlugin) getAllDeviceDetails(serialNumber string) ([]*mode
 
 			// If we were not provided an iSCSI plugin object, log an error and skip volume
 			if plugin.iscsiPlugin == nil {
-				log.Errorf("iscsiPlugin object not provided, skipping iSCSI device, Number=%v, Path=%v", nimbleDisk.Number, nimbleDisk.Path)
+				log.FromContext(ctx).Errorf("iscsiPlugin object not provided, skipping iSCSI device, Number=%v, Path=%v", nimbleDisk.Number, nimbleDisk.Path)
 				continue
 			}
 
 			// Enumerate the IscsiTarget for our device
-			device.IscsiTarget, _ = plugin.getIscsiTarget(nimbleDisk.Path, targetMappings, cachedTargetPortals)
+			device.IscsiTarget, _ = plugin.getIscsiTarget(ctx, nimbleDisk.Path, targetMappings, cachedTargetPortals)
 		}
 
 		// Log the device details
-		log.Tracef("Device %v, SerialNumber=%v, Pathname=%v, BusType=%v, Size=%v, IsOffline=%v, IsReadOnly=%v",
+		log.FromContext(ctx).Tracef("Device %v, SerialNumber=%v, Pathname=%v, BusType=%v, Size=%v, IsOffline=%v, IsReadOnly=%v",
 			deviceIndex, device.SerialNumber, device.Pathname, nimbleDisk.BusType, device.Size, nimbleDisk.IsOffline, nimbleDisk.IsReadOnly)
 
 		// If it's an iSCSI target, log the iSCSI details
 		if device.IscsiTarget != nil {
-			log.Tracef("    IQN   - %v", device.IscsiTarget.Name)
-			log.Tracef("    Scope - %v", device.IscsiTarget.TargetScope)
+			log.FromContext(ctx).Tracef("    IQN   - %v", device.IscsiTarget.Name)
+			log.FromContext(ctx).Tracef("    Scope - %v", device.IscsiTarget.TargetScope)
 			for _, targetPortal := range device.IscsiTarget.TargetPortals {
-				log.Tracef("    Port  - %v:%v", targetPortal.Address, targetPortal.Port)
+				log.FromContext(ctx).Tracef("    Port  - %v:%v", targetPortal.Address, targetPortal.Port)
 			}
 		}
 
@@ -116,35 +119,20 @@ func (plugin *MultipathPlugin) getAllDeviceDetails(serialNumber string) ([]*mode
 	}
 
 	// Make sure duplicate serial numbers are not detected (e.g. misconfigured MPIO)
-	if err = plugin.checkDuplicateSerialNumbers(devices); err != nil {
+	if err = plugin.checkDuplicateSerialNumbers(ctx, devices); err != nil {
 		return nil, err
 	}
 
 	return devices, nil
 }
 
-// checkDuplicateSerialNumbers scans the array of CHAPI devices for any duplicate serial numbers.
-// If any are found, an error object is returned (e.g. misconfigured MPIO) else nil is returned.
-func (plugin *MultipathPlugin) checkDuplicateSerialNumbers(devices []*model.Device) error {
-	m := make(map[string]bool)
-	for _, device := range devices {
-		if m[device.SerialNumber] == true {
-			err := cerrors.NewChapiErrorf(cerrors.Internal, errorMessageMisconfiguredMultipathIO, device.SerialNumber)
-			log.Error(err)
-			return err
-		}
-		m[device.SerialNumber] = true
-	}
-	return nil
-}
-
 // getPartitionInfo enumerates the partitions on the given volume
-func (plugin *MultipathPlugin) getPartitionInfo(serialNumber string) ([]*model.DevicePartition, error) {
-	log.Tracef(">>>>> getPartitionInfo, serialNumber=%v", serialNumber)
-	defer log.Trace("<<<<< getPartitionInfo")
+func (plugin *MultipathPlugin) getPartitionInfo(ctx context.Context, serialNumber string) ([]*model.DevicePartition, error) {
+	log.FromContext(ctx).Tracef(">>>>> getPartitionInfo, serialNumber=%v", serialNumber)
+	defer log.FromContext(ctx).Trace("<<<<< getPartitionInfo")
 
 	// Enumerate the one serial number
-	device, err := plugin.getDevices(serialNumber)
+	device, err := plugin.getDevices(ctx, serialNumber)
 	if err != nil {
 		return nil, err
 	}
@@ -169,7 +157,7 @@ func (plugin *MultipathPlugin) getPartitionInfo(serialNumber string) ([]*model.D
 			PartitionType: win32Partition.Type,
 			Size:          win32Partition.Size,
 		}
-		log.Tracef("Name=%v, PartitionType=%v, Size=%v", partition.Name, partition.PartitionType, partition.Size)
+		log.FromContext(ctx).Tracef("Name=%v, PartitionType=%v, Size=%v", partition.Name, partition.PartitionType, partition.Size)
 		partitions = append(partitions, partition)
 	}
 
@@ -178,9 +166,9 @@ func (plugin *MultipathPlugin) getPartitionInfo(serialNumber string) ([]*model.D
 }
 
 // offlineDevice is called to offline the given device
-func (plugin *MultipathPlugin) offlineDevice(device model.Device) error {
-	log.Tracef(">>>>> offlineDevice, Path=%v", device.Private.WindowsDisk.Path)
-	defer log.Trace("<<<<< offlineDevice")
+func (plugin *MultipathPlugin) offlineDevice(ctx context.Context, device model.Device) error {
+	log.FromContext(ctx).Tracef(">>>>> offlineDevice, Path=%v", device.Private.WindowsDisk.Path)
+	defer log.FromContext(ctx).Trace("<<<<< offlineDevice")
 
 	// Use PowerShell to offline the disk
 	_, _, err := powershell.SetDiskOffline(device.Private.WindowsDisk.Path, true)
@@ -188,9 +176,9 @@ func (plugin *MultipathPlugin) offlineDevice(device model.Device) error {
 }
 
 // createFileSystem is called to create a file system on the given device
-func (plugin *MultipathPlugin) createFileSystem(device model.Device, filesystem string) error {
-	log.Tracef(">>>>> createFileSystem, Path=%v, filesystem=%v", device.Private.WindowsDisk.Path, filesystem)
-	defer log.Trace("<<<<< createFileSystem")
+func (plugin *MultipathPlugin) createFileSystem(ctx context.Context, device model.Device, filesystem string) error {
+	log.FromContext(ctx).Tracef(">>>>> createFileSystem, Path=%v, filesystem=%v", device.Private.WindowsDisk.Path, filesystem)
+	defer log.FromContext(ctx).Trace("<<<<< createFileSystem")
 
 	// Make sure disk is online and writable before attempting the format
 	if err := plugin.MakeDiskOnlineAndWritable(device.Private.WindowsDisk.Path, true, true); err != nil {
@@ -200,7 +188,7 @@ func (plugin *MultipathPlugin) createFileSystem(device model.Device, filesystem
 	// Determine partition style to use
 	partitionStyle := powershell.PartitionStyleGPT
 	if device.Size < powershell.MinimumGPTSize {
-		log.Tracef("Disk not large enough for GPT (%v bytes), using MBR", device.Size)
+		log.FromContext(ctx).Tracef("Disk not large enough for GPT (%v bytes), using MBR", device.Size)
 		partitionStyle = powershell.PartitionStyleMBR
 	}
 
@@ -214,14 +202,19 @@ func (plugin *MultipathPlugin) createFileSystem(device model.Device, filesystem
 	return err
 }
 
+// expandDevice is not yet implemented on Windows
+func (plugin *MultipathPlugin) expandDevice(ctx context.Context, device model.Device) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageExpandNotYetImplemented)
+}
+
 // getIscsiTarget enumerates the IscsiTarget object for the "devicePathID" device.  The caller needs
 // to pass in the current target mappings (targetMappings object) and pass in cache objects where
 // this routine can cache the last enumerated target ports.  This routine first checks the cache to
 // see if the target values are known.  If not, then the target is queried to retrieve this
 // information and update the cache.
-func (plugin *MultipathPlugin) getIscsiTarget(devicePathID string, targetMappings []*iscsidsc.ISCSI_TARGET_MAPPING, cachedTargetPortals map[string][]*model.TargetPortal) (*model.IscsiTarget, error) {
-	log.Tracef(">>>>> getIscsiTarget, devicePathID=%v", devicePathID)
-	defer log.Trace("<<<<< getIscsiTarget")
+func (plugin *MultipathPlugin) getIscsiTarget(ctx context.Context, devicePathID string, targetMappings []*iscsidsc.ISCSI_TARGET_MAPPING, cachedTargetPortals map[string][]*model.TargetPortal) (*model.IscsiTarget, error) {
+	log.FromContext(ctx).Tracef(">>>>> getIscsiTarget, devicePathID=%v", devicePathID)
+	defer log.FromContext(ctx).Trace("<<<<< getIscsiTarget")
 
 	// Start by enumerating the device SCSI address; abort if unable to enumerate
 	scsiAddress, err := ioctl.GetScsiAddress(devicePathID)
@@ -275,7 +268,7 @@ func (plugin *MultipathPlugin) getIscsiTarget(devicePathID string, targetMapping
 	// Return an error if we were unable to locate the iSCSI target
 	if iscsiTarget == nil {
 		err = cerrors.NewChapiError(cerrors.NotFound, errorMessageUnableLocateIscsiTarget)
-		log.Error(err)
+		log.FromContext(ctx).Error(err)
 		return nil, err
 	}
 
@@ -0,0 +1,291 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package openapi
+
+// The openapi package builds an OpenAPI 3 document of a REST API from its routes, and derives the
+// JSON schemas of the request and response objects from their Go types.  Every JSON response is
+// expected to carry its object in a "data" property and its error in an "errors" property.
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Version of the OpenAPI specification the documents follow
+	Version = "3.0.3"
+
+	// ContentTypeJSON is the content type of request and response objects
+	ContentTypeJSON = "application/json"
+
+	// SchemaRefPrefix is the prefix of references to the schemas of the document components
+	SchemaRefPrefix = "#/components/schemas/"
+)
+
+// pathParameter matches a route path parameter (e.g. "{serialNumber}" or "{id:[0-9]+}")
+var pathParameter = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// Document is an OpenAPI 3 document
+type Document struct {
+	OpenAPI    string                  `json:"openapi"`
+	Info       Info                    `json:"info"`
+	Paths      map[string]PathItem     `json:"paths"`
+	Components Components              `json:"components"`
+	types      map[string]reflect.Type // Go type of each component schema
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path by lower case HTTP method (e.g. "get")
+type PathItem map[string]*Operation
+
+// Operation describes a single route
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path, query or header parameter of an operation
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"` // "path", "query" or "header"
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody is the request body of an operation
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response is a response of an operation
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a request or response body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the JSON schema of a value
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Components holds the schemas referenced by the document
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Endpoint describes the input and output of a route
+type Endpoint struct {
+	Summary     string      // Short description of the route
+	Request     interface{} // Value of the type decoded from the request body, nil if there is no body
+	Response    interface{} // Value of the type returned in the "data" property, nil if nothing is returned
+	Query       []string    // Optional query parameters
+	Header      []string    // Optional request headers
+	ContentType string      // Content type of a response not wrapped in a "data" property (e.g. "text/event-stream")
+}
+
+// NewDocument returns a Document without any operations.  errorValue is a value of the type
+// returned in the "errors" property of failed requests.
+func NewDocument(title string, version string, errorValue interface{}) *Document {
+	document := &Document{
+		OpenAPI:    Version,
+		Info:       Info{Title: title, Version: version},
+		Paths:      make(map[string]PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+		types:      make(map[string]reflect.Type),
+	}
+	errorSchema := &Schema{Type: "object", Properties: map[string]*Schema{"errors": document.Schema(errorValue)}}
+	document.Components.Schemas["Error"] = errorSchema
+	return document
+}
+
+// AddOperation adds the route to the document.  The route pattern may contain path parameters
+// (e.g. "/api/v1/devices/{serialNumber}").
+func (document *Document) AddOperation(operationID string, method string, pattern string, endpoint *Endpoint) {
+	operation := &Operation{OperationID: operationID, Responses: make(map[string]*Response)}
+	for _, match := range pathParameter.FindAllStringSubmatch(pattern, -1) {
+		operation.Parameters = append(operation.Parameters, &Parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	path := pathParameter.ReplaceAllString(pattern, "{$1}")
+
+	if endpoint != nil {
+		operation.Summary = endpoint.Summary
+		for _, name := range endpoint.Query {
+			operation.Parameters = append(operation.Parameters, &Parameter{Name: name, In: "query", Schema: &Schema{Type: "string"}})
+		}
+		for _, name := range endpoint.Header {
+			operation.Parameters = append(operation.Parameters, &Parameter{Name: name, In: "header", Schema: &Schema{Type: "string"}})
+		}
+		if endpoint.Request != nil {
+			operation.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]*MediaType{ContentTypeJSON: {Schema: document.Schema(endpoint.Request)}},
+			}
+		}
+	}
+
+	success := &Response{Description: "Success"}
+	switch {
+	case endpoint != nil && endpoint.ContentType != "":
+		schema := &Schema{Type: "object"}
+		if endpoint.Response != nil {
+			schema = document.Schema(endpoint.Response)
+		}
+		success.Content = map[string]*MediaType{endpoint.ContentType: {Schema: schema}}
+	case endpoint != nil && endpoint.Response != nil:
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{"data": document.Schema(endpoint.Response)}}
+		success.Content = map[string]*MediaType{ContentTypeJSON: {Schema: schema}}
+	}
+	operation.Responses["200"] = success
+	operation.Responses["default"] = &Response{
+		Description: "Error",
+		Content:     map[string]*MediaType{ContentTypeJSON: {Schema: &Schema{Ref: SchemaRefPrefix + "Error"}}},
+	}
+
+	if document.Paths[path] == nil {
+		document.Paths[path] = make(PathItem)
+	}
+	document.Paths[path][strings.ToLower(method)] = operation
+}
+
+// Operation returns the operation of the method on the path (e.g. "/api/v1/devices/{serialNumber}"),
+// or nil if there is none
+func (document *Document) Operation(method string, path string) *Operation {
+	return document.Paths[path][strings.ToLower(method)]
+}
+
+// DataSchema returns the schema of the "data" property of the operation response, or nil if the
+// operation doesn't return any data
+func (document *Document) DataSchema(operation *Operation) *Schema {
+	if success := operation.Responses["200"]; success != nil {
+		if mediaType := success.Content[ContentTypeJSON]; mediaType != nil && mediaType.Schema != nil {
+			return mediaType.Schema.Properties["data"]
+		}
+	}
+	return nil
+}
+
+// Resolve returns the component schema a schema refers to, or the schema itself if it isn't a
+// reference
+func (document *Document) Resolve(schema *Schema) *Schema {
+	if schema != nil && strings.HasPrefix(schema.Ref, SchemaRefPrefix) {
+		return document.Components.Schemas[strings.TrimPrefix(schema.Ref, SchemaRefPrefix)]
+	}
+	return schema
+}
+
+// Schema returns the schema of the value's type.  Structs are added to the document components
+// and referred to.
+func (document *Document) Schema(value interface{}) *Schema {
+	if value == nil {
+		return &Schema{}
+	}
+	return document.schemaOf(reflect.TypeOf(value))
+}
+
+// JSON returns the document in JSON
+func (document *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// schemaOf returns the schema of a Go type
+func (document *Document) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"} // base64 encoded
+		}
+		return &Schema{Type: "array", Items: document.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: document.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return &Schema{Ref: SchemaRefPrefix + document.addComponent(t)}
+	}
+	return &Schema{} // Any value (e.g. interface{})
+}
+
+// addComponent adds the schema of a struct to the document components and returns its name
+func (document *Document) addComponent(t reflect.Type) string {
+	name := t.Name()
+	if existing, ok := document.types[name]; ok && existing != t {
+		// Same type name in another package (e.g. model.Device of chapi2 and of common model)
+		pkg := pathBase(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	if _, ok := document.types[name]; ok {
+		return name
+	}
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	document.types[name] = t
+	document.Components.Schemas[name] = schema // Added before the fields for recursive types
+	document.addProperties(schema, t)
+	return name
+}
+
+// addProperties adds the JSON properties of the struct fields to the schema
+func (document *Document) addProperties(schema *Schema, t reflect.Type) {
+	for index := 0; index < t.NumField(); index++ {
+		field := t.Field(index)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		// Embedded structs without a JSON name have their fields promoted
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				document.addProperties(schema, embedded)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = document.schemaOf(field.Type)
+	}
+}
+
+// pathBase returns the last element of a package path
+func pathBase(pkgPath string) string {
+	return pkgPath[strings.LastIndex(pkgPath, "/")+1:]
+}
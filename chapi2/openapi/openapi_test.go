@@ -0,0 +1,74 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package openapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testError struct {
+	Code uint32 `json:"code"`
+}
+
+type testBase struct {
+	ID string `json:"id,omitempty"`
+}
+
+type testNode struct {
+	testBase
+	Name     string            `json:"name,omitempty"`
+	Data     []byte            `json:"data,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Children []*testNode       `json:"children,omitempty"`
+	Private  string            `json:"-"`
+	hidden   string
+}
+
+func TestSchema(t *testing.T) {
+	document := NewDocument("test", "1.0.0", &testError{})
+	if schema := document.Schema([]*testNode{}); !reflect.DeepEqual(schema, &Schema{Type: "array", Items: &Schema{Ref: SchemaRefPrefix + "testNode"}}) {
+		t.Errorf("unexpected schema %+v", schema)
+	}
+
+	expected := &Schema{Type: "object", Properties: map[string]*Schema{
+		"id":       {Type: "string"},
+		"name":     {Type: "string"},
+		"data":     {Type: "string", Format: "byte"},
+		"labels":   {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+		"children": {Type: "array", Items: &Schema{Ref: SchemaRefPrefix + "testNode"}},
+	}}
+	if schema := document.Resolve(&Schema{Ref: SchemaRefPrefix + "testNode"}); !reflect.DeepEqual(schema, expected) {
+		t.Errorf("unexpected component %+v", schema)
+	}
+	if schema := document.Components.Schemas["Error"].Properties["errors"]; schema.Ref != SchemaRefPrefix+"testError" {
+		t.Errorf("unexpected error schema %+v", schema)
+	}
+}
+
+func TestAddOperation(t *testing.T) {
+	document := NewDocument("test", "1.0.0", &testError{})
+	document.AddOperation("UpdateNode", "PUT", "/nodes/{id:[0-9]+}", &Endpoint{Summary: "Update a node", Request: &testNode{}, Response: &testNode{}, Query: []string{"force"}})
+	document.AddOperation("DeleteNode", "DELETE", "/nodes/{id:[0-9]+}", nil)
+
+	operation := document.Operation("put", "/nodes/{id}")
+	if operation == nil || operation.Summary != "Update a node" || document.Operation("DELETE", "/nodes/{id}") == nil {
+		t.Fatalf("unexpected operations %+v", document.Paths)
+	}
+	expected := []*Parameter{
+		{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+		{Name: "force", In: "query", Schema: &Schema{Type: "string"}},
+	}
+	if !reflect.DeepEqual(operation.Parameters, expected) {
+		t.Errorf("unexpected parameters %+v", operation.Parameters)
+	}
+	if operation.RequestBody == nil || operation.RequestBody.Content[ContentTypeJSON].Schema.Ref != SchemaRefPrefix+"testNode" {
+		t.Errorf("unexpected request body %+v", operation.RequestBody)
+	}
+	if schema := document.DataSchema(operation); schema == nil || schema.Ref != SchemaRefPrefix+"testNode" {
+		t.Errorf("unexpected response data %+v", schema)
+	}
+	if schema := document.DataSchema(document.Operation("DELETE", "/nodes/{id}")); schema != nil {
+		t.Errorf("unexpected response data %+v", schema)
+	}
+}
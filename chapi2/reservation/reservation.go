@@ -0,0 +1,176 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package reservation
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	log "github.com/hpe-storage/common-host-libs/logger"
+	"github.com/hpe-storage/common-host-libs/sgio"
+)
+
+const (
+	// Shared error messages
+	errorMessageEmptyHostUUID       = "host uuid not available to derive the reservation key"
+	errorMessageInvalidAction       = `invalid reservation action "%v"`
+	errorMessageInvalidKey          = `invalid reservation key "%v"`
+	errorMessagePreemptKeyRequired  = "preempt key required to preempt a reservation"
+	errorMessageReservationConflict = "reservation conflict: %v"
+	errorMessageSerialNumberMissing = "serial number not provided"
+)
+
+// DefaultReservationType is used when a request does not name a reservation type, it allows all registered
+// paths of the host to write while fencing unregistered hosts
+const DefaultReservationType = "write-exclusive-registrants-only"
+
+type ReservationPlugin struct {
+}
+
+func NewReservationPlugin() *ReservationPlugin {
+	return &ReservationPlugin{}
+}
+
+// KeyFromHostUUID derives the persistent reservation key of a host from its UUID, the key is stable across
+// restarts and never zero as a zero key unregisters the host
+func KeyFromHostUUID(hostUUID string) (uint64, error) {
+	hostUUID = strings.ToLower(strings.TrimSpace(hostUUID))
+	if hostUUID == "" {
+		return 0, cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageEmptyHostUUID)
+	}
+	sum := sha256.Sum256([]byte(hostUUID))
+	key := binary.BigEndian.Uint64(sum[:8])
+	if key == 0 {
+		key = 1
+	}
+	return key, nil
+}
+
+// FormatKey returns the hex form of a reservation key used in the CHAPI objects
+func FormatKey(key uint64) string {
+	return fmt.Sprintf("%016x", key)
+}
+
+// ParseKey parses the hex form of a reservation key, with or without a 0x prefix
+func ParseKey(key string) (uint64, error) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(key), "0x"), 16, 64)
+	if err != nil || value == 0 {
+		return 0, cerrors.NewChapiError(cerrors.InvalidArgument, fmt.Sprintf(errorMessageInvalidKey, key))
+	}
+	return value, nil
+}
+
+// GetReservation reports the persistent reservation state of the device along with the key of this host
+func (plugin *ReservationPlugin) GetReservation(serialNumber string, hostUUID string) (*model.Reservation, error) {
+	log.Tracef(">>>>> GetReservation, serialNumber=%v", serialNumber)
+	defer log.Trace("<<<<< GetReservation")
+	if serialNumber == "" {
+		return nil, cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageSerialNumberMissing)
+	}
+	hostKey, err := KeyFromHostUUID(hostUUID)
+	if err != nil {
+		return nil, err
+	}
+	return plugin.getReservation(serialNumber, hostKey)
+}
+
+// UpdateReservation applies the reservation action of the request with the key of this host and reports the
+// resulting reservation state
+func (plugin *ReservationPlugin) UpdateReservation(serialNumber string, hostUUID string, request *model.ReservationRequest) (*model.Reservation, error) {
+	log.Tracef(">>>>> UpdateReservation, serialNumber=%v", serialNumber)
+	defer log.Trace("<<<<< UpdateReservation")
+	if serialNumber == "" {
+		return nil, cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageSerialNumberMissing)
+	}
+	prType, preemptKey, err := ValidateRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	hostKey, err := KeyFromHostUUID(hostUUID)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Applying reservation action %v type %v to %v with key %v", request.Action, sgio.PRTypeName(prType), serialNumber, FormatKey(hostKey))
+	if err = plugin.updateReservation(serialNumber, hostKey, request.Action, prType, preemptKey); err != nil {
+		// the command error is wrapped by the multipath device helpers
+		var commandErr *sgio.CommandError
+		if errors.As(err, &commandErr) && commandErr.IsReservationConflict() {
+			return nil, cerrors.NewChapiError(cerrors.PermissionDenied, fmt.Sprintf(errorMessageReservationConflict, serialNumber))
+		}
+		return nil, cerrors.NewChapiError(err)
+	}
+	return plugin.getReservation(serialNumber, hostKey)
+}
+
+// ValidateRequest checks the reservation request and returns its reservation type and preempt key
+func ValidateRequest(request *model.ReservationRequest) (uint8, uint64, error) {
+	if request == nil {
+		return 0, 0, cerrors.NewChapiError(cerrors.InvalidArgument, fmt.Sprintf(errorMessageInvalidAction, ""))
+	}
+	switch request.Action {
+	case model.ReservationActionRegister, model.ReservationActionUnregister, model.ReservationActionReserve,
+		model.ReservationActionRelease, model.ReservationActionPreempt, model.ReservationActionClear:
+	default:
+		return 0, 0, cerrors.NewChapiError(cerrors.InvalidArgument, fmt.Sprintf(errorMessageInvalidAction, request.Action))
+	}
+	typeName := request.Type
+	if typeName == "" {
+		typeName = DefaultReservationType
+	}
+	prType, err := sgio.ParsePRType(typeName)
+	if err != nil {
+		return 0, 0, cerrors.NewChapiError(cerrors.InvalidArgument, err.Error())
+	}
+	var preemptKey uint64
+	if request.Action == model.ReservationActionPreempt {
+		if request.PreemptKey == "" {
+			return 0, 0, cerrors.NewChapiError(cerrors.InvalidArgument, errorMessagePreemptKeyRequired)
+		}
+		if preemptKey, err = ParseKey(request.PreemptKey); err != nil {
+			return 0, 0, err
+		}
+	}
+	return prType, preemptKey, nil
+}
+
+// NewReservation builds the CHAPI reservation object from the persistent reservation data of a device
+func NewReservation(serialNumber string, hostKey uint64, keys *sgio.PRKeys, reservation *sgio.PRReservation) *model.Reservation {
+	result := &model.Reservation{SerialNumber: serialNumber, HostKey: FormatKey(hostKey)}
+	if keys != nil {
+		result.Generation = keys.Generation
+		for _, key := range keys.Keys {
+			formatted := FormatKey(key)
+			// each path registers the same key, report it once
+			if !containsKey(result.Keys, formatted) {
+				result.Keys = append(result.Keys, formatted)
+			}
+			if key == hostKey {
+				result.Registered = true
+			}
+		}
+	}
+	if reservation != nil && reservation.Reserved {
+		result.Reserved = true
+		result.Type = sgio.PRTypeName(reservation.Type)
+		// the all-registrants types report a zero key, every registrant holds the reservation
+		if reservation.Key != 0 {
+			result.Holder = FormatKey(reservation.Key)
+		}
+	}
+	return result
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
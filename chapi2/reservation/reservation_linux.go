@@ -0,0 +1,48 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package reservation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/linux"
+	"github.com/hpe-storage/common-host-libs/sgio"
+)
+
+const errorMessageUnsupported = "persistent reservations not supported by the device: %v"
+
+// getReservation reads the registered keys and the reservation through the paths of the multipath device.
+// An Unimplemented error is returned if the device does not support persistent reservations.
+func (plugin *ReservationPlugin) getReservation(serialNumber string, hostKey uint64) (*model.Reservation, error) {
+	keys, reservation, err := linux.GetPersistentReservation(serialNumber)
+	if err != nil {
+		var commandErr *sgio.CommandError
+		if errors.As(err, &commandErr) && commandErr.IsUnsupported() {
+			return nil, cerrors.NewChapiErrorf(cerrors.Unimplemented, errorMessageUnsupported, err)
+		}
+		return nil, cerrors.NewChapiError(err)
+	}
+	return NewReservation(serialNumber, hostKey, keys, reservation), nil
+}
+
+// updateReservation issues the persistent reservation action on the paths of the multipath device
+func (plugin *ReservationPlugin) updateReservation(serialNumber string, hostKey uint64, action string, prType uint8, preemptKey uint64) error {
+	switch action {
+	case model.ReservationActionRegister:
+		return linux.RegisterReservationKey(serialNumber, hostKey)
+	case model.ReservationActionUnregister:
+		return linux.UnregisterReservationKey(serialNumber)
+	case model.ReservationActionReserve:
+		return linux.ReservePersistentReservation(serialNumber, hostKey, prType)
+	case model.ReservationActionRelease:
+		return linux.ReleasePersistentReservation(serialNumber, hostKey, prType)
+	case model.ReservationActionPreempt:
+		return linux.PreemptPersistentReservation(serialNumber, hostKey, preemptKey, prType)
+	case model.ReservationActionClear:
+		return linux.ClearPersistentReservation(serialNumber, hostKey)
+	}
+	return fmt.Errorf(errorMessageInvalidAction, action)
+}
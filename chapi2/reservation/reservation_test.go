@@ -0,0 +1,96 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package reservation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/sgio"
+)
+
+func TestKeyFromHostUUID(t *testing.T) {
+	key, err := KeyFromHostUUID("827c1723-5742-4661-be56-121edb7e263c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key == 0 {
+		t.Error("expected a non-zero key")
+	}
+	// the key must not depend on the case or padding of the UUID
+	if other, _ := KeyFromHostUUID(" 827C1723-5742-4661-BE56-121EDB7E263C\n"); other != key {
+		t.Errorf("expected key %x, got %x", key, other)
+	}
+	if other, _ := KeyFromHostUUID("4ba7f223-f4ce-4aca-99ff-a150b6df50be"); other == key {
+		t.Error("expected different hosts to derive different keys")
+	}
+	if _, err = KeyFromHostUUID(""); err == nil {
+		t.Error("expected an error for an empty host uuid")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for _, key := range []string{"00000000deadbeef", "0xDEADBEEF", "deadbeef"} {
+		if value, err := ParseKey(key); err != nil || value != 0xdeadbeef {
+			t.Errorf("%s: expected 0xdeadbeef, got %x %v", key, value, err)
+		}
+	}
+	for _, key := range []string{"", "0", "not-hex", "1234567890abcdef01"} {
+		if _, err := ParseKey(key); err == nil {
+			t.Errorf("%s: expected an error", key)
+		}
+	}
+	if FormatKey(0xdeadbeef) != "00000000deadbeef" {
+		t.Errorf("unexpected formatted key %s", FormatKey(0xdeadbeef))
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	prType, _, err := ValidateRequest(&model.ReservationRequest{Action: model.ReservationActionReserve})
+	if err != nil || prType != sgio.PRTypeWriteExclusiveRegistrantsOnly {
+		t.Errorf("expected the default reservation type, got %d %v", prType, err)
+	}
+	prType, preemptKey, err := ValidateRequest(&model.ReservationRequest{Action: model.ReservationActionPreempt, Type: "exclusive-access", PreemptKey: "1234"})
+	if err != nil || prType != sgio.PRTypeExclusiveAccess || preemptKey != 0x1234 {
+		t.Errorf("unexpected preempt request %d %x %v", prType, preemptKey, err)
+	}
+
+	invalid := map[string]*model.ReservationRequest{
+		"nil request":         nil,
+		"unknown action":      {Action: "steal"},
+		"unknown type":        {Action: model.ReservationActionReserve, Type: "shared"},
+		"missing preempt key": {Action: model.ReservationActionPreempt},
+		"invalid preempt key": {Action: model.ReservationActionPreempt, PreemptKey: "xyz"},
+	}
+	for name, request := range invalid {
+		_, _, err = ValidateRequest(request)
+		if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+			t.Errorf("%s: expected an invalid argument error, got %v", name, err)
+		}
+	}
+}
+
+func TestNewReservation(t *testing.T) {
+	keys := &sgio.PRKeys{Generation: 4, Keys: []uint64{0x10, 0x10, 0x20}}
+	reservation := NewReservation("serial1", 0x10, keys, &sgio.PRReservation{Reserved: true, Key: 0x10, Type: sgio.PRTypeWriteExclusiveRegistrantsOnly})
+	expected := &model.Reservation{
+		SerialNumber: "serial1",
+		HostKey:      "0000000000000010",
+		Registered:   true,
+		Keys:         []string{"0000000000000010", "0000000000000020"},
+		Reserved:     true,
+		Holder:       "0000000000000010",
+		Type:         "write-exclusive-registrants-only",
+		Generation:   4,
+	}
+	if !reflect.DeepEqual(reservation, expected) {
+		t.Errorf("expected %+v, got %+v", expected, reservation)
+	}
+
+	reservation = NewReservation("serial1", 0x30, keys, &sgio.PRReservation{})
+	if reservation.Registered || reservation.Reserved || reservation.Holder != "" {
+		t.Errorf("expected an unregistered and unreserved device, got %+v", reservation)
+	}
+}
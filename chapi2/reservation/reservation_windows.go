@@ -0,0 +1,20 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package reservation
+
+import (
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+)
+
+const errorMessageNotYetImplemented = "persistent reservations not yet implemented on windows"
+
+// getReservation is not yet implemented on Windows
+func (plugin *ReservationPlugin) getReservation(serialNumber string, hostKey uint64) (*model.Reservation, error) {
+	return nil, cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// updateReservation is not yet implemented on Windows
+func (plugin *ReservationPlugin) updateReservation(serialNumber string, hostKey uint64, action string, prType uint8, preemptKey uint64) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
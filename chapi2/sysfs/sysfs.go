@@ -0,0 +1,76 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+// Package sysfs walks the device mapper devices of the sysfs block device tree, e.g. the
+// partition and LUKS mappings holding a multipath device.
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+const (
+	// DevMapperPath is the directory of the device mapper device nodes
+	DevMapperPath = "/dev/mapper/"
+	// CryptUUIDPrefix is the dm uuid prefix of a dm-crypt (LUKS) mapping, e.g. "CRYPT-LUKS2-..."
+	CryptUUIDPrefix = "CRYPT-"
+	// PartUUIDPrefix is the dm uuid prefix of a partition mapping, e.g. "part1-mpath-2f6d..."
+	PartUUIDPrefix = "part"
+)
+
+// SysBlockPath is the sysfs block device directory, a variable so tests can use a fake sysfs tree
+var SysBlockPath = "/sys/block"
+
+// Holder is a device mapper device holding another block device
+type Holder struct {
+	DmName string // e.g. "dm-5"
+	UUID   string // e.g. "part1-mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1"
+}
+
+// ReadFile returns the first line of a file below SysBlockPath, e.g. ReadFile("dm-3", "dm", "name")
+func ReadFile(elem ...string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(append([]string{SysBlockPath}, elem...)...))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// GetHolders returns the device mapper devices holding the given block device.  A device
+// without a holders directory has no holders, holders whose dm uuid cannot be read are skipped.
+func GetHolders(dmName string) ([]*Holder, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(SysBlockPath, dmName, "holders"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var holders []*Holder
+	for _, entry := range entries {
+		uuid, err := ReadFile(entry.Name(), "dm", "uuid")
+		if err != nil {
+			log.Tracef("Ignoring holder %v of %v, err=%v", entry.Name(), dmName, err)
+			continue
+		}
+		holders = append(holders, &Holder{DmName: entry.Name(), UUID: uuid})
+	}
+	return holders, nil
+}
+
+// GetHolderNames returns the device mapper names (e.g. "mpathb1") of the holders of the given
+// block device whose dm uuid starts with uuidPrefix
+func GetHolderNames(dmName string, uuidPrefix string) []string {
+	holders, _ := GetHolders(dmName)
+	var names []string
+	for _, holder := range holders {
+		if !strings.HasPrefix(holder.UUID, uuidPrefix) {
+			continue
+		}
+		if name, err := ReadFile(holder.DmName, "dm", "name"); err == nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
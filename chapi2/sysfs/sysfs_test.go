@@ -0,0 +1,59 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package sysfs_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/sysfs"
+	"github.com/hpe-storage/common-host-libs/chapi2/sysfs/sysfstest"
+)
+
+// useFakeSysfs creates a multipath device dm-3 held by a partition (dm-5) and a LUKS mapping (dm-7), and a
+// multipath device dm-4 used by LVM
+func useFakeSysfs(t *testing.T) {
+	root := t.TempDir()
+	sysfstest.AddDmDevice(t, root, "dm-3", "mpathb", "mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1", "dm-5", "dm-7")
+	sysfstest.AddDmDevice(t, root, "dm-5", "mpathb1", "part1-mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1")
+	sysfstest.AddDmDevice(t, root, "dm-7", "enc-mpathb", "CRYPT-LUKS1-5a8c7e2c8f1d4e4b9d1c4f7b2e3a6d90-enc-mpathb")
+	sysfstest.AddDmDevice(t, root, "dm-4", "mpathc", "mpath-2aa3c1a4b2e8d9b06c9ce900d2a3c5e1", "dm-8")
+	sysfstest.WriteFile(t, root, "dm-8/dm/name", "vg-lv")
+	sysfstest.UseSysBlock(t, root)
+}
+
+func TestGetHolders(t *testing.T) {
+	useFakeSysfs(t)
+	holders, err := sysfs.GetHolders("dm-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*sysfs.Holder{
+		{DmName: "dm-5", UUID: "part1-mpath-2f6d3c1a4b2e8d9b06c9ce900d2a3c5e1"},
+		{DmName: "dm-7", UUID: "CRYPT-LUKS1-5a8c7e2c8f1d4e4b9d1c4f7b2e3a6d90-enc-mpathb"},
+	}
+	if !reflect.DeepEqual(holders, expected) {
+		t.Errorf("unexpected holders %+v", holders)
+	}
+
+	// A holder without a dm uuid is skipped and a missing device has no holders
+	if holders, err = sysfs.GetHolders("dm-4"); err != nil || len(holders) != 0 {
+		t.Errorf("expected no holders of dm-4, got %+v, err=%v", holders, err)
+	}
+	if holders, err = sysfs.GetHolders("dm-99"); err != nil || len(holders) != 0 {
+		t.Errorf("expected no holders of dm-99, got %+v, err=%v", holders, err)
+	}
+}
+
+func TestGetHolderNames(t *testing.T) {
+	useFakeSysfs(t)
+	if names := sysfs.GetHolderNames("dm-3", sysfs.CryptUUIDPrefix); !reflect.DeepEqual(names, []string{"enc-mpathb"}) {
+		t.Errorf("unexpected LUKS mappings %v", names)
+	}
+	if names := sysfs.GetHolderNames("dm-3", sysfs.PartUUIDPrefix); !reflect.DeepEqual(names, []string{"mpathb1"}) {
+		t.Errorf("unexpected partitions %v", names)
+	}
+	if names := sysfs.GetHolderNames("dm-5", sysfs.CryptUUIDPrefix); len(names) != 0 {
+		t.Errorf("unexpected LUKS mappings %v", names)
+	}
+}
@@ -0,0 +1,47 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+// Package sysfstest builds fake sysfs block device trees for the unit tests of the chapi2 plugins
+package sysfstest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/sysfs"
+)
+
+// WriteFile creates a file, and its parent directories, in the fake sysfs tree
+func WriteFile(t *testing.T, root string, name string, value string) {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// AddDmDevice adds a device mapper device, held by the given holders, to the fake sysfs tree
+func AddDmDevice(t *testing.T, root string, dmName string, name string, uuid string, holders ...string) {
+	t.Helper()
+	WriteFile(t, root, dmName+"/dm/name", name)
+	WriteFile(t, root, dmName+"/dm/uuid", uuid)
+	if err := os.MkdirAll(filepath.Join(root, dmName, "holders"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, holder := range holders {
+		if err := os.Symlink(filepath.Join("..", "..", holder), filepath.Join(root, dmName, "holders", holder)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// UseSysBlock points sysfs.SysBlockPath to the fake tree at root until the test completes
+func UseSysBlock(t *testing.T, root string) {
+	previous := sysfs.SysBlockPath
+	sysfs.SysBlockPath = root
+	t.Cleanup(func() { sysfs.SysBlockPath = previous })
+}
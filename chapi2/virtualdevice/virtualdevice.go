@@ -3,9 +3,22 @@
 package virtualdevice
 
 import (
+	"context"
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
 	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	log "github.com/hpe-storage/common-host-libs/logger"
 )
 
+const (
+	// Shared error messages
+	errorMessageDeviceNotFound          = "device not found"
+	errorMessageDeviceNotReady          = "device %v not ready"
+	errorMessageMissingVirtualDevice    = "missing VirtualDev object"
+	errorMessageMissingPciSlotNumber    = "missing pci slot number"
+	errorMessageSerialNumberNotProvided = "serial number not provided"
+)
+
+// VirtualDevPlugin manages hypervisor attached disks (e.g. VMware VMDKs behind a pvscsi controller)
 type VirtualDevPlugin struct {
 }
 
@@ -13,22 +26,80 @@ func NewVirtualDevPlugin() *VirtualDevPlugin {
 	return &VirtualDevPlugin{}
 }
 
+// GetDevice returns the virtual device with the given serial number.  A nil device is returned if
+// the serial number is not attached to this host.
+func (plugin *VirtualDevPlugin) GetDevice(serialNumber string) (*model.Device, error) {
+	log.Tracef(">>>>> GetDevice, serialNumber=%v", serialNumber)
+	defer log.Trace("<<<<< GetDevice")
+	return plugin.getDevice(serialNumber)
+}
+
+// HasVirtualDevices returns true if virtual devices can be attached to this host
+func (plugin *VirtualDevPlugin) HasVirtualDevices() bool {
+	return plugin.hasVirtualDevices()
+}
+
+// GetDeviceName returns the full path name of the virtual device with the given serial number
 func (plugin *VirtualDevPlugin) GetDeviceName(serial string) (*string, error) {
-	return nil, nil
+	device, err := plugin.GetDevice(serial)
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
+		err = cerrors.NewChapiError(cerrors.NotFound, errorMessageDeviceNotFound)
+		log.Error(err)
+		return nil, err
+	}
+	return &device.AltFullPathName, nil
 }
 
-func (plugin *VirtualDevPlugin) AttachDevice(publishInfo *model.PublishInfo) error {
-	return nil
+// AttachDevice rescans the hypervisor SCSI controller described by publishInfo.VirtualDev and
+// waits for the virtual device to be ready.  If the device is successfully attached, a
+// model.Device object is returned for the attached device.
+func (plugin *VirtualDevPlugin) AttachDevice(ctx context.Context, publishInfo *model.PublishInfo) (*model.Device, error) {
+	log.FromContext(ctx).Trace(">>>>> AttachDevice called")
+	defer log.FromContext(ctx).Trace("<<<<< AttachDevice")
+
+	// Fail request if the virtual device details are not provided
+	var err error
+	if publishInfo.SerialNumber == "" {
+		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageSerialNumberNotProvided)
+	} else if publishInfo.VirtualDev == nil {
+		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMissingVirtualDevice)
+	} else if publishInfo.VirtualDev.PciSlotNumber == "" {
+		err = cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageMissingPciSlotNumber)
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err)
+		return nil, err
+	}
+
+	log.FromContext(ctx).Infof("Attach virtual device, serialNumber=%v, pciSlotNumber=%v, scsiController=%v",
+		publishInfo.SerialNumber, publishInfo.VirtualDev.PciSlotNumber, publishInfo.VirtualDev.ScsiController)
+	return plugin.attachDevice(ctx, publishInfo.SerialNumber, *publishInfo.VirtualDev)
 }
 
-func (plugin *VirtualDevPlugin) DetachDevice(device model.Device) error {
-	return nil
+// DetachDevice offlines and removes the given virtual device from this host.  The hypervisor is
+// responsible for detaching the disk from the virtual machine afterwards.
+func (plugin *VirtualDevPlugin) DetachDevice(ctx context.Context, device model.Device) error {
+	log.FromContext(ctx).Trace(">>>>> DetachDevice called")
+	defer log.FromContext(ctx).Trace("<<<<< DetachDevice")
+
+	log.FromContext(ctx).Infof("Detach virtual device, serialNumber=%v", device.SerialNumber)
+
+	// Start by offlining the device on the host
+	if err := plugin.OfflineDevice(device); err != nil {
+		return err
+	}
+	return plugin.detachDevice(device)
 }
 
+// IsDeviceReady returns nil if the virtual device with the given serial number is ready for I/O
 func (plugin *VirtualDevPlugin) IsDeviceReady(serial string) error {
-	return nil
+	return plugin.isDeviceReady(serial)
 }
 
+// OfflineDevice is called to offline the given virtual device
 func (plugin *VirtualDevPlugin) OfflineDevice(device model.Device) error {
-	return nil
+	return plugin.offlineDevice(device)
 }
@@ -0,0 +1,306 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package virtualdevice
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+	log "github.com/hpe-storage/common-host-libs/logger"
+	linuxmodel "github.com/hpe-storage/common-host-libs/model"
+	"github.com/hpe-storage/common-host-libs/sgio"
+)
+
+const (
+	devPath               = "/dev/"
+	scsiDiskPrefix        = "sd"
+	scsiDeviceStateOnline = "running"
+	sectorSize            = 512
+
+	errorMessageInvalidScsiController = `invalid scsi controller "%v"`
+	errorMessagePciSlotNotFound       = "no scsi controller found in pci slot %v"
+)
+
+var (
+	// sysfs locations (swapped by unit tests)
+	sysBlockPath      = "/sys/block"
+	sysPciSlotsPath   = "/sys/bus/pci/slots"
+	sysPciDevicesPath = "/sys/bus/pci/devices"
+	sysScsiHostPath   = "/sys/class/scsi_host"
+
+	// How long, and how often, we check for the virtual device after a controller rescan
+	attachTimeout      = 30 * time.Second
+	attachPollInterval = time.Second
+
+	// testUnitReady is swapped by unit tests since they have no SCSI device to send it to
+	testUnitReady = sgio.TestUnitReady
+)
+
+// scsiDisk describes a SCSI disk enumerated from sysfs
+type scsiDisk struct {
+	name  string // Block device name (e.g. "sdb")
+	hcil  string // Host:Channel:Target:Lun (e.g. "2:0:1:0")
+	wwid  string // Device identifier (e.g. "naa.6000c2912345...")
+	state string // SCSI device state (e.g. "running", "offline")
+}
+
+// getDevice returns the SCSI disk, attached to a hypervisor SCSI controller, with the given serial
+// number.  Nil is returned if not found.
+func (plugin *VirtualDevPlugin) getDevice(serialNumber string) (*model.Device, error) {
+	if serialNumber == "" {
+		err := cerrors.NewChapiError(cerrors.InvalidArgument, errorMessageSerialNumberNotProvided)
+		log.Error(err)
+		return nil, err
+	}
+	return findDevice(serialNumber, getVirtualScsiHosts()), nil
+}
+
+// hasVirtualDevices returns true if this host has a SCSI controller in a PCI slot, the only place
+// virtual devices are attached to
+func (plugin *VirtualDevPlugin) hasVirtualDevices() bool {
+	return len(getVirtualScsiHosts()) > 0
+}
+
+// attachDevice rescans the SCSI controller in the given PCI slot and waits for the disk to be
+// ready.  The ScsiController is either the controller bus number (e.g. "1") or the hypervisor
+// SCSI node (e.g. "1:3" for a VMware disk on scsi1 unit 3).  When the unit number is provided,
+// only that SCSI target is rescanned.
+func (plugin *VirtualDevPlugin) attachDevice(ctx context.Context, serialNumber string, virtualDev model.VirtualDeviceAccessInfo) (*model.Device, error) {
+
+	// Locate the SCSI host of the controller
+	scsiHosts, err := getScsiHosts(virtualDev.PciSlotNumber)
+	if err != nil {
+		return nil, err
+	}
+	scsiTarget, err := getScsiTarget(virtualDev.ScsiController)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rescan the controller
+	for _, scsiHost := range scsiHosts {
+		scanPath := filepath.Join(sysScsiHostPath, scsiHost, "scan")
+		log.FromContext(ctx).Tracef("Rescan %v, target=%v", scanPath, scsiTarget)
+		if err = ioutil.WriteFile(scanPath, []byte("- "+scsiTarget+" -"), 0200); err != nil {
+			err = cerrors.NewChapiError(cerrors.Internal, err)
+			log.FromContext(ctx).Error(err)
+			return nil, err
+		}
+	}
+
+	// Wait for the disk to show up on the controller and be ready for I/O
+	var device *model.Device
+	for expiration := time.Now().Add(attachTimeout); ; time.Sleep(attachPollInterval) {
+		if device = findDevice(serialNumber, scsiHosts); (device != nil) && (isScsiDiskReady(device) == nil) {
+			log.FromContext(ctx).Infof("Virtual device %v attached as %v", serialNumber, device.AltFullPathName)
+			return device, nil
+		}
+		if time.Now().After(expiration) {
+			break
+		}
+	}
+
+	if device == nil {
+		err = cerrors.NewChapiError(cerrors.NotFound, errorMessageDeviceNotFound)
+	} else {
+		err = cerrors.NewChapiErrorf(cerrors.Timeout, errorMessageDeviceNotReady, device.AltFullPathName)
+	}
+	log.FromContext(ctx).Error(err)
+	return nil, err
+}
+
+// detachDevice removes the SCSI disk from the host
+func (plugin *VirtualDevPlugin) detachDevice(device model.Device) error {
+	return writeScsiDeviceFile(device, "delete", "1")
+}
+
+// isDeviceReady returns nil if the SCSI disk with the given serial number is ready for I/O
+func (plugin *VirtualDevPlugin) isDeviceReady(serialNumber string) error {
+	device, err := plugin.getDevice(serialNumber)
+	if err != nil {
+		return err
+	}
+	if device == nil {
+		err = cerrors.NewChapiError(cerrors.NotFound, errorMessageDeviceNotFound)
+		log.Error(err)
+		return err
+	}
+	return isScsiDiskReady(device)
+}
+
+// offlineDevice offlines the SCSI disk so no further I/O is sent to it
+func (plugin *VirtualDevPlugin) offlineDevice(device model.Device) error {
+	return writeScsiDeviceFile(device, "state", "offline")
+}
+
+// isScsiDiskReady returns nil if the SCSI disk is running and responds to a TEST UNIT READY
+func isScsiDiskReady(device *model.Device) error {
+	if (device.Private == nil) || (len(device.Private.Paths) == 0) || (device.Private.Paths[0].State != scsiDeviceStateOnline) {
+		return cerrors.NewChapiErrorf(cerrors.Internal, errorMessageDeviceNotReady, device.AltFullPathName)
+	}
+	if err := testUnitReady(device.AltFullPathName); err != nil {
+		log.Tracef("TEST UNIT READY failed on %v, err=%v", device.AltFullPathName, err)
+		return cerrors.NewChapiErrorf(cerrors.Internal, errorMessageDeviceNotReady, device.AltFullPathName)
+	}
+	return nil
+}
+
+// writeScsiDeviceFile writes the value to the SCSI device sysfs file of the disk.  Nothing is
+// done if the disk is already gone.
+func writeScsiDeviceFile(device model.Device, name string, value string) error {
+	path := filepath.Join(sysBlockPath, strings.TrimPrefix(device.Pathname, devPath), "device", name)
+	log.Tracef("Write %v to %v", value, path)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		log.Tracef("%v not present", path)
+		return nil
+	}
+	if err := ioutil.WriteFile(path, []byte(value), 0200); err != nil {
+		err = cerrors.NewChapiError(cerrors.Internal, err)
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// getScsiHosts returns the SCSI hosts (e.g. "host2") of the controller in the given PCI slot
+func getScsiHosts(pciSlotNumber string) ([]string, error) {
+	// The slot address is the PCI domain:bus:device (e.g. "0000:03:00") without the function
+	address := readSysfsValue(filepath.Join(sysPciSlotsPath, pciSlotNumber, "address"))
+	var scsiHosts []string
+	if address != "" {
+		hostPaths, _ := filepath.Glob(filepath.Join(sysPciDevicesPath, address+".*", "host*"))
+		for _, hostPath := range hostPaths {
+			scsiHosts = append(scsiHosts, filepath.Base(hostPath))
+		}
+	}
+	if len(scsiHosts) == 0 {
+		err := cerrors.NewChapiErrorf(cerrors.NotFound, errorMessagePciSlotNotFound, pciSlotNumber)
+		log.Error(err)
+		return nil, err
+	}
+	log.Tracef("pciSlotNumber=%v, address=%v, scsiHosts=%v", pciSlotNumber, address, scsiHosts)
+	return scsiHosts, nil
+}
+
+// getVirtualScsiHosts returns the SCSI hosts of the controllers in all the PCI slots
+func getVirtualScsiHosts() []string {
+	var scsiHosts []string
+	slotPaths, _ := filepath.Glob(filepath.Join(sysPciSlotsPath, "*"))
+	for _, slotPath := range slotPaths {
+		if address := readSysfsValue(filepath.Join(slotPath, "address")); address != "" {
+			hostPaths, _ := filepath.Glob(filepath.Join(sysPciDevicesPath, address+".*", "host*"))
+			for _, hostPath := range hostPaths {
+				scsiHosts = append(scsiHosts, filepath.Base(hostPath))
+			}
+		}
+	}
+	return scsiHosts
+}
+
+// getScsiTarget returns the SCSI target to rescan for the given scsiController ("-" for all)
+func getScsiTarget(scsiController string) (string, error) {
+	if scsiController == "" {
+		return "-", nil
+	}
+	fields := strings.Split(scsiController, ":")
+	for _, field := range fields {
+		if _, err := strconv.ParseUint(field, 10, 32); (err != nil) || (len(fields) > 2) {
+			err := cerrors.NewChapiErrorf(cerrors.InvalidArgument, errorMessageInvalidScsiController, scsiController)
+			log.Error(err)
+			return "", err
+		}
+	}
+	if len(fields) == 1 {
+		return "-", nil
+	}
+	return fields[1], nil
+}
+
+// findDevice returns the SCSI disk, attached to one of the given SCSI hosts, with the given
+// serial number.  Nil is returned if not found.
+func findDevice(serialNumber string, scsiHosts []string) *model.Device {
+	for _, disk := range getScsiDisks() {
+		if !disk.hasSerialNumber(serialNumber) {
+			continue
+		}
+		for _, scsiHost := range scsiHosts {
+			if strings.HasPrefix(disk.hcil, strings.TrimPrefix(scsiHost, "host")+":") {
+				return newDevice(serialNumber, disk)
+			}
+		}
+	}
+	return nil
+}
+
+// getScsiDisks enumerates the SCSI disks from sysfs
+func getScsiDisks() []*scsiDisk {
+	diskPaths, _ := filepath.Glob(filepath.Join(sysBlockPath, scsiDiskPrefix+"*"))
+	var disks []*scsiDisk
+	for _, diskPath := range diskPaths {
+		// The device link points to the SCSI device (e.g. "../../../2:0:1:0")
+		scsiDevicePath, err := filepath.EvalSymlinks(filepath.Join(diskPath, "device"))
+		if err != nil {
+			continue
+		}
+		disks = append(disks, &scsiDisk{
+			name:  filepath.Base(diskPath),
+			hcil:  filepath.Base(scsiDevicePath),
+			wwid:  readSysfsValue(filepath.Join(scsiDevicePath, "wwid")),
+			state: readSysfsValue(filepath.Join(scsiDevicePath, "state")),
+		})
+	}
+	return disks
+}
+
+// hasSerialNumber returns true if the disk identifier is the serial number (e.g. "naa.6000c29..."
+// for the VMware disk with serial number "6000c29...")
+func (disk *scsiDisk) hasSerialNumber(serialNumber string) bool {
+	return (disk.wwid != "") && (normalizeSerialNumber(disk.wwid) == normalizeSerialNumber(serialNumber))
+}
+
+// normalizeSerialNumber returns the lower case identifier without its SCSI name string designator
+// (e.g. "naa.6000C29..." becomes "6000c29...")
+func normalizeSerialNumber(identifier string) string {
+	identifier = strings.ToLower(strings.TrimSpace(identifier))
+	for _, designator := range []string{"naa.", "eui.", "t10."} {
+		identifier = strings.TrimPrefix(identifier, designator)
+	}
+	return identifier
+}
+
+// newDevice converts a SCSI disk into a CHAPI device
+func newDevice(serialNumber string, disk *scsiDisk) *model.Device {
+	device := &model.Device{
+		SerialNumber:    serialNumber,
+		Pathname:        disk.name,
+		AltFullPathName: devPath + disk.name,
+		State:           linuxmodel.FailedState.String(),
+		Private:         &model.DevicePrivate{},
+	}
+	if disk.state == scsiDeviceStateOnline {
+		device.State = linuxmodel.ActiveState.String()
+	}
+	if sectors, err := strconv.ParseUint(readSysfsValue(filepath.Join(sysBlockPath, disk.name, "size")), 10, 64); err == nil {
+		device.Size = sectors * sectorSize
+	}
+
+	path := model.Path{Name: disk.name, Hcils: disk.hcil, State: disk.state}
+	if majorMinor := strings.Split(readSysfsValue(filepath.Join(sysBlockPath, disk.name, "dev")), ":"); len(majorMinor) == 2 {
+		path.Major, path.Minor = majorMinor[0], majorMinor[1]
+	}
+	device.Private.Paths = []model.Path{path}
+	return device
+}
+
+// readSysfsValue is a helper to read a single sysfs value (empty if not present)
+func readSysfsValue(path string) string {
+	value, _ := ioutil.ReadFile(path)
+	return strings.TrimSpace(string(value))
+}
@@ -0,0 +1,204 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package virtualdevice
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+)
+
+const testSerialNumber = "6000c2912345678901234567890abcde"
+
+// writeSysfsFile creates a file, and its parent directories, in the fake sysfs tree
+func writeSysfsFile(t *testing.T, root string, name string, value string) {
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addScsiDisk adds a SCSI disk, attached to the given host adapter, to the fake sysfs tree
+func addScsiDisk(t *testing.T, root string, name string, hcil string, wwid string, state string) {
+	writeSysfsFile(t, root, "devices/"+hcil+"/wwid", wwid)
+	writeSysfsFile(t, root, "devices/"+hcil+"/state", state)
+	writeSysfsFile(t, root, "block/"+name+"/size", "2097152")
+	writeSysfsFile(t, root, "block/"+name+"/dev", "8:16")
+	if err := os.Symlink(filepath.Join("..", "..", "devices", hcil), filepath.Join(root, "block", name, "device")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useFakeSysfs points the sysfs paths to a fake tree.  PCI slot 160 holds the pvscsi controller
+// host2 with a VMDK (sdb), PCI slot 192 holds host3 without disks, and host0 has a local disk.
+func useFakeSysfs(t *testing.T) string {
+	root := t.TempDir()
+	addScsiDisk(t, root, "sda", "0:0:0:0", "t10.ATA     VBOX HARDDISK", "running")
+	addScsiDisk(t, root, "sdb", "2:0:1:0", "naa."+testSerialNumber, "running")
+	writeSysfsFile(t, root, "slots/160/address", "0000:03:00")
+	writeSysfsFile(t, root, "slots/192/address", "0000:0b:00")
+	writeSysfsFile(t, root, "pci/0000:03:00.0/host2/scsi_host/host2/proc_name", "vmw_pvscsi")
+	writeSysfsFile(t, root, "pci/0000:0b:00.0/host3/scsi_host/host3/proc_name", "vmw_pvscsi")
+	writeSysfsFile(t, root, "scsi_host/host2/scan", "")
+	writeSysfsFile(t, root, "scsi_host/host3/scan", "")
+
+	previousBlock, previousSlots, previousDevices, previousHosts := sysBlockPath, sysPciSlotsPath, sysPciDevicesPath, sysScsiHostPath
+	previousTimeout, previousInterval, previousTestUnitReady := attachTimeout, attachPollInterval, testUnitReady
+	sysBlockPath = filepath.Join(root, "block")
+	sysPciSlotsPath = filepath.Join(root, "slots")
+	sysPciDevicesPath = filepath.Join(root, "pci")
+	sysScsiHostPath = filepath.Join(root, "scsi_host")
+	attachTimeout, attachPollInterval = 10*time.Millisecond, time.Millisecond
+	testUnitReady = func(device string) error { return nil }
+	t.Cleanup(func() {
+		sysBlockPath, sysPciSlotsPath, sysPciDevicesPath, sysScsiHostPath = previousBlock, previousSlots, previousDevices, previousHosts
+		attachTimeout, attachPollInterval, testUnitReady = previousTimeout, previousInterval, previousTestUnitReady
+	})
+	return root
+}
+
+func TestGetDevice(t *testing.T) {
+	useFakeSysfs(t)
+	plugin := NewVirtualDevPlugin()
+
+	device, err := plugin.GetDevice("6000C2912345678901234567890ABCDE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &model.Device{
+		SerialNumber:    "6000C2912345678901234567890ABCDE",
+		Pathname:        "sdb",
+		AltFullPathName: "/dev/sdb",
+		Size:            1024 * 1024 * 1024,
+		State:           "active",
+		Private: &model.DevicePrivate{
+			Paths: []model.Path{{Name: "sdb", Major: "8", Minor: "16", Hcils: "2:0:1:0", State: "running"}},
+		},
+	}
+	if !reflect.DeepEqual(device, expected) {
+		t.Errorf("unexpected device %+v", device)
+	}
+
+	if device, err = plugin.GetDevice("6000c29ffffffffffffffffffffffff"); device != nil || err != nil {
+		t.Errorf("unexpected device %+v, err=%v", device, err)
+	}
+	if name, err := plugin.GetDeviceName(testSerialNumber); err != nil || *name != "/dev/sdb" {
+		t.Errorf("unexpected device name, err=%v", err)
+	}
+	if err = plugin.IsDeviceReady(testSerialNumber); err != nil {
+		t.Errorf("device not ready, err=%v", err)
+	}
+}
+
+func TestGetDeviceExactMatch(t *testing.T) {
+	root := useFakeSysfs(t)
+	plugin := NewVirtualDevPlugin()
+
+	// Neither a partial serial number, nor a disk outside of the PCI slots, is a virtual device
+	addScsiDisk(t, root, "sdc", "0:0:1:0", "naa.6000c29aaaaaaaaaaaaaaaaaaaaaaaa", "running")
+	for _, serialNumber := range []string{testSerialNumber[:16], testSerialNumber[8:], "6000c29aaaaaaaaaaaaaaaaaaaaaaaa"} {
+		if device, err := plugin.GetDevice(serialNumber); device != nil || err != nil {
+			t.Errorf("unexpected device %+v for %v, err=%v", device, serialNumber, err)
+		}
+	}
+	if device, err := plugin.GetDevice("naa." + testSerialNumber); device == nil || err != nil {
+		t.Errorf("device not found, err=%v", err)
+	}
+	if !plugin.HasVirtualDevices() {
+		t.Errorf("virtual devices not detected")
+	}
+
+	sysPciSlotsPath = filepath.Join(root, "noslots")
+	if plugin.HasVirtualDevices() {
+		t.Errorf("unexpected virtual devices")
+	}
+}
+
+func TestAttachDevice(t *testing.T) {
+	root := useFakeSysfs(t)
+	plugin := NewVirtualDevPlugin()
+
+	publishInfo := &model.PublishInfo{
+		SerialNumber: testSerialNumber,
+		VirtualDev:   &model.VirtualDeviceAccessInfo{PciSlotNumber: "160", ScsiController: "0:1"},
+	}
+	device, err := plugin.AttachDevice(context.Background(), publishInfo)
+	if err != nil || device.Pathname != "sdb" {
+		t.Fatalf("unexpected device %+v, err=%v", device, err)
+	}
+
+	// Only the SCSI target of the unit number is rescanned
+	if scan, _ := ioutil.ReadFile(filepath.Join(root, "scsi_host", "host2", "scan")); string(scan) != "- 1 -" {
+		t.Errorf("unexpected rescan %q", scan)
+	}
+
+	// The disk is not attached to the controller in slot 192
+	publishInfo.VirtualDev = &model.VirtualDeviceAccessInfo{PciSlotNumber: "192", ScsiController: "1"}
+	_, err = plugin.AttachDevice(context.Background(), publishInfo)
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.NotFound {
+		t.Errorf("unexpected error %v", err)
+	}
+	if scan, _ := ioutil.ReadFile(filepath.Join(root, "scsi_host", "host3", "scan")); string(scan) != "- - -" {
+		t.Errorf("unexpected rescan %q", scan)
+	}
+
+	// Invalid requests
+	tests := []*model.PublishInfo{
+		{VirtualDev: &model.VirtualDeviceAccessInfo{PciSlotNumber: "160"}},
+		{SerialNumber: testSerialNumber},
+		{SerialNumber: testSerialNumber, VirtualDev: &model.VirtualDeviceAccessInfo{ScsiController: "0:1"}},
+		{SerialNumber: testSerialNumber, VirtualDev: &model.VirtualDeviceAccessInfo{PciSlotNumber: "160", ScsiController: "scsi0"}},
+		{SerialNumber: testSerialNumber, VirtualDev: &model.VirtualDeviceAccessInfo{PciSlotNumber: "160", ScsiController: "0:1:0"}},
+	}
+	for _, publishInfo := range tests {
+		_, err = plugin.AttachDevice(context.Background(), publishInfo)
+		if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.InvalidArgument {
+			t.Errorf("unexpected error %v for %+v", err, publishInfo.VirtualDev)
+		}
+	}
+
+	// Unknown PCI slot
+	publishInfo.VirtualDev = &model.VirtualDeviceAccessInfo{PciSlotNumber: "224"}
+	_, err = plugin.AttachDevice(context.Background(), publishInfo)
+	if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.Code != cerrors.NotFound {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestDetachDevice(t *testing.T) {
+	root := useFakeSysfs(t)
+	plugin := NewVirtualDevPlugin()
+
+	// The SCSI device files only exist once the device is detected
+	writeSysfsFile(t, root, "devices/2:0:1:0/delete", "")
+	device, _ := plugin.GetDevice(testSerialNumber)
+	if err := plugin.DetachDevice(context.Background(), *device); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := ioutil.ReadFile(filepath.Join(root, "devices", "2:0:1:0", "state")); string(state) != "offline" {
+		t.Errorf("unexpected state %q", state)
+	}
+	if deleted, _ := ioutil.ReadFile(filepath.Join(root, "devices", "2:0:1:0", "delete")); string(deleted) != "1" {
+		t.Errorf("unexpected delete %q", deleted)
+	}
+
+	// An offline device is not ready
+	if err := plugin.IsDeviceReady(testSerialNumber); err == nil {
+		t.Error("offline device is ready")
+	}
+
+	// Nothing to do once the device is gone
+	if err := plugin.DetachDevice(context.Background(), model.Device{Pathname: "sdz"}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,42 @@
+// (c) Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package virtualdevice
+
+import (
+	"context"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	"github.com/hpe-storage/common-host-libs/chapi2/model"
+)
+
+const errorMessageNotYetImplemented = "virtual devices not yet implemented on windows"
+
+// getDevice is not yet implemented on Windows
+func (plugin *VirtualDevPlugin) getDevice(serialNumber string) (*model.Device, error) {
+	return nil, cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// hasVirtualDevices returns false since virtual devices are not yet implemented on Windows
+func (plugin *VirtualDevPlugin) hasVirtualDevices() bool {
+	return false
+}
+
+// attachDevice is not yet implemented on Windows
+func (plugin *VirtualDevPlugin) attachDevice(ctx context.Context, serialNumber string, virtualDev model.VirtualDeviceAccessInfo) (*model.Device, error) {
+	return nil, cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// detachDevice is not yet implemented on Windows
+func (plugin *VirtualDevPlugin) detachDevice(device model.Device) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// isDeviceReady is not yet implemented on Windows
+func (plugin *VirtualDevPlugin) isDeviceReady(serialNumber string) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
+
+// offlineDevice is not yet implemented on Windows
+func (plugin *VirtualDevPlugin) offlineDevice(device model.Device) error {
+	return cerrors.NewChapiError(cerrors.Unimplemented, errorMessageNotYetImplemented)
+}
@@ -18,6 +18,9 @@ package connectivity
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -26,8 +29,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hpe-storage/common-host-libs/cert"
 	"github.com/hpe-storage/common-host-libs/jsonutil"
 	log "github.com/hpe-storage/common-host-libs/logger"
+	"github.com/sirupsen/logrus"
 )
 
 const (
@@ -99,6 +104,23 @@ func NewHTTPSClient(url string, transport http.RoundTripper) *Client {
 	return NewHTTPSClientWithTimeout(url, transport, defaultTimeout)
 }
 
+// NewPinnedHTTPSClientWithTimeout returns a client that communicates over tls with a server whose certificate is
+// pinned in the trust store under address.  The certificate is pinned on first use.
+func NewPinnedHTTPSClientWithTimeout(url string, address string, store *cert.TrustStore, timeout time.Duration) *Client {
+	return NewHTTPSClientWithTimeout(url, &http.Transport{TLSClientConfig: store.TLSConfig(address)}, timeout)
+}
+
+// NewMutualTLSClientWithTimeout returns a client that presents a client certificate and verifies the server against
+// the given root CAs
+func NewMutualTLSClientWithTimeout(url string, clientCert tls.Certificate, rootCAs *x509.CertPool, timeout time.Duration) *Client {
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      rootCAs,
+		MinVersion:   tls.VersionTLS12,
+	}
+	return NewHTTPSClientWithTimeout(url, &http.Transport{TLSClientConfig: tlsConfig}, timeout)
+}
+
 // NewSocketClient returns a client that communicates over a unix socket using a 30 second connect timeout
 func NewSocketClient(filename string) *Client {
 	return NewSocketClientWithTimeout(filename, defaultTimeout)
@@ -132,8 +154,14 @@ func isParsableError(statusCode int) bool {
 // DoJSON action on path.  payload and response are expected to be structs that decode/encode from/to json
 // Example action=POST, path=/VolumeDriver.Create ...
 // Tries 3 times to get data from the server
-// nolint : To avoid cyclomatic complexity error
 func (client *Client) DoJSON(r *Request) (int, error) {
+	return client.DoJSONWithContext(context.Background(), r)
+}
+
+// DoJSONWithContext is DoJSON bound to ctx.  Canceling ctx, or reaching its deadline, aborts the
+// request and any remaining retries.
+// nolint : To avoid cyclomatic complexity error
+func (client *Client) DoJSONWithContext(ctx context.Context, r *Request) (int, error) {
 	// make sure we have a root slash
 	if !strings.HasPrefix(r.Path, "/") {
 		r.Path = client.pathPrefix + "/" + r.Path
@@ -150,7 +178,7 @@ func (client *Client) DoJSON(r *Request) (int, error) {
 	}
 
 	// build request
-	req, err := http.NewRequest(r.Action, r.Path, &buf)
+	req, err := http.NewRequestWithContext(ctx, r.Action, r.Path, &buf)
 	if err != nil {
 		return 0, err
 	}
@@ -170,7 +198,11 @@ func (client *Client) DoJSON(r *Request) (int, error) {
 	}
 
 	req.Close = true
-	log.Tracef("Request: action=%s path=%s", r.Action, r.Path)
+	log.Tracef("Request: action=%s path=%s", r.Action, log.RedactURL(r.Path))
+	if r.Payload != nil && log.IsLevelEnabled(logrus.TraceLevel) {
+		// Log the payload with sensitive fields (passwords, keys, tokens) redacted
+		log.Tracef("Request payload: %s", log.RedactJSON(buf.Bytes()))
+	}
 
 	// execute the do
 	res, err := doWithRetry(client, req)
@@ -201,9 +233,60 @@ func (client *Client) DoJSON(r *Request) (int, error) {
 	if err != nil {
 		return res.StatusCode, err
 	}
+	if r.Response != nil && log.IsLevelEnabled(logrus.TraceLevel) {
+		log.Tracef("Response: %v", log.Redacted(r.Response))
+	}
 	return res.StatusCode, nil
 }
 
+// DoStream opens a streaming response (e.g. text/event-stream) on path and returns it for the caller
+// to read and close.  The client timeout is not applied to the stream, it lasts until ctx is
+// canceled or the server closes it.  Error responses are decoded into r.ResponseError, and the
+// response is returned, already closed, along with the error so the caller can check its status.
+func (client *Client) DoStream(ctx context.Context, r *Request) (*http.Response, error) {
+	// make sure we have a root slash
+	if !strings.HasPrefix(r.Path, "/") {
+		r.Path = client.pathPrefix + "/" + r.Path
+	} else {
+		r.Path = client.pathPrefix + r.Path
+	}
+
+	req, err := http.NewRequest(r.Action, r.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("Accept", "text/event-stream")
+	for key, val := range r.Header {
+		req.Header.Add(key, val)
+		if log.IsSensitive(key) {
+			log.Tracef("Header: {%v : %v}\n", key, "*****")
+		} else {
+			log.Tracef("Header: {%v : %v}\n", key, val)
+		}
+	}
+	log.Tracef("Stream request: action=%s path=%s", r.Action, log.RedactURL(r.Path))
+
+	// Share the transport (e.g. unix socket dialer, TLS config) but not the timeout
+	streamClient := &http.Client{Transport: client.Transport, CheckRedirect: client.CheckRedirect, Jar: client.Jar}
+	res, err := streamClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		log.Errorf("status code was %s for stream request: action=%s path=%s, attempting to decode error response.", res.Status, r.Action, r.Path)
+		if isParsableError(res.StatusCode) {
+			if err = decode(res.Body, r.ResponseError, r); err != nil {
+				log.Error("Failed to decode error response.")
+				return res, err
+			}
+		}
+		return res, fmt.Errorf("status code was %s for stream request: action=%s path=%s", res.Status, r.Action, r.Path)
+	}
+	return res, nil
+}
+
 func doWithRetry(client *Client, request *http.Request) (*http.Response, error) {
 	try := 0
 	maxTries := 3
@@ -214,9 +297,17 @@ func doWithRetry(client *Client, request *http.Request) (*http.Response, error)
 			if strings.Contains(strings.ToLower(err.Error()), "timeout") {
 				return nil, err
 			}
+			// the caller gave up on the request, don't retry it either
+			if request.Context().Err() != nil {
+				return nil, err
+			}
 			if try < maxTries {
 				try++
-				time.Sleep(time.Duration(try) * time.Second)
+				select {
+				case <-request.Context().Done():
+					return nil, request.Context().Err()
+				case <-time.After(time.Duration(try) * time.Second):
+				}
 				continue
 			}
 			return nil, err
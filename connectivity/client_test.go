@@ -16,15 +16,22 @@ limitations under the License.
 package connectivity
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 const (
@@ -218,6 +225,34 @@ func TestHTTPTimeout(t *testing.T) {
 	verifyFoo(err, foo, t)
 }
 
+func TestDoJSONWithContextCanceled(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// The client timeout is far away, only the context deadline ends the request
+	client := NewHTTPClientWithTimeout(server.URL, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var foo answer
+	start := time.Now()
+	_, err := client.DoJSONWithContext(ctx, &Request{Action: "POST", Path: pathString, Payload: &question{Ping: "junk"}, Response: &foo, ResponseError: nil})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context deadline to end the request, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request was retried after the context ended, took %v", elapsed)
+	}
+	if count := atomic.LoadInt32(&requests); count != 1 {
+		t.Errorf("expected a single attempt, got %v", count)
+	}
+}
+
 func verifyFoo(err error, foo answer, t *testing.T) {
 	if err != nil {
 		t.Error(
@@ -261,3 +296,109 @@ func verifyEmpty(err error, bad badnews, t *testing.T) {
 			"got", bad.Info)
 	}
 }
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Array    string `json:"array"`
+}
+
+// capturedMessages records the formatted messages logged while it is installed
+type capturedMessages struct {
+	mutex    sync.Mutex
+	messages []string
+}
+
+func (c *capturedMessages) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (c *capturedMessages) Fire(entry *logrus.Entry) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.messages = append(c.messages, entry.Message)
+	return nil
+}
+
+func TestTraceRedaction(t *testing.T) {
+	captured := &capturedMessages{}
+	hooks := logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+	defer logrus.StandardLogger().ReplaceHooks(hooks)
+	logrus.AddHook(captured)
+	level := logrus.GetLevel()
+	logrus.SetLevel(logrus.TraceLevel)
+	defer logrus.SetLevel(level)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"username":"admin","password":"resp-s3cret","array":"array1"}`)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL)
+	var response credentials
+	_, err := client.DoJSON(&Request{
+		Action:   "POST",
+		Path:     "/v1/tokens?password=query-s3cret",
+		Payload:  &credentials{Username: "admin", Password: "req-s3cret", Array: "array1"},
+		Response: &response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Password != "resp-s3cret" {
+		t.Errorf("expected the response to be decoded unchanged, got %+v", response)
+	}
+
+	captured.mutex.Lock()
+	defer captured.mutex.Unlock()
+	output := strings.Join(captured.messages, "\n")
+	if strings.Contains(output, "s3cret") {
+		t.Errorf("secret logged in:\n%s", output)
+	}
+	if !strings.Contains(output, `"array":"array1"`) {
+		t.Errorf("expected the payload to be logged in:\n%s", output)
+	}
+}
+
+func TestDoStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"Info":"stream refused"}`)
+			return
+		}
+		// Stream beyond the client timeout to make sure it doesn't apply to the stream
+		flusher := w.(http.Flusher)
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "data: %d\n\n", i)
+			flusher.Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	client := NewHTTPClientWithTimeout(server.URL, 20*time.Millisecond)
+
+	res, err := client.DoStream(context.Background(), &Request{Action: "GET", Path: "/events"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "data: 0\n\ndata: 1\n\ndata: 2\n\n" {
+		t.Errorf("unexpected stream %q", body)
+	}
+
+	var bad badnews
+	if res, err = client.DoStream(context.Background(), &Request{Action: "GET", Path: "/error", ResponseError: &bad}); err == nil {
+		t.Error("expected an error for a refused stream")
+	}
+	if res == nil || res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected the refused response to be returned, got %+v", res)
+	}
+	if bad.Info != "stream refused" {
+		t.Errorf("unexpected error response %+v", bad)
+	}
+}
@@ -0,0 +1,406 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package boltdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Scalingo/go-etcd-lock/lock"
+	bbolt "github.com/coreos/bbolt"
+
+	"github.com/hpe-storage/common-host-libs/dbservice"
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+var (
+	// DefaultPath of the DB file on edge hosts without etcd
+	DefaultPath = "/var/lib/hpe-storage/dbservice.db"
+
+	// DefaultOpenTimeout is how long to wait for another process to close the DB file
+	DefaultOpenTimeout = 5 * time.Second
+
+	keysBucket = []byte("keys")
+)
+
+// record is the stored form of a value, Expires is zero when the key has no lease
+type record struct {
+	Value   string `json:"value"`
+	Expires int64  `json:"expires,omitempty"` // unix nanoseconds
+}
+
+// DBClient is a single node, file backed implementor of the DBService interface.  Values and their lease expiry
+// are persisted, locks and watches are local to the process holding the DB file open.
+type DBClient struct {
+	Path   string
+	mutex  sync.Mutex
+	db     *bbolt.DB
+	leases map[string]*time.Timer
+	locks  *dbservice.LockTable
+	hub    *dbservice.WatchHub
+}
+
+var _ dbservice.DBService = &DBClient{}
+
+// NewClient opens (or creates) the DB file at path, keys whose lease expired while the DB was closed are removed
+func NewClient(path string) (*DBClient, error) {
+	log.Tracef(">>>>> NewClient, path: %s", path)
+	defer log.Trace("<<<<< NewClient")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: DefaultOpenTimeout})
+	if err != nil {
+		log.Errorf("Failed to open DB %s, err: %s", path, err.Error())
+		return nil, err
+	}
+	d := &DBClient{
+		Path:   path,
+		db:     db,
+		leases: make(map[string]*time.Timer),
+		locks:  dbservice.NewLockTable(),
+		hub:    dbservice.NewWatchHub(),
+	}
+	if err = d.restoreLeases(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// CloseClient closes the DB file
+func (d *DBClient) CloseClient() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for key, timer := range d.leases {
+		timer.Stop()
+		delete(d.leases, key)
+	}
+	return d.db.Close()
+}
+
+// Get value from DB
+func (d *DBClient) Get(key string) (*string, error) {
+	var value *string
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		r, err := getRecord(tx, key)
+		if r != nil {
+			value = &r.Value
+		}
+		return err
+	})
+	if err != nil {
+		log.Errorf("GET %s failed, err: %s", key, err.Error())
+		return nil, err
+	}
+	// Key not found returns nil and No error
+	return value, nil
+}
+
+// Put value to DB
+func (d *DBClient) Put(key string, value string) error {
+	return d.put(key, value, 0)
+}
+
+// PutWithLeaseExpiry value to DB, the key is deleted once the lease expires
+func (d *DBClient) PutWithLeaseExpiry(key string, value string, seconds int64) error {
+	// Minimum lease TTL is 5-second, same as etcd
+	if seconds < 5 {
+		return fmt.Errorf("Minimum lease TTL is 5 seconds")
+	}
+	return d.put(key, value, seconds)
+}
+
+// Delete value from DB
+func (d *DBClient) Delete(key string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var deleted bool
+	err := d.db.Update(func(tx *bbolt.Tx) (err error) {
+		deleted, err = deleteRecord(tx, key)
+		return err
+	})
+	if err != nil {
+		log.Errorf("DELETE %s failed, err: %s", key, err.Error())
+		return err
+	}
+	if deleted {
+		d.deleted(key)
+	}
+	return nil
+}
+
+// List values with the given key prefix
+func (d *DBClient) List(prefix string) ([]*dbservice.KeyValue, error) {
+	keyValues := []*dbservice.KeyValue{}
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(keysBucket).Cursor()
+		// keys are kept sorted, so the prefix is a contiguous range
+		for k, v := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, v = c.Next() {
+			r := &record{}
+			if err := json.Unmarshal(v, r); err != nil {
+				return fmt.Errorf("unable to decode %s, %s", string(k), err.Error())
+			}
+			if !r.expired(time.Now()) {
+				keyValues = append(keyValues, &dbservice.KeyValue{Key: string(k), Value: r.Value})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("LIST %s failed, err: %s", prefix, err.Error())
+		return nil, err
+	}
+	return keyValues, nil
+}
+
+// Watch the keys with the given prefix until the context is done
+func (d *DBClient) Watch(ctx context.Context, prefix string) (<-chan *dbservice.WatchEvent, error) {
+	return d.hub.Watch(ctx, prefix), nil
+}
+
+// Transaction applies the success or failure operations atomically depending on the comparisons
+func (d *DBClient) Transaction(compares []*dbservice.Compare, success []*dbservice.Op, failure []*dbservice.Op) (bool, error) {
+	if err := dbservice.ValidateOps(success, failure); err != nil {
+		return false, err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var succeeded bool
+	var events []*dbservice.WatchEvent
+	err := d.db.Update(func(tx *bbolt.Tx) error {
+		succeeded = true
+		for _, compare := range compares {
+			r, err := getRecord(tx, compare.Key)
+			if err != nil {
+				return err
+			}
+			if compare.Value == nil {
+				succeeded = r == nil
+			} else {
+				succeeded = r != nil && r.Value == *compare.Value
+			}
+			if !succeeded {
+				break
+			}
+		}
+		ops := success
+		if !succeeded {
+			ops = failure
+		}
+		events = nil
+		for _, op := range ops {
+			if op.Type == dbservice.OpPut {
+				if err := putRecord(tx, op.Key, &record{Value: op.Value}); err != nil {
+					return err
+				}
+				events = append(events, &dbservice.WatchEvent{Type: dbservice.EventPut, Key: op.Key, Value: op.Value})
+			} else {
+				deleted, err := deleteRecord(tx, op.Key)
+				if err != nil {
+					return err
+				}
+				if deleted {
+					events = append(events, &dbservice.WatchEvent{Type: dbservice.EventDelete, Key: op.Key})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("TXN failed, err: %s", err.Error())
+		return false, err
+	}
+	for _, event := range events {
+		d.stopLease(event.Key)
+		d.hub.Notify(event)
+	}
+	return succeeded, nil
+}
+
+// CompareAndSwap sets key to newValue when its current value is oldValue
+func (d *DBClient) CompareAndSwap(key string, oldValue *string, newValue string) (bool, error) {
+	compare := &dbservice.Compare{Key: key, Value: oldValue}
+	return d.Transaction([]*dbservice.Compare{compare}, []*dbservice.Op{dbservice.NewPutOp(key, newValue)}, nil)
+}
+
+// IsLocked checks if the given key is already locked
+func (d *DBClient) IsLocked(key string) (bool, error) {
+	return d.locks.IsLocked(key), nil
+}
+
+// AcquireLock for the given key, failing when it is already held
+func (d *DBClient) AcquireLock(key string, ttl int) (lock.Lock, error) {
+	return d.locks.Acquire(key, ttl)
+}
+
+// WaitAcquireLock for the given key, waiting until it is released or expires
+func (d *DBClient) WaitAcquireLock(key string, ttl int) (lock.Lock, error) {
+	return d.locks.WaitAcquire(key, ttl)
+}
+
+// ReleaseLock for the given key
+func (d *DBClient) ReleaseLock(lck lock.Lock) error {
+	if lck == nil {
+		return fmt.Errorf("nil lock")
+	}
+	return lck.Release()
+}
+
+// put stores the value, with a lease of the given seconds when non-zero
+func (d *DBClient) put(key, value string, seconds int64) error {
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	r := &record{Value: value}
+	if seconds > 0 {
+		r.Expires = time.Now().Add(time.Duration(seconds) * time.Second).UnixNano()
+	}
+	err := d.db.Update(func(tx *bbolt.Tx) error {
+		return putRecord(tx, key, r)
+	})
+	if err != nil {
+		log.Errorf("PUT %s failed, err: %s", key, err.Error())
+		return err
+	}
+	d.stopLease(key)
+	if r.Expires != 0 {
+		d.startLease(key, r.Expires)
+	}
+	d.hub.Notify(&dbservice.WatchEvent{Type: dbservice.EventPut, Key: key, Value: value})
+	return nil
+}
+
+// restoreLeases deletes the keys that expired while the DB was closed and schedules the expiry of the others
+func (d *DBClient) restoreLeases() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(keysBucket)
+		if err != nil {
+			return err
+		}
+		var expired [][]byte
+		now := time.Now()
+		err = bucket.ForEach(func(k, v []byte) error {
+			r := &record{}
+			if err := json.Unmarshal(v, r); err != nil {
+				return fmt.Errorf("unable to decode %s, %s", string(k), err.Error())
+			}
+			if r.expired(now) {
+				expired = append(expired, k)
+			} else if r.Expires != 0 {
+				d.startLease(string(k), r.Expires)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			log.Tracef("removing %s, its lease expired while the DB was closed", string(k))
+			if err = bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// startLease schedules the deletion of key at the given expiry
+func (d *DBClient) startLease(key string, expires int64) {
+	d.leases[key] = time.AfterFunc(time.Until(time.Unix(0, expires)), func() { d.expire(key, expires) })
+}
+
+func (d *DBClient) stopLease(key string) {
+	if timer, ok := d.leases[key]; ok {
+		timer.Stop()
+		delete(d.leases, key)
+	}
+}
+
+// expire deletes key when its lease fires, unless the key was updated in the meantime
+func (d *DBClient) expire(key string, expires int64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var deleted bool
+	err := d.db.Update(func(tx *bbolt.Tx) error {
+		r, err := readRecord(tx, key)
+		if err != nil || r == nil || r.Expires != expires {
+			return err
+		}
+		deleted, err = deleteRecord(tx, key)
+		return err
+	})
+	if err != nil {
+		log.Errorf("failed to expire %s, err: %s", key, err.Error())
+		return
+	}
+	if deleted {
+		d.deleted(key)
+	}
+}
+
+// deleted cancels the lease of a deleted key and notifies its watchers
+func (d *DBClient) deleted(key string) {
+	d.stopLease(key)
+	d.hub.Notify(&dbservice.WatchEvent{Type: dbservice.EventDelete, Key: key})
+}
+
+func (r *record) expired(now time.Time) bool {
+	return r.Expires != 0 && now.UnixNano() >= r.Expires
+}
+
+// getRecord returns the unexpired record of key, or nil
+func getRecord(tx *bbolt.Tx, key string) (*record, error) {
+	r, err := readRecord(tx, key)
+	if err != nil || r == nil || r.expired(time.Now()) {
+		return nil, err
+	}
+	return r, nil
+}
+
+// readRecord returns the stored record of key, expired or not, or nil
+func readRecord(tx *bbolt.Tx, key string) (*record, error) {
+	v := tx.Bucket(keysBucket).Get([]byte(key))
+	if v == nil {
+		return nil, nil
+	}
+	r := &record{}
+	if err := json.Unmarshal(v, r); err != nil {
+		return nil, fmt.Errorf("unable to decode %s, %s", key, err.Error())
+	}
+	return r, nil
+}
+
+func putRecord(tx *bbolt.Tx, key string, r *record) error {
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(keysBucket).Put([]byte(key), v)
+}
+
+// deleteRecord deletes key, returning whether it existed
+func deleteRecord(tx *bbolt.Tx, key string) (bool, error) {
+	bucket := tx.Bucket(keysBucket)
+	if bucket.Get([]byte(key)) == nil {
+		return false, nil
+	}
+	return true, bucket.Delete([]byte(key))
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package boltdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bbolt "github.com/coreos/bbolt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hpe-storage/common-host-libs/dbservice"
+	"github.com/hpe-storage/common-host-libs/dbservice/dbservicetest"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "boltdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestConformance(t *testing.T) {
+	dir := tempDir(t)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	dbservicetest.RunConformance(t, func(t *testing.T) dbservice.DBService {
+		db, err := NewClient(filepath.Join(dir, t.Name(), "db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.CloseClient() })
+		return db
+	})
+}
+
+func TestPersistence(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "db")
+
+	db, err := NewClient(path)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put("/volumes/a", "1"))
+	assert.Nil(t, db.PutWithLeaseExpiry("/volumes/leased", "2", 60))
+	assert.Nil(t, db.CloseClient())
+
+	db, err = NewClient(path)
+	assert.Nil(t, err)
+	list, err := db.List("/volumes/")
+	assert.Nil(t, err)
+	assert.Equal(t, []*dbservice.KeyValue{{Key: "/volumes/a", Value: "1"}, {Key: "/volumes/leased", Value: "2"}}, list)
+	assert.Nil(t, db.CloseClient())
+}
+
+func TestLeaseExpiredWhileClosed(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "db")
+
+	db, err := NewClient(path)
+	assert.Nil(t, err)
+	// store a lease that has already run out, as if the DB was closed across its expiry
+	assert.Nil(t, db.db.Update(func(tx *bbolt.Tx) error {
+		return putRecord(tx, "stale", &record{Value: "1", Expires: time.Now().Add(-time.Second).UnixNano()})
+	}))
+	assert.Nil(t, db.CloseClient())
+
+	db, err = NewClient(path)
+	assert.Nil(t, err)
+	defer db.CloseClient()
+	value, err := db.Get("stale")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	list, _ := db.List("")
+	assert.Empty(t, list)
+}
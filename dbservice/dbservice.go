@@ -2,11 +2,16 @@
 
 package dbservice
 
-import "github.com/Scalingo/go-etcd-lock/lock"
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Scalingo/go-etcd-lock/lock"
+)
 
 // DBService defines the interface to any DB related operations
 type DBService interface {
-	// TODO: Add TLS support
 	Get(key string) (*string, error)
 	Put(key string, value string) error
 	PutWithLeaseExpiry(key string, value string, seconds int64) error
@@ -15,5 +20,107 @@ type DBService interface {
 	AcquireLock(key string, ttl int) (lock.Lock, error)
 	WaitAcquireLock(key string, ttl int) (lock.Lock, error)
 	ReleaseLock(lck lock.Lock) error
-	// TODO: Add more operations
+
+	// List returns the keys with the given prefix and their values, sorted by key
+	List(prefix string) ([]*KeyValue, error)
+	// Watch streams the changes to the keys with the given prefix until the context is done, when the channel is
+	// closed.  A watch that is cancelled by the service delivers a last event with Err set before the channel closes.
+	Watch(ctx context.Context, prefix string) (<-chan *WatchEvent, error)
+	// Transaction applies the success operations when all of the comparisons hold and the failure operations
+	// otherwise, atomically.  It returns whether the comparisons held.
+	Transaction(compares []*Compare, success []*Op, failure []*Op) (bool, error)
+	// CompareAndSwap sets key to newValue only when its current value is oldValue, a nil oldValue requires the
+	// key to be absent.  It returns whether the value was swapped.
+	CompareAndSwap(key string, oldValue *string, newValue string) (bool, error)
+}
+
+// KeyValue is a key and its value
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// EventType is the kind of change reported by Watch
+type EventType int
+
+const (
+	// EventPut reports a key that was created or updated
+	EventPut EventType = iota
+	// EventDelete reports a key that was deleted or expired
+	EventDelete
+)
+
+func (t EventType) String() string {
+	if t == EventDelete {
+		return "DELETE"
+	}
+	return "PUT"
+}
+
+// ErrWatchOverflow cancels a watcher that fell too far behind the changes, it must list the keys again and restart
+// its watch
+var ErrWatchOverflow = errors.New("watch cancelled, the watcher is not keeping up with the changes")
+
+// WatchEvent is a change to a watched key, Value is empty for deletes.  Err is only set on the last event of a
+// watch cancelled by the service, e.g. ErrWatchOverflow or a compacted revision, and the other fields are then empty.
+type WatchEvent struct {
+	Type  EventType
+	Key   string
+	Value string
+	Err   error
+}
+
+// Compare is a transaction condition on the value of a key, a nil Value requires the key to be absent
+type Compare struct {
+	Key   string
+	Value *string
+}
+
+// OpType is the kind of operation applied by a transaction
+type OpType int
+
+const (
+	// OpPut sets a key to a value
+	OpPut OpType = iota
+	// OpDelete deletes a key
+	OpDelete
+)
+
+// Op is an operation applied by a transaction
+type Op struct {
+	Type  OpType
+	Key   string
+	Value string
+}
+
+// NewPutOp returns an operation setting key to value
+func NewPutOp(key, value string) *Op {
+	return &Op{Type: OpPut, Key: key, Value: value}
+}
+
+// NewDeleteOp returns an operation deleting key
+func NewDeleteOp(key string) *Op {
+	return &Op{Type: OpDelete, Key: key}
+}
+
+// CompareValue returns a comparison that holds when key has the given value
+func CompareValue(key, value string) *Compare {
+	return &Compare{Key: key, Value: &value}
+}
+
+// CompareAbsent returns a comparison that holds when key does not exist
+func CompareAbsent(key string) *Compare {
+	return &Compare{Key: key}
+}
+
+// ValidateOps rejects unknown transaction operations before anything is applied
+func ValidateOps(opLists ...[]*Op) error {
+	for _, ops := range opLists {
+		for _, op := range ops {
+			if op.Type != OpPut && op.Type != OpDelete {
+				return fmt.Errorf("unsupported transaction operation %d", op.Type)
+			}
+		}
+	}
+	return nil
 }
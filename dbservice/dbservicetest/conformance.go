@@ -0,0 +1,358 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+// Package dbservicetest provides the conformance suite every dbservice.DBService implementation must pass
+package dbservicetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Scalingo/go-etcd-lock/lock"
+
+	"github.com/hpe-storage/common-host-libs/dbservice"
+)
+
+var (
+	// eventTimeout is how long to wait for an expected watch event
+	eventTimeout = 5 * time.Second
+)
+
+// Factory returns a new, empty DBService for a single conformance test
+type Factory func(t *testing.T) dbservice.DBService
+
+// RunConformance runs the DBService conformance suite against the implementation returned by newDB.  The lease and
+// lock expiry tests wait for the real TTLs to elapse and are skipped in short mode.
+func RunConformance(t *testing.T, newDB Factory) {
+	tests := []struct {
+		name string
+		slow bool
+		test func(t *testing.T, db dbservice.DBService)
+	}{
+		{"GetPutDelete", false, testGetPutDelete},
+		{"List", false, testList},
+		{"Watch", false, testWatch},
+		{"Transaction", false, testTransaction},
+		{"CompareAndSwap", false, testCompareAndSwap},
+		{"Lock", false, testLock},
+		{"WaitAcquireLock", false, testWaitAcquireLock},
+		{"LockExpiry", true, testLockExpiry},
+		{"LeaseExpiry", true, testLeaseExpiry},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.slow {
+				if testing.Short() {
+					t.Skip("skipping TTL test in short mode")
+				}
+				t.Parallel()
+			}
+			tc.test(t, newDB(t))
+		})
+	}
+}
+
+func expectValue(t *testing.T, db dbservice.DBService, key string, expected *string) {
+	t.Helper()
+	value, err := db.Get(key)
+	if err != nil {
+		t.Fatalf("GET %s failed, %s", key, err.Error())
+	}
+	switch {
+	case expected == nil && value != nil:
+		t.Errorf("GET %s: expected no value, got %q", key, *value)
+	case expected != nil && value == nil:
+		t.Errorf("GET %s: expected %q, got no value", key, *expected)
+	case expected != nil && *value != *expected:
+		t.Errorf("GET %s: expected %q, got %q", key, *expected, *value)
+	}
+}
+
+func expectEvent(t *testing.T, events <-chan *dbservice.WatchEvent, expected *dbservice.WatchEvent) {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatalf("watch closed while waiting for %s %s", expected.Type, expected.Key)
+		}
+		if *event != *expected {
+			t.Errorf("expected event %+v, got %+v", expected, event)
+		}
+	case <-time.After(eventTimeout):
+		t.Fatalf("timed out waiting for %s %s", expected.Type, expected.Key)
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func testGetPutDelete(t *testing.T, db dbservice.DBService) {
+	expectValue(t, db, "foo", nil)
+	if err := db.Put("foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, db, "foo", stringPtr("bar"))
+	if err := db.Put("foo", "baz"); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, db, "foo", stringPtr("baz"))
+	if err := db.Delete("foo"); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, db, "foo", nil)
+	// deleting a missing key is not an error
+	if err := db.Delete("foo"); err != nil {
+		t.Errorf("expected no error deleting a missing key, got %s", err.Error())
+	}
+	if err := db.PutWithLeaseExpiry("foo", "bar", 4); err == nil {
+		t.Error("expected an error for a lease shorter than 5 seconds")
+	}
+}
+
+func testList(t *testing.T, db dbservice.DBService) {
+	for _, key := range []string{"/volumes/b", "/volumes/a", "/volumes/c", "/volumesx", "/hosts/a"} {
+		if err := db.Put(key, key+"-value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keyValues, err := db.List("/volumes/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, kv := range keyValues {
+		keys = append(keys, kv.Key)
+		if kv.Value != kv.Key+"-value" {
+			t.Errorf("unexpected value %q for %s", kv.Value, kv.Key)
+		}
+	}
+	if fmt.Sprint(keys) != "[/volumes/a /volumes/b /volumes/c]" {
+		t.Errorf("expected the sorted keys under /volumes/, got %v", keys)
+	}
+	if keyValues, err = db.List("/none/"); err != nil || len(keyValues) != 0 {
+		t.Errorf("expected an empty list, got %v %v", keyValues, err)
+	}
+}
+
+func testWatch(t *testing.T, db dbservice.DBService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := db.Watch(ctx, "/volumes/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Put("/hosts/a", "ignored"); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Put("/volumes/a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.CompareAndSwap("/volumes/a", stringPtr("1"), "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Delete("/volumes/a"); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, &dbservice.WatchEvent{Type: dbservice.EventPut, Key: "/volumes/a", Value: "1"})
+	expectEvent(t, events, &dbservice.WatchEvent{Type: dbservice.EventPut, Key: "/volumes/a", Value: "2"})
+	expectEvent(t, events, &dbservice.WatchEvent{Type: dbservice.EventDelete, Key: "/volumes/a"})
+
+	cancel()
+	deadline := time.After(eventTimeout)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("expected the watch to be closed once the context is done")
+		}
+	}
+}
+
+func testTransaction(t *testing.T, db dbservice.DBService) {
+	if err := db.Put("owner", "host1"); err != nil {
+		t.Fatal(err)
+	}
+	succeeded, err := db.Transaction(
+		[]*dbservice.Compare{dbservice.CompareValue("owner", "host1"), dbservice.CompareAbsent("claim")},
+		[]*dbservice.Op{dbservice.NewPutOp("claim", "host1"), dbservice.NewDeleteOp("owner")},
+		[]*dbservice.Op{dbservice.NewPutOp("conflict", "true")},
+	)
+	if err != nil || !succeeded {
+		t.Fatalf("expected the transaction to succeed, got %v %v", succeeded, err)
+	}
+	expectValue(t, db, "claim", stringPtr("host1"))
+	expectValue(t, db, "owner", nil)
+	expectValue(t, db, "conflict", nil)
+
+	// any failing comparison applies the failure operations only
+	succeeded, err = db.Transaction(
+		[]*dbservice.Compare{dbservice.CompareAbsent("owner"), dbservice.CompareValue("claim", "host2")},
+		[]*dbservice.Op{dbservice.NewDeleteOp("claim")},
+		[]*dbservice.Op{dbservice.NewPutOp("conflict", "true")},
+	)
+	if err != nil || succeeded {
+		t.Fatalf("expected the transaction to fail, got %v %v", succeeded, err)
+	}
+	expectValue(t, db, "claim", stringPtr("host1"))
+	expectValue(t, db, "conflict", stringPtr("true"))
+
+	if _, err = db.Transaction(nil, []*dbservice.Op{{Type: dbservice.OpType(99), Key: "x"}}, nil); err == nil {
+		t.Error("expected an error for an unknown operation")
+	}
+	expectValue(t, db, "x", nil)
+}
+
+func testCompareAndSwap(t *testing.T, db dbservice.DBService) {
+	swapped, err := db.CompareAndSwap("leader", nil, "host1")
+	if err != nil || !swapped {
+		t.Fatalf("expected to create the key, got %v %v", swapped, err)
+	}
+	if swapped, err = db.CompareAndSwap("leader", nil, "host2"); err != nil || swapped {
+		t.Errorf("expected the create to fail for an existing key, got %v %v", swapped, err)
+	}
+	if swapped, err = db.CompareAndSwap("leader", stringPtr("host2"), "host3"); err != nil || swapped {
+		t.Errorf("expected the swap to fail for a different value, got %v %v", swapped, err)
+	}
+	if swapped, err = db.CompareAndSwap("leader", stringPtr("host1"), "host2"); err != nil || !swapped {
+		t.Errorf("expected the swap to succeed, got %v %v", swapped, err)
+	}
+	expectValue(t, db, "leader", stringPtr("host2"))
+
+	// only one of many concurrent creates wins
+	var wg sync.WaitGroup
+	winners := 0
+	var mutex sync.Mutex
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if swapped, err := db.CompareAndSwap("race", nil, fmt.Sprint(i)); err == nil && swapped {
+				mutex.Lock()
+				winners++
+				mutex.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if winners != 1 {
+		t.Errorf("expected exactly one concurrent create to win, got %d", winners)
+	}
+}
+
+func testLock(t *testing.T, db dbservice.DBService) {
+	if locked, err := db.IsLocked("mylock"); err != nil || locked {
+		t.Fatalf("expected the key to be unlocked, got %v %v", locked, err)
+	}
+	lck, err := db.AcquireLock("mylock", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked, err := db.IsLocked("mylock"); err != nil || !locked {
+		t.Errorf("expected the key to be locked, got %v %v", locked, err)
+	}
+	if _, err = db.AcquireLock("mylock", 30); err == nil {
+		t.Error("expected acquiring a held lock to fail")
+	} else if _, ok := err.(*lock.Error); !ok {
+		t.Errorf("expected a *lock.Error, got %T %v", err, err)
+	}
+	if err = db.ReleaseLock(lck); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.ReleaseLock(lck); err != nil {
+		t.Errorf("expected releasing a lock twice to be a no-op, got %s", err.Error())
+	}
+	if locked, err := db.IsLocked("mylock"); err != nil || locked {
+		t.Errorf("expected the key to be unlocked after release, got %v %v", locked, err)
+	}
+}
+
+func testWaitAcquireLock(t *testing.T, db dbservice.DBService) {
+	lck, err := db.AcquireLock("mylock", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan lock.Lock)
+	go func() {
+		waiter, err := db.WaitAcquireLock("mylock", 30)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- waiter
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("expected the waiter to block while the lock is held")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if err = db.ReleaseLock(lck); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case waiter := <-acquired:
+		if waiter == nil {
+			t.Fatal("expected the waiter to acquire the lock")
+		}
+		db.ReleaseLock(waiter)
+	case <-time.After(eventTimeout):
+		t.Fatal("expected the waiter to acquire the released lock")
+	}
+}
+
+func testLockExpiry(t *testing.T, db dbservice.DBService) {
+	if _, err := db.AcquireLock("mylock", 1); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	// the waiter acquires the lock once the holder's TTL elapses
+	waiter, err := db.WaitAcquireLock("mylock", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.ReleaseLock(waiter)
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("expected the waiter to block until the TTL elapsed, waited %s", elapsed)
+	}
+}
+
+func testLeaseExpiry(t *testing.T, db dbservice.DBService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := db.Watch(ctx, "lease/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.PutWithLeaseExpiry("lease/expiring", "1", 5); err != nil {
+		t.Fatal(err)
+	}
+	// a plain put replaces the lease, so this key must survive
+	if err = db.PutWithLeaseExpiry("lease/renewed", "1", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Put("lease/renewed", "2"); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, &dbservice.WatchEvent{Type: dbservice.EventPut, Key: "lease/expiring", Value: "1"})
+	expectEvent(t, events, &dbservice.WatchEvent{Type: dbservice.EventPut, Key: "lease/renewed", Value: "1"})
+	expectEvent(t, events, &dbservice.WatchEvent{Type: dbservice.EventPut, Key: "lease/renewed", Value: "2"})
+
+	expectValue(t, db, "lease/expiring", stringPtr("1"))
+	select {
+	case event := <-events:
+		expected := dbservice.WatchEvent{Type: dbservice.EventDelete, Key: "lease/expiring"}
+		if *event != expected {
+			t.Errorf("expected event %+v, got %+v", expected, event)
+		}
+	case <-time.After(5*time.Second + eventTimeout):
+		t.Fatal("timed out waiting for the lease to expire")
+	}
+	expectValue(t, db, "lease/expiring", nil)
+	expectValue(t, db, "lease/renewed", stringPtr("2"))
+}
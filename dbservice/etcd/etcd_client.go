@@ -4,13 +4,19 @@ package etcd
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
+	"strings"
 	"time"
 
 	"github.com/Scalingo/go-etcd-lock/lock"
 	v3 "github.com/coreos/etcd/clientv3"
 	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	"github.com/coreos/etcd/mvcc/mvccpb"
 
+	"github.com/hpe-storage/common-host-libs/dbservice"
 	"github.com/hpe-storage/common-host-libs/jsonutil"
 	log "github.com/hpe-storage/common-host-libs/logger"
 )
@@ -36,9 +42,29 @@ var (
 type DBClient struct {
 	Version   string
 	EndPoints []string
+	Namespace string // prefix prepended to every key, hidden from callers
 	Client    *v3.Client
 }
 
+// TLSOptions are the PEM files used to connect to etcd over TLS
+type TLSOptions struct {
+	CertFile string // client certificate, optional when etcd does not require client authentication
+	KeyFile  string // client private key
+	CAFile   string // CA bundle verifying the etcd servers, the system roots are used when empty
+}
+
+// ClientOptions configure a DB client
+type ClientOptions struct {
+	EndPoints   []string
+	TLS         *TLSOptions   // connect over TLS when set
+	Username    string        // etcd user, authentication is disabled when empty
+	Password    string        // etcd password
+	Namespace   string        // prefix prepended to every key, e.g. "/hpe-storage/"
+	DialTimeout time.Duration // DefaultDialTimeout when zero
+}
+
+var _ dbservice.DBService = &DBClient{}
+
 // NewClient creates new client instance for list of endpoints to access the DB server
 func NewClient(endPoints []string, version string) (*DBClient, error) {
 	if version == DefaultVersion {
@@ -50,13 +76,36 @@ func NewClient(endPoints []string, version string) (*DBClient, error) {
 
 // NewClientV3 creates v3 client instance to access the DB server
 func NewClientV3(endPoints []string) (*DBClient, error) {
-	log.Tracef("NewClientV3, endpoints: %v", endPoints)
+	return NewClientWithOptions(&ClientOptions{EndPoints: endPoints})
+}
+
+// NewClientWithOptions creates v3 client instance to access the DB server with TLS, credentials and a key namespace
+func NewClientWithOptions(opts *ClientOptions) (*DBClient, error) {
+	if opts == nil || len(opts.EndPoints) == 0 {
+		return nil, fmt.Errorf("at least one DB endpoint is required")
+	}
+	log.Tracef("NewClientWithOptions, endpoints: %v, tls: %v, user: %s, namespace: %s",
+		opts.EndPoints, opts.TLS != nil, opts.Username, opts.Namespace)
+
+	config := v3.Config{
+		Endpoints:   opts.EndPoints,
+		DialTimeout: opts.DialTimeout,
+		Username:    opts.Username,
+		Password:    opts.Password,
+	}
+	if config.DialTimeout == 0 {
+		config.DialTimeout = DefaultDialTimeout
+	}
+	if opts.TLS != nil {
+		tlsConfig, err := newTLSConfig(opts.TLS)
+		if err != nil {
+			log.Error("Failed to setup TLS for etcd v3 client, err: ", err.Error())
+			return nil, err
+		}
+		config.TLS = tlsConfig
+	}
 
-	cli, err := v3.New(v3.Config{
-		Endpoints:   endPoints,
-		DialTimeout: DefaultDialTimeout,
-		// TODO: Add TLS support for security, may need to generate certs
-	})
+	cli, err := v3.New(config)
 	if err != nil {
 		// handle error!
 		log.Error("Failed to create etcd v3 client, err: ", err.Error())
@@ -65,11 +114,46 @@ func NewClientV3(endPoints []string) (*DBClient, error) {
 
 	return &DBClient{
 		Version:   DefaultVersion,
-		EndPoints: endPoints,
+		EndPoints: opts.EndPoints,
+		Namespace: opts.Namespace,
 		Client:    cli,
 	}, nil
 }
 
+// newTLSConfig loads the client certificate and CA bundle for connecting to etcd
+func newTLSConfig(opts *TLSOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		keyPair, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{keyPair}
+	}
+	if opts.CAFile != "" {
+		pemData, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// key returns the key within the client namespace
+func (d *DBClient) key(key string) string {
+	return d.Namespace + key
+}
+
+// trimKey returns the key with the client namespace removed
+func (d *DBClient) trimKey(key []byte) string {
+	return strings.TrimPrefix(string(key), d.Namespace)
+}
+
 // CloseClient closes the DB client
 func (d *DBClient) CloseClient() error {
 	return d.Client.Close()
@@ -81,7 +165,7 @@ func (d *DBClient) IsLocked(key string) (bool, error) {
 	defer log.Trace("<<<<< IsLocked")
 
 	locker := lock.NewEtcdLocker(d.Client)
-	lck, err := locker.Acquire(key, 1)
+	lck, err := locker.Acquire(d.key(key), 1)
 	if err != nil {
 		// Check if it's lock err object
 		if lockErr, ok := err.(*lock.Error); ok {
@@ -104,7 +188,7 @@ func (d *DBClient) AcquireLock(key string, ttl int) (lock.Lock, error) {
 	defer log.Trace("<<<<< AcquireLock")
 
 	locker := lock.NewEtcdLocker(d.Client)
-	lck, err := locker.Acquire(key, ttl)
+	lck, err := locker.Acquire(d.key(key), ttl)
 	if err != nil {
 		log.Error(err.Error())
 		return nil, err
@@ -118,7 +202,7 @@ func (d *DBClient) WaitAcquireLock(key string, ttl int) (lock.Lock, error) {
 	defer log.Trace("<<<<< WaitAcquireLock")
 
 	locker := lock.NewEtcdLocker(d.Client)
-	lck, err := locker.WaitAcquire(key, ttl)
+	lck, err := locker.WaitAcquire(d.key(key), ttl)
 	if err != nil {
 		log.Error(err.Error())
 		return nil, err
@@ -143,7 +227,7 @@ func (d *DBClient) ReleaseLock(lck lock.Lock) error {
 // Get value from DB
 func (d *DBClient) Get(key string) (*string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
-	resp, err := d.Client.Get(ctx, key)
+	resp, err := d.Client.Get(ctx, d.key(key))
 	cancel()
 	if err != nil {
 		log.Errorf("GET %s failed, err: %s", key, err.Error())
@@ -162,7 +246,7 @@ func (d *DBClient) Get(key string) (*string, error) {
 // Put value to DB
 func (d *DBClient) Put(key string, value string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
-	resp, err := d.Client.Put(ctx, key, value)
+	resp, err := d.Client.Put(ctx, d.key(key), value)
 	cancel()
 	if err != nil {
 		log.Errorf("PUT %s failed, err: %s", key, err.Error())
@@ -188,7 +272,7 @@ func (d *DBClient) PutWithLeaseExpiry(key string, value string, seconds int64) e
 		cancel()
 		return err
 	}
-	resp, err := d.Client.Put(ctx, key, value, v3.WithLease(leaseResp.ID))
+	resp, err := d.Client.Put(ctx, d.key(key), value, v3.WithLease(leaseResp.ID))
 	cancel()
 	if err != nil {
 		log.Errorf("PUT %s failed, err: %s", key, err.Error())
@@ -201,7 +285,7 @@ func (d *DBClient) PutWithLeaseExpiry(key string, value string, seconds int64) e
 // Delete value from DB
 func (d *DBClient) Delete(key string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
-	resp, err := d.Client.Delete(ctx, key)
+	resp, err := d.Client.Delete(ctx, d.key(key))
 	cancel()
 	if err != nil {
 		log.Errorf("DELETE %s failed, err: %s", key, err.Error())
@@ -211,6 +295,113 @@ func (d *DBClient) Delete(key string) error {
 	return nil
 }
 
+// List values with the given key prefix from DB
+func (d *DBClient) List(prefix string) ([]*dbservice.KeyValue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	resp, err := d.Client.Get(ctx, d.key(prefix), v3.WithPrefix(), v3.WithSort(v3.SortByKey, v3.SortAscend))
+	cancel()
+	if err != nil {
+		log.Errorf("LIST %s failed, err: %s", prefix, err.Error())
+		return nil, handleError(err)
+	}
+	log.Tracef("LIST %s success, %d keys", prefix, len(resp.Kvs))
+	keyValues := make([]*dbservice.KeyValue, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		keyValues = append(keyValues, &dbservice.KeyValue{Key: d.trimKey(kv.Key), Value: string(kv.Value)})
+	}
+	return keyValues, nil
+}
+
+// Watch the keys with the given prefix, the channel is closed when the context is done or after an event with the
+// error of a failed watch
+func (d *DBClient) Watch(ctx context.Context, prefix string) (<-chan *dbservice.WatchEvent, error) {
+	log.Tracef(">>>>> Watch, prefix: %s", prefix)
+	defer log.Trace("<<<<< Watch")
+
+	watchChan := d.Client.Watch(ctx, d.key(prefix), v3.WithPrefix())
+	events := make(chan *dbservice.WatchEvent)
+	go func() {
+		defer close(events)
+		for resp := range watchChan {
+			if err := resp.Err(); err != nil {
+				log.Errorf("WATCH %s failed, err: %s", prefix, err.Error())
+				// e.g. a compacted revision, the watcher must list the keys again
+				select {
+				case events <- &dbservice.WatchEvent{Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+			for _, ev := range resp.Events {
+				event := &dbservice.WatchEvent{Type: dbservice.EventPut, Key: d.trimKey(ev.Kv.Key), Value: string(ev.Kv.Value)}
+				if ev.Type == mvccpb.DELETE {
+					event.Type = dbservice.EventDelete
+					event.Value = ""
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// Transaction applies the success or failure operations atomically depending on the comparisons
+func (d *DBClient) Transaction(compares []*dbservice.Compare, success []*dbservice.Op, failure []*dbservice.Op) (bool, error) {
+	var cmps []v3.Cmp
+	for _, compare := range compares {
+		if compare.Value == nil {
+			// a key that was never created, or was deleted, has a create revision of 0
+			cmps = append(cmps, v3.Compare(v3.CreateRevision(d.key(compare.Key)), "=", 0))
+		} else {
+			cmps = append(cmps, v3.Compare(v3.Value(d.key(compare.Key)), "=", *compare.Value))
+		}
+	}
+	successOps, err := d.ops(success)
+	if err != nil {
+		return false, err
+	}
+	failureOps, err := d.ops(failure)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	resp, err := d.Client.Txn(ctx).If(cmps...).Then(successOps...).Else(failureOps...).Commit()
+	cancel()
+	if err != nil {
+		log.Errorf("TXN failed, err: %s", err.Error())
+		return false, handleError(err)
+	}
+	log.Tracef("TXN success, succeeded: %v", resp.Succeeded)
+	return resp.Succeeded, nil
+}
+
+// CompareAndSwap sets key to newValue when its current value is oldValue
+func (d *DBClient) CompareAndSwap(key string, oldValue *string, newValue string) (bool, error) {
+	compare := &dbservice.Compare{Key: key, Value: oldValue}
+	return d.Transaction([]*dbservice.Compare{compare}, []*dbservice.Op{dbservice.NewPutOp(key, newValue)}, nil)
+}
+
+// ops converts transaction operations to etcd operations within the namespace
+func (d *DBClient) ops(ops []*dbservice.Op) ([]v3.Op, error) {
+	var etcdOps []v3.Op
+	for _, op := range ops {
+		switch op.Type {
+		case dbservice.OpPut:
+			etcdOps = append(etcdOps, v3.OpPut(d.key(op.Key), op.Value))
+		case dbservice.OpDelete:
+			etcdOps = append(etcdOps, v3.OpDelete(d.key(op.Key)))
+		default:
+			return nil, fmt.Errorf("unsupported transaction operation %d", op.Type)
+		}
+	}
+	return etcdOps, nil
+}
+
 func handleError(err error) error {
 	switch err {
 	case context.Canceled:
@@ -165,3 +165,20 @@ func _TestDBClientSuite2(t *testing.T) {
 	}
 	assert.Nil(t, gotVal, fmt.Sprintf("Get() = Expected: nil, Got: %v", gotVal))
 }
+
+func TestNewClientWithOptionsValidation(t *testing.T) {
+	_, err := NewClientWithOptions(&ClientOptions{})
+	assert.NotNil(t, err, "expected an error without endpoints")
+
+	_, err = NewClientWithOptions(&ClientOptions{
+		EndPoints: []string{"127.0.0.1:2379"},
+		TLS:       &TLSOptions{CAFile: "/nonexistent/ca.crt"},
+	})
+	assert.NotNil(t, err, "expected an error for a missing CA file")
+}
+
+func TestNamespace(t *testing.T) {
+	dbClient := &DBClient{Namespace: "/hpe-storage/"}
+	assert.Equal(t, "/hpe-storage/volumes/a", dbClient.key("volumes/a"))
+	assert.Equal(t, "volumes/a", dbClient.trimKey([]byte("/hpe-storage/volumes/a")))
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package dbservice
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Scalingo/go-etcd-lock/lock"
+)
+
+// LockTable implements the lock operations of a local DBService implementation.  As with etcd, a lock is released
+// automatically once its TTL elapses.
+type LockTable struct {
+	mutex sync.Mutex
+	locks map[string]*tableLock
+}
+
+// tableLock is a lock held in a LockTable
+type tableLock struct {
+	table    *LockTable
+	key      string
+	timer    *time.Timer
+	released chan struct{} // closed once the lock is released or expires
+}
+
+// NewLockTable returns a table without locks
+func NewLockTable() *LockTable {
+	return &LockTable{locks: make(map[string]*tableLock)}
+}
+
+// IsLocked checks if the given key is locked
+func (table *LockTable) IsLocked(key string) bool {
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+	_, ok := table.locks[key]
+	return ok
+}
+
+// Acquire the lock of key for ttl seconds, a *lock.Error is returned when it is already held
+func (table *LockTable) Acquire(key string, ttl int) (lock.Lock, error) {
+	if ttl < 1 {
+		return nil, fmt.Errorf("lock TTL must be at least 1 second")
+	}
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+	if _, ok := table.locks[key]; ok {
+		return nil, &lock.Error{}
+	}
+	lck := &tableLock{table: table, key: key, released: make(chan struct{})}
+	lck.timer = time.AfterFunc(time.Duration(ttl)*time.Second, func() { lck.Release() })
+	table.locks[key] = lck
+	return lck, nil
+}
+
+// WaitAcquire the lock of key for ttl seconds, waiting for the current holder to release it or for it to expire
+func (table *LockTable) WaitAcquire(key string, ttl int) (lock.Lock, error) {
+	for {
+		lck, err := table.Acquire(key, ttl)
+		if _, held := err.(*lock.Error); !held {
+			return lck, err
+		}
+		table.mutex.Lock()
+		holder, ok := table.locks[key]
+		table.mutex.Unlock()
+		if ok {
+			<-holder.released
+		}
+	}
+}
+
+// Release the lock, releasing a lock twice is a no-op
+func (lck *tableLock) Release() error {
+	if lck == nil {
+		return fmt.Errorf("nil lock")
+	}
+	lck.table.mutex.Lock()
+	defer lck.table.mutex.Unlock()
+	if lck.table.locks[lck.key] != lck {
+		return nil
+	}
+	lck.timer.Stop()
+	delete(lck.table.locks, lck.key)
+	close(lck.released)
+	return nil
+}
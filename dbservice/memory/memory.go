@@ -0,0 +1,207 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Scalingo/go-etcd-lock/lock"
+
+	"github.com/hpe-storage/common-host-libs/dbservice"
+)
+
+// entry is a stored value and the lease timer removing it, if any
+type entry struct {
+	value string
+	lease *time.Timer
+}
+
+// DBClient is an in-memory implementor of the DBService interface.  Keys put with a lease are deleted, and their
+// watchers notified, once the lease expires.  Locks are released once their TTL elapses.
+type DBClient struct {
+	mutex sync.Mutex
+	data  map[string]*entry
+	locks *dbservice.LockTable
+	hub   *dbservice.WatchHub
+}
+
+var _ dbservice.DBService = &DBClient{}
+
+// NewClient creates an empty in-memory DB
+func NewClient() *DBClient {
+	return &DBClient{
+		data:  make(map[string]*entry),
+		locks: dbservice.NewLockTable(),
+		hub:   dbservice.NewWatchHub(),
+	}
+}
+
+// Get value from DB
+func (d *DBClient) Get(key string) (*string, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	e, ok := d.data[key]
+	if !ok {
+		// Key not found and No error
+		return nil, nil
+	}
+	value := e.value
+	return &value, nil
+}
+
+// Put value to DB
+func (d *DBClient) Put(key string, value string) error {
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.put(key, value, nil)
+	return nil
+}
+
+// PutWithLeaseExpiry value to DB, the key is deleted once the lease expires
+func (d *DBClient) PutWithLeaseExpiry(key string, value string, seconds int64) error {
+	// Minimum lease TTL is 5-second, same as etcd
+	if seconds < 5 {
+		return fmt.Errorf("Minimum lease TTL is 5 seconds")
+	}
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	e := &entry{value: value}
+	e.lease = time.AfterFunc(time.Duration(seconds)*time.Second, func() { d.expire(key, e) })
+	d.put(key, value, e)
+	return nil
+}
+
+// Delete value from DB
+func (d *DBClient) Delete(key string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.delete(key)
+	return nil
+}
+
+// List values with the given key prefix
+func (d *DBClient) List(prefix string) ([]*dbservice.KeyValue, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	keyValues := []*dbservice.KeyValue{}
+	for key, e := range d.data {
+		if strings.HasPrefix(key, prefix) {
+			keyValues = append(keyValues, &dbservice.KeyValue{Key: key, Value: e.value})
+		}
+	}
+	sort.Slice(keyValues, func(i, j int) bool { return keyValues[i].Key < keyValues[j].Key })
+	return keyValues, nil
+}
+
+// Watch the keys with the given prefix until the context is done
+func (d *DBClient) Watch(ctx context.Context, prefix string) (<-chan *dbservice.WatchEvent, error) {
+	return d.hub.Watch(ctx, prefix), nil
+}
+
+// Transaction applies the success or failure operations atomically depending on the comparisons
+func (d *DBClient) Transaction(compares []*dbservice.Compare, success []*dbservice.Op, failure []*dbservice.Op) (bool, error) {
+	if err := dbservice.ValidateOps(success, failure); err != nil {
+		return false, err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	succeeded := true
+	for _, compare := range compares {
+		e, ok := d.data[compare.Key]
+		if compare.Value == nil {
+			succeeded = !ok
+		} else {
+			succeeded = ok && e.value == *compare.Value
+		}
+		if !succeeded {
+			break
+		}
+	}
+	ops := success
+	if !succeeded {
+		ops = failure
+	}
+	for _, op := range ops {
+		if op.Type == dbservice.OpPut {
+			d.put(op.Key, op.Value, nil)
+		} else {
+			d.delete(op.Key)
+		}
+	}
+	return succeeded, nil
+}
+
+// CompareAndSwap sets key to newValue when its current value is oldValue
+func (d *DBClient) CompareAndSwap(key string, oldValue *string, newValue string) (bool, error) {
+	compare := &dbservice.Compare{Key: key, Value: oldValue}
+	return d.Transaction([]*dbservice.Compare{compare}, []*dbservice.Op{dbservice.NewPutOp(key, newValue)}, nil)
+}
+
+// IsLocked checks if the given key is already locked
+func (d *DBClient) IsLocked(key string) (bool, error) {
+	return d.locks.IsLocked(key), nil
+}
+
+// AcquireLock for the given key, failing when it is already held
+func (d *DBClient) AcquireLock(key string, ttl int) (lock.Lock, error) {
+	return d.locks.Acquire(key, ttl)
+}
+
+// WaitAcquireLock for the given key, waiting until it is released or expires
+func (d *DBClient) WaitAcquireLock(key string, ttl int) (lock.Lock, error) {
+	return d.locks.WaitAcquire(key, ttl)
+}
+
+// ReleaseLock for the given key
+func (d *DBClient) ReleaseLock(lck lock.Lock) error {
+	if lck == nil {
+		return fmt.Errorf("nil lock")
+	}
+	return lck.Release()
+}
+
+// put stores the entry of key, replacing (and cancelling the lease of) any previous entry
+func (d *DBClient) put(key, value string, e *entry) {
+	if e == nil {
+		e = &entry{value: value}
+	}
+	if old, ok := d.data[key]; ok && old.lease != nil {
+		old.lease.Stop()
+	}
+	d.data[key] = e
+	d.hub.Notify(&dbservice.WatchEvent{Type: dbservice.EventPut, Key: key, Value: value})
+}
+
+func (d *DBClient) delete(key string) {
+	old, ok := d.data[key]
+	if !ok {
+		return
+	}
+	if old.lease != nil {
+		old.lease.Stop()
+	}
+	delete(d.data, key)
+	d.hub.Notify(&dbservice.WatchEvent{Type: dbservice.EventDelete, Key: key})
+}
+
+// expire deletes key when its lease fires, unless the entry was replaced in the meantime
+func (d *DBClient) expire(key string, e *entry) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.data[key] == e {
+		d.delete(key)
+	}
+}
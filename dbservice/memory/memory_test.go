@@ -0,0 +1,134 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hpe-storage/common-host-libs/dbservice"
+	"github.com/hpe-storage/common-host-libs/dbservice/dbservicetest"
+)
+
+func TestConformance(t *testing.T) {
+	dbservicetest.RunConformance(t, func(t *testing.T) dbservice.DBService {
+		return NewClient()
+	})
+}
+
+func TestGetPutDeleteList(t *testing.T) {
+	db := NewClient()
+	assert.Nil(t, db.Put("/volumes/b", "2"))
+	assert.Nil(t, db.Put("/volumes/a", "1"))
+	assert.Nil(t, db.Put("/hosts/a", "h"))
+
+	value, err := db.Get("/volumes/a")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", *value)
+
+	list, err := db.List("/volumes/")
+	assert.Nil(t, err)
+	assert.Equal(t, []*dbservice.KeyValue{{Key: "/volumes/a", Value: "1"}, {Key: "/volumes/b", Value: "2"}}, list)
+
+	assert.Nil(t, db.Delete("/volumes/a"))
+	value, err = db.Get("/volumes/a")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	assert.NotNil(t, db.PutWithLeaseExpiry("/volumes/c", "3", 1))
+}
+
+func TestTransaction(t *testing.T) {
+	db := NewClient()
+	swapped, err := db.CompareAndSwap("owner", nil, "host1")
+	assert.Nil(t, err)
+	assert.True(t, swapped)
+
+	// a second create must fail, the key exists
+	swapped, err = db.CompareAndSwap("owner", nil, "host2")
+	assert.Nil(t, err)
+	assert.False(t, swapped)
+
+	old := "host1"
+	swapped, err = db.CompareAndSwap("owner", &old, "host2")
+	assert.Nil(t, err)
+	assert.True(t, swapped)
+
+	succeeded, err := db.Transaction(
+		[]*dbservice.Compare{dbservice.CompareValue("owner", "host1")},
+		[]*dbservice.Op{dbservice.NewDeleteOp("owner")},
+		[]*dbservice.Op{dbservice.NewPutOp("conflict", "owner changed")},
+	)
+	assert.Nil(t, err)
+	assert.False(t, succeeded)
+	value, _ := db.Get("conflict")
+	assert.Equal(t, "owner changed", *value)
+	value, _ = db.Get("owner")
+	assert.Equal(t, "host2", *value)
+}
+
+func TestWatch(t *testing.T) {
+	db := NewClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := db.Watch(ctx, "/volumes/")
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Put("/hosts/a", "ignored"))
+	assert.Nil(t, db.Put("/volumes/a", "1"))
+	assert.Nil(t, db.Delete("/volumes/a"))
+
+	assert.Equal(t, &dbservice.WatchEvent{Type: dbservice.EventPut, Key: "/volumes/a", Value: "1"}, <-events)
+	assert.Equal(t, &dbservice.WatchEvent{Type: dbservice.EventDelete, Key: "/volumes/a"}, <-events)
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok, "expected the channel to be closed")
+	case <-time.After(time.Second):
+		t.Error("expected the channel to be closed once the context is done")
+	}
+}
+
+func TestWatchOverflow(t *testing.T) {
+	db := NewClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := db.Watch(ctx, "/volumes/")
+	assert.Nil(t, err)
+
+	// the watcher is not reading, it is cancelled once its buffer is full rather than missing events
+	for i := 0; i < 300; i++ {
+		assert.Nil(t, db.Put("/volumes/a", fmt.Sprint(i)))
+	}
+	var received []*dbservice.WatchEvent
+	for event := range events {
+		received = append(received, event)
+	}
+	if assert.Len(t, received, 257) {
+		assert.Equal(t, &dbservice.WatchEvent{Type: dbservice.EventPut, Key: "/volumes/a", Value: "255"}, received[255])
+		assert.Equal(t, &dbservice.WatchEvent{Err: dbservice.ErrWatchOverflow}, received[256])
+	}
+}
+
+func TestLocks(t *testing.T) {
+	db := NewClient()
+	lck, err := db.AcquireLock("mylock", 30)
+	assert.Nil(t, err)
+	locked, _ := db.IsLocked("mylock")
+	assert.True(t, locked)
+
+	_, err = db.AcquireLock("mylock", 30)
+	assert.NotNil(t, err)
+
+	assert.Nil(t, db.ReleaseLock(lck))
+	assert.Nil(t, db.ReleaseLock(lck))
+	locked, _ = db.IsLocked("mylock")
+	assert.False(t, locked)
+
+	lck, err = db.WaitAcquireLock("mylock", 30)
+	assert.Nil(t, err)
+	assert.NotNil(t, lck)
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package dbservice
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+const (
+	// watchBufferSize is the number of events buffered for each watcher
+	watchBufferSize = 256
+)
+
+// watcher receives the changes of keys with its prefix, its channel has one more slot than watchBufferSize for the
+// ErrWatchOverflow event
+type watcher struct {
+	prefix string
+	events chan *WatchEvent
+}
+
+// WatchHub fans out the changes of a local DBService implementation to its watchers
+type WatchHub struct {
+	mutex    sync.Mutex
+	watchers map[*watcher]bool
+}
+
+// NewWatchHub returns a hub without watchers
+func NewWatchHub() *WatchHub {
+	return &WatchHub{watchers: make(map[*watcher]bool)}
+}
+
+// Watch returns a channel receiving the changes of keys with the given prefix, the channel is closed once the
+// context is done
+func (hub *WatchHub) Watch(ctx context.Context, prefix string) <-chan *WatchEvent {
+	w := &watcher{prefix: prefix, events: make(chan *WatchEvent, watchBufferSize+1)}
+	hub.mutex.Lock()
+	hub.watchers[w] = true
+	hub.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		hub.mutex.Lock()
+		// a watcher cancelled by Notify is already closed
+		if hub.watchers[w] {
+			delete(hub.watchers, w)
+			close(w.events)
+		}
+		hub.mutex.Unlock()
+	}()
+	return w.events
+}
+
+// Notify delivers the event to the watchers of its key.  Implementations call it while holding their own lock so
+// watchers see changes in the order they were applied.  A watcher that is not keeping up is cancelled with
+// ErrWatchOverflow rather than missing events.
+func (hub *WatchHub) Notify(event *WatchEvent) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	for w := range hub.watchers {
+		if !strings.HasPrefix(event.Key, w.prefix) {
+			continue
+		}
+		// only the hub sends, so the buffer cannot fill up between the check and the send
+		if len(w.events) < watchBufferSize {
+			w.events <- event
+			continue
+		}
+		log.Errorf("watcher of %s is not keeping up, cancelling it at %s event for %s", w.prefix, event.Type, event.Key)
+		w.events <- &WatchEvent{Err: ErrWatchOverflow}
+		delete(hub.watchers, w)
+		close(w.events)
+	}
+}
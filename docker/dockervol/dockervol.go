@@ -303,16 +303,32 @@ func (dvp *DockerVolumePlugin) Unmount(name, mountID string) error {
 
 //Delete calls the delete function of the plugin
 func (dvp *DockerVolumePlugin) Delete(name string, managerName string) error {
-	if name == "" {
-		return fmt.Errorf("name is required")
-	}
-	var req *Request
+	var opts map[string]interface{}
 	if managerName != "" {
-		req = &Request{Name: name, Opts: map[string]interface{}{"manager": managerName}}
-	} else {
-		req = &Request{Name: name}
+		opts = map[string]interface{}{"manager": managerName}
 	}
+	return dvp.remove(name, opts)
+}
 
+//Release detaches the docker volume and hands it back to the array without deleting its data.  The array
+//volume is renamed to newName if it is not empty.  A volume still in use by another host is only released
+//when takeover is true.
+func (dvp *DockerVolumePlugin) Release(name, newName string, takeover bool) error {
+	opts := map[string]interface{}{"release": "true"}
+	if newName != "" {
+		opts["releaseName"] = newName
+	}
+	if takeover {
+		opts["takeover"] = "true"
+	}
+	return dvp.remove(name, opts)
+}
+
+func (dvp *DockerVolumePlugin) remove(name string, options map[string]interface{}) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+	var req = &Request{Name: name, Opts: options}
 	var res = &GetResponse{}
 
 	err := dvp.driverRun(&connectivity.Request{
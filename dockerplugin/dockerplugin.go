@@ -16,68 +16,114 @@ var (
 	LogLevel = "info"
 )
 
-// NewRouter creates a new mux.Router
+// volumeDriverHandlers are the handlers of the docker volume plugin end points
+type volumeDriverHandlers struct {
+	activate     http.HandlerFunc
+	list         http.HandlerFunc
+	create       http.HandlerFunc
+	mount        http.HandlerFunc
+	remove       http.HandlerFunc
+	capabilities http.HandlerFunc
+	get          http.HandlerFunc
+	path         http.HandlerFunc
+	unmount      http.HandlerFunc
+	update       http.HandlerFunc
+}
+
+// NewRouter creates a new mux.Router which proxies volume requests to the container provider
 func NewRouter() *mux.Router {
+	return newRouter(volumeDriverHandlers{
+		activate:     handler.ActivatePlugin,
+		list:         handler.VolumeDriverList,
+		create:       handler.VolumeDriverCreate,
+		mount:        handler.VolumeDriverMount,
+		remove:       handler.VolumeDriverRemove,
+		capabilities: handler.VolumeDriverCapabilities,
+		get:          handler.VolumeDriverGet,
+		path:         handler.VolumeDriverPath,
+		unmount:      handler.VolumeDriverUnmount,
+		update:       handler.VolumeDriverUpdate,
+	})
+}
+
+// NewStorageProviderRouter creates a new mux.Router which serves volume requests with the given storage provider handler
+func NewStorageProviderRouter(h *handler.StorageProviderHandler) *mux.Router {
+	return newRouter(volumeDriverHandlers{
+		activate:     h.ActivatePlugin,
+		list:         h.VolumeDriverList,
+		create:       h.VolumeDriverCreate,
+		mount:        h.VolumeDriverMount,
+		remove:       h.VolumeDriverRemove,
+		capabilities: h.VolumeDriverCapabilities,
+		get:          h.VolumeDriverGet,
+		path:         h.VolumeDriverPath,
+		unmount:      h.VolumeDriverUnmount,
+		update:       h.VolumeDriverUpdate,
+	})
+}
+
+// newRouter creates a new mux.Router serving the docker volume plugin end points with the given handlers
+func newRouter(h volumeDriverHandlers) *mux.Router {
 	routes := []util.Route{
 		util.Route{
 			Name:        "Activate Plugin",
 			Method:      "POST",
 			Pattern:     "/Plugin.Activate",
-			HandlerFunc: handler.ActivatePlugin,
+			HandlerFunc: h.activate,
 		},
 		util.Route{
 			Name:        "Volume  list",
 			Method:      "POST",
 			Pattern:     "/VolumeDriver.List",
-			HandlerFunc: handler.VolumeDriverList,
+			HandlerFunc: h.list,
 		},
 		util.Route{
 			Name:        "Volume Create",
 			Method:      "POST",
 			Pattern:     "/VolumeDriver.Create",
-			HandlerFunc: handler.VolumeDriverCreate,
+			HandlerFunc: h.create,
 		},
 		util.Route{
 			Name:        "Volume Mount",
 			Method:      "POST",
 			Pattern:     "/VolumeDriver.Mount",
-			HandlerFunc: handler.VolumeDriverMount,
+			HandlerFunc: h.mount,
 		},
 		util.Route{
 			Name:        "Volume Remove",
 			Method:      "POST",
 			Pattern:     "/VolumeDriver.Remove",
-			HandlerFunc: handler.VolumeDriverRemove,
+			HandlerFunc: h.remove,
 		},
 		util.Route{
 			Name:        "Volume Driver Capabilities",
 			Method:      "POST",
 			Pattern:     "/VolumeDriver.Capabilities",
-			HandlerFunc: handler.VolumeDriverCapabilities,
+			HandlerFunc: h.capabilities,
 		},
 		util.Route{
 			Name:        "Volume Driver Get",
 			Method:      "POST",
 			Pattern:     "/VolumeDriver.Get",
-			HandlerFunc: handler.VolumeDriverGet,
+			HandlerFunc: h.get,
 		},
 		util.Route{
 			Name:        "Volume Driver Path",
 			Method:      "POST",
 			Pattern:     "/VolumeDriver.Path",
-			HandlerFunc: handler.VolumeDriverPath,
+			HandlerFunc: h.path,
 		},
 		util.Route{
 			Name:        "Volume Driver Unmount",
 			Method:      "POST",
 			Pattern:     "/VolumeDriver.Unmount",
-			HandlerFunc: handler.VolumeDriverUnmount,
+			HandlerFunc: h.unmount,
 		},
 		util.Route{
 			Name:        "Volume Driver Update",
 			Method:      "PUT",
 			Pattern:     "/VolumeDriver.Update",
-			HandlerFunc: handler.VolumeDriverUpdate,
+			HandlerFunc: h.update,
 		},
 	}
 	router := mux.NewRouter().StrictSlash(true)
@@ -3,8 +3,19 @@
 package dockerplugin
 
 import (
-	log "github.com/hpe-storage/common-host-libs/logger"
+	"path/filepath"
+	"time"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/chapiclient"
+	"github.com/hpe-storage/common-host-libs/dockerplugin/handler"
 	"github.com/hpe-storage/common-host-libs/dockerplugin/plugin"
+	log "github.com/hpe-storage/common-host-libs/logger"
+	"github.com/hpe-storage/common-host-libs/storageprovider"
+)
+
+const (
+	// timeout for chapi2 requests, attach and filesystem creation can take a while
+	chapiClientTimeout = time.Duration(300) * time.Second
 )
 
 // RunNimbledockerd runs listeners fordocker sockets
@@ -17,7 +28,7 @@ func RunNimbledockerd(c chan error, version string) (err error) {
 		return err
 	}
 	// check and create config directory
-	_, err = plugin.GetOrCreatePluginConfigDirectory()
+	configDir, err := plugin.GetOrCreatePluginConfigDirectory()
 	if err != nil {
 		return nil
 	}
@@ -36,9 +47,64 @@ func RunNimbledockerd(c chan error, version string) (err error) {
 	// initialize the DeleteConflictDelay timeout
 	plugin.InitializeDeleteConflictDelay()
 
+	// load the mount references and drop the ones no longer mounted on the host
+	err = handler.InitializeMountTable(filepath.Join(configDir, plugin.MountTableFileName))
+	if err != nil {
+		log.Errorf("unable to initialize the mount table %s", err.Error())
+	}
+
 	// listen on the new sockets
 	router := NewRouter()
 	//use channel to listen to multiple sockets simultaneously
 	go runNimbledockerd(listener, router, c)
 	return nil
 }
+
+// RunStorageProviderDockerd runs the docker plugin listener serving volume requests directly from the
+// given storage provider, host operations are handled by the chapi2 server on this host
+func RunStorageProviderDockerd(c chan error, version string, provider storageprovider.StorageProvider) (err error) {
+	// version from build process
+	plugin.Version = version
+	// create listener for the socket
+	listener, err := plugin.PreparePluginSocket()
+	if err != nil {
+		return err
+	}
+	// check and create config directory
+	configDir, err := plugin.GetOrCreatePluginConfigDirectory()
+	if err != nil {
+		return err
+	}
+	// check and create mount directory
+	mountDir, err := plugin.GetOrCreatePluginMountDirectory()
+	if err != nil {
+		return err
+	}
+	// load the HPE Volume Config Cache, defaults are optional for the storage provider backend
+	err = plugin.LoadHPEVolConfig()
+	if err != nil {
+		log.Infof("unable to load hpe volume config %s, continuing without defaults", err.Error())
+	}
+
+	chapiClient, err := chapiclient.NewChapiClientWithTimeout(chapiClientTimeout)
+	if err != nil {
+		log.Errorf("unable to create chapi client %s", err.Error())
+		return err
+	}
+
+	// load the mount references and rebuild them from the mounts on the host
+	mounts, err := plugin.NewMountTable(filepath.Join(configDir, plugin.MountTableFileName))
+	if err != nil {
+		return err
+	}
+	storageProviderHandler := handler.NewStorageProviderHandler(provider, chapiClient, mountDir, mounts)
+	err = storageProviderHandler.ReconcileMounts()
+	if err != nil {
+		log.Errorf("unable to reconcile volume mounts %s", err.Error())
+	}
+
+	// listen on the new sockets
+	router := NewStorageProviderRouter(storageProviderHandler)
+	go runNimbledockerd(listener, router, c)
+	return nil
+}
@@ -0,0 +1,232 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package dockerplugin
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	chapiDriver "github.com/hpe-storage/common-host-libs/chapi2/driver"
+	"github.com/hpe-storage/common-host-libs/docker/dockervol"
+	"github.com/hpe-storage/common-host-libs/dockerplugin/handler"
+	"github.com/hpe-storage/common-host-libs/dockerplugin/plugin"
+	"github.com/hpe-storage/common-host-libs/storageprovider/fake"
+)
+
+const (
+	conformanceVolume = "conformance-vol"
+	conformanceClone  = "conformance-clone"
+)
+
+// conformancePlugin serves the storage provider router on a temporary unix socket
+type conformancePlugin struct {
+	dir      string
+	mountDir string
+	listener net.Listener
+	client   *dockervol.DockerVolumePlugin
+}
+
+// startConformancePlugin serves a storage provider handler backed by the given fakes and returns a dockervol client for it
+func startConformancePlugin(t *testing.T, dir string, provider *fake.StorageProvider, chapi *chapiDriver.FakeDriver) *conformancePlugin {
+	mountDir := filepath.Join(dir, "mounts") + "/"
+	mounts, err := plugin.NewMountTable(filepath.Join(dir, plugin.MountTableFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.NewStorageProviderHandler(provider, chapi, mountDir, mounts)
+	if err = h.ReconcileMounts(); err != nil {
+		t.Fatal(err)
+	}
+
+	socketPath := filepath.Join(dir, "plugin.sock")
+	os.Remove(socketPath)
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go http.Serve(listener, NewStorageProviderRouter(h))
+
+	client, err := dockervol.NewDockerVolumePlugin(&dockervol.Options{SocketPath: socketPath, SupportsCapabilities: true})
+	if err != nil {
+		listener.Close()
+		t.Fatalf("unable to connect to plugin, %s", err.Error())
+	}
+	return &conformancePlugin{dir: dir, mountDir: mountDir, listener: listener, client: client}
+}
+
+func (p *conformancePlugin) stop() {
+	p.listener.Close()
+}
+
+func (p *conformancePlugin) mountPoint(t *testing.T, name string) string {
+	res, err := p.client.Get(name)
+	if err != nil {
+		t.Fatalf("unable to get volume %s, %s", name, err.Error())
+	}
+	if res.Volume.Name != name {
+		t.Fatalf("expected volume %s, got %s", name, res.Volume.Name)
+	}
+	return res.Volume.Mountpoint
+}
+
+// nolint: gocyclo
+func TestStorageProviderPluginConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockerplugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	provider := fake.NewFakeStorageProvider()
+	chapi := chapiDriver.NewFakeDriver()
+	p := startConformancePlugin(t, dir, provider, chapi)
+	defer func() { p.stop() }()
+	c := p.client
+
+	// capabilities
+	capabilities, err := c.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capabilities.Capabilities.Scope != "global" {
+		t.Errorf("expected global scope, got %s", capabilities.Capabilities.Scope)
+	}
+
+	// create
+	if _, err = c.Create(conformanceVolume, map[string]interface{}{"size": "1", "filesystem": "ext4", "destroyOnRm": "true"}); err != nil {
+		t.Fatalf("unable to create volume, %s", err.Error())
+	}
+	if _, err = c.Create(conformanceVolume, map[string]interface{}{"size": "1"}); err != nil {
+		t.Errorf("expected create of an existing volume to succeed, got %s", err.Error())
+	}
+	if _, err = c.Create(conformanceClone, map[string]interface{}{"cloneOf": conformanceVolume}); err != nil {
+		t.Errorf("unable to clone volume, %s", err.Error())
+	}
+	createErrors := map[string]map[string]interface{}{
+		"unknown option":     {"sizeInGB": "1"},
+		"invalid size":       {"size": "-1"},
+		"invalid filesystem": {"filesystem": "fat"},
+		"missing parent":     {"cloneOf": "missing"},
+		"invalid fsMode":     {"fsMode": "999"},
+	}
+	for name, opts := range createErrors {
+		if _, err = c.Create("conformance-bad", opts); err == nil {
+			t.Errorf("%s: expected create to fail", name)
+		}
+	}
+	if _, err = c.Create("conformance-help", map[string]interface{}{"help": ""}); err == nil || !strings.Contains(err.Error(), "cloneOf") {
+		t.Errorf("expected help text, got %v", err)
+	}
+
+	// get and list
+	if mountPoint := p.mountPoint(t, conformanceVolume); mountPoint != "" {
+		t.Errorf("expected unmounted volume, got mount point %s", mountPoint)
+	}
+	if _, err = c.Get("missing"); err == nil {
+		t.Error("expected get of a missing volume to fail")
+	}
+	list, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Volumes) != 2 {
+		t.Errorf("expected 2 volumes, got %+v", list.Volumes)
+	}
+
+	// mount twice by different containers, then again by the same one
+	expected := p.mountDir + conformanceVolume
+	for _, id := range []string{"c1", "c2", "c2"} {
+		mountPoint, err := c.Mount(conformanceVolume, id)
+		if err != nil {
+			t.Fatalf("unable to mount volume by %s, %s", id, err.Error())
+		}
+		if mountPoint != expected {
+			t.Errorf("expected mount point %s, got %s", expected, mountPoint)
+		}
+	}
+	if mountPoint := p.mountPoint(t, conformanceVolume); mountPoint != expected {
+		t.Errorf("expected mount point %s, got %s", expected, mountPoint)
+	}
+	if mounts, _ := chapi.GetMounts(context.Background(), ""); len(mounts) != 1 {
+		t.Errorf("expected a single host mount, got %+v", mounts)
+	}
+	if _, err = c.Mount("missing", "c1"); err == nil {
+		t.Error("expected mount of a missing volume to fail")
+	}
+	if err = c.Delete(conformanceVolume, ""); err == nil {
+		t.Error("expected remove of a mounted volume to fail")
+	}
+
+	// the mount references survive a plugin restart
+	p.stop()
+	p = startConformancePlugin(t, dir, provider, chapi)
+	c = p.client
+
+	// unmount
+	if err = c.Unmount(conformanceVolume, "c1"); err != nil {
+		t.Fatalf("unable to unmount volume, %s", err.Error())
+	}
+	if mountPoint := p.mountPoint(t, conformanceVolume); mountPoint != expected {
+		t.Errorf("expected volume to stay mounted by c2, got mount point %s", mountPoint)
+	}
+	if err = c.Unmount(conformanceVolume, "c2"); err != nil {
+		t.Fatalf("unable to unmount volume, %s", err.Error())
+	}
+	if mountPoint := p.mountPoint(t, conformanceVolume); mountPoint != "" {
+		t.Errorf("expected volume to be unmounted, got mount point %s", mountPoint)
+	}
+	if err = c.Unmount(conformanceVolume, "c2"); err != nil {
+		t.Errorf("expected repeated unmount to succeed, got %s", err.Error())
+	}
+	if devices, _ := chapi.GetDevices(context.Background(), ""); len(devices) != 0 {
+		t.Errorf("expected device to be detached, got %+v", devices)
+	}
+
+	// update
+	if _, err = c.Update(conformanceVolume, map[string]interface{}{"description": "updated"}); err != nil {
+		t.Errorf("unable to update volume, %s", err.Error())
+	}
+	if volume, _ := provider.GetVolumeByName(conformanceVolume); volume == nil || volume.Config["description"] != "updated" {
+		t.Errorf("expected volume description to be updated, got %+v", volume)
+	}
+	if _, err = c.Update("missing", map[string]interface{}{"description": "updated"}); err == nil {
+		t.Error("expected update of a missing volume to fail")
+	}
+	for name, opts := range map[string]map[string]interface{}{
+		"unknown option":     {"sizeInGB": "1"},
+		"create only option": {"cloneOf": conformanceClone},
+		"internal option":    {"delayedCreate": "true"},
+	} {
+		if _, err = c.Update(conformanceVolume, opts); err == nil {
+			t.Errorf("%s: expected update to fail", name)
+		}
+	}
+
+	// remove
+	if err = c.Release(conformanceVolume, "", false); err == nil {
+		t.Error("expected release to be rejected by the storage provider backend")
+	}
+	for _, name := range []string{conformanceClone, conformanceVolume} {
+		if err = c.Delete(name, ""); err != nil {
+			t.Errorf("unable to remove volume %s, %s", name, err.Error())
+		}
+	}
+	// without destroyOnRm the array volume is kept and only detached from this host
+	if volume, _ := provider.GetVolumeByName(conformanceClone); volume == nil || volume.Published {
+		t.Errorf("expected the clone to be kept unpublished, got %+v", volume)
+	}
+	if devices, _ := chapi.GetDevices(context.Background(), ""); len(devices) != 0 {
+		t.Errorf("expected no attached devices, got %+v", devices)
+	}
+	if _, err = c.Get(conformanceVolume); err == nil {
+		t.Error("expected get of a removed volume to fail")
+	}
+	if err = c.Delete(conformanceVolume, ""); err == nil {
+		t.Error("expected remove of a removed volume to fail")
+	}
+}
@@ -3,8 +3,11 @@
 package dockerplugin
 
 import (
-	log "github.com/hpe-storage/common-host-libs/logger"
+	"path/filepath"
+
+	"github.com/hpe-storage/common-host-libs/dockerplugin/handler"
 	"github.com/hpe-storage/common-host-libs/dockerplugin/plugin"
+	log "github.com/hpe-storage/common-host-libs/logger"
 )
 
 // RunNimbledockerd runs listeners fordocker sockets
@@ -17,7 +20,7 @@ func RunNimbledockerd(c chan error, version string) (err error) {
 		return err
 	}
 	// check and create config directory
-	_, err = plugin.GetOrCreatePluginConfigDirectory()
+	configDir, err := plugin.GetOrCreatePluginConfigDirectory()
 	if err != nil {
 		return nil
 	}
@@ -39,6 +42,13 @@ func RunNimbledockerd(c chan error, version string) (err error) {
 
 	// Control the mountConflictDelay behavior as it is causing default timeout 120 sec.
 	plugin.InitializeMountConflictDelay()
+
+	// load the mount references and drop the ones no longer mounted on the host
+	err = handler.InitializeMountTable(filepath.Join(configDir, plugin.MountTableFileName))
+	if err != nil {
+		log.Errorf("unable to initialize the mount table %s", err.Error())
+	}
+
 	// listen on the http port
 	router := NewRouter()
 
@@ -46,6 +46,19 @@ func VolumeDriverCreate(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(dr)
 		return
 	}
+	if pluginReq.Opts == nil {
+		pluginReq.Opts = make(map[string]interface{})
+	}
+
+	// generic help is answered locally from the option schema of this plugin type
+	schema := plugin.GetCreateOptionSchema(plugin.GetPluginType())
+	if plugin.IsHelpRequest(pluginReq.Opts) {
+		log.Trace("Help Message")
+		cr.Err = schema.Help()
+		json.NewEncoder(w).Encode(cr)
+		return
+	}
+
 	//get containerProviderClient
 	providerClient, err := provider.GetProviderClient()
 	if err != nil {
@@ -55,11 +68,27 @@ func VolumeDriverCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// validate the options given on the command line, the config defaults may hold keys the schema doesn't know
+	createOpts, err := validateCreateOptions(schema, pluginReq)
+	if err != nil {
+		dr := DriverResponse{Err: err.Error()}
+		json.NewEncoder(w).Encode(dr)
+		return
+	}
+
 	// populate defaut create options
 	err = populateVolCreateOptions(pluginReq)
 	if err != nil {
 		log.Errorf("%s failed to add mount options from config file using defaults", err.Error())
 	}
+	createOpts, err = parseMergedCreateOptions(createOpts, pluginReq.Opts)
+	if err != nil {
+		dr := DriverResponse{Err: err.Error()}
+		json.NewEncoder(w).Encode(dr)
+		return
+	}
+	// write back the typed values of the options
+	createOpts.Apply(pluginReq.Opts)
 
 	// validate fsMode and fsOwner if specified in the request
 	fsMode, fsOwner, err := getFileSystemModeAndOwnerFromRequest(pluginReq)
@@ -71,9 +100,9 @@ func VolumeDriverCreate(w http.ResponseWriter, r *http.Request) {
 	fsOpts := &model.FilesystemOpts{Mode: fsMode, Owner: fsOwner}
 
 	// populate delayed create option to pluginReq except for import and clone workflows
-	if !isValidDelayedCreateOpt(pluginReq) {
+	if !createOpts.IsClone() && !createOpts.IsImport() {
 		log.Tracef("valid delayedCreate opts (%v) setting delayedCreate to true", pluginReq.Opts)
-		pluginReq.Opts[delayedCreateOpt] = true
+		pluginReq.Opts[plugin.DelayedCreateOpt] = true
 	}
 
 	// remove global options from create request
@@ -177,7 +206,7 @@ func VolumeDriverCreate(w http.ResponseWriter, r *http.Request) {
 			}
 			var dr DriverResponse
 			//force delete the volume on create failures else it will lie around in offline state
-			pluginReq.Opts["destroyOnRm"] = true
+			pluginReq.Opts[plugin.DestroyOnRmOpt] = true
 			providerClient.DoJSON(&connectivity.Request{Action: "POST", Path: provider.RemoveURI, Payload: &pluginReq, Response: &dr, ResponseError: nil})
 			dr = DriverResponse{Err: err.Error()}
 			json.NewEncoder(w).Encode(dr)
@@ -317,22 +346,30 @@ func populateVolCreateOptions(req *PluginRequest) (err error) {
 	return nil
 }
 
-func isValidDelayedCreateOpt(pluginReq *PluginRequest) bool {
-	log.Trace(">>>> isValidDelayedCreateOpt called")
-	defer log.Tracef("<<<< isValidDelayedCreateOpt")
-	_, ok := pluginReq.Opts["cloneOf"]
-	if ok {
-		return true
+// validateCreateOptions checks the request options against the plugin option schema and decodes the typed options
+func validateCreateOptions(schema *plugin.CreateOptionSchema, pluginReq *PluginRequest) (*plugin.CreateOptions, error) {
+	log.Trace(">>>>> validateCreateOptions called")
+	defer log.Trace("<<<<< validateCreateOptions")
+	err := schema.Validate(pluginReq.Opts)
+	if err != nil {
+		return nil, err
 	}
-	_, ok = pluginReq.Opts["importVolAsClone"]
-	if ok {
-		return true
+	return plugin.ParseCreateOptions(pluginReq.Opts)
+}
+
+// parseMergedCreateOptions decodes the typed options again once the config defaults are merged into the request
+// options validated by validateCreateOptions.  Imported volumes keep their size on the array, so a default size is
+// dropped for them.
+func parseMergedCreateOptions(createOpts *plugin.CreateOptions, opts map[string]interface{}) (*plugin.CreateOptions, error) {
+	if createOpts.IsImport() {
+		delete(opts, plugin.SizeOpt)
+		delete(opts, plugin.SizeInGiBOpt)
 	}
-	_, ok = pluginReq.Opts["importVol"]
-	if ok {
-		return true
+	merged, err := plugin.ParseCreateOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid create options after merging the config defaults, %s", err.Error())
 	}
-	return false
+	return merged, nil
 }
 
 func removeGlobalOptionsFromCreateRequest(pluginReq *PluginRequest) error {
@@ -0,0 +1,56 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/dockerplugin/plugin"
+)
+
+func TestCreateOptionsWithConfigDefaults(t *testing.T) {
+	schema := plugin.GetCreateOptionSchema(plugin.Nimble)
+
+	// the shipped config file sets a default size, which must not break importVol
+	pluginReq := &PluginRequest{Name: "vol1", Opts: map[string]interface{}{plugin.ImportVolOpt: "arrayvol"}}
+	createOpts, err := validateCreateOptions(schema, pluginReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pluginReq.Opts[plugin.SizeInGiBOpt] = "10"
+	pluginReq.Opts["unknownDefault"] = "value"
+	createOpts, err = parseMergedCreateOptions(createOpts, pluginReq.Opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if createOpts.ImportVol != "arrayvol" || createOpts.SizeInGiB != 0 {
+		t.Errorf("unexpected options %+v", createOpts)
+	}
+	if _, ok := pluginReq.Opts[plugin.SizeInGiBOpt]; ok {
+		t.Error("expected the default size to be dropped on import")
+	}
+
+	// defaults are decoded along with the request options
+	pluginReq = &PluginRequest{Name: "vol2", Opts: map[string]interface{}{"perfPolicy": "default"}}
+	if createOpts, err = validateCreateOptions(schema, pluginReq); err != nil {
+		t.Fatal(err)
+	}
+	pluginReq.Opts[plugin.SizeInGiBOpt] = "20"
+	pluginReq.Opts[plugin.DestroyOnRmOpt] = "true"
+	if createOpts, err = parseMergedCreateOptions(createOpts, pluginReq.Opts); err != nil {
+		t.Fatal(err)
+	}
+	if createOpts.SizeInGiB != 20 || !createOpts.DestroyOnRm {
+		t.Errorf("expected the config defaults to be decoded, got %+v", createOpts)
+	}
+	pluginReq.Opts[plugin.SizeInGiBOpt] = "large"
+	if _, err = parseMergedCreateOptions(createOpts, pluginReq.Opts); err == nil {
+		t.Error("expected an invalid default size to be rejected")
+	}
+
+	// options that are not in the schema are still rejected on the command line
+	pluginReq = &PluginRequest{Name: "vol3", Opts: map[string]interface{}{"unknownDefault": "value"}}
+	if _, err = validateCreateOptions(schema, pluginReq); err == nil {
+		t.Error("expected an unknown request option to be rejected")
+	}
+}
@@ -111,6 +111,13 @@ func VolumeDriverMount(w http.ResponseWriter, r *http.Request) {
 	log.Tracef("taken channel for volume %s in mount with channel length :%d channel capacity :%d", pluginReq.Name, len(mountRequestsChan), cap(mountRequestsChan))
 	defer unblockChannelHandler("mount", pluginReq.Name, mountRequestsChan)
 
+	// a mount ID already holding the volume is answered from the mount table
+	if ref := mountTable.Get(pluginReq.Name); ref != nil && mountTable.HasRef(pluginReq.Name, pluginReq.ID) && isMountedOnHost(chapiClient, ref) {
+		log.Infof("volume %s is already mounted on %s by %s", pluginReq.Name, ref.MountPoint, pluginReq.ID)
+		json.NewEncoder(w).Encode(MountResponse{MountPoint: ref.MountPoint})
+		return
+	}
+
 	//3. container-provider /VolumeDriver.Mount called
 	log.Debugf("/VolumeDriver.Mount for volume %s request=%+v", pluginReq.Name, pluginReq)
 	_, err = providerClient.DoJSON(&connectivity.Request{Action: "POST", Path: provider.MountURI, Payload: &pluginReq, Response: &volResp, ResponseError: &volResp})
@@ -161,6 +168,13 @@ func VolumeDriverMount(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	//6. record the mount ID holding the volume
+	if mr.Err == "" {
+		err = mountTable.Add(volume.Name, pluginReq.ID, volume.SerialNumber, mountPoint)
+		if err != nil {
+			log.Errorf("unable to record mount %s of volume %s, %s", pluginReq.ID, volume.Name, err.Error())
+		}
+	}
 	//always try to cleanup the filesystem metadata on the volume when there is no error on mount
 	if mr.Err == "" {
 		if _, ok := volume.Status[delayedCreateOpt]; ok {
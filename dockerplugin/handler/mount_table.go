@@ -0,0 +1,146 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package handler
+
+import (
+	"io/ioutil"
+
+	"github.com/hpe-storage/common-host-libs/chapi"
+	"github.com/hpe-storage/common-host-libs/connectivity"
+	"github.com/hpe-storage/common-host-libs/dockerplugin/plugin"
+	"github.com/hpe-storage/common-host-libs/dockerplugin/provider"
+	log "github.com/hpe-storage/common-host-libs/logger"
+	"github.com/hpe-storage/common-host-libs/model"
+)
+
+var (
+	// mountTable tracks the docker mount IDs holding each volume mounted by the container provider handlers
+	mountTable, _ = plugin.NewMountTable("")
+)
+
+// InitializeMountTable loads the persistent mount table at path and reconciles it with the mounts on the host
+func InitializeMountTable(path string) error {
+	table, err := plugin.NewMountTable(path)
+	if err != nil {
+		return err
+	}
+	mountTable = table
+	return ReconcileMounts()
+}
+
+// releaseMount releases the mount of the volume held by the docker mount ID and returns true if no
+// other container still holds a mount, in which case the volume is unmounted and detached from the host
+func releaseMount(volume, id string) bool {
+	remaining, err := mountTable.Remove(volume, id)
+	if err != nil {
+		log.Errorf("unable to release mount %s of volume %s, %s", id, volume, err.Error())
+	}
+	if remaining != 0 {
+		log.Infof("volume %s released by %s is still mounted by %d containers", volume, id, remaining)
+		return false
+	}
+	return true
+}
+
+// mountSource looks up the volumes mounted on the host
+type mountSource interface {
+	// getSerialNumber returns the serial number of the volume
+	getSerialNumber(volume string) (string, error)
+	// isMountedOnHost returns true if the volume of the mount reference is mounted on its mount point
+	isMountedOnHost(ref *plugin.MountRef) bool
+}
+
+// ReconcileMounts drops the volumes from the mount table which are no longer mounted on the host, and adopts the
+// volumes mounted under the plugin mount directory which are missing from it
+func ReconcileMounts() error {
+	log.Trace(">>>>> ReconcileMounts")
+	defer log.Trace("<<<<< ReconcileMounts")
+
+	chapiClient, err := chapi.NewChapiClient()
+	if err != nil {
+		return err
+	}
+	providerClient, err := provider.GetProviderClient()
+	if err != nil {
+		return err
+	}
+	user, err := provider.GetProviderAccessKeys()
+	if err != nil {
+		return err
+	}
+	source := &hostMountSource{chapiClient: chapiClient, providerClient: providerClient, user: user}
+	hostMounts := getHostMounts(source, plugin.MountDir, mountTable.List())
+	dropped, err := mountTable.Reconcile(hostMounts)
+	if err != nil {
+		return err
+	}
+	log.Infof("reconciled %d volume mounts, dropped %v", len(hostMounts), dropped)
+	return nil
+}
+
+// getHostMounts returns the mounts of the known references which are still present on the host, along with the
+// volumes mounted on mountDir followed by their name, as done on mount, that are not known yet
+func getHostMounts(source mountSource, mountDir string, known []*plugin.MountRef) map[string]*plugin.MountRef {
+	hostMounts := make(map[string]*plugin.MountRef)
+	for _, ref := range known {
+		if source.isMountedOnHost(ref) {
+			hostMounts[ref.Volume] = ref
+		}
+	}
+
+	entries, err := ioutil.ReadDir(mountDir)
+	if err != nil {
+		log.Infof("unable to read the plugin mount directory %s, %s", mountDir, err.Error())
+		return hostMounts
+	}
+	for _, entry := range entries {
+		volume := entry.Name()
+		if !entry.IsDir() || hostMounts[volume] != nil {
+			continue
+		}
+		serialNumber, err := source.getSerialNumber(volume)
+		if err != nil {
+			log.Debugf("ignoring %s in the plugin mount directory, %s", volume, err.Error())
+			continue
+		}
+		ref := &plugin.MountRef{Volume: volume, SerialNumber: serialNumber, MountPoint: mountDir + volume}
+		if source.isMountedOnHost(ref) {
+			hostMounts[volume] = ref
+		}
+	}
+	return hostMounts
+}
+
+// hostMountSource looks up the volumes with the container provider and their mounts with chapi
+type hostMountSource struct {
+	chapiClient    *chapi.Client
+	providerClient *connectivity.Client
+	user           *provider.User
+}
+
+func (source *hostMountSource) getSerialNumber(volume string) (string, error) {
+	vol, err := getVolumeInfo(source.providerClient, &PluginRequest{Name: volume, User: source.user})
+	if err != nil {
+		return "", err
+	}
+	return vol.SerialNumber, nil
+}
+
+func (source *hostMountSource) isMountedOnHost(ref *plugin.MountRef) bool {
+	return isMountedOnHost(source.chapiClient, ref)
+}
+
+// isMountedOnHost returns true if the volume of the mount reference is mounted on its mount point
+func isMountedOnHost(chapiClient *chapi.Client, ref *plugin.MountRef) bool {
+	var respMount []*model.Mount
+	if err := chapiClient.GetMounts(&respMount, ref.SerialNumber); err != nil {
+		log.Tracef("unable to get mounts of volume %s, %s", ref.Volume, err.Error())
+		return false
+	}
+	for _, mount := range respMount {
+		if mount.Mountpoint == ref.MountPoint {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package handler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/dockerplugin/plugin"
+)
+
+// fakeMountSource reports the volumes with a serial number and the mount points mounted on the host
+type fakeMountSource struct {
+	serialNumbers map[string]string
+	mounted       map[string]bool
+}
+
+func (source *fakeMountSource) getSerialNumber(volume string) (string, error) {
+	serialNumber, ok := source.serialNumbers[volume]
+	if !ok {
+		return "", errors.New("volume " + volume + " not found")
+	}
+	return serialNumber, nil
+}
+
+func (source *fakeMountSource) isMountedOnHost(ref *plugin.MountRef) bool {
+	return source.mounted[ref.MountPoint]
+}
+
+func TestReleaseMountTwoContainers(t *testing.T) {
+	table, err := plugin.NewMountTable("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := mountTable
+	mountTable = table
+	defer func() { mountTable = previous }()
+
+	mountTable.Add("vol1", "c1", "serial1", "/mnt/vol1")
+	mountTable.Add("vol1", "c2", "serial1", "/mnt/vol1")
+
+	// the first container leaves the volume mounted for the second one
+	if releaseMount("vol1", "c1") {
+		t.Fatal("expected vol1 to stay mounted while c2 holds it")
+	}
+	if !mountTable.HasRef("vol1", "c2") {
+		t.Fatal("expected c2 to still hold vol1")
+	}
+	// the last container unmounts and detaches the volume
+	if !releaseMount("vol1", "c2") {
+		t.Fatal("expected vol1 to be unmounted after c2 released it")
+	}
+	if mountTable.Get("vol1") != nil {
+		t.Error("expected vol1 to be dropped from the mount table")
+	}
+	// a volume the table doesn't know about is unmounted
+	if !releaseMount("vol2", "c1") {
+		t.Error("expected an untracked volume to be unmounted")
+	}
+}
+
+func TestGetHostMountsAddsMissingMounts(t *testing.T) {
+	mountDir := t.TempDir() + "/"
+	for _, volume := range []string{"vol1", "vol2", "vol3", "unknown"} {
+		if err := os.Mkdir(filepath.Join(mountDir, volume), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source := &fakeMountSource{
+		serialNumbers: map[string]string{"vol1": "serial1", "vol2": "serial2", "vol3": "serial3"},
+		mounted:       map[string]bool{mountDir + "vol1": true, mountDir + "vol2": true, mountDir + "unknown": true},
+	}
+	known := []*plugin.MountRef{
+		{Volume: "vol1", SerialNumber: "serial1", MountPoint: mountDir + "vol1", IDs: []string{"c1"}},
+		{Volume: "gone", SerialNumber: "serial4", MountPoint: mountDir + "gone", IDs: []string{"c2"}},
+	}
+	hostMounts := getHostMounts(source, mountDir, known)
+
+	// vol1 is kept with its references, vol2 is mounted but missing from the table, vol3 is not mounted and the
+	// unknown volume has no serial number
+	if len(hostMounts) != 2 || hostMounts["vol1"] != known[0] {
+		t.Fatalf("expected vol1 and vol2 to be mounted, got %v", hostMounts)
+	}
+	if ref := hostMounts["vol2"]; ref == nil || ref.SerialNumber != "serial2" || ref.MountPoint != mountDir+"vol2" {
+		t.Errorf("expected vol2 to be added from the host mounts, got %+v", ref)
+	}
+
+	table, err := plugin.NewMountTable("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Add("gone", "c2", "serial4", mountDir+"gone")
+	dropped, err := table.Reconcile(hostMounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dropped) != 1 || dropped[0] != "gone" || table.Get("vol2") == nil {
+		t.Errorf("expected gone to be dropped and vol2 adopted, got dropped %v and %v", dropped, table.List())
+	}
+}
@@ -5,7 +5,10 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hpe-storage/common-host-libs/chapi"
@@ -13,6 +16,7 @@ import (
 	"github.com/hpe-storage/common-host-libs/dockerplugin/plugin"
 	"github.com/hpe-storage/common-host-libs/dockerplugin/provider"
 	log "github.com/hpe-storage/common-host-libs/logger"
+	"github.com/hpe-storage/common-host-libs/model"
 )
 
 //@APIVersion 1.0.0
@@ -48,6 +52,24 @@ func VolumeDriverRemove(w http.ResponseWriter, r *http.Request) {
 		pluginReq.User = user
 	}
 
+	// release hands the volume back to the array instead of forgetting or destroying it
+	removeOpts, err := plugin.ParseRemoveOptions(pluginReq.Opts)
+	if err != nil {
+		dr = &DriverResponse{Err: err.Error()}
+		json.NewEncoder(w).Encode(dr)
+		return
+	}
+	if removeOpts.Release {
+		mapMutex.Lock(pluginReq.Name)
+		log.Debugf("taken lock for volume %s in release", pluginReq.Name)
+		defer mapMutex.Unlock(pluginReq.Name)
+		if ref := mountTable.Get(pluginReq.Name); ref != nil && len(ref.IDs) != 0 {
+			dr = &DriverResponse{Err: fmt.Sprintf("volume %s is mounted by %d containers on this host", pluginReq.Name, len(ref.IDs))}
+			json.NewEncoder(w).Encode(dr)
+			return
+		}
+	}
+
 	//get containerProviderClient
 	providerClient, err := provider.GetProviderClient()
 	if err != nil {
@@ -90,6 +112,22 @@ func VolumeDriverRemove(w http.ResponseWriter, r *http.Request) {
 		log.Infof("%s not present for %s,ignoring processDeleteConflictDelay", plugin.DeleteConflictDelayKey, volume.Name)
 	}
 
+	// a volume still attached to another host is only released when takeover is requested
+	var otherHost *Host
+	if removeOpts.Release {
+		if isDestroyOnRm(volume) {
+			dr = &DriverResponse{Err: fmt.Sprintf("volume %s is destroyed on remove and cannot be released", volume.Name)}
+			json.NewEncoder(w).Encode(dr)
+			return
+		}
+		otherHost, err = checkReleaseConflict(providerClient, pluginReq, removeOpts)
+		if err != nil {
+			dr = &DriverResponse{Err: err.Error()}
+			json.NewEncoder(w).Encode(dr)
+			return
+		}
+	}
+
 	// obtain chapi client
 	chapiClient, err := chapi.NewChapiClient()
 	if err != nil {
@@ -123,7 +161,30 @@ func VolumeDriverRemove(w http.ResponseWriter, r *http.Request) {
 		chapiClient.DeleteDevice(device)
 	}
 
-	// 6. container-provider /VolumeDriver.Remove called
+	// 6. on release, remove the access of the host taken over from, rename the volume and clear the plugin metadata,
+	// the volume is never removed
+	if removeOpts.Release {
+		if otherHost != nil {
+			err = detachOtherHost(providerClient, volume, otherHost, pluginReq)
+			if err != nil {
+				dr = &DriverResponse{Err: err.Error()}
+				json.NewEncoder(w).Encode(dr)
+				return
+			}
+		}
+		err = releaseVolume(providerClient, pluginReq, removeOpts)
+		if err != nil {
+			dr = &DriverResponse{Err: err.Error()}
+			json.NewEncoder(w).Encode(dr)
+			return
+		}
+		mountTable.Delete(pluginReq.Name)
+		log.Infof("%s: released volume %s", provider.RemoveURI, pluginReq.Name)
+		json.NewEncoder(w).Encode(dr)
+		return
+	}
+
+	// 7. container-provider /VolumeDriver.Remove called
 	_, err = providerClient.DoJSON(&connectivity.Request{Action: "POST", Path: provider.RemoveURI, Payload: &pluginReq, Response: &dr, ResponseError: nil})
 
 	if err != nil {
@@ -131,11 +192,124 @@ func VolumeDriverRemove(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(dr)
 		return
 	}
+	if dr.Err == "" {
+		mountTable.Delete(pluginReq.Name)
+	}
 	log.Infof("%s: request=(%+v) response=(%+v)", provider.RemoveURI, pluginReq, dr)
 	json.NewEncoder(w).Encode(dr)
 	return
 }
 
+// checkReleaseConflict returns an error if the volume is attached to another host and takeover was not requested.
+// On takeover it returns the initiators of the other host, whose access must be removed before the release.
+func checkReleaseConflict(containerProviderClient *connectivity.Client, pluginReq *PluginRequest, removeOpts *plugin.RemoveOptions) (*Host, error) {
+	log.Tracef(">>>>> checkReleaseConflict called for %s", pluginReq.Name)
+	defer log.Trace("<<<<< checkReleaseConflict")
+
+	volume, err := nimbleGetVolumeInfo(containerProviderClient, pluginReq)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get volume information for %s, %s", pluginReq.Name, err.Error())
+	}
+	if !volume.InUse {
+		return nil, nil
+	}
+	if isCurrentHostAttachedIscsi(volume, pluginReq) || isCurrentHostAttachedFC(volume, pluginReq) {
+		log.Debugf("volume %s is attached to this host, it will be detached before release", volume.Name)
+		return nil, nil
+	}
+	if !removeOpts.Takeover {
+		return nil, fmt.Errorf("volume %s is in use by another host, release it with %s=true once that host is down", volume.Name, plugin.TakeoverOpt)
+	}
+	log.Infof("taking over volume %s still in use by another host (iscsi sessions %d, fc sessions %d)", volume.Name, len(volume.IscsiSessions), len(volume.FcSessions))
+	return getOtherHost(volume), nil
+}
+
+// getOtherHost returns a host with the initiators of the iscsi and fc sessions of the volume
+func getOtherHost(volume *model.Volume) *Host {
+	host := &Host{}
+	var iscsiInits, fcInits []string
+	for _, iscsiSession := range volume.IscsiSessions {
+		if name := strings.TrimSpace(iscsiSession.InitiatorNameStr()); name != "" {
+			iscsiInits = append(iscsiInits, name)
+		}
+	}
+	for _, fcSession := range volume.FcSessions {
+		if wwpn := strings.TrimSpace(strings.Replace(fcSession.InitiatorWwpnStr(), ":", "", -1)); wwpn != "" {
+			fcInits = append(fcInits, wwpn)
+		}
+	}
+	if len(iscsiInits) != 0 {
+		host.Initiators = append(host.Initiators, &model.Initiator{Type: "iscsi", Init: iscsiInits})
+	}
+	if len(fcInits) != 0 {
+		host.Initiators = append(host.Initiators, &model.Initiator{Type: "fc", Init: fcInits})
+	}
+	return host
+}
+
+// detachOtherHost calls the container provider /Nimble.Detach for the host taken over from, so its access to the
+// volume is removed on the array
+func detachOtherHost(containerProviderClient *connectivity.Client, volume *model.Volume, otherHost *Host, pluginReq *PluginRequest) error {
+	log.Tracef(">>>>> detachOtherHost called for %s", volume.Name)
+	defer log.Trace("<<<<< detachOtherHost")
+
+	if len(otherHost.Initiators) == 0 {
+		log.Infof("no initiators found for the host using volume %s, nothing to detach", volume.Name)
+		return nil
+	}
+	detachReq := &NimbleDetachRequest{Volume: volume, Host: otherHost, User: pluginReq.User}
+	dr := &DriverResponse{}
+	_, err := containerProviderClient.DoJSON(&connectivity.Request{Action: "POST", Path: provider.NimbleDetachURI, Payload: detachReq, Response: &dr, ResponseError: &dr})
+	if dr.Err != "" {
+		return fmt.Errorf("unable to remove the access of the other host to volume %s, %s", volume.Name, dr.Err)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to remove the access of the other host to volume %s, %s", volume.Name, err.Error())
+	}
+	return nil
+}
+
+// isDestroyOnRm returns true if the volume was created with destroyOnRm
+func isDestroyOnRm(volume *model.Volume) bool {
+	for _, values := range []map[string]interface{}{volume.Config, volume.Status} {
+		switch v := values[plugin.DestroyOnRmOpt].(type) {
+		case bool:
+			return v
+		case string:
+			destroyOnRm, _ := strconv.ParseBool(v)
+			return destroyOnRm
+		}
+	}
+	return false
+}
+
+// releaseVolume hands the volume back to the array.  A single update renames the volume, when a release name is
+// given, and clears the plugin ownership and mount conflict metadata.  The container provider remove is never
+// called, so the array volume and its data are kept.
+func releaseVolume(containerProviderClient *connectivity.Client, pluginReq *PluginRequest, removeOpts *plugin.RemoveOptions) error {
+	log.Tracef(">>>>> releaseVolume called for %s", pluginReq.Name)
+	defer log.Trace("<<<<< releaseVolume")
+
+	// the update replaces the request options, keep the original request intact
+	updateReq := *pluginReq
+	updateReq.Opts = map[string]interface{}{
+		plugin.ManagerOpt:    "",
+		"mountConflictDelay": "0",
+	}
+	if removeOpts.ReleaseName != "" {
+		updateReq.Opts[plugin.NameOpt] = removeOpts.ReleaseName
+	}
+	cr := &CreateResponse{}
+	_, err := containerProviderClient.DoJSON(&connectivity.Request{Action: "POST", Path: provider.UpdateURI, Payload: &updateReq, Response: &cr, ResponseError: &cr})
+	if cr.Err != "" {
+		return fmt.Errorf("unable to release volume %s, %s", pluginReq.Name, cr.Err)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to release volume %s, %s", pluginReq.Name, err.Error())
+	}
+	return nil
+}
+
 /* processDeleteConflictDelay
    The method checks the volume status to check if it is currently inUse.
    If the volume is inUse, we poll every tick ( 5 secs) to recheck if the volume is not inUse.
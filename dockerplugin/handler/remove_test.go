@@ -0,0 +1,160 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hpe-storage/common-host-libs/connectivity"
+	"github.com/hpe-storage/common-host-libs/dockerplugin/plugin"
+	"github.com/hpe-storage/common-host-libs/dockerplugin/provider"
+	"github.com/hpe-storage/common-host-libs/model"
+)
+
+// fakeProvider is a container provider keeping its volumes in memory
+type fakeProvider struct {
+	sync.Mutex
+	volumes  map[string]*model.Volume
+	removed  []string
+	detached []*Host
+}
+
+func (p *fakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == provider.NimbleDetachURI {
+		var detachReq NimbleDetachRequest
+		if err := json.NewDecoder(r.Body).Decode(&detachReq); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.Lock()
+		p.detached = append(p.detached, detachReq.Host)
+		p.Unlock()
+		json.NewEncoder(w).Encode(&DriverResponse{})
+		return
+	}
+	var pluginReq PluginRequest
+	if err := json.NewDecoder(r.Body).Decode(&pluginReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	volume := p.volumes[pluginReq.Name]
+	if volume == nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: "volume " + pluginReq.Name + " not found"})
+		return
+	}
+	switch r.URL.Path {
+	case provider.UpdateURI:
+		for key, value := range pluginReq.Opts {
+			volume.Config[key] = value
+		}
+		if name, ok := pluginReq.Opts[plugin.NameOpt].(string); ok {
+			delete(p.volumes, volume.Name)
+			volume.Name = name
+			p.volumes[name] = volume
+		}
+		json.NewEncoder(w).Encode(&CreateResponse{Volumes: []*model.Volume{volume}})
+	case provider.RemoveURI:
+		if volume.Config[plugin.DestroyOnRmOpt] == true {
+			delete(p.volumes, volume.Name)
+		}
+		p.removed = append(p.removed, volume.Name)
+		json.NewEncoder(w).Encode(&DriverResponse{})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestReleaseVolume(t *testing.T) {
+	fake := &fakeProvider{volumes: map[string]*model.Volume{
+		"vol1": {Name: "vol1", Config: map[string]interface{}{plugin.ManagerOpt: "docker", "mountConflictDelay": "30"}},
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := connectivity.NewHTTPClient(server.URL)
+
+	pluginReq := &PluginRequest{Name: "vol1", Opts: map[string]interface{}{plugin.ReleaseOpt: "true", plugin.ReleaseNameOpt: "array-vol1"}}
+	removeOpts, err := plugin.ParseRemoveOptions(pluginReq.Opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = releaseVolume(client, pluginReq, removeOpts); err != nil {
+		t.Fatal(err)
+	}
+
+	// the volume survives under its release name without any plugin metadata
+	volume := fake.volumes["array-vol1"]
+	if volume == nil || fake.volumes["vol1"] != nil {
+		t.Fatalf("expected vol1 to be renamed to array-vol1, got %v", fake.volumes)
+	}
+	if volume.Config[plugin.ManagerOpt] != "" || volume.Config["mountConflictDelay"] != "0" {
+		t.Errorf("expected the plugin metadata to be cleared, got %v", volume.Config)
+	}
+	if len(fake.removed) != 0 {
+		t.Errorf("expected no remove on release, got %v", fake.removed)
+	}
+	if pluginReq.Opts[plugin.ReleaseOpt] != "true" {
+		t.Errorf("expected the remove request to be left intact, got %v", pluginReq.Opts)
+	}
+
+	// a missing volume fails the release
+	if err = releaseVolume(client, pluginReq, removeOpts); err == nil {
+		t.Error("expected the release of a missing volume to fail")
+	}
+}
+
+func TestDetachOtherHost(t *testing.T) {
+	fake := &fakeProvider{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := connectivity.NewHTTPClient(server.URL)
+
+	volume := &model.Volume{
+		Name:          "vol1",
+		InUse:         true,
+		IscsiSessions: []*model.IscsiSession{{InitiatorName: "iqn.1994-05.com.redhat:dead"}},
+		FcSessions:    []*model.FcSession{{InitiatorWwpn: "10:00:00:00:c9:00:00:01"}},
+	}
+	otherHost := getOtherHost(volume)
+	if err := detachOtherHost(client, volume, otherHost, &PluginRequest{Name: "vol1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.detached) != 1 {
+		t.Fatalf("expected one detach, got %d", len(fake.detached))
+	}
+	initiators := fake.detached[0].Initiators
+	if len(initiators) != 2 || initiators[0].Init[0] != "iqn.1994-05.com.redhat:dead" || initiators[1].Init[0] != "10000000c9000001" {
+		t.Errorf("expected the initiators of the dead host to be detached, got %+v", fake.detached[0])
+	}
+
+	// a volume without sessions has nothing to detach
+	volume = &model.Volume{Name: "vol2", InUse: true}
+	if err := detachOtherHost(client, volume, getOtherHost(volume), &PluginRequest{Name: "vol2"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.detached) != 1 {
+		t.Errorf("expected no detach without initiators, got %d", len(fake.detached))
+	}
+}
+
+func TestIsDestroyOnRm(t *testing.T) {
+	testCases := []struct {
+		volume   *model.Volume
+		expected bool
+	}{
+		{&model.Volume{}, false},
+		{&model.Volume{Config: map[string]interface{}{plugin.DestroyOnRmOpt: true}}, true},
+		{&model.Volume{Config: map[string]interface{}{plugin.DestroyOnRmOpt: "false"}}, false},
+		{&model.Volume{Status: map[string]interface{}{plugin.DestroyOnRmOpt: "true"}}, true},
+	}
+	for _, tc := range testCases {
+		if destroyOnRm := isDestroyOnRm(tc.volume); destroyOnRm != tc.expected {
+			t.Errorf("expected %v for %+v, got %v", tc.expected, tc.volume, destroyOnRm)
+		}
+	}
+}
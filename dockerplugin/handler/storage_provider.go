@@ -0,0 +1,721 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hpe-storage/common-host-libs/chapi2/cerrors"
+	chapiDriver "github.com/hpe-storage/common-host-libs/chapi2/driver"
+	chapiModel "github.com/hpe-storage/common-host-libs/chapi2/model"
+	"github.com/hpe-storage/common-host-libs/dockerplugin/plugin"
+	log "github.com/hpe-storage/common-host-libs/logger"
+	"github.com/hpe-storage/common-host-libs/model"
+	"github.com/hpe-storage/common-host-libs/storageprovider"
+)
+
+const (
+	bytesPerGiB = int64(1024 * 1024 * 1024)
+	// default volume size when neither the request nor the config file specify one
+	defaultSizeInGiB = 10
+)
+
+var (
+	// options consumed by the plugin which are not forwarded to the storage provider volume config
+	listOfProviderKeysToRemove = []string{plugin.HelpOpt, plugin.SizeOpt, plugin.SizeInGiBOpt, "description",
+		plugin.CloneOfOpt, plugin.SnapshotOpt, plugin.CreateSnapshotOpt}
+)
+
+// StorageProviderHandler implements the docker volume plugin endpoints using a StorageProvider for
+// array operations and a chapi2 driver for host operations, instead of proxying to a container provider.
+// Host side options (filesystem, fsMode, fsOwner and delayedCreate) are kept in the volume config so
+// that any host can format and mount the volume.  The docker mount IDs holding each mount are tracked
+// in a MountTable, the volume is detached once the last of them unmounts it.  Remove only deletes the array volume
+// when it was created with destroyOnRm, otherwise the volume is detached and unpublished from this host.
+type StorageProviderHandler struct {
+	provider storageprovider.StorageProvider
+	chapi    chapiDriver.Driver
+	mountDir string
+	mounts   *plugin.MountTable
+	hostLock sync.Mutex
+	host     *chapiModel.Host // set once the node context is registered with the provider
+}
+
+// NewStorageProviderHandler returns a handler serving the docker volume plugin endpoints with the given
+// storage provider and chapi2 driver, volumes are mounted under mountDir and their references are
+// recorded in mounts
+func NewStorageProviderHandler(provider storageprovider.StorageProvider, chapi chapiDriver.Driver, mountDir string, mounts *plugin.MountTable) *StorageProviderHandler {
+	return &StorageProviderHandler{provider: provider, chapi: chapi, mountDir: mountDir, mounts: mounts}
+}
+
+// ReconcileMounts rebuilds the mount table from the volumes mounted under the plugin mount directory,
+// it is expected to be called on startup before serving requests
+func (h *StorageProviderHandler) ReconcileMounts() error {
+	log.Trace(">>>>> ReconcileMounts")
+	defer log.Trace("<<<<< ReconcileMounts")
+
+	mounts, err := h.getMounts(context.Background(), "")
+	if err != nil {
+		return fmt.Errorf("unable to get mounts of this host, %s", err.Error())
+	}
+	hostMounts := make(map[string]*plugin.MountRef)
+	for _, mount := range mounts {
+		if !strings.HasPrefix(mount.MountPoint, h.mountDir) {
+			continue
+		}
+		volume := strings.TrimPrefix(mount.MountPoint, h.mountDir)
+		if volume == "" || strings.Contains(volume, "/") {
+			continue
+		}
+		hostMounts[volume] = &plugin.MountRef{Volume: volume, SerialNumber: mount.SerialNumber, MountPoint: mount.MountPoint}
+	}
+	dropped, err := h.mounts.Reconcile(hostMounts)
+	if err != nil {
+		return err
+	}
+	log.Infof("reconciled %d volume mounts, dropped %v", len(hostMounts), dropped)
+	return nil
+}
+
+// ActivatePlugin implements the /Plugin.Activate Docker end point
+func (h *StorageProviderHandler) ActivatePlugin(w http.ResponseWriter, r *http.Request) {
+	log.Trace("Plugin.Activate called")
+	json.NewEncoder(w).Encode(&PluginActivate{Activate: []string{"VolumeDriver"}})
+}
+
+// VolumeDriverCapabilities implements the /VolumeDriver.Capabilities Docker end point
+func (h *StorageProviderHandler) VolumeDriverCapabilities(w http.ResponseWriter, r *http.Request) {
+	log.Trace("VolumeDriver.Capabilities called")
+	json.NewEncoder(w).Encode(&PluginCapability{Capability: &Scope{Scope: plugin.GetDriverScope()}})
+}
+
+// VolumeDriverCreate implements the /VolumeDriver.Create Docker end point
+// nolint : gocyclo
+func (h *StorageProviderHandler) VolumeDriverCreate(w http.ResponseWriter, r *http.Request) {
+	log.Debug("VolumeDriver.Create called")
+	pluginReq, err := decodePluginRequest(r)
+	if err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+
+	// generic help is answered from the option schema of this plugin type
+	schema := plugin.GetCreateOptionSchema(plugin.GetPluginType())
+	if plugin.IsHelpRequest(pluginReq.Opts) {
+		json.NewEncoder(w).Encode(&CreateResponse{Err: schema.Help()})
+		return
+	}
+	createOpts, err := validateCreateOptions(schema, pluginReq)
+	if err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+	if createOpts.IsImport() {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: fmt.Sprintf("%s and %s are not supported by the storage provider backend", plugin.ImportVolOpt, plugin.ImportVolAsCloneOpt)})
+		return
+	}
+	if _, _, err = getFileSystemModeAndOwnerFromRequest(pluginReq); err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+
+	// populate default create options from the config file loaded on startup, if any
+	if plugin.VolumeDriverConfig != nil {
+		if err = populateVolCreateOptions(pluginReq); err != nil {
+			log.Debugf("%s, using create options from the request only", err.Error())
+		}
+	}
+	createOpts, err = parseMergedCreateOptions(createOpts, pluginReq.Opts)
+	if err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+	createOpts.Apply(pluginReq.Opts)
+	removeGlobalOptionsFromCreateRequest(pluginReq)
+	if !isValidFilesystem(pluginReq) {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: fmt.Sprintf("invalid filesystem type(%s), please enter one of the following options (%v)", pluginReq.Opts[model.FsCreateOpt], plugin.SupportedFileSystems)})
+		return
+	}
+
+	mapMutex.Lock(pluginReq.Name)
+	log.Debugf("taken lock on %s in create", pluginReq.Name)
+	defer mapMutex.Unlock(pluginReq.Name)
+
+	// docker may call create for a volume that already exists
+	existing, err := h.provider.GetVolumeByName(pluginReq.Name)
+	if err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+	if existing != nil {
+		log.Infof("volume %s already exists", pluginReq.Name)
+		json.NewEncoder(w).Encode(&CreateResponse{Volumes: []*model.Volume{existing}})
+		return
+	}
+
+	volume, err := h.createVolume(pluginReq, createOpts)
+	if err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: fmt.Sprintf("unable to create the volume %s %s", pluginReq.Name, err.Error())})
+		return
+	}
+	log.Infof("%s: request=(%+v) response=(%+v)", "VolumeDriver.Create", pluginReq, volume)
+	json.NewEncoder(w).Encode(&CreateResponse{Volumes: []*model.Volume{volume}})
+}
+
+// VolumeDriverRemove implements the /VolumeDriver.Remove Docker end point
+func (h *StorageProviderHandler) VolumeDriverRemove(w http.ResponseWriter, r *http.Request) {
+	log.Debug("VolumeDriver.Remove called")
+	pluginReq, err := decodePluginRequest(r)
+	if err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+
+	removeOpts, err := plugin.ParseRemoveOptions(pluginReq.Opts)
+	if err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+	if removeOpts.Release {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: fmt.Sprintf("%s is not supported by the storage provider backend", plugin.ReleaseOpt)})
+		return
+	}
+
+	mapMutex.Lock(pluginReq.Name)
+	log.Debugf("taken lock for volume %s in remove", pluginReq.Name)
+	defer mapMutex.Unlock(pluginReq.Name)
+
+	volume, err := h.getVolume(pluginReq.Name)
+	if err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+	mounts, err := h.getMounts(r.Context(), volume.SerialNumber)
+	if err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+	if len(mounts) != 0 {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: fmt.Sprintf("volume %s is mounted on this host", volume.Name)})
+		return
+	}
+	if isDestroyOnRm(volume) {
+		if volume.Published {
+			json.NewEncoder(w).Encode(&DriverResponse{Err: fmt.Sprintf("volume %s is in use by another host", volume.Name)})
+			return
+		}
+		if err = h.provider.DeleteVolume(volume.ID, false); err != nil {
+			json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+			return
+		}
+	} else if err = h.detachVolume(r.Context(), volume); err != nil {
+		// the array volume is kept, it is only detached and unpublished from this host
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+	h.mounts.Delete(volume.Name)
+	os.RemoveAll(h.mountDir + volume.Name)
+	log.Infof("%s: request=(%+v)", "VolumeDriver.Remove", pluginReq)
+	json.NewEncoder(w).Encode(&DriverResponse{})
+}
+
+// VolumeDriverMount implements the /VolumeDriver.Mount Docker end point
+func (h *StorageProviderHandler) VolumeDriverMount(w http.ResponseWriter, r *http.Request) {
+	log.Debug("VolumeDriver.Mount called")
+	pluginReq, err := decodePluginRequest(r)
+	if err != nil {
+		json.NewEncoder(w).Encode(&MountResponse{Err: err.Error()})
+		return
+	}
+
+	mapMutex.Lock(pluginReq.Name)
+	log.Debugf("taken lock for volume %s in mount", pluginReq.Name)
+	defer mapMutex.Unlock(pluginReq.Name)
+
+	volume, err := h.getVolume(pluginReq.Name)
+	if err != nil {
+		json.NewEncoder(w).Encode(&MountResponse{Err: err.Error()})
+		return
+	}
+	mountPoint := h.mountDir + volume.Name
+	if err = h.mountVolume(r.Context(), volume, mountPoint); err != nil {
+		json.NewEncoder(w).Encode(&MountResponse{Err: err.Error()})
+		return
+	}
+	if err = h.mounts.Add(volume.Name, pluginReq.ID, volume.SerialNumber, mountPoint); err != nil {
+		// the volume is mounted, a lost reference only delays the detach until the next reconcile
+		log.Errorf("unable to record mount %s of volume %s, %s", pluginReq.ID, volume.Name, err.Error())
+	}
+	mr := MountResponse{MountPoint: mountPoint}
+	log.Infof("%s: request=(%+v) response=(%+v)", "VolumeDriver.Mount", pluginReq, mr)
+	json.NewEncoder(w).Encode(&mr)
+}
+
+// VolumeDriverUnmount implements the /VolumeDriver.Unmount Docker end point
+func (h *StorageProviderHandler) VolumeDriverUnmount(w http.ResponseWriter, r *http.Request) {
+	log.Debug("VolumeDriver.Unmount called")
+	pluginReq, err := decodePluginRequest(r)
+	if err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+
+	mapMutex.Lock(pluginReq.Name)
+	log.Debugf("taken lock for volume %s in unmount", pluginReq.Name)
+	defer mapMutex.Unlock(pluginReq.Name)
+
+	volume, err := h.getVolume(pluginReq.Name)
+	if err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+	remaining, err := h.mounts.Remove(volume.Name, pluginReq.ID)
+	if err != nil {
+		log.Errorf("unable to release mount %s of volume %s, %s", pluginReq.ID, volume.Name, err.Error())
+	}
+	if remaining != 0 {
+		log.Infof("%s is still mounted by %d containers", volume.Name, remaining)
+		json.NewEncoder(w).Encode(&DriverResponse{})
+		return
+	}
+	if err = h.unmountVolume(r.Context(), volume, h.mountDir+volume.Name); err != nil {
+		json.NewEncoder(w).Encode(&DriverResponse{Err: err.Error()})
+		return
+	}
+	log.Infof("%s: request=(%+v)", "VolumeDriver.Unmount", pluginReq)
+	json.NewEncoder(w).Encode(&DriverResponse{})
+}
+
+// VolumeDriverGet implements the /VolumeDriver.Get Docker end point
+func (h *StorageProviderHandler) VolumeDriverGet(w http.ResponseWriter, r *http.Request) {
+	log.Trace("VolumeDriver.Get called")
+	pluginReq, err := decodePluginRequest(r)
+	if err != nil {
+		json.NewEncoder(w).Encode(&VolumeResponse{Err: err.Error()})
+		return
+	}
+
+	mapMutex.Lock(pluginReq.Name)
+	defer mapMutex.Unlock(pluginReq.Name)
+
+	volume, err := h.getVolume(pluginReq.Name)
+	if err != nil {
+		json.NewEncoder(w).Encode(&VolumeResponse{Err: err.Error()})
+		return
+	}
+	if err = h.setVolumeMountStatus(r.Context(), volume); err != nil {
+		json.NewEncoder(w).Encode(&VolumeResponse{Err: err.Error()})
+		return
+	}
+	log.Debugf("%s: request=(%+v) response=(%+v)", "VolumeDriver.Get", pluginReq, volume)
+	json.NewEncoder(w).Encode(&VolumeResponse{Volume: volume})
+}
+
+// VolumeDriverList implements the /VolumeDriver.List Docker end point
+func (h *StorageProviderHandler) VolumeDriverList(w http.ResponseWriter, r *http.Request) {
+	log.Trace("VolumeDriver.List called")
+	volumes, err := h.provider.GetVolumes()
+	if err != nil {
+		json.NewEncoder(w).Encode(&ListResponse{Err: err.Error()})
+		return
+	}
+	// report the mount point of volumes mounted on this host
+	mounts, err := h.getMounts(r.Context(), "")
+	if err != nil {
+		json.NewEncoder(w).Encode(&ListResponse{Err: err.Error()})
+		return
+	}
+	for _, volume := range volumes {
+		if mount := h.findMount(mounts, volume.SerialNumber, h.mountDir+volume.Name); mount != nil {
+			volume.MountPoint = mount.MountPoint
+		}
+	}
+	log.Tracef("response: %+v", volumes)
+	json.NewEncoder(w).Encode(&ListResponse{Volumes: volumes})
+}
+
+// VolumeDriverPath implements the /VolumeDriver.Path Docker end point
+func (h *StorageProviderHandler) VolumeDriverPath(w http.ResponseWriter, r *http.Request) {
+	log.Trace("VolumeDriver.Path called")
+	pluginReq, err := decodePluginRequest(r)
+	if err != nil {
+		json.NewEncoder(w).Encode(&MountResponse{Err: err.Error()})
+		return
+	}
+	volume, err := h.getVolume(pluginReq.Name)
+	if err != nil {
+		json.NewEncoder(w).Encode(&MountResponse{Err: err.Error()})
+		return
+	}
+	if err = h.setVolumeMountStatus(r.Context(), volume); err != nil {
+		json.NewEncoder(w).Encode(&MountResponse{Err: err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(&MountResponse{MountPoint: volume.MountPoint})
+}
+
+// VolumeDriverUpdate implements the /VolumeDriver.Update end point by editing the volume on the storage provider
+func (h *StorageProviderHandler) VolumeDriverUpdate(w http.ResponseWriter, r *http.Request) {
+	log.Debug("VolumeDriver.Update called")
+	pluginReq, err := decodePluginRequest(r)
+	if err != nil {
+		json.NewEncoder(w).Encode(&CreateResponse{Err: err.Error()})
+		return
+	}
+
+	schema := plugin.GetCreateOptionSchema(plugin.GetPluginType())
+	if err = schema.ValidateUpdate(pluginReq.Opts); err != nil {
+		json.NewEncoder(w).Encode(&CreateResponse{Err: err.Error()})
+		return
+	}
+
+	mapMutex.Lock(pluginReq.Name)
+	defer mapMutex.Unlock(pluginReq.Name)
+
+	volume, err := h.getVolume(pluginReq.Name)
+	if err != nil {
+		json.NewEncoder(w).Encode(&CreateResponse{Err: err.Error()})
+		return
+	}
+	volume, err = h.provider.EditVolume(volume.ID, pluginReq.Opts)
+	if err != nil {
+		json.NewEncoder(w).Encode(&CreateResponse{Err: err.Error()})
+		return
+	}
+	log.Debugf("%s: request=(%+v) response=(%+v)", "VolumeDriver.Update", pluginReq, volume)
+	json.NewEncoder(w).Encode(&CreateResponse{Volumes: []*model.Volume{volume}})
+}
+
+// createVolume creates or clones the volume on the storage provider
+func (h *StorageProviderHandler) createVolume(pluginReq *PluginRequest, createOpts *plugin.CreateOptions) (*model.Volume, error) {
+	log.Tracef(">>>>> createVolume called for %s", pluginReq.Name)
+	defer log.Trace("<<<<< createVolume")
+
+	description := ""
+	if val, ok := pluginReq.Opts["description"]; ok {
+		description = fmt.Sprintf("%v", val)
+	}
+	config := make(map[string]interface{})
+	for key, value := range pluginReq.Opts {
+		config[key] = value
+	}
+	for _, key := range listOfProviderKeysToRemove {
+		delete(config, key)
+	}
+
+	if createOpts.IsClone() {
+		// clones inherit the filesystem of their parent
+		delete(config, plugin.DelayedCreateOpt)
+		delete(config, model.FsCreateOpt)
+		source, err := h.getVolume(createOpts.CloneOf)
+		if err != nil {
+			return nil, err
+		}
+		size := createOpts.SizeInGiB * bytesPerGiB
+		if createOpts.Snapshot == "" {
+			// a new snapshot of the parent is taken for the clone
+			return h.provider.CloneVolume(pluginReq.Name, description, source.ID, "", size, config)
+		}
+		snapshot, err := h.provider.GetSnapshotByName(createOpts.Snapshot, source.ID)
+		if err != nil {
+			return nil, err
+		}
+		if snapshot == nil {
+			return nil, fmt.Errorf("snapshot %s of volume %s not found", createOpts.Snapshot, source.Name)
+		}
+		return h.provider.CloneVolume(pluginReq.Name, description, "", snapshot.ID, size, config)
+	}
+
+	// the filesystem is created on first mount
+	config[model.FsCreateOpt] = getFileSystemTypeFromRequest(pluginReq)
+	config[plugin.DelayedCreateOpt] = true
+	sizeInGiB := createOpts.SizeInGiB
+	if sizeInGiB == 0 {
+		sizeInGiB = defaultSizeInGiB
+	}
+	return h.provider.CreateVolume(pluginReq.Name, description, sizeInGiB*bytesPerGiB, config)
+}
+
+// mountVolume publishes, attaches, formats on first use and mounts the volume on this host
+// nolint : gocyclo
+func (h *StorageProviderHandler) mountVolume(ctx context.Context, volume *model.Volume, mountPoint string) (err error) {
+	log.FromContext(ctx).Tracef(">>>>> mountVolume called for %s on %s", volume.Name, mountPoint)
+	defer log.FromContext(ctx).Trace("<<<<< mountVolume")
+
+	host, err := h.getHost(ctx)
+	if err != nil {
+		return err
+	}
+	mounts, err := h.getMounts(ctx, volume.SerialNumber)
+	if err != nil {
+		return err
+	}
+	if h.findMount(mounts, volume.SerialNumber, mountPoint) != nil {
+		log.FromContext(ctx).Debugf("volume %s is already mounted on %s", volume.Name, mountPoint)
+		return nil
+	}
+
+	publishInfo, err := h.provider.PublishVolume(volume.ID, host.UUID, getHostProtocol())
+	if err != nil {
+		return fmt.Errorf("unable to publish volume %s to host %s, %s", volume.Name, host.Name, err.Error())
+	}
+	chapiPublishInfo := getChapiPublishInfo(publishInfo)
+	serialNumber := chapiPublishInfo.SerialNumber
+	defer func() {
+		// undo the attach and publish on failure, best effort
+		if err != nil {
+			if cleanupErr := h.chapi.DeleteDevice(ctx, serialNumber); cleanupErr != nil {
+				log.FromContext(ctx).Errorf("unable to delete device %s after mount failure, %s", serialNumber, cleanupErr.Error())
+			}
+			if cleanupErr := h.provider.UnpublishVolume(volume.ID, host.UUID); cleanupErr != nil {
+				log.FromContext(ctx).Errorf("unable to unpublish volume %s after mount failure, %s", volume.Name, cleanupErr.Error())
+			}
+		}
+	}()
+
+	if _, err = h.chapi.CreateDevice(ctx, *chapiPublishInfo); err != nil {
+		return fmt.Errorf("unable to attach device for volume %s, %s", volume.Name, err.Error())
+	}
+
+	fsOptions := getFileSystemOptionsFromConfig(volume.Config)
+	if isDelayedCreate(volume.Config) {
+		log.FromContext(ctx).Debugf("creating %s filesystem on volume %s", fsOptions.FsType, volume.Name)
+		if err = h.chapi.CreateFileSystem(ctx, serialNumber, fsOptions.FsType); err != nil {
+			return fmt.Errorf("unable to create %s filesystem on volume %s, %s", fsOptions.FsType, volume.Name, err.Error())
+		}
+		// the filesystem now exists, make sure no other host formats it again
+		if _, err = h.provider.EditVolume(volume.ID, map[string]interface{}{plugin.DelayedCreateOpt: false}); err != nil {
+			return fmt.Errorf("unable to remove %s from volume %s, %s", plugin.DelayedCreateOpt, volume.Name, err.Error())
+		}
+	}
+
+	if _, err = h.chapi.CreateMount(ctx, serialNumber, mountPoint, fsOptions); err != nil {
+		return fmt.Errorf("unable to mount volume %s on %s, %s", volume.Name, mountPoint, err.Error())
+	}
+	return nil
+}
+
+// unmountVolume unmounts the volume, detaches the device and unpublishes it from this host once it is no longer mounted
+func (h *StorageProviderHandler) unmountVolume(ctx context.Context, volume *model.Volume, mountPoint string) error {
+	log.FromContext(ctx).Tracef(">>>>> unmountVolume called for %s on %s", volume.Name, mountPoint)
+	defer log.FromContext(ctx).Trace("<<<<< unmountVolume")
+
+	host, err := h.getHost(ctx)
+	if err != nil {
+		return err
+	}
+	mounts, err := h.getMounts(ctx, volume.SerialNumber)
+	if err != nil {
+		return err
+	}
+	remaining := 0
+	for _, mount := range mounts {
+		if mount.MountPoint != mountPoint {
+			remaining++
+			continue
+		}
+		if err = h.chapi.DeleteMount(ctx, volume.SerialNumber, mount.ID); err != nil {
+			return fmt.Errorf("unable to unmount %s, %s", mountPoint, err.Error())
+		}
+	}
+	if remaining != 0 {
+		log.FromContext(ctx).Infof("%s is mounted on %d other mount points, skipping detach", volume.Name, remaining)
+		return nil
+	}
+	if err = h.chapi.DeleteDevice(ctx, volume.SerialNumber); err != nil {
+		return fmt.Errorf("unable to detach volume %s from host, %s", volume.Name, err.Error())
+	}
+	if err = h.provider.UnpublishVolume(volume.ID, host.UUID); err != nil {
+		return fmt.Errorf("unable to unpublish volume %s from host %s, %s", volume.Name, host.Name, err.Error())
+	}
+	os.Remove(mountPoint)
+	return nil
+}
+
+// detachVolume detaches the device of the volume and unpublishes the volume from this host, the volume itself is kept
+func (h *StorageProviderHandler) detachVolume(ctx context.Context, volume *model.Volume) error {
+	log.FromContext(ctx).Tracef(">>>>> detachVolume called for %s", volume.Name)
+	defer log.FromContext(ctx).Trace("<<<<< detachVolume")
+
+	host, err := h.getHost(ctx)
+	if err != nil {
+		return err
+	}
+	if err = h.chapi.DeleteDevice(ctx, volume.SerialNumber); err != nil {
+		if chapiErr, ok := err.(*cerrors.ChapiError); !ok || chapiErr.ErrorCode() != cerrors.NotFound {
+			return fmt.Errorf("unable to detach volume %s from host, %s", volume.Name, err.Error())
+		}
+	}
+	if err = h.provider.UnpublishVolume(volume.ID, host.UUID); err != nil {
+		return fmt.Errorf("unable to unpublish volume %s from host %s, %s", volume.Name, host.Name, err.Error())
+	}
+	return nil
+}
+
+// getVolume returns the volume with the given name or an error if it doesn't exist
+func (h *StorageProviderHandler) getVolume(name string) (*model.Volume, error) {
+	volume, err := h.provider.GetVolumeByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if volume == nil {
+		return nil, fmt.Errorf("volume %s not found", name)
+	}
+	return volume, nil
+}
+
+// getMounts returns the mounts of the given serial number on this host, treating not found as no mounts
+func (h *StorageProviderHandler) getMounts(ctx context.Context, serialNumber string) ([]*chapiModel.Mount, error) {
+	mounts, err := h.chapi.GetMounts(ctx, serialNumber)
+	if err != nil {
+		if chapiErr, ok := err.(*cerrors.ChapiError); ok && chapiErr.ErrorCode() == cerrors.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return mounts, nil
+}
+
+// findMount returns the mount of the serial number on the given mount point
+func (h *StorageProviderHandler) findMount(mounts []*chapiModel.Mount, serialNumber, mountPoint string) *chapiModel.Mount {
+	for _, mount := range mounts {
+		if mount.MountPoint == mountPoint && (serialNumber == "" || mount.SerialNumber == serialNumber) {
+			return mount
+		}
+	}
+	return nil
+}
+
+// setVolumeMountStatus populates the mount point of the volume if it is mounted on this host
+func (h *StorageProviderHandler) setVolumeMountStatus(ctx context.Context, volume *model.Volume) error {
+	mounts, err := h.getMounts(ctx, volume.SerialNumber)
+	if err != nil {
+		return err
+	}
+	if volume.Status == nil {
+		volume.Status = make(map[string]interface{})
+	}
+	volume.Status["serialNumber"] = volume.SerialNumber
+	if mount := h.findMount(mounts, volume.SerialNumber, h.mountDir+volume.Name); mount != nil {
+		volume.MountPoint = mount.MountPoint
+	}
+	return nil
+}
+
+// getHost returns this host and registers its initiators and networks with the storage provider on first use
+func (h *StorageProviderHandler) getHost(ctx context.Context) (*chapiModel.Host, error) {
+	h.hostLock.Lock()
+	defer h.hostLock.Unlock()
+	if h.host != nil {
+		return h.host, nil
+	}
+
+	host, err := h.chapi.GetHostInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get host information, %s", err.Error())
+	}
+	initiators, err := h.chapi.GetHostInitiators(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get host initiators, %s", err.Error())
+	}
+	networks, err := h.chapi.GetHostNetworks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get host networks, %s", err.Error())
+	}
+
+	node := &model.Node{ID: host.UUID, UUID: host.UUID, Name: host.Name}
+	for _, initiator := range initiators {
+		for i := range initiator.Init {
+			switch initiator.AccessProtocol {
+			case chapiModel.AccessProtocolIscsi:
+				node.Iqns = append(node.Iqns, &initiator.Init[i])
+			case chapiModel.AccessProtocolFC:
+				node.Wwpns = append(node.Wwpns, &initiator.Init[i])
+			}
+		}
+	}
+	for _, network := range networks {
+		if network.AddressV4 != "" {
+			node.Networks = append(node.Networks, &network.AddressV4)
+		}
+	}
+	if err = h.provider.SetNodeContext(node); err != nil {
+		return nil, fmt.Errorf("unable to set node context for host %s, %s", host.Name, err.Error())
+	}
+	h.host = host
+	return h.host, nil
+}
+
+// decodePluginRequest reads the docker plugin request from the body
+func decodePluginRequest(r *http.Request) (*PluginRequest, error) {
+	pluginReq := &PluginRequest{}
+	if err := json.NewDecoder(r.Body).Decode(pluginReq); err != nil {
+		return nil, err
+	}
+	if pluginReq.Opts == nil {
+		pluginReq.Opts = make(map[string]interface{})
+	}
+	return pluginReq, nil
+}
+
+// getChapiPublishInfo converts the storage provider publish info to the chapi2 publish info
+func getChapiPublishInfo(publishInfo *model.PublishInfo) *chapiModel.PublishInfo {
+	blockDev := &chapiModel.BlockDeviceAccessInfo{
+		AccessProtocol: publishInfo.AccessProtocol,
+		LunID:          strconv.Itoa(int(publishInfo.LunID)),
+	}
+	if len(publishInfo.TargetNames) != 0 {
+		blockDev.TargetName = publishInfo.TargetNames[0]
+	}
+	if publishInfo.AccessProtocol == chapiModel.AccessProtocolIscsi {
+		blockDev.IscsiAccessInfo = &chapiModel.IscsiAccessInfo{
+			ChapUser:     publishInfo.ChapUser,
+			ChapPassword: publishInfo.ChapPassword,
+		}
+		if len(publishInfo.DiscoveryIPs) != 0 {
+			blockDev.IscsiAccessInfo.DiscoveryIP = publishInfo.DiscoveryIPs[0]
+		}
+	}
+	return &chapiModel.PublishInfo{SerialNumber: publishInfo.SerialNumber, BlockDev: blockDev}
+}
+
+// getFileSystemOptionsFromConfig returns the filesystem options stored in the volume config
+func getFileSystemOptionsFromConfig(config map[string]interface{}) *chapiModel.FileSystemOptions {
+	fsOptions := &chapiModel.FileSystemOptions{}
+	if val, ok := config[model.FsCreateOpt]; ok {
+		fsOptions.FsType = fmt.Sprintf("%v", val)
+	}
+	if val, ok := config[model.FsModeOpt]; ok {
+		fsOptions.FsMode = fmt.Sprintf("%v", val)
+	}
+	if val, ok := config[model.FsOwnerOpt]; ok {
+		fsOptions.FsOwner = fmt.Sprintf("%v", val)
+	}
+	if fsOptions.FsType == "" {
+		fsOptions.FsType = "xfs"
+	}
+	return fsOptions
+}
+
+// isDelayedCreate returns true if the filesystem has not yet been created on the volume
+func isDelayedCreate(config map[string]interface{}) bool {
+	val, ok := config[plugin.DelayedCreateOpt]
+	if !ok {
+		return false
+	}
+	switch v := val.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
@@ -68,6 +68,13 @@ func VolumeDriverUnmount(w http.ResponseWriter, r *http.Request) {
 	log.Tracef("taken channel for volume %s in unmount with channel length :%d channel capacity :%d", pluginReq.Name, len(unmountRequestsChan), cap(unmountRequestsChan))
 	defer unblockChannelHandler("unmount", pluginReq.Name, unmountRequestsChan)
 
+	// an unmount by an ID which holds no reference while other containers still do is a no-op
+	if ref := mountTable.Get(pluginReq.Name); ref != nil && len(ref.IDs) != 0 && !mountTable.HasRef(pluginReq.Name, pluginReq.ID) {
+		log.Infof("%s holds no mount of volume %s, %d containers still do", pluginReq.ID, pluginReq.Name, len(ref.IDs))
+		json.NewEncoder(w).Encode(DriverResponse{})
+		return
+	}
+
 	//1. container-provider /VolumeDriver.Unmount called
 	_, err = providerClient.DoJSON(&connectivity.Request{Action: "POST", Path: provider.UnmountURI, Payload: &pluginReq, Response: &volResp, ResponseError: &volResp})
 	log.Tracef("/VolumeDriver.Unmount for volume %s response=%+v", pluginReq.Name, volResp)
@@ -85,8 +92,8 @@ func VolumeDriverUnmount(w http.ResponseWriter, r *http.Request) {
 	volume := volResp.Volume
 	log.Tracef("volResp Message %s", volResp.Message)
 
-	//2. check for message for other mounts
-	if volResp.Message == donotUnmount {
+	//2. check for other mounts, the volume is only unmounted and detached by the last one
+	if !releaseMount(pluginReq.Name, pluginReq.ID) || volResp.Message == donotUnmount {
 		log.Infof("%s is mounted on other containers", volume.Name)
 		dr = DriverResponse{}
 		json.NewEncoder(w).Encode(dr)
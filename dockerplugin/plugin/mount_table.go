@@ -0,0 +1,216 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+const (
+	// MountTableFileName represents the file in the plugin config directory holding the volume mount references
+	MountTableFileName = "volume-mounts.json"
+)
+
+// MountRef represents a volume mounted on this host and the docker mount IDs referencing it
+type MountRef struct {
+	Volume       string   `json:"volume"`
+	SerialNumber string   `json:"serialNumber,omitempty"`
+	MountPoint   string   `json:"mountPoint"`
+	IDs          []string `json:"ids"`
+}
+
+// hasID returns true if the docker mount ID references the mount
+func (m *MountRef) hasID(id string) bool {
+	for _, refID := range m.IDs {
+		if refID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// MountTable tracks which docker mount IDs hold each volume mounted on this host so that mount and
+// unmount are idempotent and the volume is only detached when the last container releases it.  The
+// table is written to disk on every change to survive plugin restarts.
+type MountTable struct {
+	path   string
+	lock   sync.Mutex
+	mounts map[string]*MountRef
+}
+
+// NewMountTable returns a mount table persisted at path, loading any previous state from it.  An empty
+// path keeps the table in memory only.
+func NewMountTable(path string) (*MountTable, error) {
+	table := &MountTable{path: path, mounts: make(map[string]*MountRef)}
+	if path == "" {
+		return table, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return table, nil
+		}
+		return nil, fmt.Errorf("unable to read mount table %s, %s", path, err.Error())
+	}
+	var refs []*MountRef
+	if err = json.Unmarshal(data, &refs); err != nil {
+		return nil, fmt.Errorf("unable to parse mount table %s, %s", path, err.Error())
+	}
+	for _, ref := range refs {
+		table.mounts[ref.Volume] = ref
+	}
+	return table, nil
+}
+
+// Get returns a copy of the mount reference of the volume, nil if it isn't mounted
+func (t *MountTable) Get(volume string) *MountRef {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ref, ok := t.mounts[volume]
+	if !ok {
+		return nil
+	}
+	return copyMountRef(ref)
+}
+
+// List returns a copy of all mount references sorted by volume name
+func (t *MountTable) List() []*MountRef {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.list()
+}
+
+// HasRef returns true if the docker mount ID holds a reference on the volume
+func (t *MountTable) HasRef(volume, id string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ref, ok := t.mounts[volume]
+	return ok && ref.hasID(id)
+}
+
+// Add records a reference by the docker mount ID on the volume mounted at mountPoint, adding an
+// existing reference again has no effect
+func (t *MountTable) Add(volume, id, serialNumber, mountPoint string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ref, ok := t.mounts[volume]
+	if !ok {
+		ref = &MountRef{Volume: volume}
+		t.mounts[volume] = ref
+	}
+	ref.SerialNumber = serialNumber
+	ref.MountPoint = mountPoint
+	if ref.hasID(id) {
+		return nil
+	}
+	ref.IDs = append(ref.IDs, id)
+	log.Debugf("volume %s mounted by %s, references %v", volume, id, ref.IDs)
+	return t.save()
+}
+
+// Remove releases the reference by the docker mount ID on the volume and returns the number of
+// references still held.  The volume is dropped from the table once no references remain.  Releasing
+// an ID that holds no reference leaves the table unchanged.
+func (t *MountTable) Remove(volume, id string) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ref, ok := t.mounts[volume]
+	if !ok {
+		return 0, nil
+	}
+	if !ref.hasID(id) {
+		log.Debugf("%s holds no reference on volume %s, references %v", id, volume, ref.IDs)
+		return len(ref.IDs), nil
+	}
+	ids := ref.IDs[:0]
+	for _, refID := range ref.IDs {
+		if refID != id {
+			ids = append(ids, refID)
+		}
+	}
+	ref.IDs = ids
+	if len(ref.IDs) == 0 {
+		delete(t.mounts, volume)
+	}
+	log.Debugf("volume %s released by %s, references %v", volume, id, ref.IDs)
+	return len(ref.IDs), t.save()
+}
+
+// Delete drops the volume from the table regardless of the references held
+func (t *MountTable) Delete(volume string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, ok := t.mounts[volume]; !ok {
+		return nil
+	}
+	delete(t.mounts, volume)
+	return t.save()
+}
+
+// Reconcile rebuilds the table from the mounts present on the host.  Volumes which are no longer
+// mounted are dropped with their references, and hostMounts (volume name to mount reference) which
+// are missing from the table are adopted without references so that the next unmount detaches them.
+// The names of dropped volumes are returned.
+func (t *MountTable) Reconcile(hostMounts map[string]*MountRef) ([]string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var dropped []string
+	for volume, ref := range t.mounts {
+		hostMount, ok := hostMounts[volume]
+		if !ok || hostMount.MountPoint != ref.MountPoint {
+			log.Infof("volume %s is no longer mounted on %s, dropping references %v", volume, ref.MountPoint, ref.IDs)
+			delete(t.mounts, volume)
+			dropped = append(dropped, volume)
+		}
+	}
+	for volume, hostMount := range hostMounts {
+		if _, ok := t.mounts[volume]; !ok {
+			log.Infof("adopting mount of volume %s on %s", volume, hostMount.MountPoint)
+			t.mounts[volume] = &MountRef{Volume: volume, SerialNumber: hostMount.SerialNumber, MountPoint: hostMount.MountPoint}
+		}
+	}
+	sort.Strings(dropped)
+	return dropped, t.save()
+}
+
+// list returns a sorted copy of the mount references, lock must be held
+func (t *MountTable) list() []*MountRef {
+	refs := make([]*MountRef, 0, len(t.mounts))
+	for _, ref := range t.mounts {
+		refs = append(refs, copyMountRef(ref))
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Volume < refs[j].Volume })
+	return refs
+}
+
+// save writes the table to a temporary file which then replaces the previous state, lock must be held
+func (t *MountTable) save() error {
+	if t.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(t.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := t.path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("unable to write mount table %s, %s", tmpPath, err.Error())
+	}
+	if err = os.Rename(tmpPath, t.path); err != nil {
+		return fmt.Errorf("unable to replace mount table %s, %s", t.path, err.Error())
+	}
+	return nil
+}
+
+func copyMountRef(ref *MountRef) *MountRef {
+	refCopy := *ref
+	refCopy.IDs = append([]string(nil), ref.IDs...)
+	return &refCopy
+}
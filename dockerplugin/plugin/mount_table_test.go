@@ -0,0 +1,99 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMountTableReferences(t *testing.T) {
+	table, err := NewMountTable("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Add("vol1", "c1", "serial1", "/mnt/vol1")
+	table.Add("vol1", "c2", "serial1", "/mnt/vol1")
+	// adding the same reference twice is idempotent
+	table.Add("vol1", "c2", "serial1", "/mnt/vol1")
+	if ref := table.Get("vol1"); ref == nil || len(ref.IDs) != 2 {
+		t.Fatalf("expected 2 references on vol1, got %+v", ref)
+	}
+	if !table.HasRef("vol1", "c1") || table.HasRef("vol1", "c3") {
+		t.Error("unexpected result from HasRef")
+	}
+	if remaining, _ := table.Remove("vol1", "c3"); remaining != 2 {
+		t.Errorf("expected unknown ID to leave 2 references, got %d", remaining)
+	}
+	if remaining, _ := table.Remove("vol1", "c1"); remaining != 1 {
+		t.Errorf("expected 1 remaining reference, got %d", remaining)
+	}
+	if remaining, _ := table.Remove("vol1", "c1"); remaining != 1 {
+		t.Errorf("expected releasing c1 twice to leave 1 reference, got %d", remaining)
+	}
+	if remaining, _ := table.Remove("vol1", "c2"); remaining != 0 {
+		t.Errorf("expected no remaining references, got %d", remaining)
+	}
+	if table.Get("vol1") != nil {
+		t.Error("expected vol1 to be dropped after the last reference")
+	}
+}
+
+func TestMountTablePersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mount-table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, MountTableFileName)
+
+	table, err := NewMountTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = table.Add("vol1", "c1", "serial1", "/mnt/vol1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = table.Add("vol2", "c2", "serial2", "/mnt/vol2"); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewMountTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := reloaded.List()
+	if len(refs) != 2 || refs[0].Volume != "vol1" || !reloaded.HasRef("vol2", "c2") {
+		t.Fatalf("unexpected references after reload %+v", refs)
+	}
+
+	ioutil.WriteFile(path, []byte("{"), 0600)
+	if _, err = NewMountTable(path); err == nil {
+		t.Error("expected an error loading a corrupt mount table")
+	}
+}
+
+func TestMountTableReconcile(t *testing.T) {
+	table, _ := NewMountTable("")
+	table.Add("vol1", "c1", "serial1", "/mnt/vol1")
+	table.Add("vol2", "c2", "serial2", "/mnt/vol2")
+
+	dropped, err := table.Reconcile(map[string]*MountRef{
+		"vol1": {Volume: "vol1", SerialNumber: "serial1", MountPoint: "/mnt/vol1"},
+		"vol3": {Volume: "vol3", SerialNumber: "serial3", MountPoint: "/mnt/vol3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dropped) != 1 || dropped[0] != "vol2" {
+		t.Errorf("expected vol2 to be dropped, got %v", dropped)
+	}
+	if !table.HasRef("vol1", "c1") {
+		t.Error("expected references of mounted vol1 to be kept")
+	}
+	if ref := table.Get("vol3"); ref == nil || len(ref.IDs) != 0 {
+		t.Errorf("expected vol3 to be adopted without references, got %+v", ref)
+	}
+}
@@ -0,0 +1,549 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+const (
+	// HelpOpt represents the create option used to request help text
+	HelpOpt = "help"
+	// SizeOpt represents the short form of the volume size option
+	SizeOpt = "size"
+	// SizeInGiBOpt represents the volume size option in GiB
+	SizeInGiBOpt = "sizeInGiB"
+	// CloneOfOpt represents the create option to clone an existing docker volume
+	CloneOfOpt = "cloneOf"
+	// SnapshotOpt represents the snapshot to be used for a clone
+	SnapshotOpt = "snapshot"
+	// CreateSnapshotOpt represents the create option to snapshot the parent before cloning
+	CreateSnapshotOpt = "createSnapshot"
+	// ImportVolOpt represents the create option to import an existing array volume
+	ImportVolOpt = "importVol"
+	// ImportVolAsCloneOpt represents the create option to import an array volume as a clone
+	ImportVolAsCloneOpt = "importVolAsClone"
+	// ForceImportOpt represents the create option to force import of a volume in use
+	ForceImportOpt = "forceImport"
+	// DestroyOnRmOpt represents the create option to destroy the array volume on docker volume rm
+	DestroyOnRmOpt = "destroyOnRm"
+	// DelayedCreateOpt represents the option to defer filesystem creation until first mount
+	DelayedCreateOpt = "delayedCreate"
+	// ReleaseOpt represents the remove option to release the volume to the array instead of forgetting or destroying it
+	ReleaseOpt = "release"
+	// ReleaseNameOpt represents the remove option to rename the array volume on release
+	ReleaseNameOpt = "releaseName"
+	// NameOpt represents the update option to rename the array volume
+	NameOpt = "name"
+	// TakeoverOpt represents the option to take over a volume still in use by another host
+	TakeoverOpt = "takeover"
+	// ManagerOpt represents the remove option naming the volume manager issuing the request
+	ManagerOpt = "manager"
+
+	// maximum volume size in GiB accepted by the plugin (64 TiB)
+	maxSizeInGiB = 64 * 1024
+)
+
+// OptionType represents the value type accepted by a create option
+type OptionType int
+
+const (
+	// StringOption accepts any string value
+	StringOption OptionType = 1 + iota
+	// IntOption accepts integral values
+	IntOption
+	// BoolOption accepts true or false values
+	BoolOption
+)
+
+func (t OptionType) String() string {
+	switch t {
+	case StringOption:
+		return "string"
+	case IntOption:
+		return "integer"
+	case BoolOption:
+		return "boolean"
+	default:
+		return ""
+	}
+}
+
+// CreateOption describes a single docker volume create option
+type CreateOption struct {
+	Name        string
+	Type        OptionType
+	Description string
+	// Values lists the permitted values, empty allows any value of Type
+	Values []string
+	// Internal options are accepted from the config file or set by the plugin but not listed in help
+	Internal bool
+}
+
+// CreateOptionSchema describes the create options accepted by a plugin type
+type CreateOptionSchema struct {
+	PluginType PluginType
+	options    map[string]*CreateOption
+}
+
+// CreateOptions is the typed form of the create options handled locally by the plugin
+type CreateOptions struct {
+	SizeInGiB        int64
+	CloneOf          string
+	Snapshot         string
+	CreateSnapshot   bool
+	ImportVol        string
+	ImportVolAsClone string
+	ForceImport      bool
+	DestroyOnRm      bool
+	Filesystem       string
+}
+
+// options common to all plugin types
+var commonCreateOptions = []*CreateOption{
+	{Name: HelpOpt, Type: StringOption, Description: "display this help text"},
+	{Name: SizeOpt, Type: IntOption, Description: "size of the volume in GiB (short form of sizeInGiB)"},
+	{Name: SizeInGiBOpt, Type: IntOption, Description: "size of the volume in GiB"},
+	{Name: "description", Type: StringOption, Description: "text to be added to the volume description"},
+	{Name: "filesystem", Type: StringOption, Description: "filesystem to create on the volume"},
+	{Name: "fsOwner", Type: StringOption, Description: "user id and group id that should own the root directory of the filesystem, in the form of [userId:groupId]"},
+	{Name: "fsMode", Type: StringOption, Description: "1 to 4 octal digits that represent the file mode to be applied to the root directory of the filesystem"},
+	{Name: MountConflictDelayKey, Type: IntOption, Description: "number of seconds to wait for other hosts to release the volume during mount"},
+	{Name: CloneOfOpt, Type: StringOption, Description: "name of the docker volume to clone"},
+	{Name: SnapshotOpt, Type: StringOption, Description: "name of the snapshot to base the clone on, used with cloneOf or importVolAsClone"},
+	{Name: CreateSnapshotOpt, Type: BoolOption, Description: "take a new snapshot of the parent volume and base the clone on it, used with cloneOf"},
+	{Name: ImportVolOpt, Type: StringOption, Description: "name of the array volume to import as a docker volume"},
+	{Name: ForceImportOpt, Type: BoolOption, Description: "force the import of a volume that is in use, used with importVol or importVolAsClone"},
+	{Name: DestroyOnRmOpt, Type: BoolOption, Description: "destroy the array volume when the docker volume is removed"},
+	{Name: DelayedCreateOpt, Type: BoolOption, Internal: true},
+	{Name: "logLevel", Type: StringOption, Internal: true},
+	{Name: "volumeDir", Type: StringOption, Internal: true},
+	{Name: DeleteConflictDelayKey, Type: IntOption, Internal: true},
+}
+
+// options that only apply when the volume is created
+var createOnlyOptions = []string{HelpOpt, SizeOpt, SizeInGiBOpt, "filesystem", CloneOfOpt, SnapshotOpt, CreateSnapshotOpt,
+	ImportVolOpt, ImportVolAsCloneOpt, ForceImportOpt, "restore", TakeoverOpt, "reverseRepl"}
+
+// options specific to the nimble plugin type
+var nimbleCreateOptions = []*CreateOption{
+	{Name: "perfPolicy", Type: StringOption, Description: "name of the performance policy to assign to the volume"},
+	{Name: "pool", Type: StringOption, Description: "name of the pool in which to place the volume"},
+	{Name: "folder", Type: StringOption, Description: "name of the folder in which to place the volume"},
+	{Name: "encryption", Type: BoolOption, Description: "encrypt the volume"},
+	{Name: "thick", Type: BoolOption, Description: "thick provision the volume"},
+	{Name: "dedupe", Type: BoolOption, Description: "enable deduplication on the volume"},
+	{Name: "protectionTemplate", Type: StringOption, Description: "name of the protection template to apply to the volume"},
+	{Name: "limitIOPS", Type: IntOption, Description: "IOPS limit for the volume, -1 for unlimited"},
+	{Name: "limitMBPS", Type: IntOption, Description: "MB/s throughput limit for the volume, -1 for unlimited"},
+	{Name: "syncOnUnmount", Type: BoolOption, Description: "take a snapshot of the volume on unmount, used with importVol on a replica"},
+	{Name: "destroyOnDetach", Type: BoolOption, Description: "destroy the volume when it is detached from the last host"},
+	{Name: ImportVolAsCloneOpt, Type: StringOption, Description: "name of the array volume to import as a clone"},
+	{Name: "restore", Type: BoolOption, Description: "restore the volume to the snapshot given by the snapshot option, used with importVol"},
+	{Name: TakeoverOpt, Type: BoolOption, Description: "take over ownership of a replicated volume, used with importVol"},
+	{Name: "reverseRepl", Type: BoolOption, Description: "reverse replication of a replicated volume, used with importVol"},
+}
+
+// options specific to the cloud volumes plugin type
+var cvCreateOptions = []*CreateOption{
+	{Name: "perfPolicy", Type: StringOption, Description: "name of the performance policy to assign to the volume", Values: []string{"Exchange", "Oracle", "SharePoint", "SQL", "Windows File Server", "Other Workloads"}},
+	{Name: "volumeType", Type: StringOption, Description: "volume type, general purpose (GPF) or performance (PF)", Values: []string{"GPF", "PF"}},
+	{Name: "encryption", Type: BoolOption, Description: "encrypt the volume"},
+	{Name: "protectionTemplate", Type: StringOption, Description: "name of the protection template to apply to the volume"},
+	{Name: "schedule", Type: StringOption, Description: "snapshot schedule to apply to the volume"},
+	{Name: "retentionPolicy", Type: IntOption, Description: "number of snapshots to retain"},
+	{Name: "limitIOPS", Type: IntOption, Description: "IOPS limit for the volume"},
+	{Name: "initiators", Type: StringOption, Internal: true},
+}
+
+// options specific to the simplivity plugin type
+var simplivityCreateOptions = []*CreateOption{
+	{Name: "datastore", Type: StringOption, Description: "name of the datastore on which to create the volume"},
+	{Name: "backupPolicy", Type: StringOption, Description: "name of the backup policy to assign to the volume"},
+}
+
+// GetCreateOptionSchema returns the create option schema for the given plugin type
+func GetCreateOptionSchema(pluginType PluginType) *CreateOptionSchema {
+	schema := &CreateOptionSchema{PluginType: pluginType, options: make(map[string]*CreateOption)}
+	var specific []*CreateOption
+	switch pluginType {
+	case Cv:
+		specific = cvCreateOptions
+	case Simplivity:
+		specific = simplivityCreateOptions
+	default:
+		specific = nimbleCreateOptions
+	}
+	for _, opt := range commonCreateOptions {
+		schema.options[opt.Name] = opt
+	}
+	for _, opt := range specific {
+		schema.options[opt.Name] = opt
+	}
+	// restrict filesystem to the types supported on this platform
+	fsOpt := *schema.options["filesystem"]
+	fsOpt.Values = SupportedFileSystems
+	schema.options["filesystem"] = &fsOpt
+	return schema
+}
+
+// Lookup returns the option with the given name, nil if the plugin type doesn't support it
+func (s *CreateOptionSchema) Lookup(name string) *CreateOption {
+	return s.options[name]
+}
+
+// Names returns the sorted names of all options in the schema
+func (s *CreateOptionSchema) Names() []string {
+	var names []string
+	for name := range s.options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks the option names and values against the schema and returns an error listing every invalid option
+func (s *CreateOptionSchema) Validate(opts map[string]interface{}) error {
+	log.Tracef(">>>>> Validate called with %v for %s plugin", opts, s.PluginType.String())
+	defer log.Trace("<<<<< Validate")
+
+	var invalid []string
+	for _, name := range sortedKeys(opts) {
+		opt := s.Lookup(name)
+		if opt == nil {
+			invalid = append(invalid, fmt.Sprintf("%s is not a valid option", name))
+			continue
+		}
+		if err := opt.validateValue(opts[name]); err != nil {
+			invalid = append(invalid, err.Error())
+		}
+	}
+	if len(invalid) != 0 {
+		return fmt.Errorf("invalid create options for %s plugin: %s", s.PluginType.String(), strings.Join(invalid, "; "))
+	}
+	return nil
+}
+
+// ValidateUpdate checks the options of a volume update against the schema.  Options that only apply when the volume
+// is created and internal options are rejected as well.
+func (s *CreateOptionSchema) ValidateUpdate(opts map[string]interface{}) error {
+	log.Tracef(">>>>> ValidateUpdate called with %v for %s plugin", opts, s.PluginType.String())
+	defer log.Trace("<<<<< ValidateUpdate")
+
+	if err := s.Validate(opts); err != nil {
+		return err
+	}
+	var invalid []string
+	for _, name := range sortedKeys(opts) {
+		if s.options[name].Internal || isCreateOnlyOption(name) {
+			invalid = append(invalid, name)
+		}
+	}
+	if len(invalid) != 0 {
+		return fmt.Errorf("options %s cannot be updated", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// Help returns the help text describing every user facing option in the schema
+func (s *CreateOptionSchema) Help() string {
+	var help strings.Builder
+	fmt.Fprintf(&help, "\n%s volume driver: create options:\n", s.PluginType.String())
+	for _, name := range s.Names() {
+		opt := s.options[name]
+		if opt.Internal || opt.Name == HelpOpt {
+			continue
+		}
+		fmt.Fprintf(&help, "  -o %s=<%s>  %s", opt.Name, opt.Type.String(), opt.Description)
+		if len(opt.Values) != 0 {
+			fmt.Fprintf(&help, " (%s)", strings.Join(opt.Values, ", "))
+		}
+		help.WriteString("\n")
+	}
+	return help.String()
+}
+
+func (opt *CreateOption) validateValue(value interface{}) error {
+	var str string
+	switch opt.Type {
+	case IntOption:
+		if _, err := toInt64(value); err != nil {
+			return fmt.Errorf("%s must be an %s, got %v", opt.Name, opt.Type.String(), value)
+		}
+		return nil
+	case BoolOption:
+		if _, err := toBool(value); err != nil {
+			return fmt.Errorf("%s must be a %s, got %v", opt.Name, opt.Type.String(), value)
+		}
+		return nil
+	default:
+		str = fmt.Sprintf("%v", value)
+	}
+	if len(opt.Values) == 0 {
+		return nil
+	}
+	for _, v := range opt.Values {
+		if strings.EqualFold(v, str) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be one of (%s), got %s", opt.Name, strings.Join(opt.Values, ", "), str)
+}
+
+func isCreateOnlyOption(name string) bool {
+	for _, opt := range createOnlyOptions {
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsHelpRequest returns true if the options only ask for the generic create help text
+func IsHelpRequest(opts map[string]interface{}) bool {
+	val, ok := opts[HelpOpt]
+	if !ok {
+		return false
+	}
+	// help=<topic> is answered by the container provider
+	str := strings.TrimSpace(fmt.Sprintf("%v", val))
+	return str == "" || strings.EqualFold(str, "true")
+}
+
+// ParseCreateOptions decodes the options handled locally by the plugin and rejects conflicting combinations
+// nolint: gocyclo
+func ParseCreateOptions(opts map[string]interface{}) (*CreateOptions, error) {
+	log.Tracef(">>>>> ParseCreateOptions called with %v", opts)
+	defer log.Trace("<<<<< ParseCreateOptions")
+
+	createOpts := &CreateOptions{}
+	var err error
+
+	// size and sizeInGiB may both be specified as long as they agree
+	if createOpts.SizeInGiB, err = getInt64Opt(opts, SizeInGiBOpt); err != nil {
+		return nil, err
+	}
+	size, err := getInt64Opt(opts, SizeOpt)
+	if err != nil {
+		return nil, err
+	}
+	if size != 0 {
+		if createOpts.SizeInGiB != 0 && createOpts.SizeInGiB != size {
+			return nil, fmt.Errorf("conflicting values for %s (%d) and %s (%d)", SizeOpt, size, SizeInGiBOpt, createOpts.SizeInGiB)
+		}
+		createOpts.SizeInGiB = size
+	}
+	// a size of zero is only valid when no size is given at all
+	sizeGiven := opts[SizeOpt] != nil || opts[SizeInGiBOpt] != nil
+	if (sizeGiven && createOpts.SizeInGiB <= 0) || createOpts.SizeInGiB > maxSizeInGiB {
+		return nil, fmt.Errorf("%s must be between 1 and %d, got %d", SizeInGiBOpt, maxSizeInGiB, createOpts.SizeInGiB)
+	}
+
+	createOpts.CloneOf = getStringOpt(opts, CloneOfOpt)
+	createOpts.Snapshot = getStringOpt(opts, SnapshotOpt)
+	createOpts.ImportVol = getStringOpt(opts, ImportVolOpt)
+	createOpts.ImportVolAsClone = getStringOpt(opts, ImportVolAsCloneOpt)
+	createOpts.Filesystem = getStringOpt(opts, "filesystem")
+	if createOpts.CreateSnapshot, err = getBoolOpt(opts, CreateSnapshotOpt); err != nil {
+		return nil, err
+	}
+	if createOpts.ForceImport, err = getBoolOpt(opts, ForceImportOpt); err != nil {
+		return nil, err
+	}
+	if createOpts.DestroyOnRm, err = getBoolOpt(opts, DestroyOnRmOpt); err != nil {
+		return nil, err
+	}
+
+	// only one of the clone/import workflows can be requested
+	var workflows []string
+	for _, w := range []struct{ name, value string }{
+		{CloneOfOpt, createOpts.CloneOf},
+		{ImportVolOpt, createOpts.ImportVol},
+		{ImportVolAsCloneOpt, createOpts.ImportVolAsClone},
+	} {
+		if w.value != "" {
+			workflows = append(workflows, w.name)
+		}
+	}
+	if len(workflows) > 1 {
+		return nil, fmt.Errorf("options %s cannot be specified together", strings.Join(workflows, " and "))
+	}
+
+	// imported volumes keep their size on the array
+	if createOpts.IsImport() && createOpts.SizeInGiB != 0 {
+		return nil, fmt.Errorf("%s cannot be specified with %s", SizeInGiBOpt, workflows[0])
+	}
+	if createOpts.Snapshot != "" && createOpts.CloneOf == "" && createOpts.ImportVolAsClone == "" {
+		return nil, fmt.Errorf("%s can only be specified with %s or %s", SnapshotOpt, CloneOfOpt, ImportVolAsCloneOpt)
+	}
+	if createOpts.CreateSnapshot && createOpts.CloneOf == "" {
+		return nil, fmt.Errorf("%s can only be specified with %s", CreateSnapshotOpt, CloneOfOpt)
+	}
+	if createOpts.CreateSnapshot && createOpts.Snapshot != "" {
+		return nil, fmt.Errorf("%s cannot be specified with %s", CreateSnapshotOpt, SnapshotOpt)
+	}
+	if createOpts.ForceImport && !createOpts.IsImport() {
+		return nil, fmt.Errorf("%s can only be specified with %s or %s", ForceImportOpt, ImportVolOpt, ImportVolAsCloneOpt)
+	}
+	for _, key := range []string{"restore", TakeoverOpt, "reverseRepl"} {
+		if _, ok := opts[key]; ok && createOpts.ImportVol == "" {
+			return nil, fmt.Errorf("%s can only be specified with %s", key, ImportVolOpt)
+		}
+	}
+	return createOpts, nil
+}
+
+// IsImport returns true if the options request import of an existing array volume
+func (o *CreateOptions) IsImport() bool {
+	return o.ImportVol != "" || o.ImportVolAsClone != ""
+}
+
+// IsClone returns true if the options request a clone of a docker volume
+func (o *CreateOptions) IsClone() bool {
+	return o.CloneOf != ""
+}
+
+// Apply writes the normalized typed values back into the request options
+func (o *CreateOptions) Apply(opts map[string]interface{}) {
+	// the short form of size is replaced by sizeInGiB
+	delete(opts, SizeOpt)
+	if o.SizeInGiB != 0 {
+		opts[SizeInGiBOpt] = o.SizeInGiB
+	}
+	if _, ok := opts[DestroyOnRmOpt]; ok {
+		opts[DestroyOnRmOpt] = o.DestroyOnRm
+	}
+	if _, ok := opts[CreateSnapshotOpt]; ok {
+		opts[CreateSnapshotOpt] = o.CreateSnapshot
+	}
+	if _, ok := opts[ForceImportOpt]; ok {
+		opts[ForceImportOpt] = o.ForceImport
+	}
+}
+
+// RemoveOptions is the typed form of the options accepted on docker volume remove
+type RemoveOptions struct {
+	// Release detaches the volume and hands it back to the array without deleting its data
+	Release bool
+	// ReleaseName renames the array volume on release, empty keeps the current name
+	ReleaseName string
+	// Takeover releases a volume still in use by another host, which is expected to be down
+	Takeover bool
+}
+
+// ParseRemoveOptions decodes the remove options and rejects conflicting combinations.  Unknown keys are logged and
+// ignored, docker and orchestrators may pass options of their own on remove.
+func ParseRemoveOptions(opts map[string]interface{}) (*RemoveOptions, error) {
+	log.Tracef(">>>>> ParseRemoveOptions called with %v", opts)
+	defer log.Trace("<<<<< ParseRemoveOptions")
+
+	var ignored []string
+	for _, key := range sortedKeys(opts) {
+		switch key {
+		case ReleaseOpt, ReleaseNameOpt, TakeoverOpt, ManagerOpt:
+		default:
+			ignored = append(ignored, key)
+		}
+	}
+	if len(ignored) != 0 {
+		log.Infof("ignoring unknown remove options: %s", strings.Join(ignored, ", "))
+	}
+
+	removeOpts := &RemoveOptions{ReleaseName: getStringOpt(opts, ReleaseNameOpt)}
+	var err error
+	if removeOpts.Release, err = getBoolOpt(opts, ReleaseOpt); err != nil {
+		return nil, err
+	}
+	if removeOpts.Takeover, err = getBoolOpt(opts, TakeoverOpt); err != nil {
+		return nil, err
+	}
+	if _, ok := opts[ReleaseNameOpt]; ok && !removeOpts.Release {
+		return nil, fmt.Errorf("%s can only be specified with %s", ReleaseNameOpt, ReleaseOpt)
+	}
+	if _, ok := opts[ReleaseNameOpt]; ok && removeOpts.ReleaseName == "" {
+		return nil, fmt.Errorf("%s cannot be empty", ReleaseNameOpt)
+	}
+	if removeOpts.Takeover && !removeOpts.Release {
+		return nil, fmt.Errorf("%s can only be specified with %s", TakeoverOpt, ReleaseOpt)
+	}
+	return removeOpts, nil
+}
+
+func getStringOpt(opts map[string]interface{}, key string) string {
+	val, ok := opts[key]
+	if !ok || val == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v", val))
+}
+
+func getInt64Opt(opts map[string]interface{}, key string) (int64, error) {
+	val, ok := opts[key]
+	if !ok || val == nil {
+		return 0, nil
+	}
+	i, err := toInt64(val)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer, got %v", key, val)
+	}
+	return i, nil
+}
+
+func getBoolOpt(opts map[string]interface{}, key string) (bool, error) {
+	val, ok := opts[key]
+	if !ok || val == nil {
+		return false, nil
+	}
+	b, err := toBool(val)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean, got %v", key, val)
+	}
+	return b, nil
+}
+
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	default:
+		return 0, fmt.Errorf("%v is not an integer", v)
+	}
+}
+
+func toBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		// docker passes -o key with no value as an empty string
+		if strings.TrimSpace(v) == "" {
+			return true, nil
+		}
+		return strconv.ParseBool(strings.TrimSpace(v))
+	default:
+		return false, fmt.Errorf("%v is not a boolean", v)
+	}
+}
+
+func sortedKeys(opts map[string]interface{}) []string {
+	var keys []string
+	for key := range opts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
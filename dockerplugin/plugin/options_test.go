@@ -0,0 +1,156 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package plugin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateCreateOptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		pluginType PluginType
+		opts       map[string]interface{}
+		wantErr    bool
+	}{
+		{"valid nimble options", Nimble, map[string]interface{}{"size": "10", "perfPolicy": "default", "thick": "true"}, false},
+		{"unknown option", Nimble, map[string]interface{}{"sizeInGB": "10"}, true},
+		{"nimble only option on cv", Cv, map[string]interface{}{"folder": "f1"}, true},
+		{"bad integer", Nimble, map[string]interface{}{"sizeInGiB": "ten"}, true},
+		{"bad boolean", Nimble, map[string]interface{}{"destroyOnRm": "maybe"}, true},
+		{"empty boolean", Nimble, map[string]interface{}{"destroyOnRm": ""}, false},
+		{"config file types", Nimble, map[string]interface{}{"sizeInGiB": float64(10), "dedupe": true}, false},
+		{"value not allowed", Cv, map[string]interface{}{"volumeType": "SSD"}, true},
+		{"value allowed", Cv, map[string]interface{}{"volumeType": "gpf"}, false},
+	}
+	for _, tc := range tests {
+		err := GetCreateOptionSchema(tc.pluginType).Validate(tc.opts)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestValidateListsEveryInvalidOption(t *testing.T) {
+	err := GetCreateOptionSchema(Nimble).Validate(map[string]interface{}{"foo": "1", "bar": "2", "size": "x"})
+	if err == nil {
+		t.Fatal("expected an error for invalid options")
+	}
+	for _, key := range []string{"foo", "bar", "size"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("expected error to mention %s, got %s", key, err.Error())
+		}
+	}
+}
+
+func TestParseCreateOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    map[string]interface{}
+		wantErr bool
+	}{
+		{"plain create", map[string]interface{}{"size": "10", "destroyOnRm": "true"}, false},
+		{"matching sizes", map[string]interface{}{"size": "10", "sizeInGiB": 10}, false},
+		{"conflicting sizes", map[string]interface{}{"size": "10", "sizeInGiB": "20"}, true},
+		{"negative size", map[string]interface{}{"size": "-1"}, true},
+		{"zero size", map[string]interface{}{"sizeInGiB": 0}, true},
+		{"no size", map[string]interface{}{"sizeInGiB": nil}, false},
+		{"clone and import", map[string]interface{}{"cloneOf": "v1", "importVol": "v2"}, true},
+		{"import and import as clone", map[string]interface{}{"importVolAsClone": "v1", "importVol": "v2"}, true},
+		{"import with size", map[string]interface{}{"importVol": "v1", "size": "10"}, true},
+		{"clone with size", map[string]interface{}{"cloneOf": "v1", "size": "10"}, false},
+		{"snapshot without clone", map[string]interface{}{"snapshot": "s1"}, true},
+		{"snapshot with import as clone", map[string]interface{}{"importVolAsClone": "v1", "snapshot": "s1"}, false},
+		{"createSnapshot with snapshot", map[string]interface{}{"cloneOf": "v1", "snapshot": "s1", "createSnapshot": "true"}, true},
+		{"forceImport without import", map[string]interface{}{"forceImport": "true"}, true},
+		{"takeover without importVol", map[string]interface{}{"importVolAsClone": "v1", "takeover": "true"}, true},
+		{"takeover with importVol", map[string]interface{}{"importVol": "v1", "takeover": "true"}, false},
+	}
+	for _, tc := range tests {
+		_, err := ParseCreateOptions(tc.opts)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestCreateOptionsApply(t *testing.T) {
+	opts := map[string]interface{}{"size": "10", "destroyOnRm": "", "description": "d"}
+	createOpts, err := ParseCreateOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createOpts.Apply(opts)
+	if _, ok := opts[SizeOpt]; ok {
+		t.Error("expected short form size to be removed")
+	}
+	if opts[SizeInGiBOpt] != int64(10) {
+		t.Errorf("expected sizeInGiB 10, got %v", opts[SizeInGiBOpt])
+	}
+	if opts[DestroyOnRmOpt] != true {
+		t.Errorf("expected destroyOnRm true, got %v", opts[DestroyOnRmOpt])
+	}
+	if opts["description"] != "d" {
+		t.Errorf("expected description to be preserved, got %v", opts["description"])
+	}
+}
+
+func TestValidateUpdateOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    map[string]interface{}
+		wantErr bool
+	}{
+		{"description", map[string]interface{}{"description": "updated", "perfPolicy": "default"}, false},
+		{"unknown option", map[string]interface{}{"sizeInGB": "10"}, true},
+		{"bad boolean", map[string]interface{}{"destroyOnRm": "maybe"}, true},
+		{"create only option", map[string]interface{}{"cloneOf": "vol1"}, true},
+		{"size", map[string]interface{}{"sizeInGiB": "20"}, true},
+		{"internal option", map[string]interface{}{DelayedCreateOpt: true}, true},
+	}
+	for _, tc := range tests {
+		err := GetCreateOptionSchema(Nimble).ValidateUpdate(tc.opts)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestCreateOptionHelp(t *testing.T) {
+	help := GetCreateOptionSchema(Nimble).Help()
+	if !strings.Contains(help, "-o cloneOf=<string>") {
+		t.Errorf("expected help to describe cloneOf, got %s", help)
+	}
+	if strings.Contains(help, DelayedCreateOpt) {
+		t.Errorf("expected help to hide internal options, got %s", help)
+	}
+	if !IsHelpRequest(map[string]interface{}{"help": ""}) || IsHelpRequest(map[string]interface{}{"help": "folders"}) {
+		t.Error("unexpected result from IsHelpRequest")
+	}
+}
+
+func TestParseRemoveOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    map[string]interface{}
+		wantErr bool
+	}{
+		{"no options", nil, false},
+		{"manager only", map[string]interface{}{"manager": "k8s"}, false},
+		{"release", map[string]interface{}{"release": ""}, false},
+		{"release with name", map[string]interface{}{"release": "true", "releaseName": "vol-orig"}, false},
+		{"release with takeover", map[string]interface{}{"release": true, "takeover": "true"}, false},
+		{"unknown option", map[string]interface{}{"destroy": "true"}, false},
+		{"bad boolean", map[string]interface{}{"release": "maybe"}, true},
+		{"name without release", map[string]interface{}{"releaseName": "vol-orig"}, true},
+		{"empty name", map[string]interface{}{"release": "true", "releaseName": " "}, true},
+		{"takeover without release", map[string]interface{}{"takeover": "true"}, true},
+	}
+	for _, tc := range tests {
+		_, err := ParseRemoveOptions(tc.opts)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
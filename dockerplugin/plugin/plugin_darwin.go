@@ -5,6 +5,8 @@ package plugin
 var (
 	// PluginConfigDir represents config directory for plugin
 	PluginConfigDir = ""
+	// SupportedFileSystems represent filesystem types supported for formatting with our plugin
+	SupportedFileSystems = []string{}
 )
 
 // GetOrCreatePluginConfigDirectory get or create plugin config directory
@@ -169,6 +169,11 @@ func LoginAndCreateCerts(ipAddress string, username string, password string, isv
 			return err
 		}
 	}
+	// trust the array certificate from now on
+	err = cert.PinCertOfGroup(ipAddress, nimbleProviderPort, groupCert)
+	if err != nil {
+		log.Error("unable to pin the array certificate ", err.Error())
+	}
 	return nil
 }
 
@@ -0,0 +1,384 @@
+/*
+(c) Copyright 2017 Hewlett Packard Enterprise Development LP
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jconfig
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// configTag names the key of a struct field, the json tag is used when it is absent
+	configTag = "config"
+	// validateTag lists the comma separated rules of a struct field: required, min=N, max=N and
+	// oneof=a|b|c.  min and max bound numbers and durations by value, strings and slices by length.
+	validateTag = "validate"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// KeyError describes a key that could not be decoded or failed validation
+type KeyError struct {
+	Key     string
+	Message string
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("key:%v %s", e.Key, e.Message)
+}
+
+// ValidationError lists every key of a config that could not be decoded or failed validation
+type ValidationError struct {
+	Errors []*KeyError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, keyErr := range e.Errors {
+		messages = append(messages, keyErr.Error())
+	}
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(messages, "; "))
+}
+
+// decoder accumulates the errors of a decode so every bad key is reported at once
+type decoder struct {
+	errors []*KeyError
+}
+
+func (d *decoder) fail(key string, format string, args ...interface{}) {
+	d.errors = append(d.errors, &KeyError{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// Decode decodes the whole config into the struct pointed to by out.  Fields are matched by their
+// config (or json) tag name, case insensitively; fields without a value keep their current value,
+// so defaults can be set before decoding.  Numbers, bools and durations ("30s", or a number of
+// seconds) are also accepted as strings, as provided by environment variables.  A
+// *ValidationError listing every bad key is returned when decoding or validation fails.
+func (c *Config) Decode(out interface{}) error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return decode("", c.config, out)
+}
+
+// DecodeKey decodes the value of the dotted key into out, see Decode
+func (c *Config) DecodeKey(key string, out interface{}) error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	value, found := lookupKey(c.config, key, true)
+	if !found {
+		return fmt.Errorf("key:%v not found", key)
+	}
+	return decode(key, value, out)
+}
+
+func decode(key string, value interface{}, out interface{}) error {
+	target := reflect.ValueOf(out)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("decode target must be a non-nil pointer, got %T", out)
+	}
+	d := &decoder{}
+	d.decodeValue(key, value, target.Elem())
+	if len(d.errors) != 0 {
+		return &ValidationError{Errors: d.errors}
+	}
+	return nil
+}
+
+// decodeValue converts value into target, recording an error for key when it cannot
+func (d *decoder) decodeValue(key string, value interface{}, target reflect.Value) {
+	if target.Type() == durationType {
+		d.decodeDuration(key, value, target)
+		return
+	}
+	switch target.Kind() {
+	case reflect.Ptr:
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		d.decodeValue(key, value, target.Elem())
+	case reflect.Interface:
+		if value != nil {
+			target.Set(reflect.ValueOf(value))
+		}
+	case reflect.Struct:
+		d.decodeStruct(key, value, target)
+	case reflect.Map:
+		d.decodeMap(key, value, target)
+	case reflect.Slice:
+		d.decodeSlice(key, value, target)
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			target.SetString(v)
+		case map[string]interface{}, []interface{}:
+			d.fail(key, "is not a string.  value:%v", value)
+		default:
+			target.SetString(fmt.Sprintf("%v", v))
+		}
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			target.SetBool(v)
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				d.fail(key, "is not a bool.  value:%v", value)
+				return
+			}
+			target.SetBool(b)
+		default:
+			d.fail(key, "is not a bool.  value:%v", value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := toFloat(value)
+		if !ok || f != math.Trunc(f) {
+			d.fail(key, "is not an integer.  value:%v", value)
+			return
+		}
+		if target.OverflowInt(int64(f)) || f > math.MaxInt64 || f < math.MinInt64 {
+			d.fail(key, "is out of range for %s.  value:%v", target.Type(), value)
+			return
+		}
+		target.SetInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, ok := toFloat(value)
+		if !ok || f != math.Trunc(f) || f < 0 {
+			d.fail(key, "is not an unsigned integer.  value:%v", value)
+			return
+		}
+		if f > math.MaxUint64 || target.OverflowUint(uint64(f)) {
+			d.fail(key, "is out of range for %s.  value:%v", target.Type(), value)
+			return
+		}
+		target.SetUint(uint64(f))
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat(value)
+		if !ok {
+			d.fail(key, "is not a number.  value:%v", value)
+			return
+		}
+		target.SetFloat(f)
+	default:
+		d.fail(key, "cannot be decoded into %s", target.Type())
+	}
+}
+
+// decodeDuration accepts a duration string such as "1m30s" or a number of seconds
+func (d *decoder) decodeDuration(key string, value interface{}, target reflect.Value) {
+	if s, ok := value.(string); ok {
+		if duration, err := time.ParseDuration(s); err == nil {
+			target.SetInt(int64(duration))
+			return
+		}
+	}
+	seconds, ok := toFloat(value)
+	if !ok {
+		d.fail(key, "is not a duration.  value:%v", value)
+		return
+	}
+	target.SetInt(int64(seconds * float64(time.Second)))
+}
+
+func (d *decoder) decodeStruct(key string, value interface{}, target reflect.Value) {
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		d.fail(key, "is not an object.  value:%v", value)
+		return
+	}
+	targetType := target.Type()
+	for i := 0; i < targetType.NumField(); i++ {
+		field := targetType.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		name := fieldName(field)
+		if name == "-" {
+			continue
+		}
+		fieldKey := joinKey(key, name)
+		existingKey := findKey(values, name)
+		fieldValue, found := values[existingKey]
+		found = found && existingKey != "" && fieldValue != nil
+		if found {
+			before := len(d.errors)
+			d.decodeValue(fieldKey, fieldValue, target.Field(i))
+			if len(d.errors) != before {
+				continue // do not validate a value that failed to decode
+			}
+		}
+		d.validate(fieldKey, field.Tag.Get(validateTag), found, target.Field(i))
+	}
+}
+
+func (d *decoder) decodeMap(key string, value interface{}, target reflect.Value) {
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		d.fail(key, "is not an object.  value:%v", value)
+		return
+	}
+	if target.Type().Key().Kind() != reflect.String {
+		d.fail(key, "cannot be decoded into %s", target.Type())
+		return
+	}
+	if target.IsNil() {
+		target.Set(reflect.MakeMap(target.Type()))
+	}
+	for k, v := range values {
+		elem := reflect.New(target.Type().Elem()).Elem()
+		d.decodeValue(joinKey(key, k), v, elem)
+		target.SetMapIndex(reflect.ValueOf(k).Convert(target.Type().Key()), elem)
+	}
+}
+
+// decodeSlice accepts an array, or a comma separated string as provided by environment variables
+func (d *decoder) decodeSlice(key string, value interface{}, target reflect.Value) {
+	var values []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		values = v
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	default:
+		d.fail(key, "is not a slice.  value:%v", value)
+		return
+	}
+	slice := reflect.MakeSlice(target.Type(), len(values), len(values))
+	for i, v := range values {
+		d.decodeValue(fmt.Sprintf("%s[%d]", key, i), v, slice.Index(i))
+	}
+	target.Set(slice)
+}
+
+// validate applies the validate tag rules to a decoded field
+func (d *decoder) validate(key, rules string, found bool, field reflect.Value) {
+	if rules == "" {
+		return
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		switch name {
+		case "required":
+			if !found {
+				d.fail(key, "is required")
+				return
+			}
+		case "min", "max":
+			if !found {
+				continue
+			}
+			measure, bound, err := measureField(field, arg)
+			if err != nil {
+				d.fail(key, "has an invalid %s rule, %s", name, err.Error())
+			} else if name == "min" && measure < bound {
+				d.fail(key, "must be at least %s.  value:%v", arg, field.Interface())
+			} else if name == "max" && measure > bound {
+				d.fail(key, "must be at most %s.  value:%v", arg, field.Interface())
+			}
+		case "oneof":
+			if !found {
+				continue
+			}
+			allowed := strings.Split(arg, "|")
+			actual := fmt.Sprintf("%v", field.Interface())
+			valid := false
+			for _, a := range allowed {
+				if actual == a {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				d.fail(key, "must be one of %s.  value:%v", strings.Join(allowed, ", "), actual)
+			}
+		default:
+			d.fail(key, "has an unknown validation rule %s", rule)
+		}
+	}
+}
+
+// measureField returns the value compared by min and max, and the parsed bound
+func measureField(field reflect.Value, arg string) (float64, float64, error) {
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return 0, 0, nil
+		}
+		field = field.Elem()
+	}
+	if field.Type() == durationType {
+		bound, err := time.ParseDuration(arg)
+		return float64(field.Int()), float64(bound), err
+	}
+	bound, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(field.Int()), bound, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(field.Uint()), bound, nil
+	case reflect.Float32, reflect.Float64:
+		return field.Float(), bound, nil
+	case reflect.String, reflect.Slice, reflect.Map:
+		return float64(field.Len()), bound, nil
+	}
+	return 0, 0, fmt.Errorf("not supported for %s", field.Type())
+}
+
+// fieldName returns the config key of a struct field
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{configTag, "json"} {
+		if name := strings.Split(field.Tag.Get(tag), ",")[0]; name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + KeySeparator + key
+}
+
+// toFloat converts a JSON number or a numeric string
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
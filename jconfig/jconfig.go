@@ -22,6 +22,7 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"sync"
 )
 
 const (
@@ -33,25 +34,44 @@ const (
 	BoolType = "bool"
 )
 
-// Config contains a map loaded from t a json file
+// Config contains a map loaded from a json file, or merged from layered sources.  Nested values
+// can be looked up with dotted keys, e.g. "global.mountConflictDelay".
 type Config struct {
-	config map[string]interface{}
+	mutex       sync.RWMutex
+	reloadMutex sync.Mutex // serializes reloads so an older load never replaces a newer one
+	config      map[string]interface{}
+	sources     []Source
+	callbacks   []ChangeCallback
 }
 
 //NewConfig loads the JSON in the file referred to in the path
 func NewConfig(path string) (*Config, error) {
-	c := &Config{}
+	config, err := loadJSONFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{config: config, sources: []Source{NewFileSource(path, false)}}, nil
+}
+
+// loadJSONFile decodes the JSON object in the file referred to in the path
+func loadJSONFile(path string) (config map[string]interface{}, err error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	if file != nil {
-		defer file.Close()
-		if err := json.NewDecoder(file).Decode(&c.config); err != nil {
-			return nil, err
-		}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return nil, err
 	}
-	return c, nil
+	return config, nil
+}
+
+// lookup returns the value of key.  A key present as is wins, otherwise the dotted segments of the
+// key walk down nested objects.  Keys are matched exactly.
+func (c *Config) lookup(key string) (interface{}, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return lookupKey(c.config, key, false)
 }
 
 //GetString returns the string value loaded from the JSON (backward compatibility)
@@ -62,12 +82,12 @@ func (c *Config) GetString(key string) (s string) {
 
 //GetStringWithError returns the string value loaded from the JSON
 func (c *Config) GetStringWithError(key string) (s string, err error) {
-	if _, found := c.config[key]; found {
-		switch value := c.config[key].(type) {
+	if configValue, found := c.lookup(key); found {
+		switch value := configValue.(type) {
 		case string:
 			return value, nil
 		default:
-			return fmt.Sprintf("%v", c.config[key]), nil
+			return fmt.Sprintf("%v", configValue), nil
 		}
 	}
 	return s, fmt.Errorf("key:%v not found", key)
@@ -81,8 +101,8 @@ func (c *Config) GetStringSlice(key string) (strings []string) {
 
 //GetMapSlice returns map of  strings and interface with error
 func (c *Config) GetMapSlice(key string) (maps []map[string]interface{}, err error) {
-	if _, found := c.config[key]; found {
-		switch values := c.config[key].(type) {
+	if configValue, found := c.lookup(key); found {
+		switch values := configValue.(type) {
 		case []interface{}:
 			for _, value := range values {
 				v := reflect.ValueOf(value)
@@ -102,8 +122,8 @@ func (c *Config) GetMapSlice(key string) (maps []map[string]interface{}, err err
 //GetMap returns map of  string and interface with error
 func (c *Config) GetMap(key string) (keyMap map[string]interface{}, err error) {
 	keyMap = make(map[string]interface{})
-	if _, found := c.config[key]; found {
-		switch value := c.config[key].(type) {
+	if configValue, found := c.lookup(key); found {
+		switch value := configValue.(type) {
 		case interface{}:
 			v := reflect.ValueOf(value)
 			if v.Kind() == reflect.Map {
@@ -187,15 +207,15 @@ func getStringType(value interface{}) (string, error) {
 
 //GetStringSliceWithError returns the string value loaded from the JSON
 func (c *Config) GetStringSliceWithError(key string) (strings []string, err error) {
-	if _, found := c.config[key]; found {
-		switch value := c.config[key].(type) {
+	if configValue, found := c.lookup(key); found {
+		switch value := configValue.(type) {
 		case []interface{}:
 			for _, d := range value {
 				strings = append(strings, fmt.Sprintf("%v", d))
 			}
 			return strings, nil
 		default:
-			return strings, fmt.Errorf("key:%v is not a slice.  value:%v kind:%s type:%s", key, c.config[key], reflect.TypeOf(c.config[key]).Kind(), reflect.TypeOf(c.config[key]))
+			return strings, fmt.Errorf("key:%v is not a slice.  value:%v kind:%s type:%s", key, configValue, reflect.TypeOf(configValue).Kind(), reflect.TypeOf(configValue))
 		}
 	}
 	return strings, fmt.Errorf("key:%v not found", key)
@@ -209,8 +229,8 @@ func (c *Config) GetInt64(key string) (i int64) {
 
 //GetInt64SliceWithError returns the value in the JSON cast to int64
 func (c *Config) GetInt64SliceWithError(key string) (i int64, err error) {
-	if _, found := c.config[key]; found {
-		switch value := c.config[key].(type) {
+	if configValue, found := c.lookup(key); found {
+		switch value := configValue.(type) {
 		//json marshall stores numbers as floats
 		case float64:
 			return int64(value), nil
@@ -218,7 +238,7 @@ func (c *Config) GetInt64SliceWithError(key string) (i int64, err error) {
 		case string:
 			return strconv.ParseInt(value, 10, 64)
 		default:
-			return 0, fmt.Errorf("key:%v is not a number.  value:%v kind:%s type:%s", key, c.config[key], reflect.TypeOf(c.config[key]).Kind(), reflect.TypeOf(c.config[key]))
+			return 0, fmt.Errorf("key:%v is not a number.  value:%v kind:%s type:%s", key, configValue, reflect.TypeOf(configValue).Kind(), reflect.TypeOf(configValue))
 		}
 	}
 	return 0, fmt.Errorf("key:%v not found", key)
@@ -226,15 +246,15 @@ func (c *Config) GetInt64SliceWithError(key string) (i int64, err error) {
 
 //GetBool returns the value in the JSON cast to bool
 func (c *Config) GetBool(key string) (b bool, err error) {
-	if _, found := c.config[key]; found {
-		switch value := c.config[key].(type) {
+	if configValue, found := c.lookup(key); found {
+		switch value := configValue.(type) {
 		case bool:
 			return bool(value), nil
 		//we can always try to parse a string
 		case string:
 			return strconv.ParseBool(value)
 		default:
-			return false, fmt.Errorf("key:%v is not a bool.  value:%v kind:%s type:%s", key, c.config[key], reflect.TypeOf(c.config[key]).Kind(), reflect.TypeOf(c.config[key]))
+			return false, fmt.Errorf("key:%v is not a bool.  value:%v kind:%s type:%s", key, configValue, reflect.TypeOf(configValue).Kind(), reflect.TypeOf(configValue))
 		}
 	}
 	return false, fmt.Errorf("key:%v not found", key)
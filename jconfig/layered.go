@@ -0,0 +1,291 @@
+/*
+(c) Copyright 2017 Hewlett Packard Enterprise Development LP
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jconfig
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// KeySeparator separates the levels of a nested key, e.g. "global.mountConflictDelay"
+	KeySeparator = "."
+	// EnvKeySeparator separates the levels of a nested key in an environment variable name,
+	// e.g. HPE_GLOBAL__MOUNTCONFLICTDELAY
+	EnvKeySeparator = "__"
+)
+
+// Source provides one layer of configuration
+type Source interface {
+	// Name describes the source in errors and logs
+	Name() string
+	// Load returns the configuration of the source, nested objects are map[string]interface{}
+	Load() (map[string]interface{}, error)
+}
+
+// MapSource is a layer of configuration held in memory, used for defaults and overrides
+type MapSource struct {
+	name   string
+	config map[string]interface{}
+}
+
+// NewMapSource returns a source providing the given configuration, dotted keys are expanded into
+// nested objects
+func NewMapSource(name string, config map[string]interface{}) *MapSource {
+	return &MapSource{name: name, config: config}
+}
+
+// Name of the source
+func (s *MapSource) Name() string {
+	return s.name
+}
+
+// Load returns a copy of the configuration
+func (s *MapSource) Load() (map[string]interface{}, error) {
+	config := make(map[string]interface{})
+	for key, value := range s.config {
+		setKey(config, key, value)
+	}
+	return config, nil
+}
+
+// FileSource is a layer of configuration read from a JSON file
+type FileSource struct {
+	Path     string
+	Optional bool // a missing file is an empty layer rather than an error
+}
+
+// NewFileSource returns a source reading the JSON file at path
+func NewFileSource(path string, optional bool) *FileSource {
+	return &FileSource{Path: path, Optional: optional}
+}
+
+// Name of the source
+func (s *FileSource) Name() string {
+	return "file " + s.Path
+}
+
+// Load reads the file
+func (s *FileSource) Load() (map[string]interface{}, error) {
+	config, err := loadJSONFile(s.Path)
+	if err != nil {
+		if s.Optional && os.IsNotExist(err) {
+			return map[string]interface{}{}, nil
+		}
+		return nil, err
+	}
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	return config, nil
+}
+
+// EnvSource is a layer of configuration read from environment variables with a prefix.  The rest
+// of the variable name is the key, with EnvKeySeparator separating nested levels.  Segments match
+// the keys of lower layers case insensitively, so HPE_GLOBAL__MOUNTCONFLICTDELAY overrides
+// "global.mountConflictDelay".  Values are strings and are converted when decoded.
+type EnvSource struct {
+	Prefix  string
+	environ func() []string
+}
+
+// NewEnvSource returns a source reading the environment variables starting with prefix
+func NewEnvSource(prefix string) *EnvSource {
+	return &EnvSource{Prefix: prefix, environ: os.Environ}
+}
+
+// Name of the source
+func (s *EnvSource) Name() string {
+	return "environment " + s.Prefix + "*"
+}
+
+// Load reads the environment
+func (s *EnvSource) Load() (map[string]interface{}, error) {
+	config := make(map[string]interface{})
+	for _, env := range s.environ() {
+		pair := strings.SplitN(env, "=", 2)
+		if len(pair) != 2 || !strings.HasPrefix(pair[0], s.Prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(pair[0], s.Prefix)
+		if name == "" {
+			continue
+		}
+		setKey(config, strings.Replace(name, EnvKeySeparator, KeySeparator, -1), pair[1])
+	}
+	return config, nil
+}
+
+// NewLayeredConfig merges the sources in order, later sources override earlier ones, e.g.
+// defaults, file, environment and overrides.  Objects are merged key by key, other values
+// are replaced.
+func NewLayeredConfig(sources ...Source) (*Config, error) {
+	c := &Config{sources: sources}
+	config, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	c.config = config
+	return c, nil
+}
+
+// load merges the sources of the config
+func (c *Config) load() (map[string]interface{}, error) {
+	config := make(map[string]interface{})
+	for _, source := range c.sources {
+		layer, err := source.Load()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load %s, %s", source.Name(), err.Error())
+		}
+		merge(config, layer)
+	}
+	return config, nil
+}
+
+// Get returns the value of the dotted key, nested objects are returned as map[string]interface{}
+func (c *Config) Get(key string) (interface{}, bool) {
+	return c.lookup(key)
+}
+
+// Keys returns every leaf key of the config in dotted form, sorted
+func (c *Config) Keys() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var keys []string
+	collectKeys(c.config, "", &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+// merge copies layer into config, merging nested objects and matching keys case insensitively
+func merge(config, layer map[string]interface{}) {
+	for key, value := range layer {
+		existingKey := findKey(config, key)
+		if existingKey == "" {
+			existingKey = key
+		}
+		if layerMap, ok := value.(map[string]interface{}); ok {
+			if configMap, ok := config[existingKey].(map[string]interface{}); ok {
+				merge(configMap, layerMap)
+				continue
+			}
+			copied := make(map[string]interface{})
+			merge(copied, layerMap)
+			value = copied
+		}
+		config[existingKey] = value
+	}
+}
+
+// findKey returns the key of config matching key exactly, or else case insensitively
+func findKey(config map[string]interface{}, key string) string {
+	if _, ok := config[key]; ok {
+		return key
+	}
+	for existing := range config {
+		if strings.EqualFold(existing, key) {
+			return existing
+		}
+	}
+	return ""
+}
+
+// lookupKey returns the value of key in config, trying the key as is before walking down its
+// dotted segments.  With foldCase the segments are matched case insensitively when there is no
+// exact match, which is only done for decoding so the getters keep matching keys exactly.
+func lookupKey(config map[string]interface{}, key string, foldCase bool) (interface{}, bool) {
+	if value, ok := config[key]; ok {
+		return value, true
+	}
+	var current interface{} = config
+	for _, segment := range strings.Split(key, KeySeparator) {
+		currentMap, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		existingKey := segment
+		if foldCase {
+			existingKey = findKey(currentMap, segment)
+		}
+		value, ok := currentMap[existingKey]
+		if !ok {
+			return nil, false
+		}
+		current = value
+	}
+	return current, true
+}
+
+// setKey sets the dotted key in config, creating (or matching case insensitively) the nested
+// objects on the way
+func setKey(config map[string]interface{}, key string, value interface{}) {
+	segments := strings.Split(key, KeySeparator)
+	current := config
+	for _, segment := range segments[:len(segments)-1] {
+		existingKey := findKey(current, segment)
+		if existingKey == "" {
+			existingKey = segment
+		}
+		next, ok := current[existingKey].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			current[existingKey] = next
+		}
+		current = next
+	}
+	last := segments[len(segments)-1]
+	if existingKey := findKey(current, last); existingKey != "" {
+		last = existingKey
+	}
+	current[last] = value
+}
+
+// collectKeys appends the dotted leaf keys of config
+func collectKeys(config map[string]interface{}, prefix string, keys *[]string) {
+	for key, value := range config {
+		if valueMap, ok := value.(map[string]interface{}); ok && len(valueMap) != 0 {
+			collectKeys(valueMap, prefix+key+KeySeparator, keys)
+			continue
+		}
+		*keys = append(*keys, prefix+key)
+	}
+}
+
+// changedKeys returns the sorted dotted leaf keys that differ between the two configs
+func changedKeys(before, after map[string]interface{}) []string {
+	var beforeKeys, afterKeys []string
+	collectKeys(before, "", &beforeKeys)
+	collectKeys(after, "", &afterKeys)
+	seen := make(map[string]bool)
+	var changed []string
+	for _, key := range append(beforeKeys, afterKeys...) {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		beforeValue, beforeFound := lookupKey(before, key, false)
+		afterValue, afterFound := lookupKey(after, key, false)
+		if beforeFound != afterFound || !reflect.DeepEqual(beforeValue, afterValue) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,269 @@
+/*
+(c) Copyright 2017 Hewlett Packard Enterprise Development LP
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testGlobal struct {
+	MountConflictDelay time.Duration `json:"mountConflictDelay" validate:"min=1s"`
+	LogLevel           string        `json:"logLevel" validate:"required,oneof=info|debug|trace"`
+}
+
+type testConfig struct {
+	Global  testGlobal        `json:"global"`
+	Retries int               `json:"retries" validate:"min=0,max=10"`
+	Enabled bool              `json:"enabled"`
+	Hosts   []string          `json:"hosts" validate:"min=1"`
+	Labels  map[string]string `json:"labels"`
+	Name    string            `config:"name" validate:"required"`
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestEnvSource(prefix string, env ...string) *EnvSource {
+	s := NewEnvSource(prefix)
+	s.environ = func() []string { return env }
+	return s
+}
+
+func TestLayeredConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeFile(t, path, `{"global": {"mountConflictDelay": "30s", "logLevel": "info"}, "retries": 3, "hosts": ["a", "b"]}`)
+
+	c, err := NewLayeredConfig(
+		NewMapSource("defaults", map[string]interface{}{"global.logLevel": "debug", "retries": 1, "name": "defaults"}),
+		NewFileSource(path, false),
+		NewFileSource(filepath.Join(t.TempDir(), "missing.json"), true),
+		newTestEnvSource("HPE_", "HPE_GLOBAL__LOGLEVEL=trace", "HPE_ENABLED=true", "OTHER=1"),
+		NewMapSource("overrides", map[string]interface{}{"name": "override"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"global.mountConflictDelay", "30s"},
+		{"global.logLevel", "trace"},
+		{"retries", "3"},
+		{"ENABLED", "true"},
+		{"enabled", ""},
+		{"GLOBAL.LOGLEVEL", ""},
+		{"name", "override"},
+		{"global.missing", ""},
+	}
+	for _, tc := range tests {
+		if value := c.GetString(tc.key); value != tc.expected {
+			t.Errorf("key %v expected %v, got %v", tc.key, tc.expected, value)
+		}
+	}
+
+	// Keys only set by the environment keep the case of the variable name
+	expectedKeys := []string{"ENABLED", "global.logLevel", "global.mountConflictDelay", "hosts", "name", "retries"}
+	if keys := c.Keys(); !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("expected keys %v, got %v", expectedKeys, keys)
+	}
+
+	if _, err = NewLayeredConfig(NewFileSource(filepath.Join(t.TempDir(), "missing.json"), false)); err == nil {
+		t.Error("expected an error for a missing required file")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	c, err := NewLayeredConfig(
+		NewMapSource("defaults", map[string]interface{}{
+			"global":  map[string]interface{}{"mountConflictDelay": 5.0, "logLevel": "info"},
+			"retries": 2.0,
+			"hosts":   []interface{}{"a"},
+			"labels":  map[string]interface{}{"zone": "east"},
+			"name":    "host",
+		}),
+		newTestEnvSource("HPE_", "HPE_RETRIES=4", "HPE_ENABLED=1", "HPE_HOSTS=x, y"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config testConfig
+	if err = c.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	expected := testConfig{
+		Global:  testGlobal{MountConflictDelay: 5 * time.Second, LogLevel: "info"},
+		Retries: 4,
+		Enabled: true,
+		Hosts:   []string{"x", "y"},
+		Labels:  map[string]string{"zone": "east"},
+		Name:    "host",
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %+v, got %+v", expected, config)
+	}
+
+	var global testGlobal
+	if err = c.DecodeKey("global", &global); err != nil {
+		t.Fatal(err)
+	}
+	if global != expected.Global {
+		t.Errorf("expected %+v, got %+v", expected.Global, global)
+	}
+	if err = c.DecodeKey("missing", &global); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+}
+
+func TestDecodeValidation(t *testing.T) {
+	c, err := NewLayeredConfig(NewMapSource("bad", map[string]interface{}{
+		"global.mountConflictDelay": "10ms",
+		"global.logLevel":           "loud",
+		"retries":                   "many",
+		"enabled":                   "maybe",
+		"hosts":                     []interface{}{},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config testConfig
+	err = c.Decode(&config)
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+	var keys []string
+	for _, keyErr := range validationErr.Errors {
+		keys = append(keys, keyErr.Key)
+	}
+	expectedKeys := []string{"global.mountConflictDelay", "global.logLevel", "retries", "enabled", "hosts", "name"}
+	if !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("expected errors for %v, got %v", expectedKeys, keys)
+	}
+	for _, key := range expectedKeys {
+		if !strings.Contains(err.Error(), "key:"+key+" ") {
+			t.Errorf("expected error message to mention %v, got %v", key, err.Error())
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeFile(t, path, `{"global": {"logLevel": "info"}, "retries": 1}`)
+
+	c, err := NewLayeredConfig(NewFileSource(path, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan []string, 10)
+	c.OnChange(func(c *Config, changedKeys []string) {
+		changes <- changedKeys
+	})
+	w, err := c.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	writeFile(t, path, `{"global": {"logLevel": "debug"}, "retries": 1}`)
+	select {
+	case changed := <-changes:
+		if !reflect.DeepEqual(changed, []string{"global.logLevel"}) {
+			t.Errorf("expected global.logLevel to change, got %v", changed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the config to reload")
+	}
+	if value := c.GetString("global.logLevel"); value != "debug" {
+		t.Errorf("expected reloaded value debug, got %v", value)
+	}
+
+	// A broken file keeps the current config
+	if err = os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Reload(); err == nil {
+		t.Error("expected an error reloading a broken file")
+	}
+	if value := c.GetString("global.logLevel"); value != "debug" {
+		t.Errorf("expected current value debug to be kept, got %v", value)
+	}
+}
+
+// gatedSource returns an increasing version on each load, the first reload is held until released
+type gatedSource struct {
+	lock     sync.Mutex
+	loads    int
+	loading  chan struct{}
+	released chan struct{}
+}
+
+func (s *gatedSource) Name() string {
+	return "gated"
+}
+
+func (s *gatedSource) Load() (map[string]interface{}, error) {
+	s.lock.Lock()
+	s.loads++
+	version := s.loads
+	s.lock.Unlock()
+	if version == 2 {
+		close(s.loading)
+		<-s.released
+	}
+	return map[string]interface{}{"version": float64(version)}, nil
+}
+
+func TestReloadSerialized(t *testing.T) {
+	source := &gatedSource{loading: make(chan struct{}), released: make(chan struct{})}
+	c, err := NewLayeredConfig(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second reload loads a newer version while the first one is held
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		c.Reload()
+	}()
+	<-source.loading
+	go func() {
+		defer wg.Done()
+		c.Reload()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(source.released)
+	wg.Wait()
+
+	if version := c.GetInt64("version"); version != 3 {
+		t.Errorf("expected the latest version 3 to be kept, got %v", version)
+	}
+}
@@ -0,0 +1,137 @@
+/*
+(c) Copyright 2017 Hewlett Packard Enterprise Development LP
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jconfig
+
+import (
+	"path/filepath"
+	"sync"
+
+	notify "github.com/fsnotify/fsnotify"
+	log "github.com/hpe-storage/common-host-libs/logger"
+)
+
+// ChangeCallback is called after a reload changed the config, with the dotted keys that changed
+type ChangeCallback func(c *Config, changedKeys []string)
+
+// OnChange registers a callback called whenever a reload changes the config
+func (c *Config) OnChange(callback ChangeCallback) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.callbacks = append(c.callbacks, callback)
+}
+
+// Reload reloads every source and notifies the change callbacks when the config changed.  The
+// current config is kept when a source fails to load.  Concurrent reloads are serialized.
+func (c *Config) Reload() error {
+	c.reloadMutex.Lock()
+	config, err := c.load()
+	if err != nil {
+		c.reloadMutex.Unlock()
+		log.Errorf("unable to reload config, keeping the current config, %s", err.Error())
+		return err
+	}
+
+	c.mutex.Lock()
+	changed := changedKeys(c.config, config)
+	c.config = config
+	callbacks := append([]ChangeCallback{}, c.callbacks...)
+	c.mutex.Unlock()
+	c.reloadMutex.Unlock()
+
+	if len(changed) == 0 {
+		return nil
+	}
+	log.Infof("config reloaded, changed keys %v", changed)
+	for _, callback := range callbacks {
+		callback(c, changed)
+	}
+	return nil
+}
+
+// Watcher reloads a config when one of its files changes
+type Watcher struct {
+	watcher *notify.Watcher
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Watch starts reloading the config whenever one of its file sources is written, created, renamed
+// or removed.  The directory of each file is watched so that files replaced by a rename, as
+// editors and config management tools do, keep being watched.
+func (c *Config) Watch() (*Watcher, error) {
+	log.Trace(">>>>> Watch")
+	defer log.Trace("<<<<< Watch")
+
+	watcher, err := notify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]bool)
+	for _, source := range c.sources {
+		fileSource, ok := source.(*FileSource)
+		if !ok {
+			continue
+		}
+		path, err := filepath.Abs(fileSource.Path)
+		if err != nil {
+			watcher.Close()
+			return nil, err
+		}
+		if !files[path] {
+			if err = watcher.Add(filepath.Dir(path)); err != nil {
+				watcher.Close()
+				return nil, err
+			}
+			files[path] = true
+		}
+	}
+
+	w := &Watcher{watcher: watcher, done: make(chan struct{})}
+	go func() {
+		for {
+			select {
+			case <-w.done:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				path, _ := filepath.Abs(event.Name)
+				if files[path] && event.Op&(notify.Write|notify.Create|notify.Rename|notify.Remove) != 0 {
+					log.Tracef("config file %s changed, %s", event.Name, event.Op)
+					c.Reload()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Errorf("config watcher error, %s", err.Error())
+			}
+		}
+	}()
+	return w, nil
+}
+
+// Stop watching the config files
+func (w *Watcher) Stop() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.watcher.Close()
+	})
+	return err
+}
@@ -46,9 +46,9 @@ func PrintPrettyJSONToConsole(data interface{}) {
 	fmt.Println(outStr) // std output
 }
 
-// PrintPrettyJSONToLog to log JSON data
+// PrintPrettyJSONToLog to log JSON data, with sensitive fields redacted
 func PrintPrettyJSONToLog(data interface{}) {
-	outStr, _ := GetPrettyJSON(data)
+	outStr, _ := GetPrettyJSON(log.Redact(data))
 	log.Traceln(outStr)
 }
 
@@ -0,0 +1,147 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package linux
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/hpe-storage/common-host-libs/logger"
+	"github.com/hpe-storage/common-host-libs/sgio"
+)
+
+// getReservationPaths returns the active scsi paths of the multipath device with the given serial
+func getReservationPaths(serial string) ([]string, error) {
+	dev, err := GetDmDeviceFromSerial(serial)
+	if err != nil {
+		return nil, err
+	}
+	if dev == nil {
+		return nil, fmt.Errorf("no multipath device found with serial %s", serial)
+	}
+	paths, err := retryGetPathOfDevice(dev, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no active paths found for multipath device %s", dev.AltFullPathName)
+	}
+	var devices []string
+	for _, path := range paths {
+		devices = append(devices, "/dev/"+path.Device)
+	}
+	return devices, nil
+}
+
+// reservationOnAllPaths issues the PERSISTENT RESERVE OUT on every active path, registrations are per I_T nexus
+func reservationOnAllPaths(serial string, params *sgio.PROutParams) error {
+	paths, err := getReservationPaths(serial)
+	if err != nil {
+		return err
+	}
+	var failed []error
+	for _, path := range paths {
+		if err = sgio.PersistentReserveOut(path, params); err != nil {
+			log.Errorf("persistent reserve out 0x%x failed on %s of %s, %s", params.ServiceAction, path, serial, err.Error())
+			failed = append(failed, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+	if len(failed) != 0 {
+		// the path errors are wrapped so callers can still tell a reservation conflict apart
+		return fmt.Errorf("persistent reserve out failed on %d of %d paths of %s: %w", len(failed), len(paths), serial, errors.Join(failed...))
+	}
+	return nil
+}
+
+// reservationOnAnyPath issues the PERSISTENT RESERVE OUT through the first path that completes it, a reservation
+// conflict is returned immediately as another path would get the same answer
+func reservationOnAnyPath(serial string, params *sgio.PROutParams) error {
+	paths, err := getReservationPaths(serial)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		err = sgio.PersistentReserveOut(path, params)
+		if err == nil {
+			return nil
+		}
+		log.Errorf("persistent reserve out 0x%x failed on %s of %s, %s", params.ServiceAction, path, serial, err.Error())
+		var commandErr *sgio.CommandError
+		if errors.As(err, &commandErr) && commandErr.IsReservationConflict() {
+			return err
+		}
+	}
+	return err
+}
+
+// GetPersistentReservation returns the registered keys and the reservation of the multipath device with the given serial
+func GetPersistentReservation(serial string) (*sgio.PRKeys, *sgio.PRReservation, error) {
+	log.Tracef(">>>>> GetPersistentReservation called for %s", serial)
+	defer log.Trace("<<<<< GetPersistentReservation")
+	paths, err := getReservationPaths(serial)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, path := range paths {
+		var keys *sgio.PRKeys
+		var reservation *sgio.PRReservation
+		keys, err = sgio.PersistentReserveReadKeys(path)
+		if err == nil {
+			reservation, err = sgio.PersistentReserveReadReservation(path)
+			if err == nil {
+				return keys, reservation, nil
+			}
+		}
+		log.Errorf("unable to read persistent reservation through %s of %s, %s", path, serial, err.Error())
+	}
+	return nil, nil, err
+}
+
+// RegisterReservationKey registers the key on every path of the multipath device, replacing any key already registered
+func RegisterReservationKey(serial string, key uint64) error {
+	log.Tracef(">>>>> RegisterReservationKey called for %s", serial)
+	defer log.Trace("<<<<< RegisterReservationKey")
+	if key == 0 {
+		return fmt.Errorf("reservation key must be non-zero")
+	}
+	return reservationOnAllPaths(serial, &sgio.PROutParams{ServiceAction: sgio.PROutRegisterAndIgnoreKey, ServiceActionKey: key})
+}
+
+// UnregisterReservationKey removes the registration of every path of the multipath device
+func UnregisterReservationKey(serial string) error {
+	log.Tracef(">>>>> UnregisterReservationKey called for %s", serial)
+	defer log.Trace("<<<<< UnregisterReservationKey")
+	return reservationOnAllPaths(serial, &sgio.PROutParams{ServiceAction: sgio.PROutRegisterAndIgnoreKey})
+}
+
+// ReservePersistentReservation acquires a reservation of the given type with the registered key
+func ReservePersistentReservation(serial string, key uint64, prType uint8) error {
+	log.Tracef(">>>>> ReservePersistentReservation called for %s type %s", serial, sgio.PRTypeName(prType))
+	defer log.Trace("<<<<< ReservePersistentReservation")
+	return reservationOnAnyPath(serial, &sgio.PROutParams{ServiceAction: sgio.PROutReserve, Type: prType, Key: key})
+}
+
+// ReleasePersistentReservation releases the reservation held with the key, it is issued on every path as only the
+// holding I_T nexus can release it and a release from any other path is a no-op
+func ReleasePersistentReservation(serial string, key uint64, prType uint8) error {
+	log.Tracef(">>>>> ReleasePersistentReservation called for %s type %s", serial, sgio.PRTypeName(prType))
+	defer log.Trace("<<<<< ReleasePersistentReservation")
+	return reservationOnAllPaths(serial, &sgio.PROutParams{ServiceAction: sgio.PROutRelease, Type: prType, Key: key})
+}
+
+// PreemptPersistentReservation removes the registrations of preemptKey and takes over its reservation
+func PreemptPersistentReservation(serial string, key, preemptKey uint64, prType uint8) error {
+	log.Tracef(">>>>> PreemptPersistentReservation called for %s type %s", serial, sgio.PRTypeName(prType))
+	defer log.Trace("<<<<< PreemptPersistentReservation")
+	if preemptKey == 0 {
+		return fmt.Errorf("preempt key must be non-zero")
+	}
+	return reservationOnAnyPath(serial, &sgio.PROutParams{ServiceAction: sgio.PROutPreempt, Type: prType, Key: key, ServiceActionKey: preemptKey})
+}
+
+// ClearPersistentReservation removes the reservation and all registrations of the device
+func ClearPersistentReservation(serial string, key uint64) error {
+	log.Tracef(">>>>> ClearPersistentReservation called for %s", serial)
+	defer log.Trace("<<<<< ClearPersistentReservation")
+	return reservationOnAnyPath(serial, &sgio.PROutParams{ServiceAction: sgio.PROutClear, Key: key})
+}
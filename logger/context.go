@@ -0,0 +1,105 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package logger
+
+import (
+	"context"
+	"sync"
+
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Correlation fields added to the log lines of a request
+	RequestIDKey    = "requestId"
+	RouteKey        = "route"
+	RemoteKey       = "remote"
+	OperationKey    = "operation"
+	SerialNumberKey = "serialNumber"
+	MountIDKey      = "mountId"
+	MountPointKey   = "mountPoint"
+
+	// RequestIDHeader carries the request ID of an http request, it is generated by HTTPLogger
+	// when the caller does not provide one
+	RequestIDHeader = "X-Request-ID"
+)
+
+// contextKey is the key of the contextFields stored in a context
+type contextKey struct{}
+
+// contextFields are the fields accumulated along the call path of a context.  They are shared by
+// pointer so fields added by a handler also appear on the lines logged by the middleware that
+// created the context.
+type contextFields struct {
+	mutex  sync.RWMutex
+	fields Fields
+}
+
+func fieldsFromContext(ctx context.Context) *contextFields {
+	if ctx == nil {
+		return nil
+	}
+	cf, _ := ctx.Value(contextKey{}).(*contextFields)
+	return cf
+}
+
+// NewContext returns a child context carrying the fields of ctx along with the given fields.
+// Fields added to the child are not seen by the parent.
+func NewContext(ctx context.Context, fields Fields) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	child := &contextFields{fields: ContextFields(ctx)}
+	for key, value := range fields {
+		child.fields[key] = value
+	}
+	return context.WithValue(ctx, contextKey{}, child)
+}
+
+// AddContextFields adds fields to ctx in place, so they are also seen by every holder of ctx
+// such as the HTTPLogger middleware.  It returns false when ctx was not created by NewContext.
+func AddContextFields(ctx context.Context, fields Fields) bool {
+	cf := fieldsFromContext(ctx)
+	if cf == nil {
+		return false
+	}
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+	for key, value := range fields {
+		cf.fields[key] = value
+	}
+	return true
+}
+
+// ContextFields returns a copy of the fields accumulated in ctx
+func ContextFields(ctx context.Context) Fields {
+	fields := make(Fields)
+	cf := fieldsFromContext(ctx)
+	if cf == nil {
+		return fields
+	}
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+	for key, value := range cf.fields {
+		fields[key] = value
+	}
+	return fields
+}
+
+// FromContext creates an entry from the standard logger carrying the fields accumulated in ctx.
+//
+// Note that it doesn't log until you call Debug, Print, Info, Warn, Fatal
+// or Panic on the Entry it returns.
+func FromContext(ctx context.Context) *log.Entry {
+	entry := callerLogger(1).WithFields(ContextFields(ctx))
+	if ctx != nil {
+		entry = entry.WithContext(ctx)
+	}
+	return entry
+}
+
+// NewRequestID returns a new unique request ID
+func NewRequestID() string {
+	return uuid.NewV4().String()
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+package logger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureHook records the entries logged while it is installed
+type captureHook struct {
+	mutex   sync.Mutex
+	entries []*log.Entry
+}
+
+func (hook *captureHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (hook *captureHook) Fire(entry *log.Entry) error {
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	hook.entries = append(hook.entries, entry)
+	return nil
+}
+
+func installCaptureHook(t *testing.T) *captureHook {
+	hook := &captureHook{}
+	hooks := log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	log.AddHook(hook)
+	t.Cleanup(func() { log.StandardLogger().ReplaceHooks(hooks) })
+	return hook
+}
+
+func TestContextFields(t *testing.T) {
+	assert.Empty(t, ContextFields(context.Background()))
+	assert.False(t, AddContextFields(context.Background(), Fields{OperationKey: "op"}))
+
+	parent := NewContext(context.Background(), Fields{RequestIDKey: "1"})
+	child := NewContext(parent, Fields{SerialNumberKey: "abc"})
+	assert.Equal(t, Fields{RequestIDKey: "1"}, ContextFields(parent))
+	assert.Equal(t, Fields{RequestIDKey: "1", SerialNumberKey: "abc"}, ContextFields(child))
+
+	// Fields added in place are seen by every holder of the context, but not by its parent
+	assert.True(t, AddContextFields(child, Fields{OperationKey: "CreateDevice"}))
+	assert.Equal(t, "CreateDevice", FromContext(child).Data[OperationKey])
+	assert.NotContains(t, ContextFields(parent), OperationKey)
+}
+
+func TestHTTPLoggerSeedsContext(t *testing.T) {
+	hook := installCaptureHook(t)
+
+	var handlerFields Fields
+	handler := HTTPLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddContextFields(r.Context(), Fields{SerialNumberKey: "abc"})
+		handlerFields = ContextFields(r.Context())
+	}), "CreateDevice")
+
+	// A generated request ID is returned to the caller
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/devices", nil))
+	requestID := recorder.Header().Get(RequestIDHeader)
+	assert.NotEmpty(t, requestID)
+	assert.Equal(t, requestID, handlerFields[RequestIDKey])
+	assert.Equal(t, "CreateDevice", handlerFields[RouteKey])
+	assert.NotEmpty(t, handlerFields[RemoteKey])
+
+	// The completion line carries the fields added by the handler
+	hook.mutex.Lock()
+	last := hook.entries[len(hook.entries)-1]
+	hook.mutex.Unlock()
+	assert.Equal(t, requestID, last.Data[RequestIDKey])
+	assert.Equal(t, "abc", last.Data[SerialNumberKey])
+
+	// A request ID provided by the caller is kept
+	recorder = httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/devices", nil)
+	request.Header.Set(RequestIDHeader, "caller-id")
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, "caller-id", recorder.Header().Get(RequestIDHeader))
+	assert.Equal(t, "caller-id", handlerFields[RequestIDKey])
+}
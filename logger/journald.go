@@ -0,0 +1,112 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultJournaldSocket is the socket of the systemd journal native protocol
+const DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldHook sends log entries to the systemd journal using its native protocol, so the entry
+// fields can be queried as journal fields, e.g. journalctl SERIALNUMBER=<serial>.
+type JournaldHook struct {
+	identifier string
+	mutex      sync.Mutex
+	conn       *net.UnixConn
+}
+
+// NewJournaldHook creates a hook writing to the journal socket, entries are tagged with
+// SYSLOG_IDENTIFIER=identifier
+func NewJournaldHook(socket, identifier string) (*JournaldHook, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &JournaldHook{identifier: identifier, conn: conn}, nil
+}
+
+func (hook *JournaldHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (hook *JournaldHook) Fire(entry *log.Entry) error {
+	var payload bytes.Buffer
+	writeJournaldField(&payload, "MESSAGE", entry.Message)
+	writeJournaldField(&payload, "PRIORITY", strconv.Itoa(syslogSeverity(entry.Level)))
+	writeJournaldField(&payload, "SYSLOG_IDENTIFIER", hook.identifier)
+	writeJournaldField(&payload, "SYSLOG_PID", strconv.Itoa(os.Getpid()))
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := fmt.Sprintf("%v", entry.Data[key])
+		if err, ok := entry.Data[key].(error); ok {
+			value = err.Error()
+		}
+		writeJournaldField(&payload, journaldFieldName(key), value)
+	}
+
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	_, err := hook.conn.Write(payload.Bytes())
+	if err != nil && journaldPayloadTooLarge(err) {
+		// Entries larger than a datagram are passed to journald in a sealed memory file
+		err = sendJournaldMemfd(hook.conn, payload.Bytes())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write log entry to journald, %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// Close the journal socket
+func (hook *JournaldHook) Close() error {
+	return hook.conn.Close()
+}
+
+// writeJournaldField appends a field in the native protocol format, values containing a newline
+// are written as a little endian 64-bit length followed by the raw value
+func writeJournaldField(payload *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		payload.WriteString(name + "=" + value + "\n")
+		return
+	}
+	payload.WriteString(name + "\n")
+	binary.Write(payload, binary.LittleEndian, uint64(len(value)))
+	payload.WriteString(value + "\n")
+}
+
+// journaldFieldName converts a field name to a journal field name: upper case letters, digits
+// and underscores, not starting with an underscore (reserved for trusted fields) or a digit
+func journaldFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, key)
+	name = strings.TrimLeft(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "F_" + name
+	}
+	return name
+}
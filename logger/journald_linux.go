@@ -0,0 +1,48 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package logger
+
+import (
+	"errors"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// journaldPayloadTooLarge returns whether a write failed because the entry does not fit in a datagram
+func journaldPayloadTooLarge(err error) bool {
+	return errors.Is(err, unix.EMSGSIZE) || errors.Is(err, unix.ENOBUFS)
+}
+
+// sendJournaldMemfd writes payload to a sealed memfd and passes its descriptor to journald, which
+// reads the entry from it
+func sendJournaldMemfd(conn *net.UnixConn, payload []byte) error {
+	fd, err := unix.MemfdCreate("journald-entry", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return err
+	}
+	file := os.NewFile(uintptr(fd), "journald-entry")
+	defer file.Close()
+	if _, err = file.Write(payload); err != nil {
+		return err
+	}
+	// journald only accepts memfds that can no longer be modified
+	seals := unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE | unix.F_SEAL_SEAL
+	if _, err = unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS, seals); err != nil {
+		return err
+	}
+	// WriteMsgUnix refuses connected datagram sockets, send the descriptor on the raw socket
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rights := unix.UnixRights(fd)
+	if writeErr := raw.Write(func(socket uintptr) bool {
+		err = unix.Sendmsg(int(socket), nil, rights, nil, 0)
+		return err != unix.EAGAIN
+	}); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
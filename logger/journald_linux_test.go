@@ -0,0 +1,64 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+package logger
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestJournaldHookLargeEntry(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	hook, err := NewJournaldHook(socket, "chapid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(hook)
+	message := strings.Repeat("x", 4<<20)
+	logger.Info(message)
+
+	// The entry does not fit in a datagram, it is received as a memfd
+	oob := make([]byte, unix.CmsgSpace(4))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, oobn, _, _, err := conn.ReadMsgUnix(make([]byte, 1), oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, n)
+	messages, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("expected one control message, got %v %v", messages, err)
+	}
+	fds, err := unix.ParseUnixRights(&messages[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("expected one descriptor, got %v %v", fds, err)
+	}
+	file := os.NewFile(uintptr(fds[0]), "journald-entry")
+	defer file.Close()
+	// the descriptor shares the file offset left at the end by the hook, journald maps the file instead
+	payload, err := io.ReadAll(io.NewSectionReader(file, 0, int64(len(message))+1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := parseJournaldPayload(t, payload)
+	assert.True(t, fields["MESSAGE"] == message, "expected the whole message")
+	assert.Equal(t, "chapid", fields["SYSLOG_IDENTIFIER"])
+}
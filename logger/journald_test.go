@@ -0,0 +1,80 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// parseJournaldPayload decodes a native protocol datagram
+func parseJournaldPayload(t *testing.T, payload []byte) map[string]string {
+	fields := make(map[string]string)
+	for len(payload) > 0 {
+		line := payload
+		if i := bytes.IndexByte(payload, '\n'); i >= 0 {
+			line = payload[:i]
+			payload = payload[i+1:]
+		} else {
+			payload = nil
+		}
+		if i := bytes.IndexByte(line, '='); i >= 0 {
+			fields[string(line[:i])] = string(line[i+1:])
+			continue
+		}
+		// Binary field, little endian length followed by the value and a newline
+		if len(payload) < 8 {
+			t.Fatalf("truncated binary field %s", line)
+		}
+		length := binary.LittleEndian.Uint64(payload[:8])
+		fields[string(line)] = string(payload[8 : 8+length])
+		payload = payload[8+length+1:]
+	}
+	return fields
+}
+
+func TestJournaldHook(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	hook, err := NewJournaldHook(socket, "chapid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(hook)
+	logger.WithFields(Fields{SerialNumberKey: "abc", "_trusted": "x", "1st": "y"}).Warn("line one\nline two")
+
+	buf := make([]byte, 64<<10)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := parseJournaldPayload(t, buf[:n])
+	assert.Equal(t, "line one\nline two", fields["MESSAGE"])
+	assert.Equal(t, "4", fields["PRIORITY"])
+	assert.Equal(t, "chapid", fields["SYSLOG_IDENTIFIER"])
+	assert.Equal(t, "abc", fields["SERIALNUMBER"])
+	assert.Equal(t, "x", fields["TRUSTED"])
+	assert.Equal(t, "y", fields["F_1ST"])
+}
+
+func TestJournaldHookNoSocket(t *testing.T) {
+	_, err := NewJournaldHook(filepath.Join(t.TempDir(), "missing.sock"), "chapid")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,18 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package logger
+
+import (
+	"errors"
+	"net"
+)
+
+// journaldPayloadTooLarge returns false, there is no journald on Windows
+func journaldPayloadTooLarge(err error) bool {
+	return false
+}
+
+// sendJournaldMemfd is not supported on Windows
+func sendJournaldMemfd(conn *net.UnixConn, payload []byte) error {
+	return errors.New("journald is not supported on windows")
+}
@@ -0,0 +1,274 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// KnownPackages are the packages whose log level is commonly adjusted on its own, any package
+// can be given a level though.  A package is matched by its name or by a suffix of its import
+// path, e.g. "iscsi" matches both linux iSCSI and chapi2/iscsi while "chapi2/iscsi" only matches
+// the latter.
+var KnownPackages = []string{"linux", "iscsi", "multipath", "tunelinux", "connectivity"}
+
+// LevelSettings reports the log levels in effect
+type LevelSettings struct {
+	Level    log.Level            // level of the packages without a level of their own
+	Packages map[string]log.Level // levels of individual packages
+	Reverts  map[string]time.Time // pending automatic reverts, keyed by package ("" for Level)
+}
+
+// levelRevert restores a previous level when its timer fires
+type levelRevert struct {
+	timer   *time.Timer
+	at      time.Time
+	restore func() // called with levelsMutex held
+}
+
+var (
+	levelsMutex      sync.RWMutex
+	packageLoggers   = make(map[string]*log.Logger)
+	packageLevelsSet int32 // non-zero when packageLoggers is not empty, checked without locking
+	callerPackages   sync.Map
+	levelReverts     = make(map[string]*levelRevert)
+)
+
+// SetLogLevel changes the level of the packages without a level of their own.  With a non-zero
+// revertAfter the current level is restored after that duration, otherwise the change is
+// permanent and any pending revert is cancelled.
+func SetLogLevel(level string, revertAfter time.Duration) error {
+	newLevel, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	previous := log.GetLevel()
+	scheduleRevert("", revertAfter, func() {
+		setBaseLevel(previous)
+	})
+	setBaseLevel(newLevel)
+	return nil
+}
+
+// SetPackageLogLevel changes the level of a package, an empty level removes the level of the
+// package so it logs at the level of the other packages.  revertAfter behaves as in SetLogLevel.
+func SetPackageLogLevel(pkg, level string, revertAfter time.Duration) error {
+	if pkg == "" || strings.ContainsAny(pkg, " .") {
+		return fmt.Errorf("invalid package name %q", pkg)
+	}
+	var newLevel *log.Level
+	if level != "" {
+		parsed, err := log.ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		newLevel = &parsed
+	}
+
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	var previous *log.Level
+	if logger, ok := packageLoggers[pkg]; ok {
+		previousLevel := logger.GetLevel()
+		previous = &previousLevel
+	}
+	scheduleRevert(pkg, revertAfter, func() {
+		setPackageLevel(pkg, previous)
+	})
+	setPackageLevel(pkg, newLevel)
+	return nil
+}
+
+// GetLevelSettings returns the log levels in effect
+func GetLevelSettings() LevelSettings {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+	settings := LevelSettings{
+		Level:    log.GetLevel(),
+		Packages: make(map[string]log.Level),
+		Reverts:  make(map[string]time.Time),
+	}
+	for pkg, logger := range packageLoggers {
+		settings.Packages[pkg] = logger.GetLevel()
+	}
+	for key, revert := range levelReverts {
+		settings.Reverts[key] = revert.at
+	}
+	return settings
+}
+
+// ResetLogLevels removes every package level and pending revert, the base level is unchanged
+func ResetLogLevels() {
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	for key, revert := range levelReverts {
+		revert.timer.Stop()
+		delete(levelReverts, key)
+	}
+	packageLoggers = make(map[string]*log.Logger)
+	atomic.StoreInt32(&packageLevelsSet, 0)
+}
+
+// scheduleRevert arranges for restore to be called after revertAfter, called with levelsMutex
+// held.  When a revert is already pending for key its restore is kept, so that successive
+// temporary changes revert to the level in effect before the first one.
+func scheduleRevert(key string, revertAfter time.Duration, restore func()) {
+	if pending, ok := levelReverts[key]; ok {
+		pending.timer.Stop()
+		delete(levelReverts, key)
+		restore = pending.restore
+	}
+	if revertAfter <= 0 {
+		return
+	}
+	revert := &levelRevert{at: time.Now().Add(revertAfter), restore: restore}
+	revert.timer = time.AfterFunc(revertAfter, func() {
+		levelsMutex.Lock()
+		if levelReverts[key] != revert {
+			levelsMutex.Unlock()
+			return
+		}
+		delete(levelReverts, key)
+		revert.restore()
+		levelsMutex.Unlock()
+		log.WithField("package", key).Info("log level reverted")
+	})
+	levelReverts[key] = revert
+}
+
+// setBaseLevel sets the level of the standard logger, called with levelsMutex held
+func setBaseLevel(level log.Level) {
+	log.SetLevel(level)
+	logParams.Level = level.String()
+}
+
+// setPackageLevel sets or, with a nil level, removes the level of a package, called with
+// levelsMutex held
+func setPackageLevel(pkg string, level *log.Level) {
+	if level == nil {
+		delete(packageLoggers, pkg)
+	} else if logger, ok := packageLoggers[pkg]; ok {
+		logger.SetLevel(*level)
+	} else {
+		packageLoggers[pkg] = newPackageLogger(*level)
+	}
+	if len(packageLoggers) == 0 {
+		atomic.StoreInt32(&packageLevelsSet, 0)
+	} else {
+		atomic.StoreInt32(&packageLevelsSet, 1)
+	}
+}
+
+// newPackageLogger returns a logger at the level of a package, forwarding its entries to the
+// hooks of the standard logger
+func newPackageLogger(level log.Level) *log.Logger {
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.SetLevel(level)
+	logger.AddHook(forwardHook{})
+	return logger
+}
+
+// forwardHook fires the hooks of the standard logger, so package loggers write to the same
+// file, console, journal and syslog sinks
+type forwardHook struct{}
+
+func (forwardHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (forwardHook) Fire(entry *log.Entry) error {
+	var err error
+	for _, hook := range log.StandardLogger().Hooks[entry.Level] {
+		if hookErr := hook.Fire(entry); hookErr != nil && err == nil {
+			err = hookErr
+		}
+	}
+	return err
+}
+
+// callerLogger returns the logger for the caller skip frames above callerLogger, which is the
+// logger of its package when the package has a level of its own
+func callerLogger(skip int) *log.Logger {
+	if atomic.LoadInt32(&packageLevelsSet) == 0 {
+		return log.StandardLogger()
+	}
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return log.StandardLogger()
+	}
+	return loggerForPC(pc)
+}
+
+// loggerForPC returns the logger of the package of the function at pc
+func loggerForPC(pc uintptr) *log.Logger {
+	if atomic.LoadInt32(&packageLevelsSet) == 0 {
+		return log.StandardLogger()
+	}
+	path := callerPackage(pc)
+
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+	logger, match := log.StandardLogger(), ""
+	for pkg, packageLogger := range packageLoggers {
+		// The most specific package wins, e.g. "chapi2/iscsi" over "iscsi"
+		if len(pkg) > len(match) && matchPackage(path, pkg) {
+			logger, match = packageLogger, pkg
+		}
+	}
+	return logger
+}
+
+// callerPackage returns the import path of the package of the function at pc
+func callerPackage(pc uintptr) string {
+	if path, ok := callerPackages.Load(pc); ok {
+		return path.(string)
+	}
+	path := ""
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		// e.g. github.com/hpe-storage/common-host-libs/linux.(*Device).Delete.func1
+		path = fn.Name()
+		slash := strings.LastIndex(path, "/")
+		if dot := strings.Index(path[slash+1:], "."); dot >= 0 {
+			path = path[:slash+1+dot]
+		}
+	}
+	callerPackages.Store(pc, path)
+	return path
+}
+
+// matchPackage reports whether pkg is the name or a suffix of the import path
+func matchPackage(path, pkg string) bool {
+	return path == pkg || strings.HasSuffix(path, "/"+pkg)
+}
+
+// sortedPackages returns the packages of the settings, sorted
+func (s LevelSettings) sortedPackages() []string {
+	packages := make([]string, 0, len(s.Packages))
+	for pkg := range s.Packages {
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+	return packages
+}
+
+// String describes the settings, e.g. "info linux=trace iscsi=debug"
+func (s LevelSettings) String() string {
+	parts := []string{s.Level.String()}
+	for _, pkg := range s.sortedPackages() {
+		parts = append(parts, pkg+"="+s.Packages[pkg].String())
+	}
+	return strings.Join(parts, " ")
+}
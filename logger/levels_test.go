@@ -0,0 +1,98 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+package logger
+
+import (
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPackage(t *testing.T) {
+	tests := []struct {
+		path  string
+		pkg   string
+		match bool
+	}{
+		{"github.com/hpe-storage/common-host-libs/linux", "linux", true},
+		{"github.com/hpe-storage/common-host-libs/chapi2/iscsi", "iscsi", true},
+		{"github.com/hpe-storage/common-host-libs/chapi2/iscsi", "chapi2/iscsi", true},
+		{"github.com/hpe-storage/common-host-libs/iscsi", "chapi2/iscsi", false},
+		{"github.com/hpe-storage/common-host-libs/tunelinux", "linux", false},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.match, matchPackage(tc.path, tc.pkg), "%s %s", tc.path, tc.pkg)
+	}
+}
+
+func TestPackageLogLevel(t *testing.T) {
+	hook := installCaptureHook(t)
+	level := log.GetLevel()
+	log.SetLevel(log.InfoLevel)
+	defer func() {
+		ResetLogLevels()
+		log.SetLevel(level)
+	}()
+
+	Trace("filtered by the base level")
+	assert.Empty(t, hook.entries)
+	assert.False(t, IsLevelEnabled(log.TraceLevel))
+
+	// This test runs in the logger package
+	assert.Nil(t, SetPackageLogLevel("logger", "trace", 0))
+	Trace("logged at the package level")
+	WithField("key", "value").Debug("entry logged at the package level")
+	assert.True(t, IsLevelEnabled(log.TraceLevel))
+	assert.Equal(t, 2, len(hook.entries))
+	assert.Equal(t, log.InfoLevel, log.GetLevel())
+
+	// A more specific package wins
+	assert.Nil(t, SetPackageLogLevel("hpe-storage/common-host-libs/logger", "error", 0))
+	Info("filtered by the more specific package level")
+	assert.Equal(t, 2, len(hook.entries))
+
+	// Other packages are unaffected
+	assert.Nil(t, SetPackageLogLevel("hpe-storage/common-host-libs/logger", "", 0))
+	assert.Nil(t, SetPackageLogLevel("logger", "", 0))
+	assert.Nil(t, SetPackageLogLevel("linux", "trace", 0))
+	Trace("filtered again")
+	assert.Equal(t, 2, len(hook.entries))
+
+	settings := GetLevelSettings()
+	assert.Equal(t, map[string]log.Level{"linux": log.TraceLevel}, settings.Packages)
+	assert.Equal(t, "info linux=trace", settings.String())
+
+	assert.NotNil(t, SetPackageLogLevel("linux", "loud", 0))
+	assert.NotNil(t, SetPackageLogLevel("", "trace", 0))
+	assert.NotNil(t, SetLogLevel("loud", 0))
+}
+
+func TestLogLevelRevert(t *testing.T) {
+	level := log.GetLevel()
+	log.SetLevel(log.InfoLevel)
+	defer func() {
+		ResetLogLevels()
+		log.SetLevel(level)
+	}()
+
+	// Successive temporary changes revert to the level before the first one
+	assert.Nil(t, SetLogLevel("debug", time.Hour))
+	assert.Nil(t, SetLogLevel("trace", 50*time.Millisecond))
+	assert.Nil(t, SetPackageLogLevel("iscsi", "trace", 50*time.Millisecond))
+	settings := GetLevelSettings()
+	assert.Equal(t, log.TraceLevel, settings.Level)
+	assert.Contains(t, settings.Reverts, "")
+	assert.Contains(t, settings.Reverts, "iscsi")
+
+	assert.Eventually(t, func() bool {
+		settings := GetLevelSettings()
+		return settings.Level == log.InfoLevel && len(settings.Packages) == 0 && len(settings.Reverts) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// A permanent change cancels a pending revert
+	assert.Nil(t, SetLogLevel("debug", 50*time.Millisecond))
+	assert.Nil(t, SetLogLevel("warn", 0))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, log.WarnLevel, log.GetLevel())
+}
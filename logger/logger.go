@@ -3,6 +3,7 @@
 package logger
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -30,6 +31,10 @@ const (
 	MaxLogSizeLimit    = 1024 // in MB
 	JsonFormat         = "json"
 	TextFormat         = "text"
+	// DefaultSyslogBufferSize is the number of messages buffered while the syslog server is slow
+	// or unreachable
+	DefaultSyslogBufferSize = 1024
+	MaxSyslogBufferSize     = 65536
 )
 
 // LogParams to configure logging
@@ -39,6 +44,15 @@ type LogParams struct {
 	MaxFiles   int
 	MaxSizeMiB int
 	Format     string
+	// Journald also logs to the systemd journal, with the entry fields as journal fields
+	Journald bool
+	// Syslog also ships logs to a syslog server in RFC 5424 format, e.g. tcp://host:6514,
+	// udp://host:514 or unix:///dev/log
+	Syslog string
+	// SyslogTag is the syslog APP-NAME and journal SYSLOG_IDENTIFIER, defaults to the process name
+	SyslogTag string
+	// SyslogBufferSize is the number of messages buffered for the syslog server
+	SyslogBufferSize int
 }
 
 var (
@@ -88,6 +102,13 @@ func (l LogParams) isValidMaxLogSize() bool {
 	return true
 }
 
+func (l LogParams) isValidSyslogBufferSize() bool {
+	if l.SyslogBufferSize <= 0 || l.SyslogBufferSize > MaxSyslogBufferSize {
+		return false
+	}
+	return true
+}
+
 func (l LogParams) GetLevel() string {
 	if !l.isValidLevel() {
 		return DefaultLogLevel
@@ -120,6 +141,20 @@ func (l LogParams) GetLogFormat() string {
 	return l.Format
 }
 
+func (l LogParams) GetSyslogTag() string {
+	if l.SyslogTag == "" {
+		return path.Base(os.Args[0])
+	}
+	return l.SyslogTag
+}
+
+func (l LogParams) GetSyslogBufferSize() int {
+	if !l.isValidSyslogBufferSize() {
+		return DefaultSyslogBufferSize
+	}
+	return l.SyslogBufferSize
+}
+
 func (l LogParams) UseJsonFormatter() bool {
 	return l.Format == JsonFormat
 }
@@ -161,6 +196,32 @@ func updateLogParamsFromEnv() {
 	if logFormat != "" {
 		logParams.Format = logFormat
 	}
+
+	journald := os.Getenv("LOG_JOURNALD")
+	if journald != "" {
+		enabled, err := strconv.ParseBool(journald)
+		if err == nil {
+			logParams.Journald = enabled
+		}
+	}
+
+	syslog := os.Getenv("LOG_SYSLOG")
+	if syslog != "" {
+		logParams.Syslog = syslog
+	}
+
+	syslogTag := os.Getenv("LOG_SYSLOG_TAG")
+	if syslogTag != "" {
+		logParams.SyslogTag = syslogTag
+	}
+
+	syslogBufferSize := os.Getenv("LOG_SYSLOG_BUFFER_SIZE")
+	if syslogBufferSize != "" {
+		size, err := strconv.ParseInt(syslogBufferSize, 0, 0)
+		if err == nil {
+			logParams.SyslogBufferSize = int(size)
+		}
+	}
 }
 
 // Initialize logging with given params
@@ -190,6 +251,9 @@ func InitLogging(logName string, params *LogParams, alsoLogToStderr bool) (err e
 	// No output except for the hooks
 	log.SetOutput(ioutil.Discard)
 
+	// Drop the journald and syslog hooks of a previous initialization so entries are not sent twice
+	removeShippingHooks()
+
 	if logParams.GetFile() != "" {
 		err = AddFileHook()
 		if err != nil {
@@ -202,6 +266,18 @@ func InitLogging(logName string, params *LogParams, alsoLogToStderr bool) (err e
 			return err
 		}
 	}
+	if logParams.Journald {
+		err = AddJournaldHook()
+		if err != nil {
+			return err
+		}
+	}
+	if logParams.Syslog != "" {
+		err = AddSyslogHook()
+		if err != nil {
+			return err
+		}
+	}
 
 	// Set log level
 	level, err := log.ParseLevel(logParams.GetLevel())
@@ -215,6 +291,8 @@ func InitLogging(logName string, params *LogParams, alsoLogToStderr bool) (err e
 		"logLevel":        log.GetLevel().String(),
 		"logFileLocation": logParams.GetFile(),
 		"alsoLogToStderr": alsoLogToStderr,
+		"journald":        logParams.Journald,
+		"syslog":          logParams.Syslog,
 	}).Info("Initialized logging.")
 
 	return nil
@@ -236,6 +314,51 @@ func AddFileHook() error {
 	return nil
 }
 
+func AddJournaldHook() error {
+	// Write to the systemd journal
+	journaldHook, err := NewJournaldHook(DefaultJournaldSocket, logParams.GetSyslogTag())
+	if err != nil {
+		return fmt.Errorf("could not initialize logging to journald: %v", err)
+	}
+	log.AddHook(journaldHook)
+	return nil
+}
+
+func AddSyslogHook() error {
+	// Ship to the syslog server
+	syslogHook, err := NewSyslogHook(logParams.Syslog, logParams.GetSyslogTag(), logParams.GetSyslogBufferSize())
+	if err != nil {
+		return fmt.Errorf("could not initialize logging to syslog %s: %v", logParams.Syslog, err)
+	}
+	log.AddHook(syslogHook)
+	return nil
+}
+
+// removeShippingHooks removes and closes any journald and syslog hooks from the standard logger
+func removeShippingHooks() {
+	hooks := make(log.LevelHooks)
+	closed := make(map[log.Hook]bool)
+	for level, levelHooks := range log.StandardLogger().Hooks {
+		for _, hook := range levelHooks {
+			switch shippingHook := hook.(type) {
+			case *JournaldHook:
+				if !closed[hook] {
+					shippingHook.Close()
+				}
+			case *SyslogHook:
+				if !closed[hook] {
+					shippingHook.Close(syslogCloseTimeout)
+				}
+			default:
+				hooks[level] = append(hooks[level], hook)
+				continue
+			}
+			closed[hook] = true
+		}
+	}
+	log.StandardLogger().ReplaceHooks(hooks)
+}
+
 // ConsoleHook sends log entries to stdout.
 type ConsoleHook struct {
 	formatter log.Formatter
@@ -359,9 +482,10 @@ func GetLevel() log.Level {
 	return log.GetLevel()
 }
 
-// IsLevelEnabled checks if the log level of the standard logger is greater than the level param
+// IsLevelEnabled checks if the log level of the caller's package, or of the standard logger, is
+// greater than the level param
 func IsLevelEnabled(level log.Level) bool {
-	return log.IsLevelEnabled(level)
+	return callerLogger(1).IsLevelEnabled(level)
 }
 
 // AddHook adds a hook to the standard logger hooks.
@@ -371,12 +495,12 @@ func AddHook(hook log.Hook) {
 
 // WithError creates an entry from the standard logger and adds an error to it, using the value defined in ErrorKey as key.
 func WithError(err error) *log.Entry {
-	return log.WithField(log.ErrorKey, err)
+	return callerLogger(1).WithField(log.ErrorKey, err)
 }
 
 // WithContext creates an entry from the standard logger and adds a context to it.
 func WithContext(ctx context.Context) *log.Entry {
-	return log.WithContext(ctx)
+	return callerLogger(1).WithContext(ctx)
 }
 
 // WithField creates an entry from the standard logger and adds a field to
@@ -385,7 +509,7 @@ func WithContext(ctx context.Context) *log.Entry {
 // Note that it doesn't log until you call Debug, Print, Info, Warn, Fatal
 // or Panic on the Entry it returns.
 func WithField(key string, value interface{}) *log.Entry {
-	return log.WithField(key, value)
+	return callerLogger(1).WithField(key, value)
 }
 
 // WithFields creates an entry from the standard logger and adds multiple
@@ -395,7 +519,7 @@ func WithField(key string, value interface{}) *log.Entry {
 // Note that it doesn't log until you call Debug, Print, Info, Warn, Fatal
 // or Panic on the Entry it returns.
 func WithFields(fields Fields) *log.Entry {
-	return log.WithFields(fields)
+	return callerLogger(1).WithFields(fields)
 }
 
 // WithTime creats an entry from the standard logger and overrides the time of
@@ -404,36 +528,73 @@ func WithFields(fields Fields) *log.Entry {
 // Note that it doesn't log until you call Debug, Print, Info, Warn, Fatal
 // or Panic on the Entry it returns.
 func WithTime(t time.Time) *log.Entry {
-	return log.WithTime(t)
+	return callerLogger(1).WithTime(t)
 }
 
-// HTTPLogger : wrapper for http logging
+// maxLoggedBodySize bounds the request and response bodies HTTPLogger logs at trace level
+const maxLoggedBodySize = 64 << 10
+
+// HTTPLogger : wrapper for http logging.  The request context is seeded with the request ID (taken
+// from the X-Request-ID header or generated), route and remote address, see FromContext.  Fields
+// added by the handler with AddContextFields are included in the completion line.  Sensitive query
+// parameters are redacted and, at trace level, the request and response bodies are logged with
+// their sensitive fields redacted.
 func HTTPLogger(inner http.Handler, name string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		r = r.WithContext(NewContext(r.Context(), Fields{
+			RequestIDKey: requestID,
+			RouteKey:     name,
+			RemoteKey:    r.RemoteAddr,
+		}))
+		requestURI := RedactURL(r.RequestURI)
+
 		panicked := true
 		defer func() {
 			if panicked {
 				const size = 64 << 10
 				buf := make([]byte, size)
 				buf = buf[:runtime.Stack(buf, false)]
-				sourced().Errorf("HTTPLogger: panic serving %v:\n%s", name, buf)
+				sourced().WithFields(ContextFields(r.Context())).Errorf("HTTPLogger: panic serving %v:\n%s", name, buf)
 			}
 		}()
 
-		sourced().Infof(
+		sourced().WithFields(ContextFields(r.Context())).Infof(
 			">>>>> %s %s - %s",
 			r.Method,
-			r.RequestURI,
+			requestURI,
 			name,
 		)
 
+		lw := &loggingResponseWriter{ResponseWriter: w}
+		if IsLevelEnabled(log.TraceLevel) {
+			lw.body = &bytes.Buffer{}
+			if r.Body != nil {
+				head, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxLoggedBodySize))
+				r.Body = &struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+				if len(head) != 0 {
+					FromContext(r.Context()).Tracef("request body: %s", RedactJSON(head))
+				}
+			}
+		}
+
 		start := time.Now()
-		inner.ServeHTTP(w, r)
+		inner.ServeHTTP(lw, r)
 
-		sourced().Infof(
+		if lw.body != nil && lw.body.Len() != 0 {
+			FromContext(r.Context()).Tracef("response body: %s", RedactJSON(lw.body.Bytes()))
+		}
+		sourced().WithFields(ContextFields(r.Context())).WithField("status", lw.statusCode()).Infof(
 			"<<<<< %s %s - %s %s",
 			r.Method,
-			r.RequestURI,
+			requestURI,
 			name,
 			time.Since(start),
 		)
@@ -442,25 +603,56 @@ func HTTPLogger(inner http.Handler, name string) http.Handler {
 	})
 }
 
-// IsSensitive checks if the given key exists in the list of bad words (sensitive info)
+// loggingResponseWriter records the status code and, when body is set, the start of the response
+// body
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   *bytes.Buffer
+}
+
+func (w *loggingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *loggingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.body != nil && w.body.Len() < maxLoggedBodySize {
+		remaining := maxLoggedBodySize - w.body.Len()
+		if len(b) < remaining {
+			remaining = len(b)
+		}
+		w.body.Write(b[:remaining])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush supports streaming handlers
+func (w *loggingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *loggingResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// IsSensitive checks if the given key matches one of the sensitive key patterns (case-insensitive
+// substring match by default), see AddSensitivePatterns
 func IsSensitive(key string) bool {
-	// TODO: Add more sensitive words (lower-case) to this list
-	badWords := []string{
-		"x-auth-token",
-		"username",
-		"user",
-		"password",
-		"passwd",
-		"secret",
-		"token",
-		"accesskey",
-		"passphrase",
-
-	}
-	key = strings.ToLower(key)
-	for _, bad := range badWords {
-		// Perform case-insensitive and substring match
-		if strings.Contains(key, bad) {
+	sensitiveMutex.RLock()
+	defer sensitiveMutex.RUnlock()
+	for _, re := range sensitiveRegexps {
+		if re.MatchString(key) {
 			return true
 		}
 	}
@@ -495,15 +687,17 @@ func MapScrubber(m map[string]string) map[string]string {
 // sourced adds a source field to the logger that contains
 // the file name and line where the logging happened.
 func sourced() *log.Entry {
-	_, file, line, ok := runtime.Caller(2)
+	pc, file, line, ok := runtime.Caller(2)
+	logger := log.StandardLogger()
 	if !ok {
 		file = "<???>"
 		line = 1
 	} else {
 		slash := strings.LastIndex(file, "/")
 		file = file[slash+1:]
+		logger = loggerForPC(pc)
 	}
-	return log.WithField("file", fmt.Sprintf("%s:%d", file, line))
+	return logger.WithField("file", fmt.Sprintf("%s:%d", file, line))
 }
 
 // Trace logs a message at level Trace on the standard logger.
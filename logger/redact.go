@@ -0,0 +1,317 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package logger
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// RedactedValue replaces the value of sensitive fields in log output
+	RedactedValue = "**********"
+	// redactTag marks a struct field whose value is always redacted, e.g. `log:"redact"`
+	redactTag = "log"
+)
+
+// defaultSensitivePatterns match the keys whose values are redacted, case insensitively
+var defaultSensitivePatterns = []string{
+	"x-auth-token",
+	"username",
+	"user",
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"accesskey",
+	"passphrase",
+	"encryption_?key",
+	"private_?key",
+	"authorization",
+	"cookie",
+}
+
+var (
+	sensitiveMutex   sync.RWMutex
+	sensitiveRegexps = mustCompileSensitivePatterns(defaultSensitivePatterns)
+)
+
+func mustCompileSensitivePatterns(patterns []string) []*regexp.Regexp {
+	regexps, err := compileSensitivePatterns(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return regexps
+}
+
+func compileSensitivePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sensitive key pattern %q, %v", pattern, err)
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
+// AddSensitivePatterns adds regular expressions matching, case insensitively and anywhere in the
+// key, the keys whose values are redacted
+func AddSensitivePatterns(patterns ...string) error {
+	regexps, err := compileSensitivePatterns(patterns)
+	if err != nil {
+		return err
+	}
+	sensitiveMutex.Lock()
+	defer sensitiveMutex.Unlock()
+	sensitiveRegexps = append(append([]*regexp.Regexp{}, sensitiveRegexps...), regexps...)
+	return nil
+}
+
+// ResetSensitivePatterns restores the default sensitive key patterns
+func ResetSensitivePatterns() {
+	sensitiveMutex.Lock()
+	defer sensitiveMutex.Unlock()
+	sensitiveRegexps = mustCompileSensitivePatterns(defaultSensitivePatterns)
+}
+
+// Redact returns a copy of data, in the generic form produced by decoding its JSON encoding, with
+// the values of sensitive fields replaced by RedactedValue.  A field is sensitive when it is
+// tagged `log:"redact"` or when its JSON name matches a sensitive key pattern.
+func Redact(data interface{}) interface{} {
+	return redactValue(reflect.ValueOf(data))
+}
+
+// RedactJSON returns the JSON document with the values of sensitive keys redacted.  Input that
+// is not a JSON document, including a truncated one, is replaced by a placeholder since it
+// cannot be redacted.
+func RedactJSON(data []byte) []byte {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return data
+	}
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return []byte(fmt.Sprintf("<%d bytes not logged, not a JSON document>", len(data)))
+	}
+	redacted, err := json.Marshal(Redact(document))
+	if err != nil {
+		return []byte(fmt.Sprintf("<%d bytes not logged, %v>", len(data), err))
+	}
+	return redacted
+}
+
+// RedactURL returns the URL, or request URI, with the values of sensitive query parameters and
+// any user password redacted
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	changed := false
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), RedactedValue)
+		changed = true
+	}
+	query := u.Query()
+	for key := range query {
+		if IsSensitive(key) {
+			query[key] = []string{RedactedValue}
+			changed = true
+		}
+	}
+	if !changed {
+		return rawURL
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// redactedData formats its data redacted
+type redactedData struct {
+	data interface{}
+}
+
+// Redacted wraps data so that it is logged as redacted JSON, e.g.
+// log.Tracef("publishInfo=%v", log.Redacted(publishInfo))
+func Redacted(data interface{}) fmt.Stringer {
+	return redactedData{data: data}
+}
+
+func (r redactedData) String() string {
+	out, err := json.Marshal(Redact(r.data))
+	if err != nil {
+		return fmt.Sprintf("<unable to redact %T, %v>", r.data, err)
+	}
+	return string(out)
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// redactValue converts v into its generic JSON form, redacting sensitive fields
+func redactValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+	}
+
+	// Types with their own encoding (e.g. time.Time) are redacted by the keys of that encoding
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return redactEncoded(v.Interface())
+	}
+	if v.Kind() == reflect.Ptr {
+		return redactValue(v.Elem())
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		out := make(map[string]interface{})
+		redactStruct(v, out)
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprintf("%v", iter.Key().Interface())
+			if IsSensitive(key) {
+				out[key] = redactedValue(iter.Value())
+			} else {
+				out[key] = redactValue(iter.Value())
+			}
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		fallthrough
+	case reflect.Array:
+		out := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out[i] = redactValue(v.Index(i))
+		}
+		return out
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return nil
+	}
+	return v.Interface()
+}
+
+// redactStruct adds the exported fields of a struct to out following the encoding/json rules for
+// names, omitempty and embedded structs
+func redactStruct(v reflect.Value, out map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, options = tag[:comma], tag[comma+1:]
+		}
+		value := v.Field(i)
+
+		if field.Anonymous && name == "" {
+			embedded := value
+			if embedded.Kind() == reflect.Ptr {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				redactStruct(embedded, out)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(","+options+",", ",omitempty,") && isEmptyValue(value) {
+			continue
+		}
+		if field.Tag.Get(redactTag) == "redact" || IsSensitive(name) {
+			out[name] = redactedValue(value)
+			continue
+		}
+		out[name] = redactValue(value)
+	}
+}
+
+// redactEncoded redacts a value with its own JSON encoding by the keys of that encoding
+func redactEncoded(data interface{}) interface{} {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Sprintf("%v", data)
+	}
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err = decoder.Decode(&document); err != nil {
+		return string(encoded)
+	}
+	if _, ok := document.(map[string]interface{}); !ok {
+		return document
+	}
+	return redactValue(reflect.ValueOf(document))
+}
+
+// redactedValue returns the replacement of a sensitive value, empty values are kept so that log
+// output still tells whether a secret was provided
+func redactedValue(v reflect.Value) interface{} {
+	if !v.IsValid() || isEmptyValue(v) {
+		return redactValue(v)
+	}
+	return RedactedValue
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,377 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+
+package logger
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// syslogFacility is the daemon facility
+	syslogFacility = 3
+	// syslogSDID is the SD-ID of the structured data element carrying the entry fields, 32473 is
+	// the private enterprise number reserved for documentation (RFC 5612)
+	syslogSDID = "fields@32473"
+	// syslogTimestampFormat is RFC 3339 with at most 6 fractional digits, as required by RFC 5424
+	syslogTimestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+	// Maximum lengths of the RFC 5424 header fields
+	syslogMaxHostname = 255
+	syslogMaxAppName  = 48
+	syslogMaxParam    = 32
+
+	syslogDefaultPort  = "514"
+	syslogDialTimeout  = 5 * time.Second
+	syslogWriteTimeout = 5 * time.Second
+	syslogCloseTimeout = 5 * time.Second
+)
+
+var (
+	// syslogEnqueueTimeout is how long Fire waits for room in a full buffer before dropping the
+	// message, so a slow syslog server slows logging down rather than losing messages right away.
+	// There is no wait while the server is unreachable, it would only slow logging down.
+	syslogEnqueueTimeout = 100 * time.Millisecond
+	// Reconnection backoff bounds
+	syslogMinBackoff = 100 * time.Millisecond
+	syslogMaxBackoff = 30 * time.Second
+)
+
+// SyslogHook ships log entries to a syslog server in RFC 5424 format.  Entries are buffered and
+// written by a background goroutine that reconnects with backoff, so an unreachable server does
+// not block logging.  When the buffer is full Fire waits briefly for room before dropping the
+// entry, or drops it right away while the writer is reconnecting; the number of dropped entries
+// is reported to the server once it catches up.
+type SyslogHook struct {
+	dropped      uint64 // first for 64-bit alignment of atomic operations
+	disconnected uint32 // set while the writer is backing off
+
+	network  string
+	address  string
+	tag      string
+	hostname string
+	pid      int
+
+	queue   chan []byte
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+
+	// owned by the writer goroutine
+	conn   net.Conn
+	stream bool
+}
+
+// NewSyslogHook creates a hook shipping log entries to the syslog server at address, given as
+// tcp://host:port, udp://host:port, unix:///path or host:port (udp)
+func NewSyslogHook(address, tag string, bufferSize int) (*SyslogHook, error) {
+	network, addr, err := parseSyslogAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if bufferSize <= 0 {
+		bufferSize = DefaultSyslogBufferSize
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	hook := &SyslogHook{
+		network:  network,
+		address:  addr,
+		tag:      tag,
+		hostname: hostname,
+		pid:      os.Getpid(),
+		queue:    make(chan []byte, bufferSize),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go hook.run()
+	return hook, nil
+}
+
+// parseSyslogAddress returns the network and address of a syslog server
+func parseSyslogAddress(address string) (string, string, error) {
+	if !strings.Contains(address, "://") {
+		address = "udp://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", "", err
+	}
+	switch u.Scheme {
+	case "tcp", "udp":
+		if u.Host == "" {
+			return "", "", fmt.Errorf("missing syslog host in %s", address)
+		}
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), syslogDefaultPort)
+		}
+		return u.Scheme, host, nil
+	case "unix", "unixgram":
+		if u.Path == "" {
+			return "", "", fmt.Errorf("missing syslog socket path in %s", address)
+		}
+		return u.Scheme, u.Path, nil
+	}
+	return "", "", fmt.Errorf("unsupported syslog network %s", u.Scheme)
+}
+
+func (hook *SyslogHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (hook *SyslogHook) Fire(entry *log.Entry) error {
+	message := hook.format(entry.Level, entry.Time, entry.Message, entry.Data)
+
+	select {
+	case hook.queue <- message:
+		return nil
+	default:
+	}
+
+	if atomic.LoadUint32(&hook.disconnected) != 0 {
+		atomic.AddUint64(&hook.dropped, 1)
+		return nil
+	}
+
+	// The buffer is full, wait a little for the writer to catch up
+	timer := time.NewTimer(syslogEnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case hook.queue <- message:
+	case <-timer.C:
+		atomic.AddUint64(&hook.dropped, 1)
+	case <-hook.done:
+		atomic.AddUint64(&hook.dropped, 1)
+	}
+	return nil
+}
+
+// Close flushes the buffered entries, waiting at most timeout, and stops the hook
+func (hook *SyslogHook) Close(timeout time.Duration) {
+	hook.once.Do(func() {
+		close(hook.done)
+	})
+	select {
+	case <-hook.stopped:
+	case <-time.After(timeout):
+	}
+}
+
+// Dropped returns the number of entries dropped and not yet reported to the server
+func (hook *SyslogHook) Dropped() uint64 {
+	return atomic.LoadUint64(&hook.dropped)
+}
+
+// run writes the buffered entries until the hook is closed
+func (hook *SyslogHook) run() {
+	defer close(hook.stopped)
+	for {
+		select {
+		case message := <-hook.queue:
+			hook.deliver(message)
+		case <-hook.done:
+			hook.flush()
+			return
+		}
+	}
+}
+
+// deliver writes message, reconnecting with backoff until it is written or the hook is closed
+func (hook *SyslogHook) deliver(message []byte) {
+	backoff := syslogMinBackoff
+	for attempt := 0; ; attempt++ {
+		err := hook.write(message)
+		if err == nil {
+			if attempt > 0 {
+				atomic.StoreUint32(&hook.disconnected, 0)
+				fmt.Fprintf(os.Stderr, "Reconnected to syslog %s://%s\n", hook.network, hook.address)
+			}
+			return
+		}
+		if attempt == 0 {
+			atomic.StoreUint32(&hook.disconnected, 1)
+			// Logging the failure would loop back into this hook
+			fmt.Fprintf(os.Stderr, "Unable to write to syslog %s://%s, retrying, %v\n", hook.network, hook.address, err)
+		}
+		select {
+		case <-hook.done:
+			atomic.AddUint64(&hook.dropped, 1)
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > syslogMaxBackoff {
+			backoff = syslogMaxBackoff
+		}
+	}
+}
+
+// flush writes the remaining buffered entries without retrying
+func (hook *SyslogHook) flush() {
+	defer func() {
+		if hook.conn != nil {
+			hook.conn.Close()
+			hook.conn = nil
+		}
+	}()
+	for {
+		select {
+		case message := <-hook.queue:
+			if hook.write(message) != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// write sends message, preceded by a notice of the dropped entries if any
+func (hook *SyslogHook) write(message []byte) error {
+	if hook.conn == nil {
+		if err := hook.dial(); err != nil {
+			return err
+		}
+	}
+	if dropped := atomic.SwapUint64(&hook.dropped, 0); dropped != 0 {
+		notice := hook.format(log.WarnLevel, time.Now(), fmt.Sprintf("dropped %d log messages", dropped), nil)
+		if err := hook.send(notice); err != nil {
+			atomic.AddUint64(&hook.dropped, dropped)
+			return err
+		}
+	}
+	return hook.send(message)
+}
+
+func (hook *SyslogHook) dial() error {
+	var conn net.Conn
+	var err error
+	switch hook.network {
+	case "unix":
+		// Local syslog sockets such as /dev/log are usually datagram sockets
+		conn, err = net.DialTimeout("unixgram", hook.address, syslogDialTimeout)
+		if err != nil {
+			conn, err = net.DialTimeout("unix", hook.address, syslogDialTimeout)
+		}
+	default:
+		conn, err = net.DialTimeout(hook.network, hook.address, syslogDialTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	hook.conn = conn
+	switch c := conn.(type) {
+	case *net.UDPConn:
+		hook.stream = false
+	case *net.UnixConn:
+		hook.stream = c.RemoteAddr() != nil && c.RemoteAddr().Network() == "unix"
+	default:
+		hook.stream = true
+	}
+	return nil
+}
+
+// send writes one message, with octet counting framing (RFC 6587) on stream connections
+func (hook *SyslogHook) send(message []byte) error {
+	if hook.stream {
+		message = append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+	}
+	hook.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	_, err := hook.conn.Write(message)
+	if err != nil {
+		hook.conn.Close()
+		hook.conn = nil
+	}
+	return err
+}
+
+// format returns an RFC 5424 message, the fields are sent as structured data
+func (hook *SyslogHook) format(level log.Level, timestamp time.Time, message string, fields Fields) []byte {
+	priority := syslogFacility*8 + syslogSeverity(level)
+	header := fmt.Sprintf("<%d>1 %s %s %s %d - ",
+		priority,
+		timestamp.Format(syslogTimestampFormat),
+		syslogHeaderField(hook.hostname, syslogMaxHostname),
+		syslogHeaderField(hook.tag, syslogMaxAppName),
+		hook.pid,
+	)
+	return []byte(header + syslogStructuredData(fields) + " " + message)
+}
+
+// syslogSeverity maps a logrus level to a syslog severity
+func syslogSeverity(level log.Level) int {
+	switch level {
+	case log.PanicLevel:
+		return 0 // emergency
+	case log.FatalLevel:
+		return 2 // critical
+	case log.ErrorLevel:
+		return 3 // error
+	case log.WarnLevel:
+		return 4 // warning
+	case log.InfoLevel:
+		return 6 // informational
+	}
+	return 7 // debug
+}
+
+// syslogHeaderField returns value restricted to the printable ASCII allowed in a header field
+func syslogHeaderField(value string, maxLength int) string {
+	field := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(field) > maxLength {
+		field = field[:maxLength]
+	}
+	if field == "" {
+		return "-"
+	}
+	return field
+}
+
+// syslogStructuredData returns the fields as one structured data element
+func syslogStructuredData(fields Fields) string {
+	if len(fields) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sd strings.Builder
+	sd.WriteString("[" + syslogSDID)
+	for _, key := range keys {
+		name := strings.Map(func(r rune) rune {
+			if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+				return '_'
+			}
+			return r
+		}, key)
+		if len(name) > syslogMaxParam {
+			name = name[:syslogMaxParam]
+		}
+		value := fmt.Sprintf("%v", fields[key])
+		if err, ok := fields[key].(error); ok {
+			value = err.Error()
+		}
+		value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+		sd.WriteString(fmt.Sprintf(` %s="%s"`, name, value))
+	}
+	sd.WriteString("]")
+	return sd.String()
+}
@@ -0,0 +1,300 @@
+// Copyright 2020 Hewlett Packard Enterprise Development LP
+package logger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// syslogTestServer is a local syslog server collecting the messages it receives
+type syslogTestServer struct {
+	network  string
+	address  string
+	messages chan string
+	closer   io.Closer
+}
+
+// startSyslogTestServer listens on network ("tcp", "udp", "unix" or "unixgram") at address
+func startSyslogTestServer(t *testing.T, network, address string) *syslogTestServer {
+	server := &syslogTestServer{network: network, messages: make(chan string, 1000)}
+	switch network {
+	case "udp", "unixgram":
+		conn, err := net.ListenPacket(network, address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.address = conn.LocalAddr().String()
+		server.closer = conn
+		go func() {
+			buf := make([]byte, 64<<10)
+			for {
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				server.messages <- string(buf[:n])
+			}
+		}()
+	default:
+		listener, err := net.Listen(network, address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.address = listener.Addr().String()
+		server.closer = listener
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go server.readFrames(conn)
+			}
+		}()
+	}
+	t.Cleanup(func() { server.closer.Close() })
+	return server
+}
+
+// readFrames reads octet counted messages from a stream connection
+func (server *syslogTestServer) readFrames(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		if err != nil {
+			return
+		}
+		message := make([]byte, n)
+		if _, err = io.ReadFull(reader, message); err != nil {
+			return
+		}
+		server.messages <- string(message)
+	}
+}
+
+// url returns the address of the server for NewSyslogHook
+func (server *syslogTestServer) url() string {
+	return server.network + "://" + server.address
+}
+
+func (server *syslogTestServer) next(t *testing.T) string {
+	select {
+	case message := <-server.messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a syslog message")
+	}
+	return ""
+}
+
+func TestParseSyslogAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		addr    string
+		err     bool
+	}{
+		{"tcp://logs.example.com:6514", "tcp", "logs.example.com:6514", false},
+		{"udp://10.0.0.1", "udp", "10.0.0.1:514", false},
+		{"10.0.0.1:1514", "udp", "10.0.0.1:1514", false},
+		{"unix:///dev/log", "unix", "/dev/log", false},
+		{"tcp://", "", "", true},
+		{"unix://", "", "", true},
+		{"http://host:80", "", "", true},
+	}
+	for _, tc := range tests {
+		network, addr, err := parseSyslogAddress(tc.address)
+		assert.Equal(t, tc.err, err != nil, tc.address)
+		assert.Equal(t, tc.network, network, tc.address)
+		assert.Equal(t, tc.addr, addr, tc.address)
+	}
+}
+
+func TestSyslogFormat(t *testing.T) {
+	hook := &SyslogHook{tag: "chapid", hostname: "host 1", pid: 42}
+	timestamp := time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)
+
+	message := string(hook.format(log.ErrorLevel, timestamp, "attach failed", Fields{
+		"serialNumber": "abc",
+		"error":        errors.New(`bad "path" ]`),
+	}))
+	assert.Equal(t, `<27>1 2020-01-02T03:04:05.123456Z host_1 chapid 42 - [fields@32473 error="bad \"path\" \]" serialNumber="abc"] attach failed`, message)
+
+	message = string(hook.format(log.InfoLevel, timestamp, "no fields", nil))
+	assert.Equal(t, "<30>1 2020-01-02T03:04:05.123456Z host_1 chapid 42 - - no fields", message)
+}
+
+func TestSyslogHook(t *testing.T) {
+	for _, network := range []string{"tcp", "udp", "unix", "unixgram"} {
+		t.Run(network, func(t *testing.T) {
+			address := "127.0.0.1:0"
+			if strings.HasPrefix(network, "unix") {
+				address = filepath.Join(t.TempDir(), "syslog.sock")
+			}
+			server := startSyslogTestServer(t, network, address)
+
+			hook, err := NewSyslogHook(server.url(), "chapid", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer hook.Close(time.Second)
+
+			logger := log.New()
+			logger.SetOutput(io.Discard)
+			logger.AddHook(hook)
+			logger.WithField(SerialNumberKey, "abc").Info("line one\nline two")
+
+			message := server.next(t)
+			assert.Contains(t, message, " chapid ")
+			assert.Contains(t, message, `[fields@32473 serialNumber="abc"]`)
+			assert.True(t, strings.HasSuffix(message, "line one\nline two"), message)
+		})
+	}
+}
+
+func TestSyslogHookBackpressure(t *testing.T) {
+	defer func(timeout, backoff time.Duration) {
+		syslogEnqueueTimeout, syslogMinBackoff = timeout, backoff
+	}(syslogEnqueueTimeout, syslogMinBackoff)
+	syslogEnqueueTimeout = 10 * time.Millisecond
+	syslogMinBackoff = 10 * time.Millisecond
+
+	// Nothing listens on the socket yet, so entries are buffered and then dropped
+	address := filepath.Join(t.TempDir(), "syslog.sock")
+	hook, err := NewSyslogHook("unix://"+address, "chapid", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close(time.Second)
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(hook)
+	for i := 0; i < 10; i++ {
+		logger.Infof("message %d", i)
+	}
+	dropped := hook.Dropped()
+	assert.True(t, dropped > 0, "expected dropped messages")
+
+	// Once the server is up the buffered entries are delivered along with a notice of the drops
+	server := startSyslogTestServer(t, "unix", address)
+	var received []string
+	for len(received) < 3 {
+		message := server.next(t)
+		received = append(received, message[strings.LastIndex(message, " - ")+3:])
+	}
+	assert.Contains(t, received, fmt.Sprintf("dropped %d log messages", dropped))
+	assert.Contains(t, received, "message 0")
+}
+
+func TestSyslogHookDisconnectedDropsImmediately(t *testing.T) {
+	defer func(timeout, backoff time.Duration) {
+		syslogEnqueueTimeout, syslogMinBackoff = timeout, backoff
+	}(syslogEnqueueTimeout, syslogMinBackoff)
+	syslogEnqueueTimeout = time.Minute
+	syslogMinBackoff = time.Minute
+
+	address := filepath.Join(t.TempDir(), "syslog.sock")
+	hook, err := NewSyslogHook("unix://"+address, "chapid", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close(time.Second)
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(hook)
+	logger.Info("first")
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadUint32(&hook.disconnected) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the writer to be backing off")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The buffer fills up while the writer backs off, the next entries must not wait for room
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		logger.Infof("message %d", i)
+	}
+	assert.True(t, time.Since(start) < syslogEnqueueTimeout/2, "expected the entries to be dropped right away")
+	assert.True(t, hook.Dropped() >= 9, "expected dropped messages")
+}
+
+func TestInitLoggingSyslog(t *testing.T) {
+	hooks := log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	defer log.StandardLogger().ReplaceHooks(hooks)
+
+	server := startSyslogTestServer(t, "tcp", "127.0.0.1:0")
+	t.Setenv("LOG_SYSLOG", server.url())
+	t.Setenv("LOG_SYSLOG_TAG", "chapid-test")
+	if err := InitLogging("", nil, false); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { logParams.Syslog = "" }()
+
+	log.Info("shipped to syslog")
+	for {
+		message := server.next(t)
+		if strings.HasSuffix(message, "shipped to syslog") {
+			assert.Contains(t, message, " chapid-test ")
+			break
+		}
+	}
+}
+
+func TestInitLoggingReplacesHooks(t *testing.T) {
+	hooks := log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	defer log.StandardLogger().ReplaceHooks(hooks)
+
+	// A journald hook left over from an earlier initialization
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	journaldHook, err := NewJournaldHook(socket, "chapid-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.AddHook(journaldHook)
+
+	server := startSyslogTestServer(t, "tcp", "127.0.0.1:0")
+	t.Setenv("LOG_SYSLOG", server.url())
+	defer func() { logParams.Syslog = "" }()
+	for i := 0; i < 2; i++ {
+		if err := InitLogging("", nil, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var syslogHooks int
+	for _, hook := range log.StandardLogger().Hooks[log.InfoLevel] {
+		switch hook.(type) {
+		case *JournaldHook:
+			t.Errorf("journald hook was not removed")
+		case *SyslogHook:
+			syslogHooks++
+		}
+	}
+	assert.Equal(t, 1, syslogHooks)
+}
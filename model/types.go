@@ -127,7 +127,7 @@ type Initiator struct {
 // ChapInfo : Host initiator CHAP credentials
 type ChapInfo struct {
 	Name     string `json:"chap_user,omitempty"`
-	Password string `json:"chap_password,omitempty"`
+	Password string `json:"chap_password,omitempty" log:"redact"`
 }
 
 // IscsiTarget struct
@@ -199,7 +199,7 @@ type Volume struct {
 	SecondaryArrayDetails string                 `json:"secondary_array_details,omitempty"`
 	UsedBytes             int64                  `json:"used_bytes,omitempty"`
 	FreeBytes             int64                  `json:"free_bytes,omitempty"`
-	EncryptionKey         string                 `json:"encryption_key,omitempty"`
+	EncryptionKey         string                 `json:"encryption_key,omitempty" log:"redact"`
 }
 
 func (v Volume) TargetNames() []string {
@@ -312,7 +312,7 @@ type SecondaryLunInfo struct {
 type IscsiAccessInfo struct {
 	DiscoveryIPs []string `json:"discovery_ips,omitempty"`
 	ChapUser     string   `json:"chap_user,omitempty"`
-	ChapPassword string   `json:"chap_password,omitempty"`
+	ChapPassword string   `json:"chap_password,omitempty" log:"redact"`
 }
 
 // VirtualDeviceAccessInfo contains the required data to access a virtual device
@@ -379,9 +379,9 @@ type PathInfo struct {
 type Token struct {
 	ID           string `json:"id,omitempty"`
 	Username     string `json:"username,omitempty"`
-	Password     string `json:"password,omitempty"`
+	Password     string `json:"password,omitempty" log:"redact"`
 	ArrayIP      string `json:"array_ip,omityempty"`
-	SessionToken string `json:"session_token,omitempty"`
+	SessionToken string `json:"session_token,omitempty" log:"redact"`
 }
 
 // Node represents a host that would access volumes through the CSP
@@ -393,7 +393,7 @@ type Node struct {
 	Networks     []*string `json:"networks,omitempty"`
 	Wwpns        []*string `json:"wwpns,omitempty"`
 	ChapUser     string    `json:"chap_user,omitempty"`
-	ChapPassword string    `json:"chap_password,omitempty"`
+	ChapPassword string    `json:"chap_password,omitempty" log:"redact"`
 }
 
 // KeyValue is a store of key-value pairs
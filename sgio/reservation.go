@@ -0,0 +1,219 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package sgio
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// PERSISTENT RESERVE IN service actions
+const (
+	PRInReadKeys        = 0x00
+	PRInReadReservation = 0x01
+)
+
+// PERSISTENT RESERVE OUT service actions
+const (
+	PROutRegister             = 0x00
+	PROutReserve              = 0x01
+	PROutRelease              = 0x02
+	PROutClear                = 0x03
+	PROutPreempt              = 0x04
+	PROutPreemptAndAbort      = 0x05
+	PROutRegisterAndIgnoreKey = 0x06
+)
+
+// Persistent reservation types
+const (
+	PRTypeWriteExclusive                 = 0x1
+	PRTypeExclusiveAccess                = 0x3
+	PRTypeWriteExclusiveRegistrantsOnly  = 0x5
+	PRTypeExclusiveAccessRegistrantsOnly = 0x6
+	PRTypeWriteExclusiveAllRegistrants   = 0x7
+	PRTypeExclusiveAccessAllRegistrants  = 0x8
+)
+
+const (
+	prInHeaderLen         = 8
+	prInInitialLen        = prInHeaderLen + 256*8
+	prReservationDescLen  = 16
+	prOutParameterListLen = 24
+	prScopeLogicalUnit    = 0x0
+)
+
+var prTypeNames = map[uint8]string{
+	PRTypeWriteExclusive:                 "write-exclusive",
+	PRTypeExclusiveAccess:                "exclusive-access",
+	PRTypeWriteExclusiveRegistrantsOnly:  "write-exclusive-registrants-only",
+	PRTypeExclusiveAccessRegistrantsOnly: "exclusive-access-registrants-only",
+	PRTypeWriteExclusiveAllRegistrants:   "write-exclusive-all-registrants",
+	PRTypeExclusiveAccessAllRegistrants:  "exclusive-access-all-registrants",
+}
+
+// PRTypeName returns the name of the persistent reservation type
+func PRTypeName(prType uint8) string {
+	if name, ok := prTypeNames[prType]; ok {
+		return name
+	}
+	return fmt.Sprintf("reserved(0x%x)", prType)
+}
+
+// ParsePRType returns the persistent reservation type with the given name
+func ParsePRType(name string) (uint8, error) {
+	for prType, prName := range prTypeNames {
+		if prName == name {
+			return prType, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown persistent reservation type %q", name)
+}
+
+// PRKeys is the decoded PERSISTENT RESERVE IN READ KEYS parameter data
+type PRKeys struct {
+	Generation uint32
+	Keys       []uint64
+}
+
+// PRReservation is the decoded PERSISTENT RESERVE IN READ RESERVATION parameter data, Key is only valid if Reserved is set
+type PRReservation struct {
+	Generation uint32
+	Reserved   bool
+	Key        uint64
+	Scope      uint8
+	Type       uint8
+}
+
+// PROutParams is the parameter list of a PERSISTENT RESERVE OUT command
+type PROutParams struct {
+	ServiceAction uint8
+	Type          uint8
+	// Key is the reservation key registered by this I_T nexus
+	Key uint64
+	// ServiceActionKey is the new key when registering, or the key to preempt
+	ServiceActionKey uint64
+	// AllTargetPorts registers the key through all target ports of the logical unit
+	AllTargetPorts bool
+	// ActivatePersistThroughPowerLoss keeps the registration across a power loss of the target
+	ActivatePersistThroughPowerLoss bool
+}
+
+// PersistentReserveInCDB returns a PERSISTENT RESERVE IN CDB for the given service action
+func PersistentReserveInCDB(serviceAction uint8, allocationLength uint16) []uint8 {
+	cdb := make([]uint8, 10)
+	cdb[0] = OpPersistentReserveIn
+	cdb[1] = serviceAction & 0x1f
+	binary.BigEndian.PutUint16(cdb[7:9], allocationLength)
+	return cdb
+}
+
+// PersistentReserveOutCDB returns a PERSISTENT RESERVE OUT CDB for the given parameters
+func PersistentReserveOutCDB(params *PROutParams) []uint8 {
+	cdb := make([]uint8, 10)
+	cdb[0] = OpPersistentReserveOut
+	cdb[1] = params.ServiceAction & 0x1f
+	cdb[2] = prScopeLogicalUnit<<4 | params.Type&0x0f
+	binary.BigEndian.PutUint32(cdb[5:9], prOutParameterListLen)
+	return cdb
+}
+
+// EncodePROutParameterList returns the basic PERSISTENT RESERVE OUT parameter list for the given parameters
+func EncodePROutParameterList(params *PROutParams) []byte {
+	b := make([]byte, prOutParameterListLen)
+	binary.BigEndian.PutUint64(b[0:8], params.Key)
+	binary.BigEndian.PutUint64(b[8:16], params.ServiceActionKey)
+	if params.AllTargetPorts {
+		b[20] |= 0x04
+	}
+	if params.ActivatePersistThroughPowerLoss {
+		b[20] |= 0x01
+	}
+	return b
+}
+
+// prInPayload validates the PERSISTENT RESERVE IN header and returns the generation and the data following it
+func prInPayload(b []byte) (uint32, []byte, error) {
+	if len(b) < prInHeaderLen {
+		return 0, nil, fmt.Errorf("persistent reserve in data too short, %d bytes", len(b))
+	}
+	end := prInHeaderLen + int(binary.BigEndian.Uint32(b[4:8]))
+	if end > len(b) {
+		return 0, nil, fmt.Errorf("persistent reserve in data of %d bytes truncated to %d bytes", end, len(b))
+	}
+	return binary.BigEndian.Uint32(b[0:4]), b[prInHeaderLen:end], nil
+}
+
+// ParsePRKeys decodes the READ KEYS parameter data
+func ParsePRKeys(b []byte) (*PRKeys, error) {
+	generation, payload, err := prInPayload(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload)%8 != 0 {
+		return nil, fmt.Errorf("read keys list length %d is not a multiple of 8", len(payload))
+	}
+	keys := &PRKeys{Generation: generation}
+	for i := 0; i < len(payload); i += 8 {
+		keys.Keys = append(keys.Keys, binary.BigEndian.Uint64(payload[i:i+8]))
+	}
+	return keys, nil
+}
+
+// ParsePRReservation decodes the READ RESERVATION parameter data
+func ParsePRReservation(b []byte) (*PRReservation, error) {
+	generation, payload, err := prInPayload(b)
+	if err != nil {
+		return nil, err
+	}
+	reservation := &PRReservation{Generation: generation}
+	if len(payload) == 0 {
+		return reservation, nil
+	}
+	if len(payload) < prReservationDescLen {
+		return nil, fmt.Errorf("reservation descriptor too short, %d bytes", len(payload))
+	}
+	reservation.Reserved = true
+	reservation.Key = binary.BigEndian.Uint64(payload[0:8])
+	reservation.Scope = payload[13] >> 4
+	reservation.Type = payload[13] & 0x0f
+	return reservation, nil
+}
+
+// PersistentReserveReadKeys returns the reservation keys registered with the device
+func PersistentReserveReadKeys(device string) (*PRKeys, error) {
+	respBuf, err := persistentReserveIn(device, PRInReadKeys)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePRKeys(respBuf)
+}
+
+// PersistentReserveReadReservation returns the persistent reservation held on the device
+func PersistentReserveReadReservation(device string) (*PRReservation, error) {
+	respBuf, err := persistentReserveIn(device, PRInReadReservation)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePRReservation(respBuf)
+}
+
+// PersistentReserveOut issues a PERSISTENT RESERVE OUT command with the given parameters
+func PersistentReserveOut(device string, params *PROutParams) error {
+	return execCommand(PersistentReserveOutCDB(params), EncodePROutParameterList(params), device, dataOut)
+}
+
+// persistentReserveIn issues a PERSISTENT RESERVE IN, re-issuing it if the data is longer than the initial allocation
+func persistentReserveIn(device string, serviceAction uint8) ([]byte, error) {
+	respBuf := make([]byte, prInInitialLen)
+	if err := execCommand(PersistentReserveInCDB(serviceAction, uint16(len(respBuf))), respBuf, device, dataIn); err != nil {
+		return nil, err
+	}
+	needed := prInHeaderLen + int(binary.BigEndian.Uint32(respBuf[4:8]))
+	if needed > len(respBuf) && needed <= maxAllocationLength {
+		respBuf = make([]byte, needed)
+		if err := execCommand(PersistentReserveInCDB(serviceAction, uint16(needed)), respBuf, device, dataIn); err != nil {
+			return nil, err
+		}
+	}
+	return respBuf, nil
+}
@@ -0,0 +1,382 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package sgio
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+var (
+	// StandardInquiry :
+	StandardInquiry = []uint8{
+		0x12, // Operation Code
+		0,    // EVPD
+		0,    // VPD Page
+		0,    // Reserved
+		96,   // Response length
+		0,    // Control
+	}
+	// Vpd80Inquiry :
+	Vpd80Inquiry = []uint8{
+		0x12, // Operation Code
+		1,    // EVPD
+		0x80, // VPD Page
+		0,    // Reserved
+		96,   // Response length
+		0,    // Control
+	}
+)
+
+// SCSI operation codes
+const (
+	OpTestUnitReady          = 0x00
+	OpInquiry                = 0x12
+	OpPersistentReserveIn    = 0x5E
+	OpPersistentReserveOut   = 0x5F
+	OpServiceActionIn16      = 0x9E
+	OpReportLuns             = 0xA0
+	OpMaintenanceIn          = 0xA3
+	saReadCapacity16         = 0x10
+	saReportTargetPortGroups = 0x0A
+)
+
+// Asymmetric access states reported by REPORT TARGET PORT GROUPS
+const (
+	AccessStateActiveOptimized    = 0x0
+	AccessStateActiveNonOptimized = 0x1
+	AccessStateStandby            = 0x2
+	AccessStateUnavailable        = 0x3
+	AccessStateLBADependent       = 0x4
+	AccessStateOffline            = 0xE
+	AccessStateTransitioning      = 0xF
+)
+
+const (
+	readCapacity16Len     = 32
+	reportLunsHeaderLen   = 8
+	reportLunsInitialLen  = reportLunsHeaderLen + 256*8
+	rtpgInitialLen        = 1024
+	rtpgDescriptorLen     = 8
+	vpdInitialLen         = 0xFF
+	maxAllocationLength   = 0xFFFF
+	rtpgExtendedHeader    = 0x1
+	rtpgExtendedHeaderLen = 8
+	rtpgLengthHeaderLen   = 4
+)
+
+var accessStateNames = map[uint8]string{
+	AccessStateActiveOptimized:    "active/optimized",
+	AccessStateActiveNonOptimized: "active/non-optimized",
+	AccessStateStandby:            "standby",
+	AccessStateUnavailable:        "unavailable",
+	AccessStateLBADependent:       "lba-dependent",
+	AccessStateOffline:            "offline",
+	AccessStateTransitioning:      "transitioning",
+}
+
+// dataDirection is the direction of the data transfer of a SCSI command
+type dataDirection int
+
+const (
+	dataNone dataDirection = iota
+	dataIn
+	dataOut
+)
+
+// Capacity is the decoded READ CAPACITY(16) parameter data
+type Capacity struct {
+	LastLBA                  uint64
+	BlockLength              uint32
+	ProtectionEnabled        bool
+	ProtectionType           uint8
+	ProtectionIntervalExp    uint8
+	LogicalBlocksPerPhysExp  uint8
+	ThinProvisioningEnabled  bool
+	ThinProvisioningReadZero bool
+	LowestAlignedLBA         uint16
+}
+
+// Blocks returns the number of logical blocks of the device
+func (c *Capacity) Blocks() uint64 {
+	return c.LastLBA + 1
+}
+
+// SizeInBytes returns the capacity of the device in bytes
+func (c *Capacity) SizeInBytes() uint64 {
+	return c.Blocks() * uint64(c.BlockLength)
+}
+
+// PhysicalBlockLength returns the length of a physical block in bytes
+func (c *Capacity) PhysicalBlockLength() uint64 {
+	return uint64(c.BlockLength) << c.LogicalBlocksPerPhysExp
+}
+
+// Lun is a single entry of the REPORT LUNS parameter data
+type Lun struct {
+	// Raw is the 8 byte SAM LUN structure
+	Raw [8]byte
+	// Number is the LUN as numbered by the Linux SCSI midlayer
+	Number uint64
+}
+
+// TargetPortGroup is a single target port group descriptor of the REPORT TARGET PORT GROUPS parameter data
+type TargetPortGroup struct {
+	Preferred             bool
+	AccessState           uint8
+	SupportedStates       uint8
+	ID                    uint16
+	StatusCode            uint8
+	RelativeTargetPortIDs []uint16
+}
+
+// AccessStateName returns the name of the asymmetric access state of the group
+func (g *TargetPortGroup) AccessStateName() string {
+	if name, ok := accessStateNames[g.AccessState]; ok {
+		return name
+	}
+	return fmt.Sprintf("reserved(0x%x)", g.AccessState)
+}
+
+// TargetPortGroups is the decoded REPORT TARGET PORT GROUPS parameter data
+type TargetPortGroups struct {
+	// ImplicitTransitionTime is the seconds an implicit ALUA transition may take, reported with the extended header only
+	ImplicitTransitionTime uint8
+	Groups                 []*TargetPortGroup
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// CDB encoders
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// InquiryCDB returns an INQUIRY CDB for the standard inquiry data, or the given VPD page if evpd is set
+func InquiryCDB(evpd bool, page uint8, allocationLength uint16) []uint8 {
+	cdb := make([]uint8, 6)
+	cdb[0] = OpInquiry
+	if evpd {
+		cdb[1] = 0x01
+		cdb[2] = page
+	}
+	binary.BigEndian.PutUint16(cdb[3:5], allocationLength)
+	return cdb
+}
+
+// ReadCapacity16CDB returns a READ CAPACITY(16) CDB
+func ReadCapacity16CDB(allocationLength uint32) []uint8 {
+	cdb := make([]uint8, 16)
+	cdb[0] = OpServiceActionIn16
+	cdb[1] = saReadCapacity16
+	binary.BigEndian.PutUint32(cdb[10:14], allocationLength)
+	return cdb
+}
+
+// ReportLunsCDB returns a REPORT LUNS CDB for the given select report field
+func ReportLunsCDB(selectReport uint8, allocationLength uint32) []uint8 {
+	cdb := make([]uint8, 12)
+	cdb[0] = OpReportLuns
+	cdb[2] = selectReport
+	binary.BigEndian.PutUint32(cdb[6:10], allocationLength)
+	return cdb
+}
+
+// ReportTargetPortGroupsCDB returns a REPORT TARGET PORT GROUPS CDB requesting the extended header format
+func ReportTargetPortGroupsCDB(allocationLength uint32) []uint8 {
+	cdb := make([]uint8, 12)
+	cdb[0] = OpMaintenanceIn
+	cdb[1] = rtpgExtendedHeader<<5 | saReportTargetPortGroups
+	binary.BigEndian.PutUint32(cdb[6:10], allocationLength)
+	return cdb
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Parameter data decoders
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// ParseReadCapacity16 decodes the READ CAPACITY(16) parameter data
+func ParseReadCapacity16(b []byte) (*Capacity, error) {
+	if len(b) < readCapacity16Len {
+		return nil, fmt.Errorf("read capacity(16) data too short, %d bytes", len(b))
+	}
+	capacity := &Capacity{
+		LastLBA:                  binary.BigEndian.Uint64(b[0:8]),
+		BlockLength:              binary.BigEndian.Uint32(b[8:12]),
+		ProtectionEnabled:        b[12]&0x01 != 0,
+		ProtectionType:           (b[12] >> 1) & 0x7,
+		ProtectionIntervalExp:    b[13] >> 4,
+		LogicalBlocksPerPhysExp:  b[13] & 0x0f,
+		ThinProvisioningEnabled:  b[14]&0x80 != 0,
+		ThinProvisioningReadZero: b[14]&0x40 != 0,
+		LowestAlignedLBA:         binary.BigEndian.Uint16(b[14:16]) & 0x3fff,
+	}
+	if capacity.BlockLength == 0 {
+		return nil, fmt.Errorf("read capacity(16) reported a zero block length")
+	}
+	return capacity, nil
+}
+
+// ParseReportLuns decodes the REPORT LUNS parameter data, it fails if the list was truncated by the allocation length
+func ParseReportLuns(b []byte) ([]*Lun, error) {
+	if len(b) < reportLunsHeaderLen {
+		return nil, fmt.Errorf("report luns data too short, %d bytes", len(b))
+	}
+	listLen := int(binary.BigEndian.Uint32(b[0:4]))
+	if listLen%8 != 0 {
+		return nil, fmt.Errorf("report luns list length %d is not a multiple of 8", listLen)
+	}
+	if reportLunsHeaderLen+listLen > len(b) {
+		return nil, fmt.Errorf("report luns list of %d bytes truncated to %d bytes", listLen, len(b)-reportLunsHeaderLen)
+	}
+	var luns []*Lun
+	for i := reportLunsHeaderLen; i < reportLunsHeaderLen+listLen; i += 8 {
+		lun := &Lun{}
+		copy(lun.Raw[:], b[i:i+8])
+		lun.Number = lunToInt(lun.Raw)
+		luns = append(luns, lun)
+	}
+	return luns, nil
+}
+
+// lunToInt converts a SAM LUN structure into the LUN number used by Linux (see scsilun_to_int)
+func lunToInt(raw [8]byte) uint64 {
+	var lun uint64
+	for i := 0; i < 8; i += 2 {
+		lun |= uint64(raw[i])<<uint(i*8+8) | uint64(raw[i+1])<<uint(i*8)
+	}
+	return lun
+}
+
+// ParseReportTargetPortGroups decodes the REPORT TARGET PORT GROUPS parameter data in either header format
+func ParseReportTargetPortGroups(b []byte) (*TargetPortGroups, error) {
+	if len(b) < rtpgLengthHeaderLen {
+		return nil, fmt.Errorf("report target port groups data too short, %d bytes", len(b))
+	}
+	end := rtpgLengthHeaderLen + int(binary.BigEndian.Uint32(b[0:4]))
+	if end > len(b) {
+		return nil, fmt.Errorf("report target port groups data of %d bytes truncated to %d bytes", end, len(b))
+	}
+	groups := &TargetPortGroups{}
+	offset := rtpgLengthHeaderLen
+	if len(b) >= rtpgExtendedHeaderLen && (b[4]>>4)&0x7 == rtpgExtendedHeader {
+		groups.ImplicitTransitionTime = b[5]
+		offset = rtpgExtendedHeaderLen
+	}
+	for offset < end {
+		if offset+rtpgDescriptorLen > end {
+			return nil, fmt.Errorf("target port group descriptor at offset %d is truncated", offset)
+		}
+		d := b[offset:]
+		group := &TargetPortGroup{
+			Preferred:       d[0]&0x80 != 0,
+			AccessState:     d[0] & 0x0f,
+			SupportedStates: d[1],
+			ID:              binary.BigEndian.Uint16(d[2:4]),
+			StatusCode:      d[5],
+		}
+		count := int(d[7])
+		offset += rtpgDescriptorLen
+		if offset+count*4 > end {
+			return nil, fmt.Errorf("target port group %d lists %d ports beyond the data returned", group.ID, count)
+		}
+		for i := 0; i < count; i++ {
+			group.RelativeTargetPortIDs = append(group.RelativeTargetPortIDs, binary.BigEndian.Uint16(b[offset+2:offset+4]))
+			offset += 4
+		}
+		groups.Groups = append(groups.Groups, group)
+	}
+	return groups, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////////
+// Device commands
+///////////////////////////////////////////////////////////////////////////////////////////////////
+
+// inquiryVPD reads the given VPD page, re-issuing the INQUIRY if the page is longer than the initial allocation
+func inquiryVPD(device string, page uint8) ([]byte, error) {
+	respBuf := make([]byte, vpdInitialLen)
+	if err := execCommand(InquiryCDB(true, page, uint16(len(respBuf))), respBuf, device, dataIn); err != nil {
+		return nil, err
+	}
+	pageLen := vpdHeaderLen + int(binary.BigEndian.Uint16(respBuf[2:4]))
+	if pageLen > len(respBuf) && pageLen <= maxAllocationLength {
+		respBuf = make([]byte, pageLen)
+		if err := execCommand(InquiryCDB(true, page, uint16(pageLen)), respBuf, device, dataIn); err != nil {
+			return nil, err
+		}
+	}
+	return respBuf, nil
+}
+
+// GetSupportedVPDPages returns the VPD pages supported by the device
+func GetSupportedVPDPages(device string) ([]uint8, error) {
+	respBuf, err := inquiryVPD(device, VpdSupportedPages)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSupportedVPDPages(respBuf)
+}
+
+// GetDeviceIdentification returns the designators of the device identification VPD page (0x83)
+func GetDeviceIdentification(device string) (*DeviceIdentification, error) {
+	respBuf, err := inquiryVPD(device, VpdDeviceIdentification)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDeviceIdentification(respBuf)
+}
+
+// GetBlockLimits returns the block limits VPD page (0xB0) of the device
+func GetBlockLimits(device string) (*BlockLimits, error) {
+	respBuf, err := inquiryVPD(device, VpdBlockLimits)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBlockLimits(respBuf)
+}
+
+// GetLogicalBlockProvisioning returns the logical block provisioning VPD page (0xB2) of the device
+func GetLogicalBlockProvisioning(device string) (*LogicalBlockProvisioning, error) {
+	respBuf, err := inquiryVPD(device, VpdLogicalBlockProvisioning)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLogicalBlockProvisioning(respBuf)
+}
+
+// ReadCapacity16 returns the capacity and provisioning attributes of the device
+func ReadCapacity16(device string) (*Capacity, error) {
+	respBuf := make([]byte, readCapacity16Len)
+	if err := execCommand(ReadCapacity16CDB(readCapacity16Len), respBuf, device, dataIn); err != nil {
+		return nil, err
+	}
+	return ParseReadCapacity16(respBuf)
+}
+
+// ReportLuns returns all the logical units reported by the target of the device
+func ReportLuns(device string) ([]*Lun, error) {
+	respBuf := make([]byte, reportLunsInitialLen)
+	if err := execCommand(ReportLunsCDB(0, uint32(len(respBuf))), respBuf, device, dataIn); err != nil {
+		return nil, err
+	}
+	if needed := reportLunsHeaderLen + int(binary.BigEndian.Uint32(respBuf[0:4])); needed > len(respBuf) {
+		respBuf = make([]byte, needed)
+		if err := execCommand(ReportLunsCDB(0, uint32(needed)), respBuf, device, dataIn); err != nil {
+			return nil, err
+		}
+	}
+	return ParseReportLuns(respBuf)
+}
+
+// ReportTargetPortGroups returns the ALUA target port groups and their access states for the device
+func ReportTargetPortGroups(device string) (*TargetPortGroups, error) {
+	respBuf := make([]byte, rtpgInitialLen)
+	if err := execCommand(ReportTargetPortGroupsCDB(uint32(len(respBuf))), respBuf, device, dataIn); err != nil {
+		return nil, err
+	}
+	if needed := rtpgLengthHeaderLen + int(binary.BigEndian.Uint32(respBuf[0:4])); needed > len(respBuf) {
+		respBuf = make([]byte, needed)
+		if err := execCommand(ReportTargetPortGroupsCDB(uint32(needed)), respBuf, device, dataIn); err != nil {
+			return nil, err
+		}
+	}
+	return ParseReportTargetPortGroups(respBuf)
+}
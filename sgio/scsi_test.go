@@ -0,0 +1,176 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package sgio
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCDBEncoders(t *testing.T) {
+	tests := []struct {
+		name     string
+		cdb      []uint8
+		expected []uint8
+	}{
+		{"standard inquiry", InquiryCDB(false, 0, 96), StandardInquiry},
+		{"vpd 0x80 inquiry", InquiryCDB(true, VpdUnitSerialNumber, 96), Vpd80Inquiry},
+		{"vpd 0x83 inquiry", InquiryCDB(true, VpdDeviceIdentification, 0x200), []uint8{0x12, 0x01, 0x83, 0x02, 0x00, 0x00}},
+		{"read capacity(16)", ReadCapacity16CDB(32), []uint8{0x9e, 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x20, 0, 0}},
+		{"report luns", ReportLunsCDB(0x02, 0x1008), []uint8{0xa0, 0, 0x02, 0, 0, 0, 0, 0, 0x10, 0x08, 0, 0}},
+		{"report target port groups", ReportTargetPortGroupsCDB(0x400), []uint8{0xa3, 0x2a, 0, 0, 0, 0, 0, 0, 0x04, 0x00, 0, 0}},
+		{"persistent reserve in", PersistentReserveInCDB(PRInReadReservation, 0x808), []uint8{0x5e, 0x01, 0, 0, 0, 0, 0, 0x08, 0x08, 0}},
+		{
+			"persistent reserve out",
+			PersistentReserveOutCDB(&PROutParams{ServiceAction: PROutReserve, Type: PRTypeWriteExclusiveRegistrantsOnly}),
+			[]uint8{0x5f, 0x01, 0x05, 0, 0, 0, 0, 0, 0x18, 0},
+		},
+	}
+	for _, tc := range tests {
+		if !bytes.Equal(tc.cdb, tc.expected) {
+			t.Errorf("%s: expected % x, got % x", tc.name, tc.expected, tc.cdb)
+		}
+	}
+}
+
+func TestParseReadCapacity16(t *testing.T) {
+	b := make([]byte, 32)
+	b[5], b[6], b[7] = 0x3f, 0xff, 0xff // last LBA 0x3fffff
+	b[10] = 0x02                        // 512 byte blocks
+	b[12] = 0x03                        // protection type 2 (P_TYPE 1), enabled
+	b[13] = 0x03                        // 8 logical blocks per physical block
+	b[14], b[15] = 0xc0, 0x07           // LBPME, LBPRZ, lowest aligned LBA 7
+	capacity, err := ParseReadCapacity16(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity.Blocks() != 0x400000 || capacity.BlockLength != 512 || capacity.SizeInBytes() != 2<<30 {
+		t.Errorf("unexpected capacity %+v", capacity)
+	}
+	if !capacity.ProtectionEnabled || capacity.ProtectionType != 1 {
+		t.Errorf("unexpected protection %+v", capacity)
+	}
+	if capacity.PhysicalBlockLength() != 4096 || capacity.LowestAlignedLBA != 7 {
+		t.Errorf("unexpected physical block layout %+v", capacity)
+	}
+	if !capacity.ThinProvisioningEnabled || !capacity.ThinProvisioningReadZero {
+		t.Errorf("unexpected thin provisioning flags %+v", capacity)
+	}
+	if _, err = ParseReadCapacity16(b[:16]); err == nil {
+		t.Error("expected an error for short data")
+	}
+	if _, err = ParseReadCapacity16(make([]byte, 32)); err == nil {
+		t.Error("expected an error for a zero block length")
+	}
+}
+
+func TestParseReportLuns(t *testing.T) {
+	b := []byte{
+		0x00, 0x00, 0x00, 0x18, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // peripheral LUN 0
+		0x00, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // peripheral LUN 5
+		0x41, 0x2c, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // flat space LUN 300
+	}
+	luns, err := ParseReportLuns(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []uint64
+	for _, lun := range luns {
+		numbers = append(numbers, lun.Number)
+	}
+	if !reflect.DeepEqual(numbers, []uint64{0, 5, 0x412c}) {
+		t.Errorf("unexpected LUN numbers %v", numbers)
+	}
+	if _, err = ParseReportLuns(b[:24]); err == nil {
+		t.Error("expected an error for a truncated LUN list")
+	}
+	if _, err = ParseReportLuns([]byte{0, 0, 0, 0x04, 0, 0, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Error("expected an error for a misaligned LUN list")
+	}
+}
+
+func TestParseReportTargetPortGroups(t *testing.T) {
+	descriptors := []byte{
+		// preferred active/optimized group 1 with ports 1 and 2
+		0x80, 0x8f, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+		// standby group 2 with port 3
+		0x02, 0x8f, 0x00, 0x02, 0x00, 0x02, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x03,
+	}
+	extended := append([]byte{0x00, 0x00, 0x00, 0x20, 0x10, 0x3c, 0x00, 0x00}, descriptors...)
+	lengthOnly := append([]byte{0x00, 0x00, 0x00, 0x1c}, descriptors...)
+
+	for name, b := range map[string][]byte{"extended": extended, "length only": lengthOnly} {
+		groups, err := ParseReportTargetPortGroups(b)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err.Error())
+		}
+		if len(groups.Groups) != 2 {
+			t.Fatalf("%s: expected 2 groups, got %d", name, len(groups.Groups))
+		}
+		first, second := groups.Groups[0], groups.Groups[1]
+		if !first.Preferred || first.ID != 1 || first.AccessStateName() != "active/optimized" || !reflect.DeepEqual(first.RelativeTargetPortIDs, []uint16{1, 2}) {
+			t.Errorf("%s: unexpected first group %+v", name, first)
+		}
+		if second.Preferred || second.AccessState != AccessStateStandby || second.StatusCode != 0x02 || !reflect.DeepEqual(second.RelativeTargetPortIDs, []uint16{3}) {
+			t.Errorf("%s: unexpected second group %+v", name, second)
+		}
+	}
+	if groups, _ := ParseReportTargetPortGroups(extended); groups.ImplicitTransitionTime != 0x3c {
+		t.Errorf("expected implicit transition time 60, got %d", groups.ImplicitTransitionTime)
+	}
+	if _, err := ParseReportTargetPortGroups(lengthOnly[:20]); err == nil {
+		t.Error("expected an error for truncated data")
+	}
+}
+
+func TestPersistentReservationData(t *testing.T) {
+	keys, err := ParsePRKeys([]byte{
+		0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x10,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x12, 0x34,
+		0xde, 0xad, 0xbe, 0xef, 0x00, 0x00, 0x00, 0x01,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.Generation != 7 || !reflect.DeepEqual(keys.Keys, []uint64{0x1234, 0xdeadbeef00000001}) {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+
+	reservation, err := ParsePRReservation([]byte{
+		0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x10,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x12, 0x34,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reservation.Reserved || reservation.Key != 0x1234 || reservation.Type != PRTypeWriteExclusiveRegistrantsOnly {
+		t.Errorf("unexpected reservation %+v", reservation)
+	}
+	if PRTypeName(reservation.Type) != "write-exclusive-registrants-only" {
+		t.Errorf("unexpected reservation type name %s", PRTypeName(reservation.Type))
+	}
+	if prType, err := ParsePRType("write-exclusive-registrants-only"); err != nil || prType != reservation.Type {
+		t.Errorf("unexpected reservation type %d %v", prType, err)
+	}
+	if _, err = ParsePRType("shared"); err == nil {
+		t.Error("expected an error for an unknown reservation type")
+	}
+	if reservation, err = ParsePRReservation([]byte{0, 0, 0, 0x09, 0, 0, 0, 0}); err != nil || reservation.Reserved {
+		t.Errorf("expected no reservation, got %+v %v", reservation, err)
+	}
+
+	params := EncodePROutParameterList(&PROutParams{Key: 0x1234, ServiceActionKey: 0x5678, AllTargetPorts: true, ActivatePersistThroughPowerLoss: true})
+	expected := []byte{
+		0, 0, 0, 0, 0, 0, 0x12, 0x34,
+		0, 0, 0, 0, 0, 0, 0x56, 0x78,
+		0, 0, 0, 0, 0x05, 0, 0, 0,
+	}
+	if !bytes.Equal(params, expected) {
+		t.Errorf("expected parameter list % x, got % x", expected, params)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package sgio
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFixedSense(t *testing.T) {
+	// ILLEGAL REQUEST, INVALID FIELD IN CDB, field pointer to byte 2 of the CDB
+	b := []byte{0xf0, 0x00, 0x05, 0x00, 0x00, 0x10, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x00, 0x24, 0x00, 0x00, 0xc0, 0x00, 0x02}
+	sense, err := ParseSenseData(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sense.ResponseCode != SenseFixedCurrent || sense.Deferred {
+		t.Errorf("unexpected response code 0x%x deferred %v", sense.ResponseCode, sense.Deferred)
+	}
+	if sense.SenseKey != SenseKeyIllegalRequest || sense.ASC != 0x24 || sense.ASCQ != 0x00 {
+		t.Errorf("unexpected sense %s", sense)
+	}
+	if !sense.InformationValid || sense.Information != 0x1000 {
+		t.Errorf("unexpected information %v 0x%x", sense.InformationValid, sense.Information)
+	}
+	if !sense.SenseKeySpecificValid || sense.SenseKeySpecific != [3]byte{0xc0, 0x00, 0x02} {
+		t.Errorf("unexpected sense key specific %v %v", sense.SenseKeySpecificValid, sense.SenseKeySpecific)
+	}
+	if !strings.Contains(sense.String(), "ILLEGAL REQUEST") || !strings.Contains(sense.String(), "INVALID FIELD IN CDB") {
+		t.Errorf("unexpected sense string %s", sense)
+	}
+}
+
+func TestParseDescriptorSense(t *testing.T) {
+	// deferred UNIT ATTENTION, POWER ON OCCURRED with information and sense key specific descriptors
+	b := []byte{
+		0x73, 0x06, 0x29, 0x00, 0x00, 0x00, 0x00, 0x14,
+		0x00, 0x0a, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04,
+		0x02, 0x06, 0x00, 0x00, 0x80, 0x12, 0x34, 0x00,
+	}
+	sense, err := ParseSenseData(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sense.Deferred || sense.SenseKey != SenseKeyUnitAttention || sense.ASC != 0x29 {
+		t.Errorf("unexpected sense %+v", sense)
+	}
+	if len(sense.Descriptors) != 2 {
+		t.Fatalf("expected 2 descriptors, got %+v", sense.Descriptors)
+	}
+	if !sense.InformationValid || sense.Information != 0x01020304 {
+		t.Errorf("unexpected information %v 0x%x", sense.InformationValid, sense.Information)
+	}
+	if !sense.SenseKeySpecificValid || sense.SenseKeySpecific != [3]byte{0x80, 0x12, 0x34} {
+		t.Errorf("unexpected sense key specific %v %v", sense.SenseKeySpecificValid, sense.SenseKeySpecific)
+	}
+}
+
+func TestParseSenseErrors(t *testing.T) {
+	tests := map[string][]byte{
+		"empty":              {},
+		"short fixed":        {0x70, 0x00, 0x05},
+		"short descriptor":   {0x72, 0x05},
+		"unsupported":        {0x7f, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		"descriptor overrun": {0x72, 0x05, 0x24, 0x00, 0x00, 0x00, 0x00, 0x04, 0x00, 0x0a, 0x00, 0x00},
+	}
+	for name, b := range tests {
+		if _, err := ParseSenseData(b); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	sense := []byte{0x70, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x00, 0x04, 0x01}
+	err := newCommandError(OpTestUnitReady, StatusCheckCondition, sense)
+	if err.Sense == nil || err.Sense.SenseKey != SenseKeyNotReady {
+		t.Fatalf("expected NOT READY sense, got %+v", err.Sense)
+	}
+	if !strings.Contains(err.Error(), "BECOMING READY") {
+		t.Errorf("unexpected error %s", err)
+	}
+	conflict := newCommandError(OpPersistentReserveOut, StatusReservationConflict, nil)
+	if !conflict.IsReservationConflict() || conflict.Sense != nil {
+		t.Errorf("expected a reservation conflict without sense, got %+v", conflict)
+	}
+	if err.IsUnsupported() || conflict.IsUnsupported() {
+		t.Error("expected NOT READY and a reservation conflict to be supported commands")
+	}
+	illegal := []byte{0x70, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x00, 0x20, 0x00}
+	if unsupported := newCommandError(OpPersistentReserveIn, StatusCheckCondition, illegal); !unsupported.IsUnsupported() {
+		t.Errorf("expected an invalid command operation code to be unsupported, got %+v", unsupported.Sense)
+	}
+}
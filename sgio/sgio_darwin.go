@@ -26,3 +26,8 @@ func GetNOSVersion(device string) (string, error) {
 func TestUnitReady(device string) error {
 	return fmt.Errorf("not implemented")
 }
+
+// execCommand is not supported on this platform
+func execCommand(cmdBlk []uint8, buf []byte, device string, direction dataDirection) error {
+	return fmt.Errorf("not implemented")
+}
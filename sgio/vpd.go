@@ -0,0 +1,255 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package sgio
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// VPD page codes
+const (
+	VpdSupportedPages           = 0x00
+	VpdUnitSerialNumber         = 0x80
+	VpdDeviceIdentification     = 0x83
+	VpdBlockLimits              = 0xB0
+	VpdLogicalBlockProvisioning = 0xB2
+)
+
+const vpdHeaderLen = 4
+
+// Designator code sets
+const (
+	CodeSetBinary = 0x1
+	CodeSetASCII  = 0x2
+	CodeSetUTF8   = 0x3
+)
+
+// Designator associations
+const (
+	AssociationLogicalUnit  = 0x0
+	AssociationTargetPort   = 0x1
+	AssociationTargetDevice = 0x2
+)
+
+// Designator types
+const (
+	DesignatorVendorSpecific       = 0x0
+	DesignatorT10VendorID          = 0x1
+	DesignatorEUI64                = 0x2
+	DesignatorNAA                  = 0x3
+	DesignatorRelativeTargetPort   = 0x4
+	DesignatorTargetPortGroup      = 0x5
+	DesignatorLogicalUnitGroup     = 0x6
+	DesignatorMD5LogicalUnit       = 0x7
+	DesignatorSCSINameString       = 0x8
+	DesignatorProtocolSpecificPort = 0x9
+)
+
+// Logical block provisioning types reported in VPD page 0xB2
+const (
+	ProvisioningTypeFull     = 0x0
+	ProvisioningTypeResource = 0x1
+	ProvisioningTypeThin     = 0x2
+)
+
+// Designator is a single designation descriptor of the device identification VPD page
+type Designator struct {
+	ProtocolIdentifier uint8
+	CodeSet            uint8
+	PIV                bool
+	Association        uint8
+	Type               uint8
+	Value              []byte
+}
+
+// String returns the designator as text for ASCII and UTF-8 code sets, and as lowercase hex otherwise
+func (d *Designator) String() string {
+	if d.CodeSet == CodeSetASCII || d.CodeSet == CodeSetUTF8 {
+		return strings.TrimRight(string(d.Value), "\x00 ")
+	}
+	return hex.EncodeToString(d.Value)
+}
+
+// PortIdentifier returns the 16 bit relative target port, target port group or logical unit group identifier
+func (d *Designator) PortIdentifier() (uint16, error) {
+	switch d.Type {
+	case DesignatorRelativeTargetPort, DesignatorTargetPortGroup, DesignatorLogicalUnitGroup:
+		if len(d.Value) < 4 {
+			return 0, fmt.Errorf("designator type 0x%x is too short, %d bytes", d.Type, len(d.Value))
+		}
+		return binary.BigEndian.Uint16(d.Value[2:4]), nil
+	}
+	return 0, fmt.Errorf("designator type 0x%x does not carry a port identifier", d.Type)
+}
+
+// DeviceIdentification is the decoded device identification VPD page (0x83)
+type DeviceIdentification struct {
+	Designators []*Designator
+}
+
+// Find returns the first designator with the given association and type, nil if none is reported
+func (id *DeviceIdentification) Find(association, designatorType uint8) *Designator {
+	for _, d := range id.Designators {
+		if d.Association == association && d.Type == designatorType {
+			return d
+		}
+	}
+	return nil
+}
+
+// NAA returns the hex encoded NAA designator of the logical unit, empty if the device does not report one
+func (id *DeviceIdentification) NAA() string {
+	if d := id.Find(AssociationLogicalUnit, DesignatorNAA); d != nil {
+		return d.String()
+	}
+	return ""
+}
+
+// BlockLimits is the decoded block limits VPD page (0xB0)
+type BlockLimits struct {
+	WriteSameNonZero                 bool
+	MaxCompareAndWriteLength         uint8
+	OptimalTransferLengthGranularity uint16
+	MaxTransferLength                uint32
+	OptimalTransferLength            uint32
+	MaxPrefetchLength                uint32
+	MaxUnmapLBACount                 uint32
+	MaxUnmapBlockDescriptorCount     uint32
+	OptimalUnmapGranularity          uint32
+	UnmapGranularityAlignmentValid   bool
+	UnmapGranularityAlignment        uint32
+	MaxWriteSameLength               uint64
+}
+
+// LogicalBlockProvisioning is the decoded logical block provisioning VPD page (0xB2)
+type LogicalBlockProvisioning struct {
+	ThresholdExponent uint8
+	// LBPU indicates UNMAP support
+	LBPU bool
+	// LBPWS indicates WRITE SAME(16) with UNMAP support
+	LBPWS bool
+	// LBPWS10 indicates WRITE SAME(10) with UNMAP support
+	LBPWS10 bool
+	// LBPRZ is the value returned when reading unmapped blocks
+	LBPRZ               uint8
+	ANCSupported        bool
+	DescriptorPresent   bool
+	MinimumPercentage   uint8
+	ProvisioningType    uint8
+	ThresholdPercentage uint8
+}
+
+// IsThin returns true if the logical unit is thin provisioned
+func (p *LogicalBlockProvisioning) IsThin() bool {
+	return p.ProvisioningType == ProvisioningTypeThin
+}
+
+// vpdPayload validates the VPD page header and returns the page payload following it
+func vpdPayload(b []byte, page uint8) ([]byte, error) {
+	if len(b) < vpdHeaderLen {
+		return nil, fmt.Errorf("vpd page 0x%02x too short, %d bytes", page, len(b))
+	}
+	if b[1] != page {
+		return nil, fmt.Errorf("expected vpd page 0x%02x, got 0x%02x", page, b[1])
+	}
+	end := vpdHeaderLen + int(binary.BigEndian.Uint16(b[2:4]))
+	if end > len(b) {
+		return nil, fmt.Errorf("vpd page 0x%02x truncated, page length %d exceeds %d bytes returned", page, end, len(b))
+	}
+	return b[vpdHeaderLen:end], nil
+}
+
+// ParseSupportedVPDPages decodes the supported VPD pages page (0x00)
+func ParseSupportedVPDPages(b []byte) ([]uint8, error) {
+	payload, err := vpdPayload(b, VpdSupportedPages)
+	if err != nil {
+		return nil, err
+	}
+	return append([]uint8(nil), payload...), nil
+}
+
+// ParseDeviceIdentification decodes the device identification VPD page (0x83)
+func ParseDeviceIdentification(b []byte) (*DeviceIdentification, error) {
+	payload, err := vpdPayload(b, VpdDeviceIdentification)
+	if err != nil {
+		return nil, err
+	}
+	id := &DeviceIdentification{}
+	for i := 0; i < len(payload); {
+		if i+4 > len(payload) {
+			return nil, fmt.Errorf("designation descriptor header at offset %d is truncated", i)
+		}
+		end := i + 4 + int(payload[i+3])
+		if end > len(payload) {
+			return nil, fmt.Errorf("designation descriptor at offset %d overruns the page", i)
+		}
+		id.Designators = append(id.Designators, &Designator{
+			ProtocolIdentifier: payload[i] >> 4,
+			CodeSet:            payload[i] & 0x0f,
+			PIV:                payload[i+1]&0x80 != 0,
+			Association:        (payload[i+1] >> 4) & 0x3,
+			Type:               payload[i+1] & 0x0f,
+			Value:              append([]byte(nil), payload[i+4:end]...),
+		})
+		i = end
+	}
+	return id, nil
+}
+
+// ParseBlockLimits decodes the block limits VPD page (0xB0), fields beyond a short page are left zero
+func ParseBlockLimits(b []byte) (*BlockLimits, error) {
+	payload, err := vpdPayload(b, VpdBlockLimits)
+	if err != nil {
+		return nil, err
+	}
+	// SBC-2 devices report only the transfer length fields
+	if len(payload) < 12 {
+		return nil, fmt.Errorf("block limits page too short, %d bytes", len(payload))
+	}
+	limits := &BlockLimits{
+		WriteSameNonZero:                 payload[0]&0x01 != 0,
+		MaxCompareAndWriteLength:         payload[1],
+		OptimalTransferLengthGranularity: binary.BigEndian.Uint16(payload[2:4]),
+		MaxTransferLength:                binary.BigEndian.Uint32(payload[4:8]),
+		OptimalTransferLength:            binary.BigEndian.Uint32(payload[8:12]),
+	}
+	if len(payload) >= 32 {
+		limits.MaxPrefetchLength = binary.BigEndian.Uint32(payload[12:16])
+		limits.MaxUnmapLBACount = binary.BigEndian.Uint32(payload[16:20])
+		limits.MaxUnmapBlockDescriptorCount = binary.BigEndian.Uint32(payload[20:24])
+		limits.OptimalUnmapGranularity = binary.BigEndian.Uint32(payload[24:28])
+		alignment := binary.BigEndian.Uint32(payload[28:32])
+		limits.UnmapGranularityAlignmentValid = alignment&0x80000000 != 0
+		limits.UnmapGranularityAlignment = alignment & 0x7fffffff
+	}
+	if len(payload) >= 40 {
+		limits.MaxWriteSameLength = binary.BigEndian.Uint64(payload[32:40])
+	}
+	return limits, nil
+}
+
+// ParseLogicalBlockProvisioning decodes the logical block provisioning VPD page (0xB2)
+func ParseLogicalBlockProvisioning(b []byte) (*LogicalBlockProvisioning, error) {
+	payload, err := vpdPayload(b, VpdLogicalBlockProvisioning)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) < 4 {
+		return nil, fmt.Errorf("logical block provisioning page too short, %d bytes", len(payload))
+	}
+	return &LogicalBlockProvisioning{
+		ThresholdExponent:   payload[0],
+		LBPU:                payload[1]&0x80 != 0,
+		LBPWS:               payload[1]&0x40 != 0,
+		LBPWS10:             payload[1]&0x20 != 0,
+		LBPRZ:               (payload[1] >> 2) & 0x7,
+		ANCSupported:        payload[1]&0x02 != 0,
+		DescriptorPresent:   payload[1]&0x01 != 0,
+		MinimumPercentage:   payload[2] >> 3,
+		ProvisioningType:    payload[2] & 0x7,
+		ThresholdPercentage: payload[3],
+	}, nil
+}
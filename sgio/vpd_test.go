@@ -0,0 +1,128 @@
+// Copyright 2019 Hewlett Packard Enterprise Development LP
+
+package sgio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSupportedVPDPages(t *testing.T) {
+	pages, err := ParseSupportedVPDPages([]byte{0x00, 0x00, 0x00, 0x05, 0x00, 0x80, 0x83, 0xb0, 0xb2, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pages, []uint8{0x00, 0x80, 0x83, 0xb0, 0xb2}) {
+		t.Errorf("unexpected pages %v", pages)
+	}
+	if _, err = ParseSupportedVPDPages([]byte{0x00, 0x83, 0x00, 0x00}); err == nil {
+		t.Error("expected an error for the wrong page code")
+	}
+	if _, err = ParseSupportedVPDPages([]byte{0x00, 0x00, 0x00, 0x08, 0x00}); err == nil {
+		t.Error("expected an error for a truncated page")
+	}
+}
+
+func TestParseDeviceIdentification(t *testing.T) {
+	b := []byte{
+		0x00, 0x83, 0x00, 0x34,
+		// NAA 6, binary, logical unit
+		0x01, 0x03, 0x00, 0x10,
+		0x6d, 0x03, 0x9e, 0xa0, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2a,
+		// T10 vendor ID, ASCII, logical unit
+		0x02, 0x01, 0x00, 0x0c,
+		'N', 'i', 'm', 'b', 'l', 'e', ' ', ' ', 's', 'n', '1', 0x00,
+		// relative target port 3, iSCSI, target port, PIV
+		0x51, 0x94, 0x00, 0x04,
+		0x00, 0x00, 0x00, 0x03,
+		// target port group 2, binary, target port
+		0x01, 0x15, 0x00, 0x04,
+		0x00, 0x00, 0x00, 0x02,
+	}
+	id, err := ParseDeviceIdentification(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id.Designators) != 4 {
+		t.Fatalf("expected 4 designators, got %d", len(id.Designators))
+	}
+	if naa := id.NAA(); naa != "6d039ea000000001000000000000002a" {
+		t.Errorf("unexpected NAA %s", naa)
+	}
+	if vendor := id.Find(AssociationLogicalUnit, DesignatorT10VendorID); vendor == nil || vendor.String() != "Nimble  sn1" {
+		t.Errorf("unexpected T10 vendor designator %+v", vendor)
+	}
+	port := id.Find(AssociationTargetPort, DesignatorRelativeTargetPort)
+	if port == nil || !port.PIV || port.ProtocolIdentifier != 0x5 {
+		t.Fatalf("unexpected relative target port designator %+v", port)
+	}
+	if portID, err := port.PortIdentifier(); err != nil || portID != 3 {
+		t.Errorf("expected relative target port 3, got %d %v", portID, err)
+	}
+	group := id.Find(AssociationTargetPort, DesignatorTargetPortGroup)
+	if groupID, err := group.PortIdentifier(); err != nil || groupID != 2 {
+		t.Errorf("expected target port group 2, got %d %v", groupID, err)
+	}
+	if _, err = id.Designators[0].PortIdentifier(); err == nil {
+		t.Error("expected an error for the port identifier of an NAA designator")
+	}
+
+	// a descriptor length running past the page is rejected
+	if _, err = ParseDeviceIdentification([]byte{0x00, 0x83, 0x00, 0x06, 0x01, 0x03, 0x00, 0x10, 0x6d, 0x03}); err == nil {
+		t.Error("expected an error for an overrunning designator")
+	}
+}
+
+func TestParseBlockLimits(t *testing.T) {
+	b := make([]byte, 64)
+	b[1], b[3] = VpdBlockLimits, 0x3c
+	b[4] = 0x01                                         // WSNZ
+	b[5] = 0x01                                         // max compare and write length
+	b[7] = 0x08                                         // optimal transfer length granularity
+	b[10] = 0x80                                        // max transfer length
+	b[20], b[21], b[22], b[23] = 0xff, 0xff, 0xff, 0xff // max unmap lba count
+	b[27] = 0x01                                        // max unmap block descriptor count
+	b[31] = 0x10                                        // optimal unmap granularity
+	b[32] = 0x80                                        // unmap granularity alignment valid
+	b[43] = 0x20                                        // max write same length
+	limits, err := ParseBlockLimits(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &BlockLimits{
+		WriteSameNonZero:                 true,
+		MaxCompareAndWriteLength:         1,
+		OptimalTransferLengthGranularity: 8,
+		MaxTransferLength:                0x8000,
+		MaxUnmapLBACount:                 0xffffffff,
+		MaxUnmapBlockDescriptorCount:     1,
+		OptimalUnmapGranularity:          0x10,
+		UnmapGranularityAlignmentValid:   true,
+		MaxWriteSameLength:               0x20,
+	}
+	if !reflect.DeepEqual(limits, expected) {
+		t.Errorf("expected %+v, got %+v", expected, limits)
+	}
+
+	// SBC-2 devices return the short form of the page
+	short := []byte{0x00, 0xb0, 0x00, 0x0c, 0, 0, 0, 0x08, 0, 0, 0x01, 0, 0, 0, 0, 0}
+	if limits, err = ParseBlockLimits(short); err != nil || limits.MaxTransferLength != 0x100 || limits.MaxUnmapLBACount != 0 {
+		t.Errorf("unexpected short block limits %+v %v", limits, err)
+	}
+}
+
+func TestParseLogicalBlockProvisioning(t *testing.T) {
+	provisioning, err := ParseLogicalBlockProvisioning([]byte{0x00, 0xb2, 0x00, 0x04, 0x00, 0xe6, 0x02, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !provisioning.LBPU || !provisioning.LBPWS || !provisioning.LBPWS10 || provisioning.LBPRZ != 1 || !provisioning.ANCSupported {
+		t.Errorf("unexpected provisioning flags %+v", provisioning)
+	}
+	if !provisioning.IsThin() {
+		t.Error("expected a thin provisioned logical unit")
+	}
+	if _, err = ParseLogicalBlockProvisioning([]byte{0x00, 0xb2, 0x00, 0x02, 0x00, 0x00}); err == nil {
+		t.Error("expected an error for a short page")
+	}
+}
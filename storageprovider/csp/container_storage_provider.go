@@ -5,17 +5,20 @@
 package csp
 
 import (
-	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/grpc/codes"
 	grpcstatus "google.golang.org/grpc/status"
 
+	"github.com/hpe-storage/common-host-libs/cert"
 	"github.com/hpe-storage/common-host-libs/concurrent"
 	"github.com/hpe-storage/common-host-libs/connectivity"
 	"github.com/hpe-storage/common-host-libs/jsonutil"
@@ -35,6 +38,11 @@ const (
 
 var (
 	loginMutex = concurrent.NewMapMutex()
+
+	// TrustStorePath is where the certificates of on-array CSPs are pinned on first use
+	TrustStorePath  = cert.DefaultTrustStorePath
+	trustStore      *cert.TrustStore
+	trustStoreMutex sync.Mutex
 )
 
 // ErrorsPayload is a serializer struct for representing a valid JSON API errors payload.
@@ -324,7 +332,7 @@ func (provider *ContainerStorageProvider) DeleteSnapshotGroup(id string) error {
 
 // CreateVolumeGroup creates a volume group on the CSP
 func (provider *ContainerStorageProvider) CreateVolumeGroup(name, description string, opts map[string]interface{}) (*model.VolumeGroup, error) {
-	log.Tracef(">>>>> CreateVolumeGroup, name: %s, opts: %+v", name, opts)
+	log.Tracef(">>>>> CreateVolumeGroup, name: %s, opts: %v", name, log.Redacted(opts))
 	defer log.Trace("<<<<< CreateVolumeGroup")
 
 	response := &model.VolumeGroup{}
@@ -450,7 +458,7 @@ func (provider *ContainerStorageProvider) CloneVolume(name, description, sourceI
 			Config:      opts,
 		}
 	}
-	log.Tracef("Clone requested with volume config: %+v", volume)
+	log.Tracef("Clone requested with volume config: %v", log.Redacted(volume))
 
 	response := &model.Volume{}
 	var errorResponse *ErrorsPayload
@@ -903,6 +911,21 @@ func (provider *ContainerStorageProvider) DeleteSnapshot(id string) error {
 	return err
 }
 
+// getTrustStore loads the trust store of on-array CSP certificates once
+func getTrustStore() (*cert.TrustStore, error) {
+	trustStoreMutex.Lock()
+	defer trustStoreMutex.Unlock()
+	if trustStore == nil {
+		store, err := cert.NewTrustStore(TrustStorePath)
+		if err != nil {
+			log.Errorf("unable to load trust store %s, %s", TrustStorePath, err.Error())
+			return nil, err
+		}
+		trustStore = store
+	}
+	return trustStore, nil
+}
+
 // get CSP client
 func getCspClient(credentials *storageprovider.Credentials) (*connectivity.Client, error) {
 
@@ -914,19 +937,22 @@ func getCspClient(credentials *storageprovider.Credentials) (*connectivity.Clien
 		if credentials.ServicePort == 0 {
 			credentials.ServicePort = storageprovider.DefaultServicePort
 		}
-		cspURI := fmt.Sprintf("https://%s:%d%s", credentials.Backend, credentials.ServicePort, credentials.ContextPath)
+		address := net.JoinHostPort(credentials.Backend, strconv.Itoa(credentials.ServicePort))
+		cspURI := fmt.Sprintf("https://%s%s", address, credentials.ContextPath)
 
 		log.Tracef(">>>>> getCspClient (direct-connect) using URI %s and username %s", cspURI, credentials.Username)
 		defer log.Trace("<<<<< getCspClient")
 
-		// Setup HTTPS client
-		cspClient := connectivity.NewHTTPSClientWithTimeout(
+		store, err := getTrustStore()
+		if err != nil {
+			return nil, err
+		}
+
+		// Setup HTTPS client verifying the pinned array certificate
+		cspClient := connectivity.NewPinnedHTTPSClientWithTimeout(
 			cspURI,
-			&http.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: true,
-				},
-			},
+			address,
+			store,
 			CspClientTimeout,
 		)
 
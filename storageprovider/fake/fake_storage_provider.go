@@ -5,16 +5,21 @@ package fake
 import (
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/hpe-storage/common-host-libs/model"
 )
 
 // StorageProvider is an implementor of the StorageProvider interface
 type StorageProvider struct {
+	lock           sync.Mutex
+	serialCount    int
 	volumes        map[string]model.Volume
 	snapshots      map[string]model.Snapshot
 	volumeGroups   map[string]model.VolumeGroup
 	snapshotGroups map[string]model.SnapshotGroup
+	// hosts each volume is published to, keyed by volume id
+	publishedHosts map[string]map[string]bool
 }
 
 // NewFakeStorageProvider returns a fake storage provider
@@ -24,6 +29,32 @@ func NewFakeStorageProvider() *StorageProvider {
 		snapshots:      make(map[string]model.Snapshot),
 		volumeGroups:   make(map[string]model.VolumeGroup),
 		snapshotGroups: make(map[string]model.SnapshotGroup),
+		publishedHosts: make(map[string]map[string]bool),
+	}
+}
+
+// nextSerialNumber returns a unique fake serial number, caller must hold the lock
+func (provider *StorageProvider) nextSerialNumber() string {
+	provider.serialCount++
+	return fmt.Sprintf("fake%028x", provider.serialCount)
+}
+
+// copyVolume returns a copy of the stored volume along with its publish state, caller must hold the lock
+func (provider *StorageProvider) copyVolume(volume model.Volume) *model.Volume {
+	config := make(map[string]interface{})
+	for key, value := range volume.Config {
+		config[key] = value
+	}
+	return &model.Volume{
+		ID:           volume.ID,
+		Name:         volume.Name,
+		Size:         volume.Size,
+		Description:  volume.Description,
+		BaseSnapID:   volume.BaseSnapID,
+		Clone:        volume.Clone,
+		SerialNumber: volume.SerialNumber,
+		Config:       config,
+		Published:    len(provider.publishedHosts[volume.ID]) != 0,
 	}
 }
 
@@ -44,21 +75,27 @@ func (provider *StorageProvider) GetNodeContext(nodeID string) (*model.Node, err
 
 // CreateVolume returns a fake volume
 func (provider *StorageProvider) CreateVolume(name, description string, size int64, opts map[string]interface{}) (*model.Volume, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
 	if _, ok := provider.volumes[name]; ok {
 		return nil, fmt.Errorf("Volume named %s already exists", name)
 	}
 	fakeVolume := model.Volume{
-		ID:     name,
-		Name:   name,
-		Size:   size,
-		Config: opts,
+		ID:           name,
+		Name:         name,
+		Size:         size,
+		Description:  description,
+		SerialNumber: provider.nextSerialNumber(),
+		Config:       opts,
 	}
 	provider.volumes[name] = fakeVolume
-	return &fakeVolume, nil
+	return provider.copyVolume(fakeVolume), nil
 }
 
 // CreateVolumeGroup returns a fake volume group
 func (provider *StorageProvider) CreateVolumeGroup(name, description string, opts map[string]interface{}) (*model.VolumeGroup, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
 	if _, ok := provider.volumeGroups[name]; ok {
 		return nil, fmt.Errorf("Volume Group named %s already exists", name)
 	}
@@ -73,6 +110,8 @@ func (provider *StorageProvider) CreateVolumeGroup(name, description string, opt
 
 // CreateSnapshotGroup returns a fake volume group
 func (provider *StorageProvider) CreateSnapshotGroup(name, sourceVolumeGroupID string, opts map[string]interface{}) (*model.SnapshotGroup, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
 	if _, ok := provider.snapshotGroups[name]; ok {
 		return nil, fmt.Errorf("Snapshot Group named %s already exists", name)
 	}
@@ -87,6 +126,8 @@ func (provider *StorageProvider) CreateSnapshotGroup(name, sourceVolumeGroupID s
 
 // CloneVolume returns a fake volume
 func (provider *StorageProvider) CloneVolume(name, description, sourceID, snapshotID string, size int64, opts map[string]interface{}) (*model.Volume, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
 	if _, ok := provider.volumes[name]; ok {
 		return nil, fmt.Errorf("Volume named %s already exists", name)
 	}
@@ -94,16 +135,16 @@ func (provider *StorageProvider) CloneVolume(name, description, sourceID, snapsh
 	var snapshot *model.Snapshot
 	var err error
 	if sourceID == "" {
-		snapshot, err = provider.GetSnapshot(snapshotID)
-		if err != nil {
-			return nil, err
-		}
+		snapshot = provider.getSnapshot(snapshotID)
 		if snapshot == nil {
 			return nil, errors.New("Could not find snapshot with id " + snapshotID)
 		}
 	} else if snapshotID == "" {
+		if _, ok := provider.volumes[sourceID]; !ok {
+			return nil, fmt.Errorf("Could not find volume with id %s", sourceID)
+		}
 		snapshotName := "testSnapshot"
-		snapshot, err = provider.CreateSnapshot(snapshotName, snapshotName, sourceID, nil)
+		snapshot, err = provider.createSnapshot(snapshotName, snapshotName, sourceID, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -113,19 +154,29 @@ func (provider *StorageProvider) CloneVolume(name, description, sourceID, snapsh
 	}
 
 	fakeClone := model.Volume{
-		ID:         name,
-		Name:       name,
-		Size:       size,
-		BaseSnapID: snapshot.ID,
+		ID:           name,
+		Name:         name,
+		Size:         size,
+		Description:  description,
+		BaseSnapID:   snapshot.ID,
+		Clone:        true,
+		SerialNumber: provider.nextSerialNumber(),
+		Config:       opts,
 	}
 	provider.volumes[name] = fakeClone
-	return &fakeClone, nil
+	return provider.copyVolume(fakeClone), nil
 }
 
 // DeleteVolume removes a fake volume
 func (provider *StorageProvider) DeleteVolume(id string, force bool) error {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
 	if _, ok := provider.volumes[id]; ok {
+		if len(provider.publishedHosts[id]) != 0 && !force {
+			return fmt.Errorf("Volume with id %s is still published", id)
+		}
 		delete(provider.volumes, id)
+		delete(provider.publishedHosts, id)
 		return nil
 	}
 
@@ -134,6 +185,8 @@ func (provider *StorageProvider) DeleteVolume(id string, force bool) error {
 
 // DeleteVolumeGroup removes a fake volumeGroup
 func (provider *StorageProvider) DeleteVolumeGroup(id string) error {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
 	if _, ok := provider.volumeGroups[id]; ok {
 		delete(provider.volumeGroups, id)
 		return nil
@@ -143,6 +196,8 @@ func (provider *StorageProvider) DeleteVolumeGroup(id string) error {
 
 // DeleteSnapshotGroup removes a fake snapshotGroup
 func (provider *StorageProvider) DeleteSnapshotGroup(id string) error {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
 	if _, ok := provider.snapshotGroups[id]; ok {
 		delete(provider.snapshotGroups, id)
 		return nil
@@ -150,27 +205,42 @@ func (provider *StorageProvider) DeleteSnapshotGroup(id string) error {
 	return fmt.Errorf("Could not find snapshot group with id %s", id)
 }
 
-// PublishVolume returns fake publish data
+// PublishVolume returns fake publish data and records the host the volume is published to
 func (provider *StorageProvider) PublishVolume(id, hostUUID, accessProtocol string) (*model.PublishInfo, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+	serialNumber := "eui.fake"
+	if volume, ok := provider.volumes[id]; ok {
+		serialNumber = volume.SerialNumber
+		if provider.publishedHosts[id] == nil {
+			provider.publishedHosts[id] = make(map[string]bool)
+		}
+		provider.publishedHosts[id][hostUUID] = true
+	}
 	return &model.PublishInfo{
-		SerialNumber: "eui.fake",
+		SerialNumber: serialNumber,
+		AccessInfo: model.AccessInfo{
+			BlockDeviceAccessInfo: model.BlockDeviceAccessInfo{
+				AccessProtocol: accessProtocol,
+			},
+		},
 	}, nil
 }
 
-// UnpublishVolume does nothing
+// UnpublishVolume removes the host from the volume's published hosts
 func (provider *StorageProvider) UnpublishVolume(id, hostUUID string) error {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+	delete(provider.publishedHosts[id], hostUUID)
 	return nil
 }
 
 // GetVolume returns a fake volume from memory
 func (provider *StorageProvider) GetVolume(id string) (*model.Volume, error) {
-	if _, ok := provider.volumes[id]; ok {
-		fakeVolume := provider.volumes[id]
-		return &model.Volume{
-			ID:   fakeVolume.ID,
-			Name: fakeVolume.Name,
-			Size: fakeVolume.Size,
-		}, nil
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+	if fakeVolume, ok := provider.volumes[id]; ok {
+		return provider.copyVolume(fakeVolume), nil
 	}
 
 	return nil, nil
@@ -183,15 +253,12 @@ func (provider *StorageProvider) GetVolumeByName(name string) (*model.Volume, er
 
 // GetVolumes returns the fake volumes saved in the map
 func (provider *StorageProvider) GetVolumes() ([]*model.Volume, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
 	var volumes []*model.Volume
 
 	for _, volume := range provider.volumes {
-		fakeVolume := &model.Volume{
-			ID:   volume.ID,
-			Name: volume.Name,
-			Size: volume.Size,
-		}
-		volumes = append(volumes, fakeVolume)
+		volumes = append(volumes, provider.copyVolume(volume))
 	}
 
 	return volumes, nil
@@ -199,6 +266,8 @@ func (provider *StorageProvider) GetVolumes() ([]*model.Volume, error) {
 
 // GetSnapshots returns the fake snapshots saved in the map
 func (provider *StorageProvider) GetSnapshots(sourceID string) ([]*model.Snapshot, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
 	var snapshots []*model.Snapshot
 
 	for _, snapshot := range provider.snapshots {
@@ -223,6 +292,13 @@ func (provider *StorageProvider) GetSnapshotByName(name string, sourceID string)
 
 // GetSnapshot returns the fake snapshot from memory
 func (provider *StorageProvider) GetSnapshot(id string) (*model.Snapshot, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+	return provider.getSnapshot(id), nil
+}
+
+// getSnapshot returns a copy of the fake snapshot, caller must hold the lock
+func (provider *StorageProvider) getSnapshot(id string) *model.Snapshot {
 	if _, ok := provider.snapshots[id]; ok {
 		fakeSnap := provider.snapshots[id]
 		return &model.Snapshot{
@@ -232,13 +308,20 @@ func (provider *StorageProvider) GetSnapshot(id string) (*model.Snapshot, error)
 			VolumeName: fakeSnap.VolumeName,
 			Size:       fakeSnap.Size,
 			ReadyToUse: fakeSnap.ReadyToUse,
-		}, nil
+		}
 	}
-	return nil, nil
+	return nil
 }
 
 // CreateSnapshot returns a fake snapshot
 func (provider *StorageProvider) CreateSnapshot(name, description, sourceID string, opts map[string]interface{}) (*model.Snapshot, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+	return provider.createSnapshot(name, description, sourceID, opts)
+}
+
+// createSnapshot creates a fake snapshot, caller must hold the lock
+func (provider *StorageProvider) createSnapshot(name, description, sourceID string, opts map[string]interface{}) (*model.Snapshot, error) {
 	if _, ok := provider.snapshots[name]; ok {
 		return nil, fmt.Errorf("Snapshot named %s already exists", name)
 	}
@@ -255,6 +338,8 @@ func (provider *StorageProvider) CreateSnapshot(name, description, sourceID stri
 
 // DeleteSnapshot removes a fake volume
 func (provider *StorageProvider) DeleteSnapshot(id string) error {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
 	if _, ok := provider.snapshots[id]; ok {
 		delete(provider.snapshots, id)
 		return nil
@@ -265,25 +350,34 @@ func (provider *StorageProvider) DeleteSnapshot(id string) error {
 
 // ExpandVolume will expand the fake volume to requested size
 func (provider *StorageProvider) ExpandVolume(id string, requestBytes int64) (*model.Volume, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
 	if _, ok := provider.volumes[id]; !ok {
 		return nil, fmt.Errorf("Could not find volume with id %s", id)
 	}
 	fakeVolume := provider.volumes[id]
 	// update volume, so that new size will be reflected
 	fakeVolume.Size = requestBytes
-	return &fakeVolume, nil
+	provider.volumes[id] = fakeVolume
+	return provider.copyVolume(fakeVolume), nil
 }
 
 // EditVolume will edit the fake volume with requested params
 func (provider *StorageProvider) EditVolume(id string, parameters map[string]interface{}) (*model.Volume, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
 	if _, ok := provider.volumes[id]; !ok {
 		return nil, fmt.Errorf("Could not find volume with id %s", id)
 	}
 
 	// update volume in the map, so that new properties will be reflected
 	fakeVolume := provider.volumes[id]
+	if fakeVolume.Config == nil {
+		fakeVolume.Config = make(map[string]interface{})
+	}
 	for key, value := range parameters {
 		fakeVolume.Config[key] = value
 	}
-	return &fakeVolume, nil
+	provider.volumes[id] = fakeVolume
+	return provider.copyVolume(fakeVolume), nil
 }